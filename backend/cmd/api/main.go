@@ -2,20 +2,34 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"akiba/backend/internal/aml"
 	"akiba/backend/internal/auth"
 	"akiba/backend/internal/config"
+	"akiba/backend/internal/events"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/fx"
+	"akiba/backend/internal/infrastructure/memory"
 	mongoRepo "akiba/backend/internal/infrastructure/mongo"
+	"akiba/backend/internal/infrastructure/mongo/migrate"
+	postgresRepo "akiba/backend/internal/infrastructure/postgres"
+	"akiba/backend/internal/notify"
 	"akiba/backend/internal/observability"
+	"akiba/backend/internal/pii"
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/risk"
 	httptransport "akiba/backend/internal/transport/http"
 	"akiba/backend/internal/usecase"
+	"akiba/backend/internal/webauthn"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -30,28 +44,138 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+	repos, err := openRepositories(ctx, cfg)
 	if err != nil {
 		log.Fatalf("mongo connect error: %v", err)
 	}
+	if cfg.MemoryStore {
+		logger.Warn("keeping all data in process memory; it is lost when the api stops")
+	}
 
-	db := client.Database(cfg.MongoDBName)
-	userRepo := mongoRepo.NewUserRepository(db, cfg.DBTimeout)
+	piiKeys, err := loadPIIKeys(cfg.PIIKeyFile)
+	if err != nil {
+		log.Fatalf("pii key file error: %v", err)
+	}
+	if cfg.PIIKeyFile == "" {
+		logger.Warn("sealing personal data with the development keys; set PII_KEY_FILE before storing real data")
+	}
+	piiCipher, err := pii.NewCipher(piiKeys, piiKeys.BlindIndexKey())
+	if err != nil {
+		log.Fatalf("pii key file error: %v", err)
+	}
+
+	users, err := openUserStore(ctx, cfg, repos, piiCipher)
+	if err != nil {
+		log.Fatalf("user store error: %v", err)
+	}
+	defer users.close()
+	userRepo := users.repo
+	ledgerRepo := repos.ledger
+	merchantRepo := repos.merchants
+	fxQuoteRepo := repos.fxQuotes
+	disputeRepo := repos.disputes
+	beneficiaryRepo := repos.beneficiaries
+	amlRepo := repos.aml
+	riskRepo := repos.risk
+	passkeyRepo := repos.passkeys
+	deviceRepo := repos.devices
+	auditRepo := repos.audit
+	outboxRepo := repos.outbox
+	webhookRepo := repos.webhooks
+	apiClientRepo := repos.apiClients
+	oauthRepo := repos.oauth
 	indexCtx, indexCancel := context.WithTimeout(context.Background(), cfg.DBTimeout)
 	defer indexCancel()
-	if err := userRepo.EnsureIndexes(indexCtx); err != nil {
-		log.Fatalf("index setup error: %v", err)
+	// Pending migrations may drop indexes EnsureIndexes would otherwise
+	// keep in place, so check them before touching any.
+	if repos.db != nil {
+		if err := checkMigrations(indexCtx, logger, repos.db, cfg.RequireMigrations); err != nil {
+			log.Fatalf("migration check error: %v", err)
+		}
+	}
+	ensures := []func(context.Context) error{userRepo.EnsureIndexes, ledgerRepo.EnsureIndexes, merchantRepo.EnsureIndexes, fxQuoteRepo.EnsureIndexes, disputeRepo.EnsureIndexes, beneficiaryRepo.EnsureIndexes, amlRepo.EnsureIndexes, riskRepo.EnsureIndexes, passkeyRepo.EnsureIndexes, deviceRepo.EnsureIndexes, auditRepo.EnsureIndexes, outboxRepo.EnsureIndexes, webhookRepo.EnsureIndexes, apiClientRepo.EnsureIndexes, oauthRepo.EnsureIndexes}
+	if users.outbox != nil {
+		ensures = append(ensures, users.outbox.EnsureIndexes)
+	}
+	for _, ensure := range ensures {
+		if err := ensure(indexCtx); err != nil {
+			log.Fatalf("index setup error: %v", err)
+		}
 	}
 
 	jwtMgr := auth.NewJWTManager(cfg.JWTSecret, cfg.JWTIssuer)
-	authSvc := usecase.NewAuthService(userRepo, jwtMgr, cfg.AccessTokenTTL)
-	router := httptransport.NewRouter(logger, authSvc, jwtMgr, func(ctx context.Context) error {
-		return client.Ping(ctx, nil)
-	})
+	if cfg.OIDCIssuer != "" {
+		key, err := loadIDTokenKey(cfg.OIDCSigningKeyFile)
+		if err != nil {
+			log.Fatalf("oidc signing key error: %v", err)
+		}
+		if cfg.OIDCSigningKeyFile == "" {
+			logger.Warn("signing ID tokens with a throwaway key; set OIDC_SIGNING_KEY_FILE so they survive restarts")
+		}
+		jwtMgr.SetIDTokenKey(key)
+	}
+	pinSvc := usecase.NewPINService(userRepo, usecase.PINConfig{MaxAttempts: cfg.PINMaxAttempts, Lockout: cfg.PINLockout})
+	totpSvc := usecase.NewTOTPService(userRepo, usecase.TOTPConfig{Issuer: cfg.TOTPIssuer, MaxAttempts: cfg.PINMaxAttempts, Lockout: cfg.PINLockout})
+	passkeySvc := usecase.NewPasskeyService(userRepo, passkeyRepo, usecase.PasskeyConfig{WebAuthn: webauthn.Config{RPID: cfg.WebAuthnRPID, RPName: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins, RequireUserVerification: true}, ChallengeTTL: cfg.WebAuthnChallengeTTL})
+	if cfg.Env != "development" {
+		logger.Warn("notifications are logged, not delivered; configure an SMS and push provider")
+	}
+	deviceSvc := usecase.NewDeviceService(userRepo, deviceRepo, notify.NewLogSender(logger), usecase.DeviceConfig{OTPTTL: cfg.OTPTTL, OTPMaxAttempts: cfg.OTPMaxAttempts})
+	authSvc := usecase.NewAuthService(userRepo, jwtMgr, cfg.AccessTokenTTL, usecase.StepUpConfig{MaxAge: cfg.StepUpMaxAge, TokenTTL: cfg.StepUpTokenTTL, TransferThresholds: cfg.StepUpTransferThresholds}, pinSvc, passkeySvc, deviceSvc, totpSvc)
+	merchantSvc := usecase.NewMerchantService(userRepo, merchantRepo, ledgerRepo, pinSvc)
+	walletSvc := usecase.NewWalletService(ledgerRepo)
+	rates, err := loadRateProvider(cfg.FXRatesFile)
+	if err != nil {
+		log.Fatalf("fx rates error: %v", err)
+	}
+	schedule, err := loadFeeSchedule(cfg.FeeScheduleFile)
+	if err != nil {
+		log.Fatalf("fee schedule error: %v", err)
+	}
+	feeSvc := usecase.NewFeeService(schedule)
+	fxSvc := usecase.NewFXService(userRepo, fxQuoteRepo, ledgerRepo, rates, feeSvc, usecase.FXConfig{QuoteTTL: cfg.FXQuoteTTL, SpreadBps: cfg.FXSpreadBps})
+	holdSvc := usecase.NewHoldService(userRepo, merchantRepo, ledgerRepo, cfg.HoldTTL, pinSvc)
+	beneficiarySvc := usecase.NewBeneficiaryService(beneficiaryRepo, userRepo, ledgerRepo, usecase.BeneficiaryConfig{CoolingOff: cfg.BeneficiaryCoolingOff, CoolingOffLimit: cfg.BeneficiaryCoolingOffLimit})
+	riskCfg, err := loadRiskConfig(cfg.RiskConfigFile)
+	if err != nil {
+		log.Fatalf("risk config error: %v", err)
+	}
+	riskSvc := usecase.NewRiskService(userRepo, riskRepo, ledgerRepo, riskCfg)
+	transferSvc := usecase.NewTransferService(userRepo, ledgerRepo, feeSvc, holdSvc, beneficiarySvc, riskSvc, pinSvc)
+	reversalSvc := usecase.NewReversalService(userRepo, merchantRepo, ledgerRepo, disputeRepo)
+	disputeSvc := usecase.NewDisputeService(disputeRepo, ledgerRepo, reversalSvc)
+	amlRules, err := loadAMLRules(cfg.AMLRulesFile)
+	if err != nil {
+		log.Fatalf("aml rules error: %v", err)
+	}
+	monitoringSvc := usecase.NewMonitoringService(userRepo, ledgerRepo, amlRepo, amlRules)
+	adminSvc := usecase.NewAdminService(userRepo, ledgerRepo, merchantRepo, jwtMgr, usecase.AdminConfig{ImpersonationTTL: cfg.ImpersonationTTL})
+	auditSvc := usecase.NewAuditService(auditRepo, userRepo)
+	apiClientSvc := usecase.NewAPIClientService(apiClientRepo, userRepo, jwtMgr, usecase.APIClientConfig{TokenTTL: cfg.ClientTokenTTL})
+	oauthSvc := usecase.NewOAuthService(oauthRepo, apiClientSvc, userRepo, jwtMgr, usecase.OAuthConfig{AccessTokenTTL: cfg.OAuthAccessTokenTTL, RefreshTokenTTL: cfg.OAuthRefreshTokenTTL, Issuer: cfg.OIDCIssuer, AuthorizationEndpoint: cfg.OIDCAuthorizationURL})
+	webhookSvc := usecase.NewWebhookService(webhookRepo, apiClientRepo, userRepo, events.NewWebhookSender(cfg.WebhookTimeout), usecase.WebhookConfig{AllowHTTP: cfg.Env == "development", MaxAttempts: cfg.WebhookMaxAttempts})
+	services := httptransport.Services{Auth: authSvc, Merchants: merchantSvc, Wallets: walletSvc, FX: fxSvc, Fees: feeSvc, Transfers: transferSvc, Holds: holdSvc, Beneficiaries: beneficiarySvc, Reversals: reversalSvc, Disputes: disputeSvc, Monitoring: monitoringSvc, Risk: riskSvc, PINs: pinSvc, TOTP: totpSvc, Passkeys: passkeySvc, Devices: deviceSvc, Admin: adminSvc, Audit: auditSvc, Webhooks: webhookSvc, APIClients: apiClientSvc, OAuth: oauthSvc}
+	router := httptransport.NewRouter(logger, services, jwtMgr, readiness(repos, users))
 
 	srv := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Port), Handler: router, ReadHeaderTimeout: 5 * time.Second}
 	logger.Info("starting api", "env", cfg.Env, "port", cfg.Port)
 
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+	go runHoldExpiry(sweepCtx, logger, holdSvc, cfg.HoldSweepEvery)
+	go runAMLMonitor(sweepCtx, logger, monitoringSvc, cfg.AMLScanEvery)
+	go runPIIReencryption(sweepCtx, logger, usecase.NewPIIReencryption(userRepo, 0), cfg.PIIReencryptEvery)
+	if cfg.OutboxRelayEnabled {
+		publisher := events.MultiPublisher{newEventPublisher(cfg, logger), webhookSvc}
+		relay := usecase.NewOutboxRelay(outboxRepo, publisher, usecase.OutboxConfig{MaxAttempts: cfg.OutboxMaxAttempts})
+		go runOutboxRelay(sweepCtx, logger, relay, cfg.OutboxRelayEvery)
+		if users.outbox != nil {
+			userRelay := usecase.NewOutboxRelay(users.outbox, publisher, usecase.OutboxConfig{MaxAttempts: cfg.OutboxMaxAttempts})
+			go runOutboxRelay(sweepCtx, logger, userRelay, cfg.OutboxRelayEvery)
+		}
+		go runWebhookDelivery(sweepCtx, logger, webhookSvc, cfg.WebhookDeliveryEvery)
+	}
+
 	serverErr := make(chan error, 1)
 	go func() {
 		serverErr <- srv.ListenAndServe()
@@ -70,13 +194,287 @@ func main() {
 		return
 	}
 
+	stopSweep()
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("server shutdown failed", "error", err)
 	}
-	if err := client.Disconnect(shutdownCtx); err != nil {
+	if err := repos.close(shutdownCtx); err != nil {
 		logger.Error("mongo disconnect failed", "error", err)
 	}
 }
+
+// loadRateProvider reads rates from path when set and falls back to the
+// built-in offline table otherwise.
+func loadRateProvider(path string) (fx.RateProvider, error) {
+	if path != "" {
+		return fx.NewFileProvider(path)
+	}
+	return fx.NewStaticProvider(fx.DefaultRates())
+}
+
+// loadFeeSchedule reads the schedule from path when set and falls back to
+// the built-in default otherwise.
+func loadFeeSchedule(path string) (*fees.Schedule, error) {
+	if path != "" {
+		return fees.LoadFile(path)
+	}
+	return fees.DefaultSchedule(), nil
+}
+
+// loadAMLRules reads monitoring rules from path when set and falls back to
+// the built-in defaults otherwise.
+func loadAMLRules(path string) (*aml.RuleSet, error) {
+	if path != "" {
+		return aml.LoadFile(path)
+	}
+	rules := aml.DefaultRules()
+	return rules, rules.Validate()
+}
+
+// loadRiskConfig reads scoring weights from path when set and falls back to
+// the built-in defaults otherwise.
+func loadRiskConfig(path string) (*risk.Config, error) {
+	if path != "" {
+		return risk.LoadFile(path)
+	}
+	return risk.DefaultConfig(), nil
+}
+
+// loadIDTokenKey reads the ID token signing key from path when set and
+// generates one otherwise; config only allows that in development.
+func loadIDTokenKey(path string) (*ecdsa.PrivateKey, error) {
+	if path != "" {
+		return auth.LoadSigningKey(path)
+	}
+	return auth.GenerateSigningKey()
+}
+
+// repositories are the stores for everything but users: Mongo, or with
+// MEMORY_STORE a process-memory store for demos that also holds the users.
+type repositories struct {
+	db            *mongo.Database
+	memory        *memory.Store
+	ledger        repository.LedgerRepository
+	merchants     repository.MerchantRepository
+	fxQuotes      repository.FXQuoteRepository
+	disputes      repository.DisputeRepository
+	beneficiaries repository.BeneficiaryRepository
+	aml           repository.AMLRepository
+	risk          repository.RiskRepository
+	passkeys      repository.PasskeyRepository
+	devices       repository.DeviceRepository
+	audit         repository.AuditRepository
+	outbox        repository.OutboxRepository
+	webhooks      repository.WebhookRepository
+	apiClients    repository.APIClientRepository
+	oauth         repository.OAuthRepository
+	ping          func(context.Context) error
+	close         func(context.Context) error
+}
+
+func openRepositories(ctx context.Context, cfg config.Config) (repositories, error) {
+	if cfg.MemoryStore {
+		s := memory.NewStore()
+		none := func(context.Context) error { return nil }
+		return repositories{memory: s, ledger: memory.NewLedgerRepository(s), merchants: memory.NewMerchantRepository(s), fxQuotes: memory.NewFXQuoteRepository(s),
+			disputes: memory.NewDisputeRepository(s), beneficiaries: memory.NewBeneficiaryRepository(s), aml: memory.NewAMLRepository(s), risk: memory.NewRiskRepository(s),
+			passkeys: memory.NewPasskeyRepository(s), devices: memory.NewDeviceRepository(s), audit: memory.NewAuditRepository(s), outbox: memory.NewOutboxRepository(s),
+			webhooks: memory.NewWebhookRepository(s), apiClients: memory.NewAPIClientRepository(s), oauth: memory.NewOAuthRepository(s), ping: none, close: none}, nil
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+	if err != nil {
+		return repositories{}, err
+	}
+	db := client.Database(cfg.MongoDBName)
+	return repositories{db: db, ledger: mongoRepo.NewLedgerRepository(db, cfg.DBTimeout), merchants: mongoRepo.NewMerchantRepository(db, cfg.DBTimeout), fxQuotes: mongoRepo.NewFXQuoteRepository(db, cfg.DBTimeout),
+		disputes: mongoRepo.NewDisputeRepository(db, cfg.DBTimeout), beneficiaries: mongoRepo.NewBeneficiaryRepository(db, cfg.DBTimeout), aml: mongoRepo.NewAMLRepository(db, cfg.DBTimeout), risk: mongoRepo.NewRiskRepository(db, cfg.DBTimeout),
+		passkeys: mongoRepo.NewPasskeyRepository(db, cfg.DBTimeout), devices: mongoRepo.NewDeviceRepository(db, cfg.DBTimeout), audit: mongoRepo.NewAuditRepository(db, cfg.DBTimeout), outbox: mongoRepo.NewOutboxRepository(db, cfg.DBTimeout),
+		webhooks: mongoRepo.NewWebhookRepository(db, cfg.DBTimeout), apiClients: mongoRepo.NewAPIClientRepository(db, cfg.DBTimeout), oauth: mongoRepo.NewOAuthRepository(db, cfg.DBTimeout),
+		ping: func(ctx context.Context) error { return client.Ping(ctx, nil) }, close: client.Disconnect}, nil
+}
+
+// checkMigrations refuses to start over pending schema migrations, or only
+// warns about them with REQUIRE_MIGRATIONS=false.
+func checkMigrations(ctx context.Context, logger *slog.Logger, db *mongo.Database, require bool) error {
+	migrator, err := migrate.New(db, mongoRepo.Migrations(), "api", time.Minute)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	if require {
+		return fmt.Errorf("%d migration(s) pending, from %d %s; run cmd/migrate up", len(pending), pending[0].Version, pending[0].Name)
+	}
+	logger.Warn("schema migrations pending; run cmd/migrate up", "count", len(pending), "next", pending[0].Version)
+	return nil
+}
+
+// readiness pings every database the API writes to: Mongo, and Postgres
+// too when it holds the users.
+func readiness(repos repositories, users userStore) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if err := repos.ping(ctx); err != nil || users.ping == nil {
+			return err
+		}
+		return users.ping(ctx)
+	}
+}
+
+// userStore is the user repository USER_STORAGE selects, or the memory
+// store with MEMORY_STORE. Users kept outside
+// Mongo write their events to an outbox of their own, which needs a relay
+// of its own, and their database is pinged for readiness.
+type userStore struct {
+	repo interface {
+		repository.UserRepository
+		repository.PIIReencrypter
+	}
+	outbox repository.OutboxRepository
+	ping   func(context.Context) error
+	close  func()
+}
+
+func openUserStore(ctx context.Context, cfg config.Config, repos repositories, cipher *pii.Cipher) (userStore, error) {
+	if cfg.MemoryStore {
+		// Memory keeps personal data in the clear; it never leaves the
+		// process.
+		return userStore{repo: memory.NewUserRepository(repos.memory), close: func() {}}, nil
+	}
+	if cfg.UserStorage == "postgres" {
+		pool, err := postgresRepo.Connect(ctx, cfg.PostgresURL)
+		if err != nil {
+			return userStore{}, err
+		}
+		return userStore{repo: postgresRepo.NewUserRepository(pool, cfg.DBTimeout, cipher), outbox: postgresRepo.NewOutboxRepository(pool, cfg.DBTimeout), ping: pool.Ping, close: pool.Close}, nil
+	}
+	return userStore{repo: mongoRepo.NewUserRepository(repos.db, cfg.DBTimeout, cipher), close: func() {}}, nil
+}
+
+// loadPIIKeys reads the personal data key file from path when set and
+// falls back to the development keys; config only allows that in
+// development.
+func loadPIIKeys(path string) (*pii.LocalKeyProvider, error) {
+	if path != "" {
+		return pii.LoadKeyFile(path)
+	}
+	return pii.DevelopmentKeys(), nil
+}
+
+// newEventPublisher picks the outbox relay's transport. Broker transports
+// plug in through events.NewBrokerPublisher with a client adapter.
+func newEventPublisher(cfg config.Config, logger *slog.Logger) events.EventPublisher {
+	if cfg.EventPublisher == "http" {
+		return events.NewHTTPPublisher(cfg.EventWebhookURL, 10*time.Second)
+	}
+	return events.NewLogPublisher(logger)
+}
+
+// runHoldExpiry releases expired holds every interval until ctx is done.
+func runHoldExpiry(ctx context.Context, logger *slog.Logger, holds *usecase.HoldService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := holds.ExpireDue(ctx)
+			if err != nil {
+				logger.Error("hold expiry failed", "error", err)
+			}
+			if n > 0 {
+				logger.Info("expired holds released", "count", n)
+			}
+		}
+	}
+}
+
+// runAMLMonitor evaluates newly posted entries every interval until ctx is
+// done.
+func runAMLMonitor(ctx context.Context, logger *slog.Logger, monitoring *usecase.MonitoringService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := monitoring.Scan(ctx)
+			if err != nil {
+				logger.Error("aml scan failed", "error", err)
+			}
+			if n > 0 {
+				logger.Info("aml alerts raised", "count", n)
+			}
+		}
+	}
+}
+
+// runOutboxRelay publishes due outbox events every interval until ctx is
+// done.
+func runOutboxRelay(ctx context.Context, logger *slog.Logger, relay *usecase.OutboxRelay, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := relay.RelayDue(ctx)
+			if err != nil {
+				logger.Error("outbox relay failed", "error", err)
+			}
+			if res.Failed > 0 || res.DeadLettered > 0 {
+				logger.Warn("outbox publish failures", "published", res.Published, "failed", res.Failed, "dead_lettered", res.DeadLettered)
+			}
+		}
+	}
+}
+
+// runWebhookDelivery calls partner endpoints with due deliveries every
+// interval until ctx is done.
+func runWebhookDelivery(ctx context.Context, logger *slog.Logger, webhooks *usecase.WebhookService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := webhooks.DeliverDue(ctx)
+			if err != nil {
+				logger.Error("webhook delivery failed", "error", err)
+			}
+			if res.Failed > 0 {
+				logger.Warn("webhook deliveries exhausted", "succeeded", res.Succeeded, "retrying", res.Retrying, "failed", res.Failed)
+			}
+		}
+	}
+}
+
+// runPIIReencryption re-seals personal data left under an old key or in
+// plaintext, once at start so a rotation takes effect on deploy and then
+// every interval until ctx is done.
+func runPIIReencryption(ctx context.Context, logger *slog.Logger, job *usecase.PIIReencryption, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := job.Run(ctx)
+		if err != nil {
+			logger.Error("pii re-encryption failed", "error", err)
+		}
+		if n > 0 {
+			logger.Info("personal data re-encrypted", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Command migrate applies the Mongo schema migrations and reports which
+// have run. It reads the same MONGO_URI and MONGO_DB_NAME as the API.
+//
+//	migrate status           list every migration and when it was applied
+//	migrate up -dry-run      list the migrations up would apply
+//	migrate up               apply the pending migrations in version order
+//
+// up takes a lock, so two deploys running it at once apply each migration
+// only once; the second exits 1 without touching the database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"akiba/backend/internal/config"
+	mongoRepo "akiba/backend/internal/infrastructure/mongo"
+	"akiba/backend/internal/infrastructure/mongo/migrate"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate status | up [-dry-run] [-lock-ttl 10m]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the pending migrations without applying them")
+	lockTTL := flags.Duration("lock-ttl", 10*time.Minute, "how long the lock outlives a migrator that dies holding it")
+	if cmd != "status" && cmd != "up" {
+		usage()
+	}
+	_ = flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	if cfg.MemoryStore {
+		log.Fatal("MEMORY_STORE keeps no schema; there is nothing to migrate")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(cfg.MongoURI))
+	if err != nil {
+		log.Fatalf("mongo connect error: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	host, _ := os.Hostname()
+	migrator, err := migrate.New(client.Database(cfg.MongoDBName), mongoRepo.Migrations(), fmt.Sprintf("%s:%d", host, os.Getpid()), *lockTTL)
+	if err != nil {
+		log.Fatalf("migrations error: %v", err)
+	}
+
+	switch {
+	case cmd == "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("status error: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied() {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if !s.Known {
+				state += " (unknown to this binary)"
+			}
+			fmt.Printf("%4d %-40s %s\n", s.Version, s.Name, state)
+		}
+	case *dryRun:
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			log.Fatalf("status error: %v", err)
+		}
+		for _, m := range pending {
+			fmt.Printf("would apply %d %s\n", m.Version, m.Name)
+		}
+		fmt.Printf("%d migration(s) pending\n", len(pending))
+	default:
+		done, err := migrator.Up(ctx, func(m migrate.Migration) {
+			fmt.Printf("applying %d %s\n", m.Version, m.Name)
+		})
+		if errors.Is(err, migrate.ErrLocked) {
+			fmt.Println("another migrator holds the lock; try again once it finishes")
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatalf("migrate error after %d applied: %v", len(done), err)
+		}
+		fmt.Printf("%d migration(s) applied\n", len(done))
+	}
+}
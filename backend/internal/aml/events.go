@@ -0,0 +1,53 @@
+package aml
+
+import (
+	"sort"
+
+	"akiba/backend/internal/domain"
+)
+
+// EventsFromEntry splits entry into one Event per customer or merchant
+// account it moved money on. accounts must hold every account the entry
+// posts to. House accounts are never monitored, but they still appear as
+// counterparties, except revenue so that fee legs do not count as a party.
+func EventsFromEntry(entry *domain.JournalEntry, accounts map[string]*domain.Account) []Event {
+	var out []Event
+	seen := map[string]bool{}
+	for _, p := range entry.Postings {
+		account, ok := accounts[p.AccountID]
+		if !ok || account.AllowsNegative() || seen[p.AccountID] {
+			continue
+		}
+		seen[p.AccountID] = true
+		net := entry.AmountFor(p.AccountID)
+		if net == 0 {
+			continue
+		}
+		ev := Event{EntryID: entry.ID, Kind: string(entry.Kind), AccountID: account.ID, OwnerID: account.OwnerID, Currency: p.Currency, Direction: DirectionIn, Amount: net, At: entry.CreatedAt}
+		if net < 0 {
+			ev.Direction, ev.Amount = DirectionOut, -net
+		}
+		parties := map[string]bool{}
+		for _, other := range entry.Postings {
+			o, ok := accounts[other.AccountID]
+			if !ok || o.OwnerID == account.OwnerID || o.OwnerID == domain.SystemOwnerRevenue || (other.Amount < 0) == (net < 0) || parties[o.OwnerID] {
+				continue
+			}
+			parties[o.OwnerID] = true
+			ev.Counterparties = append(ev.Counterparties, o.OwnerID)
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+func sortedEvents(events []Event) []Event {
+	out := append([]Event(nil), events...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].At.Equal(out[j].At) {
+			return out[i].At.Before(out[j].At)
+		}
+		return out[i].EntryID < out[j].EntryID
+	})
+	return out
+}
@@ -0,0 +1,309 @@
+package aml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const bpsScale = 10000
+
+// Aggregate is what a rule measures over the events in its window.
+type Aggregate string
+
+const (
+	// AggregateCount counts matching events.
+	AggregateCount Aggregate = "count"
+	// AggregateSum totals matching amounts.
+	AggregateSum Aggregate = "sum"
+	// AggregateDistinctCounterparties counts the distinct parties on the
+	// other side of matching events; inbound, it detects many-to-one fan-in.
+	AggregateDistinctCounterparties Aggregate = "distinct_counterparties"
+	// AggregatePassThrough fires on an outbound event when at least
+	// Threshold came in during the window and RatioBps of it has gone out.
+	AggregatePassThrough Aggregate = "pass_through"
+	// AggregateDormant fires on an event of at least Threshold when the
+	// group had no activity at all for Window before it.
+	AggregateDormant Aggregate = "dormant"
+)
+
+type Direction string
+
+const (
+	DirectionIn  Direction = "in"
+	DirectionOut Direction = "out"
+	DirectionAny Direction = "any"
+)
+
+// GroupBy selects the key events are aggregated under.
+type GroupBy string
+
+const (
+	GroupByAccount GroupBy = "account"
+	// GroupByOwner aggregates across every account of the owner in the
+	// rule's currency.
+	GroupByOwner GroupBy = "owner"
+)
+
+var ErrInvalidRules = errors.New("invalid aml rules")
+
+// Duration is a time.Duration that reads and writes as "24h" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(d).String()) }
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Rule is one declarative detection. An event matches when its currency,
+// entry kind, direction and amount fall inside the filters; MaxAmount is
+// exclusive and zero leaves it open. Matching events for the same GroupBy
+// key within Window are aggregated and compared with Threshold, which is in
+// minor units for sum, pass_through and dormant and a plain count otherwise.
+type Rule struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Severity  string    `json:"severity"`
+	Currency  string    `json:"currency"`
+	Kinds     []string  `json:"kinds,omitempty"`
+	Direction Direction `json:"direction"`
+	MinAmount int64     `json:"minAmount,omitempty"`
+	MaxAmount int64     `json:"maxAmount,omitempty"`
+	GroupBy   GroupBy   `json:"groupBy"`
+	Window    Duration  `json:"window"`
+	Aggregate Aggregate `json:"aggregate"`
+	Threshold int64     `json:"threshold"`
+	RatioBps  int       `json:"ratioBps,omitempty"`
+	Disabled  bool      `json:"disabled,omitempty"`
+}
+
+// Event is one account's side of a journal entry. Amount is always
+// positive; Direction says which way it moved. Counterparties are the
+// owners on the other side.
+type Event struct {
+	EntryID        string
+	Kind           string
+	AccountID      string
+	OwnerID        string
+	Currency       string
+	Direction      Direction
+	Amount         int64
+	Counterparties []string
+	At             time.Time
+}
+
+// Hit is a rule firing on a trigger event. EntryIDs are the events that
+// contributed, oldest first, and are linked to the alert as evidence.
+type Hit struct {
+	RuleID   string
+	Value    int64
+	EntryIDs []string
+	From     time.Time
+	To       time.Time
+}
+
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Active returns the enabled rules.
+func (s *RuleSet) Active() []Rule {
+	out := make([]Rule, 0, len(s.Rules))
+	for _, r := range s.Rules {
+		if !r.Disabled {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Lookback is how far before an event history must reach to evaluate any of
+// rules.
+func Lookback(rules []Rule) time.Duration {
+	var max time.Duration
+	for _, r := range rules {
+		if w := time.Duration(r.Window); w > max {
+			max = w
+		}
+	}
+	return max
+}
+
+func (r Rule) matches(ev Event) bool {
+	if ev.Currency != r.Currency || ev.Amount < r.MinAmount || (r.MaxAmount > 0 && ev.Amount >= r.MaxAmount) {
+		return false
+	}
+	if r.Direction != DirectionAny && r.Direction != "" && ev.Direction != r.Direction {
+		return false
+	}
+	if len(r.Kinds) == 0 {
+		return true
+	}
+	for _, k := range r.Kinds {
+		if k == ev.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate decides whether trigger fires the rule. history holds the
+// group's events up to and including trigger, in any order; events outside
+// the window are ignored. groupSince is when the group's oldest account was
+// opened, so a brand new account never counts as dormant.
+func (r Rule) Evaluate(trigger Event, history []Event, groupSince time.Time) (Hit, bool) {
+	if r.Disabled {
+		return Hit{}, false
+	}
+	from := trigger.At.Add(-time.Duration(r.Window))
+	hit := Hit{RuleID: r.ID, From: from, To: trigger.At}
+	inWindow := func(ev Event) bool { return ev.At.After(from) && !ev.At.After(trigger.At) }
+
+	switch r.Aggregate {
+	case AggregateDormant:
+		if !r.matches(trigger) || trigger.Amount < r.Threshold || groupSince.After(from) {
+			return Hit{}, false
+		}
+		for _, ev := range history {
+			if ev.EntryID != trigger.EntryID && !ev.At.After(trigger.At) && !ev.At.Before(from) {
+				return Hit{}, false
+			}
+		}
+		hit.Value, hit.EntryIDs = trigger.Amount, []string{trigger.EntryID}
+		return hit, true
+
+	case AggregatePassThrough:
+		// The direction filter is implied: money in, then out.
+		r.Direction = DirectionAny
+		if trigger.Direction != DirectionOut || !r.matches(trigger) {
+			return Hit{}, false
+		}
+		var in, out int64
+		for _, ev := range sortedEvents(history) {
+			if !inWindow(ev) || !r.matches(ev) {
+				continue
+			}
+			if ev.Direction == DirectionIn {
+				in += ev.Amount
+			} else {
+				out += ev.Amount
+			}
+			hit.EntryIDs = append(hit.EntryIDs, ev.EntryID)
+		}
+		if in < r.Threshold || out*bpsScale < in*int64(r.RatioBps) {
+			return Hit{}, false
+		}
+		hit.Value = out
+		return hit, true
+	}
+
+	if !r.matches(trigger) {
+		return Hit{}, false
+	}
+	parties := map[string]bool{}
+	for _, ev := range sortedEvents(history) {
+		if !inWindow(ev) || !r.matches(ev) {
+			continue
+		}
+		hit.EntryIDs = append(hit.EntryIDs, ev.EntryID)
+		switch r.Aggregate {
+		case AggregateCount:
+			hit.Value++
+		case AggregateSum:
+			hit.Value += ev.Amount
+		case AggregateDistinctCounterparties:
+			for _, p := range ev.Counterparties {
+				parties[p] = true
+			}
+			hit.Value = int64(len(parties))
+		}
+	}
+	if hit.Value < r.Threshold {
+		return Hit{}, false
+	}
+	return hit, true
+}
+
+// Validate rejects rules the engine cannot evaluate, and fills in the
+// default direction and group.
+func (s *RuleSet) Validate() error {
+	seen := map[string]bool{}
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if strings.TrimSpace(r.ID) == "" || seen[r.ID] {
+			return fmt.Errorf("%w: rule %q missing or duplicated", ErrInvalidRules, r.ID)
+		}
+		seen[r.ID] = true
+		if r.Direction == "" {
+			r.Direction = DirectionAny
+		}
+		if r.GroupBy == "" {
+			r.GroupBy = GroupByAccount
+		}
+		if r.Currency == "" || r.Window <= 0 || r.Threshold <= 0 || r.MinAmount < 0 || r.MaxAmount < 0 || (r.MaxAmount > 0 && r.MaxAmount <= r.MinAmount) {
+			return fmt.Errorf("%w: rule %s needs a currency, a positive window and threshold and a valid amount band", ErrInvalidRules, r.ID)
+		}
+		switch r.Direction {
+		case DirectionIn, DirectionOut, DirectionAny:
+		default:
+			return fmt.Errorf("%w: rule %s has unknown direction %q", ErrInvalidRules, r.ID, r.Direction)
+		}
+		switch r.GroupBy {
+		case GroupByAccount, GroupByOwner:
+		default:
+			return fmt.Errorf("%w: rule %s has unknown groupBy %q", ErrInvalidRules, r.ID, r.GroupBy)
+		}
+		switch r.Aggregate {
+		case AggregateCount, AggregateSum, AggregateDistinctCounterparties, AggregateDormant:
+		case AggregatePassThrough:
+			if r.RatioBps <= 0 || r.RatioBps > bpsScale {
+				return fmt.Errorf("%w: rule %s needs ratioBps between 1 and 10000", ErrInvalidRules, r.ID)
+			}
+		default:
+			return fmt.Errorf("%w: rule %s has unknown aggregate %q", ErrInvalidRules, r.ID, r.Aggregate)
+		}
+	}
+	return nil
+}
+
+// DefaultRules covers the typologies compliance asked for first, in KES.
+// Amounts are minor units: structuring looks for repeated payments just
+// under the KES 1,000,000 reporting threshold.
+func DefaultRules() *RuleSet {
+	day := Duration(24 * time.Hour)
+	return &RuleSet{Rules: []Rule{
+		{ID: "structuring", Name: "Repeated amounts just under the reporting threshold", Severity: "high", Currency: "KES", Direction: DirectionAny, MinAmount: 90000000, MaxAmount: 100000000, GroupBy: GroupByOwner, Window: 7 * day, Aggregate: AggregateCount, Threshold: 3},
+		{ID: "rapid_in_out", Name: "Funds moved out soon after arriving", Severity: "medium", Currency: "KES", Kinds: []string{"transfer", "withdrawal", "qr_payment", "merchant_payment"}, Direction: DirectionAny, GroupBy: GroupByAccount, Window: day, Aggregate: AggregatePassThrough, Threshold: 10000000, RatioBps: 9000},
+		{ID: "fan_in", Name: "Many senders paying one account", Severity: "medium", Currency: "KES", Kinds: []string{"transfer"}, Direction: DirectionIn, GroupBy: GroupByAccount, Window: day, Aggregate: AggregateDistinctCounterparties, Threshold: 10},
+		{ID: "dormant_reactivation", Name: "Large movement on a dormant account", Severity: "medium", Currency: "KES", Direction: DirectionAny, GroupBy: GroupByAccount, Window: 180 * day, Aggregate: AggregateDormant, Threshold: 5000000},
+	}}
+}
+
+// LoadFile reads a JSON-encoded RuleSet and validates it.
+func LoadFile(path string) (*RuleSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s RuleSet
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,85 @@
+package aml
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func ev(id string, dir Direction, amount int64, at time.Duration, parties ...string) Event {
+	return Event{EntryID: id, Kind: "transfer", AccountID: "a1", OwnerID: "u1", Currency: "KES", Direction: dir, Amount: amount, Counterparties: parties, At: t0.Add(at)}
+}
+
+func TestStructuringCountsOnlyAmountsInBand(t *testing.T) {
+	rule := Rule{ID: "structuring", Currency: "KES", Direction: DirectionAny, MinAmount: 9000, MaxAmount: 10000, Window: Duration(24 * time.Hour), Aggregate: AggregateCount, Threshold: 3}
+	history := []Event{ev("e1", DirectionIn, 9500, 0), ev("e2", DirectionIn, 12000, time.Hour), ev("e3", DirectionOut, 9900, 2*time.Hour)}
+	if _, ok := rule.Evaluate(history[2], history, time.Time{}); ok {
+		t.Fatalf("two in-band events should not fire")
+	}
+	history = append(history, ev("e4", DirectionIn, 9100, 3*time.Hour))
+	hit, ok := rule.Evaluate(history[3], history, time.Time{})
+	if !ok || hit.Value != 3 || len(hit.EntryIDs) != 3 || hit.EntryIDs[0] != "e1" {
+		t.Fatalf("unexpected hit: ok=%v hit=%#v", ok, hit)
+	}
+	old := append([]Event{}, history...)
+	old[0].At = t0.Add(-25 * time.Hour)
+	if _, ok := rule.Evaluate(old[3], old, time.Time{}); ok {
+		t.Fatalf("events outside the window should not count")
+	}
+}
+
+func TestPassThroughFiresOnOutflow(t *testing.T) {
+	rule := Rule{ID: "rapid", Currency: "KES", Window: Duration(24 * time.Hour), Aggregate: AggregatePassThrough, Threshold: 10000, RatioBps: 9000}
+	history := []Event{ev("e1", DirectionIn, 20000, 0), ev("e2", DirectionOut, 10000, time.Hour)}
+	if _, ok := rule.Evaluate(history[1], history, time.Time{}); ok {
+		t.Fatalf("half moved out should not fire")
+	}
+	history = append(history, ev("e3", DirectionOut, 8000, 2*time.Hour))
+	hit, ok := rule.Evaluate(history[2], history, time.Time{})
+	if !ok || hit.Value != 18000 || len(hit.EntryIDs) != 3 {
+		t.Fatalf("unexpected hit: ok=%v hit=%#v", ok, hit)
+	}
+	if _, ok := rule.Evaluate(history[0], history[:1], time.Time{}); ok {
+		t.Fatalf("inflows never trigger pass-through")
+	}
+}
+
+func TestFanInCountsDistinctSenders(t *testing.T) {
+	rule := Rule{ID: "fan_in", Currency: "KES", Direction: DirectionIn, Window: Duration(time.Hour), Aggregate: AggregateDistinctCounterparties, Threshold: 3}
+	history := []Event{ev("e1", DirectionIn, 100, 0, "u2"), ev("e2", DirectionIn, 100, time.Minute, "u2"), ev("e3", DirectionIn, 100, 2*time.Minute, "u3")}
+	if _, ok := rule.Evaluate(history[2], history, time.Time{}); ok {
+		t.Fatalf("two senders should not fire")
+	}
+	history = append(history, ev("e4", DirectionIn, 100, 3*time.Minute, "u4"))
+	if hit, ok := rule.Evaluate(history[3], history, time.Time{}); !ok || hit.Value != 3 {
+		t.Fatalf("unexpected hit: ok=%v hit=%#v", ok, hit)
+	}
+}
+
+func TestDormantRequiresQuietOldAccount(t *testing.T) {
+	rule := Rule{ID: "dormant", Currency: "KES", Window: Duration(90 * 24 * time.Hour), Aggregate: AggregateDormant, Threshold: 5000}
+	trigger := ev("e9", DirectionIn, 6000, 0)
+	opened := t0.Add(-365 * 24 * time.Hour)
+	if _, ok := rule.Evaluate(trigger, []Event{trigger}, opened); !ok {
+		t.Fatalf("expected dormant account to fire")
+	}
+	if _, ok := rule.Evaluate(trigger, []Event{trigger}, t0.Add(-24*time.Hour)); ok {
+		t.Fatalf("a new account is not dormant")
+	}
+	recent := ev("e1", DirectionOut, 10, -30*24*time.Hour)
+	if _, ok := rule.Evaluate(trigger, []Event{recent, trigger}, opened); ok {
+		t.Fatalf("recent activity means not dormant")
+	}
+}
+
+func TestValidateRejectsBadRulesAndDefaultsValidate(t *testing.T) {
+	bad := &RuleSet{Rules: []Rule{{ID: "x", Currency: "KES", Window: Duration(time.Hour), Aggregate: AggregatePassThrough, Threshold: 1}}}
+	if err := bad.Validate(); !errors.Is(err, ErrInvalidRules) {
+		t.Fatalf("expected missing ratio to be rejected, got %v", err)
+	}
+	if err := DefaultRules().Validate(); err != nil {
+		t.Fatalf("default rules invalid: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newDeviceKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+func TestDeviceAssertion(t *testing.T) {
+	key, pub := newDeviceKey(t)
+	device := &DeviceClaim{ID: "d1", Key: pub}
+	now := time.Unix(1767225600, 0)
+	body := []byte(`{"recipient":"bob","amount":1000}`)
+	header, err := SignDeviceAssertion(key, now, "POST", "/api/v1/transfers", body, "tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := VerifyDeviceAssertion(device, header, "POST", "/api/v1/transfers", body, "tok", now.Add(time.Minute))
+	if err != nil || !verified.ExpiresAt.Equal(now.Add(DeviceAssertionMaxSkew)) {
+		t.Fatalf("expected valid assertion: %+v %v", verified, err)
+	}
+	resigned, _ := SignDeviceAssertion(key, now, "POST", "/api/v1/transfers", body, "tok")
+	if again, err := VerifyDeviceAssertion(device, resigned, "POST", "/api/v1/transfers", body, "tok", now); err != nil || again.Digest != verified.Digest {
+		t.Fatalf("a new signature over the same request must share its digest: %+v %v", again, err)
+	}
+	for name, tc := range map[string]struct {
+		method, uri, body, token string
+		at                       time.Time
+	}{
+		"other path":  {"POST", "/api/v1/withdrawals", string(body), "tok", now},
+		"other body":  {"POST", "/api/v1/transfers", `{"recipient":"bob","amount":900000}`, "tok", now},
+		"no body":     {"POST", "/api/v1/transfers", "", "tok", now},
+		"other token": {"POST", "/api/v1/transfers", string(body), "tok2", now},
+		"stale":       {"POST", "/api/v1/transfers", string(body), "tok", now.Add(DeviceAssertionMaxSkew + time.Second)},
+	} {
+		if _, err := VerifyDeviceAssertion(device, header, tc.method, tc.uri, []byte(tc.body), tc.token, tc.at); !errors.Is(err, ErrDeviceAssertion) {
+			t.Fatalf("%s: expected rejection, got %v", name, err)
+		}
+	}
+	other, _ := newDeviceKey(t)
+	forged, _ := SignDeviceAssertion(other, now, "POST", "/api/v1/transfers", body, "tok")
+	if _, err := VerifyDeviceAssertion(device, forged, "POST", "/api/v1/transfers", body, "tok", now); !errors.Is(err, ErrDeviceAssertion) {
+		t.Fatalf("expected another key rejected, got %v", err)
+	}
+}
+
+func TestDeviceClaimRoundTrip(t *testing.T) {
+	mgr := NewJWTManager("secret", "akiba-api")
+	_, pub := newDeviceKey(t)
+	token, err := mgr.IssueDeviceToken("u1", time.Hour, &DeviceClaim{ID: "d1", Key: pub}, MethodPassword, MethodOTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := mgr.Verify(token)
+	if err != nil || claims.Device == nil || claims.Device.ID != "d1" || claims.Device.Key != pub {
+		t.Fatalf("expected device claim, got %#v %v", claims, err)
+	}
+	if _, err := ParseDeviceKey(base64.StdEncoding.EncodeToString([]byte("junk"))); err == nil {
+		t.Fatal("expected junk key rejected")
+	}
+}
@@ -1,29 +1,141 @@
 package auth
 
 import (
+	"crypto/ecdsa"
 	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Authentication method references for the amr claim (RFC 8176).
+const (
+	MethodPassword = "pwd"
+	MethodOTP      = "otp"
+	MethodPIN      = "pin"
+	// MethodHardwareKey marks a passkey (WebAuthn) authentication.
+	MethodHardwareKey = "hwk"
+)
+
+// Claims carry when and how the user last proved who they are. AuthTime and
+// AMR are unset on tokens issued without an authentication. Device is set
+// on tokens bound to a registered device; requests with them must carry a
+// device assertion. Role and Permissions are a snapshot taken at issue time;
+// operator use cases re-check the stored role. Act is set on impersonation
+// tokens. ClientID and Scope are set on tokens issued to an API client (RFC
+// 9068); on client-credentials tokens Sub is the client itself, and on
+// tokens an app holds for a user Sub is the user and GrantID names the
+// OAuth grant they came from. Networks, when set, are the CIDRs the token
+// may be used from.
 type Claims struct {
-	Sub string `json:"sub"`
+	Sub         string           `json:"sub"`
+	AuthTime    *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR         []string         `json:"amr,omitempty"`
+	Device      *DeviceClaim     `json:"dev,omitempty"`
+	Role        string           `json:"role,omitempty"`
+	Permissions []string         `json:"perms,omitempty"`
+	Act         *ActorClaim      `json:"act,omitempty"`
+	ClientID    string           `json:"client_id,omitempty"`
+	Scope       string           `json:"scope,omitempty"`
+	Networks    []string         `json:"nets,omitempty"`
+	GrantID     string           `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ActorClaim is the RFC 8693 act claim: the operator acting as Sub on the
+// token's subject.
+type ActorClaim struct {
+	Sub string `json:"sub"`
+}
+
+// TokenOptions carries the optional claims of an access token. ID sets the
+// jti claim.
+type TokenOptions struct {
+	ID          string
+	Role        string
+	Permissions []string
+	Device      *DeviceClaim
+	AMR         []string
+	Actor       string
+	ClientID    string
+	Scopes      []string
+	Networks    []string
+	GrantID     string
+}
+
+func (c *Claims) HasPermission(p string) bool { return slices.Contains(c.Permissions, p) }
+
+// HasScope reports whether the space-separated scope claim includes s.
+func (c *Claims) HasScope(s string) bool { return slices.Contains(strings.Fields(c.Scope), s) }
+
+// IsClient reports whether the token was issued to an API client acting for
+// itself rather than for a user.
+func (c *Claims) IsClient() bool { return c.ClientID != "" && c.ClientID == c.Sub }
+
+// Delegated reports whether the token was issued to an app acting for the
+// user in Sub.
+func (c *Claims) Delegated() bool { return c.ClientID != "" && c.ClientID != c.Sub }
+
+// Impersonated reports whether the token was issued to an operator acting as
+// the subject.
+func (c *Claims) Impersonated() bool { return c.Act != nil }
+
+// FreshAuth reports whether the user authenticated within maxAge of now
+// using one of methods, or any method when none are given.
+func (c *Claims) FreshAuth(now time.Time, maxAge time.Duration, methods ...string) bool {
+	if c.AuthTime == nil || now.Sub(c.AuthTime.Time) > maxAge {
+		return false
+	}
+	if len(methods) == 0 {
+		return len(c.AMR) > 0
+	}
+	for _, m := range c.AMR {
+		if slices.Contains(methods, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// JWTManager signs and verifies access tokens with an HS256 secret and,
+// once SetIDTokenKey is called, OpenID Connect ID tokens with an ECDSA key.
 type JWTManager struct {
-	secret []byte
-	issuer string
+	secret  []byte
+	issuer  string
+	idKey   *ecdsa.PrivateKey
+	idKeyID string
 }
 
 func NewJWTManager(secret, issuer string) *JWTManager {
 	return &JWTManager{secret: []byte(secret), issuer: issuer}
 }
 
-func (j *JWTManager) IssueAccessToken(userID string, ttl time.Duration) (string, error) {
+// IssueAccessToken signs a token for userID. Passing the methods the user
+// just authenticated with stamps auth_time as now.
+func (j *JWTManager) IssueAccessToken(userID string, ttl time.Duration, amr ...string) (string, error) {
+	return j.IssueDeviceToken(userID, ttl, nil, amr...)
+}
+
+// IssueDeviceToken is IssueAccessToken for a token bound to device, which
+// may be nil for an unbound token.
+func (j *JWTManager) IssueDeviceToken(userID string, ttl time.Duration, device *DeviceClaim, amr ...string) (string, error) {
+	return j.IssueToken(userID, ttl, TokenOptions{Device: device, AMR: amr})
+}
+
+// IssueToken signs a token for subject userID, or a client ID for a
+// client-credentials token, with the claims in opts. A non-empty
+// opts.AMR stamps auth_time as now.
+func (j *JWTManager) IssueToken(userID string, ttl time.Duration, opts TokenOptions) (string, error) {
 	now := time.Now().UTC()
-	claims := Claims{Sub: userID, RegisteredClaims: jwt.RegisteredClaims{Issuer: j.issuer, Subject: userID, IssuedAt: jwt.NewNumericDate(now), ExpiresAt: jwt.NewNumericDate(now.Add(ttl))}}
+	claims := Claims{Sub: userID, Device: opts.Device, Role: opts.Role, Permissions: opts.Permissions, ClientID: opts.ClientID, Scope: strings.Join(opts.Scopes, " "), Networks: opts.Networks, GrantID: opts.GrantID, RegisteredClaims: jwt.RegisteredClaims{ID: opts.ID, Issuer: j.issuer, Subject: userID, IssuedAt: jwt.NewNumericDate(now), ExpiresAt: jwt.NewNumericDate(now.Add(ttl))}}
+	if len(opts.AMR) > 0 {
+		claims.AuthTime, claims.AMR = jwt.NewNumericDate(now), opts.AMR
+	}
+	if opts.Actor != "" {
+		claims.Act = &ActorClaim{Sub: opts.Actor}
+	}
 	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return tok.SignedString(j.secret)
 }
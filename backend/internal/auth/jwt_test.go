@@ -40,3 +40,42 @@ func TestJWTVerifyRejectsWrongAlgorithm(t *testing.T) {
 		t.Fatalf("expected algorithm verification error")
 	}
 }
+
+func TestFreshAuthChecksAgeAndMethod(t *testing.T) {
+	mgr := NewJWTManager("secret", "akiba-api")
+	plain, _ := mgr.IssueAccessToken("u1", time.Hour)
+	stepped, _ := mgr.IssueAccessToken("u1", 5*time.Minute, MethodPassword)
+	plainClaims, err := mgr.Verify(plain)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	claims, err := mgr.Verify(stepped)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	now := time.Now()
+	if plainClaims.FreshAuth(now, time.Hour) {
+		t.Fatalf("token without auth_time must not be fresh")
+	}
+	if !claims.FreshAuth(now, time.Minute) || !claims.FreshAuth(now, time.Minute, MethodPIN, MethodPassword) {
+		t.Fatalf("expected fresh password auth, got %#v", claims)
+	}
+	if claims.FreshAuth(now, time.Minute, MethodPIN) {
+		t.Fatalf("password auth must not satisfy a PIN-only check")
+	}
+	if claims.FreshAuth(now.Add(2*time.Minute), time.Minute) {
+		t.Fatalf("auth older than maxAge must not be fresh")
+	}
+}
+
+func TestTokenCarriesRoleAndPermissions(t *testing.T) {
+	mgr := NewJWTManager("secret", "akiba-api")
+	tok, _ := mgr.IssueToken("u1", time.Hour, TokenOptions{Role: "support", Permissions: []string{"users:read"}})
+	claims, err := mgr.Verify(tok)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Role != "support" || !claims.HasPermission("users:read") || claims.HasPermission("roles:write") {
+		t.Fatalf("unexpected claims %#v", claims)
+	}
+}
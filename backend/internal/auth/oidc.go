@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IDTokenAlg is the algorithm ID tokens are signed with. Access tokens use
+// HS256 with a secret only this service knows, which an app could not
+// verify; ID tokens are signed with an ECDSA key whose public half is
+// published as a JWK set.
+const IDTokenAlg = "ES256"
+
+var ErrNoIDTokenKey = errors.New("no id token signing key")
+
+// IDTokenClaims are the claims of an OpenID Connect ID token (Core section
+// 2). Audience is the app's client ID. The user claims are only set for
+// the scopes the user released; the verified flags are pointers so that
+// false is still sent.
+type IDTokenClaims struct {
+	Nonce               string           `json:"nonce,omitempty"`
+	AuthTime            *jwt.NumericDate `json:"auth_time,omitempty"`
+	AuthorizedParty     string           `json:"azp,omitempty"`
+	AccessTokenHash     string           `json:"at_hash,omitempty"`
+	PreferredUsername   string           `json:"preferred_username,omitempty"`
+	Email               string           `json:"email,omitempty"`
+	EmailVerified       *bool            `json:"email_verified,omitempty"`
+	PhoneNumber         string           `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool            `json:"phone_number_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWK is the public half of an ID token signing key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// LoadSigningKey reads a PEM encoded P-256 private key, either SEC 1
+// ("EC PRIVATE KEY") or PKCS #8 ("PRIVATE KEY").
+func LoadSigningKey(path string) (*ecdsa.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed any
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if key, ok = parsed.(*ecdsa.PrivateKey); !ok {
+				return nil, fmt.Errorf("%s: not an ECDSA key", path)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%s: key must be on P-256", path)
+	}
+	return key, nil
+}
+
+// GenerateSigningKey makes a fresh P-256 key, for development only: ID
+// tokens it signed stop verifying when the process restarts.
+func GenerateSigningKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// SetIDTokenKey sets the key ID tokens are signed with. Its kid is the
+// key's RFC 7638 thumbprint, so it changes with the key. Call it before
+// the manager is used.
+func (j *JWTManager) SetIDTokenKey(key *ecdsa.PrivateKey) {
+	j.idKey = key
+	j.idKeyID = newJWK(&key.PublicKey, "").thumbprint()
+}
+
+// CanIssueIDTokens reports whether an ID token signing key is set.
+func (j *JWTManager) CanIssueIDTokens() bool { return j.idKey != nil }
+
+// IssueIDToken signs claims, stamping iat as now and exp ttl later. The
+// caller sets the issuer, subject and audience.
+func (j *JWTManager) IssueIDToken(claims IDTokenClaims, ttl time.Duration) (string, error) {
+	if j.idKey == nil {
+		return "", ErrNoIDTokenKey
+	}
+	now := time.Now().UTC()
+	claims.IssuedAt, claims.ExpiresAt = jwt.NewNumericDate(now), jwt.NewNumericDate(now.Add(ttl))
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	tok.Header["kid"] = j.idKeyID
+	return tok.SignedString(j.idKey)
+}
+
+// VerifyIDToken checks an ID token this manager signed for issuer and
+// audience, the way a relying party would with the JWK set.
+func (j *JWTManager) VerifyIDToken(tokenString, issuer, audience string) (*IDTokenClaims, error) {
+	if j.idKey == nil {
+		return nil, ErrNoIDTokenKey
+	}
+	tok, err := jwt.ParseWithClaims(tokenString, &IDTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if kid, _ := token.Header["kid"].(string); kid != j.idKeyID {
+			return nil, errors.New("unknown key id")
+		}
+		return &j.idKey.PublicKey, nil
+	}, jwt.WithIssuer(issuer), jwt.WithAudience(audience), jwt.WithValidMethods([]string{IDTokenAlg}))
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := tok.Claims.(*IDTokenClaims)
+	if !ok || !tok.Valid || claims.Subject == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWKS returns the public keys ID tokens are verified with: none without a
+// signing key.
+func (j *JWTManager) JWKS() []JWK {
+	if j.idKey == nil {
+		return []JWK{}
+	}
+	return []JWK{newJWK(&j.idKey.PublicKey, j.idKeyID)}
+}
+
+// AccessTokenHash is the at_hash of an access token issued alongside an ID
+// token: the left half of its SHA-256, base64url encoded (Core section
+// 3.1.3.6).
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func newJWK(pub *ecdsa.PublicKey, kid string) JWK {
+	// Coordinates are fixed-width, 32 bytes on P-256 (RFC 7518 section
+	// 6.2.1.2).
+	x, y := make([]byte, 32), make([]byte, 32)
+	pub.X.FillBytes(x)
+	pub.Y.FillBytes(y)
+	return JWK{Kty: "EC", Crv: "P-256", X: base64.RawURLEncoding.EncodeToString(x), Y: base64.RawURLEncoding.EncodeToString(y), Use: "sig", Alg: IDTokenAlg, Kid: kid}
+}
+
+// thumbprint is the RFC 7638 SHA-256 thumbprint of the key: the required
+// members in lexicographic order, without whitespace.
+func (k JWK) thumbprint() string {
+	canonical, _ := json.Marshal(struct {
+		Crv string `json:"crv"`
+		Kty string `json:"kty"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}{k.Crv, k.Kty, k.X, k.Y})
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
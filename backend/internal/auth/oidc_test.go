@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIDTokenVerifiesWithPublishedKey(t *testing.T) {
+	mgr := NewJWTManager("secret", "akiba-api")
+	if _, err := mgr.IssueIDToken(IDTokenClaims{}, time.Minute); err != ErrNoIDTokenKey {
+		t.Fatalf("expected ErrNoIDTokenKey without a key, got %v", err)
+	}
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetIDTokenKey(key)
+	verified := true
+	token, err := mgr.IssueIDToken(IDTokenClaims{Nonce: "n-1", PhoneNumber: "+254700000001", PhoneNumberVerified: &verified, RegisteredClaims: jwt.RegisteredClaims{Issuer: "https://id.akiba.example", Subject: "u1", Audience: jwt.ClaimStrings{"app1"}}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A relying party only has the JWK set.
+	keys := mgr.JWKS()
+	if len(keys) != 1 || keys[0].Alg != "ES256" || keys[0].Kid == "" {
+		t.Fatalf("unexpected key set: %+v", keys)
+	}
+	jwk := keys[0]
+	x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, _ := base64.RawURLEncoding.DecodeString(jwk.Y)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	claims := &IDTokenClaims{}
+	tok, err := jwt.ParseWithClaims(token, claims, func(tok *jwt.Token) (interface{}, error) {
+		if tok.Header["kid"] != jwk.Kid {
+			t.Fatalf("kid %v does not match %s", tok.Header["kid"], jwk.Kid)
+		}
+		return pub, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience("app1"), jwt.WithIssuer("https://id.akiba.example"))
+	if err != nil || !tok.Valid {
+		t.Fatalf("id token does not verify with the published key: %v", err)
+	}
+	if claims.Nonce != "n-1" || claims.PhoneNumberVerified == nil || !*claims.PhoneNumberVerified || claims.EmailVerified != nil {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := mgr.VerifyIDToken(token, "https://id.akiba.example", "app2"); err == nil {
+		t.Fatal("expected a token for another audience to be refused")
+	}
+	if _, err := mgr.Verify(token); err == nil {
+		t.Fatal("an ID token must not pass as an access token")
+	}
+}
+
+func TestLoadSigningKeyReadsPKCS8AndSEC1(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, block := range map[string]*pem.Block{"pkcs8.pem": {Type: "PRIVATE KEY", Bytes: pkcs8}, "sec1.pem": {Type: "EC PRIVATE KEY", Bytes: sec1}} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadSigningKey(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !loaded.Equal(key) {
+			t.Fatalf("%s: loaded a different key", name)
+		}
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(p384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "p384.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSigningKey(path); err == nil {
+		t.Fatal("expected a P-384 key to be refused")
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The SHA-1 vectors of RFC 6238 appendix B, cut to six digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Fatalf("T=%d: expected %s, got %s %v", unix, want, got, err)
+		}
+	}
+}
+
+func TestVerifyTOTPAcceptsOneStepOfDrift(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1767225600, 0)
+	code, _ := TOTPCode(secret, TOTPStep(now))
+	if step, ok := VerifyTOTP(secret, code, now.Add(TOTPPeriod)); !ok || step != TOTPStep(now) {
+		t.Fatalf("expected the previous step's code accepted, got %d %v", step, ok)
+	}
+	if _, ok := VerifyTOTP(secret, code, now.Add(2*TOTPPeriod)); ok {
+		t.Fatal("expected a code two steps old to be refused")
+	}
+	if _, ok := VerifyTOTP(secret, "12345", now); ok {
+		t.Fatal("expected a short code to be refused")
+	}
+	uri := TOTPKeyURI("Akiba", "alice@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Akiba:alice@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected key URI %s", uri)
+	}
+}
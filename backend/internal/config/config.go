@@ -2,20 +2,66 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Env            string
-	Port           int
-	MongoURI       string
-	MongoDBName    string
-	JWTSecret      string
-	JWTIssuer      string
-	AccessTokenTTL time.Duration
-	DBTimeout      time.Duration
+	Env                        string
+	Port                       int
+	MongoURI                   string
+	MongoDBName                string
+	UserStorage                string
+	MemoryStore                bool
+	PostgresURL                string
+	RequireMigrations          bool
+	JWTSecret                  string
+	JWTIssuer                  string
+	AccessTokenTTL             time.Duration
+	DBTimeout                  time.Duration
+	FXRatesFile                string
+	FXQuoteTTL                 time.Duration
+	FXSpreadBps                int
+	FeeScheduleFile            string
+	HoldTTL                    time.Duration
+	HoldSweepEvery             time.Duration
+	BeneficiaryCoolingOff      time.Duration
+	BeneficiaryCoolingOffLimit int64
+	AMLRulesFile               string
+	AMLScanEvery               time.Duration
+	RiskConfigFile             string
+	StepUpMaxAge               time.Duration
+	StepUpTokenTTL             time.Duration
+	StepUpTransferThresholds   map[string]int64
+	PINMaxAttempts             int
+	PINLockout                 time.Duration
+	TOTPIssuer                 string
+	WebAuthnRPID               string
+	WebAuthnRPName             string
+	WebAuthnOrigins            []string
+	WebAuthnChallengeTTL       time.Duration
+	OTPTTL                     time.Duration
+	OTPMaxAttempts             int
+	ImpersonationTTL           time.Duration
+	OutboxRelayEnabled         bool
+	OutboxRelayEvery           time.Duration
+	OutboxMaxAttempts          int
+	EventPublisher             string
+	EventWebhookURL            string
+	WebhookDeliveryEvery       time.Duration
+	WebhookMaxAttempts         int
+	WebhookTimeout             time.Duration
+	ClientTokenTTL             time.Duration
+	OAuthAccessTokenTTL        time.Duration
+	OAuthRefreshTokenTTL       time.Duration
+	OIDCIssuer                 string
+	OIDCAuthorizationURL       string
+	OIDCSigningKeyFile         string
+	PIIKeyFile                 string
+	PIIReencryptEvery          time.Duration
 }
 
 func Load() (Config, error) {
@@ -31,16 +77,163 @@ func Load() (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	fxQuoteTTL, err := getEnvDuration("FX_QUOTE_TTL", 30*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	fxSpreadBps, err := getEnvInt("FX_SPREAD_BPS", 150)
+	if err != nil {
+		return Config{}, err
+	}
+	holdTTL, err := getEnvDuration("HOLD_TTL", 72*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	holdSweepEvery, err := getEnvDuration("HOLD_SWEEP_INTERVAL", time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	coolingOff, err := getEnvDuration("BENEFICIARY_COOLING_OFF", 24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	coolingOffLimit, err := getEnvInt("BENEFICIARY_COOLING_OFF_LIMIT", 1000000)
+	if err != nil {
+		return Config{}, err
+	}
+	amlScanEvery, err := getEnvDuration("AML_SCAN_INTERVAL", time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	stepUpMaxAge, err := getEnvDuration("STEP_UP_MAX_AGE", 5*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	stepUpTokenTTL, err := getEnvDuration("STEP_UP_TOKEN_TTL", 5*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	if os.Getenv("STEP_UP_TRANSFER_THRESHOLD") != "" {
+		return Config{}, fmt.Errorf("STEP_UP_TRANSFER_THRESHOLD was replaced by STEP_UP_TRANSFER_THRESHOLDS, e.g. KES=5000000")
+	}
+	stepUpTransferThresholds, err := getEnvAmounts("STEP_UP_TRANSFER_THRESHOLDS", "KES=5000000,UGX=1500000,TZS=100000000,USD=40000")
+	if err != nil {
+		return Config{}, err
+	}
+	pinMaxAttempts, err := getEnvInt("PIN_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return Config{}, err
+	}
+	pinLockout, err := getEnvDuration("PIN_LOCKOUT", 30*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	webAuthnChallengeTTL, err := getEnvDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	otpTTL, err := getEnvDuration("OTP_TTL", 5*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	otpMaxAttempts, err := getEnvInt("OTP_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return Config{}, err
+	}
+	impersonationTTL, err := getEnvDuration("IMPERSONATION_TTL", 15*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	outboxRelayEvery, err := getEnvDuration("OUTBOX_RELAY_INTERVAL", time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	outboxMaxAttempts, err := getEnvInt("OUTBOX_MAX_ATTEMPTS", 12)
+	if err != nil {
+		return Config{}, err
+	}
+	webhookDeliveryEvery, err := getEnvDuration("WEBHOOK_DELIVERY_INTERVAL", 5*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	webhookMaxAttempts, err := getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10)
+	if err != nil {
+		return Config{}, err
+	}
+	webhookTimeout, err := getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	clientTokenTTL, err := getEnvDuration("CLIENT_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	oauthAccessTokenTTL, err := getEnvDuration("OAUTH_ACCESS_TOKEN_TTL", 10*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	oauthRefreshTokenTTL, err := getEnvDuration("OAUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	piiReencryptEvery, err := getEnvDuration("PII_REENCRYPT_INTERVAL", time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
 
 	cfg := Config{
-		Env:            getEnv("ENV", "development"),
-		Port:           port,
-		MongoURI:       getEnv("MONGO_URI", "mongodb://mongo:27017"),
-		MongoDBName:    getEnv("MONGO_DB_NAME", "akiba"),
-		JWTSecret:      getEnv("JWT_SECRET", "change-me-in-production"),
-		JWTIssuer:      getEnv("JWT_ISSUER", "akiba-api"),
-		AccessTokenTTL: accessTokenTTL,
-		DBTimeout:      dbTimeout,
+		Env:                        getEnv("ENV", "development"),
+		Port:                       port,
+		MongoURI:                   getEnv("MONGO_URI", "mongodb://mongo:27017"),
+		MongoDBName:                getEnv("MONGO_DB_NAME", "akiba"),
+		UserStorage:                getEnv("USER_STORAGE", "mongo"),
+		MemoryStore:                getEnv("MEMORY_STORE", "false") == "true",
+		PostgresURL:                getEnv("POSTGRES_URL", ""),
+		RequireMigrations:          getEnv("REQUIRE_MIGRATIONS", "true") == "true",
+		JWTSecret:                  getEnv("JWT_SECRET", "change-me-in-production"),
+		JWTIssuer:                  getEnv("JWT_ISSUER", "akiba-api"),
+		AccessTokenTTL:             accessTokenTTL,
+		DBTimeout:                  dbTimeout,
+		FXRatesFile:                getEnv("FX_RATES_FILE", ""),
+		FXQuoteTTL:                 fxQuoteTTL,
+		FXSpreadBps:                fxSpreadBps,
+		FeeScheduleFile:            getEnv("FEE_SCHEDULE_FILE", ""),
+		HoldTTL:                    holdTTL,
+		HoldSweepEvery:             holdSweepEvery,
+		BeneficiaryCoolingOff:      coolingOff,
+		BeneficiaryCoolingOffLimit: int64(coolingOffLimit),
+		AMLRulesFile:               getEnv("AML_RULES_FILE", ""),
+		AMLScanEvery:               amlScanEvery,
+		RiskConfigFile:             getEnv("RISK_CONFIG_FILE", ""),
+		StepUpMaxAge:               stepUpMaxAge,
+		StepUpTokenTTL:             stepUpTokenTTL,
+		StepUpTransferThresholds:   stepUpTransferThresholds,
+		PINMaxAttempts:             pinMaxAttempts,
+		PINLockout:                 pinLockout,
+		TOTPIssuer:                 getEnv("TOTP_ISSUER", "Akiba"),
+		WebAuthnRPID:               getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:             getEnv("WEBAUTHN_RP_NAME", "Akiba"),
+		WebAuthnOrigins:            getEnvList("WEBAUTHN_ORIGINS", "http://localhost:3000"),
+		WebAuthnChallengeTTL:       webAuthnChallengeTTL,
+		OTPTTL:                     otpTTL,
+		OTPMaxAttempts:             otpMaxAttempts,
+		ImpersonationTTL:           impersonationTTL,
+		OutboxRelayEnabled:         getEnv("OUTBOX_RELAY_ENABLED", "true") == "true",
+		OutboxRelayEvery:           outboxRelayEvery,
+		OutboxMaxAttempts:          outboxMaxAttempts,
+		EventPublisher:             getEnv("EVENT_PUBLISHER", "log"),
+		EventWebhookURL:            getEnv("EVENT_WEBHOOK_URL", ""),
+		WebhookDeliveryEvery:       webhookDeliveryEvery,
+		WebhookMaxAttempts:         webhookMaxAttempts,
+		WebhookTimeout:             webhookTimeout,
+		ClientTokenTTL:             clientTokenTTL,
+		OAuthAccessTokenTTL:        oauthAccessTokenTTL,
+		OAuthRefreshTokenTTL:       oauthRefreshTokenTTL,
+		OIDCIssuer:                 strings.TrimSuffix(getEnv("OIDC_ISSUER", ""), "/"),
+		OIDCAuthorizationURL:       getEnv("OIDC_AUTHORIZATION_URL", "http://localhost:3000/oauth/authorize"),
+		OIDCSigningKeyFile:         getEnv("OIDC_SIGNING_KEY_FILE", ""),
+		PIIKeyFile:                 getEnv("PII_KEY_FILE", ""),
+		PIIReencryptEvery:          piiReencryptEvery,
 	}
 	if cfg.JWTSecret == "" {
 		return Config{}, fmt.Errorf("JWT_SECRET cannot be empty")
@@ -54,6 +247,134 @@ func Load() (Config, error) {
 	if cfg.DBTimeout <= 0 {
 		return Config{}, fmt.Errorf("DB_TIMEOUT must be > 0")
 	}
+	if cfg.FXQuoteTTL <= 0 {
+		return Config{}, fmt.Errorf("FX_QUOTE_TTL must be > 0")
+	}
+	if cfg.FXSpreadBps < 0 || cfg.FXSpreadBps >= 10000 {
+		return Config{}, fmt.Errorf("FX_SPREAD_BPS must be between 0 and 9999")
+	}
+	if cfg.HoldTTL <= 0 {
+		return Config{}, fmt.Errorf("HOLD_TTL must be > 0")
+	}
+	if cfg.HoldSweepEvery <= 0 {
+		return Config{}, fmt.Errorf("HOLD_SWEEP_INTERVAL must be > 0")
+	}
+	if cfg.BeneficiaryCoolingOff < 0 {
+		return Config{}, fmt.Errorf("BENEFICIARY_COOLING_OFF must be >= 0")
+	}
+	if cfg.BeneficiaryCoolingOffLimit <= 0 {
+		return Config{}, fmt.Errorf("BENEFICIARY_COOLING_OFF_LIMIT must be > 0")
+	}
+	if cfg.AMLScanEvery <= 0 {
+		return Config{}, fmt.Errorf("AML_SCAN_INTERVAL must be > 0")
+	}
+	if cfg.StepUpMaxAge <= 0 {
+		return Config{}, fmt.Errorf("STEP_UP_MAX_AGE must be > 0")
+	}
+	if cfg.StepUpTokenTTL <= 0 {
+		return Config{}, fmt.Errorf("STEP_UP_TOKEN_TTL must be > 0")
+	}
+	if len(cfg.StepUpTransferThresholds) == 0 {
+		return Config{}, fmt.Errorf("STEP_UP_TRANSFER_THRESHOLDS must name at least one currency")
+	}
+	if cfg.PINMaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("PIN_MAX_ATTEMPTS must be > 0")
+	}
+	if cfg.PINLockout <= 0 {
+		return Config{}, fmt.Errorf("PIN_LOCKOUT must be > 0")
+	}
+	if len(cfg.WebAuthnOrigins) == 0 {
+		return Config{}, fmt.Errorf("WEBAUTHN_ORIGINS cannot be empty")
+	}
+	if cfg.WebAuthnChallengeTTL <= 0 {
+		return Config{}, fmt.Errorf("WEBAUTHN_CHALLENGE_TTL must be > 0")
+	}
+	if cfg.OTPTTL <= 0 {
+		return Config{}, fmt.Errorf("OTP_TTL must be > 0")
+	}
+	if cfg.OTPMaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("OTP_MAX_ATTEMPTS must be > 0")
+	}
+	if cfg.ImpersonationTTL <= 0 || cfg.ImpersonationTTL > time.Hour {
+		return Config{}, fmt.Errorf("IMPERSONATION_TTL must be > 0 and at most 1h")
+	}
+	if cfg.OutboxRelayEvery <= 0 {
+		return Config{}, fmt.Errorf("OUTBOX_RELAY_INTERVAL must be > 0")
+	}
+	if cfg.OutboxMaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be > 0")
+	}
+	if cfg.WebhookDeliveryEvery <= 0 {
+		return Config{}, fmt.Errorf("WEBHOOK_DELIVERY_INTERVAL must be > 0")
+	}
+	if cfg.WebhookMaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be > 0")
+	}
+	if cfg.WebhookTimeout <= 0 {
+		return Config{}, fmt.Errorf("WEBHOOK_TIMEOUT must be > 0")
+	}
+	if cfg.ClientTokenTTL <= 0 || cfg.ClientTokenTTL > time.Hour {
+		return Config{}, fmt.Errorf("CLIENT_TOKEN_TTL must be > 0 and at most 1h")
+	}
+	if cfg.OAuthAccessTokenTTL <= 0 || cfg.OAuthAccessTokenTTL > time.Hour {
+		return Config{}, fmt.Errorf("OAUTH_ACCESS_TOKEN_TTL must be > 0 and at most 1h")
+	}
+	if cfg.OAuthRefreshTokenTTL < cfg.OAuthAccessTokenTTL {
+		return Config{}, fmt.Errorf("OAUTH_REFRESH_TOKEN_TTL must be at least OAUTH_ACCESS_TOKEN_TTL")
+	}
+	if cfg.OIDCIssuer != "" {
+		// Apps compare the issuer exactly and fetch the discovery document
+		// under it, so it must be a plain URL (OpenID Connect Discovery
+		// section 3).
+		u, err := url.Parse(cfg.OIDCIssuer)
+		if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" || (u.Scheme != "https" && !(u.Scheme == "http" && cfg.Env == "development")) {
+			return Config{}, fmt.Errorf("OIDC_ISSUER must be an https URL without query or fragment")
+		}
+		if u, err := url.Parse(cfg.OIDCAuthorizationURL); err != nil || !u.IsAbs() || u.Host == "" {
+			return Config{}, fmt.Errorf("OIDC_AUTHORIZATION_URL must be an absolute URL")
+		}
+		if cfg.OIDCSigningKeyFile == "" && cfg.Env != "development" {
+			return Config{}, fmt.Errorf("OIDC_SIGNING_KEY_FILE is required when OIDC_ISSUER is set")
+		}
+	}
+	// Without a key file personal data is sealed with publicly known
+	// development keys.
+	if cfg.PIIKeyFile == "" && cfg.Env != "development" {
+		return Config{}, fmt.Errorf("PII_KEY_FILE is required outside development")
+	}
+	if cfg.PIIReencryptEvery <= 0 {
+		return Config{}, fmt.Errorf("PII_REENCRYPT_INTERVAL must be > 0")
+	}
+	// USER_STORAGE only moves users; everything else stays in Mongo, so
+	// MONGO_URI is needed either way.
+	switch cfg.UserStorage {
+	case "mongo":
+	case "postgres":
+		if cfg.PostgresURL == "" {
+			return Config{}, fmt.Errorf("POSTGRES_URL is required when USER_STORAGE is postgres")
+		}
+	default:
+		return Config{}, fmt.Errorf("USER_STORAGE must be mongo or postgres")
+	}
+	if cfg.MemoryStore {
+		// Nothing survives a restart, so memory is for demos and local
+		// development only. It holds the users too.
+		if cfg.Env != "development" {
+			return Config{}, fmt.Errorf("MEMORY_STORE is only allowed in development")
+		}
+		if cfg.UserStorage != "mongo" {
+			return Config{}, fmt.Errorf("MEMORY_STORE keeps users in memory; unset USER_STORAGE")
+		}
+	}
+	switch cfg.EventPublisher {
+	case "log":
+	case "http":
+		if cfg.EventWebhookURL == "" {
+			return Config{}, fmt.Errorf("EVENT_WEBHOOK_URL is required when EVENT_PUBLISHER is http")
+		}
+	default:
+		return Config{}, fmt.Errorf("EVENT_PUBLISHER must be log or http")
+	}
 	return cfg, nil
 }
 
@@ -63,6 +384,36 @@ func getEnv(k, def string) string {
 	}
 	return def
 }
+
+// getEnvList splits a comma-separated value, dropping empty items.
+func getEnvList(k, def string) []string {
+	var out []string
+	for _, v := range strings.Split(getEnv(k, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getEnvAmounts parses comma-separated CODE=amount pairs, keyed by the
+// upper-case currency code. Every amount must be positive.
+func getEnvAmounts(k, def string) (map[string]int64, error) {
+	out := map[string]int64{}
+	for _, item := range getEnvList(k, def) {
+		code, amount, ok := strings.Cut(item, "=")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		n, err := strconv.ParseInt(strings.TrimSpace(amount), 10, 64)
+		if !ok || code == "" || err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s must be comma-separated CODE=amount pairs with positive amounts, got %q", k, item)
+		}
+		if _, dup := out[code]; dup {
+			return nil, fmt.Errorf("%s names %s twice", k, code)
+		}
+		out[code] = n
+	}
+	return out, nil
+}
 func getEnvInt(k string, def int) (int, error) {
 	v := os.Getenv(k)
 	if v == "" {
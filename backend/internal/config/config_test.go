@@ -42,3 +42,139 @@ func TestLoadDefaultsRemainValid(t *testing.T) {
 		t.Fatalf("unexpected default port: %d", cfg.Port)
 	}
 }
+
+func TestLoadRejectsOutOfRangeFXSpread(t *testing.T) {
+	t.Setenv("FX_SPREAD_BPS", "10000")
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "FX_SPREAD_BPS") {
+		t.Fatalf("expected FX_SPREAD_BPS validation error, got %v", err)
+	}
+}
+
+func TestLoadSplitsWebAuthnOrigins(t *testing.T) {
+	t.Setenv("WEBAUTHN_ORIGINS", "https://app.akiba.example, https://akiba.example,")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.WebAuthnOrigins) != 2 || cfg.WebAuthnOrigins[1] != "https://akiba.example" {
+		t.Fatalf("unexpected origins: %v", cfg.WebAuthnOrigins)
+	}
+	t.Setenv("WEBAUTHN_ORIGINS", " , ")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WEBAUTHN_ORIGINS") {
+		t.Fatalf("expected WEBAUTHN_ORIGINS validation error, got %v", err)
+	}
+}
+
+func TestLoadRequiresWebhookURLForHTTPPublisher(t *testing.T) {
+	t.Setenv("EVENT_PUBLISHER", "http")
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "EVENT_WEBHOOK_URL") {
+		t.Fatalf("expected EVENT_WEBHOOK_URL validation error, got %v", err)
+	}
+}
+
+func TestLoadValidatesOIDCIssuer(t *testing.T) {
+	t.Setenv("OIDC_ISSUER", "https://id.akiba.example/")
+	t.Setenv("OIDC_SIGNING_KEY_FILE", "/etc/akiba/oidc.pem")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OIDCIssuer != "https://id.akiba.example" {
+		t.Fatalf("trailing slash not trimmed: %q", cfg.OIDCIssuer)
+	}
+	t.Setenv("OIDC_ISSUER", "https://id.akiba.example?tenant=1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OIDC_ISSUER") {
+		t.Fatalf("expected OIDC_ISSUER validation error, got %v", err)
+	}
+	t.Setenv("ENV", "production")
+	t.Setenv("OIDC_ISSUER", "http://id.akiba.example")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OIDC_ISSUER") {
+		t.Fatalf("expected plain http issuer to be refused outside development, got %v", err)
+	}
+	t.Setenv("OIDC_ISSUER", "https://id.akiba.example")
+	t.Setenv("OIDC_SIGNING_KEY_FILE", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OIDC_SIGNING_KEY_FILE") {
+		t.Fatalf("expected OIDC_SIGNING_KEY_FILE validation error, got %v", err)
+	}
+}
+
+func TestLoadRequiresPIIKeyFileOutsideDevelopment(t *testing.T) {
+	if _, err := Load(); err != nil {
+		t.Fatalf("expected development keys to be allowed in development, got %v", err)
+	}
+	t.Setenv("ENV", "production")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "PII_KEY_FILE") {
+		t.Fatalf("expected PII_KEY_FILE validation error, got %v", err)
+	}
+	t.Setenv("PII_KEY_FILE", "/etc/akiba/pii-keys.json")
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadValidatesUserStorage(t *testing.T) {
+	t.Setenv("USER_STORAGE", "postgres")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "POSTGRES_URL") {
+		t.Fatalf("expected POSTGRES_URL validation error, got %v", err)
+	}
+	t.Setenv("POSTGRES_URL", "postgres://akiba@localhost:5432/akiba")
+	if cfg, err := Load(); err != nil || cfg.UserStorage != "postgres" {
+		t.Fatalf("expected postgres user storage, got %q %v", cfg.UserStorage, err)
+	}
+	t.Setenv("USER_STORAGE", "memory")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "USER_STORAGE") {
+		t.Fatalf("expected USER_STORAGE validation error, got %v", err)
+	}
+}
+
+func TestLoadAllowsMemoryStoreOnlyInDevelopment(t *testing.T) {
+	t.Setenv("MEMORY_STORE", "true")
+	if cfg, err := Load(); err != nil || !cfg.MemoryStore {
+		t.Fatalf("expected memory store, got %v %v", cfg.MemoryStore, err)
+	}
+	t.Setenv("USER_STORAGE", "postgres")
+	t.Setenv("POSTGRES_URL", "postgres://akiba@localhost:5432/akiba")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "USER_STORAGE") {
+		t.Fatalf("expected USER_STORAGE conflict, got %v", err)
+	}
+	t.Setenv("USER_STORAGE", "mongo")
+	t.Setenv("ENV", "production")
+	t.Setenv("PII_KEY_FILE", "/etc/akiba/pii-keys.json")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MEMORY_STORE") {
+		t.Fatalf("expected MEMORY_STORE validation error, got %v", err)
+	}
+}
+
+func TestLoadRequiresMigrationsUnlessDisabled(t *testing.T) {
+	if cfg, err := Load(); err != nil || !cfg.RequireMigrations {
+		t.Fatalf("expected pending migrations to block startup by default, got %v %v", cfg.RequireMigrations, err)
+	}
+	t.Setenv("REQUIRE_MIGRATIONS", "false")
+	if cfg, err := Load(); err != nil || cfg.RequireMigrations {
+		t.Fatalf("expected REQUIRE_MIGRATIONS=false to only warn, got %v %v", cfg.RequireMigrations, err)
+	}
+}
+
+func TestLoadParsesStepUpThresholdsPerCurrency(t *testing.T) {
+	t.Setenv("STEP_UP_TRANSFER_THRESHOLDS", "kes=5000000, USD=40000")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.StepUpTransferThresholds) != 2 || cfg.StepUpTransferThresholds["KES"] != 5000000 || cfg.StepUpTransferThresholds["USD"] != 40000 {
+		t.Fatalf("unexpected thresholds: %v", cfg.StepUpTransferThresholds)
+	}
+	for _, bad := range []string{"KES", "KES=0", "KES=abc", "KES=1,kes=2", " , "} {
+		t.Setenv("STEP_UP_TRANSFER_THRESHOLDS", bad)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "STEP_UP_TRANSFER_THRESHOLDS") {
+			t.Fatalf("%q: expected STEP_UP_TRANSFER_THRESHOLDS validation error, got %v", bad, err)
+		}
+	}
+	t.Setenv("STEP_UP_TRANSFER_THRESHOLDS", "")
+	t.Setenv("STEP_UP_TRANSFER_THRESHOLD", "5000000")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "STEP_UP_TRANSFER_THRESHOLDS") {
+		t.Fatalf("expected the old single threshold to be refused, got %v", err)
+	}
+}
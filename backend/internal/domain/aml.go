@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+type AlertStatus string
+
+const (
+	AlertStatusOpen          AlertStatus = "open"
+	AlertStatusInvestigating AlertStatus = "investigating"
+	// AlertStatusDismissed closes an alert as a false positive.
+	AlertStatusDismissed AlertStatus = "dismissed"
+	// AlertStatusEscalated closes an alert as reported to the financial
+	// intelligence unit.
+	AlertStatusEscalated AlertStatus = "escalated"
+)
+
+var alertTransitions = map[AlertStatus][]AlertStatus{
+	AlertStatusOpen:          {AlertStatusInvestigating, AlertStatusDismissed, AlertStatusEscalated},
+	AlertStatusInvestigating: {AlertStatusDismissed, AlertStatusEscalated},
+}
+
+// CanTransition reports whether an alert may move from s to next. Closed
+// alerts are final.
+func (s AlertStatus) CanTransition(next AlertStatus) bool {
+	for _, allowed := range alertTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s AlertStatus) Closed() bool {
+	return s == AlertStatusDismissed || s == AlertStatusEscalated
+}
+
+// AMLAlert is a case in the compliance queue raised by a monitoring rule.
+// SubjectKey is the rule's group ("account:<id>" or "owner:<id>"); while an
+// alert is unresolved, further hits on the same rule and subject add their
+// entries to EntryIDs instead of opening a new case.
+type AMLAlert struct {
+	ID             string
+	RuleID         string
+	RuleName       string
+	Severity       string
+	SubjectKey     string
+	OwnerID        string
+	AccountID      string
+	Currency       string
+	Value          int64
+	Threshold      int64
+	TriggerEntryID string
+	EntryIDs       []string
+	Status         AlertStatus
+	AssignedTo     string
+	ResolutionNote string
+	ResolvedBy     string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ResolvedAt     time.Time
+}
@@ -0,0 +1,187 @@
+package domain
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+type APIClientStatus string
+
+const (
+	APIClientActive   APIClientStatus = "active"
+	APIClientDisabled APIClientStatus = "disabled"
+)
+
+// Scope names what a token issued to an API client may do. Client tokens
+// carry scopes instead of a role's permissions.
+type Scope string
+
+const (
+	// ScopeWebhooksRead allows a client to list its webhook endpoints and
+	// their deliveries.
+	ScopeWebhooksRead Scope = "webhooks:read"
+	// ScopeWebhooksWrite allows a client to redeliver its webhook
+	// deliveries.
+	ScopeWebhooksWrite Scope = "webhooks:write"
+
+	// ScopeProfile allows an app to read the profile of the user who
+	// approved it.
+	ScopeProfile Scope = "profile"
+	// ScopeAccountsRead allows an app to list the user's accounts and
+	// balances.
+	ScopeAccountsRead Scope = "accounts:read"
+	// ScopeOfflineAccess lets an app keep access with a refresh token after
+	// its access token expires.
+	ScopeOfflineAccess Scope = "offline_access"
+	// ScopeOpenID makes the request an OpenID Connect sign-in: the app gets
+	// an ID token and may call the userinfo endpoint.
+	ScopeOpenID Scope = "openid"
+	// ScopeEmail releases the user's email address to an OpenID Connect app.
+	ScopeEmail Scope = "email"
+	// ScopePhone releases the user's phone number to an OpenID Connect app.
+	ScopePhone Scope = "phone"
+)
+
+// KnownScopes lists every scope a client may be granted.
+func KnownScopes() []Scope {
+	return []Scope{ScopeWebhooksRead, ScopeWebhooksWrite, ScopeProfile, ScopeAccountsRead, ScopeOfflineAccess, ScopeOpenID, ScopeEmail, ScopePhone}
+}
+
+// Delegated reports whether s is a scope a user grants an app through
+// consent, as opposed to one the client holds for itself.
+func (s Scope) Delegated() bool {
+	switch s {
+	case ScopeProfile, ScopeAccountsRead, ScopeOfflineAccess, ScopeOpenID, ScopeEmail, ScopePhone:
+		return true
+	}
+	return false
+}
+
+// APIKey is a client secret. Only its SHA-256 is stored; Prefix is the
+// key's public first part, shown in listings so a key can be recognised
+// without revealing it.
+type APIKey struct {
+	Prefix     string
+	Hash       string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+}
+
+func (k APIKey) Active() bool { return k.RevokedAt.IsZero() }
+
+// APIClient is a partner backend, internal service or third-party app. It
+// authenticates with one of its keys and may only be granted Scopes. An
+// empty AllowedNetworks accepts any address. Apps that act for users are
+// sent back to one of RedirectURIs after consent; a Public client, such as
+// a mobile app, cannot keep a secret and so has no keys.
+type APIClient struct {
+	ID              string
+	Name            string
+	Scopes          []Scope
+	AllowedNetworks []netip.Prefix
+	RedirectURIs    []string
+	Public          bool
+	Keys            []APIKey
+	Status          APIClientStatus
+	CreatedBy       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (c *APIClient) HasScope(s Scope) bool { return slices.Contains(c.Scopes, s) }
+
+// Key returns the client's key with prefix, revoked or not.
+func (c *APIClient) Key(prefix string) (APIKey, bool) {
+	for _, k := range c.Keys {
+		if k.Prefix == prefix {
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// ActiveKeys counts keys that have not been revoked.
+func (c *APIClient) ActiveKeys() int {
+	n := 0
+	for _, k := range c.Keys {
+		if k.Active() {
+			n++
+		}
+	}
+	return n
+}
+
+// AllowsAddr reports whether ip falls in one of the allowed networks.
+func (c *APIClient) AllowsAddr(ip string) bool {
+	return NetworksAllow(c.AllowedNetworks, ip)
+}
+
+// NetworksAllow reports whether ip falls in one of networks; no networks
+// allow every address.
+func NetworksAllow(networks []netip.Prefix, ip string) bool {
+	if len(networks) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, n := range networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses CIDRs such as "203.0.113.0/24"; a bare address is
+// taken as a single host. It returns the first entry that does not parse.
+func ParseNetworks(raw []string) ([]netip.Prefix, string, bool) {
+	var out []netip.Prefix
+	for _, s := range raw {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				return nil, s, false
+			}
+			p = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		p = p.Masked()
+		if !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	return out, "", true
+}
+
+// NormalizeScopes trims and de-duplicates scopes, keeping their order. It
+// splits on spaces too, so an OAuth scope parameter can be passed whole.
+func NormalizeScopes(raw []string) []Scope {
+	var out []Scope
+	for _, s := range raw {
+		for _, f := range strings.Fields(s) {
+			if sc := Scope(f); !slices.Contains(out, sc) {
+				out = append(out, sc)
+			}
+		}
+	}
+	return out
+}
+
+// ScopeString joins scopes the way the OAuth scope parameter does.
+func ScopeString(scopes []Scope) string {
+	parts := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		parts = append(parts, string(s))
+	}
+	return strings.Join(parts, " ")
+}
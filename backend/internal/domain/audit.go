@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// AuditAction names a security event in the audit log.
+type AuditAction string
+
+const (
+	AuditSignup               AuditAction = "auth.signup"
+	AuditLoginSucceeded       AuditAction = "auth.login_succeeded"
+	AuditLoginFailed          AuditAction = "auth.login_failed"
+	AuditTokenIssued          AuditAction = "auth.token_issued"
+	AuditPasswordChanged      AuditAction = "auth.password_changed"
+	AuditUserStatusChanged    AuditAction = "user.status_changed"
+	AuditRoleChanged          AuditAction = "admin.role_changed"
+	AuditKYCReviewed          AuditAction = "admin.kyc_reviewed"
+	AuditImpersonationStarted AuditAction = "admin.impersonation_started"
+	AuditWebhookCreated       AuditAction = "admin.webhook_created"
+	AuditWebhookDisabled      AuditAction = "admin.webhook_disabled"
+	AuditWebhookSecretRotated AuditAction = "admin.webhook_secret_rotated"
+	AuditAPIClientCreated     AuditAction = "admin.api_client_created"
+	AuditAPIClientDisabled    AuditAction = "admin.api_client_disabled"
+	AuditAPIKeyCreated        AuditAction = "admin.api_key_created"
+	AuditAPIKeyRevoked        AuditAction = "admin.api_key_revoked"
+	AuditClientTokenIssued    AuditAction = "auth.client_token_issued"
+	AuditClientAuthFailed     AuditAction = "auth.client_auth_failed"
+	AuditOAuthConsentGranted  AuditAction = "oauth.consent_granted"
+	AuditOAuthConsentRevoked  AuditAction = "oauth.consent_revoked"
+	AuditOAuthTokenIssued     AuditAction = "oauth.token_issued"
+	AuditOAuthTokenRevoked    AuditAction = "oauth.token_revoked"
+	AuditOAuthTokenReused     AuditAction = "oauth.token_reuse_detected"
+)
+
+// AuditRecord is one entry of the append-only audit log. Records are
+// numbered from 1 without gaps, and each carries the hash of the one before
+// it, so editing, removing or reordering a record breaks the chain.
+// ActorID is empty when nobody is signed in, for example a failed login.
+type AuditRecord struct {
+	Seq        int64
+	Action     AuditAction
+	ActorID    string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	RequestID  string
+	Details    map[string]string
+	CreatedAt  time.Time
+	PrevHash   string
+	Hash       string
+}
+
+// Seal places r after prev, nil for the first record, and sets its hash.
+// CreatedAt is cut to milliseconds and empty Details to nil so the hash
+// survives storage.
+func (r *AuditRecord) Seal(prev *AuditRecord) {
+	r.Seq, r.PrevHash = 1, ""
+	if prev != nil {
+		r.Seq, r.PrevHash = prev.Seq+1, prev.Hash
+	}
+	r.CreatedAt = r.CreatedAt.UTC().Truncate(time.Millisecond)
+	if len(r.Details) == 0 {
+		r.Details = nil
+	}
+	r.Hash = r.ComputeHash()
+}
+
+// ComputeHash returns the hex SHA-256 of every field but Hash. Fields are
+// encoded as JSON in a fixed order, with Details keys sorted.
+func (r *AuditRecord) ComputeHash() string {
+	b, _ := json.Marshal(struct {
+		Seq        int64             `json:"seq"`
+		Action     AuditAction       `json:"action"`
+		ActorID    string            `json:"actorId"`
+		TargetType string            `json:"targetType"`
+		TargetID   string            `json:"targetId"`
+		IP         string            `json:"ip"`
+		UserAgent  string            `json:"userAgent"`
+		RequestID  string            `json:"requestId"`
+		Details    map[string]string `json:"details"`
+		CreatedAt  string            `json:"createdAt"`
+		PrevHash   string            `json:"prevHash"`
+	}{r.Seq, r.Action, r.ActorID, r.TargetType, r.TargetID, r.IP, r.UserAgent, r.RequestID, r.Details, r.CreatedAt.UTC().Format(time.RFC3339Nano), r.PrevHash})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter narrows an audit log search; empty fields match everything.
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+	Action   AuditAction
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
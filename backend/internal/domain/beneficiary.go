@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+type BeneficiaryType string
+
+const (
+	BeneficiaryTypeAkiba BeneficiaryType = "akiba_user"
+	BeneficiaryTypeMpesa BeneficiaryType = "mpesa"
+	BeneficiaryTypeBank  BeneficiaryType = "bank"
+)
+
+type BeneficiaryVerification string
+
+const (
+	// BeneficiaryPending destinations have not been confirmed with the
+	// external rail yet.
+	BeneficiaryPending  BeneficiaryVerification = "pending"
+	BeneficiaryVerified BeneficiaryVerification = "verified"
+	BeneficiaryFailed   BeneficiaryVerification = "failed"
+)
+
+// Beneficiary is a payee saved by OwnerID. Exactly one destination is set,
+// matching Type: RecipientID for Akiba users, Phone for M-Pesa, BankCode and
+// AccountNumber for banks. Destinations are never edited; changing one means
+// adding a new beneficiary, which restarts the cooling-off window.
+type Beneficiary struct {
+	ID            string
+	OwnerID       string
+	Type          BeneficiaryType
+	Nickname      string
+	RecipientID   string
+	Phone         string
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+	Verification  BeneficiaryVerification
+	// CoolingOffUntil ends the window after creation during which payments
+	// to the beneficiary are capped at a lower limit.
+	CoolingOffUntil time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (b *Beneficiary) InCoolingOff(now time.Time) bool { return now.Before(b.CoolingOffUntil) }
+
+// DestinationKey identifies the destination independent of nickname, so an
+// owner cannot save the same payee twice.
+func (b *Beneficiary) DestinationKey() string {
+	switch b.Type {
+	case BeneficiaryTypeAkiba:
+		return string(b.Type) + ":" + b.RecipientID
+	case BeneficiaryTypeMpesa:
+		return string(b.Type) + ":" + b.Phone
+	default:
+		return string(b.Type) + ":" + b.BankCode + ":" + b.AccountNumber
+	}
+}
+
+// PayeeCap limits what AccountID may send one payee while the payee is new
+// to it. The payee is named by the Metadata[Field] == Payee of entries and
+// holds, whether or not it was saved as a beneficiary. Everything sent from
+// Since on counts, posted or still held, net of its "fee" metadata, so only
+// what reached the payee counts against Limit. Until is when the payee
+// stops being new.
+type PayeeCap struct {
+	AccountID string
+	Field     string
+	Payee     string
+	Since     time.Time
+	Until     time.Time
+	Limit     int64
+}
+
+// EntryAmount is what e sent the payee from AccountID, or zero if e is not a
+// payment to it.
+func (c *PayeeCap) EntryAmount(e *JournalEntry) int64 {
+	if e.Metadata[c.Field] != c.Payee || e.CreatedAt.Before(c.Since) {
+		return 0
+	}
+	return -e.AmountFor(c.AccountID) - metadataFee(e.Metadata)
+}
+
+// HoldAmount is what an active hold h reserves for the payee.
+func (c *PayeeCap) HoldAmount(h *Hold) int64 {
+	if h.AccountID != c.AccountID || h.Status != HoldStatusActive || h.Metadata[c.Field] != c.Payee {
+		return 0
+	}
+	return h.Amount - metadataFee(h.Metadata)
+}
+
+func metadataFee(m map[string]string) int64 {
+	fee, _ := strconv.ParseInt(m["fee"], 10, 64)
+	return fee
+}
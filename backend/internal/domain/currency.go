@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Currency describes an ISO 4217 currency Akiba can hold. Amounts are always
+// stored as int64 minor units.
+type Currency struct {
+	Code       string
+	Numeric    string
+	MinorUnits int
+}
+
+var currencies = map[string]Currency{
+	"KES": {Code: "KES", Numeric: "404", MinorUnits: 2},
+	"UGX": {Code: "UGX", Numeric: "800", MinorUnits: 0},
+	"TZS": {Code: "TZS", Numeric: "834", MinorUnits: 2},
+	"USD": {Code: "USD", Numeric: "840", MinorUnits: 2},
+}
+
+// MaxAmount is the largest amount, in minor units, a single request may
+// name. It is far above any real payment, and far enough below MaxInt64
+// that the amount plus its fee cannot overflow.
+const MaxAmount int64 = 1_000_000_000_000_000
+
+// AmountProblem describes what is wrong with a requested amount, or returns
+// "" when it is positive and at most MaxAmount.
+func AmountProblem(amount int64) string {
+	switch {
+	case amount <= 0:
+		return "must be positive"
+	case amount > MaxAmount:
+		return fmt.Sprintf("must be at most %d", MaxAmount)
+	}
+	return ""
+}
+
+func NormalizeCurrency(code string) string { return strings.ToUpper(strings.TrimSpace(code)) }
+
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[code]
+	return c, ok
+}
+
+func LookupCurrencyByNumeric(numeric string) (Currency, bool) {
+	for _, c := range currencies {
+		if c.Numeric == numeric {
+			return c, true
+		}
+	}
+	return Currency{}, false
+}
+
+// FormatAmount renders minor units as a plain decimal string, e.g. 15050 KES
+// becomes "150.50".
+func (c Currency) FormatAmount(minor int64) string {
+	if c.MinorUnits == 0 {
+		return strconv.FormatInt(minor, 10)
+	}
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	scale := int64(1)
+	for i := 0; i < c.MinorUnits; i++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/scale, c.MinorUnits, minor%scale)
+}
+
+// ParseAmount is the inverse of FormatAmount and rejects more fractional
+// digits than the currency allows.
+func (c Currency) ParseAmount(s string) (int64, error) {
+	whole, frac, hasFrac := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" || len(frac) > c.MinorUnits || (hasFrac && frac == "") {
+		return 0, ErrInvalidInput
+	}
+	frac += strings.Repeat("0", c.MinorUnits-len(frac))
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || n < 0 {
+		return 0, ErrInvalidInput
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+)
+
+type DevicePlatform string
+
+const (
+	DevicePlatformIOS     DevicePlatform = "ios"
+	DevicePlatformAndroid DevicePlatform = "android"
+	DevicePlatformWeb     DevicePlatform = "web"
+)
+
+func (p DevicePlatform) Valid() bool {
+	return p == DevicePlatformIOS || p == DevicePlatformAndroid || p == DevicePlatformWeb
+}
+
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{8,128}$`)
+
+// ValidateDeviceID accepts the client-generated installation IDs the apps
+// send: 8-128 letters, digits and . _ : -.
+func ValidateDeviceID(id string) bool { return deviceIDPattern.MatchString(id) }
+
+// Device is an installation a user signed in from. DeviceID is chosen by the
+// client; PublicKey is the base64 DER P-256 key that signs device
+// assertions. A device becomes trusted once a login from it is confirmed by
+// OTP, and tokens are then bound to it.
+type Device struct {
+	ID          string
+	UserID      string
+	DeviceID    string
+	Platform    DevicePlatform
+	PublicKey   string
+	PushToken   string
+	Trusted     bool
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// OTPChallenge is a one-time code sent to the user's phone to confirm a
+// login from a device that is not trusted yet. Device is the device to trust
+// once the code is entered, nil when the login named none. Method is the amr
+// value of the factor the login began with; challenges stored before it
+// was recorded have none and came from a password.
+type OTPChallenge struct {
+	ID        string
+	UserID    string
+	CodeHash  string
+	Device    *Device
+	Method    string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
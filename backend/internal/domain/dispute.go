@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+type DisputeStatus string
+
+const (
+	DisputeStatusOpen             DisputeStatus = "open"
+	DisputeStatusUnderReview      DisputeStatus = "under_review"
+	DisputeStatusResolvedCustomer DisputeStatus = "resolved_customer"
+	DisputeStatusResolvedMerchant DisputeStatus = "resolved_merchant"
+)
+
+var disputeTransitions = map[DisputeStatus][]DisputeStatus{
+	DisputeStatusOpen:        {DisputeStatusUnderReview, DisputeStatusResolvedCustomer, DisputeStatusResolvedMerchant},
+	DisputeStatusUnderReview: {DisputeStatusResolvedCustomer, DisputeStatusResolvedMerchant},
+}
+
+// CanTransition reports whether a dispute may move from s to next. Resolved
+// disputes are final.
+func (s DisputeStatus) CanTransition(next DisputeStatus) bool {
+	for _, allowed := range disputeTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s DisputeStatus) Resolved() bool {
+	return s == DisputeStatusResolvedCustomer || s == DisputeStatusResolvedMerchant
+}
+
+// Evidence is a note and optional attachment submitted by either party or
+// by support. Data is never returned in listings.
+type Evidence struct {
+	ID          string
+	SubmittedBy string
+	Note        string
+	FileName    string
+	ContentType string
+	SHA256      string
+	Size        int
+	Data        []byte
+	CreatedAt   time.Time
+}
+
+// Dispute challenges a posted journal entry. While open, HeldAmount of the
+// payee's funds sits in the suspense account; resolution either refunds it
+// to the payer or releases it back to the payee.
+type Dispute struct {
+	ID                string
+	EntryID           string
+	PayerID           string
+	PayeeOwnerID      string
+	PayeeAccountID    string
+	PayerAccountID    string
+	Currency          string
+	Amount            int64
+	HeldAmount        int64
+	Reason            string
+	Status            DisputeStatus
+	Evidence          []Evidence
+	HoldEntryID       string
+	ResolutionEntryID string
+	ResolutionNote    string
+	ResolvedBy        string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	ResolvedAt        time.Time
+}
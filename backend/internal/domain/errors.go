@@ -3,9 +3,67 @@ package domain
 import "errors"
 
 var (
-	ErrInvalidInput       = errors.New("invalid_input")
-	ErrInvalidCredentials = errors.New("invalid_credentials")
-	ErrUnauthorized       = errors.New("unauthorized")
-	ErrUserExists         = errors.New("user_exists")
-	ErrUserNotFound       = errors.New("user_not_found")
+	ErrInvalidInput         = errors.New("invalid_input")
+	ErrInvalidCredentials   = errors.New("invalid_credentials")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrUserExists           = errors.New("user_exists")
+	ErrUserNotFound         = errors.New("user_not_found")
+	ErrAccountNotFound      = errors.New("account_not_found")
+	ErrInsufficientFunds    = errors.New("insufficient_funds")
+	ErrUnbalancedEntry      = errors.New("unbalanced_entry")
+	ErrDuplicateEntry       = errors.New("duplicate_entry")
+	ErrMerchantNotFound     = errors.New("merchant_not_found")
+	ErrInvalidQRPayload     = errors.New("invalid_qr_payload")
+	ErrQuoteNotFound        = errors.New("quote_not_found")
+	ErrQuoteExpired         = errors.New("quote_expired")
+	ErrQuoteExecuted        = errors.New("quote_executed")
+	ErrRecipientNotFound    = errors.New("recipient_not_found")
+	ErrForbidden            = errors.New("forbidden")
+	ErrEntryNotFound        = errors.New("entry_not_found")
+	ErrNotReversible        = errors.New("not_reversible")
+	ErrDisputeNotFound      = errors.New("dispute_not_found")
+	ErrDisputeExists        = errors.New("dispute_exists")
+	ErrInvalidTransition    = errors.New("invalid_transition")
+	ErrEvidenceNotFound     = errors.New("evidence_not_found")
+	ErrHoldNotFound         = errors.New("hold_not_found")
+	ErrHoldNotActive        = errors.New("hold_not_active")
+	ErrBeneficiaryNotFound  = errors.New("beneficiary_not_found")
+	ErrBeneficiaryExists    = errors.New("beneficiary_exists")
+	ErrCoolingOffLimit      = errors.New("cooling_off_limit")
+	ErrAlertNotFound        = errors.New("alert_not_found")
+	ErrAlertExists          = errors.New("alert_exists")
+	ErrRiskDecisionNotFound = errors.New("risk_decision_not_found")
+	ErrStepUpRequired       = errors.New("step_up_required")
+	ErrRiskBlocked          = errors.New("risk_blocked")
+	ErrPINNotSet            = errors.New("pin_not_set")
+	ErrPINAlreadySet        = errors.New("pin_already_set")
+	ErrInvalidPIN           = errors.New("invalid_pin")
+	ErrPINLocked            = errors.New("pin_locked")
+	ErrPasskeyNotFound      = errors.New("passkey_not_found")
+	ErrPasskeyExists        = errors.New("passkey_exists")
+	ErrPasskeyInvalid       = errors.New("passkey_invalid")
+	ErrChallengeNotFound    = errors.New("challenge_not_found")
+	ErrDeviceNotFound       = errors.New("device_not_found")
+	ErrAssertionReplayed    = errors.New("assertion_replayed")
+	ErrInvalidOTP           = errors.New("invalid_otp")
+	ErrTOTPNotEnrolled      = errors.New("totp_not_enrolled")
+	ErrTOTPAlreadyEnrolled  = errors.New("totp_already_enrolled")
+	ErrTOTPLocked           = errors.New("totp_locked")
+	ErrAccountRestricted    = errors.New("account_restricted")
+	ErrBalanceRemaining     = errors.New("balance_remaining")
+	ErrEventNotFound        = errors.New("event_not_found")
+	ErrWebhookNotFound      = errors.New("webhook_not_found")
+	ErrDeliveryNotFound     = errors.New("delivery_not_found")
+	ErrAPIClientNotFound    = errors.New("api_client_not_found")
+	ErrAPIKeyNotFound       = errors.New("api_key_not_found")
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidScope         = errors.New("invalid_scope")
+	ErrNetworkNotAllowed    = errors.New("network_not_allowed")
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrInvalidRedirectURI   = errors.New("invalid_redirect_uri")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+	ErrTokenReused          = errors.New("token_reused")
+	ErrUnsupportedResponse  = errors.New("unsupported_response_type")
+	ErrConsentNotFound      = errors.New("consent_not_found")
+	ErrGrantNotFound        = errors.New("grant_not_found")
 )
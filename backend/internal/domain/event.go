@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType names a domain event published to downstream consumers.
+type EventType string
+
+const (
+	EventUserSignedUp      EventType = "user.signed_up"
+	EventUserStatusChanged EventType = "user.status_changed"
+)
+
+// EntryEventType is the event published when an entry of kind is posted,
+// for example "transfer.completed".
+func EntryEventType(kind EntryKind) EventType { return EventType(string(kind) + ".completed") }
+
+// Aggregates events are ordered within.
+const (
+	AggregateUser         = "user"
+	AggregateJournalEntry = "journal_entry"
+)
+
+// OutboxStatus is where an event is in the outbox.
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusPublished OutboxStatus = "published"
+)
+
+// OutboxEvent is a domain event stored in the same transaction as the state
+// change it describes and published later by the relay. Seq numbers the
+// events of one aggregate from 1 in commit order; consumers see them in
+// that order and should deduplicate on ID, since delivery is at least once.
+type OutboxEvent struct {
+	ID            string
+	Type          EventType
+	AggregateType string
+	AggregateID   string
+	Seq           int64
+	Payload       json.RawMessage
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	OccurredAt    time.Time
+	PublishedAt   time.Time
+	DeadAt        time.Time
+}
+
+// AggregateKey identifies the event's aggregate across types.
+func (e *OutboxEvent) AggregateKey() string { return e.AggregateType + ":" + e.AggregateID }
+
+func newOutboxEvent(eventType EventType, aggregateType, aggregateID string, payload any, at time.Time) *OutboxEvent {
+	b, _ := json.Marshal(payload)
+	return &OutboxEvent{Type: eventType, AggregateType: aggregateType, AggregateID: aggregateID, Payload: b, Status: OutboxStatusPending, NextAttemptAt: at, OccurredAt: at}
+}
+
+// NewUserSignedUpEvent describes a new user. Contact details are left out;
+// consumers that need them look the user up.
+func NewUserSignedUpEvent(u *User) *OutboxEvent {
+	return newOutboxEvent(EventUserSignedUp, AggregateUser, u.ID, struct {
+		UserID    string     `json:"userId"`
+		Username  string     `json:"username"`
+		Status    UserStatus `json:"status"`
+		CreatedAt time.Time  `json:"createdAt"`
+	}{u.ID, u.UsernameLower, u.Status, u.CreatedAt}, u.CreatedAt)
+}
+
+func NewUserStatusChangedEvent(e *UserStatusEvent) *OutboxEvent {
+	return newOutboxEvent(EventUserStatusChanged, AggregateUser, e.UserID, struct {
+		UserID    string     `json:"userId"`
+		From      UserStatus `json:"from"`
+		To        UserStatus `json:"to"`
+		Reason    string     `json:"reason"`
+		ActorID   string     `json:"actorId"`
+		EventID   string     `json:"statusEventId"`
+		CreatedAt time.Time  `json:"createdAt"`
+	}{e.UserID, e.From, e.To, e.Reason, e.ActorID, e.ID, e.CreatedAt}, e.CreatedAt)
+}
+
+// NewEntryPostedEvent describes a posted entry. A reversal or refund is
+// ordered with the entry it undoes, so consumers never see it first.
+func NewEntryPostedEvent(entry *JournalEntry) *OutboxEvent {
+	type posting struct {
+		AccountID string `json:"accountId"`
+		Amount    int64  `json:"amount"`
+		Currency  string `json:"currency"`
+	}
+	postings := make([]posting, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		postings = append(postings, posting{p.AccountID, p.Amount, p.Currency})
+	}
+	aggregateID := entry.ID
+	if entry.ReversalOf != "" {
+		aggregateID = entry.ReversalOf
+	}
+	return newOutboxEvent(EntryEventType(entry.Kind), AggregateJournalEntry, aggregateID, struct {
+		EntryID    string            `json:"entryId"`
+		Kind       EntryKind         `json:"kind"`
+		Reference  string            `json:"reference,omitempty"`
+		ReversalOf string            `json:"reversalOf,omitempty"`
+		Postings   []posting         `json:"postings"`
+		Metadata   map[string]string `json:"metadata,omitempty"`
+		CreatedAt  time.Time         `json:"createdAt"`
+	}{entry.ID, entry.Kind, entry.Reference, entry.ReversalOf, postings, entry.Metadata, entry.CreatedAt}, entry.CreatedAt)
+}
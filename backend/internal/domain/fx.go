@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+type FXQuoteStatus string
+
+const (
+	FXQuoteStatusOpen     FXQuoteStatus = "open"
+	FXQuoteStatusExecuted FXQuoteStatus = "executed"
+)
+
+// FXQuote locks a customer rate for a short window. SellAmount is the total
+// debited from the sell wallet and includes Fee; BuyAmount is what lands in
+// the buy wallet. Rates are decimal strings to avoid float rounding.
+type FXQuote struct {
+	ID           string
+	UserID       string
+	SellCurrency string
+	BuyCurrency  string
+	SellAmount   int64
+	BuyAmount    int64
+	Fee          int64
+	MidRate      string
+	Rate         string
+	SpreadBps    int
+	Status       FXQuoteStatus
+	EntryID      string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+	ExecutedAt   time.Time
+}
+
+func (q *FXQuote) Expired(now time.Time) bool { return !now.Before(q.ExpiresAt) }
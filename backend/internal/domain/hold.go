@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusVoided   HoldStatus = "voided"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// HoldKind names the flow that placed a hold and decides who may settle it.
+type HoldKind string
+
+const (
+	HoldKindMerchantPayment HoldKind = "merchant_payment"
+	HoldKindWithdrawal      HoldKind = "withdrawal"
+)
+
+// Hold reserves Amount of an account's funds for a later capture. While
+// active it lowers the account's available balance but not its ledger
+// balance; nothing is posted until capture. A capture may take less than
+// Amount, and the remainder is released with it. A zero ExpiresAt means the
+// hold never lapses and stays active until it is captured or voided.
+type Hold struct {
+	ID        string
+	AccountID string
+	OwnerID   string
+	Currency  string
+	Amount    int64
+	Kind      HoldKind
+	Status    HoldStatus
+	// PayeeAccountID receives the captured funds for merchant payments.
+	PayeeAccountID string
+	CapturedAmount int64
+	EntryID        string
+	Metadata       map[string]string
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Expired reports whether the hold has lapsed by at.
+func (h *Hold) Expired(at time.Time) bool {
+	return !h.ExpiresAt.IsZero() && !h.ExpiresAt.After(at)
+}
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+type AccountType string
+
+const (
+	AccountTypeWallet   AccountType = "wallet"
+	AccountTypeMerchant AccountType = "merchant"
+	AccountTypeSystem   AccountType = "system"
+)
+
+// SystemOwnerPrefix namespaces the owner ID of house accounts so they can
+// never collide with a user or merchant ID.
+const SystemOwnerPrefix = "system:"
+
+// House account owners. Each holds one AccountTypeSystem account per
+// currency.
+const (
+	SystemOwnerFXPosition = SystemOwnerPrefix + "fx_position"
+	SystemOwnerRevenue    = SystemOwnerPrefix + "revenue"
+	// SystemOwnerPayoutClearing holds withdrawn funds until the external
+	// rail (M-Pesa, bank) confirms the payout.
+	SystemOwnerPayoutClearing = SystemOwnerPrefix + "payout_clearing"
+	// SystemOwnerSuspense holds funds under dispute.
+	SystemOwnerSuspense = SystemOwnerPrefix + "suspense"
+)
+
+type EntryKind string
+
+const (
+	EntryKindQRPayment EntryKind = "qr_payment"
+	// EntryKindMerchantPayment is the capture of a merchant authorization.
+	EntryKindMerchantPayment EntryKind = "merchant_payment"
+	EntryKindFXConversion    EntryKind = "fx_conversion"
+	EntryKindTransfer        EntryKind = "transfer"
+	EntryKindWithdrawal      EntryKind = "withdrawal"
+	EntryKindReversal        EntryKind = "reversal"
+	EntryKindRefund          EntryKind = "refund"
+	EntryKindDisputeHold     EntryKind = "dispute_hold"
+	EntryKindDisputeRelease  EntryKind = "dispute_release"
+	// EntryKindClosurePayout pays a closing account's balance out to
+	// payout clearing.
+	EntryKindClosurePayout EntryKind = "closure_payout"
+)
+
+// Account is a single-currency ledger account. Balance is in minor units and
+// is only ever changed by posting a JournalEntry. Held is the sum of active
+// holds against the account.
+type Account struct {
+	ID        string
+	OwnerID   string
+	Type      AccountType
+	Currency  string
+	Balance   int64
+	Held      int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Available is the balance that may still be spent or held.
+func (a *Account) Available() int64 { return a.Balance - a.Held }
+
+// AllowsNegative reports whether postings may take the account below zero.
+// Only house accounts may run a negative balance.
+func (a *Account) AllowsNegative() bool { return a.Type == AccountTypeSystem }
+
+// Posting moves Amount minor units into (positive) or out of (negative)
+// AccountID.
+type Posting struct {
+	AccountID string
+	Amount    int64
+	Currency  string
+}
+
+// JournalEntry is an immutable, balanced set of postings. Reference is
+// unique across entries when set and doubles as an idempotency key. Entries
+// are never edited: a mistake is undone by a new entry whose ReversalOf
+// names the original.
+type JournalEntry struct {
+	ID         string
+	Kind       EntryKind
+	Reference  string
+	ReversalOf string
+	Postings   []Posting
+	Metadata   map[string]string
+	CreatedAt  time.Time
+}
+
+// Validate checks the entry has at least two non-zero postings and that they
+// sum to zero in every currency. A sum that overflows int64 is unbalanced,
+// so postings that only balance modulo 2^64 cannot mint money.
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return ErrUnbalancedEntry
+	}
+	sums := map[string]int64{}
+	for _, p := range e.Postings {
+		if p.AccountID == "" || p.Amount == 0 || p.Currency == "" {
+			return ErrUnbalancedEntry
+		}
+		sum := sums[p.Currency]
+		if (p.Amount > 0 && sum > math.MaxInt64-p.Amount) || (p.Amount < 0 && sum < math.MinInt64-p.Amount) {
+			return ErrUnbalancedEntry
+		}
+		sums[p.Currency] = sum + p.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return ErrUnbalancedEntry
+		}
+	}
+	return nil
+}
+
+// AmountFor returns the net amount the entry posts to accountID.
+func (e *JournalEntry) AmountFor(accountID string) int64 {
+	var total int64
+	for _, p := range e.Postings {
+		if p.AccountID == accountID {
+			total += p.Amount
+		}
+	}
+	return total
+}
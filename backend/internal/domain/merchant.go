@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type MerchantStatus string
+
+const (
+	MerchantStatusActive   MerchantStatus = "active"
+	MerchantStatusDisabled MerchantStatus = "disabled"
+)
+
+var (
+	merchantCategoryRegex = regexp.MustCompile(`^[0-9]{4}$`)
+	countryCodeRegex      = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// Merchant is a business profile owned by a User. Payments land in the
+// merchant's AccountTypeMerchant ledger account, whose owner is the merchant
+// ID.
+type Merchant struct {
+	ID           string
+	OwnerID      string
+	Name         string
+	City         string
+	CategoryCode string
+	CountryCode  string
+	Currency     string
+	Status       MerchantStatus
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func NormalizeMerchantName(name string) string { return strings.Join(strings.Fields(name), " ") }
+func NormalizeCountryCode(code string) string  { return strings.ToUpper(strings.TrimSpace(code)) }
+func ValidateMerchantCategory(mcc string) bool { return merchantCategoryRegex.MatchString(mcc) }
+func ValidateCountryCode(code string) bool     { return countryCodeRegex.MatchString(code) }
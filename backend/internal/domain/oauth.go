@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OAuthConsent is a user's standing approval for an app to use Scopes on
+// their behalf. There is one per user and client; approving more scopes
+// widens it and revoking it ends every grant the app holds for the user.
+type OAuthConsent struct {
+	ID        string
+	UserID    string
+	ClientID  string
+	Scopes    []Scope
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	RevokedAt time.Time
+}
+
+func (c *OAuthConsent) Active() bool { return c.RevokedAt.IsZero() }
+
+// Covers reports whether the consent is active and includes every scope.
+func (c *OAuthConsent) Covers(scopes []Scope) bool {
+	if !c.Active() {
+		return false
+	}
+	for _, s := range scopes {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthCode is an authorization code waiting to be exchanged. Only the
+// code's hash is stored. CodeChallenge is the PKCE S256 challenge the
+// exchange must answer; UsedAt is stamped by the first exchange. Nonce and
+// AuthTime are copied into the ID token of an OpenID Connect request;
+// AuthTime is zero when the user's session does not record it.
+type OAuthCode struct {
+	Hash          string
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scopes        []Scope
+	CodeChallenge string
+	Nonce         string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	UsedAt        time.Time
+}
+
+// OAuthGrant is the chain of tokens issued from one authorization code.
+// Access tokens carry its ID so revoking it stops them before they expire.
+// Its refresh token rotates on every use; RefreshHash is the current one
+// and UsedRefreshHashes the recent ones, kept to spot a stolen token being
+// replayed. A grant ends at ExpiresAt however often it is refreshed.
+type OAuthGrant struct {
+	ID                string
+	ClientID          string
+	UserID            string
+	CodeHash          string
+	Scopes            []Scope
+	RefreshHash       string
+	UsedRefreshHashes []string
+	ExpiresAt         time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	RevokedAt         time.Time
+}
+
+// Active reports whether the grant is unrevoked and unexpired at now.
+func (g *OAuthGrant) Active(now time.Time) bool {
+	return g.RevokedAt.IsZero() && now.Before(g.ExpiresAt)
+}
+
+// ValidRedirectURI reports whether raw may be registered as an app's
+// redirect URI: an https URL, an http URL on a loopback host, or a
+// reverse-domain private scheme such as "com.example.app:/callback" for
+// native apps (RFC 8252). Fragments and credentials are never allowed.
+func ValidRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(raw, "#") || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return strings.Contains(u.Scheme, ".") && u.Host == ""
+}
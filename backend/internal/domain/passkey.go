@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// Passkey is a WebAuthn credential registered by UserID. CredentialID is the
+// base64url credential ID; PublicKey is the COSE_Key used to verify
+// assertions and SignCount the last counter the authenticator reported.
+type Passkey struct {
+	ID             string
+	UserID         string
+	CredentialID   string
+	PublicKey      []byte
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         []byte
+	Attestation    string
+	BackupEligible bool
+	Name           string
+	CreatedAt      time.Time
+	LastUsedAt     time.Time
+}
+
+type WebAuthnPurpose string
+
+const (
+	WebAuthnRegistration WebAuthnPurpose = "registration"
+	WebAuthnLogin        WebAuthnPurpose = "login"
+)
+
+// WebAuthnChallenge is an outstanding ceremony challenge. It is consumed by
+// the first attempt to finish the ceremony, successful or not. UserID is
+// empty for usernameless logins.
+type WebAuthnChallenge struct {
+	ID        string
+	UserID    string
+	Purpose   WebAuthnPurpose
+	Challenge []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
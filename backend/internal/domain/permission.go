@@ -0,0 +1,67 @@
+package domain
+
+import "slices"
+
+// Permission names an operator capability. Routes and use cases check
+// permissions rather than roles, so roles can be regrouped without touching
+// either.
+type Permission string
+
+const (
+	// PermUsersRead allows searching users and viewing their profiles.
+	PermUsersRead Permission = "users:read"
+	// PermUsersWrite allows freezing, suspending, closing and reopening
+	// users.
+	PermUsersWrite Permission = "users:write"
+	// PermUsersImpersonate allows obtaining a read-only token to see the
+	// app as a customer sees it.
+	PermUsersImpersonate Permission = "users:impersonate"
+	// PermRolesWrite allows changing a user's role.
+	PermRolesWrite Permission = "roles:write"
+	// PermKYCReview allows approving and rejecting KYC reviews.
+	PermKYCReview Permission = "kyc:review"
+	// PermLedgerRead allows viewing any user's accounts, entries and holds.
+	PermLedgerRead Permission = "ledger:read"
+	// PermPaymentsOperate allows reversing and refunding entries and
+	// settling holds on others' behalf.
+	PermPaymentsOperate Permission = "payments:operate"
+	// PermDisputesManage allows working the dispute queue.
+	PermDisputesManage Permission = "disputes:manage"
+	// PermAMLManage allows working AML alerts and replaying rules.
+	PermAMLManage Permission = "aml:manage"
+	// PermRiskRead allows viewing risk decisions.
+	PermRiskRead Permission = "risk:read"
+	// PermAuditRead allows searching the audit log.
+	PermAuditRead Permission = "audit:read"
+	// PermWebhooksManage allows registering partner webhook endpoints,
+	// rotating their secrets and redelivering events.
+	PermWebhooksManage Permission = "webhooks:manage"
+	// PermAPIClientsManage allows registering API clients and issuing and
+	// revoking their keys.
+	PermAPIClientsManage Permission = "api_clients:manage"
+)
+
+// rolePermissions is the grant table. Customers hold no operator
+// permissions; everything they may do is scoped to their own data.
+var rolePermissions = map[UserRole][]Permission{
+	UserRoleSupport: {
+		PermUsersRead, PermUsersWrite, PermUsersImpersonate, PermLedgerRead,
+		PermPaymentsOperate, PermDisputesManage, PermAMLManage, PermRiskRead,
+	},
+	UserRoleCompliance: {
+		PermUsersRead, PermUsersWrite, PermKYCReview, PermLedgerRead,
+		PermAMLManage, PermRiskRead, PermAuditRead,
+	},
+	UserRoleAdmin: {
+		PermUsersRead, PermUsersWrite, PermUsersImpersonate, PermRolesWrite,
+		PermKYCReview, PermLedgerRead, PermPaymentsOperate,
+		PermDisputesManage, PermAMLManage, PermRiskRead, PermAuditRead,
+		PermWebhooksManage, PermAPIClientsManage,
+	},
+}
+
+// Permissions returns the permissions granted to r.
+func (r UserRole) Permissions() []Permission { return slices.Clone(rolePermissions[r]) }
+
+// Can reports whether r grants p.
+func (r UserRole) Can(p Permission) bool { return slices.Contains(rolePermissions[r], p) }
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+type RiskFactor struct {
+	Signal       string
+	Value        float64
+	Weight       int
+	Contribution float64
+	Detail       string
+}
+
+// RiskDecision records how a payment was scored before authorization and
+// what was decided (allow, step_up or block), with the factors behind it.
+// SteppedUp marks a step_up decision the user satisfied by re-authenticating.
+type RiskDecision struct {
+	ID          string
+	UserID      string
+	Action      string
+	Amount      int64
+	Currency    string
+	DeviceID    string
+	RecipientID string
+	Score       int
+	Decision    string
+	SteppedUp   bool
+	Factors     []RiskFactor
+	CreatedAt   time.Time
+}
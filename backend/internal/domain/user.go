@@ -2,20 +2,159 @@ package domain
 
 import "time"
 
+// UserStatus is where a user is in their lifecycle. New users wait in
+// pending_verification until KYC approval activates them. Frozen users can
+// log in and receive money but cannot send it; suspended users cannot log
+// in; closing accounts can neither send nor receive while their balances
+// are paid out; closed accounts can only be reopened.
 type UserStatus string
 
 const (
-	UserStatusActive   UserStatus = "active"
-	UserStatusDisabled UserStatus = "disabled"
+	UserStatusPendingVerification UserStatus = "pending_verification"
+	UserStatusActive              UserStatus = "active"
+	UserStatusFrozen              UserStatus = "frozen"
+	UserStatusSuspended           UserStatus = "suspended"
+	UserStatusClosing             UserStatus = "closing"
+	UserStatusClosed              UserStatus = "closed"
 )
 
+var userTransitions = map[UserStatus][]UserStatus{
+	UserStatusPendingVerification: {UserStatusActive, UserStatusSuspended, UserStatusClosing},
+	UserStatusActive:              {UserStatusFrozen, UserStatusSuspended, UserStatusClosing},
+	UserStatusFrozen:              {UserStatusActive, UserStatusSuspended, UserStatusClosing},
+	UserStatusSuspended:           {UserStatusActive, UserStatusFrozen, UserStatusClosing},
+	UserStatusClosing:             {UserStatusActive, UserStatusClosed},
+	UserStatusClosed:              {UserStatusActive},
+}
+
+func (s UserStatus) Valid() bool {
+	_, ok := userTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a user may move from s to next. Reopening
+// a closed account makes it active again.
+func (s UserStatus) CanTransition(next UserStatus) bool {
+	for _, allowed := range userTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s UserStatus) CanLogIn() bool {
+	return s == UserStatusPendingVerification || s == UserStatusActive || s == UserStatusFrozen
+}
+
+// CanSend reports whether the user may move money out of their wallets.
+func (s UserStatus) CanSend() bool { return s == UserStatusActive }
+
+// CanReceive reports whether the user may be paid. Frozen users still can,
+// so that incoming payments are not bounced while an account is reviewed.
+func (s UserStatus) CanReceive() bool { return s == UserStatusActive || s == UserStatusFrozen }
+
+// UserRole grants operator capabilities through its permissions. Users
+// without a stored role are customers.
+type UserRole string
+
+const (
+	UserRoleCustomer   UserRole = "customer"
+	UserRoleSupport    UserRole = "support"
+	UserRoleCompliance UserRole = "compliance"
+	UserRoleAdmin      UserRole = "admin"
+)
+
+func (r UserRole) Valid() bool {
+	switch r {
+	case UserRoleCustomer, UserRoleSupport, UserRoleCompliance, UserRoleAdmin:
+		return true
+	}
+	return false
+}
+
+// KYCStatus tracks review of a user's identity. Users start pending and an
+// operator with PermKYCReview approves or rejects them.
+type KYCStatus string
+
+const (
+	KYCStatusPending  KYCStatus = "pending"
+	KYCStatusApproved KYCStatus = "approved"
+	KYCStatusRejected KYCStatus = "rejected"
+)
+
+func (s KYCStatus) Valid() bool {
+	return s == KYCStatusPending || s == KYCStatusApproved || s == KYCStatusRejected
+}
+
+// User is an account holder. PINHash is the bcrypt hash of the transaction
+// PIN, empty until one is set; PINFailures counts consecutive wrong PINs
+// and PINLockedUntil blocks PIN entry after too many. KYCReviewedBy and
+// KYCReviewedAt are set once an operator decides the KYC review.
+// PhoneVerifiedAt is when the user last confirmed a one-time code sent to
+// PhoneE164; it is zero until they have. TOTPSecret is the base32
+// authenticator app secret, empty unless enrolled; it only counts once
+// TOTPConfirmedAt is set. TOTPLastStep is the time step of the last code
+// accepted, so no code works twice, and TOTPFailures and TOTPLockedUntil
+// lock out guessing like their PIN counterparts.
 type User struct {
-	ID            string
-	EmailLower    string
-	PhoneE164     string
-	UsernameLower string
-	PasswordHash  string
-	Status        UserStatus
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	ID              string
+	EmailLower      string
+	PhoneE164       string
+	UsernameLower   string
+	PasswordHash    string
+	PINHash         string
+	PINFailures     int
+	PINLockedUntil  time.Time
+	Status          UserStatus
+	Role            UserRole
+	KYCStatus       KYCStatus
+	KYCNote         string
+	KYCReviewedBy   string
+	KYCReviewedAt   time.Time
+	PhoneVerifiedAt time.Time
+	TOTPSecret      string
+	TOTPConfirmedAt time.Time
+	TOTPLastStep    int64
+	TOTPFailures    int
+	TOTPLockedUntil time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// PhoneVerified reports whether the user has proved they receive messages
+// at PhoneE164.
+func (u *User) PhoneVerified() bool { return !u.PhoneVerifiedAt.IsZero() }
+
+func (u *User) HasPIN() bool { return u.PINHash != "" }
+
+func (u *User) PINLocked(now time.Time) bool { return now.Before(u.PINLockedUntil) }
+
+// HasTOTP reports whether the user finished enrolling an authenticator app.
+func (u *User) HasTOTP() bool { return u.TOTPSecret != "" && !u.TOTPConfirmedAt.IsZero() }
+
+func (u *User) TOTPLocked(now time.Time) bool { return now.Before(u.TOTPLockedUntil) }
+
+// UserFilter narrows an operator's user search. Query matches a user ID, a
+// whole email or phone number, or the start of a username; empty fields
+// match everything.
+type UserFilter struct {
+	Query     string
+	Status    UserStatus
+	Role      UserRole
+	KYCStatus KYCStatus
+	Limit     int
+}
+
+// UserStatusEvent records one lifecycle transition, who made it and why.
+// EntryIDs lists the payouts posted when a funded account is closed.
+type UserStatusEvent struct {
+	ID        string
+	UserID    string
+	From      UserStatus
+	To        UserStatus
+	Reason    string
+	ActorID   string
+	EntryIDs  []string
+	CreatedAt time.Time
 }
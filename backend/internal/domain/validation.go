@@ -10,6 +10,9 @@ var (
 	e164Regex     = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
 	letterRegex   = regexp.MustCompile(`[A-Za-z]`)
 	numberRegex   = regexp.MustCompile(`[0-9]`)
+	bankCodeRegex = regexp.MustCompile(`^[A-Z0-9]{2,11}$`)
+	bankAcctRegex = regexp.MustCompile(`^[0-9]{6,20}$`)
+	pinRegex      = regexp.MustCompile(`^[0-9]{4,6}$`)
 )
 
 type FieldErrors map[string]string
@@ -17,11 +20,38 @@ type FieldErrors map[string]string
 func NormalizeEmail(email string) string       { return strings.ToLower(strings.TrimSpace(email)) }
 func NormalizeUsername(username string) string { return strings.ToLower(strings.TrimSpace(username)) }
 func NormalizePhone(phone string) string       { return strings.TrimSpace(phone) }
-func ValidateUsername(username string) bool    { return usernameRegex.MatchString(username) }
-func ValidatePhoneE164(phone string) bool      { return e164Regex.MatchString(phone) }
+func NormalizeNickname(nickname string) string { return strings.Join(strings.Fields(nickname), " ") }
+func NormalizeBankCode(code string) string     { return strings.ToUpper(strings.TrimSpace(code)) }
+
+// NormalizeBankAccount drops the spaces and dashes people copy from
+// statements.
+func NormalizeBankAccount(account string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(account))
+}
+func ValidateUsername(username string) bool   { return usernameRegex.MatchString(username) }
+func ValidateNickname(nickname string) bool   { return nickname != "" && len(nickname) <= 40 }
+func ValidateBankCode(code string) bool       { return bankCodeRegex.MatchString(code) }
+func ValidateBankAccount(account string) bool { return bankAcctRegex.MatchString(account) }
+func ValidatePhoneE164(phone string) bool     { return e164Regex.MatchString(phone) }
 func ValidatePassword(password string) bool {
 	if len(password) < 8 {
 		return false
 	}
 	return letterRegex.MatchString(password) && numberRegex.MatchString(password)
 }
+
+// ValidatePIN accepts 4-6 digits, rejecting repeated digits and straight
+// runs such as 1234 or 987654.
+func ValidatePIN(pin string) bool {
+	if !pinRegex.MatchString(pin) {
+		return false
+	}
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		d := int(pin[i]) - int(pin[i-1])
+		repeated = repeated && d == 0
+		ascending = ascending && d == 1
+		descending = descending && d == -1
+	}
+	return !repeated && !ascending && !descending
+}
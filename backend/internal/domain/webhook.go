@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type WebhookEndpointStatus string
+
+const (
+	WebhookEndpointActive   WebhookEndpointStatus = "active"
+	WebhookEndpointDisabled WebhookEndpointStatus = "disabled"
+)
+
+// WebhookEventAll subscribes an endpoint to every event type.
+const WebhookEventAll EventType = "*"
+
+// WebhookSecret signs deliveries. A rotated-out secret keeps signing until
+// ExpiresAt so partners can switch without dropping deliveries; the current
+// secret has no expiry.
+type WebhookSecret struct {
+	Secret    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// WebhookEndpoint is a partner URL that receives the event types it
+// subscribes to. ClientID names the API client that owns it.
+type WebhookEndpoint struct {
+	ID         string
+	ClientID   string
+	URL        string
+	EventTypes []EventType
+	Secrets    []WebhookSecret
+	Status     WebhookEndpointStatus
+	CreatedBy  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Subscribes reports whether the endpoint wants events of type t.
+func (e *WebhookEndpoint) Subscribes(t EventType) bool {
+	return slices.Contains(e.EventTypes, t) || slices.Contains(e.EventTypes, WebhookEventAll)
+}
+
+// SigningSecrets returns the secrets still valid at now, newest first.
+func (e *WebhookEndpoint) SigningSecrets(now time.Time) []string {
+	var out []string
+	for i := len(e.Secrets) - 1; i >= 0; i-- {
+		if s := e.Secrets[i]; s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt) {
+			out = append(out, s.Secret)
+		}
+	}
+	return out
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed means every attempt failed; only a manual
+	// redelivery tries again.
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookAttempt is one HTTP call to the endpoint. StatusCode is zero when
+// no response arrived.
+type WebhookAttempt struct {
+	At         time.Time
+	StatusCode int
+	Error      string
+	DurationMs int64
+}
+
+// WebhookDelivery is one event owed to one endpoint. Payload is the signed
+// request body, fixed when the delivery is created.
+type WebhookDelivery struct {
+	ID            string
+	EndpointID    string
+	ClientID      string
+	EventID       string
+	EventType     EventType
+	Payload       []byte
+	Status        WebhookDeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	History       []WebhookAttempt
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// KnownEventTypes lists every event type the outbox can emit.
+func KnownEventTypes() []EventType {
+	out := []EventType{EventUserSignedUp, EventUserStatusChanged}
+	for _, kind := range []EntryKind{EntryKindQRPayment, EntryKindMerchantPayment, EntryKindFXConversion, EntryKindTransfer, EntryKindWithdrawal, EntryKindReversal, EntryKindRefund, EntryKindDisputeHold, EntryKindDisputeRelease, EntryKindClosurePayout} {
+		out = append(out, EntryEventType(kind))
+	}
+	return out
+}
+
+// ValidateWebhookURL accepts absolute https URLs without credentials, and
+// http ones when allowHTTP is set for local development.
+func ValidateWebhookURL(raw string, allowHTTP bool) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	return u.Scheme == "https" || (allowHTTP && u.Scheme == "http")
+}
+
+// NormalizeEventTypes trims and de-duplicates types, keeping their order.
+func NormalizeEventTypes(types []string) []EventType {
+	var out []EventType
+	for _, t := range types {
+		et := EventType(strings.TrimSpace(t))
+		if et != "" && !slices.Contains(out, et) {
+			out = append(out, et)
+		}
+	}
+	return out
+}
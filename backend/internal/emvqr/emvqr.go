@@ -0,0 +1,254 @@
+package emvqr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tag IDs from the EMVCo QR Code Specification for Payment Systems,
+// Merchant-Presented Mode (v1.1).
+const (
+	TagPayloadFormat      = "00"
+	TagPointOfInitiation  = "01"
+	TagMerchantAccount    = "26"
+	TagMerchantCategory   = "52"
+	TagCurrency           = "53"
+	TagAmount             = "54"
+	TagCountryCode        = "58"
+	TagMerchantName       = "59"
+	TagMerchantCity       = "60"
+	TagAdditionalData     = "62"
+	TagCRC                = "63"
+	SubTagGloballyUnique  = "00"
+	SubTagMerchantID      = "01"
+	SubTagReferenceLabel  = "05"
+	PayloadFormatVersion  = "01"
+	InitiationStatic      = "11"
+	InitiationDynamic     = "12"
+	maxFieldValueLength   = 99
+	crcFieldHeader        = TagCRC + "04"
+	maxMerchantNameLength = 25
+	maxMerchantCityLength = 15
+)
+
+var (
+	ErrMalformed   = errors.New("malformed payload")
+	ErrChecksum    = errors.New("crc mismatch")
+	ErrMissingTag  = errors.New("missing mandatory tag")
+	ErrInvalidData = errors.New("invalid field value")
+)
+
+// Payload is the decoded subset of a merchant-presented QR that Akiba issues
+// and accepts. Amount is the decimal string from tag 54 and is empty for
+// static codes.
+type Payload struct {
+	Dynamic      bool
+	GUID         string
+	MerchantID   string
+	Category     string
+	Currency     string
+	Amount       string
+	CountryCode  string
+	MerchantName string
+	MerchantCity string
+	Reference    string
+}
+
+// Encode renders p as an EMVCo TLV string terminated by its CRC field.
+func Encode(p Payload) (string, error) {
+	if p.GUID == "" || p.MerchantID == "" || len(p.Category) != 4 || len(p.Currency) != 3 || len(p.CountryCode) != 2 {
+		return "", ErrInvalidData
+	}
+	if p.MerchantName == "" || p.MerchantCity == "" {
+		return "", ErrInvalidData
+	}
+	if p.Dynamic && p.Amount == "" {
+		return "", ErrInvalidData
+	}
+	var b strings.Builder
+	initiation := InitiationStatic
+	if p.Dynamic {
+		initiation = InitiationDynamic
+	}
+	account, err := tlv(SubTagGloballyUnique, p.GUID)
+	if err != nil {
+		return "", err
+	}
+	merchantID, err := tlv(SubTagMerchantID, p.MerchantID)
+	if err != nil {
+		return "", err
+	}
+	fields := [][2]string{
+		{TagPayloadFormat, PayloadFormatVersion},
+		{TagPointOfInitiation, initiation},
+		{TagMerchantAccount, account + merchantID},
+		{TagMerchantCategory, p.Category},
+		{TagCurrency, p.Currency},
+	}
+	if p.Amount != "" {
+		fields = append(fields, [2]string{TagAmount, p.Amount})
+	}
+	fields = append(fields,
+		[2]string{TagCountryCode, p.CountryCode},
+		[2]string{TagMerchantName, truncate(p.MerchantName, maxMerchantNameLength)},
+		[2]string{TagMerchantCity, truncate(p.MerchantCity, maxMerchantCityLength)},
+	)
+	if p.Reference != "" {
+		ref, err := tlv(SubTagReferenceLabel, p.Reference)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, [2]string{TagAdditionalData, ref})
+	}
+	for _, f := range fields {
+		s, err := tlv(f[0], f[1])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+	b.WriteString(crcFieldHeader)
+	body := b.String()
+	return body + fmt.Sprintf("%04X", CRC16(body)), nil
+}
+
+// Decode validates the CRC and structure of raw and extracts the fields
+// Akiba relies on. Unknown tags are ignored so codes issued by other
+// schemes still parse.
+func Decode(raw string) (*Payload, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < len(crcFieldHeader)+4 {
+		return nil, ErrMalformed
+	}
+	crcStart := len(raw) - 4
+	if raw[crcStart-len(crcFieldHeader):crcStart] != crcFieldHeader {
+		return nil, ErrMalformed
+	}
+	want, err := strconv.ParseUint(raw[crcStart:], 16, 16)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if CRC16(raw[:crcStart]) != uint16(want) {
+		return nil, ErrChecksum
+	}
+	fields, err := parse(raw[:crcStart-len(crcFieldHeader)])
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{TagPayloadFormat, TagMerchantCategory, TagCurrency, TagCountryCode, TagMerchantName, TagMerchantCity} {
+		if _, ok := fields[tag]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingTag, tag)
+		}
+	}
+	if fields[TagPayloadFormat] != PayloadFormatVersion {
+		return nil, ErrInvalidData
+	}
+	p := &Payload{
+		Category:     fields[TagMerchantCategory],
+		Currency:     fields[TagCurrency],
+		Amount:       fields[TagAmount],
+		CountryCode:  fields[TagCountryCode],
+		MerchantName: fields[TagMerchantName],
+		MerchantCity: fields[TagMerchantCity],
+	}
+	switch fields[TagPointOfInitiation] {
+	case "", InitiationStatic:
+	case InitiationDynamic:
+		p.Dynamic = true
+	default:
+		return nil, ErrInvalidData
+	}
+	if err := p.decodeMerchantAccount(fields); err != nil {
+		return nil, err
+	}
+	if add, ok := fields[TagAdditionalData]; ok {
+		sub, err := parse(add)
+		if err != nil {
+			return nil, err
+		}
+		p.Reference = sub[SubTagReferenceLabel]
+	}
+	return p, nil
+}
+
+// decodeMerchantAccount picks the first merchant account template (tags
+// 26-51) that carries both a GUID and a merchant ID.
+func (p *Payload) decodeMerchantAccount(fields map[string]string) error {
+	tags := make([]string, 0, len(fields))
+	for tag := range fields {
+		if tag >= "26" && tag <= "51" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		sub, err := parse(fields[tag])
+		if err != nil {
+			return err
+		}
+		if sub[SubTagGloballyUnique] != "" && sub[SubTagMerchantID] != "" {
+			p.GUID = sub[SubTagGloballyUnique]
+			p.MerchantID = sub[SubTagMerchantID]
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: merchant account information", ErrMissingTag)
+}
+
+// CRC16 is CRC-16/CCITT-FALSE (poly 0x1021, init 0xFFFF) as required by the
+// spec, computed over everything up to and including "6304".
+func CRC16(s string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func parse(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for i := 0; i < len(s); {
+		if i+4 > len(s) {
+			return nil, ErrMalformed
+		}
+		tag := s[i : i+2]
+		n, err := strconv.Atoi(s[i+2 : i+4])
+		if err != nil || n <= 0 || i+4+n > len(s) {
+			return nil, ErrMalformed
+		}
+		if _, dup := out[tag]; dup {
+			return nil, ErrMalformed
+		}
+		out[tag] = s[i+4 : i+4+n]
+		i += 4 + n
+	}
+	return out, nil
+}
+
+func tlv(tag, value string) (string, error) {
+	if value == "" || len(value) > maxFieldValueLength {
+		return "", ErrInvalidData
+	}
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value), nil
+}
+
+// truncate keeps the first n characters of s, never splitting a rune.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package emvqr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCRC16MatchesCCITTFalseCheckValue(t *testing.T) {
+	if got := CRC16("123456789"); got != 0x29B1 {
+		t.Fatalf("expected 0x29B1, got %#04x", got)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	in := Payload{Dynamic: true, GUID: "ke.akiba", MerchantID: "m1", Category: "5411", Currency: "404", Amount: "150.00", CountryCode: "KE", MerchantName: "Mama Mboga", MerchantCity: "Nairobi", Reference: "INV-42"}
+	raw, err := Encode(in)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !strings.HasPrefix(raw, "000201010212") {
+		t.Fatalf("unexpected header: %s", raw)
+	}
+	out, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if *out != in {
+		t.Fatalf("round trip mismatch: %#v", out)
+	}
+}
+
+func TestDecodeRejectsTamperedPayload(t *testing.T) {
+	raw, err := Encode(Payload{GUID: "ke.akiba", MerchantID: "m1", Category: "5411", Currency: "404", CountryCode: "KE", MerchantName: "Shop", MerchantCity: "Nairobi"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	tampered := strings.Replace(raw, "Shop", "Shap", 1)
+	if _, err := Decode(tampered); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+}
+
+func TestDecodeRejectsMissingMerchantAccount(t *testing.T) {
+	body := "000201010211520454115303404" + "5802KE5904Shop6007Nairobi" + crcFieldHeader
+	raw := body + fmt.Sprintf("%04X", CRC16(body))
+	if _, err := Decode(raw); !errors.Is(err, ErrMissingTag) {
+		t.Fatalf("expected missing tag error, got %v", err)
+	}
+}
+
+func TestEncodeTruncatesNamesByCharacter(t *testing.T) {
+	name := strings.Repeat("é", 30)
+	raw, err := Encode(Payload{GUID: "ke.akiba", MerchantID: "m1", Category: "5411", Currency: "404", CountryCode: "KE", MerchantName: name, MerchantCity: "Nairobi"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.MerchantName != strings.Repeat("é", 25) {
+		t.Fatalf("expected 25 whole characters, got %q", out.MerchantName)
+	}
+}
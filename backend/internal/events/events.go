@@ -0,0 +1,149 @@
+// Package events delivers outbox events to downstream consumers: in memory,
+// to a message broker such as NATS or Kafka, or to an HTTP endpoint.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+// EventPublisher hands one event to its transport. A nil error means the
+// transport has accepted it; the relay retries anything else.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *domain.OutboxEvent) error
+}
+
+// Envelope is the wire form of an event for every transport.
+type Envelope struct {
+	ID            string           `json:"id"`
+	Type          domain.EventType `json:"type"`
+	AggregateType string           `json:"aggregateType"`
+	AggregateID   string           `json:"aggregateId"`
+	Seq           int64            `json:"seq"`
+	OccurredAt    time.Time        `json:"occurredAt"`
+	Data          json.RawMessage  `json:"data"`
+}
+
+func NewEnvelope(e *domain.OutboxEvent) Envelope {
+	return Envelope{ID: e.ID, Type: e.Type, AggregateType: e.AggregateType, AggregateID: e.AggregateID, Seq: e.Seq, OccurredAt: e.OccurredAt, Data: e.Payload}
+}
+
+// MemoryPublisher keeps published envelopes in order, for tests and local
+// development.
+type MemoryPublisher struct {
+	mu        sync.Mutex
+	envelopes []Envelope
+}
+
+func NewMemoryPublisher() *MemoryPublisher { return &MemoryPublisher{} }
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.envelopes = append(p.envelopes, NewEnvelope(event))
+	return nil
+}
+
+// Envelopes returns a copy of everything published so far.
+func (p *MemoryPublisher) Envelopes() []Envelope {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Envelope(nil), p.envelopes...)
+}
+
+// LogPublisher writes events to the log instead of delivering them, for
+// development.
+type LogPublisher struct{ logger *slog.Logger }
+
+func NewLogPublisher(logger *slog.Logger) *LogPublisher { return &LogPublisher{logger: logger} }
+
+func (p *LogPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	p.logger.InfoContext(ctx, "event_published", "event_id", event.ID, "type", string(event.Type), "aggregate", event.AggregateKey(), "seq", event.Seq)
+	return nil
+}
+
+// MultiPublisher hands each event to every publisher in turn and fails if
+// any of them does, so the relay retries all of them. Publishers after the
+// first may therefore see an event more than once.
+type MultiPublisher []EventPublisher
+
+func (m MultiPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Producer is the small slice of a broker client the relay needs. NATS
+// JetStream and Kafka clients both fit it with a few lines of adapter.
+type Producer interface {
+	// Produce writes value to topic. Messages with the same key must land
+	// in the same partition or stream so they stay in order.
+	Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
+}
+
+// BrokerPublisher sends each event to TopicPrefix + type, keyed by its
+// aggregate so the broker preserves per-aggregate order.
+type BrokerPublisher struct {
+	producer    Producer
+	topicPrefix string
+}
+
+func NewBrokerPublisher(producer Producer, topicPrefix string) *BrokerPublisher {
+	return &BrokerPublisher{producer: producer, topicPrefix: topicPrefix}
+}
+
+func (p *BrokerPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	body, err := json.Marshal(NewEnvelope(event))
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"event-id": event.ID, "event-type": string(event.Type)}
+	return p.producer.Produce(ctx, p.topicPrefix+string(event.Type), []byte(event.AggregateKey()), body, headers)
+}
+
+// HTTPPublisher POSTs each envelope to a fixed URL and treats any 2xx as
+// accepted. It is meant for internal consumers on a trusted network.
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, timeout time.Duration) *HTTPPublisher {
+	return &HTTPPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	body, err := json.Marshal(NewEnvelope(event))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Type", string(event.Type))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("event endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+func testEvent() *domain.OutboxEvent {
+	e := domain.NewUserSignedUpEvent(&domain.User{ID: "u1", UsernameLower: "alice", Status: domain.UserStatusPendingVerification, CreatedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)})
+	e.ID, e.Seq = "evt1", 1
+	return e
+}
+
+func TestHTTPPublisherPostsEnvelope(t *testing.T) {
+	var got Envelope
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Event-ID") != "evt1" || r.Header.Get("X-Event-Type") != "user.signed_up" {
+			t.Errorf("missing event headers: %v", r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := NewHTTPPublisher(srv.URL, time.Second)
+	if err := p.Publish(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	var data struct{ UserID, Username string }
+	_ = json.Unmarshal(got.Data, &data)
+	if got.ID != "evt1" || got.AggregateID != "u1" || got.Seq != 1 || data.Username != "alice" {
+		t.Fatalf("unexpected envelope %#v %s", got, got.Data)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := p.Publish(context.Background(), testEvent()); err == nil {
+		t.Fatal("expected non-2xx to fail")
+	}
+}
+
+type recordingProducer struct{ topic, key string }
+
+func (p *recordingProducer) Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	p.topic, p.key = topic, string(key)
+	return nil
+}
+
+func TestBrokerPublisherKeysByAggregate(t *testing.T) {
+	producer := &recordingProducer{}
+	if err := NewBrokerPublisher(producer, "akiba.").Publish(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if producer.topic != "akiba.user.signed_up" || producer.key != "user:u1" {
+		t.Fatalf("unexpected topic %q key %q", producer.topic, producer.key)
+	}
+}
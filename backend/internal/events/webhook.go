@@ -0,0 +1,115 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries "t=<unix seconds>,v1=<hex>[,v1=<hex>...]", one v1
+// per valid secret, so partners can verify during a secret rotation.
+const SignatureHeader = "Akiba-Signature"
+
+var (
+	ErrSignatureInvalid = errors.New("webhook signature invalid")
+	ErrSignatureExpired = errors.New("webhook signature timestamp outside tolerance")
+)
+
+// Sign is the hex HMAC-SHA256 of "<unix seconds>.<body>" under secret.
+// Including the timestamp stops a captured request being replayed later.
+func Sign(secret string, at time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(at.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureValue builds the SignatureHeader value for body at at.
+func SignatureValue(secrets []string, at time.Time, body []byte) string {
+	parts := []string{"t=" + strconv.FormatInt(at.Unix(), 10)}
+	for _, s := range secrets {
+		parts = append(parts, "v1="+Sign(s, at, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// VerifySignature checks a SignatureHeader value against secret, the way a
+// receiver should: any v1 must match, and the timestamp must be within
+// tolerance of now.
+func VerifySignature(header, secret string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts int64
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ErrSignatureInvalid
+			}
+			ts = n
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts == 0 || len(sigs) == 0 {
+		return ErrSignatureInvalid
+	}
+	at := time.Unix(ts, 0)
+	if d := now.Sub(at); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+	want := Sign(secret, at, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrSignatureInvalid
+}
+
+// WebhookResult is what one webhook call returned.
+type WebhookResult struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// WebhookSender POSTs signed webhook bodies. Redirects are not followed, so
+// a delivery only counts if the registered URL itself answers 2xx.
+type WebhookSender struct{ client *http.Client }
+
+func NewWebhookSender(timeout time.Duration) *WebhookSender {
+	return &WebhookSender{client: &http.Client{Timeout: timeout, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}}
+}
+
+// Send signs body with secrets at at and POSTs it to url with headers. A
+// non-2xx response is returned in the result with a nil error.
+func (s *WebhookSender) Send(ctx context.Context, url string, secrets []string, headers map[string]string, body []byte, at time.Time) (WebhookResult, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return WebhookResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Akiba-Webhooks/1.0")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(SignatureHeader, SignatureValue(secrets, at, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return WebhookResult{Duration: time.Since(start)}, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return WebhookResult{StatusCode: resp.StatusCode, Duration: time.Since(start)}, nil
+}
@@ -0,0 +1,31 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt1"}`)
+	header := SignatureValue([]string{"whsec_new", "whsec_old"}, at, body)
+
+	for _, secret := range []string{"whsec_new", "whsec_old"} {
+		if err := VerifySignature(header, secret, body, at.Add(time.Minute), 5*time.Minute); err != nil {
+			t.Fatalf("expected %s to verify, got %v", secret, err)
+		}
+	}
+	if err := VerifySignature(header, "whsec_other", body, at, 5*time.Minute); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("expected wrong secret rejected, got %v", err)
+	}
+	if err := VerifySignature(header, "whsec_new", []byte(`{"id":"evt2"}`), at, 5*time.Minute); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("expected altered body rejected, got %v", err)
+	}
+	if err := VerifySignature(header, "whsec_new", body, at.Add(6*time.Minute), 5*time.Minute); !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("expected replayed delivery rejected, got %v", err)
+	}
+	if err := VerifySignature("v1=abc", "whsec_new", body, at, 5*time.Minute); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("expected header without timestamp rejected, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package fees
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const bpsScale = 10000
+
+type TransactionType string
+
+const (
+	TypeTransfer   TransactionType = "transfer"
+	TypeWithdrawal TransactionType = "withdrawal"
+	TypeConversion TransactionType = "conversion"
+)
+
+var ErrInvalidSchedule = errors.New("invalid fee schedule")
+
+// Band is one tier of a tiered rule. It applies to amounts up to and
+// including UpTo; a zero UpTo is the open-ended top band.
+type Band struct {
+	UpTo       int64 `json:"upTo"`
+	Flat       int64 `json:"flat"`
+	PercentBps int   `json:"percentBps"`
+}
+
+// Rule prices one transaction type in one currency. When Bands is set the
+// matching band replaces Flat and PercentBps. Min and Max are the floor and
+// cap applied afterwards; zero disables either.
+type Rule struct {
+	Type       TransactionType `json:"type"`
+	Currency   string          `json:"currency"`
+	Flat       int64           `json:"flat"`
+	PercentBps int             `json:"percentBps"`
+	Bands      []Band          `json:"bands,omitempty"`
+	Min        int64           `json:"min"`
+	Max        int64           `json:"max"`
+}
+
+// Promo waives DiscountBps of the fee (10000 waives it entirely) for the
+// listed types while now is in [ValidFrom, ValidUntil).
+type Promo struct {
+	Code        string            `json:"code"`
+	Types       []TransactionType `json:"types"`
+	DiscountBps int               `json:"discountBps"`
+	ValidFrom   time.Time         `json:"validFrom"`
+	ValidUntil  time.Time         `json:"validUntil"`
+}
+
+type Schedule struct {
+	Rules  []Rule  `json:"rules"`
+	Promos []Promo `json:"promos,omitempty"`
+}
+
+// Quote is the evaluated charge for one transaction. Fee is what the user
+// pays: Gross minus Waived.
+type Quote struct {
+	Gross  int64
+	Waived int64
+	Fee    int64
+	Promo  string
+}
+
+// Evaluate prices amount minor units of txType in currency. Unknown or
+// expired promo codes are ignored rather than rejected so a stale code never
+// blocks a payment; the returned Quote.Promo is empty in that case.
+func (s *Schedule) Evaluate(txType TransactionType, currency string, amount int64, promoCode string, now time.Time) Quote {
+	rule, ok := s.rule(txType, currency)
+	if !ok || amount <= 0 {
+		return Quote{}
+	}
+	flat, bps := rule.Flat, rule.PercentBps
+	if len(rule.Bands) > 0 {
+		band := rule.Bands[len(rule.Bands)-1]
+		for _, b := range rule.Bands {
+			if b.UpTo == 0 || amount <= b.UpTo {
+				band = b
+				break
+			}
+		}
+		flat, bps = band.Flat, band.PercentBps
+	}
+	gross := addCapped(flat, percentCeil(amount, bps))
+	if rule.Min > 0 && gross < rule.Min {
+		gross = rule.Min
+	}
+	if rule.Max > 0 && gross > rule.Max {
+		gross = rule.Max
+	}
+	q := Quote{Gross: gross, Fee: gross}
+	if promo, ok := s.promo(promoCode, txType, now); ok && gross > 0 {
+		q.Promo = promo.Code
+		q.Waived = gross/bpsScale*int64(promo.DiscountBps) + gross%bpsScale*int64(promo.DiscountBps)/bpsScale
+		q.Fee = gross - q.Waived
+	}
+	return q
+}
+
+func (s *Schedule) rule(txType TransactionType, currency string) (Rule, bool) {
+	for _, r := range s.Rules {
+		if r.Type == txType && r.Currency == currency {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (s *Schedule) promo(code string, txType TransactionType, now time.Time) (Promo, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return Promo{}, false
+	}
+	for _, p := range s.Promos {
+		if strings.ToUpper(p.Code) != code {
+			continue
+		}
+		if (!p.ValidFrom.IsZero() && now.Before(p.ValidFrom)) || (!p.ValidUntil.IsZero() && !now.Before(p.ValidUntil)) {
+			return Promo{}, false
+		}
+		for _, t := range p.Types {
+			if t == txType {
+				return p, true
+			}
+		}
+	}
+	return Promo{}, false
+}
+
+// Validate rejects schedules that would produce negative or unbounded fees.
+func (s *Schedule) Validate() error {
+	seen := map[string]bool{}
+	for _, r := range s.Rules {
+		key := string(r.Type) + "/" + r.Currency
+		if r.Type == "" || r.Currency == "" || seen[key] {
+			return fmt.Errorf("%w: rule %s missing or duplicated", ErrInvalidSchedule, key)
+		}
+		seen[key] = true
+		if r.Flat < 0 || r.PercentBps < 0 || r.PercentBps > bpsScale || r.Min < 0 || r.Max < 0 || (r.Max > 0 && r.Min > r.Max) {
+			return fmt.Errorf("%w: rule %s has invalid amounts", ErrInvalidSchedule, key)
+		}
+		var prev int64
+		for i, b := range r.Bands {
+			if b.Flat < 0 || b.PercentBps < 0 || b.PercentBps > bpsScale {
+				return fmt.Errorf("%w: rule %s band %d has invalid amounts", ErrInvalidSchedule, key, i)
+			}
+			last := i == len(r.Bands)-1
+			if (b.UpTo == 0 && !last) || (b.UpTo != 0 && b.UpTo <= prev) {
+				return fmt.Errorf("%w: rule %s bands must ascend with only the last open-ended", ErrInvalidSchedule, key)
+			}
+			prev = b.UpTo
+		}
+	}
+	for _, p := range s.Promos {
+		if strings.TrimSpace(p.Code) == "" || p.DiscountBps <= 0 || p.DiscountBps > bpsScale || len(p.Types) == 0 {
+			return fmt.Errorf("%w: promo %q is invalid", ErrInvalidSchedule, p.Code)
+		}
+	}
+	return nil
+}
+
+// DefaultSchedule mirrors typical East African mobile-money pricing and is
+// used when no schedule file is configured.
+func DefaultSchedule() *Schedule {
+	return &Schedule{Rules: []Rule{
+		{Type: TypeTransfer, Currency: "KES", Bands: []Band{{UpTo: 10000}, {UpTo: 50000, Flat: 700}, {UpTo: 100000, Flat: 1300}, {Flat: 2300}}},
+		{Type: TypeWithdrawal, Currency: "KES", Flat: 2900, PercentBps: 50, Max: 30900},
+		{Type: TypeConversion, Currency: "KES", PercentBps: 25},
+		{Type: TypeConversion, Currency: "UGX", PercentBps: 25},
+		{Type: TypeConversion, Currency: "TZS", PercentBps: 25},
+		{Type: TypeConversion, Currency: "USD", PercentBps: 25, Min: 10},
+	}}
+}
+
+// LoadFile reads a JSON-encoded Schedule and validates it.
+func LoadFile(path string) (*Schedule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Schedule
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func ceilDiv(a, b int64) int64 {
+	if a <= 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
+
+// percentCeil is bps basis points of amount, rounded up. It splits amount
+// at bpsScale so no intermediate product overflows; bps is at most
+// bpsScale, so neither does the result.
+func percentCeil(amount int64, bps int) int64 {
+	return amount/bpsScale*int64(bps) + ceilDiv(amount%bpsScale*int64(bps), bpsScale)
+}
+
+// addCapped adds two non-negative amounts, saturating at MaxInt64 so a
+// huge flat fee cannot wrap negative.
+func addCapped(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
@@ -0,0 +1,72 @@
+package fees
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEvaluateTieredBandsAndCap(t *testing.T) {
+	s := &Schedule{Rules: []Rule{
+		{Type: TypeTransfer, Currency: "KES", Bands: []Band{{UpTo: 10000}, {UpTo: 50000, Flat: 700}, {PercentBps: 100}}, Max: 5000},
+	}}
+	now := time.Now()
+	cases := []struct {
+		amount int64
+		want   int64
+	}{
+		{10000, 0},
+		{10001, 700},
+		{50000, 700},
+		{200000, 2000},
+		{1000000, 5000},
+	}
+	for _, c := range cases {
+		if got := s.Evaluate(TypeTransfer, "KES", c.amount, "", now).Fee; got != c.want {
+			t.Fatalf("amount %d: expected fee %d, got %d", c.amount, c.want, got)
+		}
+	}
+}
+
+func TestEvaluateFloorAndPromoWaiver(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	s := &Schedule{
+		Rules:  []Rule{{Type: TypeWithdrawal, Currency: "KES", PercentBps: 10, Min: 500}},
+		Promos: []Promo{{Code: "JANFREE", Types: []TransactionType{TypeWithdrawal}, DiscountBps: 10000, ValidUntil: now.Add(time.Hour)}},
+	}
+	if q := s.Evaluate(TypeWithdrawal, "KES", 1000, "", now); q.Fee != 500 {
+		t.Fatalf("expected floor of 500, got %#v", q)
+	}
+	q := s.Evaluate(TypeWithdrawal, "KES", 1000, "janfree", now)
+	if q.Fee != 0 || q.Waived != 500 || q.Promo != "JANFREE" {
+		t.Fatalf("expected full waiver, got %#v", q)
+	}
+	if q := s.Evaluate(TypeWithdrawal, "KES", 1000, "JANFREE", now.Add(2*time.Hour)); q.Fee != 500 || q.Promo != "" {
+		t.Fatalf("expected expired promo to be ignored, got %#v", q)
+	}
+}
+
+func TestValidateRejectsUnorderedBands(t *testing.T) {
+	s := &Schedule{Rules: []Rule{{Type: TypeTransfer, Currency: "KES", Bands: []Band{{UpTo: 500}, {}, {UpTo: 1000}}}}}
+	if err := s.Validate(); !errors.Is(err, ErrInvalidSchedule) {
+		t.Fatalf("expected invalid schedule, got %v", err)
+	}
+	if err := DefaultSchedule().Validate(); err != nil {
+		t.Fatalf("default schedule must be valid: %v", err)
+	}
+}
+
+func TestEvaluateDoesNotOverflow(t *testing.T) {
+	s := &Schedule{Rules: []Rule{
+		{Type: TypeTransfer, Currency: "KES", PercentBps: 100},
+		{Type: TypeWithdrawal, Currency: "KES", Flat: math.MaxInt64, PercentBps: 10000},
+	}}
+	now := time.Now()
+	if q := s.Evaluate(TypeTransfer, "KES", math.MaxInt64, "", now); q.Fee != math.MaxInt64/100+1 {
+		t.Fatalf("expected 1%% of MaxInt64 rounded up, got %#v", q)
+	}
+	if q := s.Evaluate(TypeWithdrawal, "KES", math.MaxInt64, "", now); q.Fee != math.MaxInt64 {
+		t.Fatalf("expected the fee to saturate, got %#v", q)
+	}
+}
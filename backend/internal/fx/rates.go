@@ -0,0 +1,77 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+var ErrUnsupportedPair = errors.New("unsupported currency pair")
+
+// RateProvider returns the mid-market rate for base/quote: how many units of
+// quote one unit of base buys.
+type RateProvider interface {
+	MidRate(ctx context.Context, base, quote string) (*big.Rat, error)
+}
+
+// DefaultRates are indicative units-per-USD used when no rates file is
+// configured, so the service can quote offline.
+func DefaultRates() map[string]string {
+	return map[string]string{"USD": "1", "KES": "129.25", "UGX": "3720", "TZS": "2650"}
+}
+
+// StaticProvider derives every cross rate from a fixed units-per-USD table.
+type StaticProvider struct {
+	perUSD map[string]*big.Rat
+}
+
+func NewStaticProvider(perUSD map[string]string) (*StaticProvider, error) {
+	p := &StaticProvider{perUSD: map[string]*big.Rat{}}
+	for code, v := range perUSD {
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(v))
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s: %q", code, v)
+		}
+		p.perUSD[strings.ToUpper(code)] = r
+	}
+	if _, ok := p.perUSD["USD"]; !ok {
+		p.perUSD["USD"] = big.NewRat(1, 1)
+	}
+	return p, nil
+}
+
+// rateFile is the on-disk format read by NewFileProvider.
+//
+//	{"rates": {"KES": "129.25", "UGX": "3720"}}
+type rateFile struct {
+	Rates map[string]string `json:"rates"`
+}
+
+// NewFileProvider loads a units-per-USD table from a JSON file.
+func NewFileProvider(path string) (*StaticProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f rateFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(f.Rates) == 0 {
+		return nil, fmt.Errorf("%s has no rates", path)
+	}
+	return NewStaticProvider(f.Rates)
+}
+
+func (p *StaticProvider) MidRate(ctx context.Context, base, quote string) (*big.Rat, error) {
+	b, okB := p.perUSD[base]
+	q, okQ := p.perUSD[quote]
+	if !okB || !okQ || base == quote {
+		return nil, ErrUnsupportedPair
+	}
+	return new(big.Rat).Quo(q, b), nil
+}
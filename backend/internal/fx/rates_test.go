@@ -0,0 +1,36 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticProviderCrossRate(t *testing.T) {
+	p, err := NewStaticProvider(map[string]string{"KES": "130", "UGX": "3900"})
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	r, err := p.MidRate(context.Background(), "KES", "UGX")
+	if err != nil {
+		t.Fatalf("mid rate: %v", err)
+	}
+	if r.RatString() != "30" {
+		t.Fatalf("expected 30 UGX per KES, got %s", r.RatString())
+	}
+	if _, err := p.MidRate(context.Background(), "KES", "EUR"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Fatalf("expected unsupported pair, got %v", err)
+	}
+}
+
+func TestFileProviderRejectsBadRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"rates":{"KES":"-1"}}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := NewFileProvider(path); err == nil {
+		t.Fatalf("expected invalid rate error")
+	}
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type amlTables struct {
+	alerts        []*domain.AMLAlert
+	cursorAt      time.Time
+	cursorEntryID string
+}
+
+func cloneAlert(a *domain.AMLAlert) *domain.AMLAlert {
+	c := *a
+	c.EntryIDs = slices.Clone(a.EntryIDs)
+	return &c
+}
+
+// AMLRepository allows one unresolved alert per rule and subject.
+type AMLRepository struct {
+	s *Store
+}
+
+func NewAMLRepository(s *Store) *AMLRepository {
+	return &AMLRepository{s: s}
+}
+
+func (r *AMLRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *AMLRepository) CreateAlert(ctx context.Context, alert *domain.AMLAlert) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if !alert.Status.Closed() && r.open(alert.RuleID, alert.SubjectKey) != nil {
+		return domain.ErrAlertExists
+	}
+	created := cloneAlert(alert)
+	created.ID = r.s.newID()
+	r.s.aml.alerts = append(r.s.aml.alerts, created)
+	alert.ID = created.ID
+	return nil
+}
+
+func (r *AMLRepository) GetAlert(ctx context.Context, id string) (*domain.AMLAlert, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.found(r.alert(id))
+}
+
+func (r *AMLRepository) GetOpenAlert(ctx context.Context, ruleID, subjectKey string) (*domain.AMLAlert, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.found(r.open(ruleID, subjectKey))
+}
+
+func (r *AMLRepository) found(a *domain.AMLAlert) (*domain.AMLAlert, error) {
+	if a == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	return cloneAlert(a), nil
+}
+
+func (r *AMLRepository) alert(id string) *domain.AMLAlert {
+	return find(r.s.aml.alerts, func(a *domain.AMLAlert) bool { return a.ID == id })
+}
+
+func (r *AMLRepository) open(ruleID, subjectKey string) *domain.AMLAlert {
+	return find(r.s.aml.alerts, func(a *domain.AMLAlert) bool {
+		return a.RuleID == ruleID && a.SubjectKey == subjectKey && !a.Status.Closed()
+	})
+}
+
+func (r *AMLRepository) ListAlerts(ctx context.Context, status domain.AlertStatus) ([]*domain.AMLAlert, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	alerts := filter(r.s.aml.alerts, func(a *domain.AMLAlert) bool { return status == "" || a.Status == status })
+	slices.SortStableFunc(alerts, func(a, b *domain.AMLAlert) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(alerts, 0, cloneAlert), nil
+}
+
+func (r *AMLRepository) AddAlertEntries(ctx context.Context, id string, entryIDs []string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	a := r.alert(id)
+	if a == nil {
+		return domain.ErrAlertNotFound
+	}
+	for _, entryID := range entryIDs {
+		if !slices.Contains(a.EntryIDs, entryID) {
+			a.EntryIDs = append(a.EntryIDs, entryID)
+		}
+	}
+	a.UpdatedAt = at
+	return nil
+}
+
+func (r *AMLRepository) TransitionAlert(ctx context.Context, alert *domain.AMLAlert, from domain.AlertStatus) error {
+	if !objectIDPattern.MatchString(alert.ID) {
+		return domain.ErrAlertNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	a := r.alert(alert.ID)
+	if a == nil || a.Status != from {
+		return domain.ErrInvalidTransition
+	}
+	a.Status, a.AssignedTo, a.ResolutionNote, a.ResolvedBy, a.ResolvedAt, a.UpdatedAt = alert.Status, alert.AssignedTo, alert.ResolutionNote, alert.ResolvedBy, alert.ResolvedAt, alert.UpdatedAt
+	return nil
+}
+
+func (r *AMLRepository) GetCursor(ctx context.Context) (time.Time, string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.aml.cursorAt, r.s.aml.cursorEntryID, nil
+}
+
+func (r *AMLRepository) SaveCursor(ctx context.Context, at time.Time, entryID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.aml.cursorAt, r.s.aml.cursorEntryID = at, entryID
+	return nil
+}
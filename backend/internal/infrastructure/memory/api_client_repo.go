@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type apiClientTables struct {
+	clients []*domain.APIClient
+}
+
+func cloneAPIClient(c *domain.APIClient) *domain.APIClient {
+	out := *c
+	out.Scopes = slices.Clone(c.Scopes)
+	out.AllowedNetworks = slices.Clone(c.AllowedNetworks)
+	out.RedirectURIs = slices.Clone(c.RedirectURIs)
+	out.Keys = slices.Clone(c.Keys)
+	return &out
+}
+
+// APIClientRepository keeps key prefixes unique across every client.
+type APIClientRepository struct {
+	s *Store
+}
+
+func NewAPIClientRepository(s *Store) *APIClientRepository {
+	return &APIClientRepository{s: s}
+}
+
+func (r *APIClientRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *APIClientRepository) Create(ctx context.Context, client *domain.APIClient) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, k := range client.Keys {
+		if r.keyOwner(k.Prefix) != nil || slices.ContainsFunc(client.Keys[:i], func(o domain.APIKey) bool { return o.Prefix == k.Prefix }) {
+			return domain.ErrDuplicateEntry
+		}
+	}
+	created := cloneAPIClient(client)
+	created.ID = r.s.newID()
+	r.s.apiClients.clients = append(r.s.apiClients.clients, created)
+	client.ID = created.ID
+	return nil
+}
+
+func (r *APIClientRepository) GetByID(ctx context.Context, id string) (*domain.APIClient, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.found(r.client(id))
+}
+
+func (r *APIClientRepository) GetByKeyPrefix(ctx context.Context, prefix string) (*domain.APIClient, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.found(r.keyOwner(prefix))
+}
+
+func (r *APIClientRepository) found(c *domain.APIClient) (*domain.APIClient, error) {
+	if c == nil {
+		return nil, domain.ErrAPIClientNotFound
+	}
+	return cloneAPIClient(c), nil
+}
+
+func (r *APIClientRepository) client(id string) *domain.APIClient {
+	return find(r.s.apiClients.clients, func(c *domain.APIClient) bool { return c.ID == id })
+}
+
+func (r *APIClientRepository) keyOwner(prefix string) *domain.APIClient {
+	return find(r.s.apiClients.clients, func(c *domain.APIClient) bool {
+		return slices.ContainsFunc(c.Keys, func(k domain.APIKey) bool { return k.Prefix == prefix })
+	})
+}
+
+func (r *APIClientRepository) List(ctx context.Context) ([]*domain.APIClient, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return head(newestFirst(r.s.apiClients.clients), 0, cloneAPIClient), nil
+}
+
+func (r *APIClientRepository) AddKey(ctx context.Context, id string, key domain.APIKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := r.client(id)
+	if c == nil {
+		return domain.ErrAPIClientNotFound
+	}
+	if r.keyOwner(key.Prefix) != nil {
+		return domain.ErrDuplicateEntry
+	}
+	c.Keys = append(c.Keys, key)
+	c.UpdatedAt = key.CreatedAt
+	return nil
+}
+
+func (r *APIClientRepository) RevokeKey(ctx context.Context, id, prefix string, at time.Time) error {
+	return r.updateKey(id, prefix, func(c *domain.APIClient, k *domain.APIKey) bool {
+		if !k.RevokedAt.IsZero() {
+			return false
+		}
+		k.RevokedAt, c.UpdatedAt = at, at
+		return true
+	})
+}
+
+func (r *APIClientRepository) TouchKey(ctx context.Context, id, prefix string, at time.Time) error {
+	return r.updateKey(id, prefix, func(c *domain.APIClient, k *domain.APIKey) bool {
+		k.LastUsedAt = at
+		return true
+	})
+}
+
+// updateKey applies update to the key with prefix on client id. update
+// reports whether the key was in a state it applies to.
+func (r *APIClientRepository) updateKey(id, prefix string, update func(*domain.APIClient, *domain.APIKey) bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if c := r.client(id); c != nil {
+		for i := range c.Keys {
+			if c.Keys[i].Prefix == prefix && update(c, &c.Keys[i]) {
+				return nil
+			}
+		}
+	}
+	return domain.ErrAPIClientNotFound
+}
+
+func (r *APIClientRepository) SetStatus(ctx context.Context, id string, status domain.APIClientStatus, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := r.client(id)
+	if c == nil {
+		return domain.ErrAPIClientNotFound
+	}
+	c.Status, c.UpdatedAt = status, at
+	return nil
+}
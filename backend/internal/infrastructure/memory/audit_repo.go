@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"maps"
+
+	"akiba/backend/internal/domain"
+)
+
+type auditTables struct {
+	records []*domain.AuditRecord
+}
+
+func cloneAuditRecord(r *domain.AuditRecord) *domain.AuditRecord {
+	c := *r
+	c.Details = maps.Clone(r.Details)
+	return &c
+}
+
+// AuditRepository keeps the chain in sequence order. Append holds the
+// Store's lock while sealing, so it never races for a sequence number.
+type AuditRepository struct {
+	s *Store
+}
+
+func NewAuditRepository(s *Store) *AuditRepository {
+	return &AuditRepository{s: s}
+}
+
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *AuditRepository) Append(ctx context.Context, record *domain.AuditRecord) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var prev *domain.AuditRecord
+	if n := len(r.s.audit.records); n > 0 {
+		prev = cloneAuditRecord(r.s.audit.records[n-1])
+	}
+	record.Seal(prev)
+	r.s.audit.records = append(r.s.audit.records, cloneAuditRecord(record))
+	return nil
+}
+
+func (r *AuditRepository) Search(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditRecord, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	records := filter(newestFirst(r.s.audit.records), func(rec *domain.AuditRecord) bool {
+		return (f.ActorID == "" || rec.ActorID == f.ActorID) &&
+			(f.TargetID == "" || rec.TargetID == f.TargetID) &&
+			(f.Action == "" || rec.Action == f.Action) &&
+			(f.From.IsZero() || !rec.CreatedAt.Before(f.From)) &&
+			(f.To.IsZero() || rec.CreatedAt.Before(f.To))
+	})
+	return head(records, f.Limit, cloneAuditRecord), nil
+}
+
+func (r *AuditRepository) ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*domain.AuditRecord, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	records := filter(r.s.audit.records, func(rec *domain.AuditRecord) bool { return rec.Seq > afterSeq })
+	return head(records, limit, cloneAuditRecord), nil
+}
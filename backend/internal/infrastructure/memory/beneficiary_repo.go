@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type beneficiaryTables struct {
+	beneficiaries []*domain.Beneficiary
+}
+
+func cloneBeneficiary(b *domain.Beneficiary) *domain.Beneficiary {
+	c := *b
+	return &c
+}
+
+// BeneficiaryRepository keeps each owner's destinations and nicknames
+// unique, nicknames case-insensitively.
+type BeneficiaryRepository struct {
+	s *Store
+}
+
+func NewBeneficiaryRepository(s *Store) *BeneficiaryRepository {
+	return &BeneficiaryRepository{s: s}
+}
+
+func (r *BeneficiaryRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *BeneficiaryRepository) Create(ctx context.Context, beneficiary *domain.Beneficiary) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	taken := find(r.s.beneficiaries.beneficiaries, func(b *domain.Beneficiary) bool {
+		return b.OwnerID == beneficiary.OwnerID && (b.DestinationKey() == beneficiary.DestinationKey() || strings.EqualFold(b.Nickname, beneficiary.Nickname))
+	})
+	if taken != nil {
+		return domain.ErrBeneficiaryExists
+	}
+	created := cloneBeneficiary(beneficiary)
+	created.ID = r.s.newID()
+	r.s.beneficiaries.beneficiaries = append(r.s.beneficiaries.beneficiaries, created)
+	beneficiary.ID = created.ID
+	return nil
+}
+
+func (r *BeneficiaryRepository) GetByID(ctx context.Context, id string) (*domain.Beneficiary, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	b := r.beneficiary(id)
+	if b == nil {
+		return nil, domain.ErrBeneficiaryNotFound
+	}
+	return cloneBeneficiary(b), nil
+}
+
+func (r *BeneficiaryRepository) beneficiary(id string) *domain.Beneficiary {
+	return find(r.s.beneficiaries.beneficiaries, func(b *domain.Beneficiary) bool { return b.ID == id })
+}
+
+func (r *BeneficiaryRepository) ListByOwner(ctx context.Context, ownerID string) ([]*domain.Beneficiary, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	owned := filter(r.s.beneficiaries.beneficiaries, func(b *domain.Beneficiary) bool { return b.OwnerID == ownerID })
+	slices.SortStableFunc(owned, func(a, b *domain.Beneficiary) int {
+		return strings.Compare(strings.ToLower(a.Nickname), strings.ToLower(b.Nickname))
+	})
+	return head(owned, 0, cloneBeneficiary), nil
+}
+
+func (r *BeneficiaryRepository) UpdateNickname(ctx context.Context, id, nickname string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	b := r.beneficiary(id)
+	if b == nil {
+		return domain.ErrBeneficiaryNotFound
+	}
+	taken := find(r.s.beneficiaries.beneficiaries, func(o *domain.Beneficiary) bool {
+		return o.ID != id && o.OwnerID == b.OwnerID && strings.EqualFold(o.Nickname, nickname)
+	})
+	if taken != nil {
+		return domain.ErrBeneficiaryExists
+	}
+	b.Nickname, b.UpdatedAt = nickname, at
+	return nil
+}
+
+func (r *BeneficiaryRepository) Delete(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if !remove(&r.s.beneficiaries.beneficiaries, func(b *domain.Beneficiary) bool { return b.ID == id }) {
+		return domain.ErrBeneficiaryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type deviceTables struct {
+	devices    []*domain.Device
+	otps       []*domain.OTPChallenge
+	assertions map[string]time.Time
+}
+
+func cloneDevice(d *domain.Device) *domain.Device {
+	c := *d
+	return &c
+}
+
+func cloneOTP(o *domain.OTPChallenge) *domain.OTPChallenge {
+	c := *o
+	if o.Device != nil {
+		c.Device = cloneDevice(o.Device)
+	}
+	return &c
+}
+
+// DeviceRepository keys devices by user and DeviceID. Expired OTP
+// challenges and assertions are dropped when the next one is stored,
+// standing in for Mongo's TTL indexes.
+type DeviceRepository struct {
+	s *Store
+}
+
+func NewDeviceRepository(s *Store) *DeviceRepository {
+	return &DeviceRepository{s: s}
+}
+
+func (r *DeviceRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *DeviceRepository) Upsert(ctx context.Context, device *domain.Device) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := find(r.s.devices.devices, func(d *domain.Device) bool {
+		return d.UserID == device.UserID && d.DeviceID == device.DeviceID
+	})
+	if d == nil {
+		d = cloneDevice(device)
+		d.ID = r.s.newID()
+		r.s.devices.devices = append(r.s.devices.devices, d)
+	}
+	d.Platform, d.PublicKey, d.PushToken, d.Trusted, d.LastSeenAt = device.Platform, device.PublicKey, device.PushToken, device.Trusted, device.LastSeenAt
+	device.ID, device.FirstSeenAt = d.ID, d.FirstSeenAt
+	return nil
+}
+
+func (r *DeviceRepository) Get(ctx context.Context, userID, deviceID string) (*domain.Device, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := find(r.s.devices.devices, func(d *domain.Device) bool { return d.UserID == userID && d.DeviceID == deviceID })
+	if d == nil {
+		return nil, domain.ErrDeviceNotFound
+	}
+	return cloneDevice(d), nil
+}
+
+func (r *DeviceRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Device, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	devices := filter(r.s.devices.devices, func(d *domain.Device) bool { return d.UserID == userID })
+	slices.SortStableFunc(devices, func(a, b *domain.Device) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return head(devices, 0, cloneDevice), nil
+}
+
+func (r *DeviceRepository) Touch(ctx context.Context, id, pushToken string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := find(r.s.devices.devices, func(d *domain.Device) bool { return d.ID == id })
+	if d == nil {
+		return domain.ErrDeviceNotFound
+	}
+	d.LastSeenAt = at
+	if pushToken != "" {
+		d.PushToken = pushToken
+	}
+	return nil
+}
+
+func (r *DeviceRepository) Delete(ctx context.Context, userID, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if !remove(&r.s.devices.devices, func(d *domain.Device) bool { return d.ID == id && d.UserID == userID }) {
+		return domain.ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *DeviceRepository) CreateOTP(ctx context.Context, challenge *domain.OTPChallenge) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.devices.otps = slices.DeleteFunc(r.s.devices.otps, func(o *domain.OTPChallenge) bool {
+		return !o.ExpiresAt.After(challenge.CreatedAt)
+	})
+	created := cloneOTP(challenge)
+	created.ID = r.s.newID()
+	if created.Device != nil {
+		created.Device.ID = ""
+	}
+	r.s.devices.otps = append(r.s.devices.otps, created)
+	challenge.ID = created.ID
+	return nil
+}
+
+func (r *DeviceRepository) GetOTP(ctx context.Context, id string) (*domain.OTPChallenge, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	o := r.otp(id)
+	if o == nil {
+		return nil, domain.ErrChallengeNotFound
+	}
+	return cloneOTP(o), nil
+}
+
+func (r *DeviceRepository) otp(id string) *domain.OTPChallenge {
+	return find(r.s.devices.otps, func(o *domain.OTPChallenge) bool { return o.ID == id })
+}
+
+func (r *DeviceRepository) RecordOTPFailure(ctx context.Context, id string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	o := r.otp(id)
+	if o == nil {
+		return 0, domain.ErrChallengeNotFound
+	}
+	o.Attempts++
+	return o.Attempts, nil
+}
+
+func (r *DeviceRepository) DeleteOTP(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if !remove(&r.s.devices.otps, func(o *domain.OTPChallenge) bool { return o.ID == id }) {
+		return domain.ErrChallengeNotFound
+	}
+	return nil
+}
+
+func (r *DeviceRepository) UseAssertion(ctx context.Context, digest string, at, expiresAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.s.devices.assertions == nil {
+		r.s.devices.assertions = map[string]time.Time{}
+	}
+	maps.DeleteFunc(r.s.devices.assertions, func(_ string, until time.Time) bool { return !until.After(at) })
+	if _, ok := r.s.devices.assertions[digest]; ok {
+		return domain.ErrAssertionReplayed
+	}
+	r.s.devices.assertions[digest] = expiresAt
+	return nil
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type disputeTables struct {
+	disputes []*domain.Dispute
+}
+
+func cloneDispute(d *domain.Dispute) *domain.Dispute {
+	c := *d
+	c.Evidence = make([]domain.Evidence, 0, len(d.Evidence))
+	for _, e := range d.Evidence {
+		e.Data = slices.Clone(e.Data)
+		c.Evidence = append(c.Evidence, e)
+	}
+	return &c
+}
+
+// cloneDisputeSummary leaves out evidence file contents, as the Mongo
+// repository does for every read but GetByID.
+func cloneDisputeSummary(d *domain.Dispute) *domain.Dispute {
+	c := *d
+	c.Evidence = make([]domain.Evidence, 0, len(d.Evidence))
+	for _, e := range d.Evidence {
+		e.Data = nil
+		c.Evidence = append(c.Evidence, e)
+	}
+	return &c
+}
+
+// DisputeRepository allows one unresolved dispute per entry.
+type DisputeRepository struct {
+	s *Store
+}
+
+func NewDisputeRepository(s *Store) *DisputeRepository {
+	return &DisputeRepository{s: s}
+}
+
+func (r *DisputeRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *DisputeRepository) Create(ctx context.Context, dispute *domain.Dispute) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if !dispute.Status.Resolved() && r.active(dispute.EntryID) != nil {
+		return domain.ErrDisputeExists
+	}
+	created := &domain.Dispute{ID: r.s.newID(), EntryID: dispute.EntryID, PayerID: dispute.PayerID, PayeeOwnerID: dispute.PayeeOwnerID, PayerAccountID: dispute.PayerAccountID, PayeeAccountID: dispute.PayeeAccountID, Currency: dispute.Currency, Amount: dispute.Amount, Reason: dispute.Reason, Status: dispute.Status, Evidence: []domain.Evidence{}, CreatedAt: dispute.CreatedAt, UpdatedAt: dispute.UpdatedAt}
+	r.s.disputes.disputes = append(r.s.disputes.disputes, created)
+	dispute.ID = created.ID
+	return nil
+}
+
+func (r *DisputeRepository) GetByID(ctx context.Context, id string) (*domain.Dispute, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := r.dispute(id)
+	if d == nil {
+		return nil, domain.ErrDisputeNotFound
+	}
+	return cloneDispute(d), nil
+}
+
+func (r *DisputeRepository) GetActiveByEntry(ctx context.Context, entryID string) (*domain.Dispute, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := r.active(entryID)
+	if d == nil {
+		return nil, domain.ErrDisputeNotFound
+	}
+	return cloneDisputeSummary(d), nil
+}
+
+func (r *DisputeRepository) dispute(id string) *domain.Dispute {
+	return find(r.s.disputes.disputes, func(d *domain.Dispute) bool { return d.ID == id })
+}
+
+func (r *DisputeRepository) active(entryID string) *domain.Dispute {
+	return find(r.s.disputes.disputes, func(d *domain.Dispute) bool { return d.EntryID == entryID && !d.Status.Resolved() })
+}
+
+func (r *DisputeRepository) ListByParty(ctx context.Context, userID string) ([]*domain.Dispute, error) {
+	return r.list(func(d *domain.Dispute) bool { return d.PayerID == userID || d.PayeeOwnerID == userID })
+}
+
+func (r *DisputeRepository) ListByStatus(ctx context.Context, status domain.DisputeStatus) ([]*domain.Dispute, error) {
+	return r.list(func(d *domain.Dispute) bool { return status == "" || d.Status == status })
+}
+
+func (r *DisputeRepository) list(match func(*domain.Dispute) bool) ([]*domain.Dispute, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	disputes := filter(r.s.disputes.disputes, match)
+	slices.SortStableFunc(disputes, func(a, b *domain.Dispute) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return head(disputes, 0, cloneDisputeSummary), nil
+}
+
+func (r *DisputeRepository) SetHold(ctx context.Context, id, entryID string, amount int64, at time.Time) error {
+	return r.update(id, func(d *domain.Dispute) {
+		d.HoldEntryID, d.HeldAmount, d.UpdatedAt = entryID, amount, at
+	})
+}
+
+func (r *DisputeRepository) AddEvidence(ctx context.Context, id string, evidence domain.Evidence) error {
+	evidence.Data = slices.Clone(evidence.Data)
+	return r.update(id, func(d *domain.Dispute) {
+		d.Evidence = append(d.Evidence, evidence)
+		d.UpdatedAt = evidence.CreatedAt
+	})
+}
+
+func (r *DisputeRepository) update(id string, update func(*domain.Dispute)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := r.dispute(id)
+	if d == nil {
+		return domain.ErrDisputeNotFound
+	}
+	update(d)
+	return nil
+}
+
+func (r *DisputeRepository) Transition(ctx context.Context, dispute *domain.Dispute, from domain.DisputeStatus) error {
+	if !objectIDPattern.MatchString(dispute.ID) {
+		return domain.ErrDisputeNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := r.dispute(dispute.ID)
+	if d == nil || d.Status != from {
+		return domain.ErrInvalidTransition
+	}
+	d.Status, d.ResolutionEntryID, d.ResolutionNote, d.ResolvedBy, d.ResolvedAt, d.UpdatedAt = dispute.Status, dispute.ResolutionEntryID, dispute.ResolutionNote, dispute.ResolvedBy, dispute.ResolvedAt, dispute.UpdatedAt
+	return nil
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type fxQuoteTables struct {
+	quotes []*domain.FXQuote
+}
+
+func cloneFXQuote(q *domain.FXQuote) *domain.FXQuote {
+	c := *q
+	return &c
+}
+
+type FXQuoteRepository struct {
+	s *Store
+}
+
+func NewFXQuoteRepository(s *Store) *FXQuoteRepository {
+	return &FXQuoteRepository{s: s}
+}
+
+func (r *FXQuoteRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *FXQuoteRepository) Create(ctx context.Context, quote *domain.FXQuote) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	created := cloneFXQuote(quote)
+	created.ID, created.EntryID, created.ExecutedAt = r.s.newID(), "", time.Time{}
+	r.s.fxQuotes.quotes = append(r.s.fxQuotes.quotes, created)
+	quote.ID = created.ID
+	return nil
+}
+
+func (r *FXQuoteRepository) GetByID(ctx context.Context, id string) (*domain.FXQuote, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	q := r.quote(id)
+	if q == nil {
+		return nil, domain.ErrQuoteNotFound
+	}
+	return cloneFXQuote(q), nil
+}
+
+func (r *FXQuoteRepository) quote(id string) *domain.FXQuote {
+	return find(r.s.fxQuotes.quotes, func(q *domain.FXQuote) bool { return q.ID == id })
+}
+
+func (r *FXQuoteRepository) MarkExecuted(ctx context.Context, id, entryID string, at time.Time) error {
+	if !objectIDPattern.MatchString(id) {
+		return domain.ErrQuoteNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	q := r.quote(id)
+	if q == nil || q.Status != domain.FXQuoteStatusOpen {
+		return domain.ErrQuoteExecuted
+	}
+	q.Status, q.EntryID, q.ExecutedAt = domain.FXQuoteStatusExecuted, entryID, at
+	return nil
+}
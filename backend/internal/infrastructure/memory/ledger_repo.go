@@ -0,0 +1,357 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type ledgerTables struct {
+	accounts []*domain.Account
+	entries  []*domain.JournalEntry
+	holds    []*domain.Hold
+}
+
+func cloneAccount(a *domain.Account) *domain.Account {
+	c := *a
+	return &c
+}
+
+func cloneEntry(e *domain.JournalEntry) *domain.JournalEntry {
+	c := *e
+	c.Postings = slices.Clone(e.Postings)
+	c.Metadata = maps.Clone(e.Metadata)
+	return &c
+}
+
+func cloneHold(h *domain.Hold) *domain.Hold {
+	c := *h
+	c.Metadata = maps.Clone(h.Metadata)
+	return &c
+}
+
+// LedgerRepository keeps accounts with a running balance, the journal
+// entries that produced it and the holds reserving part of it. Every
+// posted entry queues a "<kind>.completed" event in the Store's outbox.
+type LedgerRepository struct {
+	s *Store
+}
+
+func NewLedgerRepository(s *Store) *LedgerRepository {
+	return &LedgerRepository{s: s}
+}
+
+func (r *LedgerRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *LedgerRepository) GetOrCreateAccount(ctx context.Context, ownerID string, accountType domain.AccountType, currency string) (*domain.Account, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	a := find(r.s.ledger.accounts, func(a *domain.Account) bool {
+		return a.OwnerID == ownerID && a.Type == accountType && a.Currency == currency
+	})
+	if a == nil {
+		now := time.Now().UTC()
+		a = &domain.Account{ID: r.s.newID(), OwnerID: ownerID, Type: accountType, Currency: currency, CreatedAt: now, UpdatedAt: now}
+		r.s.ledger.accounts = append(r.s.ledger.accounts, a)
+	}
+	return cloneAccount(a), nil
+}
+
+func (r *LedgerRepository) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	a := r.account(id)
+	if a == nil {
+		return nil, domain.ErrAccountNotFound
+	}
+	return cloneAccount(a), nil
+}
+
+func (r *LedgerRepository) account(id string) *domain.Account {
+	return find(r.s.ledger.accounts, func(a *domain.Account) bool { return a.ID == id })
+}
+
+func (r *LedgerRepository) ListAccountsByOwner(ctx context.Context, ownerID string) ([]*domain.Account, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	accounts := filter(r.s.ledger.accounts, func(a *domain.Account) bool { return a.OwnerID == ownerID })
+	slices.SortStableFunc(accounts, func(a, b *domain.Account) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(accounts, 0, cloneAccount), nil
+}
+
+func (r *LedgerRepository) Post(ctx context.Context, entry *domain.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.post(entry)
+}
+
+func (r *LedgerRepository) PostWithinCap(ctx context.Context, entry *domain.JournalEntry, limit domain.PayeeCap) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.sentWithinCap(limit)+limit.EntryAmount(entry) > limit.Limit {
+		return domain.ErrCoolingOffLimit
+	}
+	return r.post(entry)
+}
+
+func (r *LedgerRepository) SentWithinCap(ctx context.Context, limit domain.PayeeCap) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.sentWithinCap(limit), nil
+}
+
+// sentWithinCap sums the entries and active holds that count against
+// limit. The caller holds s.mu.
+func (r *LedgerRepository) sentWithinCap(limit domain.PayeeCap) int64 {
+	var sent int64
+	for _, e := range r.s.ledger.entries {
+		sent += limit.EntryAmount(e)
+	}
+	for _, h := range r.s.ledger.holds {
+		sent += limit.HoldAmount(h)
+	}
+	return sent
+}
+
+func (r *LedgerRepository) FirstPaymentTo(ctx context.Context, accountID, field, payee string) (time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var first time.Time
+	for _, e := range r.s.ledger.entries {
+		if e.Metadata[field] == payee && e.AmountFor(accountID) < 0 && (first.IsZero() || e.CreatedAt.Before(first)) {
+			first = e.CreatedAt
+		}
+	}
+	return first, nil
+}
+
+// post checks every posting before applying any, so a rejected entry
+// leaves no trace. The caller holds s.mu.
+func (r *LedgerRepository) post(entry *domain.JournalEntry) error {
+	deltas := map[*domain.Account]int64{}
+	for _, p := range entry.Postings {
+		a := r.account(p.AccountID)
+		if a == nil || a.Currency != p.Currency {
+			return domain.ErrAccountNotFound
+		}
+		if p.Amount < 0 && !a.AllowsNegative() && a.Balance+deltas[a]-a.Held < -p.Amount {
+			return domain.ErrInsufficientFunds
+		}
+		deltas[a] += p.Amount
+	}
+	if entry.Reference != "" && find(r.s.ledger.entries, func(e *domain.JournalEntry) bool { return e.Reference == entry.Reference }) != nil {
+		return domain.ErrDuplicateEntry
+	}
+	for a, delta := range deltas {
+		a.Balance += delta
+		a.UpdatedAt = entry.CreatedAt
+	}
+	posted := cloneEntry(entry)
+	posted.ID = r.s.newID()
+	r.s.ledger.entries = append(r.s.ledger.entries, posted)
+	r.s.appendOutbox(domain.NewEntryPostedEvent(posted))
+	entry.ID = posted.ID
+	return nil
+}
+
+func (r *LedgerRepository) ListEntriesByAccount(ctx context.Context, accountID string, from, to time.Time) ([]*domain.JournalEntry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	entries := filter(r.s.ledger.entries, func(e *domain.JournalEntry) bool {
+		touches := slices.ContainsFunc(e.Postings, func(p domain.Posting) bool { return p.AccountID == accountID })
+		return touches && !e.CreatedAt.Before(from) && e.CreatedAt.Before(to)
+	})
+	slices.SortStableFunc(entries, func(a, b *domain.JournalEntry) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(entries, 0, cloneEntry), nil
+}
+
+func (r *LedgerRepository) GetEntry(ctx context.Context, id string) (*domain.JournalEntry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e := find(r.s.ledger.entries, func(e *domain.JournalEntry) bool { return e.ID == id })
+	if e == nil {
+		return nil, domain.ErrEntryNotFound
+	}
+	return cloneEntry(e), nil
+}
+
+func (r *LedgerRepository) GetEntryByReference(ctx context.Context, reference string) (*domain.JournalEntry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e := find(r.s.ledger.entries, func(e *domain.JournalEntry) bool { return reference != "" && e.Reference == reference })
+	if e == nil {
+		return nil, domain.ErrEntryNotFound
+	}
+	return cloneEntry(e), nil
+}
+
+func (r *LedgerRepository) ListEntriesAfter(ctx context.Context, after time.Time, afterID string, until time.Time, limit int) ([]*domain.JournalEntry, error) {
+	if afterID != "" && !objectIDPattern.MatchString(afterID) {
+		return nil, domain.ErrEntryNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	entries := filter(r.s.ledger.entries, func(e *domain.JournalEntry) bool {
+		return e.CreatedAt.Before(until) && (e.CreatedAt.After(after) || (e.CreatedAt.Equal(after) && e.ID > afterID))
+	})
+	slices.SortStableFunc(entries, func(a, b *domain.JournalEntry) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return head(entries, limit, cloneEntry), nil
+}
+
+func (r *LedgerRepository) ListReversals(ctx context.Context, entryID string) ([]*domain.JournalEntry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	entries := filter(r.s.ledger.entries, func(e *domain.JournalEntry) bool { return e.ReversalOf == entryID })
+	slices.SortStableFunc(entries, func(a, b *domain.JournalEntry) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(entries, 0, cloneEntry), nil
+}
+
+func (r *LedgerRepository) PlaceHold(ctx context.Context, hold *domain.Hold) error {
+	if hold.Amount <= 0 {
+		return domain.ErrInvalidInput
+	}
+	if !objectIDPattern.MatchString(hold.AccountID) {
+		return domain.ErrAccountNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.placeHold(hold)
+}
+
+func (r *LedgerRepository) PlaceHoldWithinCap(ctx context.Context, hold *domain.Hold, limit domain.PayeeCap) error {
+	if hold.Amount <= 0 {
+		return domain.ErrInvalidInput
+	}
+	if !objectIDPattern.MatchString(hold.AccountID) {
+		return domain.ErrAccountNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.sentWithinCap(limit)+limit.HoldAmount(hold) > limit.Limit {
+		return domain.ErrCoolingOffLimit
+	}
+	return r.placeHold(hold)
+}
+
+// placeHold reserves hold on its account. The caller holds s.mu.
+func (r *LedgerRepository) placeHold(hold *domain.Hold) error {
+	a := r.account(hold.AccountID)
+	if a == nil || a.Currency != hold.Currency || a.AllowsNegative() || a.Balance-a.Held < hold.Amount {
+		return domain.ErrInsufficientFunds
+	}
+	a.Held += hold.Amount
+	a.UpdatedAt = hold.CreatedAt
+	placed := cloneHold(hold)
+	placed.ID, placed.CapturedAmount, placed.EntryID = r.s.newID(), 0, ""
+	r.s.ledger.holds = append(r.s.ledger.holds, placed)
+	hold.ID = placed.ID
+	return nil
+}
+
+func (r *LedgerRepository) GetHold(ctx context.Context, id string) (*domain.Hold, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	h := r.hold(id)
+	if h == nil {
+		return nil, domain.ErrHoldNotFound
+	}
+	return cloneHold(h), nil
+}
+
+func (r *LedgerRepository) hold(id string) *domain.Hold {
+	return find(r.s.ledger.holds, func(h *domain.Hold) bool { return h.ID == id })
+}
+
+func (r *LedgerRepository) ListActiveHolds(ctx context.Context, accountID string) ([]*domain.Hold, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	holds := filter(r.s.ledger.holds, func(h *domain.Hold) bool {
+		return h.AccountID == accountID && h.Status == domain.HoldStatusActive
+	})
+	slices.SortStableFunc(holds, func(a, b *domain.Hold) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(holds, 0, cloneHold), nil
+}
+
+func (r *LedgerRepository) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*domain.Hold, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	holds := filter(r.s.ledger.holds, func(h *domain.Hold) bool {
+		return h.Status == domain.HoldStatusActive && h.Expired(now)
+	})
+	slices.SortStableFunc(holds, func(a, b *domain.Hold) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return head(holds, limit, cloneHold), nil
+}
+
+func (r *LedgerRepository) CaptureHold(ctx context.Context, id string, capturedAmount int64, entry *domain.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	h := r.hold(id)
+	if h == nil {
+		return domain.ErrHoldNotFound
+	}
+	if h.Status != domain.HoldStatusActive || h.Expired(entry.CreatedAt) {
+		return domain.ErrHoldNotActive
+	}
+	// The hold is released before posting so the capture can spend the
+	// funds it reserved, and restored if the posting is rejected.
+	before, a := *h, r.account(h.AccountID)
+	var held int64
+	var updated time.Time
+	if a != nil {
+		held, updated = a.Held, a.UpdatedAt
+	}
+	r.settle(h, domain.HoldStatusCaptured, entry.CreatedAt)
+	if err := r.post(entry); err != nil {
+		*h = before
+		if a != nil {
+			a.Held, a.UpdatedAt = held, updated
+		}
+		return err
+	}
+	h.CapturedAmount, h.EntryID = capturedAmount, entry.ID
+	return nil
+}
+
+func (r *LedgerRepository) ReleaseHold(ctx context.Context, id string, status domain.HoldStatus, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	h := r.hold(id)
+	if h == nil {
+		return domain.ErrHoldNotFound
+	}
+	if h.Status != domain.HoldStatusActive || (status == domain.HoldStatusExpired && !h.Expired(at)) {
+		return domain.ErrHoldNotActive
+	}
+	r.settle(h, status, at)
+	return nil
+}
+
+// settle moves h out of active and gives its amount back to the account's
+// available balance. The caller holds s.mu.
+func (r *LedgerRepository) settle(h *domain.Hold, status domain.HoldStatus, at time.Time) {
+	h.Status, h.UpdatedAt = status, at
+	if a := r.account(h.AccountID); a != nil {
+		a.Held -= h.Amount
+		a.UpdatedAt = at
+	}
+}
@@ -0,0 +1,108 @@
+// Package memory implements every repository in process memory, for demos
+// and tests. Nothing survives a restart. Repositories built on the same
+// Store share one lock, so a write spanning several of them, such as a
+// ledger posting and its outbox event, is atomic as in a Mongo transaction.
+package memory
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var objectIDPattern = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// Store holds the records of every repository built on it. Records are
+// copied on the way in and out, so callers never share memory with it.
+type Store struct {
+	mu     sync.Mutex
+	lastID uint64
+
+	users         userTables
+	outbox        outboxTables
+	ledger        ledgerTables
+	aml           amlTables
+	apiClients    apiClientTables
+	audit         auditTables
+	beneficiaries beneficiaryTables
+	devices       deviceTables
+	disputes      disputeTables
+	fxQuotes      fxQuoteTables
+	merchants     merchantTables
+	oauth         oauthTables
+	passkeys      passkeyTables
+	risk          riskTables
+	webhooks      webhookTables
+}
+
+func NewStore() *Store {
+	return &Store{outbox: outboxTables{sequences: map[string]int64{}}}
+}
+
+// newID returns a 24 character hex ID shaped like a Mongo ObjectID, the
+// current second followed by a counter, so IDs look the same on every
+// backend. The caller holds s.mu.
+func (s *Store) newID() string {
+	s.lastID++
+	var b [12]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(time.Now().Unix()))
+	binary.BigEndian.PutUint64(b[4:], s.lastID)
+	return hex.EncodeToString(b[:])
+}
+
+// find returns the first row matching match, or nil.
+func find[T any](rows []*T, match func(*T) bool) *T {
+	for _, row := range rows {
+		if match(row) {
+			return row
+		}
+	}
+	return nil
+}
+
+// filter returns the rows matching match, in store order.
+func filter[T any](rows []*T, match func(*T) bool) []*T {
+	var out []*T
+	for _, row := range rows {
+		if match(row) {
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// newestFirst returns rows in reverse store order, the order of a
+// descending sort on a Mongo ObjectID.
+func newestFirst[T any](rows []*T) []*T {
+	out := make([]*T, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		out = append(out, rows[i])
+	}
+	return out
+}
+
+// remove deletes row from rows, reporting whether it was there.
+func remove[T any](rows *[]*T, match func(*T) bool) bool {
+	for i, row := range *rows {
+		if match(row) {
+			*rows = append((*rows)[:i], (*rows)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// head returns the first limit rows copied with clone. A limit of zero or
+// less returns every row, as Mongo does.
+func head[T any](rows []*T, limit int, clone func(*T) *T) []*T {
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	out := make([]*T, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, clone(row))
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/repository/repositorytest"
+)
+
+func TestUserRepositoryContract(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository { return NewUserRepository(NewStore()) })
+}
+
+func TestOutboxRepositoryContract(t *testing.T) {
+	repositorytest.TestOutboxRepository(t, func(t *testing.T) (repository.OutboxRepository, repository.UserRepository) {
+		s := NewStore()
+		return NewOutboxRepository(s), NewUserRepository(s)
+	})
+}
+
+func TestLedgerRepositoryContract(t *testing.T) {
+	repositorytest.TestLedgerRepository(t, func(t *testing.T) repository.LedgerRepository { return NewLedgerRepository(NewStore()) })
+}
+
+func TestAMLRepositoryContract(t *testing.T) {
+	repositorytest.TestAMLRepository(t, func(t *testing.T) repository.AMLRepository { return NewAMLRepository(NewStore()) })
+}
+
+func TestAPIClientRepositoryContract(t *testing.T) {
+	repositorytest.TestAPIClientRepository(t, func(t *testing.T) repository.APIClientRepository { return NewAPIClientRepository(NewStore()) })
+}
+
+func TestAuditRepositoryContract(t *testing.T) {
+	repositorytest.TestAuditRepository(t, func(t *testing.T) repository.AuditRepository { return NewAuditRepository(NewStore()) })
+}
+
+func TestBeneficiaryRepositoryContract(t *testing.T) {
+	repositorytest.TestBeneficiaryRepository(t, func(t *testing.T) repository.BeneficiaryRepository { return NewBeneficiaryRepository(NewStore()) })
+}
+
+func TestDeviceRepositoryContract(t *testing.T) {
+	repositorytest.TestDeviceRepository(t, func(t *testing.T) repository.DeviceRepository { return NewDeviceRepository(NewStore()) })
+}
+
+func TestDisputeRepositoryContract(t *testing.T) {
+	repositorytest.TestDisputeRepository(t, func(t *testing.T) repository.DisputeRepository { return NewDisputeRepository(NewStore()) })
+}
+
+func TestFXQuoteRepositoryContract(t *testing.T) {
+	repositorytest.TestFXQuoteRepository(t, func(t *testing.T) repository.FXQuoteRepository { return NewFXQuoteRepository(NewStore()) })
+}
+
+func TestMerchantRepositoryContract(t *testing.T) {
+	repositorytest.TestMerchantRepository(t, func(t *testing.T) repository.MerchantRepository { return NewMerchantRepository(NewStore()) })
+}
+
+func TestOAuthRepositoryContract(t *testing.T) {
+	repositorytest.TestOAuthRepository(t, func(t *testing.T) repository.OAuthRepository { return NewOAuthRepository(NewStore()) })
+}
+
+func TestPasskeyRepositoryContract(t *testing.T) {
+	repositorytest.TestPasskeyRepository(t, func(t *testing.T) repository.PasskeyRepository { return NewPasskeyRepository(NewStore()) })
+}
+
+func TestRiskRepositoryContract(t *testing.T) {
+	repositorytest.TestRiskRepository(t, func(t *testing.T) repository.RiskRepository { return NewRiskRepository(NewStore()) })
+}
+
+func TestWebhookRepositoryContract(t *testing.T) {
+	repositorytest.TestWebhookRepository(t, func(t *testing.T) repository.WebhookRepository { return NewWebhookRepository(NewStore()) })
+}
+
+func TestConcurrentCreateKeepsEmailUnique(t *testing.T) {
+	repo := NewUserRepository(NewStore())
+	var wg sync.WaitGroup
+	var created, rejected atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u := &domain.User{EmailLower: "same@example.com", PhoneE164: fmt.Sprintf("+2547000000%02d", i), UsernameLower: fmt.Sprintf("user_%d", i), CreatedAt: time.Now()}
+			switch err := repo.Create(context.Background(), u); {
+			case err == nil:
+				created.Add(1)
+			case errors.Is(err, domain.ErrUserExists):
+				rejected.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created.Load() != 1 || rejected.Load() != 19 {
+		t.Fatalf("expected one user and 19 rejections, got %d and %d", created.Load(), rejected.Load())
+	}
+}
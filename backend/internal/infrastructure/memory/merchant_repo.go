@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"akiba/backend/internal/domain"
+)
+
+type merchantTables struct {
+	merchants []*domain.Merchant
+}
+
+func cloneMerchant(m *domain.Merchant) *domain.Merchant {
+	c := *m
+	return &c
+}
+
+type MerchantRepository struct {
+	s *Store
+}
+
+func NewMerchantRepository(s *Store) *MerchantRepository {
+	return &MerchantRepository{s: s}
+}
+
+func (r *MerchantRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MerchantRepository) Create(ctx context.Context, merchant *domain.Merchant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	created := cloneMerchant(merchant)
+	created.ID = r.s.newID()
+	r.s.merchants.merchants = append(r.s.merchants.merchants, created)
+	merchant.ID = created.ID
+	return nil
+}
+
+func (r *MerchantRepository) GetByID(ctx context.Context, id string) (*domain.Merchant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	m := find(r.s.merchants.merchants, func(m *domain.Merchant) bool { return m.ID == id })
+	if m == nil {
+		return nil, domain.ErrMerchantNotFound
+	}
+	return cloneMerchant(m), nil
+}
+
+func (r *MerchantRepository) ListByOwner(ctx context.Context, ownerID string) ([]*domain.Merchant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	owned := filter(r.s.merchants.merchants, func(m *domain.Merchant) bool { return m.OwnerID == ownerID })
+	slices.SortStableFunc(owned, func(a, b *domain.Merchant) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(owned, 0, cloneMerchant), nil
+}
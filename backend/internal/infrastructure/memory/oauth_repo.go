@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+const (
+	// usedRefreshHashesKept bounds how many rotated refresh tokens a grant
+	// remembers for reuse detection.
+	usedRefreshHashesKept = 20
+	// oauthCodeRetention keeps used and expired codes long enough that a
+	// replayed code is recognised and its grant revoked.
+	oauthCodeRetention = 10 * time.Minute
+)
+
+type oauthTables struct {
+	consents []*domain.OAuthConsent
+	codes    []*domain.OAuthCode
+	grants   []*domain.OAuthGrant
+}
+
+func cloneConsent(c *domain.OAuthConsent) *domain.OAuthConsent {
+	out := *c
+	out.Scopes = slices.Clone(c.Scopes)
+	return &out
+}
+
+func cloneCode(c *domain.OAuthCode) *domain.OAuthCode {
+	out := *c
+	out.Scopes = slices.Clone(c.Scopes)
+	return &out
+}
+
+func cloneGrant(g *domain.OAuthGrant) *domain.OAuthGrant {
+	out := *g
+	out.Scopes = slices.Clone(g.Scopes)
+	out.UsedRefreshHashes = slices.Clone(g.UsedRefreshHashes)
+	return &out
+}
+
+// OAuthRepository drops codes past their retention and expired grants when
+// new ones are created, standing in for Mongo's TTL indexes.
+type OAuthRepository struct {
+	s *Store
+}
+
+func NewOAuthRepository(s *Store) *OAuthRepository {
+	return &OAuthRepository{s: s}
+}
+
+func (r *OAuthRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *OAuthRepository) UpsertConsent(ctx context.Context, consent *domain.OAuthConsent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := find(r.s.oauth.consents, func(c *domain.OAuthConsent) bool {
+		return c.UserID == consent.UserID && c.ClientID == consent.ClientID
+	})
+	if c == nil {
+		c = &domain.OAuthConsent{ID: r.s.newID(), UserID: consent.UserID, ClientID: consent.ClientID, CreatedAt: consent.CreatedAt}
+		r.s.oauth.consents = append(r.s.oauth.consents, c)
+	}
+	c.Scopes, c.UpdatedAt, c.RevokedAt = slices.Clone(consent.Scopes), consent.UpdatedAt, time.Time{}
+	*consent = *cloneConsent(c)
+	return nil
+}
+
+func (r *OAuthRepository) GetConsent(ctx context.Context, userID, clientID string) (*domain.OAuthConsent, error) {
+	return r.findConsent(func(c *domain.OAuthConsent) bool { return c.UserID == userID && c.ClientID == clientID })
+}
+
+func (r *OAuthRepository) GetConsentByID(ctx context.Context, id string) (*domain.OAuthConsent, error) {
+	return r.findConsent(func(c *domain.OAuthConsent) bool { return c.ID == id })
+}
+
+func (r *OAuthRepository) findConsent(match func(*domain.OAuthConsent) bool) (*domain.OAuthConsent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := find(r.s.oauth.consents, match)
+	if c == nil {
+		return nil, domain.ErrConsentNotFound
+	}
+	return cloneConsent(c), nil
+}
+
+func (r *OAuthRepository) ListConsents(ctx context.Context, userID string) ([]*domain.OAuthConsent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	consents := filter(r.s.oauth.consents, func(c *domain.OAuthConsent) bool { return c.UserID == userID && c.RevokedAt.IsZero() })
+	slices.SortStableFunc(consents, func(a, b *domain.OAuthConsent) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return head(consents, 0, cloneConsent), nil
+}
+
+func (r *OAuthRepository) RevokeConsent(ctx context.Context, id string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := find(r.s.oauth.consents, func(c *domain.OAuthConsent) bool { return c.ID == id })
+	if c == nil {
+		return domain.ErrConsentNotFound
+	}
+	c.RevokedAt, c.UpdatedAt = at, at
+	return nil
+}
+
+func (r *OAuthRepository) CreateCode(ctx context.Context, code *domain.OAuthCode) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.oauth.codes = slices.DeleteFunc(r.s.oauth.codes, func(c *domain.OAuthCode) bool {
+		return !c.ExpiresAt.Add(oauthCodeRetention).After(code.CreatedAt)
+	})
+	if find(r.s.oauth.codes, func(c *domain.OAuthCode) bool { return c.Hash == code.Hash }) != nil {
+		return domain.ErrDuplicateEntry
+	}
+	created := cloneCode(code)
+	created.UsedAt = time.Time{}
+	r.s.oauth.codes = append(r.s.oauth.codes, created)
+	return nil
+}
+
+func (r *OAuthRepository) ConsumeCode(ctx context.Context, hash string, at time.Time) (*domain.OAuthCode, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := find(r.s.oauth.codes, func(c *domain.OAuthCode) bool { return c.Hash == hash })
+	if c == nil {
+		return nil, domain.ErrInvalidGrant
+	}
+	if !c.UsedAt.IsZero() {
+		return cloneCode(c), domain.ErrTokenReused
+	}
+	c.UsedAt = at
+	return cloneCode(c), nil
+}
+
+func (r *OAuthRepository) CreateGrant(ctx context.Context, grant *domain.OAuthGrant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.oauth.grants = slices.DeleteFunc(r.s.oauth.grants, func(g *domain.OAuthGrant) bool {
+		return !g.ExpiresAt.After(grant.CreatedAt)
+	})
+	if grant.RefreshHash != "" && find(r.s.oauth.grants, func(g *domain.OAuthGrant) bool { return g.RefreshHash == grant.RefreshHash }) != nil {
+		return domain.ErrDuplicateEntry
+	}
+	created := cloneGrant(grant)
+	created.ID, created.UsedRefreshHashes, created.RevokedAt = r.s.newID(), nil, time.Time{}
+	r.s.oauth.grants = append(r.s.oauth.grants, created)
+	grant.ID = created.ID
+	return nil
+}
+
+func (r *OAuthRepository) GetGrant(ctx context.Context, id string) (*domain.OAuthGrant, error) {
+	return r.findGrant(func(g *domain.OAuthGrant) bool { return g.ID == id })
+}
+
+func (r *OAuthRepository) GetGrantByRefresh(ctx context.Context, hash string) (*domain.OAuthGrant, error) {
+	return r.findGrant(func(g *domain.OAuthGrant) bool {
+		return g.RefreshHash == hash || slices.Contains(g.UsedRefreshHashes, hash)
+	})
+}
+
+func (r *OAuthRepository) findGrant(match func(*domain.OAuthGrant) bool) (*domain.OAuthGrant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	g := find(r.s.oauth.grants, match)
+	if g == nil {
+		return nil, domain.ErrGrantNotFound
+	}
+	return cloneGrant(g), nil
+}
+
+func (r *OAuthRepository) RotateRefresh(ctx context.Context, id, oldHash, newHash string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	g := find(r.s.oauth.grants, func(g *domain.OAuthGrant) bool {
+		return g.ID == id && g.RefreshHash == oldHash && g.RevokedAt.IsZero()
+	})
+	if g == nil {
+		return domain.ErrInvalidGrant
+	}
+	g.RefreshHash, g.UpdatedAt = newHash, at
+	g.UsedRefreshHashes = append(g.UsedRefreshHashes, oldHash)
+	if n := len(g.UsedRefreshHashes); n > usedRefreshHashesKept {
+		g.UsedRefreshHashes = g.UsedRefreshHashes[n-usedRefreshHashesKept:]
+	}
+	return nil
+}
+
+func (r *OAuthRepository) RevokeGrant(ctx context.Context, id string, at time.Time) error {
+	if !objectIDPattern.MatchString(id) {
+		return domain.ErrGrantNotFound
+	}
+	r.revokeGrants(func(g *domain.OAuthGrant) bool { return g.ID == id }, at)
+	return nil
+}
+
+func (r *OAuthRepository) RevokeGrants(ctx context.Context, userID, clientID string, at time.Time) error {
+	r.revokeGrants(func(g *domain.OAuthGrant) bool { return g.UserID == userID && g.ClientID == clientID }, at)
+	return nil
+}
+
+func (r *OAuthRepository) RevokeGrantsByCode(ctx context.Context, codeHash string, at time.Time) error {
+	r.revokeGrants(func(g *domain.OAuthGrant) bool { return g.CodeHash == codeHash }, at)
+	return nil
+}
+
+// revokeGrants stamps RevokedAt on the unrevoked grants matching match;
+// revoking an already revoked grant is not an error.
+func (r *OAuthRepository) revokeGrants(match func(*domain.OAuthGrant) bool, at time.Time) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, g := range r.s.oauth.grants {
+		if match(g) && g.RevokedAt.IsZero() {
+			g.RevokedAt, g.UpdatedAt = at, at
+		}
+	}
+}
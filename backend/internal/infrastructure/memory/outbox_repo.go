@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+// publishedOutboxRetention is how long published events stay in the outbox
+// before MarkPublished deletes them.
+const publishedOutboxRetention = 7 * 24 * time.Hour
+
+type outboxTables struct {
+	events      []*domain.OutboxEvent
+	deadLetters []*domain.OutboxEvent
+	sequences   map[string]int64
+}
+
+func cloneOutboxEvent(e *domain.OutboxEvent) *domain.OutboxEvent {
+	c := *e
+	c.Payload = slices.Clone(e.Payload)
+	return &c
+}
+
+// appendOutbox numbers event within its aggregate and queues it. The
+// caller holds s.mu, so Seq follows the order of the writes.
+func (s *Store) appendOutbox(event *domain.OutboxEvent) {
+	s.outbox.sequences[event.AggregateKey()]++
+	event.Seq = s.outbox.sequences[event.AggregateKey()]
+	event.ID = s.newID()
+	s.outbox.events = append(s.outbox.events, cloneOutboxEvent(event))
+}
+
+// OutboxRepository serves the relay for events written by the repositories
+// of the same Store.
+type OutboxRepository struct {
+	s *Store
+}
+
+func NewOutboxRepository(s *Store) *OutboxRepository {
+	return &OutboxRepository{s: s}
+}
+
+func (r *OutboxRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *OutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxEvent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	due := filter(r.s.outbox.events, func(e *domain.OutboxEvent) bool {
+		return e.Status == domain.OutboxStatusPending && !e.NextAttemptAt.After(now)
+	})
+	return head(due, limit, cloneOutboxEvent), nil
+}
+
+func (r *OutboxRepository) PendingBefore(ctx context.Context, event *domain.OutboxEvent) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	earlier := find(r.s.outbox.events, func(e *domain.OutboxEvent) bool {
+		return e.AggregateType == event.AggregateType && e.AggregateID == event.AggregateID && e.Seq < event.Seq && e.Status == domain.OutboxStatusPending
+	})
+	return earlier != nil, nil
+}
+
+// MarkPublished also deletes events published longer ago than
+// publishedOutboxRetention, standing in for Mongo's TTL index.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	return r.updatePending(id, func(e *domain.OutboxEvent) {
+		e.Status, e.PublishedAt = domain.OutboxStatusPublished, at
+		r.s.outbox.events = slices.DeleteFunc(r.s.outbox.events, func(e *domain.OutboxEvent) bool {
+			return !e.PublishedAt.IsZero() && e.PublishedAt.Before(at.Add(-publishedOutboxRetention))
+		})
+	})
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, attempts int, lastError string, nextAttemptAt time.Time) error {
+	return r.updatePending(id, func(e *domain.OutboxEvent) {
+		e.Attempts, e.LastError, e.NextAttemptAt = attempts, lastError, nextAttemptAt
+	})
+}
+
+func (r *OutboxRepository) updatePending(id string, update func(*domain.OutboxEvent)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e := find(r.s.outbox.events, func(e *domain.OutboxEvent) bool { return e.ID == id && e.Status == domain.OutboxStatusPending })
+	if e == nil {
+		return domain.ErrEventNotFound
+	}
+	update(e)
+	return nil
+}
+
+func (r *OutboxRepository) DeadLetter(ctx context.Context, event *domain.OutboxEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	removed := remove(&r.s.outbox.events, func(e *domain.OutboxEvent) bool {
+		return e.ID == event.ID && e.Status == domain.OutboxStatusPending
+	})
+	if !removed {
+		return domain.ErrEventNotFound
+	}
+	r.s.outbox.deadLetters = append(r.s.outbox.deadLetters, cloneOutboxEvent(event))
+	return nil
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type passkeyTables struct {
+	passkeys   []*domain.Passkey
+	challenges []*domain.WebAuthnChallenge
+}
+
+func clonePasskey(p *domain.Passkey) *domain.Passkey {
+	c := *p
+	c.PublicKey = slices.Clone(p.PublicKey)
+	c.AAGUID = slices.Clone(p.AAGUID)
+	return &c
+}
+
+func cloneChallenge(ch *domain.WebAuthnChallenge) *domain.WebAuthnChallenge {
+	c := *ch
+	c.Challenge = slices.Clone(ch.Challenge)
+	return &c
+}
+
+// PasskeyRepository keeps credential IDs unique across users. Expired
+// challenges are dropped when the next one is created, standing in for
+// Mongo's TTL index.
+type PasskeyRepository struct {
+	s *Store
+}
+
+func NewPasskeyRepository(s *Store) *PasskeyRepository {
+	return &PasskeyRepository{s: s}
+}
+
+func (r *PasskeyRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *PasskeyRepository) Create(ctx context.Context, passkey *domain.Passkey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if find(r.s.passkeys.passkeys, func(p *domain.Passkey) bool { return p.CredentialID == passkey.CredentialID }) != nil {
+		return domain.ErrPasskeyExists
+	}
+	created := clonePasskey(passkey)
+	created.ID = r.s.newID()
+	r.s.passkeys.passkeys = append(r.s.passkeys.passkeys, created)
+	passkey.ID = created.ID
+	return nil
+}
+
+func (r *PasskeyRepository) GetByCredentialID(ctx context.Context, credentialID string) (*domain.Passkey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p := find(r.s.passkeys.passkeys, func(p *domain.Passkey) bool { return p.CredentialID == credentialID })
+	if p == nil {
+		return nil, domain.ErrPasskeyNotFound
+	}
+	return clonePasskey(p), nil
+}
+
+func (r *PasskeyRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Passkey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	owned := filter(r.s.passkeys.passkeys, func(p *domain.Passkey) bool { return p.UserID == userID })
+	slices.SortStableFunc(owned, func(a, b *domain.Passkey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(owned, 0, clonePasskey), nil
+}
+
+func (r *PasskeyRepository) UpdateSignCount(ctx context.Context, id string, from, to uint32, at time.Time) error {
+	if !objectIDPattern.MatchString(id) {
+		return domain.ErrPasskeyNotFound
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p := find(r.s.passkeys.passkeys, func(p *domain.Passkey) bool { return p.ID == id && p.SignCount == from })
+	if p == nil {
+		return domain.ErrPasskeyInvalid
+	}
+	p.SignCount, p.LastUsedAt = to, at
+	return nil
+}
+
+func (r *PasskeyRepository) Delete(ctx context.Context, userID, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if !remove(&r.s.passkeys.passkeys, func(p *domain.Passkey) bool { return p.ID == id && p.UserID == userID }) {
+		return domain.ErrPasskeyNotFound
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.passkeys.challenges = slices.DeleteFunc(r.s.passkeys.challenges, func(c *domain.WebAuthnChallenge) bool {
+		return !c.ExpiresAt.After(challenge.CreatedAt)
+	})
+	created := cloneChallenge(challenge)
+	created.ID = r.s.newID()
+	r.s.passkeys.challenges = append(r.s.passkeys.challenges, created)
+	challenge.ID = created.ID
+	return nil
+}
+
+func (r *PasskeyRepository) ConsumeChallenge(ctx context.Context, id string, purpose domain.WebAuthnPurpose) (*domain.WebAuthnChallenge, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c := find(r.s.passkeys.challenges, func(c *domain.WebAuthnChallenge) bool { return c.ID == id && c.Purpose == purpose })
+	if c == nil {
+		return nil, domain.ErrChallengeNotFound
+	}
+	remove(&r.s.passkeys.challenges, func(o *domain.WebAuthnChallenge) bool { return o == c })
+	return cloneChallenge(c), nil
+}
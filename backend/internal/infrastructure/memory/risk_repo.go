@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"akiba/backend/internal/domain"
+)
+
+type riskTables struct {
+	decisions []*domain.RiskDecision
+}
+
+func cloneRiskDecision(d *domain.RiskDecision) *domain.RiskDecision {
+	c := *d
+	c.Factors = slices.Clone(d.Factors)
+	return &c
+}
+
+type RiskRepository struct {
+	s *Store
+}
+
+func NewRiskRepository(s *Store) *RiskRepository {
+	return &RiskRepository{s: s}
+}
+
+func (r *RiskRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *RiskRepository) Create(ctx context.Context, decision *domain.RiskDecision) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	created := cloneRiskDecision(decision)
+	created.ID = r.s.newID()
+	r.s.risk.decisions = append(r.s.risk.decisions, created)
+	decision.ID = created.ID
+	return nil
+}
+
+func (r *RiskRepository) GetByID(ctx context.Context, id string) (*domain.RiskDecision, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := find(r.s.risk.decisions, func(d *domain.RiskDecision) bool { return d.ID == id })
+	if d == nil {
+		return nil, domain.ErrRiskDecisionNotFound
+	}
+	return cloneRiskDecision(d), nil
+}
+
+func (r *RiskRepository) List(ctx context.Context, userID, decision string, limit int) ([]*domain.RiskDecision, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	decisions := filter(r.s.risk.decisions, func(d *domain.RiskDecision) bool {
+		return (userID == "" || d.UserID == userID) && (decision == "" || d.Decision == decision)
+	})
+	slices.SortStableFunc(decisions, func(a, b *domain.RiskDecision) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return head(decisions, limit, cloneRiskDecision), nil
+}
+
+func (r *RiskRepository) HasAllowedDevice(ctx context.Context, userID, deviceID string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	allowed := find(r.s.risk.decisions, func(d *domain.RiskDecision) bool {
+		return d.UserID == userID && d.DeviceID == deviceID && (d.Decision == "allow" || d.SteppedUp)
+	})
+	return allowed != nil, nil
+}
@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+type userTables struct {
+	users  []*domain.User
+	events []*domain.UserStatusEvent
+}
+
+func cloneUser(u *domain.User) *domain.User {
+	c := *u
+	return &c
+}
+
+func cloneStatusEvent(e *domain.UserStatusEvent) *domain.UserStatusEvent {
+	c := *e
+	c.EntryIDs = slices.Clone(e.EntryIDs)
+	return &c
+}
+
+// UserRepository keeps contact details in plaintext; there is no storage
+// at rest to protect. Sign-ups and status changes queue their events in
+// the Store's outbox.
+type UserRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) *UserRepository {
+	return &UserRepository{s: s}
+}
+
+func (r *UserRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	taken := find(r.s.users.users, func(u *domain.User) bool {
+		return u.EmailLower == user.EmailLower || u.UsernameLower == user.UsernameLower || (user.PhoneE164 != "" && u.PhoneE164 == user.PhoneE164)
+	})
+	if taken != nil {
+		return domain.ErrUserExists
+	}
+	created := cloneUser(user)
+	created.ID = r.s.newID()
+	r.s.users.users = append(r.s.users.users, created)
+	r.s.appendOutbox(domain.NewUserSignedUpEvent(created))
+	user.ID = created.ID
+	return nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.getOne(func(u *domain.User) bool { return u.ID == id })
+}
+
+func (r *UserRepository) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	if strings.Contains(login, "@") {
+		email := strings.ToLower(login)
+		return r.getOne(func(u *domain.User) bool { return u.EmailLower == email })
+	}
+	if strings.HasPrefix(login, "+") {
+		return r.getOne(func(u *domain.User) bool { return u.PhoneE164 == login })
+	}
+	username := strings.ToLower(login)
+	return r.getOne(func(u *domain.User) bool { return u.UsernameLower == username })
+}
+
+func (r *UserRepository) getOne(match func(*domain.User) bool) (*domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u := find(r.s.users.users, match)
+	if u == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return cloneUser(u), nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, passwordHash string, at time.Time) error {
+	return r.updateByID(id, func(u *domain.User) {
+		u.PasswordHash, u.UpdatedAt = passwordHash, at
+	})
+}
+
+func (r *UserRepository) SetPIN(ctx context.Context, id, pinHash string, at time.Time) error {
+	return r.updateByID(id, func(u *domain.User) {
+		u.PINHash, u.PINFailures, u.PINLockedUntil, u.UpdatedAt = pinHash, 0, time.Time{}, at
+	})
+}
+
+func (r *UserRepository) ClaimPINAttempt(ctx context.Context, id string, max int, now time.Time) (int, error) {
+	var attempts int
+	err := r.updateByID(id, func(u *domain.User) {
+		attempts = claimAttempt(&u.PINFailures, &u.PINLockedUntil, max, now)
+	})
+	if err == nil && attempts == 0 {
+		return 0, domain.ErrPINLocked
+	}
+	return attempts, err
+}
+
+func (r *UserRepository) ResetPINFailures(ctx context.Context, id string) error {
+	return r.updateByID(id, func(u *domain.User) { u.PINFailures = 0 })
+}
+
+func (r *UserRepository) LockPIN(ctx context.Context, id string, until time.Time) error {
+	return r.updateByID(id, func(u *domain.User) { u.PINLockedUntil = until })
+}
+
+func (r *UserRepository) SetTOTP(ctx context.Context, id, secret string, at time.Time) error {
+	return r.updateByID(id, func(u *domain.User) {
+		u.TOTPSecret, u.TOTPConfirmedAt, u.TOTPLastStep, u.TOTPFailures, u.TOTPLockedUntil, u.UpdatedAt = secret, time.Time{}, 0, 0, time.Time{}, at
+	})
+}
+
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id string, step int64, at time.Time) error {
+	replayed := false
+	err := r.updateByID(id, func(u *domain.User) {
+		if u.TOTPSecret == "" || step <= u.TOTPLastStep {
+			replayed = true
+			return
+		}
+		if u.TOTPConfirmedAt.IsZero() {
+			u.TOTPConfirmedAt = at
+		}
+		u.TOTPLastStep, u.TOTPFailures, u.UpdatedAt = step, 0, at
+	})
+	if err == nil && replayed {
+		return domain.ErrInvalidOTP
+	}
+	return err
+}
+
+func (r *UserRepository) ClaimTOTPAttempt(ctx context.Context, id string, max int, now time.Time) (int, error) {
+	var attempts int
+	err := r.updateByID(id, func(u *domain.User) {
+		attempts = claimAttempt(&u.TOTPFailures, &u.TOTPLockedUntil, max, now)
+	})
+	if err == nil && attempts == 0 {
+		return 0, domain.ErrTOTPLocked
+	}
+	return attempts, err
+}
+
+func (r *UserRepository) LockTOTP(ctx context.Context, id string, until time.Time) error {
+	return r.updateByID(id, func(u *domain.User) { u.TOTPLockedUntil = until })
+}
+
+func (r *UserRepository) Search(ctx context.Context, f domain.UserFilter) ([]*domain.User, error) {
+	match := func(*domain.User) bool { return true }
+	if q := strings.TrimSpace(f.Query); q != "" {
+		switch {
+		case objectIDPattern.MatchString(q):
+			match = func(u *domain.User) bool { return u.ID == q }
+		case strings.Contains(q, "@"):
+			email := strings.ToLower(q)
+			match = func(u *domain.User) bool { return u.EmailLower == email }
+		case strings.HasPrefix(q, "+"):
+			match = func(u *domain.User) bool { return u.PhoneE164 == q }
+		default:
+			prefix := strings.ToLower(q)
+			match = func(u *domain.User) bool { return strings.HasPrefix(u.UsernameLower, prefix) }
+		}
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	found := filter(newestFirst(r.s.users.users), func(u *domain.User) bool {
+		return match(u) && (f.Status == "" || u.Status == f.Status) && (f.Role == "" || u.Role == f.Role) && (f.KYCStatus == "" || u.KYCStatus == f.KYCStatus)
+	})
+	return head(found, f.Limit, cloneUser), nil
+}
+
+func (r *UserRepository) TransitionStatus(ctx context.Context, event *domain.UserStatusEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u := find(r.s.users.users, func(u *domain.User) bool { return u.ID == event.UserID })
+	if u == nil {
+		return domain.ErrUserNotFound
+	}
+	if u.Status != event.From {
+		return domain.ErrInvalidTransition
+	}
+	u.Status, u.UpdatedAt = event.To, event.CreatedAt
+	recorded := cloneStatusEvent(event)
+	recorded.ID = r.s.newID()
+	r.s.users.events = append(r.s.users.events, recorded)
+	r.s.appendOutbox(domain.NewUserStatusChangedEvent(recorded))
+	event.ID = recorded.ID
+	return nil
+}
+
+func (r *UserRepository) ListStatusEvents(ctx context.Context, userID string) ([]*domain.UserStatusEvent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	events := filter(r.s.users.events, func(e *domain.UserStatusEvent) bool { return e.UserID == userID })
+	slices.SortStableFunc(events, func(a, b *domain.UserStatusEvent) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return head(events, 0, cloneStatusEvent), nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, id string, role domain.UserRole, at time.Time) error {
+	return r.updateByID(id, func(u *domain.User) { u.Role, u.UpdatedAt = role, at })
+}
+
+func (r *UserRepository) SetKYC(ctx context.Context, id string, status domain.KYCStatus, note, reviewerID string, at time.Time) error {
+	return r.updateByID(id, func(u *domain.User) {
+		u.KYCStatus, u.KYCNote, u.KYCReviewedBy, u.KYCReviewedAt, u.UpdatedAt = status, note, reviewerID, at, at
+	})
+}
+
+func (r *UserRepository) MarkPhoneVerified(ctx context.Context, id string, at time.Time) error {
+	return r.updateByID(id, func(u *domain.User) { u.PhoneVerifiedAt, u.UpdatedAt = at, at })
+}
+
+// ReencryptPII has nothing to do: nothing is sealed in memory.
+func (r *UserRepository) ReencryptPII(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+// claimAttempt counts an attempt and returns the new count, or 0 if none is
+// allowed. A passed lockout is lifted with the count left at max, so one
+// more attempt is allowed before it locks again.
+func claimAttempt(failures *int, lockedUntil *time.Time, max int, now time.Time) int {
+	if now.Before(*lockedUntil) || (*failures >= max && lockedUntil.IsZero()) {
+		return 0
+	}
+	*failures, *lockedUntil = min(*failures+1, max), time.Time{}
+	return *failures
+}
+
+func (r *UserRepository) updateByID(id string, update func(*domain.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u := find(r.s.users.users, func(u *domain.User) bool { return u.ID == id })
+	if u == nil {
+		return domain.ErrUserNotFound
+	}
+	update(u)
+	return nil
+}
@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+// maxWebhookHistory caps the attempts kept on a delivery.
+const maxWebhookHistory = 50
+
+type webhookTables struct {
+	endpoints  []*domain.WebhookEndpoint
+	deliveries []*domain.WebhookDelivery
+}
+
+func cloneEndpoint(e *domain.WebhookEndpoint) *domain.WebhookEndpoint {
+	c := *e
+	c.EventTypes = slices.Clone(e.EventTypes)
+	c.Secrets = slices.Clone(e.Secrets)
+	return &c
+}
+
+func cloneDelivery(d *domain.WebhookDelivery) *domain.WebhookDelivery {
+	c := *d
+	c.Payload = slices.Clone(d.Payload)
+	c.History = slices.Clone(d.History)
+	return &c
+}
+
+// WebhookRepository allows one delivery per endpoint and event.
+type WebhookRepository struct {
+	s *Store
+}
+
+func NewWebhookRepository(s *Store) *WebhookRepository {
+	return &WebhookRepository{s: s}
+}
+
+func (r *WebhookRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	created := cloneEndpoint(endpoint)
+	created.ID = r.s.newID()
+	r.s.webhooks.endpoints = append(r.s.webhooks.endpoints, created)
+	endpoint.ID = created.ID
+	return nil
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, id string) (*domain.WebhookEndpoint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e := r.endpoint(id)
+	if e == nil {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return cloneEndpoint(e), nil
+}
+
+func (r *WebhookRepository) endpoint(id string) *domain.WebhookEndpoint {
+	return find(r.s.webhooks.endpoints, func(e *domain.WebhookEndpoint) bool { return e.ID == id })
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, clientID string) ([]*domain.WebhookEndpoint, error) {
+	return r.findEndpoints(func(e *domain.WebhookEndpoint) bool { return clientID == "" || e.ClientID == clientID })
+}
+
+func (r *WebhookRepository) ListSubscribed(ctx context.Context, eventType domain.EventType) ([]*domain.WebhookEndpoint, error) {
+	return r.findEndpoints(func(e *domain.WebhookEndpoint) bool {
+		return e.Status == domain.WebhookEndpointActive && (slices.Contains(e.EventTypes, eventType) || slices.Contains(e.EventTypes, domain.WebhookEventAll))
+	})
+}
+
+func (r *WebhookRepository) findEndpoints(match func(*domain.WebhookEndpoint) bool) ([]*domain.WebhookEndpoint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return head(filter(newestFirst(r.s.webhooks.endpoints), match), 0, cloneEndpoint), nil
+}
+
+func (r *WebhookRepository) SetSecrets(ctx context.Context, id string, secrets []domain.WebhookSecret, at time.Time) error {
+	secrets = slices.Clone(secrets)
+	return r.updateEndpoint(id, func(e *domain.WebhookEndpoint) { e.Secrets, e.UpdatedAt = secrets, at })
+}
+
+func (r *WebhookRepository) SetEndpointStatus(ctx context.Context, id string, status domain.WebhookEndpointStatus, at time.Time) error {
+	return r.updateEndpoint(id, func(e *domain.WebhookEndpoint) { e.Status, e.UpdatedAt = status, at })
+}
+
+func (r *WebhookRepository) updateEndpoint(id string, update func(*domain.WebhookEndpoint)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e := r.endpoint(id)
+	if e == nil {
+		return domain.ErrWebhookNotFound
+	}
+	update(e)
+	return nil
+}
+
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	taken := find(r.s.webhooks.deliveries, func(d *domain.WebhookDelivery) bool {
+		return d.EndpointID == delivery.EndpointID && d.EventID == delivery.EventID
+	})
+	if taken != nil {
+		return domain.ErrDuplicateEntry
+	}
+	created := cloneDelivery(delivery)
+	created.ID, created.History = r.s.newID(), nil
+	r.s.webhooks.deliveries = append(r.s.webhooks.deliveries, created)
+	delivery.ID = created.ID
+	return nil
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := r.delivery(id)
+	if d == nil {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	return cloneDelivery(d), nil
+}
+
+func (r *WebhookRepository) delivery(id string) *domain.WebhookDelivery {
+	return find(r.s.webhooks.deliveries, func(d *domain.WebhookDelivery) bool { return d.ID == id })
+}
+
+func (r *WebhookRepository) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	due := filter(r.s.webhooks.deliveries, func(d *domain.WebhookDelivery) bool {
+		return d.Status == domain.WebhookDeliveryPending && !d.NextAttemptAt.After(now)
+	})
+	slices.SortStableFunc(due, func(a, b *domain.WebhookDelivery) int { return a.NextAttemptAt.Compare(b.NextAttemptAt) })
+	return head(due, limit, cloneDelivery), nil
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	deliveries := filter(newestFirst(r.s.webhooks.deliveries), func(d *domain.WebhookDelivery) bool { return d.EndpointID == endpointID })
+	return head(deliveries, limit, cloneDelivery), nil
+}
+
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, id string, attempt domain.WebhookAttempt, status domain.WebhookDeliveryStatus, attempts int, nextAttemptAt time.Time) error {
+	return r.updateDelivery(id, func(d *domain.WebhookDelivery) {
+		d.History = append(d.History, attempt)
+		if n := len(d.History); n > maxWebhookHistory {
+			d.History = d.History[n-maxWebhookHistory:]
+		}
+		d.Status, d.Attempts, d.NextAttemptAt, d.UpdatedAt = status, attempts, nextAttemptAt, attempt.At
+	})
+}
+
+func (r *WebhookRepository) Requeue(ctx context.Context, id string, at time.Time) error {
+	return r.updateDelivery(id, func(d *domain.WebhookDelivery) {
+		d.Status, d.Attempts, d.NextAttemptAt, d.UpdatedAt = domain.WebhookDeliveryPending, 0, at, at
+	})
+}
+
+func (r *WebhookRepository) updateDelivery(id string, update func(*domain.WebhookDelivery)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	d := r.delivery(id)
+	if d == nil {
+		return domain.ErrDeliveryNotFound
+	}
+	update(d)
+	return nil
+}
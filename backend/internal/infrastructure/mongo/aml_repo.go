@@ -0,0 +1,205 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// monitorCursorID names the single cursor document of the ledger monitor.
+const monitorCursorID = "ledger"
+
+type AMLRepository struct {
+	alerts  *mongo.Collection
+	cursors *mongo.Collection
+	timeout time.Duration
+}
+
+// alertDoc carries an Active flag, set while unresolved, so a partial
+// unique index can allow only one live alert per rule and subject.
+type alertDoc struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	RuleID         string             `bson:"ruleId"`
+	RuleName       string             `bson:"ruleName"`
+	Severity       string             `bson:"severity"`
+	SubjectKey     string             `bson:"subjectKey"`
+	OwnerID        string             `bson:"ownerId"`
+	AccountID      string             `bson:"accountId,omitempty"`
+	Currency       string             `bson:"currency"`
+	Value          int64              `bson:"value"`
+	Threshold      int64              `bson:"threshold"`
+	TriggerEntryID string             `bson:"triggerEntryId"`
+	EntryIDs       []string           `bson:"entryIds"`
+	Status         domain.AlertStatus `bson:"status"`
+	Active         bool               `bson:"active"`
+	AssignedTo     string             `bson:"assignedTo,omitempty"`
+	ResolutionNote string             `bson:"resolutionNote,omitempty"`
+	ResolvedBy     string             `bson:"resolvedBy,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt"`
+	ResolvedAt     time.Time          `bson:"resolvedAt,omitempty"`
+}
+
+func (d alertDoc) toDomain() *domain.AMLAlert {
+	return &domain.AMLAlert{ID: d.ID.Hex(), RuleID: d.RuleID, RuleName: d.RuleName, Severity: d.Severity, SubjectKey: d.SubjectKey, OwnerID: d.OwnerID, AccountID: d.AccountID, Currency: d.Currency, Value: d.Value, Threshold: d.Threshold, TriggerEntryID: d.TriggerEntryID, EntryIDs: d.EntryIDs, Status: d.Status, AssignedTo: d.AssignedTo, ResolutionNote: d.ResolutionNote, ResolvedBy: d.ResolvedBy, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC(), ResolvedAt: d.ResolvedAt.UTC()}
+}
+
+type cursorDoc struct {
+	ID        string    `bson:"_id"`
+	At        time.Time `bson:"at"`
+	EntryID   string    `bson:"entryId"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func NewAMLRepository(db *mongo.Database, timeout time.Duration) *AMLRepository {
+	return &AMLRepository{alerts: db.Collection("aml_alerts"), cursors: db.Collection("aml_cursors"), timeout: timeout}
+}
+
+func (r *AMLRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.alerts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ruleId", Value: 1}, {Key: "subjectKey", Value: 1}}, Options: options.Index().SetName("uniq_active_rule_subject").SetUnique(true).SetPartialFilterExpression(bson.M{"active": true})},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("idx_status_createdAt")},
+	})
+	return err
+}
+
+func (r *AMLRepository) CreateAlert(ctx context.Context, alert *domain.AMLAlert) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := alertDoc{RuleID: alert.RuleID, RuleName: alert.RuleName, Severity: alert.Severity, SubjectKey: alert.SubjectKey, OwnerID: alert.OwnerID, AccountID: alert.AccountID, Currency: alert.Currency, Value: alert.Value, Threshold: alert.Threshold, TriggerEntryID: alert.TriggerEntryID, EntryIDs: alert.EntryIDs, Status: alert.Status, Active: !alert.Status.Closed(), CreatedAt: alert.CreatedAt, UpdatedAt: alert.UpdatedAt}
+	res, err := r.alerts.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrAlertExists
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	alert.ID = id.Hex()
+	return nil
+}
+
+func (r *AMLRepository) GetAlert(ctx context.Context, id string) (*domain.AMLAlert, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": objID})
+}
+
+func (r *AMLRepository) GetOpenAlert(ctx context.Context, ruleID, subjectKey string) (*domain.AMLAlert, error) {
+	return r.findOne(ctx, bson.M{"ruleId": ruleID, "subjectKey": subjectKey, "active": true})
+}
+
+func (r *AMLRepository) findOne(ctx context.Context, filter bson.M) (*domain.AMLAlert, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out alertDoc
+	err := r.alerts.FindOne(cctx, filter).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrAlertNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *AMLRepository) ListAlerts(ctx context.Context, status domain.AlertStatus) ([]*domain.AMLAlert, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.alerts.Find(cctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []alertDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.AMLAlert, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *AMLRepository) AddAlertEntries(ctx context.Context, id string, entryIDs []string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrAlertNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	update := bson.M{"$addToSet": bson.M{"entryIds": bson.M{"$each": entryIDs}}, "$set": bson.M{"updatedAt": at}}
+	res, err := r.alerts.UpdateOne(cctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrAlertNotFound
+	}
+	return nil
+}
+
+func (r *AMLRepository) TransitionAlert(ctx context.Context, alert *domain.AMLAlert, from domain.AlertStatus) error {
+	objID, err := primitive.ObjectIDFromHex(alert.ID)
+	if err != nil {
+		return domain.ErrAlertNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	set := bson.M{
+		"status":         alert.Status,
+		"active":         !alert.Status.Closed(),
+		"assignedTo":     alert.AssignedTo,
+		"resolutionNote": alert.ResolutionNote,
+		"resolvedBy":     alert.ResolvedBy,
+		"resolvedAt":     alert.ResolvedAt,
+		"updatedAt":      alert.UpdatedAt,
+	}
+	res, err := r.alerts.UpdateOne(cctx, bson.M{"_id": objID, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrInvalidTransition
+	}
+	return nil
+}
+
+func (r *AMLRepository) GetCursor(ctx context.Context) (time.Time, string, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out cursorDoc
+	err := r.cursors.FindOne(cctx, bson.M{"_id": monitorCursorID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, "", nil
+	}
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return out.At.UTC(), out.EntryID, nil
+}
+
+func (r *AMLRepository) SaveCursor(ctx context.Context, at time.Time, entryID string) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := cursorDoc{ID: monitorCursorID, At: at, EntryID: entryID, UpdatedAt: time.Now().UTC()}
+	_, err := r.cursors.ReplaceOne(cctx, bson.M{"_id": monitorCursorID}, doc, options.Replace().SetUpsert(true))
+	return err
+}
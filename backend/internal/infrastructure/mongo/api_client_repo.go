@@ -0,0 +1,189 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type APIClientRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type apiKeyDoc struct {
+	Prefix     string    `bson:"prefix"`
+	Hash       string    `bson:"hash"`
+	CreatedAt  time.Time `bson:"createdAt"`
+	LastUsedAt time.Time `bson:"lastUsedAt,omitempty"`
+	RevokedAt  time.Time `bson:"revokedAt,omitempty"`
+}
+
+type apiClientDoc struct {
+	ID              primitive.ObjectID     `bson:"_id,omitempty"`
+	Name            string                 `bson:"name"`
+	Scopes          []domain.Scope         `bson:"scopes"`
+	AllowedNetworks []string               `bson:"allowedNetworks,omitempty"`
+	RedirectURIs    []string               `bson:"redirectUris,omitempty"`
+	Public          bool                   `bson:"public,omitempty"`
+	Keys            []apiKeyDoc            `bson:"keys"`
+	Status          domain.APIClientStatus `bson:"status"`
+	CreatedBy       string                 `bson:"createdBy"`
+	CreatedAt       time.Time              `bson:"createdAt"`
+	UpdatedAt       time.Time              `bson:"updatedAt"`
+}
+
+func (d apiClientDoc) toDomain() *domain.APIClient {
+	keys := make([]domain.APIKey, 0, len(d.Keys))
+	for _, k := range d.Keys {
+		key := domain.APIKey{Prefix: k.Prefix, Hash: k.Hash, CreatedAt: k.CreatedAt.UTC()}
+		if !k.LastUsedAt.IsZero() {
+			key.LastUsedAt = k.LastUsedAt.UTC()
+		}
+		if !k.RevokedAt.IsZero() {
+			key.RevokedAt = k.RevokedAt.UTC()
+		}
+		keys = append(keys, key)
+	}
+	var networks []netip.Prefix
+	for _, n := range d.AllowedNetworks {
+		if p, err := netip.ParsePrefix(n); err == nil {
+			networks = append(networks, p)
+		}
+	}
+	return &domain.APIClient{ID: d.ID.Hex(), Name: d.Name, Scopes: d.Scopes, AllowedNetworks: networks, RedirectURIs: d.RedirectURIs, Public: d.Public, Keys: keys, Status: d.Status, CreatedBy: d.CreatedBy, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+func apiKeyToDoc(k domain.APIKey) apiKeyDoc {
+	return apiKeyDoc{Prefix: k.Prefix, Hash: k.Hash, CreatedAt: k.CreatedAt, LastUsedAt: k.LastUsedAt, RevokedAt: k.RevokedAt}
+}
+
+func NewAPIClientRepository(db *mongo.Database, timeout time.Duration) *APIClientRepository {
+	return &APIClientRepository{collection: db.Collection("api_clients"), timeout: timeout}
+}
+
+func (r *APIClientRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "keys.prefix", Value: 1}}, Options: options.Index().SetName("uniq_keys_prefix").SetUnique(true).SetSparse(true)})
+	return err
+}
+
+func (r *APIClientRepository) Create(ctx context.Context, c *domain.APIClient) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	keys := make([]apiKeyDoc, 0, len(c.Keys))
+	for _, k := range c.Keys {
+		keys = append(keys, apiKeyToDoc(k))
+	}
+	networks := make([]string, 0, len(c.AllowedNetworks))
+	for _, n := range c.AllowedNetworks {
+		networks = append(networks, n.String())
+	}
+	doc := apiClientDoc{Name: c.Name, Scopes: c.Scopes, AllowedNetworks: networks, RedirectURIs: c.RedirectURIs, Public: c.Public, Keys: keys, Status: c.Status, CreatedBy: c.CreatedBy, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt}
+	res, err := r.collection.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	c.ID = id.Hex()
+	return nil
+}
+
+func (r *APIClientRepository) GetByID(ctx context.Context, id string) (*domain.APIClient, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrAPIClientNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": objID})
+}
+
+func (r *APIClientRepository) GetByKeyPrefix(ctx context.Context, prefix string) (*domain.APIClient, error) {
+	return r.findOne(ctx, bson.M{"keys.prefix": prefix})
+}
+
+func (r *APIClientRepository) findOne(ctx context.Context, filter bson.M) (*domain.APIClient, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out apiClientDoc
+	err := r.collection.FindOne(cctx, filter).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrAPIClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *APIClientRepository) List(ctx context.Context) ([]*domain.APIClient, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.collection.Find(cctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []apiClientDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.APIClient, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *APIClientRepository) AddKey(ctx context.Context, id string, key domain.APIKey) error {
+	return r.update(ctx, id, nil, bson.M{"$push": bson.M{"keys": apiKeyToDoc(key)}, "$set": bson.M{"updatedAt": key.CreatedAt}})
+}
+
+func (r *APIClientRepository) RevokeKey(ctx context.Context, id, prefix string, at time.Time) error {
+	return r.update(ctx, id, bson.M{"keys": bson.M{"$elemMatch": bson.M{"prefix": prefix, "revokedAt": bson.M{"$exists": false}}}}, bson.M{"$set": bson.M{"keys.$.revokedAt": at, "updatedAt": at}})
+}
+
+func (r *APIClientRepository) TouchKey(ctx context.Context, id, prefix string, at time.Time) error {
+	return r.update(ctx, id, bson.M{"keys.prefix": prefix}, bson.M{"$set": bson.M{"keys.$.lastUsedAt": at}})
+}
+
+func (r *APIClientRepository) SetStatus(ctx context.Context, id string, status domain.APIClientStatus, at time.Time) error {
+	return r.update(ctx, id, nil, bson.M{"$set": bson.M{"status": status, "updatedAt": at}})
+}
+
+// update applies update to client id when it also matches extra.
+func (r *APIClientRepository) update(ctx context.Context, id string, extra bson.M, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrAPIClientNotFound
+	}
+	filter := bson.M{"_id": objID}
+	for k, v := range extra {
+		filter[k] = v
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.collection.UpdateOne(cctx, filter, update)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrAPIClientNotFound
+	}
+	return nil
+}
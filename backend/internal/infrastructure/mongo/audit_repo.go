@@ -0,0 +1,130 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAuditAppendAttempts bounds how often Append re-seals after losing the
+// race for a sequence number.
+const maxAuditAppendAttempts = 10
+
+type AuditRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+// auditDoc is keyed by the sequence number, so the primary key rejects a
+// second record claiming the same place in the chain.
+type auditDoc struct {
+	Seq        int64              `bson:"_id"`
+	Action     domain.AuditAction `bson:"action"`
+	ActorID    string             `bson:"actorId,omitempty"`
+	TargetType string             `bson:"targetType,omitempty"`
+	TargetID   string             `bson:"targetId,omitempty"`
+	IP         string             `bson:"ip,omitempty"`
+	UserAgent  string             `bson:"userAgent,omitempty"`
+	RequestID  string             `bson:"requestId,omitempty"`
+	Details    map[string]string  `bson:"details,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	PrevHash   string             `bson:"prevHash"`
+	Hash       string             `bson:"hash"`
+}
+
+func (d auditDoc) toDomain() *domain.AuditRecord {
+	return &domain.AuditRecord{Seq: d.Seq, Action: d.Action, ActorID: d.ActorID, TargetType: d.TargetType, TargetID: d.TargetID, IP: d.IP, UserAgent: d.UserAgent, RequestID: d.RequestID, Details: d.Details, CreatedAt: d.CreatedAt.UTC(), PrevHash: d.PrevHash, Hash: d.Hash}
+}
+
+func NewAuditRepository(db *mongo.Database, timeout time.Duration) *AuditRepository {
+	return &AuditRepository{collection: db.Collection("audit_log"), timeout: timeout}
+}
+
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("actorId_seq")},
+		{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("targetId_seq")},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("action_seq")},
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}, Options: options.Index().SetName("createdAt")},
+	})
+	return err
+}
+
+func (r *AuditRepository) Append(ctx context.Context, record *domain.AuditRecord) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	for attempt := 0; attempt < maxAuditAppendAttempts; attempt++ {
+		var head *domain.AuditRecord
+		var last auditDoc
+		err := r.collection.FindOne(cctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&last)
+		switch {
+		case err == nil:
+			head = last.toDomain()
+		case !errors.Is(err, mongo.ErrNoDocuments):
+			return err
+		}
+		record.Seal(head)
+		doc := auditDoc{Seq: record.Seq, Action: record.Action, ActorID: record.ActorID, TargetType: record.TargetType, TargetID: record.TargetID, IP: record.IP, UserAgent: record.UserAgent, RequestID: record.RequestID, Details: record.Details, CreatedAt: record.CreatedAt, PrevHash: record.PrevHash, Hash: record.Hash}
+		_, err = r.collection.InsertOne(cctx, doc)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("audit append: lost the race for a sequence number %d times", maxAuditAppendAttempts)
+}
+
+func (r *AuditRepository) Search(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditRecord, error) {
+	filter := bson.M{}
+	if f.ActorID != "" {
+		filter["actorId"] = f.ActorID
+	}
+	if f.TargetID != "" {
+		filter["targetId"] = f.TargetID
+	}
+	if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		window := bson.M{}
+		if !f.From.IsZero() {
+			window["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			window["$lt"] = f.To
+		}
+		filter["createdAt"] = window
+	}
+	return r.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(f.Limit)))
+}
+
+func (r *AuditRepository) ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*domain.AuditRecord, error) {
+	return r.find(ctx, bson.M{"_id": bson.M{"$gt": afterSeq}}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (r *AuditRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*domain.AuditRecord, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.collection.Find(cctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []auditDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.AuditRecord, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
@@ -0,0 +1,149 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BeneficiaryRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+// beneficiaryDoc stores the destination key and lower-cased nickname so
+// unique indexes can reject duplicates per owner.
+type beneficiaryDoc struct {
+	ID              primitive.ObjectID             `bson:"_id,omitempty"`
+	OwnerID         string                         `bson:"ownerId"`
+	Type            domain.BeneficiaryType         `bson:"type"`
+	Nickname        string                         `bson:"nickname"`
+	NicknameLower   string                         `bson:"nicknameLower"`
+	DestinationKey  string                         `bson:"destinationKey"`
+	RecipientID     string                         `bson:"recipientId,omitempty"`
+	Phone           string                         `bson:"phone,omitempty"`
+	BankCode        string                         `bson:"bankCode,omitempty"`
+	AccountNumber   string                         `bson:"accountNumber,omitempty"`
+	AccountName     string                         `bson:"accountName,omitempty"`
+	Verification    domain.BeneficiaryVerification `bson:"verification"`
+	CoolingOffUntil time.Time                      `bson:"coolingOffUntil"`
+	CreatedAt       time.Time                      `bson:"createdAt"`
+	UpdatedAt       time.Time                      `bson:"updatedAt"`
+}
+
+func (d beneficiaryDoc) toDomain() *domain.Beneficiary {
+	return &domain.Beneficiary{ID: d.ID.Hex(), OwnerID: d.OwnerID, Type: d.Type, Nickname: d.Nickname, RecipientID: d.RecipientID, Phone: d.Phone, BankCode: d.BankCode, AccountNumber: d.AccountNumber, AccountName: d.AccountName, Verification: d.Verification, CoolingOffUntil: d.CoolingOffUntil.UTC(), CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+func NewBeneficiaryRepository(db *mongo.Database, timeout time.Duration) *BeneficiaryRepository {
+	return &BeneficiaryRepository{collection: db.Collection("beneficiaries"), timeout: timeout}
+}
+
+func (r *BeneficiaryRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "destinationKey", Value: 1}}, Options: options.Index().SetName("uniq_owner_destination").SetUnique(true)},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "nicknameLower", Value: 1}}, Options: options.Index().SetName("uniq_owner_nickname").SetUnique(true)},
+	})
+	return err
+}
+
+func (r *BeneficiaryRepository) Create(ctx context.Context, b *domain.Beneficiary) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := beneficiaryDoc{OwnerID: b.OwnerID, Type: b.Type, Nickname: b.Nickname, NicknameLower: strings.ToLower(b.Nickname), DestinationKey: b.DestinationKey(), RecipientID: b.RecipientID, Phone: b.Phone, BankCode: b.BankCode, AccountNumber: b.AccountNumber, AccountName: b.AccountName, Verification: b.Verification, CoolingOffUntil: b.CoolingOffUntil, CreatedAt: b.CreatedAt, UpdatedAt: b.UpdatedAt}
+	res, err := r.collection.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrBeneficiaryExists
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	b.ID = id.Hex()
+	return nil
+}
+
+func (r *BeneficiaryRepository) GetByID(ctx context.Context, id string) (*domain.Beneficiary, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrBeneficiaryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out beneficiaryDoc
+	err = r.collection.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrBeneficiaryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *BeneficiaryRepository) ListByOwner(ctx context.Context, ownerID string) ([]*domain.Beneficiary, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.collection.Find(cctx, bson.M{"ownerId": ownerID}, options.Find().SetSort(bson.D{{Key: "nicknameLower", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []beneficiaryDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Beneficiary, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *BeneficiaryRepository) UpdateNickname(ctx context.Context, id, nickname string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrBeneficiaryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.collection.UpdateOne(cctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"nickname": nickname, "nicknameLower": strings.ToLower(nickname), "updatedAt": at}})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrBeneficiaryExists
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrBeneficiaryNotFound
+	}
+	return nil
+}
+
+func (r *BeneficiaryRepository) Delete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrBeneficiaryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.collection.DeleteOne(cctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrBeneficiaryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/repository/repositorytest"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestOutboxRepositoryContract(t *testing.T) {
+	repositorytest.TestOutboxRepository(t, func(t *testing.T) (repository.OutboxRepository, repository.UserRepository) {
+		db := testDB(t)
+		return ensured(t, NewOutboxRepository(db, testTimeout)), ensured(t, NewUserRepository(db, testTimeout, testCipher(t)))
+	})
+}
+
+func TestLedgerRepositoryContract(t *testing.T) {
+	repositorytest.TestLedgerRepository(t, func(t *testing.T) repository.LedgerRepository {
+		return ensured(t, NewLedgerRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestAMLRepositoryContract(t *testing.T) {
+	repositorytest.TestAMLRepository(t, func(t *testing.T) repository.AMLRepository {
+		return ensured(t, NewAMLRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestAPIClientRepositoryContract(t *testing.T) {
+	repositorytest.TestAPIClientRepository(t, func(t *testing.T) repository.APIClientRepository {
+		return ensured(t, NewAPIClientRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestAuditRepositoryContract(t *testing.T) {
+	repositorytest.TestAuditRepository(t, func(t *testing.T) repository.AuditRepository {
+		return ensured(t, NewAuditRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestBeneficiaryRepositoryContract(t *testing.T) {
+	repositorytest.TestBeneficiaryRepository(t, func(t *testing.T) repository.BeneficiaryRepository {
+		return ensured(t, NewBeneficiaryRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestDeviceRepositoryContract(t *testing.T) {
+	repositorytest.TestDeviceRepository(t, func(t *testing.T) repository.DeviceRepository {
+		return ensured(t, NewDeviceRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestDisputeRepositoryContract(t *testing.T) {
+	repositorytest.TestDisputeRepository(t, func(t *testing.T) repository.DisputeRepository {
+		return ensured(t, NewDisputeRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestFXQuoteRepositoryContract(t *testing.T) {
+	repositorytest.TestFXQuoteRepository(t, func(t *testing.T) repository.FXQuoteRepository {
+		return ensured(t, NewFXQuoteRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestMerchantRepositoryContract(t *testing.T) {
+	repositorytest.TestMerchantRepository(t, func(t *testing.T) repository.MerchantRepository {
+		return ensured(t, NewMerchantRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestOAuthRepositoryContract(t *testing.T) {
+	repositorytest.TestOAuthRepository(t, func(t *testing.T) repository.OAuthRepository {
+		return ensured(t, NewOAuthRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestPasskeyRepositoryContract(t *testing.T) {
+	repositorytest.TestPasskeyRepository(t, func(t *testing.T) repository.PasskeyRepository {
+		return ensured(t, NewPasskeyRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestRiskRepositoryContract(t *testing.T) {
+	repositorytest.TestRiskRepository(t, func(t *testing.T) repository.RiskRepository {
+		return ensured(t, NewRiskRepository(testDB(t), testTimeout))
+	})
+}
+
+func TestWebhookRepositoryContract(t *testing.T) {
+	repositorytest.TestWebhookRepository(t, func(t *testing.T) repository.WebhookRepository {
+		return ensured(t, NewWebhookRepository(testDB(t), testTimeout))
+	})
+}
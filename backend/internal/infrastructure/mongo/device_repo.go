@@ -0,0 +1,249 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DeviceRepository struct {
+	devices    *mongo.Collection
+	otps       *mongo.Collection
+	assertions *mongo.Collection
+	timeout    time.Duration
+}
+
+type deviceDoc struct {
+	ID          primitive.ObjectID    `bson:"_id,omitempty"`
+	UserID      string                `bson:"userId"`
+	DeviceID    string                `bson:"deviceId"`
+	Platform    domain.DevicePlatform `bson:"platform"`
+	PublicKey   string                `bson:"publicKey"`
+	PushToken   string                `bson:"pushToken,omitempty"`
+	Trusted     bool                  `bson:"trusted"`
+	FirstSeenAt time.Time             `bson:"firstSeenAt"`
+	LastSeenAt  time.Time             `bson:"lastSeenAt"`
+}
+
+func (d deviceDoc) toDomain() *domain.Device {
+	return &domain.Device{ID: d.ID.Hex(), UserID: d.UserID, DeviceID: d.DeviceID, Platform: d.Platform, PublicKey: d.PublicKey, PushToken: d.PushToken, Trusted: d.Trusted, FirstSeenAt: d.FirstSeenAt.UTC(), LastSeenAt: d.LastSeenAt.UTC()}
+}
+
+// otpDoc embeds the pending device; expired codes are removed by a TTL
+// index, and GetOTP callers still check expiresAt.
+type otpDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"userId"`
+	CodeHash  string             `bson:"codeHash"`
+	Device    *deviceDoc         `bson:"device,omitempty"`
+	Method    string             `bson:"method,omitempty"`
+	Attempts  int                `bson:"attempts"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+func (d otpDoc) toDomain() *domain.OTPChallenge {
+	out := &domain.OTPChallenge{ID: d.ID.Hex(), UserID: d.UserID, CodeHash: d.CodeHash, Method: d.Method, Attempts: d.Attempts, ExpiresAt: d.ExpiresAt.UTC(), CreatedAt: d.CreatedAt.UTC()}
+	if d.Device != nil {
+		out.Device = d.Device.toDomain()
+		out.Device.ID = ""
+	}
+	return out
+}
+
+func NewDeviceRepository(db *mongo.Database, timeout time.Duration) *DeviceRepository {
+	return &DeviceRepository{devices: db.Collection("devices"), otps: db.Collection("otp_challenges"), assertions: db.Collection("device_assertions"), timeout: timeout}
+}
+
+func (r *DeviceRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.devices.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "deviceId", Value: 1}}, Options: options.Index().SetName("uniq_userId_deviceId").SetUnique(true)})
+	if err != nil {
+		return err
+	}
+	_, err = r.otps.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetName("ttl_expiresAt").SetExpireAfterSeconds(0)})
+	if err != nil {
+		return err
+	}
+	_, err = r.assertions.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetName("ttl_expiresAt").SetExpireAfterSeconds(0)})
+	return err
+}
+
+func (r *DeviceRepository) Upsert(ctx context.Context, d *domain.Device) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"userId": d.UserID, "deviceId": d.DeviceID}
+	update := bson.M{
+		"$set":         bson.M{"platform": d.Platform, "publicKey": d.PublicKey, "pushToken": d.PushToken, "trusted": d.Trusted, "lastSeenAt": d.LastSeenAt},
+		"$setOnInsert": bson.M{"firstSeenAt": d.FirstSeenAt},
+	}
+	var out deviceDoc
+	err := r.devices.FindOneAndUpdate(cctx, filter, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&out)
+	if err != nil {
+		return err
+	}
+	d.ID, d.FirstSeenAt = out.ID.Hex(), out.FirstSeenAt.UTC()
+	return nil
+}
+
+func (r *DeviceRepository) Get(ctx context.Context, userID, deviceID string) (*domain.Device, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out deviceDoc
+	err := r.devices.FindOne(cctx, bson.M{"userId": userID, "deviceId": deviceID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *DeviceRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Device, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.devices.Find(cctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []deviceDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Device, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *DeviceRepository) Touch(ctx context.Context, id, pushToken string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrDeviceNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	set := bson.M{"lastSeenAt": at}
+	if pushToken != "" {
+		set["pushToken"] = pushToken
+	}
+	res, err := r.devices.UpdateOne(cctx, bson.M{"_id": objID}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *DeviceRepository) Delete(ctx context.Context, userID, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrDeviceNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.devices.DeleteOne(cctx, bson.M{"_id": objID, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *DeviceRepository) CreateOTP(ctx context.Context, c *domain.OTPChallenge) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := otpDoc{UserID: c.UserID, CodeHash: c.CodeHash, Method: c.Method, Attempts: c.Attempts, ExpiresAt: c.ExpiresAt, CreatedAt: c.CreatedAt}
+	if d := c.Device; d != nil {
+		doc.Device = &deviceDoc{UserID: d.UserID, DeviceID: d.DeviceID, Platform: d.Platform, PublicKey: d.PublicKey, PushToken: d.PushToken, Trusted: d.Trusted, FirstSeenAt: d.FirstSeenAt, LastSeenAt: d.LastSeenAt}
+	}
+	res, err := r.otps.InsertOne(cctx, doc)
+	if err != nil {
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	c.ID = id.Hex()
+	return nil
+}
+
+func (r *DeviceRepository) GetOTP(ctx context.Context, id string) (*domain.OTPChallenge, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrChallengeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out otpDoc
+	err = r.otps.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *DeviceRepository) RecordOTPFailure(ctx context.Context, id string) (int, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, domain.ErrChallengeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out otpDoc
+	err = r.otps.FindOneAndUpdate(cctx, bson.M{"_id": objID}, bson.M{"$inc": bson.M{"attempts": 1}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, domain.ErrChallengeNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return out.Attempts, nil
+}
+
+func (r *DeviceRepository) DeleteOTP(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrChallengeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.otps.DeleteOne(cctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrChallengeNotFound
+	}
+	return nil
+}
+
+// UseAssertion keys assertions by digest, so the unique _id index refuses a
+// replay even from another API instance. The TTL index may leave expired
+// ones for a while; by then their timestamps fail verification anyway.
+func (r *DeviceRepository) UseAssertion(ctx context.Context, digest string, at, expiresAt time.Time) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	_, err := r.assertions.InsertOne(cctx, bson.M{"_id": digest, "expiresAt": expiresAt, "createdAt": at})
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrAssertionReplayed
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DisputeRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type evidenceDoc struct {
+	ID          string    `bson:"id"`
+	SubmittedBy string    `bson:"submittedBy"`
+	Note        string    `bson:"note,omitempty"`
+	FileName    string    `bson:"fileName,omitempty"`
+	ContentType string    `bson:"contentType,omitempty"`
+	SHA256      string    `bson:"sha256,omitempty"`
+	Size        int       `bson:"size,omitempty"`
+	Data        []byte    `bson:"data,omitempty"`
+	CreatedAt   time.Time `bson:"createdAt"`
+}
+
+// disputeDoc carries an Active flag, set while unresolved, so a partial
+// unique index can allow only one live dispute per entry.
+type disputeDoc struct {
+	ID                primitive.ObjectID   `bson:"_id,omitempty"`
+	EntryID           string               `bson:"entryId"`
+	PayerID           string               `bson:"payerId"`
+	PayeeOwnerID      string               `bson:"payeeOwnerId"`
+	PayerAccountID    string               `bson:"payerAccountId"`
+	PayeeAccountID    string               `bson:"payeeAccountId"`
+	Currency          string               `bson:"currency"`
+	Amount            int64                `bson:"amount"`
+	HeldAmount        int64                `bson:"heldAmount"`
+	Reason            string               `bson:"reason"`
+	Status            domain.DisputeStatus `bson:"status"`
+	Active            bool                 `bson:"active"`
+	Evidence          []evidenceDoc        `bson:"evidence"`
+	HoldEntryID       string               `bson:"holdEntryId,omitempty"`
+	ResolutionEntryID string               `bson:"resolutionEntryId,omitempty"`
+	ResolutionNote    string               `bson:"resolutionNote,omitempty"`
+	ResolvedBy        string               `bson:"resolvedBy,omitempty"`
+	CreatedAt         time.Time            `bson:"createdAt"`
+	UpdatedAt         time.Time            `bson:"updatedAt"`
+	ResolvedAt        time.Time            `bson:"resolvedAt,omitempty"`
+}
+
+func (d disputeDoc) toDomain() *domain.Dispute {
+	evidence := make([]domain.Evidence, 0, len(d.Evidence))
+	for _, e := range d.Evidence {
+		evidence = append(evidence, domain.Evidence{ID: e.ID, SubmittedBy: e.SubmittedBy, Note: e.Note, FileName: e.FileName, ContentType: e.ContentType, SHA256: e.SHA256, Size: e.Size, Data: e.Data, CreatedAt: e.CreatedAt.UTC()})
+	}
+	return &domain.Dispute{ID: d.ID.Hex(), EntryID: d.EntryID, PayerID: d.PayerID, PayeeOwnerID: d.PayeeOwnerID, PayerAccountID: d.PayerAccountID, PayeeAccountID: d.PayeeAccountID, Currency: d.Currency, Amount: d.Amount, HeldAmount: d.HeldAmount, Reason: d.Reason, Status: d.Status, Evidence: evidence, HoldEntryID: d.HoldEntryID, ResolutionEntryID: d.ResolutionEntryID, ResolutionNote: d.ResolutionNote, ResolvedBy: d.ResolvedBy, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC(), ResolvedAt: d.ResolvedAt.UTC()}
+}
+
+func NewDisputeRepository(db *mongo.Database, timeout time.Duration) *DisputeRepository {
+	return &DisputeRepository{collection: db.Collection("disputes"), timeout: timeout}
+}
+
+func (r *DisputeRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "entryId", Value: 1}}, Options: options.Index().SetName("uniq_active_entryId").SetUnique(true).SetPartialFilterExpression(bson.M{"active": true})},
+		{Keys: bson.D{{Key: "payerId", Value: 1}}, Options: options.Index().SetName("idx_payerId")},
+		{Keys: bson.D{{Key: "payeeOwnerId", Value: 1}}, Options: options.Index().SetName("idx_payeeOwnerId")},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("idx_status_createdAt")},
+	})
+	return err
+}
+
+func (r *DisputeRepository) Create(ctx context.Context, dispute *domain.Dispute) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := disputeDoc{EntryID: dispute.EntryID, PayerID: dispute.PayerID, PayeeOwnerID: dispute.PayeeOwnerID, PayerAccountID: dispute.PayerAccountID, PayeeAccountID: dispute.PayeeAccountID, Currency: dispute.Currency, Amount: dispute.Amount, Reason: dispute.Reason, Status: dispute.Status, Active: !dispute.Status.Resolved(), Evidence: []evidenceDoc{}, CreatedAt: dispute.CreatedAt, UpdatedAt: dispute.UpdatedAt}
+	res, err := r.collection.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDisputeExists
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	dispute.ID = id.Hex()
+	return nil
+}
+
+func (r *DisputeRepository) GetByID(ctx context.Context, id string) (*domain.Dispute, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrDisputeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out disputeDoc
+	err = r.collection.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *DisputeRepository) GetActiveByEntry(ctx context.Context, entryID string) (*domain.Dispute, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out disputeDoc
+	err := r.collection.FindOne(cctx, bson.M{"entryId": entryID, "active": true}, options.FindOne().SetProjection(bson.M{"evidence.data": 0})).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *DisputeRepository) ListByParty(ctx context.Context, userID string) ([]*domain.Dispute, error) {
+	return r.find(ctx, bson.M{"$or": bson.A{bson.M{"payerId": userID}, bson.M{"payeeOwnerId": userID}}})
+}
+
+func (r *DisputeRepository) ListByStatus(ctx context.Context, status domain.DisputeStatus) ([]*domain.Dispute, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *DisputeRepository) find(ctx context.Context, filter bson.M) ([]*domain.Dispute, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	// Attachments can be large; listings never need them.
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetProjection(bson.M{"evidence.data": 0})
+	cur, err := r.collection.Find(cctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []disputeDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Dispute, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *DisputeRepository) SetHold(ctx context.Context, id, entryID string, amount int64, at time.Time) error {
+	return r.update(ctx, id, bson.M{"$set": bson.M{"holdEntryId": entryID, "heldAmount": amount, "updatedAt": at}})
+}
+
+func (r *DisputeRepository) AddEvidence(ctx context.Context, id string, evidence domain.Evidence) error {
+	doc := evidenceDoc{ID: evidence.ID, SubmittedBy: evidence.SubmittedBy, Note: evidence.Note, FileName: evidence.FileName, ContentType: evidence.ContentType, SHA256: evidence.SHA256, Size: evidence.Size, Data: evidence.Data, CreatedAt: evidence.CreatedAt}
+	return r.update(ctx, id, bson.M{"$push": bson.M{"evidence": doc}, "$set": bson.M{"updatedAt": evidence.CreatedAt}})
+}
+
+func (r *DisputeRepository) update(ctx context.Context, id string, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrDisputeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.collection.UpdateOne(cctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrDisputeNotFound
+	}
+	return nil
+}
+
+func (r *DisputeRepository) Transition(ctx context.Context, dispute *domain.Dispute, from domain.DisputeStatus) error {
+	objID, err := primitive.ObjectIDFromHex(dispute.ID)
+	if err != nil {
+		return domain.ErrDisputeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	set := bson.M{
+		"status":            dispute.Status,
+		"active":            !dispute.Status.Resolved(),
+		"resolutionEntryId": dispute.ResolutionEntryID,
+		"resolutionNote":    dispute.ResolutionNote,
+		"resolvedBy":        dispute.ResolvedBy,
+		"resolvedAt":        dispute.ResolvedAt,
+		"updatedAt":         dispute.UpdatedAt,
+	}
+	res, err := r.collection.UpdateOne(cctx, bson.M{"_id": objID, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrInvalidTransition
+	}
+	return nil
+}
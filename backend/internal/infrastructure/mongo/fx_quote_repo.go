@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FXQuoteRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type fxQuoteDoc struct {
+	ID           primitive.ObjectID   `bson:"_id,omitempty"`
+	UserID       string               `bson:"userId"`
+	SellCurrency string               `bson:"sellCurrency"`
+	BuyCurrency  string               `bson:"buyCurrency"`
+	SellAmount   int64                `bson:"sellAmount"`
+	BuyAmount    int64                `bson:"buyAmount"`
+	Fee          int64                `bson:"fee"`
+	MidRate      string               `bson:"midRate"`
+	Rate         string               `bson:"rate"`
+	SpreadBps    int                  `bson:"spreadBps"`
+	Status       domain.FXQuoteStatus `bson:"status"`
+	EntryID      string               `bson:"entryId,omitempty"`
+	ExpiresAt    time.Time            `bson:"expiresAt"`
+	CreatedAt    time.Time            `bson:"createdAt"`
+	ExecutedAt   time.Time            `bson:"executedAt,omitempty"`
+}
+
+func (d fxQuoteDoc) toDomain() *domain.FXQuote {
+	return &domain.FXQuote{ID: d.ID.Hex(), UserID: d.UserID, SellCurrency: d.SellCurrency, BuyCurrency: d.BuyCurrency, SellAmount: d.SellAmount, BuyAmount: d.BuyAmount, Fee: d.Fee, MidRate: d.MidRate, Rate: d.Rate, SpreadBps: d.SpreadBps, Status: d.Status, EntryID: d.EntryID, ExpiresAt: d.ExpiresAt.UTC(), CreatedAt: d.CreatedAt.UTC(), ExecutedAt: d.ExecutedAt.UTC()}
+}
+
+func NewFXQuoteRepository(db *mongo.Database, timeout time.Duration) *FXQuoteRepository {
+	return &FXQuoteRepository{collection: db.Collection("fx_quotes"), timeout: timeout}
+}
+
+func (r *FXQuoteRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("idx_userId_createdAt")},
+	})
+	return err
+}
+
+func (r *FXQuoteRepository) Create(ctx context.Context, quote *domain.FXQuote) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := fxQuoteDoc{UserID: quote.UserID, SellCurrency: quote.SellCurrency, BuyCurrency: quote.BuyCurrency, SellAmount: quote.SellAmount, BuyAmount: quote.BuyAmount, Fee: quote.Fee, MidRate: quote.MidRate, Rate: quote.Rate, SpreadBps: quote.SpreadBps, Status: quote.Status, ExpiresAt: quote.ExpiresAt, CreatedAt: quote.CreatedAt}
+	res, err := r.collection.InsertOne(cctx, doc)
+	if err != nil {
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	quote.ID = id.Hex()
+	return nil
+}
+
+func (r *FXQuoteRepository) GetByID(ctx context.Context, id string) (*domain.FXQuote, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrQuoteNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out fxQuoteDoc
+	err = r.collection.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *FXQuoteRepository) MarkExecuted(ctx context.Context, id, entryID string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrQuoteNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"_id": objID, "status": domain.FXQuoteStatusOpen}
+	update := bson.M{"$set": bson.M{"status": domain.FXQuoteStatusExecuted, "entryId": entryID, "executedAt": at}}
+	res, err := r.collection.UpdateOne(cctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrQuoteExecuted
+	}
+	return nil
+}
@@ -0,0 +1,616 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LedgerRepository stores accounts with a running balance and the journal
+// entries that produced it, plus the holds reserving part of that balance.
+// Post and hold changes run in multi-document transactions, so the server
+// must be a replica set. Every posted entry queues a "<kind>.completed"
+// event in the outbox within the same transaction.
+type LedgerRepository struct {
+	client   *mongo.Client
+	accounts *mongo.Collection
+	entries  *mongo.Collection
+	holds    *mongo.Collection
+	outbox   outboxWriter
+	timeout  time.Duration
+}
+
+type accountDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	OwnerID   string             `bson:"ownerId"`
+	Type      domain.AccountType `bson:"type"`
+	Currency  string             `bson:"currency"`
+	Balance   int64              `bson:"balance"`
+	Held      int64              `bson:"held"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+func (d accountDoc) toDomain() *domain.Account {
+	return &domain.Account{ID: d.ID.Hex(), OwnerID: d.OwnerID, Type: d.Type, Currency: d.Currency, Balance: d.Balance, Held: d.Held, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+type postingDoc struct {
+	AccountID string `bson:"accountId"`
+	Amount    int64  `bson:"amount"`
+	Currency  string `bson:"currency"`
+}
+
+type entryDoc struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Kind       domain.EntryKind   `bson:"kind"`
+	Reference  string             `bson:"reference,omitempty"`
+	ReversalOf string             `bson:"reversalOf,omitempty"`
+	Postings   []postingDoc       `bson:"postings"`
+	AccountIDs []string           `bson:"accountIds"`
+	Metadata   map[string]string  `bson:"metadata,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+}
+
+func (d entryDoc) toDomain() *domain.JournalEntry {
+	postings := make([]domain.Posting, 0, len(d.Postings))
+	for _, p := range d.Postings {
+		postings = append(postings, domain.Posting{AccountID: p.AccountID, Amount: p.Amount, Currency: p.Currency})
+	}
+	return &domain.JournalEntry{ID: d.ID.Hex(), Kind: d.Kind, Reference: d.Reference, ReversalOf: d.ReversalOf, Postings: postings, Metadata: d.Metadata, CreatedAt: d.CreatedAt.UTC()}
+}
+
+type holdDoc struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	AccountID      string             `bson:"accountId"`
+	OwnerID        string             `bson:"ownerId"`
+	Currency       string             `bson:"currency"`
+	Amount         int64              `bson:"amount"`
+	Kind           domain.HoldKind    `bson:"kind"`
+	Status         domain.HoldStatus  `bson:"status"`
+	PayeeAccountID string             `bson:"payeeAccountId,omitempty"`
+	CapturedAmount int64              `bson:"capturedAmount,omitempty"`
+	EntryID        string             `bson:"entryId,omitempty"`
+	Metadata       map[string]string  `bson:"metadata,omitempty"`
+	ExpiresAt      time.Time          `bson:"expiresAt,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt"`
+}
+
+func (d holdDoc) toDomain() *domain.Hold {
+	return &domain.Hold{ID: d.ID.Hex(), AccountID: d.AccountID, OwnerID: d.OwnerID, Currency: d.Currency, Amount: d.Amount, Kind: d.Kind, Status: d.Status, PayeeAccountID: d.PayeeAccountID, CapturedAmount: d.CapturedAmount, EntryID: d.EntryID, Metadata: d.Metadata, ExpiresAt: d.ExpiresAt.UTC(), CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+func NewLedgerRepository(db *mongo.Database, timeout time.Duration) *LedgerRepository {
+	return &LedgerRepository{client: db.Client(), accounts: db.Collection("ledger_accounts"), entries: db.Collection("journal_entries"), holds: db.Collection("ledger_holds"), outbox: newOutboxWriter(db), timeout: timeout}
+}
+
+func (r *LedgerRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.accounts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "type", Value: 1}, {Key: "currency", Value: 1}}, Options: options.Index().SetName("uniq_owner_type_currency").SetUnique(true)},
+	}); err != nil {
+		return err
+	}
+	if _, err := r.entries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "reference", Value: 1}}, Options: options.Index().SetName("uniq_reference").SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "accountIds", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("idx_accountIds_createdAt")},
+		{Keys: bson.D{{Key: "reversalOf", Value: 1}}, Options: options.Index().SetName("idx_reversalOf").SetSparse(true)},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("idx_createdAt_id")},
+	}); err != nil {
+		return err
+	}
+	_, err := r.holds.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "status", Value: 1}}, Options: options.Index().SetName("idx_accountId_status")},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}, Options: options.Index().SetName("idx_status_expiresAt")},
+	})
+	return err
+}
+
+func (r *LedgerRepository) GetOrCreateAccount(ctx context.Context, ownerID string, accountType domain.AccountType, currency string) (*domain.Account, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	now := time.Now().UTC()
+	filter := bson.M{"ownerId": ownerID, "type": accountType, "currency": currency}
+	update := bson.M{"$setOnInsert": bson.M{"balance": int64(0), "held": int64(0), "createdAt": now, "updatedAt": now}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var out accountDoc
+	err := r.accounts.FindOneAndUpdate(cctx, filter, update, opts).Decode(&out)
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost an upsert race with a concurrent open; the winner's document
+		// is now visible.
+		err = r.accounts.FindOne(cctx, filter).Decode(&out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *LedgerRepository) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrAccountNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out accountDoc
+	err = r.accounts.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *LedgerRepository) ListAccountsByOwner(ctx context.Context, ownerID string) ([]*domain.Account, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.accounts.Find(cctx, bson.M{"ownerId": ownerID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []accountDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Account, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) Post(ctx context.Context, entry *domain.JournalEntry) error {
+	return r.postChecked(ctx, entry, func(mongo.SessionContext) error { return nil })
+}
+
+func (r *LedgerRepository) PostWithinCap(ctx context.Context, entry *domain.JournalEntry, limit domain.PayeeCap) error {
+	return r.postChecked(ctx, entry, func(sc mongo.SessionContext) error {
+		return r.checkCap(sc, limit, limit.EntryAmount(entry))
+	})
+}
+
+// postChecked posts entry in a transaction that first runs check.
+func (r *LedgerRepository) postChecked(ctx context.Context, entry *domain.JournalEntry, check func(mongo.SessionContext) error) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	id, err := sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		if err := check(sc); err != nil {
+			return nil, err
+		}
+		return r.post(sc, entry)
+	})
+	if err != nil {
+		return err
+	}
+	entry.ID = id.(primitive.ObjectID).Hex()
+	return nil
+}
+
+// checkCap refuses amount when it would take what the payee was sent past
+// limit. Every payment the cap counts debits limit.AccountID, so one that
+// commits after this transaction read the total makes its own debit a
+// write conflict, and the retry reads the new total.
+func (r *LedgerRepository) checkCap(sc mongo.SessionContext, limit domain.PayeeCap, amount int64) error {
+	sent, err := r.sentWithinCap(sc, limit)
+	if err != nil {
+		return err
+	}
+	if sent+amount > limit.Limit {
+		return domain.ErrCoolingOffLimit
+	}
+	return nil
+}
+
+func (r *LedgerRepository) SentWithinCap(ctx context.Context, limit domain.PayeeCap) (int64, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.sentWithinCap(cctx, limit)
+}
+
+func (r *LedgerRepository) sentWithinCap(ctx context.Context, limit domain.PayeeCap) (int64, error) {
+	payee := "metadata." + limit.Field
+	cur, err := r.entries.Find(ctx, bson.M{"accountIds": limit.AccountID, payee: limit.Payee, "createdAt": bson.M{"$gte": limit.Since}})
+	if err != nil {
+		return 0, err
+	}
+	var entries []entryDoc
+	if err := cur.All(ctx, &entries); err != nil {
+		return 0, err
+	}
+	cur, err = r.holds.Find(ctx, bson.M{"accountId": limit.AccountID, "status": domain.HoldStatusActive, payee: limit.Payee})
+	if err != nil {
+		return 0, err
+	}
+	var holds []holdDoc
+	if err := cur.All(ctx, &holds); err != nil {
+		return 0, err
+	}
+	var sent int64
+	for _, d := range entries {
+		sent += limit.EntryAmount(d.toDomain())
+	}
+	for _, d := range holds {
+		sent += limit.HoldAmount(d.toDomain())
+	}
+	return sent, nil
+}
+
+func (r *LedgerRepository) FirstPaymentTo(ctx context.Context, accountID, field, payee string) (time.Time, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"accountIds": accountID, "metadata." + field: payee, "postings": bson.M{"$elemMatch": bson.M{"accountId": accountID, "amount": bson.M{"$lt": 0}}}}
+	var out entryDoc
+	err := r.entries.FindOne(cctx, filter, options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: 1}})).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return out.CreatedAt.UTC(), nil
+}
+
+func (r *LedgerRepository) post(sc mongo.SessionContext, entry *domain.JournalEntry) (primitive.ObjectID, error) {
+	doc := entryDoc{ID: primitive.NewObjectID(), Kind: entry.Kind, Reference: entry.Reference, ReversalOf: entry.ReversalOf, Metadata: entry.Metadata, CreatedAt: entry.CreatedAt}
+	seen := map[string]bool{}
+	for _, p := range entry.Postings {
+		objID, err := primitive.ObjectIDFromHex(p.AccountID)
+		if err != nil {
+			return primitive.NilObjectID, domain.ErrAccountNotFound
+		}
+		filter := bson.M{"_id": objID, "currency": p.Currency}
+		if p.Amount < 0 {
+			filter["$or"] = bson.A{bson.M{"type": domain.AccountTypeSystem}, availableAtLeast(-p.Amount)}
+		}
+		res, err := r.accounts.UpdateOne(sc, filter, bson.M{"$inc": bson.M{"balance": p.Amount}, "$set": bson.M{"updatedAt": entry.CreatedAt}})
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		if res.MatchedCount == 0 {
+			n, err := r.accounts.CountDocuments(sc, bson.M{"_id": objID, "currency": p.Currency})
+			if err != nil {
+				return primitive.NilObjectID, err
+			}
+			if n == 0 {
+				return primitive.NilObjectID, domain.ErrAccountNotFound
+			}
+			return primitive.NilObjectID, domain.ErrInsufficientFunds
+		}
+		doc.Postings = append(doc.Postings, postingDoc{AccountID: p.AccountID, Amount: p.Amount, Currency: p.Currency})
+		if !seen[p.AccountID] {
+			seen[p.AccountID] = true
+			doc.AccountIDs = append(doc.AccountIDs, p.AccountID)
+		}
+	}
+	if _, err := r.entries.InsertOne(sc, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return primitive.NilObjectID, domain.ErrDuplicateEntry
+		}
+		return primitive.NilObjectID, err
+	}
+	posted := *entry
+	posted.ID = doc.ID.Hex()
+	if err := r.outbox.append(sc, domain.NewEntryPostedEvent(&posted)); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return doc.ID, nil
+}
+
+// availableAtLeast matches accounts whose balance net of holds covers
+// amount. Accounts opened before holds existed have no held field.
+func availableAtLeast(amount int64) bson.M {
+	return bson.M{"$expr": bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{"$balance", bson.M{"$ifNull": bson.A{"$held", 0}}}}, amount}}}
+}
+
+func (r *LedgerRepository) ListEntriesByAccount(ctx context.Context, accountID string, from, to time.Time) ([]*domain.JournalEntry, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"accountIds": accountID, "createdAt": bson.M{"$gte": from, "$lt": to}}
+	cur, err := r.entries.Find(cctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []entryDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.JournalEntry, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) GetEntry(ctx context.Context, id string) (*domain.JournalEntry, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrEntryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out entryDoc
+	err = r.entries.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *LedgerRepository) GetEntryByReference(ctx context.Context, reference string) (*domain.JournalEntry, error) {
+	if reference == "" {
+		return nil, domain.ErrEntryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out entryDoc
+	err := r.entries.FindOne(cctx, bson.M{"reference": reference}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *LedgerRepository) ListEntriesAfter(ctx context.Context, after time.Time, afterID string, until time.Time, limit int) ([]*domain.JournalEntry, error) {
+	afterObjID := primitive.NilObjectID
+	if afterID != "" {
+		var err error
+		if afterObjID, err = primitive.ObjectIDFromHex(afterID); err != nil {
+			return nil, domain.ErrEntryNotFound
+		}
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{
+		"createdAt": bson.M{"$lt": until},
+		"$or": bson.A{
+			bson.M{"createdAt": bson.M{"$gt": after}},
+			bson.M{"createdAt": after, "_id": bson.M{"$gt": afterObjID}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cur, err := r.entries.Find(cctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []entryDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.JournalEntry, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) ListReversals(ctx context.Context, entryID string) ([]*domain.JournalEntry, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.entries.Find(cctx, bson.M{"reversalOf": entryID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []entryDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.JournalEntry, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) PlaceHold(ctx context.Context, hold *domain.Hold) error {
+	return r.placeHold(ctx, hold, func(mongo.SessionContext) error { return nil })
+}
+
+func (r *LedgerRepository) PlaceHoldWithinCap(ctx context.Context, hold *domain.Hold, limit domain.PayeeCap) error {
+	return r.placeHold(ctx, hold, func(sc mongo.SessionContext) error {
+		return r.checkCap(sc, limit, limit.HoldAmount(hold))
+	})
+}
+
+// placeHold reserves hold in a transaction that first runs check.
+func (r *LedgerRepository) placeHold(ctx context.Context, hold *domain.Hold, check func(mongo.SessionContext) error) error {
+	// A negative hold would lower Held and raise the available balance.
+	if hold.Amount <= 0 {
+		return domain.ErrInvalidInput
+	}
+	accountID, err := primitive.ObjectIDFromHex(hold.AccountID)
+	if err != nil {
+		return domain.ErrAccountNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	id, err := sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		if err := check(sc); err != nil {
+			return nil, err
+		}
+		filter := bson.M{"_id": accountID, "currency": hold.Currency, "type": bson.M{"$ne": domain.AccountTypeSystem}}
+		for k, v := range availableAtLeast(hold.Amount) {
+			filter[k] = v
+		}
+		res, err := r.accounts.UpdateOne(sc, filter, bson.M{"$inc": bson.M{"held": hold.Amount}, "$set": bson.M{"updatedAt": hold.CreatedAt}})
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 0 {
+			return nil, domain.ErrInsufficientFunds
+		}
+		doc := holdDoc{AccountID: hold.AccountID, OwnerID: hold.OwnerID, Currency: hold.Currency, Amount: hold.Amount, Kind: hold.Kind, Status: hold.Status, PayeeAccountID: hold.PayeeAccountID, Metadata: hold.Metadata, ExpiresAt: hold.ExpiresAt, CreatedAt: hold.CreatedAt, UpdatedAt: hold.UpdatedAt}
+		ins, err := r.holds.InsertOne(sc, doc)
+		if err != nil {
+			return nil, err
+		}
+		return ins.InsertedID, nil
+	})
+	if err != nil {
+		return err
+	}
+	hold.ID = id.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *LedgerRepository) GetHold(ctx context.Context, id string) (*domain.Hold, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrHoldNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out holdDoc
+	err = r.holds.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *LedgerRepository) ListActiveHolds(ctx context.Context, accountID string) ([]*domain.Hold, error) {
+	return r.findHolds(ctx, bson.M{"accountId": accountID, "status": domain.HoldStatusActive}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+}
+
+func (r *LedgerRepository) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*domain.Hold, error) {
+	// Holds without an expiry have no expiresAt field and never match.
+	filter := bson.M{"status": domain.HoldStatusActive, "expiresAt": bson.M{"$lte": now}}
+	return r.findHolds(ctx, filter, options.Find().SetSort(bson.D{{Key: "expiresAt", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (r *LedgerRepository) findHolds(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*domain.Hold, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.holds.Find(cctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []holdDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Hold, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) CaptureHold(ctx context.Context, id string, capturedAmount int64, entry *domain.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrHoldNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	entryID, err := sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		filter := bson.M{"_id": objID, "status": domain.HoldStatusActive, "$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$gt": entry.CreatedAt}},
+		}}
+		set := bson.M{"status": domain.HoldStatusCaptured, "capturedAmount": capturedAmount, "updatedAt": entry.CreatedAt}
+		if _, err := r.settleHold(sc, filter, set); err != nil {
+			return nil, err
+		}
+		// The hold is released before posting so the capture can spend the
+		// funds it reserved.
+		entryID, err := r.post(sc, entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.holds.UpdateOne(sc, bson.M{"_id": objID}, bson.M{"$set": bson.M{"entryId": entryID.Hex()}}); err != nil {
+			return nil, err
+		}
+		return entryID, nil
+	})
+	if err != nil {
+		return err
+	}
+	entry.ID = entryID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *LedgerRepository) ReleaseHold(ctx context.Context, id string, status domain.HoldStatus, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrHoldNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	_, err = sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		filter := bson.M{"_id": objID, "status": domain.HoldStatusActive}
+		if status == domain.HoldStatusExpired {
+			filter["expiresAt"] = bson.M{"$lte": at}
+		}
+		return r.settleHold(sc, filter, bson.M{"status": status, "updatedAt": at})
+	})
+	return err
+}
+
+// settleHold moves the hold matching filter out of active and gives its
+// amount back to the account's available balance.
+func (r *LedgerRepository) settleHold(sc mongo.SessionContext, filter, set bson.M) (*domain.Hold, error) {
+	var out holdDoc
+	err := r.holds.FindOneAndUpdate(sc, filter, bson.M{"$set": set}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if n, cerr := r.holds.CountDocuments(sc, bson.M{"_id": filter["_id"]}); cerr == nil && n == 0 {
+			return nil, domain.ErrHoldNotFound
+		}
+		return nil, domain.ErrHoldNotActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	accountID, err := primitive.ObjectIDFromHex(out.AccountID)
+	if err != nil {
+		return nil, domain.ErrAccountNotFound
+	}
+	if _, err := r.accounts.UpdateOne(sc, bson.M{"_id": accountID}, bson.M{"$inc": bson.M{"held": -out.Amount}, "$set": bson.M{"updatedAt": set["updatedAt"]}}); err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
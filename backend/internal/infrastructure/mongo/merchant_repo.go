@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MerchantRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type merchantDoc struct {
+	ID           primitive.ObjectID    `bson:"_id,omitempty"`
+	OwnerID      string                `bson:"ownerId"`
+	Name         string                `bson:"name"`
+	City         string                `bson:"city"`
+	CategoryCode string                `bson:"categoryCode"`
+	CountryCode  string                `bson:"countryCode"`
+	Currency     string                `bson:"currency"`
+	Status       domain.MerchantStatus `bson:"status"`
+	CreatedAt    time.Time             `bson:"createdAt"`
+	UpdatedAt    time.Time             `bson:"updatedAt"`
+}
+
+func (d merchantDoc) toDomain() *domain.Merchant {
+	return &domain.Merchant{ID: d.ID.Hex(), OwnerID: d.OwnerID, Name: d.Name, City: d.City, CategoryCode: d.CategoryCode, CountryCode: d.CountryCode, Currency: d.Currency, Status: d.Status, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+func NewMerchantRepository(db *mongo.Database, timeout time.Duration) *MerchantRepository {
+	return &MerchantRepository{collection: db.Collection("merchants"), timeout: timeout}
+}
+
+func (r *MerchantRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}}, Options: options.Index().SetName("idx_ownerId")},
+	})
+	return err
+}
+
+func (r *MerchantRepository) Create(ctx context.Context, merchant *domain.Merchant) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := merchantDoc{OwnerID: merchant.OwnerID, Name: merchant.Name, City: merchant.City, CategoryCode: merchant.CategoryCode, CountryCode: merchant.CountryCode, Currency: merchant.Currency, Status: merchant.Status, CreatedAt: merchant.CreatedAt, UpdatedAt: merchant.UpdatedAt}
+	res, err := r.collection.InsertOne(cctx, doc)
+	if err != nil {
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	merchant.ID = id.Hex()
+	return nil
+}
+
+func (r *MerchantRepository) GetByID(ctx context.Context, id string) (*domain.Merchant, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrMerchantNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out merchantDoc
+	err = r.collection.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrMerchantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *MerchantRepository) ListByOwner(ctx context.Context, ownerID string) ([]*domain.Merchant, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.collection.Find(cctx, bson.M{"ownerId": ownerID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []merchantDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Merchant, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
@@ -0,0 +1,225 @@
+// Package migrate applies versioned schema migrations to a Mongo database.
+// Each applied version is recorded in the schema_migrations collection, and
+// a lease in schema_migrations_lock keeps two migrators from running at
+// once. A migration may be cut short by a crash or a lost lease and then
+// run again, so every Up must be safe to repeat.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrLocked is returned when another migrator holds the lock.
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// lockID names the single lease document.
+const lockID = "schema_migrations"
+
+// Migration changes the schema or data from Version-1 to Version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status is a migration this binary knows, or a version the database has
+// applied that it does not, with Known false.
+type Status struct {
+	Version   int
+	Name      string
+	Known     bool
+	AppliedAt time.Time
+}
+
+func (s Status) Applied() bool { return !s.AppliedAt.IsZero() }
+
+type appliedDoc struct {
+	Version    int       `bson:"_id"`
+	Name       string    `bson:"name"`
+	AppliedAt  time.Time `bson:"appliedAt"`
+	DurationMs int64     `bson:"durationMs"`
+}
+
+// Migrator runs migrations in version order. Owner identifies it in the
+// lock, and LockTTL bounds how long a crashed migrator blocks others; a
+// running migrator renews its lease well before then.
+type Migrator struct {
+	db         *mongo.Database
+	applied    *mongo.Collection
+	lock       *mongo.Collection
+	migrations []Migration
+	owner      string
+	lockTTL    time.Duration
+	now        func() time.Time
+}
+
+// New checks that migrations have unique positive versions and names and
+// sorts them by version.
+func New(db *mongo.Database, migrations []Migration, owner string, lockTTL time.Duration) (*Migrator, error) {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int { return a.Version - b.Version })
+	for i, m := range sorted {
+		if m.Version <= 0 || m.Name == "" || m.Up == nil {
+			return nil, fmt.Errorf("migration %d %q: version, name and Up are required", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migration version %d is used twice", m.Version)
+		}
+	}
+	if owner == "" || lockTTL <= 0 {
+		return nil, fmt.Errorf("owner and lock TTL are required")
+	}
+	return &Migrator{db: db, applied: db.Collection("schema_migrations"), lock: db.Collection("schema_migrations_lock"), migrations: sorted,
+		owner: owner, lockTTL: lockTTL, now: time.Now}, nil
+}
+
+// Status lists every known migration, applied or not, then any applied
+// version this binary does not know, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name, Known: true}
+		if doc, ok := applied[mig.Version]; ok {
+			s.AppliedAt = doc.AppliedAt
+			delete(applied, mig.Version)
+		}
+		out = append(out, s)
+	}
+	for _, doc := range applied {
+		out = append(out, Status{Version: doc.Version, Name: doc.Name, AppliedAt: doc.AppliedAt})
+	}
+	slices.SortFunc(out, func(a, b Status) int { return a.Version - b.Version })
+	return out, nil
+}
+
+// Pending returns the known migrations not applied yet, in the order Up
+// would run them.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			out = append(out, mig)
+		}
+	}
+	return out, nil
+}
+
+// Up takes the lock and applies the pending migrations one by one,
+// recording each as it completes. The lease is renewed while each
+// migration runs; if it is lost, the migration's context is cancelled and
+// Up fails without recording it. It stops at the first failure; the
+// migrations before it stay applied. report, when set, is called before
+// each migration runs.
+func (m *Migrator) Up(ctx context.Context, report func(Migration)) ([]Migration, error) {
+	if err := m.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.release(context.WithoutCancel(ctx))
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range pending {
+		if report != nil {
+			report(mig)
+		}
+		start := m.now()
+		if err := m.holding(ctx, func(ctx context.Context) error { return mig.Up(ctx, m.db) }); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+		doc := appliedDoc{Version: mig.Version, Name: mig.Name, AppliedAt: m.now().UTC(), DurationMs: m.now().Sub(start).Milliseconds()}
+		if _, err := m.applied.InsertOne(ctx, doc); err != nil {
+			return done, fmt.Errorf("record migration %d: %w", mig.Version, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]appliedDoc, error) {
+	cur, err := m.applied.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var docs []appliedDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make(map[int]appliedDoc, len(docs))
+	for _, d := range docs {
+		out[d.Version] = d
+	}
+	return out, nil
+}
+
+// acquire takes or renews the lease. The upsert only matches a lease that
+// is ours or has expired; when another live lease exists it collides with
+// it on _id instead.
+func (m *Migrator) acquire(ctx context.Context) error {
+	now := m.now().UTC()
+	filter := bson.M{"_id": lockID, "$or": bson.A{bson.M{"owner": m.owner}, bson.M{"expiresAt": bson.M{"$lte": now}}}}
+	update := bson.M{"$set": bson.M{"owner": m.owner, "expiresAt": now.Add(m.lockTTL)}}
+	_, err := m.lock.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLocked
+	}
+	return err
+}
+
+// holding runs fn while renewing the lease every third of its TTL. When a
+// renewal fails another migrator may already have taken over, so fn's
+// context is cancelled and the renewal error is returned instead of fn's.
+func (m *Migrator) holding(ctx context.Context, fn func(context.Context) error) error {
+	if err := m.acquire(ctx); err != nil {
+		return err
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	var lost error
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(max(m.lockTTL/3, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := m.acquire(runCtx); err != nil {
+					lost = fmt.Errorf("lost the migration lock: %w", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	err := fn(runCtx)
+	close(stop)
+	<-stopped
+	if lost != nil {
+		return lost
+	}
+	return err
+}
+
+func (m *Migrator) release(ctx context.Context) {
+	_, _ = m.lock.DeleteOne(ctx, bson.M{"_id": lockID, "owner": m.owner})
+}
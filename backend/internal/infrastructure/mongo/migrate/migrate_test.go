@@ -0,0 +1,192 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDB connects to TEST_MONGO_URI and returns a database of its own,
+// dropped when the test ends.
+func testDB(t *testing.T) *mongo.Database {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("akiba_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return db
+}
+
+// offlineDB returns a handle on a database that is never reached, for tests
+// that stop before any query.
+func offlineDB(t *testing.T) *mongo.Database {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	return client.Database("akiba_offline")
+}
+
+func noop(context.Context, *mongo.Database) error { return nil }
+
+func TestNewValidatesMigrations(t *testing.T) {
+	cases := map[string][]Migration{
+		"zero version": {{Version: 0, Name: "a", Up: noop}},
+		"missing name": {{Version: 1, Up: noop}},
+		"missing up":   {{Version: 1, Name: "a"}},
+		"duplicate":    {{Version: 2, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}, {Version: 2, Name: "c", Up: noop}},
+		"negative":     {{Version: -1, Name: "a", Up: noop}},
+	}
+	db := offlineDB(t)
+	for name, migrations := range cases {
+		if _, err := New(db, migrations, "test", time.Minute); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := New(db, nil, "", time.Minute); err == nil {
+		t.Error("expected an owner to be required")
+	}
+	m, err := New(db, []Migration{{Version: 2, Name: "b", Up: noop}, {Version: 1, Name: "a", Up: noop}}, "test", time.Minute)
+	if err != nil || m.migrations[0].Version != 1 {
+		t.Fatalf("expected migrations sorted by version: %v", err)
+	}
+}
+
+func TestUpAppliesPendingMigrationsOnce(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	var ran []int
+	step := func(v int) Migration {
+		return Migration{Version: v, Name: fmt.Sprintf("step_%d", v), Up: func(context.Context, *mongo.Database) error {
+			ran = append(ran, v)
+			return nil
+		}}
+	}
+	m, err := New(db, []Migration{step(2), step(1)}, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 2 {
+		t.Fatalf("expected both migrations pending, got %v %v", pending, err)
+	}
+	if done, err := m.Up(ctx, nil); err != nil || len(done) != 2 || fmt.Sprint(ran) != "[1 2]" {
+		t.Fatalf("first up: %v %v, ran %v", done, err, ran)
+	}
+	if done, err := m.Up(ctx, nil); err != nil || len(done) != 0 || len(ran) != 2 {
+		t.Fatalf("second up must apply nothing: %v %v, ran %v", done, err, ran)
+	}
+
+	// A newer binary adds a migration; an older one sees it as unknown.
+	newer, _ := New(db, []Migration{step(1), step(2), step(3)}, "test", time.Minute)
+	if done, err := newer.Up(ctx, nil); err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("expected only migration 3: %v %v", done, err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil || len(statuses) != 3 || !statuses[0].Applied() || !statuses[2].Applied() || statuses[2].Known {
+		t.Fatalf("unexpected status: %+v %v", statuses, err)
+	}
+}
+
+func TestUpStopsAtTheFirstFailure(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	boom := errors.New("boom")
+	m, _ := New(db, []Migration{
+		{Version: 1, Name: "ok", Up: noop},
+		{Version: 2, Name: "fails", Up: func(context.Context, *mongo.Database) error { return boom }},
+		{Version: 3, Name: "never", Up: noop},
+	}, "test", time.Minute)
+	if done, err := m.Up(ctx, nil); !errors.Is(err, boom) || len(done) != 1 {
+		t.Fatalf("expected the failure after one migration, got %v %v", done, err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 2 || pending[0].Version != 2 {
+		t.Fatalf("failed and later migrations stay pending: %v %v", pending, err)
+	}
+	if n, err := db.Collection("schema_migrations_lock").CountDocuments(ctx, bson.M{}); err != nil || n != 0 {
+		t.Fatalf("lock must be released after a failure: %d %v", n, err)
+	}
+}
+
+func TestUpRefusesWhileAnotherMigratorHoldsTheLock(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	migrations := []Migration{{Version: 1, Name: "ok", Up: noop}}
+	holder, _ := New(db, migrations, "holder", time.Minute)
+	if err := holder.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := New(db, migrations, "other", time.Minute)
+	if _, err := other.Up(ctx, nil); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if pending, _ := other.Pending(ctx); len(pending) != 1 {
+		t.Fatal("a locked out migrator must not apply anything")
+	}
+
+	// Once the holder's lease runs out, the lock is up for grabs.
+	other.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if done, err := other.Up(ctx, nil); err != nil || len(done) != 1 {
+		t.Fatalf("expected the expired lease to be taken over: %v %v", done, err)
+	}
+}
+
+func TestUpRenewsTheLeaseWhileAMigrationRuns(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	other, _ := New(db, []Migration{{Version: 1, Name: "slow", Up: noop}}, "other", time.Minute)
+	var otherErr error
+	slow := func(context.Context, *mongo.Database) error {
+		// Outlive the holder's TTL twice over, then try to take the lock.
+		time.Sleep(600 * time.Millisecond)
+		_, otherErr = other.Up(ctx, nil)
+		return nil
+	}
+	holder, _ := New(db, []Migration{{Version: 1, Name: "slow", Up: slow}}, "holder", 300*time.Millisecond)
+	if done, err := holder.Up(ctx, nil); err != nil || len(done) != 1 {
+		t.Fatalf("expected the slow migration applied: %v %v", done, err)
+	}
+	if !errors.Is(otherErr, ErrLocked) {
+		t.Fatalf("expected the lease kept during the migration, got %v", otherErr)
+	}
+}
+
+func TestUpCancelsAMigrationThatLosesTheLease(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	lock := db.Collection("schema_migrations_lock")
+	stolen := func(ctx context.Context, _ *mongo.Database) error {
+		if _, err := lock.UpdateOne(context.Background(), bson.M{"_id": lockID}, bson.M{"$set": bson.M{"owner": "thief", "expiresAt": time.Now().Add(time.Hour)}}); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+	m, _ := New(db, []Migration{{Version: 1, Name: "stolen", Up: stolen}}, "holder", 300*time.Millisecond)
+	if done, err := m.Up(ctx, nil); !errors.Is(err, ErrLocked) || len(done) != 0 {
+		t.Fatalf("expected the migration stopped on a lost lease, got %v %v", done, err)
+	}
+	if pending, _ := m.Pending(ctx); len(pending) != 1 {
+		t.Fatal("a migration that lost its lease must stay pending")
+	}
+}
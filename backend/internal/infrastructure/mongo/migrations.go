@@ -0,0 +1,70 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/mongo/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations lists the schema migrations in version order. Append new
+// ones; never renumber or edit one that has shipped.
+func Migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{Version: 1, Name: "drop_plaintext_contact_indexes", Up: dropPlaintextContactIndexes},
+		{Version: 2, Name: "backfill_disabled_user_status", Up: backfillDisabledUserStatus},
+		{Version: 3, Name: "partial_plaintext_contact_indexes", Up: partialPlaintextContactIndexes},
+		{Version: 4, Name: "unset_withdrawal_hold_expiry", Up: unsetWithdrawalHoldExpiry},
+	}
+}
+
+// dropPlaintextContactIndexes drops the unique indexes on the plaintext
+// email and phone. Sealed users no longer have those fields, and would
+// collide on the missing value.
+func dropPlaintextContactIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, legacy := range []string{"uniq_emailLower", "uniq_phoneE164"} {
+		if _, err := db.Collection("users").Indexes().DropOne(ctx, legacy); err != nil && !isIndexNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// partialPlaintextContactIndexes keeps users not re-encrypted yet unique on
+// their plaintext email and phone. The indexes only cover documents that
+// still have the field, so sealed users do not collide on its absence.
+func partialPlaintextContactIndexes(ctx context.Context, db *mongo.Database) error {
+	var models []mongo.IndexModel
+	for _, field := range []string{"emailLower", "phoneE164"} {
+		models = append(models, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}, Options: options.Index().SetName("uniq_" + field + "_legacy").SetUnique(true).
+			SetPartialFilterExpression(bson.M{field: bson.M{"$exists": true}})})
+	}
+	_, err := db.Collection("users").Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// backfillDisabledUserStatus rewrites the legacy "disabled" status as
+// suspended, which is how it already reads.
+func backfillDisabledUserStatus(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("users").UpdateMany(ctx, bson.M{"status": legacyStatusDisabled}, bson.M{"$set": bson.M{"status": domain.UserStatusSuspended}})
+	return err
+}
+
+// unsetWithdrawalHoldExpiry clears the expiry of active withdrawal holds.
+// Their payouts are already on the rail, so the sweep must not release them.
+func unsetWithdrawalHoldExpiry(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("ledger_holds").UpdateMany(ctx, bson.M{"kind": domain.HoldKindWithdrawal, "status": domain.HoldStatusActive}, bson.M{"$unset": bson.M{"expiresAt": ""}})
+	return err
+}
+
+// isIndexNotFound reports whether err is dropping an index that does not
+// exist, including on a collection not created yet.
+func isIndexNotFound(err error) bool {
+	var ce mongo.CommandError
+	return errors.As(err, &ce) && (ce.Code == 26 || ce.Code == 27 || ce.Name == "NamespaceNotFound" || ce.Name == "IndexNotFound")
+}
@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/mongo/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMigrationsRewriteLegacyUsers(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	users := db.Collection("users")
+	if _, err := users.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "emailLower", Value: 1}},
+		Options: options.Index().SetName("uniq_emailLower").SetUnique(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.InsertMany(ctx, []any{bson.M{"status": legacyStatusDisabled}, bson.M{"status": domain.UserStatusActive}}); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrate.New(db, Migrations(), "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Twice, since a migration cut short runs again from the start.
+	for _, m := range Migrations() {
+		for range 2 {
+			if err := m.Up(ctx, db); err != nil {
+				t.Fatalf("migration %d: %v", m.Version, err)
+			}
+		}
+	}
+	if _, err := migrator.Up(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("expected nothing pending, got %v %v", pending, err)
+	}
+
+	specs, err := users.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := 0
+	for _, s := range specs {
+		if s.Name == "uniq_emailLower" {
+			t.Fatal("plaintext email index survived migration 1")
+		}
+		if s.Name == "uniq_emailLower_legacy" || s.Name == "uniq_phoneE164_legacy" {
+			legacy++
+		}
+	}
+	if legacy != 2 {
+		t.Fatalf("expected both partial plaintext indexes, got %d", legacy)
+	}
+	if _, err := users.InsertOne(ctx, bson.M{"emailLower": "old@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.InsertOne(ctx, bson.M{"emailLower": "old@example.com"}); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("expected plaintext emails to stay unique, got %v", err)
+	}
+	if n, err := users.CountDocuments(ctx, bson.M{"status": legacyStatusDisabled}); err != nil || n != 0 {
+		t.Fatalf("expected no disabled users left, got %d %v", n, err)
+	}
+	if n, err := users.CountDocuments(ctx, bson.M{"status": domain.UserStatusSuspended}); err != nil || n != 1 {
+		t.Fatalf("expected the disabled user suspended, got %d %v", n, err)
+	}
+}
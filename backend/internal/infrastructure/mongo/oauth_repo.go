@@ -0,0 +1,328 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// usedRefreshHashesKept bounds how many rotated refresh tokens a grant
+	// remembers for reuse detection.
+	usedRefreshHashesKept = 20
+	// oauthCodeRetention keeps used and expired codes long enough that a
+	// replayed code is recognised and its grant revoked.
+	oauthCodeRetention = 10 * time.Minute
+)
+
+type OAuthRepository struct {
+	consents *mongo.Collection
+	codes    *mongo.Collection
+	grants   *mongo.Collection
+	timeout  time.Duration
+}
+
+type oauthConsentDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"userId"`
+	ClientID  string             `bson:"clientId"`
+	Scopes    []domain.Scope     `bson:"scopes"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+	RevokedAt time.Time          `bson:"revokedAt,omitempty"`
+}
+
+func (d oauthConsentDoc) toDomain() *domain.OAuthConsent {
+	c := &domain.OAuthConsent{ID: d.ID.Hex(), UserID: d.UserID, ClientID: d.ClientID, Scopes: d.Scopes, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+	if !d.RevokedAt.IsZero() {
+		c.RevokedAt = d.RevokedAt.UTC()
+	}
+	return c
+}
+
+// oauthCodeDoc is keyed by the code's hash.
+type oauthCodeDoc struct {
+	Hash          string         `bson:"_id"`
+	ClientID      string         `bson:"clientId"`
+	UserID        string         `bson:"userId"`
+	RedirectURI   string         `bson:"redirectUri"`
+	Scopes        []domain.Scope `bson:"scopes"`
+	CodeChallenge string         `bson:"codeChallenge"`
+	Nonce         string         `bson:"nonce,omitempty"`
+	AuthTime      time.Time      `bson:"authTime,omitempty"`
+	ExpiresAt     time.Time      `bson:"expiresAt"`
+	CreatedAt     time.Time      `bson:"createdAt"`
+	UsedAt        time.Time      `bson:"usedAt,omitempty"`
+}
+
+func (d oauthCodeDoc) toDomain() *domain.OAuthCode {
+	c := &domain.OAuthCode{Hash: d.Hash, ClientID: d.ClientID, UserID: d.UserID, RedirectURI: d.RedirectURI, Scopes: d.Scopes, CodeChallenge: d.CodeChallenge, Nonce: d.Nonce, ExpiresAt: d.ExpiresAt.UTC(), CreatedAt: d.CreatedAt.UTC()}
+	if !d.AuthTime.IsZero() {
+		c.AuthTime = d.AuthTime.UTC()
+	}
+	if !d.UsedAt.IsZero() {
+		c.UsedAt = d.UsedAt.UTC()
+	}
+	return c
+}
+
+type oauthGrantDoc struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty"`
+	ClientID          string             `bson:"clientId"`
+	UserID            string             `bson:"userId"`
+	CodeHash          string             `bson:"codeHash"`
+	Scopes            []domain.Scope     `bson:"scopes"`
+	RefreshHash       string             `bson:"refreshHash,omitempty"`
+	UsedRefreshHashes []string           `bson:"usedRefreshHashes,omitempty"`
+	ExpiresAt         time.Time          `bson:"expiresAt"`
+	CreatedAt         time.Time          `bson:"createdAt"`
+	UpdatedAt         time.Time          `bson:"updatedAt"`
+	RevokedAt         time.Time          `bson:"revokedAt,omitempty"`
+}
+
+func (d oauthGrantDoc) toDomain() *domain.OAuthGrant {
+	g := &domain.OAuthGrant{ID: d.ID.Hex(), ClientID: d.ClientID, UserID: d.UserID, CodeHash: d.CodeHash, Scopes: d.Scopes, RefreshHash: d.RefreshHash, UsedRefreshHashes: d.UsedRefreshHashes, ExpiresAt: d.ExpiresAt.UTC(), CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+	if !d.RevokedAt.IsZero() {
+		g.RevokedAt = d.RevokedAt.UTC()
+	}
+	return g
+}
+
+func NewOAuthRepository(db *mongo.Database, timeout time.Duration) *OAuthRepository {
+	return &OAuthRepository{consents: db.Collection("oauth_consents"), codes: db.Collection("oauth_codes"), grants: db.Collection("oauth_grants"), timeout: timeout}
+}
+
+func (r *OAuthRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.consents.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "clientId", Value: 1}}, Options: options.Index().SetName("uniq_userId_clientId").SetUnique(true)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.codes.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetName("ttl_expiresAt").SetExpireAfterSeconds(int32(oauthCodeRetention.Seconds()))})
+	if err != nil {
+		return err
+	}
+	_, err = r.grants.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "refreshHash", Value: 1}}, Options: options.Index().SetName("uniq_refreshHash").SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "usedRefreshHashes", Value: 1}}, Options: options.Index().SetName("idx_usedRefreshHashes")},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "clientId", Value: 1}}, Options: options.Index().SetName("idx_userId_clientId")},
+		{Keys: bson.D{{Key: "codeHash", Value: 1}}, Options: options.Index().SetName("idx_codeHash")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetName("ttl_expiresAt").SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+func (r *OAuthRepository) UpsertConsent(ctx context.Context, c *domain.OAuthConsent) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"userId": c.UserID, "clientId": c.ClientID}
+	update := bson.M{
+		"$set":         bson.M{"scopes": c.Scopes, "updatedAt": c.UpdatedAt},
+		"$unset":       bson.M{"revokedAt": ""},
+		"$setOnInsert": bson.M{"createdAt": c.CreatedAt},
+	}
+	var out oauthConsentDoc
+	err := r.consents.FindOneAndUpdate(cctx, filter, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&out)
+	if err != nil {
+		return err
+	}
+	*c = *out.toDomain()
+	return nil
+}
+
+func (r *OAuthRepository) GetConsent(ctx context.Context, userID, clientID string) (*domain.OAuthConsent, error) {
+	return r.findConsent(ctx, bson.M{"userId": userID, "clientId": clientID})
+}
+
+func (r *OAuthRepository) GetConsentByID(ctx context.Context, id string) (*domain.OAuthConsent, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrConsentNotFound
+	}
+	return r.findConsent(ctx, bson.M{"_id": objID})
+}
+
+func (r *OAuthRepository) findConsent(ctx context.Context, filter bson.M) (*domain.OAuthConsent, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out oauthConsentDoc
+	err := r.consents.FindOne(cctx, filter).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrConsentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *OAuthRepository) ListConsents(ctx context.Context, userID string) ([]*domain.OAuthConsent, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.consents.Find(cctx, bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}}, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []oauthConsentDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.OAuthConsent, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *OAuthRepository) RevokeConsent(ctx context.Context, id string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrConsentNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.consents.UpdateOne(cctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"revokedAt": at, "updatedAt": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrConsentNotFound
+	}
+	return nil
+}
+
+func (r *OAuthRepository) CreateCode(ctx context.Context, c *domain.OAuthCode) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := oauthCodeDoc{Hash: c.Hash, ClientID: c.ClientID, UserID: c.UserID, RedirectURI: c.RedirectURI, Scopes: c.Scopes, CodeChallenge: c.CodeChallenge, Nonce: c.Nonce, AuthTime: c.AuthTime, ExpiresAt: c.ExpiresAt, CreatedAt: c.CreatedAt}
+	if _, err := r.codes.InsertOne(cctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *OAuthRepository) ConsumeCode(ctx context.Context, hash string, at time.Time) (*domain.OAuthCode, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out oauthCodeDoc
+	err := r.codes.FindOneAndUpdate(cctx, bson.M{"_id": hash, "usedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"usedAt": at}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&out)
+	if err == nil {
+		return out.toDomain(), nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	err = r.codes.FindOne(cctx, bson.M{"_id": hash}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), domain.ErrTokenReused
+}
+
+func (r *OAuthRepository) CreateGrant(ctx context.Context, g *domain.OAuthGrant) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := oauthGrantDoc{ClientID: g.ClientID, UserID: g.UserID, CodeHash: g.CodeHash, Scopes: g.Scopes, RefreshHash: g.RefreshHash, ExpiresAt: g.ExpiresAt, CreatedAt: g.CreatedAt, UpdatedAt: g.UpdatedAt}
+	res, err := r.grants.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	g.ID = id.Hex()
+	return nil
+}
+
+func (r *OAuthRepository) GetGrant(ctx context.Context, id string) (*domain.OAuthGrant, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrGrantNotFound
+	}
+	return r.findGrant(ctx, bson.M{"_id": objID})
+}
+
+func (r *OAuthRepository) GetGrantByRefresh(ctx context.Context, hash string) (*domain.OAuthGrant, error) {
+	return r.findGrant(ctx, bson.M{"$or": bson.A{bson.M{"refreshHash": hash}, bson.M{"usedRefreshHashes": hash}}})
+}
+
+func (r *OAuthRepository) findGrant(ctx context.Context, filter bson.M) (*domain.OAuthGrant, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out oauthGrantDoc
+	err := r.grants.FindOne(cctx, filter).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *OAuthRepository) RotateRefresh(ctx context.Context, id, oldHash, newHash string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidGrant
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"_id": objID, "refreshHash": oldHash, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{
+		"$set":  bson.M{"refreshHash": newHash, "updatedAt": at},
+		"$push": bson.M{"usedRefreshHashes": bson.M{"$each": bson.A{oldHash}, "$slice": -usedRefreshHashesKept}},
+	}
+	res, err := r.grants.UpdateOne(cctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrInvalidGrant
+	}
+	return nil
+}
+
+func (r *OAuthRepository) RevokeGrant(ctx context.Context, id string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrGrantNotFound
+	}
+	return r.revokeGrants(ctx, bson.M{"_id": objID}, at)
+}
+
+func (r *OAuthRepository) RevokeGrants(ctx context.Context, userID, clientID string, at time.Time) error {
+	return r.revokeGrants(ctx, bson.M{"userId": userID, "clientId": clientID}, at)
+}
+
+func (r *OAuthRepository) RevokeGrantsByCode(ctx context.Context, codeHash string, at time.Time) error {
+	return r.revokeGrants(ctx, bson.M{"codeHash": codeHash}, at)
+}
+
+// revokeGrants stamps revokedAt on the unrevoked grants matching filter;
+// revoking an already revoked grant is not an error.
+func (r *OAuthRepository) revokeGrants(ctx context.Context, filter bson.M, at time.Time) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter["revokedAt"] = bson.M{"$exists": false}
+	_, err := r.grants.UpdateMany(cctx, filter, bson.M{"$set": bson.M{"revokedAt": at, "updatedAt": at}})
+	return err
+}
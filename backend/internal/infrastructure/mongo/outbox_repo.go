@@ -0,0 +1,181 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// publishedOutboxRetention is how long published events stay in the outbox
+// for troubleshooting before the TTL index removes them.
+const publishedOutboxRetention = 7 * 24 * time.Hour
+
+type outboxDoc struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty"`
+	Type          domain.EventType    `bson:"type"`
+	AggregateType string              `bson:"aggregateType"`
+	AggregateID   string              `bson:"aggregateId"`
+	Seq           int64               `bson:"seq"`
+	Payload       []byte              `bson:"payload"`
+	Status        domain.OutboxStatus `bson:"status"`
+	Attempts      int                 `bson:"attempts"`
+	LastError     string              `bson:"lastError,omitempty"`
+	NextAttemptAt time.Time           `bson:"nextAttemptAt"`
+	OccurredAt    time.Time           `bson:"occurredAt"`
+	PublishedAt   time.Time           `bson:"publishedAt,omitempty"`
+	DeadAt        time.Time           `bson:"deadAt,omitempty"`
+}
+
+func (d outboxDoc) toDomain() *domain.OutboxEvent {
+	e := &domain.OutboxEvent{ID: d.ID.Hex(), Type: d.Type, AggregateType: d.AggregateType, AggregateID: d.AggregateID, Seq: d.Seq, Payload: d.Payload, Status: d.Status, Attempts: d.Attempts, LastError: d.LastError, NextAttemptAt: d.NextAttemptAt.UTC(), OccurredAt: d.OccurredAt.UTC()}
+	if !d.PublishedAt.IsZero() {
+		e.PublishedAt = d.PublishedAt.UTC()
+	}
+	if !d.DeadAt.IsZero() {
+		e.DeadAt = d.DeadAt.UTC()
+	}
+	return e
+}
+
+func outboxDocFrom(e *domain.OutboxEvent) outboxDoc {
+	return outboxDoc{Type: e.Type, AggregateType: e.AggregateType, AggregateID: e.AggregateID, Seq: e.Seq, Payload: e.Payload, Status: e.Status, Attempts: e.Attempts, LastError: e.LastError, NextAttemptAt: e.NextAttemptAt, OccurredAt: e.OccurredAt, PublishedAt: e.PublishedAt, DeadAt: e.DeadAt}
+}
+
+// outboxWriter appends events inside a caller's transaction. Each aggregate
+// has a counter document; bumping it makes concurrent transactions on the
+// same aggregate conflict, so Seq follows commit order.
+type outboxWriter struct {
+	events    *mongo.Collection
+	sequences *mongo.Collection
+}
+
+func newOutboxWriter(db *mongo.Database) outboxWriter {
+	return outboxWriter{events: db.Collection("outbox"), sequences: db.Collection("outbox_sequences")}
+}
+
+func (w outboxWriter) append(sc mongo.SessionContext, event *domain.OutboxEvent) error {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := w.sequences.FindOneAndUpdate(sc, bson.M{"_id": event.AggregateKey()}, bson.M{"$inc": bson.M{"seq": int64(1)}}, opts).Decode(&counter); err != nil {
+		return err
+	}
+	event.Seq = counter.Seq
+	res, err := w.events.InsertOne(sc, outboxDocFrom(event))
+	if err != nil {
+		return err
+	}
+	event.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+// OutboxRepository serves the relay. Events that exhaust their attempts
+// move to the outbox_dead_letters collection.
+type OutboxRepository struct {
+	client      *mongo.Client
+	events      *mongo.Collection
+	deadLetters *mongo.Collection
+	timeout     time.Duration
+}
+
+func NewOutboxRepository(db *mongo.Database, timeout time.Duration) *OutboxRepository {
+	w := newOutboxWriter(db)
+	return &OutboxRepository{client: db.Client(), events: w.events, deadLetters: db.Collection("outbox_dead_letters"), timeout: timeout}
+}
+
+func (r *OutboxRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "aggregateType", Value: 1}, {Key: "aggregateId", Value: 1}, {Key: "seq", Value: 1}}, Options: options.Index().SetName("uniq_aggregate_seq").SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("status_nextAttemptAt")},
+		{Keys: bson.D{{Key: "publishedAt", Value: 1}}, Options: options.Index().SetName("ttl_publishedAt").SetExpireAfterSeconds(int32(publishedOutboxRetention / time.Second))},
+	}); err != nil {
+		return err
+	}
+	_, err := r.deadLetters.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "deadAt", Value: -1}}, Options: options.Index().SetName("deadAt")})
+	return err
+}
+
+func (r *OutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxEvent, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"status": domain.OutboxStatusPending, "nextAttemptAt": bson.M{"$lte": now}}
+	cur, err := r.events.Find(cctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var docs []outboxDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.OutboxEvent, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) PendingBefore(ctx context.Context, event *domain.OutboxEvent) (bool, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	n, err := r.events.CountDocuments(cctx, bson.M{"aggregateType": event.AggregateType, "aggregateId": event.AggregateID, "seq": bson.M{"$lt": event.Seq}, "status": domain.OutboxStatusPending}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	return r.updatePending(ctx, id, bson.M{"status": domain.OutboxStatusPublished, "publishedAt": at})
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, attempts int, lastError string, nextAttemptAt time.Time) error {
+	return r.updatePending(ctx, id, bson.M{"attempts": attempts, "lastError": lastError, "nextAttemptAt": nextAttemptAt})
+}
+
+func (r *OutboxRepository) updatePending(ctx context.Context, id string, set bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrEventNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.events.UpdateOne(cctx, bson.M{"_id": objID, "status": domain.OutboxStatusPending}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrEventNotFound
+	}
+	return nil
+}
+
+func (r *OutboxRepository) DeadLetter(ctx context.Context, event *domain.OutboxEvent) error {
+	objID, err := primitive.ObjectIDFromHex(event.ID)
+	if err != nil {
+		return domain.ErrEventNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	_, err = sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		res, err := r.events.DeleteOne(sc, bson.M{"_id": objID, "status": domain.OutboxStatusPending})
+		if err != nil {
+			return nil, err
+		}
+		if res.DeletedCount == 0 {
+			return nil, domain.ErrEventNotFound
+		}
+		doc := outboxDocFrom(event)
+		doc.ID = objID
+		return r.deadLetters.InsertOne(sc, doc)
+	})
+	return err
+}
@@ -0,0 +1,190 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PasskeyRepository struct {
+	passkeys   *mongo.Collection
+	challenges *mongo.Collection
+	timeout    time.Duration
+}
+
+type passkeyDoc struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	UserID         string             `bson:"userId"`
+	CredentialID   string             `bson:"credentialId"`
+	PublicKey      []byte             `bson:"publicKey"`
+	Algorithm      int64              `bson:"algorithm"`
+	SignCount      int64              `bson:"signCount"`
+	AAGUID         []byte             `bson:"aaguid,omitempty"`
+	Attestation    string             `bson:"attestation"`
+	BackupEligible bool               `bson:"backupEligible"`
+	Name           string             `bson:"name"`
+	CreatedAt      time.Time          `bson:"createdAt"`
+	LastUsedAt     time.Time          `bson:"lastUsedAt,omitempty"`
+}
+
+func (d passkeyDoc) toDomain() *domain.Passkey {
+	return &domain.Passkey{ID: d.ID.Hex(), UserID: d.UserID, CredentialID: d.CredentialID, PublicKey: d.PublicKey, Algorithm: d.Algorithm, SignCount: uint32(d.SignCount), AAGUID: d.AAGUID, Attestation: d.Attestation, BackupEligible: d.BackupEligible, Name: d.Name, CreatedAt: d.CreatedAt.UTC(), LastUsedAt: d.LastUsedAt.UTC()}
+}
+
+// challengeDoc expires through a TTL index; ConsumeChallenge also checks
+// expiresAt because the TTL monitor only runs once a minute.
+type challengeDoc struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty"`
+	UserID    string                 `bson:"userId,omitempty"`
+	Purpose   domain.WebAuthnPurpose `bson:"purpose"`
+	Challenge []byte                 `bson:"challenge"`
+	ExpiresAt time.Time              `bson:"expiresAt"`
+	CreatedAt time.Time              `bson:"createdAt"`
+}
+
+func (d challengeDoc) toDomain() *domain.WebAuthnChallenge {
+	return &domain.WebAuthnChallenge{ID: d.ID.Hex(), UserID: d.UserID, Purpose: d.Purpose, Challenge: d.Challenge, ExpiresAt: d.ExpiresAt.UTC(), CreatedAt: d.CreatedAt.UTC()}
+}
+
+func NewPasskeyRepository(db *mongo.Database, timeout time.Duration) *PasskeyRepository {
+	return &PasskeyRepository{passkeys: db.Collection("passkeys"), challenges: db.Collection("webauthn_challenges"), timeout: timeout}
+}
+
+func (r *PasskeyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.passkeys.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "credentialId", Value: 1}}, Options: options.Index().SetName("uniq_credentialId").SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("idx_userId_createdAt")},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.challenges.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetName("ttl_expiresAt").SetExpireAfterSeconds(0)})
+	return err
+}
+
+func (r *PasskeyRepository) Create(ctx context.Context, p *domain.Passkey) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := passkeyDoc{UserID: p.UserID, CredentialID: p.CredentialID, PublicKey: p.PublicKey, Algorithm: p.Algorithm, SignCount: int64(p.SignCount), AAGUID: p.AAGUID, Attestation: p.Attestation, BackupEligible: p.BackupEligible, Name: p.Name, CreatedAt: p.CreatedAt, LastUsedAt: p.LastUsedAt}
+	res, err := r.passkeys.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrPasskeyExists
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	p.ID = id.Hex()
+	return nil
+}
+
+func (r *PasskeyRepository) GetByCredentialID(ctx context.Context, credentialID string) (*domain.Passkey, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out passkeyDoc
+	err := r.passkeys.FindOne(cctx, bson.M{"credentialId": credentialID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrPasskeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *PasskeyRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Passkey, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.passkeys.Find(cctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []passkeyDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.Passkey, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *PasskeyRepository) UpdateSignCount(ctx context.Context, id string, from, to uint32, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrPasskeyNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.passkeys.UpdateOne(cctx, bson.M{"_id": objID, "signCount": int64(from)}, bson.M{"$set": bson.M{"signCount": int64(to), "lastUsedAt": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrPasskeyInvalid
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) Delete(ctx context.Context, userID, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrPasskeyNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.passkeys.DeleteOne(cctx, bson.M{"_id": objID, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrPasskeyNotFound
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) CreateChallenge(ctx context.Context, c *domain.WebAuthnChallenge) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := challengeDoc{UserID: c.UserID, Purpose: c.Purpose, Challenge: c.Challenge, ExpiresAt: c.ExpiresAt, CreatedAt: c.CreatedAt}
+	res, err := r.challenges.InsertOne(cctx, doc)
+	if err != nil {
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	c.ID = id.Hex()
+	return nil
+}
+
+func (r *PasskeyRepository) ConsumeChallenge(ctx context.Context, id string, purpose domain.WebAuthnPurpose) (*domain.WebAuthnChallenge, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrChallengeNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out challengeDoc
+	err = r.challenges.FindOneAndDelete(cctx, bson.M{"_id": objID, "purpose": purpose}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
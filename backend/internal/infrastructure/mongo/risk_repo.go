@@ -0,0 +1,137 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RiskRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type riskFactorDoc struct {
+	Signal       string  `bson:"signal"`
+	Value        float64 `bson:"value"`
+	Weight       int     `bson:"weight"`
+	Contribution float64 `bson:"contribution"`
+	Detail       string  `bson:"detail,omitempty"`
+}
+
+type riskDecisionDoc struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      string             `bson:"userId"`
+	Action      string             `bson:"action"`
+	Amount      int64              `bson:"amount"`
+	Currency    string             `bson:"currency"`
+	DeviceID    string             `bson:"deviceId,omitempty"`
+	RecipientID string             `bson:"recipientId,omitempty"`
+	Score       int                `bson:"score"`
+	Decision    string             `bson:"decision"`
+	SteppedUp   bool               `bson:"steppedUp,omitempty"`
+	Factors     []riskFactorDoc    `bson:"factors"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+}
+
+func (d riskDecisionDoc) toDomain() *domain.RiskDecision {
+	factors := make([]domain.RiskFactor, 0, len(d.Factors))
+	for _, f := range d.Factors {
+		factors = append(factors, domain.RiskFactor{Signal: f.Signal, Value: f.Value, Weight: f.Weight, Contribution: f.Contribution, Detail: f.Detail})
+	}
+	return &domain.RiskDecision{ID: d.ID.Hex(), UserID: d.UserID, Action: d.Action, Amount: d.Amount, Currency: d.Currency, DeviceID: d.DeviceID, RecipientID: d.RecipientID, Score: d.Score, Decision: d.Decision, SteppedUp: d.SteppedUp, Factors: factors, CreatedAt: d.CreatedAt.UTC()}
+}
+
+func NewRiskRepository(db *mongo.Database, timeout time.Duration) *RiskRepository {
+	return &RiskRepository{collection: db.Collection("risk_decisions"), timeout: timeout}
+}
+
+func (r *RiskRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("idx_userId_createdAt")},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "deviceId", Value: 1}, {Key: "decision", Value: 1}}, Options: options.Index().SetName("idx_userId_deviceId_decision")},
+		{Keys: bson.D{{Key: "decision", Value: 1}, {Key: "createdAt", Value: -1}}, Options: options.Index().SetName("idx_decision_createdAt")},
+	})
+	return err
+}
+
+func (r *RiskRepository) Create(ctx context.Context, decision *domain.RiskDecision) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	factors := make([]riskFactorDoc, 0, len(decision.Factors))
+	for _, f := range decision.Factors {
+		factors = append(factors, riskFactorDoc{Signal: f.Signal, Value: f.Value, Weight: f.Weight, Contribution: f.Contribution, Detail: f.Detail})
+	}
+	doc := riskDecisionDoc{UserID: decision.UserID, Action: decision.Action, Amount: decision.Amount, Currency: decision.Currency, DeviceID: decision.DeviceID, RecipientID: decision.RecipientID, Score: decision.Score, Decision: decision.Decision, SteppedUp: decision.SteppedUp, Factors: factors, CreatedAt: decision.CreatedAt}
+	res, err := r.collection.InsertOne(cctx, doc)
+	if err != nil {
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	decision.ID = id.Hex()
+	return nil
+}
+
+func (r *RiskRepository) GetByID(ctx context.Context, id string) (*domain.RiskDecision, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrRiskDecisionNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out riskDecisionDoc
+	err = r.collection.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrRiskDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *RiskRepository) List(ctx context.Context, userID, decision string, limit int) ([]*domain.RiskDecision, error) {
+	filter := bson.M{}
+	if userID != "" {
+		filter["userId"] = userID
+	}
+	if decision != "" {
+		filter["decision"] = decision
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.collection.Find(cctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var docs []riskDecisionDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.RiskDecision, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *RiskRepository) HasAllowedDevice(ctx context.Context, userID, deviceID string) (bool, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	n, err := r.collection.CountDocuments(cctx, bson.M{"userId": userID, "deviceId": deviceID, "$or": bson.A{bson.M{"decision": "allow"}, bson.M{"steppedUp": true}}}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
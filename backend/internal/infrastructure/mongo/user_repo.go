@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"akiba/backend/internal/domain"
+	"akiba/backend/internal/pii"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,41 +17,163 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Fields a user's personal data is sealed for; they are bound into the
+// ciphertext and the blind indexes.
+const (
+	piiFieldEmail = "users.email"
+	piiFieldPhone = "users.phone"
+	piiFieldTOTP  = "users.totpSecret"
+)
+
+// UserRepository stores email and phone sealed with pii.Cipher and looks
+// them up by blind index, so the collection holds no readable contact
+// details.
 type UserRepository struct {
+	client     *mongo.Client
 	collection *mongo.Collection
+	events     *mongo.Collection
+	outbox     outboxWriter
+	pii        *pii.Cipher
 	timeout    time.Duration
 }
 
-func NewUserRepository(db *mongo.Database, timeout time.Duration) *UserRepository {
-	return &UserRepository{collection: db.Collection("users"), timeout: timeout}
+// userDoc keeps EmailLower and PhoneE164 only for users stored before
+// encryption, until ReencryptPII seals them. PIIKeyID is the key the
+// sealed fields are under; TOTPSecretEnc is sealed the same way.
+type userDoc struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	EmailLower      string             `bson:"emailLower,omitempty"`
+	PhoneE164       string             `bson:"phoneE164,omitempty"`
+	EmailEnc        string             `bson:"emailEnc,omitempty"`
+	EmailIndex      string             `bson:"emailIndex,omitempty"`
+	PhoneEnc        string             `bson:"phoneEnc,omitempty"`
+	PhoneIndex      string             `bson:"phoneIndex,omitempty"`
+	PIIKeyID        string             `bson:"piiKeyId,omitempty"`
+	UsernameLower   string             `bson:"usernameLower"`
+	PasswordHash    string             `bson:"passwordHash"`
+	PINHash         string             `bson:"pinHash,omitempty"`
+	PINFailures     int                `bson:"pinFailures,omitempty"`
+	PINLockedUntil  time.Time          `bson:"pinLockedUntil,omitempty"`
+	Status          domain.UserStatus  `bson:"status"`
+	Role            domain.UserRole    `bson:"role"`
+	KYCStatus       domain.KYCStatus   `bson:"kycStatus,omitempty"`
+	KYCNote         string             `bson:"kycNote,omitempty"`
+	KYCReviewedBy   string             `bson:"kycReviewedBy,omitempty"`
+	KYCReviewedAt   time.Time          `bson:"kycReviewedAt,omitempty"`
+	PhoneVerifiedAt time.Time          `bson:"phoneVerifiedAt,omitempty"`
+	TOTPSecretEnc   string             `bson:"totpSecretEnc,omitempty"`
+	TOTPConfirmedAt time.Time          `bson:"totpConfirmedAt,omitempty"`
+	TOTPLastStep    int64              `bson:"totpLastStep,omitempty"`
+	TOTPFailures    int                `bson:"totpFailures,omitempty"`
+	TOTPLockedUntil time.Time          `bson:"totpLockedUntil,omitempty"`
+	CreatedAt       time.Time          `bson:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt"`
+}
+
+func (d userDoc) toDomain() *domain.User {
+	u := &domain.User{ID: d.ID.Hex(), EmailLower: d.EmailLower, PhoneE164: d.PhoneE164, UsernameLower: d.UsernameLower, PasswordHash: d.PasswordHash, PINHash: d.PINHash, PINFailures: d.PINFailures, Status: statusOrDefault(d.Status), Role: roleOrDefault(d.Role), KYCStatus: kycOrDefault(d.KYCStatus), KYCNote: d.KYCNote, KYCReviewedBy: d.KYCReviewedBy, TOTPLastStep: d.TOTPLastStep, TOTPFailures: d.TOTPFailures, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+	if !d.PINLockedUntil.IsZero() {
+		u.PINLockedUntil = d.PINLockedUntil.UTC()
+	}
+	if !d.KYCReviewedAt.IsZero() {
+		u.KYCReviewedAt = d.KYCReviewedAt.UTC()
+	}
+	if !d.PhoneVerifiedAt.IsZero() {
+		u.PhoneVerifiedAt = d.PhoneVerifiedAt.UTC()
+	}
+	if !d.TOTPConfirmedAt.IsZero() {
+		u.TOTPConfirmedAt = d.TOTPConfirmedAt.UTC()
+	}
+	if !d.TOTPLockedUntil.IsZero() {
+		u.TOTPLockedUntil = d.TOTPLockedUntil.UTC()
+	}
+	return u
+}
+
+type userStatusEventDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"userId"`
+	From      domain.UserStatus  `bson:"from"`
+	To        domain.UserStatus  `bson:"to"`
+	Reason    string             `bson:"reason"`
+	ActorID   string             `bson:"actorId"`
+	EntryIDs  []string           `bson:"entryIds,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+func (d userStatusEventDoc) toDomain() *domain.UserStatusEvent {
+	return &domain.UserStatusEvent{ID: d.ID.Hex(), UserID: d.UserID, From: d.From, To: d.To, Reason: d.Reason, ActorID: d.ActorID, EntryIDs: d.EntryIDs, CreatedAt: d.CreatedAt.UTC()}
+}
+
+func NewUserRepository(db *mongo.Database, timeout time.Duration, cipher *pii.Cipher) *UserRepository {
+	return &UserRepository{client: db.Client(), collection: db.Collection("users"), events: db.Collection("user_status_events"), outbox: newOutboxWriter(db), pii: cipher, timeout: timeout}
 }
 
+// EnsureIndexes makes the blind indexes unique. They are sparse because
+// users stored before encryption have none until they are re-encrypted.
+// Migration 1 drops the unique indexes on the plaintext fields and
+// migration 3 recreates them covering only users that still have them.
 func (r *UserRepository) EnsureIndexes(ctx context.Context) error {
 	models := []mongo.IndexModel{
-		{Keys: bson.D{{Key: "emailLower", Value: 1}}, Options: options.Index().SetName("uniq_emailLower").SetUnique(true)},
-		{Keys: bson.D{{Key: "phoneE164", Value: 1}}, Options: options.Index().SetName("uniq_phoneE164").SetUnique(true)},
+		{Keys: bson.D{{Key: "emailIndex", Value: 1}}, Options: options.Index().SetName("uniq_emailIndex").SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "phoneIndex", Value: 1}}, Options: options.Index().SetName("uniq_phoneIndex").SetUnique(true).SetSparse(true)},
 		{Keys: bson.D{{Key: "usernameLower", Value: 1}}, Options: options.Index().SetName("uniq_usernameLower").SetUnique(true)},
+		{Keys: bson.D{{Key: "kycStatus", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("kycStatus_id")},
+		{Keys: bson.D{{Key: "piiKeyId", Value: 1}}, Options: options.Index().SetName("piiKeyId")},
 	}
-	_, err := r.collection.Indexes().CreateMany(ctx, models)
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		return err
+	}
+	_, err := r.events.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("userId_createdAt")})
 	return err
 }
 
+// Create stores user and its user.signed_up event in one transaction. It
+// refuses an email or phone a user stored before encryption still holds in
+// plaintext.
 func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 	cctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
-	doc := bson.M{"emailLower": user.EmailLower, "phoneE164": user.PhoneE164, "usernameLower": user.UsernameLower, "passwordHash": user.PasswordHash, "status": user.Status, "createdAt": user.CreatedAt, "updatedAt": user.UpdatedAt}
-	res, err := r.collection.InsertOne(cctx, doc)
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	id := primitive.NewObjectID()
+	sealed, err := r.seal(cctx, id.Hex(), user.EmailLower, user.PhoneE164)
 	if err != nil {
-		if mongo.IsDuplicateKeyError(err) {
-			return domain.ErrUserExists
-		}
 		return err
 	}
-	id, ok := res.InsertedID.(primitive.ObjectID)
-	if !ok {
-		return fmt.Errorf("invalid inserted id")
+	doc := bson.M{"_id": id, "usernameLower": user.UsernameLower, "passwordHash": user.PasswordHash, "status": user.Status, "role": user.Role, "kycStatus": user.KYCStatus, "createdAt": user.CreatedAt, "updatedAt": user.UpdatedAt}
+	for k, v := range sealed {
+		doc[k] = v
+	}
+	_, err = sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		// Users not re-encrypted yet have no blind index for the unique
+		// indexes to compare against, so look for their plaintext.
+		legacy := bson.A{bson.M{"emailLower": user.EmailLower}}
+		if user.PhoneE164 != "" {
+			legacy = append(legacy, bson.M{"phoneE164": user.PhoneE164})
+		}
+		if n, err := r.collection.CountDocuments(sc, bson.M{"$or": legacy}, options.Count().SetLimit(1)); err != nil {
+			return nil, err
+		} else if n > 0 {
+			return nil, domain.ErrUserExists
+		}
+		if _, err := r.collection.InsertOne(sc, doc); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, domain.ErrUserExists
+			}
+			return nil, err
+		}
+		created := *user
+		created.ID = doc["_id"].(primitive.ObjectID).Hex()
+		return nil, r.outbox.append(sc, domain.NewUserSignedUpEvent(&created))
+	})
+	if err != nil {
+		return err
 	}
-	user.ID = id.Hex()
+	user.ID = doc["_id"].(primitive.ObjectID).Hex()
 	return nil
 }
 
@@ -60,16 +184,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User,
 	}
 	cctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
-	var out struct {
-		ID            primitive.ObjectID `bson:"_id"`
-		EmailLower    string             `bson:"emailLower"`
-		PhoneE164     string             `bson:"phoneE164"`
-		UsernameLower string             `bson:"usernameLower"`
-		PasswordHash  string             `bson:"passwordHash"`
-		Status        domain.UserStatus  `bson:"status"`
-		CreatedAt     time.Time          `bson:"createdAt"`
-		UpdatedAt     time.Time          `bson:"updatedAt"`
-	}
+	var out userDoc
 	err = r.collection.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, domain.ErrUserNotFound
@@ -77,30 +192,21 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User,
 	if err != nil {
 		return nil, err
 	}
-	return &domain.User{ID: out.ID.Hex(), EmailLower: out.EmailLower, PhoneE164: out.PhoneE164, UsernameLower: out.UsernameLower, PasswordHash: out.PasswordHash, Status: out.Status, CreatedAt: out.CreatedAt.UTC(), UpdatedAt: out.UpdatedAt.UTC()}, nil
+	return r.decode(cctx, out)
 }
 
 func (r *UserRepository) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
 	cctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
-	filter := bson.M{}
+	var filter bson.M
 	if strings.Contains(login, "@") {
-		filter["emailLower"] = strings.ToLower(login)
+		filter = r.contactFilter("emailIndex", "emailLower", piiFieldEmail, strings.ToLower(login))
 	} else if strings.HasPrefix(login, "+") {
-		filter["phoneE164"] = login
+		filter = r.contactFilter("phoneIndex", "phoneE164", piiFieldPhone, login)
 	} else {
-		filter["usernameLower"] = strings.ToLower(login)
-	}
-	var out struct {
-		ID            primitive.ObjectID `bson:"_id"`
-		EmailLower    string             `bson:"emailLower"`
-		PhoneE164     string             `bson:"phoneE164"`
-		UsernameLower string             `bson:"usernameLower"`
-		PasswordHash  string             `bson:"passwordHash"`
-		Status        domain.UserStatus  `bson:"status"`
-		CreatedAt     time.Time          `bson:"createdAt"`
-		UpdatedAt     time.Time          `bson:"updatedAt"`
+		filter = bson.M{"usernameLower": strings.ToLower(login)}
 	}
+	var out userDoc
 	err := r.collection.FindOne(cctx, filter).Decode(&out)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, domain.ErrUserNotFound
@@ -108,5 +214,401 @@ func (r *UserRepository) GetByLogin(ctx context.Context, login string) (*domain.
 	if err != nil {
 		return nil, err
 	}
-	return &domain.User{ID: out.ID.Hex(), EmailLower: out.EmailLower, PhoneE164: out.PhoneE164, UsernameLower: out.UsernameLower, PasswordHash: out.PasswordHash, Status: out.Status, CreatedAt: out.CreatedAt.UTC(), UpdatedAt: out.UpdatedAt.UTC()}, nil
+	return r.decode(cctx, out)
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, passwordHash string, at time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"passwordHash": passwordHash, "updatedAt": at}})
+}
+
+func (r *UserRepository) SetPIN(ctx context.Context, id, pinHash string, at time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"pinHash": pinHash, "updatedAt": at}, "$unset": bson.M{"pinFailures": "", "pinLockedUntil": ""}})
+}
+
+func (r *UserRepository) ClaimPINAttempt(ctx context.Context, id string, max int, now time.Time) (int, error) {
+	return r.claimAttempt(ctx, id, "pinFailures", "pinLockedUntil", max, now, domain.ErrPINLocked)
+}
+
+func (r *UserRepository) ResetPINFailures(ctx context.Context, id string) error {
+	return r.updateByID(ctx, id, bson.M{"$unset": bson.M{"pinFailures": ""}})
+}
+
+func (r *UserRepository) LockPIN(ctx context.Context, id string, until time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"pinLockedUntil": until}})
+}
+
+// totpState lists the fields a new TOTP secret resets.
+var totpState = bson.M{"totpConfirmedAt": "", "totpLastStep": "", "totpFailures": "", "totpLockedUntil": ""}
+
+func (r *UserRepository) SetTOTP(ctx context.Context, id, secret string, at time.Time) error {
+	if secret == "" {
+		unset := bson.M{"totpSecretEnc": ""}
+		for k, v := range totpState {
+			unset[k] = v
+		}
+		return r.updateByID(ctx, id, bson.M{"$set": bson.M{"updatedAt": at}, "$unset": unset})
+	}
+	sealed, err := r.pii.Seal(ctx, piiFieldTOTP, id, secret)
+	if err != nil {
+		return err
+	}
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"totpSecretEnc": sealed, "updatedAt": at}, "$unset": totpState})
+}
+
+// UseTOTPStep only matches while step is later than the last one used, so
+// two requests racing with the same code cannot both succeed.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id string, step int64, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrUserNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"_id": objID, "totpSecretEnc": bson.M{"$exists": true}, "$or": bson.A{bson.M{"totpLastStep": bson.M{"$lt": step}}, bson.M{"totpLastStep": bson.M{"$exists": false}}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"totpLastStep": step, "totpConfirmedAt": bson.M{"$ifNull": bson.A{"$totpConfirmedAt", at}}, "updatedAt": at}}},
+		{{Key: "$unset", Value: "totpFailures"}},
+	}
+	res, err := r.collection.UpdateOne(cctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		n, err := r.collection.CountDocuments(cctx, bson.M{"_id": objID})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return domain.ErrUserNotFound
+		}
+		return domain.ErrInvalidOTP
+	}
+	return nil
+}
+
+func (r *UserRepository) ClaimTOTPAttempt(ctx context.Context, id string, max int, now time.Time) (int, error) {
+	return r.claimAttempt(ctx, id, "totpFailures", "totpLockedUntil", max, now, domain.ErrTOTPLocked)
+}
+
+func (r *UserRepository) LockTOTP(ctx context.Context, id string, until time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"totpLockedUntil": until}})
+}
+
+// Search matches Query as a user ID, a whole email or phone number by
+// blind index, or a prefix of the username, so lookups stay on the unique
+// indexes. Sealed fields cannot be matched by prefix.
+func (r *UserRepository) Search(ctx context.Context, f domain.UserFilter) ([]*domain.User, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{}
+	if q := strings.TrimSpace(f.Query); q != "" {
+		if id, err := primitive.ObjectIDFromHex(q); err == nil {
+			filter["_id"] = id
+		} else if strings.Contains(q, "@") {
+			filter = r.contactFilter("emailIndex", "emailLower", piiFieldEmail, strings.ToLower(q))
+		} else if strings.HasPrefix(q, "+") {
+			filter = r.contactFilter("phoneIndex", "phoneE164", piiFieldPhone, q)
+		} else {
+			filter["usernameLower"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(q))}
+		}
+	}
+	if f.Status != "" {
+		filter["status"] = bson.M{"$in": storedStatuses(f.Status)}
+	}
+	if f.Role == domain.UserRoleCustomer {
+		filter["role"] = bson.M{"$in": bson.A{domain.UserRoleCustomer, "", nil}}
+	} else if f.Role != "" {
+		filter["role"] = f.Role
+	}
+	if f.KYCStatus == domain.KYCStatusPending {
+		filter["kycStatus"] = bson.M{"$in": bson.A{domain.KYCStatusPending, nil}}
+	} else if f.KYCStatus != "" {
+		filter["kycStatus"] = f.KYCStatus
+	}
+	cur, err := r.collection.Find(cctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(f.Limit)))
+	if err != nil {
+		return nil, err
+	}
+	var docs []userDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.User, 0, len(docs))
+	for _, d := range docs {
+		u, err := r.decode(cctx, d)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// TransitionStatus updates the user, records event and queues its
+// user.status_changed event in one transaction, so the history never
+// disagrees with the stored status.
+func (r *UserRepository) TransitionStatus(ctx context.Context, event *domain.UserStatusEvent) error {
+	objID, err := primitive.ObjectIDFromHex(event.UserID)
+	if err != nil {
+		return domain.ErrUserNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	sess, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(cctx)
+	id, err := sess.WithTransaction(cctx, func(sc mongo.SessionContext) (any, error) {
+		res, err := r.collection.UpdateOne(sc, bson.M{"_id": objID, "status": bson.M{"$in": storedStatuses(event.From)}}, bson.M{"$set": bson.M{"status": event.To, "updatedAt": event.CreatedAt}})
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 0 {
+			n, err := r.collection.CountDocuments(sc, bson.M{"_id": objID})
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return nil, domain.ErrUserNotFound
+			}
+			return nil, domain.ErrInvalidTransition
+		}
+		doc := userStatusEventDoc{UserID: event.UserID, From: event.From, To: event.To, Reason: event.Reason, ActorID: event.ActorID, EntryIDs: event.EntryIDs, CreatedAt: event.CreatedAt}
+		ins, err := r.events.InsertOne(sc, doc)
+		if err != nil {
+			return nil, err
+		}
+		eventID, ok := ins.InsertedID.(primitive.ObjectID)
+		if !ok {
+			return nil, fmt.Errorf("invalid inserted id")
+		}
+		recorded := *event
+		recorded.ID = eventID.Hex()
+		if err := r.outbox.append(sc, domain.NewUserStatusChangedEvent(&recorded)); err != nil {
+			return nil, err
+		}
+		return eventID, nil
+	})
+	if err != nil {
+		return err
+	}
+	event.ID = id.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *UserRepository) ListStatusEvents(ctx context.Context, userID string) ([]*domain.UserStatusEvent, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.events.Find(cctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []userStatusEventDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.UserStatusEvent, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, id string, role domain.UserRole, at time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"role": role, "updatedAt": at}})
+}
+
+func (r *UserRepository) SetKYC(ctx context.Context, id string, status domain.KYCStatus, note, reviewerID string, at time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"kycStatus": status, "kycNote": note, "kycReviewedBy": reviewerID, "kycReviewedAt": at, "updatedAt": at}})
+}
+
+func (r *UserRepository) MarkPhoneVerified(ctx context.Context, id string, at time.Time) error {
+	return r.updateByID(ctx, id, bson.M{"$set": bson.M{"phoneVerifiedAt": at, "updatedAt": at}})
+}
+
+// ReencryptPII re-seals up to limit users whose personal data is under a
+// key other than the current one, or still in plaintext, and returns how
+// many it updated. An update is skipped if the user was re-sealed or
+// enrolled a new TOTP secret concurrently.
+func (r *UserRepository) ReencryptPII(ctx context.Context, limit int) (int, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.collection.Find(cctx, bson.M{"piiKeyId": bson.M{"$ne": r.pii.CurrentKeyID()}}, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return 0, err
+	}
+	var docs []userDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range docs {
+		u, err := r.decode(cctx, d)
+		if err != nil {
+			return n, err
+		}
+		sealed, err := r.seal(cctx, u.ID, u.EmailLower, u.PhoneE164)
+		if err != nil {
+			return n, err
+		}
+		filter := bson.M{"_id": d.ID, "piiKeyId": d.PIIKeyID, "totpSecretEnc": d.TOTPSecretEnc}
+		if d.PIIKeyID == "" {
+			filter["piiKeyId"] = bson.M{"$exists": false}
+		}
+		if u.TOTPSecret != "" {
+			if sealed["totpSecretEnc"], err = r.pii.Seal(cctx, piiFieldTOTP, u.ID, u.TOTPSecret); err != nil {
+				return n, err
+			}
+		} else {
+			filter["totpSecretEnc"] = bson.M{"$exists": false}
+		}
+		res, err := r.collection.UpdateOne(cctx, filter, bson.M{"$set": sealed, "$unset": bson.M{"emailLower": "", "phoneE164": ""}})
+		if mongo.IsDuplicateKeyError(err) {
+			return n, fmt.Errorf("user %s shares its email or phone with another user: %w", u.ID, domain.ErrUserExists)
+		}
+		if err != nil {
+			return n, err
+		}
+		n += int(res.ModifiedCount)
+	}
+	return n, nil
+}
+
+// seal returns the stored fields for a user's email and phone. An empty
+// phone gets no index, so the sparse unique index skips it.
+func (r *UserRepository) seal(ctx context.Context, id, email, phone string) (bson.M, error) {
+	emailEnc, err := r.pii.Seal(ctx, piiFieldEmail, id, email)
+	if err != nil {
+		return nil, err
+	}
+	phoneEnc, err := r.pii.Seal(ctx, piiFieldPhone, id, phone)
+	if err != nil {
+		return nil, err
+	}
+	out := bson.M{"emailEnc": emailEnc, "emailIndex": r.pii.BlindIndex(piiFieldEmail, email), "piiKeyId": r.pii.CurrentKeyID()}
+	if phone != "" {
+		out["phoneEnc"], out["phoneIndex"] = phoneEnc, r.pii.BlindIndex(piiFieldPhone, phone)
+	}
+	return out, nil
+}
+
+// decode maps d to a user, opening its sealed fields.
+func (r *UserRepository) decode(ctx context.Context, d userDoc) (*domain.User, error) {
+	u := d.toDomain()
+	var err error
+	if d.EmailEnc != "" {
+		if u.EmailLower, err = r.pii.Open(ctx, piiFieldEmail, u.ID, d.EmailEnc); err != nil {
+			return nil, err
+		}
+	}
+	if d.PhoneEnc != "" {
+		if u.PhoneE164, err = r.pii.Open(ctx, piiFieldPhone, u.ID, d.PhoneEnc); err != nil {
+			return nil, err
+		}
+	}
+	if d.TOTPSecretEnc != "" {
+		if u.TOTPSecret, err = r.pii.Open(ctx, piiFieldTOTP, u.ID, d.TOTPSecretEnc); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// contactFilter matches value by its blind index, or in plaintext on users
+// not re-encrypted yet.
+func (r *UserRepository) contactFilter(indexField, legacyField, piiField, value string) bson.M {
+	return bson.M{"$or": bson.A{bson.M{indexField: r.pii.BlindIndex(piiField, value)}, bson.M{legacyField: value}}}
+}
+
+// claimAttempt counts an attempt in one conditional update. It matches
+// only while the lockout in lockedField has passed and the count in
+// failuresField is below max, or the count was left at max by a lockout
+// that has now passed; that lockout is lifted and the count capped at max,
+// so one more attempt is allowed before it locks again.
+func (r *UserRepository) claimAttempt(ctx context.Context, id, failuresField, lockedField string, max int, now time.Time, locked error) (int, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, domain.ErrUserNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{"_id": objID, "$and": bson.A{
+		bson.M{"$or": bson.A{bson.M{lockedField: bson.M{"$exists": false}}, bson.M{lockedField: bson.M{"$lte": now}}}},
+		bson.M{"$or": bson.A{bson.M{lockedField: bson.M{"$exists": true}}, bson.M{failuresField: bson.M{"$exists": false}}, bson.M{failuresField: bson.M{"$lt": max}}}},
+	}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{failuresField: bson.M{"$min": bson.A{bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + failuresField, 0}}, 1}}, max}}}}},
+		{{Key: "$unset", Value: lockedField}},
+	}
+	var out bson.Raw
+	err = r.collection.FindOneAndUpdate(cctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{failuresField: 1})).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		n, err := r.collection.CountDocuments(cctx, bson.M{"_id": objID})
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, domain.ErrUserNotFound
+		}
+		return 0, locked
+	}
+	if err != nil {
+		return 0, err
+	}
+	attempts, _ := out.Lookup(failuresField).AsInt64OK()
+	return int(attempts), nil
+}
+
+func (r *UserRepository) updateByID(ctx context.Context, id string, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrUserNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.collection.UpdateOne(cctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// roleOrDefault treats users stored before roles existed as customers.
+func roleOrDefault(role domain.UserRole) domain.UserRole {
+	if role == "" {
+		return domain.UserRoleCustomer
+	}
+	return role
+}
+
+// legacyStatusDisabled is what users switched off before the lifecycle
+// existed were stored with. They read as suspended until migration 2
+// rewrites them.
+const legacyStatusDisabled domain.UserStatus = "disabled"
+
+func statusOrDefault(status domain.UserStatus) domain.UserStatus {
+	if status == legacyStatusDisabled {
+		return domain.UserStatusSuspended
+	}
+	return status
+}
+
+// storedStatuses lists the stored values that read as status.
+func storedStatuses(status domain.UserStatus) bson.A {
+	if status == domain.UserStatusSuspended {
+		return bson.A{status, legacyStatusDisabled}
+	}
+	return bson.A{status}
+}
+
+// kycOrDefault treats users stored before KYC review existed as pending.
+func kycOrDefault(status domain.KYCStatus) domain.KYCStatus {
+	if status == "" {
+		return domain.KYCStatusPending
+	}
+	return status
 }
@@ -0,0 +1,93 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/pii"
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/repository/repositorytest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDB connects to TEST_MONGO_URI, which must be a replica set since
+// the repositories use transactions, and returns a database of its own,
+// dropped when the test ends.
+func testDB(t *testing.T) *mongo.Database {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("akiba_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return db
+}
+
+// testCipher encrypts PII with the development keys.
+func testCipher(t *testing.T) *pii.Cipher {
+	cipher, err := pii.NewCipher(pii.DevelopmentKeys(), pii.DevelopmentKeys().BlindIndexKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+// ensured creates repo's indexes before handing it to a contract suite.
+func ensured[R interface {
+	EnsureIndexes(ctx context.Context) error
+}](t *testing.T, repo R) R {
+	if err := repo.EnsureIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestUserRepositoryContract(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		return ensured(t, NewUserRepository(testDB(t), testTimeout, testCipher(t)))
+	})
+}
+
+func TestCreateRefusesContactsHeldInPlaintext(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	for _, m := range Migrations() {
+		if err := m.Up(ctx, db); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+	repo := ensured(t, NewUserRepository(db, testTimeout, testCipher(t)))
+	if _, err := db.Collection("users").InsertOne(ctx, bson.M{"emailLower": "old@example.com", "phoneE164": "+254700000100", "usernameLower": "old", "status": domain.UserStatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	for name, u := range map[string]*domain.User{
+		"email": {EmailLower: "old@example.com", PhoneE164: "+254700000101", UsernameLower: "new_1"},
+		"phone": {EmailLower: "new@example.com", PhoneE164: "+254700000100", UsernameLower: "new_2"},
+	} {
+		if err := repo.Create(ctx, u); !errors.Is(err, domain.ErrUserExists) {
+			t.Fatalf("%s held in plaintext: expected ErrUserExists, got %v", name, err)
+		}
+	}
+	if n, err := repo.ReencryptPII(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("expected the plaintext user sealed, got %d %v", n, err)
+	}
+	if got, err := repo.GetByLogin(ctx, "old@example.com"); err != nil || got.UsernameLower != "old" {
+		t.Fatalf("expected the sealed user found by email, got %+v %v", got, err)
+	}
+}
@@ -0,0 +1,293 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxWebhookHistory caps the attempts kept on a delivery.
+const maxWebhookHistory = 50
+
+type WebhookRepository struct {
+	endpoints  *mongo.Collection
+	deliveries *mongo.Collection
+	timeout    time.Duration
+}
+
+type webhookSecretDoc struct {
+	Secret    string    `bson:"secret"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt,omitempty"`
+}
+
+type webhookEndpointDoc struct {
+	ID         primitive.ObjectID           `bson:"_id,omitempty"`
+	ClientID   string                       `bson:"clientId"`
+	URL        string                       `bson:"url"`
+	EventTypes []domain.EventType           `bson:"eventTypes"`
+	Secrets    []webhookSecretDoc           `bson:"secrets"`
+	Status     domain.WebhookEndpointStatus `bson:"status"`
+	CreatedBy  string                       `bson:"createdBy"`
+	CreatedAt  time.Time                    `bson:"createdAt"`
+	UpdatedAt  time.Time                    `bson:"updatedAt"`
+}
+
+func (d webhookEndpointDoc) toDomain() *domain.WebhookEndpoint {
+	secrets := make([]domain.WebhookSecret, 0, len(d.Secrets))
+	for _, s := range d.Secrets {
+		secret := domain.WebhookSecret{Secret: s.Secret, CreatedAt: s.CreatedAt.UTC()}
+		if !s.ExpiresAt.IsZero() {
+			secret.ExpiresAt = s.ExpiresAt.UTC()
+		}
+		secrets = append(secrets, secret)
+	}
+	return &domain.WebhookEndpoint{ID: d.ID.Hex(), ClientID: d.ClientID, URL: d.URL, EventTypes: d.EventTypes, Secrets: secrets, Status: d.Status, CreatedBy: d.CreatedBy, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+func webhookSecretDocs(secrets []domain.WebhookSecret) []webhookSecretDoc {
+	out := make([]webhookSecretDoc, 0, len(secrets))
+	for _, s := range secrets {
+		out = append(out, webhookSecretDoc{Secret: s.Secret, CreatedAt: s.CreatedAt, ExpiresAt: s.ExpiresAt})
+	}
+	return out
+}
+
+type webhookAttemptDoc struct {
+	At         time.Time `bson:"at"`
+	StatusCode int       `bson:"statusCode,omitempty"`
+	Error      string    `bson:"error,omitempty"`
+	DurationMs int64     `bson:"durationMs"`
+}
+
+type webhookDeliveryDoc struct {
+	ID            primitive.ObjectID           `bson:"_id,omitempty"`
+	EndpointID    string                       `bson:"endpointId"`
+	ClientID      string                       `bson:"clientId"`
+	EventID       string                       `bson:"eventId"`
+	EventType     domain.EventType             `bson:"eventType"`
+	Payload       []byte                       `bson:"payload"`
+	Status        domain.WebhookDeliveryStatus `bson:"status"`
+	Attempts      int                          `bson:"attempts"`
+	NextAttemptAt time.Time                    `bson:"nextAttemptAt"`
+	History       []webhookAttemptDoc          `bson:"history,omitempty"`
+	CreatedAt     time.Time                    `bson:"createdAt"`
+	UpdatedAt     time.Time                    `bson:"updatedAt"`
+}
+
+func (d webhookDeliveryDoc) toDomain() *domain.WebhookDelivery {
+	history := make([]domain.WebhookAttempt, 0, len(d.History))
+	for _, a := range d.History {
+		history = append(history, domain.WebhookAttempt{At: a.At.UTC(), StatusCode: a.StatusCode, Error: a.Error, DurationMs: a.DurationMs})
+	}
+	return &domain.WebhookDelivery{ID: d.ID.Hex(), EndpointID: d.EndpointID, ClientID: d.ClientID, EventID: d.EventID, EventType: d.EventType, Payload: d.Payload, Status: d.Status, Attempts: d.Attempts, NextAttemptAt: d.NextAttemptAt.UTC(), History: history, CreatedAt: d.CreatedAt.UTC(), UpdatedAt: d.UpdatedAt.UTC()}
+}
+
+func NewWebhookRepository(db *mongo.Database, timeout time.Duration) *WebhookRepository {
+	return &WebhookRepository{endpoints: db.Collection("webhook_endpoints"), deliveries: db.Collection("webhook_deliveries"), timeout: timeout}
+}
+
+func (r *WebhookRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.endpoints.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "clientId", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("clientId_id")},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "eventTypes", Value: 1}}, Options: options.Index().SetName("status_eventTypes")},
+	}); err != nil {
+		return err
+	}
+	_, err := r.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "endpointId", Value: 1}, {Key: "eventId", Value: 1}}, Options: options.Index().SetName("uniq_endpoint_event").SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}, Options: options.Index().SetName("status_nextAttemptAt")},
+		{Keys: bson.D{{Key: "endpointId", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("endpointId_id")},
+	})
+	return err
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := webhookEndpointDoc{ClientID: e.ClientID, URL: e.URL, EventTypes: e.EventTypes, Secrets: webhookSecretDocs(e.Secrets), Status: e.Status, CreatedBy: e.CreatedBy, CreatedAt: e.CreatedAt, UpdatedAt: e.UpdatedAt}
+	res, err := r.endpoints.InsertOne(cctx, doc)
+	if err != nil {
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	e.ID = id.Hex()
+	return nil
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, id string) (*domain.WebhookEndpoint, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrWebhookNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out webhookEndpointDoc
+	err = r.endpoints.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, clientID string) ([]*domain.WebhookEndpoint, error) {
+	filter := bson.M{}
+	if clientID != "" {
+		filter["clientId"] = clientID
+	}
+	return r.findEndpoints(ctx, filter)
+}
+
+func (r *WebhookRepository) ListSubscribed(ctx context.Context, eventType domain.EventType) ([]*domain.WebhookEndpoint, error) {
+	return r.findEndpoints(ctx, bson.M{"status": domain.WebhookEndpointActive, "eventTypes": bson.M{"$in": bson.A{eventType, domain.WebhookEventAll}}})
+}
+
+func (r *WebhookRepository) findEndpoints(ctx context.Context, filter bson.M) ([]*domain.WebhookEndpoint, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.endpoints.Find(cctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []webhookEndpointDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.WebhookEndpoint, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *WebhookRepository) SetSecrets(ctx context.Context, id string, secrets []domain.WebhookSecret, at time.Time) error {
+	return r.updateEndpoint(ctx, id, bson.M{"secrets": webhookSecretDocs(secrets), "updatedAt": at})
+}
+
+func (r *WebhookRepository) SetEndpointStatus(ctx context.Context, id string, status domain.WebhookEndpointStatus, at time.Time) error {
+	return r.updateEndpoint(ctx, id, bson.M{"status": status, "updatedAt": at})
+}
+
+func (r *WebhookRepository) updateEndpoint(ctx context.Context, id string, set bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrWebhookNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.endpoints.UpdateOne(cctx, bson.M{"_id": objID}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	doc := webhookDeliveryDoc{EndpointID: d.EndpointID, ClientID: d.ClientID, EventID: d.EventID, EventType: d.EventType, Payload: d.Payload, Status: d.Status, Attempts: d.Attempts, NextAttemptAt: d.NextAttemptAt, CreatedAt: d.CreatedAt, UpdatedAt: d.UpdatedAt}
+	res, err := r.deliveries.InsertOne(cctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	id, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("invalid inserted id")
+	}
+	d.ID = id.Hex()
+	return nil
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var out webhookDeliveryDoc
+	err = r.deliveries.FindOne(cctx, bson.M{"_id": objID}).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.toDomain(), nil
+}
+
+func (r *WebhookRepository) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	filter := bson.M{"status": domain.WebhookDeliveryPending, "nextAttemptAt": bson.M{"$lte": now}}
+	return r.findDeliveries(ctx, filter, options.Find().SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error) {
+	return r.findDeliveries(ctx, bson.M{"endpointId": endpointID}, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit)))
+}
+
+func (r *WebhookRepository) findDeliveries(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*domain.WebhookDelivery, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cur, err := r.deliveries.Find(cctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []webhookDeliveryDoc
+	if err := cur.All(cctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]*domain.WebhookDelivery, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.toDomain())
+	}
+	return out, nil
+}
+
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, id string, attempt domain.WebhookAttempt, status domain.WebhookDeliveryStatus, attempts int, nextAttemptAt time.Time) error {
+	history := webhookAttemptDoc{At: attempt.At, StatusCode: attempt.StatusCode, Error: attempt.Error, DurationMs: attempt.DurationMs}
+	return r.updateDelivery(ctx, id, bson.M{
+		"$set":  bson.M{"status": status, "attempts": attempts, "nextAttemptAt": nextAttemptAt, "updatedAt": attempt.At},
+		"$push": bson.M{"history": bson.M{"$each": bson.A{history}, "$slice": -maxWebhookHistory}},
+	})
+}
+
+func (r *WebhookRepository) Requeue(ctx context.Context, id string, at time.Time) error {
+	return r.updateDelivery(ctx, id, bson.M{"$set": bson.M{"status": domain.WebhookDeliveryPending, "attempts": 0, "nextAttemptAt": at, "updatedAt": at}})
+}
+
+func (r *WebhookRepository) updateDelivery(ctx context.Context, id string, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrDeliveryNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.deliveries.UpdateOne(cctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrDeliveryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"akiba/backend/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// publishedOutboxRetention is how long published events stay in the outbox
+// for troubleshooting before MarkPublished deletes them.
+const publishedOutboxRetention = 7 * 24 * time.Hour
+
+const outboxColumns = `id, type, aggregate_type, aggregate_id, seq, payload, status, attempts, last_error, next_attempt_at, occurred_at, published_at, dead_at`
+
+var outboxSchema = []string{
+	`CREATE TABLE IF NOT EXISTS outbox_sequences (
+		aggregate_key text PRIMARY KEY,
+		seq bigint NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+		id bigserial PRIMARY KEY,
+		type text NOT NULL,
+		aggregate_type text NOT NULL,
+		aggregate_id text NOT NULL,
+		seq bigint NOT NULL,
+		payload bytea NOT NULL,
+		status text NOT NULL,
+		attempts integer NOT NULL DEFAULT 0,
+		last_error text NOT NULL DEFAULT '',
+		next_attempt_at timestamptz NOT NULL,
+		occurred_at timestamptz NOT NULL,
+		published_at timestamptz,
+		dead_at timestamptz,
+		CONSTRAINT uniq_aggregate_seq UNIQUE (aggregate_type, aggregate_id, seq)
+	)`,
+	`CREATE INDEX IF NOT EXISTS outbox_status_next_attempt_at ON outbox (status, next_attempt_at, id)`,
+	`CREATE INDEX IF NOT EXISTS outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS outbox_dead_letters (LIKE outbox)`,
+	`CREATE INDEX IF NOT EXISTS outbox_dead_letters_dead_at ON outbox_dead_letters (dead_at DESC)`,
+}
+
+// appendOutbox adds event inside tx. Bumping the aggregate's counter row
+// locks it, so concurrent transactions on the same aggregate serialize and
+// Seq follows commit order.
+func appendOutbox(ctx context.Context, tx pgx.Tx, event *domain.OutboxEvent) error {
+	err := tx.QueryRow(ctx, `INSERT INTO outbox_sequences (aggregate_key, seq) VALUES ($1, 1)
+		ON CONFLICT (aggregate_key) DO UPDATE SET seq = outbox_sequences.seq + 1
+		RETURNING seq`, event.AggregateKey()).Scan(&event.Seq)
+	if err != nil {
+		return err
+	}
+	var id int64
+	err = tx.QueryRow(ctx, `INSERT INTO outbox (type, aggregate_type, aggregate_id, seq, payload, status, attempts, last_error, next_attempt_at, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		event.Type, event.AggregateType, event.AggregateID, event.Seq, []byte(event.Payload), event.Status, event.Attempts, event.LastError, event.NextAttemptAt, event.OccurredAt).Scan(&id)
+	if err != nil {
+		return err
+	}
+	event.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+// OutboxRepository serves the relay for events written by the Postgres
+// repositories. Events that exhaust their attempts move to the
+// outbox_dead_letters table.
+type OutboxRepository struct {
+	pool    *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool, timeout time.Duration) *OutboxRepository {
+	return &OutboxRepository{pool: pool, timeout: timeout}
+}
+
+func (r *OutboxRepository) EnsureIndexes(ctx context.Context) error {
+	return execAll(ctx, r.pool, outboxSchema)
+}
+
+func (r *OutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxEvent, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.pool.Query(cctx, `SELECT `+outboxColumns+` FROM outbox WHERE status = $1 AND next_attempt_at <= $2 ORDER BY id LIMIT $3`, domain.OutboxStatusPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanOutboxEvent)
+}
+
+func (r *OutboxRepository) PendingBefore(ctx context.Context, event *domain.OutboxEvent) (bool, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var pending bool
+	err := r.pool.QueryRow(cctx, `SELECT EXISTS (SELECT 1 FROM outbox WHERE aggregate_type = $1 AND aggregate_id = $2 AND seq < $3 AND status = $4)`,
+		event.AggregateType, event.AggregateID, event.Seq, domain.OutboxStatusPending).Scan(&pending)
+	return pending, err
+}
+
+// MarkPublished also deletes events published longer ago than
+// publishedOutboxRetention, standing in for Mongo's TTL index.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	if err := r.updatePending(ctx, id, `status = $3, published_at = $4`, domain.OutboxStatusPublished, at); err != nil {
+		return err
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	_, err := r.pool.Exec(cctx, `DELETE FROM outbox WHERE published_at < $1`, at.Add(-publishedOutboxRetention))
+	return err
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, attempts int, lastError string, nextAttemptAt time.Time) error {
+	return r.updatePending(ctx, id, `attempts = $3, last_error = $4, next_attempt_at = $5`, attempts, lastError, nextAttemptAt)
+}
+
+// updatePending applies set, whose placeholders start at $3, to the
+// pending event id.
+func (r *OutboxRepository) updatePending(ctx context.Context, id, set string, args ...any) error {
+	eventID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return domain.ErrEventNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	tag, err := r.pool.Exec(cctx, `UPDATE outbox SET `+set+` WHERE id = $1 AND status = $2`, append([]any{eventID, domain.OutboxStatusPending}, args...)...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrEventNotFound
+	}
+	return nil
+}
+
+func (r *OutboxRepository) DeadLetter(ctx context.Context, event *domain.OutboxEvent) error {
+	eventID, err := strconv.ParseInt(event.ID, 10, 64)
+	if err != nil {
+		return domain.ErrEventNotFound
+	}
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return inTx(cctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(cctx, `DELETE FROM outbox WHERE id = $1 AND status = $2`, eventID, domain.OutboxStatusPending)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return domain.ErrEventNotFound
+		}
+		_, err = tx.Exec(cctx, `INSERT INTO outbox_dead_letters (`+outboxColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			eventID, event.Type, event.AggregateType, event.AggregateID, event.Seq, []byte(event.Payload), event.Status, event.Attempts, event.LastError, event.NextAttemptAt, event.OccurredAt, nullTime(event.PublishedAt), nullTime(event.DeadAt))
+		return err
+	})
+}
+
+func scanOutboxEvent(row pgx.CollectableRow) (*domain.OutboxEvent, error) {
+	var (
+		e                 domain.OutboxEvent
+		id                int64
+		payload           []byte
+		published, deadAt *time.Time
+	)
+	if err := row.Scan(&id, &e.Type, &e.AggregateType, &e.AggregateID, &e.Seq, &payload, &e.Status, &e.Attempts, &e.LastError, &e.NextAttemptAt, &e.OccurredAt, &published, &deadAt); err != nil {
+		return nil, err
+	}
+	e.ID, e.Payload = strconv.FormatInt(id, 10), payload
+	e.NextAttemptAt, e.OccurredAt = e.NextAttemptAt.UTC(), e.OccurredAt.UTC()
+	e.PublishedAt, e.DeadAt = timeOrZero(published), timeOrZero(deadAt)
+	return &e, nil
+}
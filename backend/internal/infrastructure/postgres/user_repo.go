@@ -0,0 +1,492 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/pii"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Fields a user's personal data is sealed for. They match the Mongo
+// repository's, so sealed values and blind indexes carry over between
+// backends.
+const (
+	piiFieldEmail = "users.email"
+	piiFieldPhone = "users.phone"
+	piiFieldTOTP  = "users.totpSecret"
+)
+
+const userColumns = `id, email_enc, phone_enc, username_lower, password_hash, pin_hash, pin_failures, pin_locked_until, status, role, kyc_status, kyc_note, kyc_reviewed_by, kyc_reviewed_at, phone_verified_at, totp_secret_enc, totp_confirmed_at, totp_last_step, totp_failures, totp_locked_until, pii_key_id, created_at, updated_at`
+
+// userSchema mirrors the Mongo unique indexes: email and phone are unique
+// by blind index, usernames in lower case. The ALTER adds the TOTP columns
+// to tables created before them.
+var userSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id text PRIMARY KEY,
+		email_enc text NOT NULL,
+		email_index text NOT NULL,
+		phone_enc text NOT NULL DEFAULT '',
+		phone_index text,
+		pii_key_id text NOT NULL,
+		username_lower text NOT NULL,
+		password_hash text NOT NULL,
+		pin_hash text NOT NULL DEFAULT '',
+		pin_failures integer NOT NULL DEFAULT 0,
+		pin_locked_until timestamptz,
+		status text NOT NULL,
+		role text NOT NULL,
+		kyc_status text NOT NULL,
+		kyc_note text NOT NULL DEFAULT '',
+		kyc_reviewed_by text NOT NULL DEFAULT '',
+		kyc_reviewed_at timestamptz,
+		phone_verified_at timestamptz,
+		totp_secret_enc text NOT NULL DEFAULT '',
+		totp_confirmed_at timestamptz,
+		totp_last_step bigint NOT NULL DEFAULT 0,
+		totp_failures integer NOT NULL DEFAULT 0,
+		totp_locked_until timestamptz,
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+		CONSTRAINT uniq_email_index UNIQUE (email_index),
+		CONSTRAINT uniq_phone_index UNIQUE (phone_index),
+		CONSTRAINT uniq_username_lower UNIQUE (username_lower)
+	)`,
+	`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS totp_secret_enc text NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS totp_confirmed_at timestamptz,
+		ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS totp_failures integer NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS totp_locked_until timestamptz`,
+	`CREATE INDEX IF NOT EXISTS users_kyc_status_id ON users (kyc_status, id DESC)`,
+	`CREATE INDEX IF NOT EXISTS users_pii_key_id ON users (pii_key_id)`,
+	`CREATE TABLE IF NOT EXISTS user_status_events (
+		id bigserial PRIMARY KEY,
+		user_id text NOT NULL REFERENCES users (id),
+		from_status text NOT NULL,
+		to_status text NOT NULL,
+		reason text NOT NULL,
+		actor_id text NOT NULL,
+		entry_ids text[],
+		created_at timestamptz NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS user_status_events_user_id_created_at ON user_status_events (user_id, created_at)`,
+}
+
+var objectIDPattern = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// UserRepository stores users in PostgreSQL with email and phone sealed by
+// pii.Cipher, like the Mongo repository. Its events go to the Postgres
+// outbox in the same transaction.
+type UserRepository struct {
+	pool    *pgxpool.Pool
+	pii     *pii.Cipher
+	timeout time.Duration
+}
+
+func NewUserRepository(pool *pgxpool.Pool, timeout time.Duration, cipher *pii.Cipher) *UserRepository {
+	return &UserRepository{pool: pool, pii: cipher, timeout: timeout}
+}
+
+// EnsureIndexes creates the user tables, their constraints and the outbox
+// the user events are written to.
+func (r *UserRepository) EnsureIndexes(ctx context.Context) error {
+	if err := execAll(ctx, r.pool, outboxSchema); err != nil {
+		return err
+	}
+	return execAll(ctx, r.pool, userSchema)
+}
+
+// Create stores user and its user.signed_up event in one transaction.
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id := newID(user.CreatedAt)
+	s, err := r.seal(cctx, id, user.EmailLower, user.PhoneE164)
+	if err != nil {
+		return err
+	}
+	err = inTx(cctx, r.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(cctx, `INSERT INTO users (id, email_enc, email_index, phone_enc, phone_index, pii_key_id, username_lower, password_hash, status, role, kyc_status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			id, s.emailEnc, s.emailIndex, s.phoneEnc, nullString(s.phoneIndex), s.keyID, user.UsernameLower, user.PasswordHash, user.Status, user.Role, user.KYCStatus, user.CreatedAt, user.UpdatedAt)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return domain.ErrUserExists
+			}
+			return err
+		}
+		created := *user
+		created.ID = id
+		return appendOutbox(cctx, tx, domain.NewUserSignedUpEvent(&created))
+	})
+	if err != nil {
+		return err
+	}
+	user.ID = id
+	return nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.getOne(ctx, `id = $1`, id)
+}
+
+func (r *UserRepository) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	if strings.Contains(login, "@") {
+		return r.getOne(ctx, `email_index = $1`, r.pii.BlindIndex(piiFieldEmail, strings.ToLower(login)))
+	}
+	if strings.HasPrefix(login, "+") {
+		return r.getOne(ctx, `phone_index = $1`, r.pii.BlindIndex(piiFieldPhone, login))
+	}
+	return r.getOne(ctx, `username_lower = $1`, strings.ToLower(login))
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, passwordHash string, at time.Time) error {
+	return r.updateByID(ctx, id, `password_hash = $2, updated_at = $3`, passwordHash, at)
+}
+
+func (r *UserRepository) SetPIN(ctx context.Context, id, pinHash string, at time.Time) error {
+	return r.updateByID(ctx, id, `pin_hash = $2, updated_at = $3, pin_failures = 0, pin_locked_until = NULL`, pinHash, at)
+}
+
+func (r *UserRepository) ClaimPINAttempt(ctx context.Context, id string, max int, now time.Time) (int, error) {
+	return r.claimAttempt(ctx, id, "pin_failures", "pin_locked_until", max, now, domain.ErrPINLocked)
+}
+
+func (r *UserRepository) ResetPINFailures(ctx context.Context, id string) error {
+	return r.updateByID(ctx, id, `pin_failures = 0`)
+}
+
+func (r *UserRepository) LockPIN(ctx context.Context, id string, until time.Time) error {
+	return r.updateByID(ctx, id, `pin_locked_until = $2`, until)
+}
+
+func (r *UserRepository) SetTOTP(ctx context.Context, id, secret string, at time.Time) error {
+	sealed := ""
+	if secret != "" {
+		var err error
+		if sealed, err = r.pii.Seal(ctx, piiFieldTOTP, id, secret); err != nil {
+			return err
+		}
+	}
+	return r.updateByID(ctx, id, `totp_secret_enc = $2, totp_confirmed_at = NULL, totp_last_step = 0, totp_failures = 0, totp_locked_until = NULL, updated_at = $3`, sealed, at)
+}
+
+// UseTOTPStep only matches while step is later than the last one used, so
+// two requests racing with the same code cannot both succeed.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id string, step int64, at time.Time) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	tag, err := r.pool.Exec(cctx, `UPDATE users SET totp_last_step = $2, totp_confirmed_at = COALESCE(totp_confirmed_at, $3), totp_failures = 0, updated_at = $3
+		WHERE id = $1 AND totp_secret_enc <> '' AND totp_last_step < $2`, id, step, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	var exists bool
+	if err := r.pool.QueryRow(cctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrInvalidOTP
+}
+
+func (r *UserRepository) ClaimTOTPAttempt(ctx context.Context, id string, max int, now time.Time) (int, error) {
+	return r.claimAttempt(ctx, id, "totp_failures", "totp_locked_until", max, now, domain.ErrTOTPLocked)
+}
+
+func (r *UserRepository) LockTOTP(ctx context.Context, id string, until time.Time) error {
+	return r.updateByID(ctx, id, `totp_locked_until = $2`, until)
+}
+
+// Search matches Query as a user ID, a whole email or phone number by
+// blind index, or a prefix of the username.
+func (r *UserRepository) Search(ctx context.Context, f domain.UserFilter) ([]*domain.User, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if q := strings.TrimSpace(f.Query); q != "" {
+		switch {
+		case objectIDPattern.MatchString(q):
+			where = append(where, "id = "+arg(q))
+		case strings.Contains(q, "@"):
+			where = append(where, "email_index = "+arg(r.pii.BlindIndex(piiFieldEmail, strings.ToLower(q))))
+		case strings.HasPrefix(q, "+"):
+			where = append(where, "phone_index = "+arg(r.pii.BlindIndex(piiFieldPhone, q)))
+		default:
+			where = append(where, "starts_with(username_lower, "+arg(strings.ToLower(q))+")")
+		}
+	}
+	if f.Status != "" {
+		where = append(where, "status = "+arg(f.Status))
+	}
+	if f.Role != "" {
+		where = append(where, "role = "+arg(f.Role))
+	}
+	if f.KYCStatus != "" {
+		where = append(where, "kyc_status = "+arg(f.KYCStatus))
+	}
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + arg(f.Limit)
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.pool.Query(cctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := pgx.CollectRows(rows, scanUserRow)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*domain.User, 0, len(stored))
+	for _, row := range stored {
+		u, err := r.decode(cctx, row)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// TransitionStatus updates the user, records event and queues its
+// user.status_changed event in one transaction, so the history never
+// disagrees with the stored status.
+func (r *UserRepository) TransitionStatus(ctx context.Context, event *domain.UserStatusEvent) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var eventID int64
+	err := inTx(cctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(cctx, `UPDATE users SET status = $3, updated_at = $4 WHERE id = $1 AND status = $2`, event.UserID, event.From, event.To, event.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			var exists bool
+			if err := tx.QueryRow(cctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, event.UserID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return domain.ErrUserNotFound
+			}
+			return domain.ErrInvalidTransition
+		}
+		err = tx.QueryRow(cctx, `INSERT INTO user_status_events (user_id, from_status, to_status, reason, actor_id, entry_ids, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			event.UserID, event.From, event.To, event.Reason, event.ActorID, event.EntryIDs, event.CreatedAt).Scan(&eventID)
+		if err != nil {
+			return err
+		}
+		recorded := *event
+		recorded.ID = strconv.FormatInt(eventID, 10)
+		return appendOutbox(cctx, tx, domain.NewUserStatusChangedEvent(&recorded))
+	})
+	if err != nil {
+		return err
+	}
+	event.ID = strconv.FormatInt(eventID, 10)
+	return nil
+}
+
+func (r *UserRepository) ListStatusEvents(ctx context.Context, userID string) ([]*domain.UserStatusEvent, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.pool.Query(cctx, `SELECT id, user_id, from_status, to_status, reason, actor_id, entry_ids, created_at FROM user_status_events WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*domain.UserStatusEvent, error) {
+		var e domain.UserStatusEvent
+		var id int64
+		if err := row.Scan(&id, &e.UserID, &e.From, &e.To, &e.Reason, &e.ActorID, &e.EntryIDs, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.ID, e.CreatedAt = strconv.FormatInt(id, 10), e.CreatedAt.UTC()
+		return &e, nil
+	})
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, id string, role domain.UserRole, at time.Time) error {
+	return r.updateByID(ctx, id, `role = $2, updated_at = $3`, role, at)
+}
+
+func (r *UserRepository) SetKYC(ctx context.Context, id string, status domain.KYCStatus, note, reviewerID string, at time.Time) error {
+	return r.updateByID(ctx, id, `kyc_status = $2, kyc_note = $3, kyc_reviewed_by = $4, kyc_reviewed_at = $5, updated_at = $5`, status, note, reviewerID, at)
+}
+
+func (r *UserRepository) MarkPhoneVerified(ctx context.Context, id string, at time.Time) error {
+	return r.updateByID(ctx, id, `phone_verified_at = $2, updated_at = $2`, at)
+}
+
+// ReencryptPII re-seals up to limit users whose personal data is under a
+// key other than the current one and returns how many it updated. An
+// update is skipped if the user was re-sealed or enrolled a new TOTP
+// secret concurrently.
+func (r *UserRepository) ReencryptPII(ctx context.Context, limit int) (int, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.pool.Query(cctx, `SELECT `+userColumns+` FROM users WHERE pii_key_id <> $1 LIMIT $2`, r.pii.CurrentKeyID(), limit)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := pgx.CollectRows(rows, scanUserRow)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, row := range stored {
+		u, err := r.decode(cctx, row)
+		if err != nil {
+			return n, err
+		}
+		s, err := r.seal(cctx, u.ID, u.EmailLower, u.PhoneE164)
+		if err != nil {
+			return n, err
+		}
+		totpEnc := ""
+		if u.TOTPSecret != "" {
+			if totpEnc, err = r.pii.Seal(cctx, piiFieldTOTP, u.ID, u.TOTPSecret); err != nil {
+				return n, err
+			}
+		}
+		tag, err := r.pool.Exec(cctx, `UPDATE users SET email_enc = $3, phone_enc = $4, totp_secret_enc = $5, pii_key_id = $6 WHERE id = $1 AND pii_key_id = $2 AND totp_secret_enc = $7`, u.ID, row.keyID, s.emailEnc, s.phoneEnc, totpEnc, s.keyID, row.totpEnc)
+		if err != nil {
+			return n, err
+		}
+		n += int(tag.RowsAffected())
+	}
+	return n, nil
+}
+
+// sealedContact is the stored form of a user's email and phone.
+type sealedContact struct {
+	emailEnc, emailIndex, phoneEnc, phoneIndex, keyID string
+}
+
+func (r *UserRepository) seal(ctx context.Context, id, email, phone string) (sealedContact, error) {
+	emailEnc, err := r.pii.Seal(ctx, piiFieldEmail, id, email)
+	if err != nil {
+		return sealedContact{}, err
+	}
+	phoneEnc, err := r.pii.Seal(ctx, piiFieldPhone, id, phone)
+	if err != nil {
+		return sealedContact{}, err
+	}
+	return sealedContact{emailEnc: emailEnc, emailIndex: r.pii.BlindIndex(piiFieldEmail, email), phoneEnc: phoneEnc, phoneIndex: r.pii.BlindIndex(piiFieldPhone, phone), keyID: r.pii.CurrentKeyID()}, nil
+}
+
+// userRow is a users row with its sealed fields still sealed.
+type userRow struct {
+	user               domain.User
+	emailEnc, phoneEnc string
+	totpEnc            string
+	keyID              string
+}
+
+func scanUserRow(row pgx.CollectableRow) (userRow, error) {
+	var (
+		out                                   userRow
+		u                                     = &out.user
+		lockedUntil, reviewedAt, phoneChecked *time.Time
+		totpConfirmed, totpLocked             *time.Time
+	)
+	err := row.Scan(&u.ID, &out.emailEnc, &out.phoneEnc, &u.UsernameLower, &u.PasswordHash, &u.PINHash, &u.PINFailures, &lockedUntil, &u.Status, &u.Role, &u.KYCStatus, &u.KYCNote, &u.KYCReviewedBy, &reviewedAt, &phoneChecked, &out.totpEnc, &totpConfirmed, &u.TOTPLastStep, &u.TOTPFailures, &totpLocked, &out.keyID, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return userRow{}, err
+	}
+	u.PINLockedUntil, u.KYCReviewedAt, u.PhoneVerifiedAt = timeOrZero(lockedUntil), timeOrZero(reviewedAt), timeOrZero(phoneChecked)
+	u.TOTPConfirmedAt, u.TOTPLockedUntil = timeOrZero(totpConfirmed), timeOrZero(totpLocked)
+	u.CreatedAt, u.UpdatedAt = u.CreatedAt.UTC(), u.UpdatedAt.UTC()
+	return out, nil
+}
+
+// decode opens the row's sealed fields.
+func (r *UserRepository) decode(ctx context.Context, row userRow) (*domain.User, error) {
+	u := row.user
+	var err error
+	if u.EmailLower, err = r.pii.Open(ctx, piiFieldEmail, u.ID, row.emailEnc); err != nil {
+		return nil, err
+	}
+	if u.PhoneE164, err = r.pii.Open(ctx, piiFieldPhone, u.ID, row.phoneEnc); err != nil {
+		return nil, err
+	}
+	if row.totpEnc != "" {
+		if u.TOTPSecret, err = r.pii.Open(ctx, piiFieldTOTP, u.ID, row.totpEnc); err != nil {
+			return nil, err
+		}
+	}
+	return &u, nil
+}
+
+func (r *UserRepository) getOne(ctx context.Context, where string, arg any) (*domain.User, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.pool.Query(cctx, `SELECT `+userColumns+` FROM users WHERE `+where, arg)
+	if err != nil {
+		return nil, err
+	}
+	row, err := pgx.CollectExactlyOneRow(rows, scanUserRow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.decode(cctx, row)
+}
+
+// updateByID applies set, whose placeholders start at $2, to the user id.
+// claimAttempt counts an attempt in one conditional update. It matches
+// only while the lockout has passed and fewer than max attempts are
+// uncleared, or the count was left at max by a lockout that has now
+// passed; that lockout is lifted so one more attempt is allowed.
+func (r *UserRepository) claimAttempt(ctx context.Context, id, failures, lockedUntil string, max int, now time.Time, locked error) (int, error) {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var attempts int
+	err := r.pool.QueryRow(cctx, `UPDATE users SET `+failures+` = LEAST(`+failures+` + 1, $2), `+lockedUntil+` = NULL
+		WHERE id = $1 AND (`+lockedUntil+` IS NULL OR `+lockedUntil+` <= $3) AND (`+failures+` < $2 OR `+lockedUntil+` IS NOT NULL)
+		RETURNING `+failures, id, max, now).Scan(&attempts)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return attempts, err
+	}
+	var exists bool
+	if err := r.pool.QueryRow(cctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, domain.ErrUserNotFound
+	}
+	return 0, locked
+}
+
+func (r *UserRepository) updateByID(ctx context.Context, id, set string, args ...any) error {
+	cctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	tag, err := r.pool.Exec(cctx, `UPDATE users SET `+set+` WHERE id = $1`, append([]any{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
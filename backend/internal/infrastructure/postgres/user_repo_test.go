@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/pii"
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/repository/repositorytest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool connects to TEST_POSTGRES_URL with a schema of its own, dropped
+// when the test ends.
+func testPool(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL not set")
+	}
+	ctx := context.Background()
+	admin, err := Connect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE") })
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+const testTimeout = 5 * time.Second
+
+func testCipher(t *testing.T) *pii.Cipher {
+	cipher, err := pii.NewCipher(pii.DevelopmentKeys(), pii.DevelopmentKeys().BlindIndexKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestUserRepositoryContract(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		repo := NewUserRepository(testPool(t), testTimeout, testCipher(t))
+		if err := repo.EnsureIndexes(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestOutboxRepositoryContract(t *testing.T) {
+	repositorytest.TestOutboxRepository(t, func(t *testing.T) (repository.OutboxRepository, repository.UserRepository) {
+		pool := testPool(t)
+		users := NewUserRepository(pool, testTimeout, testCipher(t))
+		if err := users.EnsureIndexes(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return NewOutboxRepository(pool, testTimeout), users
+	})
+}
@@ -0,0 +1,29 @@
+// Package notify delivers security messages to users: one-time codes by SMS
+// and alerts to registered devices by push.
+package notify
+
+import (
+	"context"
+	"log/slog"
+)
+
+type Sender interface {
+	SendOTP(ctx context.Context, phone, code string) error
+	Push(ctx context.Context, pushToken, title, body string) error
+}
+
+// LogSender writes messages to the log instead of delivering them, for
+// development. It logs one-time codes, so it must not run in production.
+type LogSender struct{ logger *slog.Logger }
+
+func NewLogSender(logger *slog.Logger) *LogSender { return &LogSender{logger: logger} }
+
+func (s *LogSender) SendOTP(ctx context.Context, phone, code string) error {
+	s.logger.InfoContext(ctx, "otp_sent", "phone", phone, "code", code)
+	return nil
+}
+
+func (s *LogSender) Push(ctx context.Context, pushToken, title, body string) error {
+	s.logger.InfoContext(ctx, "push_sent", "token", pushToken, "title", title, "body", body)
+	return nil
+}
@@ -0,0 +1,114 @@
+package pii
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+const kekLen = 32
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// keyFile is the layout LoadKeyFile reads. Keys maps key IDs to base64
+// 32-byte AES keys; Current must be one of them.
+type keyFile struct {
+	Current       string            `json:"current"`
+	Keys          map[string]string `json:"keys"`
+	BlindIndexKey string            `json:"blindIndexKey"`
+}
+
+// LocalKeyProvider keeps the key encryption keys in memory, loaded from a
+// key file. To rotate, add a key, make it current and let the
+// re-encryption job re-seal old values before the old key is removed.
+type LocalKeyProvider struct {
+	current  string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+func NewLocalKeyProvider(current string, keys map[string][]byte, indexKey []byte) (*LocalKeyProvider, error) {
+	for id, k := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("key id %q must be 1-64 letters, digits, '.', '_' or '-'", id)
+		}
+		if len(k) != kekLen {
+			return nil, fmt.Errorf("key %q must be %d bytes", id, kekLen)
+		}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in keys", current)
+	}
+	if len(indexKey) < MinIndexKeyLen {
+		return nil, fmt.Errorf("blind index key must be at least %d bytes", MinIndexKeyLen)
+	}
+	return &LocalKeyProvider{current: current, keys: keys, indexKey: indexKey}, nil
+}
+
+// LoadKeyFile reads a JSON key file such as
+//
+//	{"current": "2024-06", "keys": {"2024-06": "<base64>"}, "blindIndexKey": "<base64>"}
+func LoadKeyFile(path string) (*LocalKeyProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, enc := range f.Keys {
+		if keys[id], err = base64.StdEncoding.DecodeString(enc); err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", path, id, err)
+		}
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(f.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: blindIndexKey: %w", path, err)
+	}
+	p, err := NewLocalKeyProvider(f.Current, keys, indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// DevelopmentKeys returns fixed, publicly known keys so local data
+// survives restarts without a key file. Never use them with real data.
+func DevelopmentKeys() *LocalKeyProvider {
+	kek := sha256.Sum256([]byte("akiba development pii key"))
+	index := sha256.Sum256([]byte("akiba development blind index key"))
+	p, _ := NewLocalKeyProvider("dev", map[string][]byte{"dev": kek[:]}, index[:])
+	return p
+}
+
+// BlindIndexKey is the key file's HMAC key for blind indexes.
+func (p *LocalKeyProvider) BlindIndexKey() []byte { return p.indexKey }
+
+func (p *LocalKeyProvider) CurrentKeyID() string { return p.current }
+
+// Wrap seals dataKey under the current key, authenticating the key ID.
+func (p *LocalKeyProvider) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := gcmSeal(p.keys[p.current], dataKey, []byte(p.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.current, wrapped, nil
+}
+
+func (p *LocalKeyProvider) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	dataKey, err := gcmOpen(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key under %q: %w", keyID, err)
+	}
+	return dataKey, nil
+}
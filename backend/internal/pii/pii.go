@@ -0,0 +1,180 @@
+// Package pii encrypts personal data field by field before it is stored.
+// Each value is sealed with AES-256-GCM under its own data key, and the
+// data key is wrapped by a key encryption key held by a KeyProvider
+// (envelope encryption), so rotating the key encryption key only needs
+// the values re-sealed, never a new data format. Values that must still
+// be looked up exactly are stored alongside an HMAC blind index.
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	sealedVersion = "v1"
+	dataKeyLen    = 32
+	// MinIndexKeyLen is the shortest blind index key NewCipher accepts.
+	MinIndexKeyLen = 32
+)
+
+var ErrMalformed = errors.New("malformed sealed value")
+
+// KeyProvider holds the key encryption keys. It wraps fresh data keys
+// under its current key and unwraps data keys wrapped under any key it
+// still has, so values sealed before a rotation stay readable until they
+// are re-sealed. A KMS fits behind it as well as a local key file.
+type KeyProvider interface {
+	// CurrentKeyID names the key Wrap uses.
+	CurrentKeyID() string
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Cipher seals and opens field values and computes their blind indexes.
+type Cipher struct {
+	keys     KeyProvider
+	indexKey []byte
+}
+
+// NewCipher returns a Cipher using keys for envelope encryption and
+// indexKey for blind indexes. The index key cannot be rotated without
+// recomputing every stored index, so it is kept apart from keys.
+func NewCipher(keys KeyProvider, indexKey []byte) (*Cipher, error) {
+	if len(indexKey) < MinIndexKeyLen {
+		return nil, fmt.Errorf("blind index key must be at least %d bytes", MinIndexKeyLen)
+	}
+	return &Cipher{keys: keys, indexKey: indexKey}, nil
+}
+
+// CurrentKeyID names the key new values are sealed under.
+func (c *Cipher) CurrentKeyID() string { return c.keys.CurrentKeyID() }
+
+// Seal encrypts value for field of the record subject. Field and subject
+// are authenticated, so a sealed value copied to another field or record
+// does not open. The result is
+// "v1:<key id>:<wrapped data key>:<nonce and ciphertext>", base64url
+// encoded. An empty value seals to "".
+func (c *Cipher) Seal(ctx context.Context, field, subject, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	keyID, wrapped, err := c.keys.Wrap(ctx, dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := gcmSeal(dataKey, []byte(value), associatedData(field, subject))
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return strings.Join([]string{sealedVersion, keyID, enc.EncodeToString(wrapped), enc.EncodeToString(sealed)}, ":"), nil
+}
+
+// Open decrypts a value Seal returned for the same field and subject.
+func (c *Cipher) Open(ctx context.Context, field, subject, sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	keyID, wrapped, ciphertext, err := parseSealed(sealed)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := c.keys.Unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plain, err := gcmOpen(dataKey, ciphertext, associatedData(field, subject))
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", field, err)
+	}
+	return string(plain), nil
+}
+
+// BlindIndex is the keyed hash of value for exact lookups and unique
+// indexes on field. Different fields hash the same value differently. An
+// empty value has no index.
+func (c *Cipher) BlindIndex(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// KeyID returns the key a sealed value's data key is wrapped under.
+func KeyID(sealed string) string {
+	keyID, _, _, err := parseSealed(sealed)
+	if err != nil {
+		return ""
+	}
+	return keyID
+}
+
+func parseSealed(sealed string) (string, []byte, []byte, error) {
+	parts := strings.Split(sealed, ":")
+	if len(parts) != 4 || parts[0] != sealedVersion || parts[1] == "" {
+		return "", nil, nil, ErrMalformed
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[1], wrapped, ciphertext, nil
+}
+
+func associatedData(field, subject string) []byte {
+	return []byte(field + "\x00" + subject)
+}
+
+// gcmSeal returns the random nonce followed by the AES-GCM ciphertext.
+func gcmSeal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, sealed, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
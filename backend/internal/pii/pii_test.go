@@ -0,0 +1,123 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+func TestSealOpenAndBinding(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewCipher(DevelopmentKeys(), DevelopmentKeys().BlindIndexKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Seal(ctx, "email", "u1", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "ada") || KeyID(sealed) != "dev" {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+	again, _ := c.Seal(ctx, "email", "u1", "ada@example.com")
+	if again == sealed {
+		t.Fatal("expected a fresh data key and nonce per value")
+	}
+	if got, err := c.Open(ctx, "email", "u1", sealed); err != nil || got != "ada@example.com" {
+		t.Fatalf("open: %q %v", got, err)
+	}
+	// A value moved to another record or field does not open.
+	if _, err := c.Open(ctx, "email", "u2", sealed); err == nil {
+		t.Fatal("expected a value copied to another user to fail")
+	}
+	if _, err := c.Open(ctx, "phone", "u1", sealed); err == nil {
+		t.Fatal("expected a value copied to another field to fail")
+	}
+	if _, err := c.Open(ctx, "email", "u1", "v1:dev:bad"); err != ErrMalformed {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+	if s, err := c.Seal(ctx, "email", "u1", ""); err != nil || s != "" {
+		t.Fatalf("expected empty values left empty, got %q %v", s, err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	keys := DevelopmentKeys()
+	c, _ := NewCipher(keys, keys.BlindIndexKey())
+	other, _ := NewCipher(keys, testKey(9))
+	idx := c.BlindIndex("phone", "+254700000001")
+	if idx != c.BlindIndex("phone", "+254700000001") || len(idx) != 64 {
+		t.Fatalf("expected a stable index, got %q", idx)
+	}
+	if idx == c.BlindIndex("email", "+254700000001") || idx == other.BlindIndex("phone", "+254700000001") {
+		t.Fatal("expected indexes to depend on field and key")
+	}
+	if _, err := NewCipher(keys, testKey(1)[:16]); err == nil {
+		t.Fatal("expected a short index key refused")
+	}
+}
+
+func TestKeyRotationKeepsOldValuesReadable(t *testing.T) {
+	ctx := context.Background()
+	index := testKey(7)
+	old, err := NewLocalKeyProvider("k1", map[string][]byte{"k1": testKey(1)}, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, _ := NewCipher(old, index)
+	sealed, _ := c1.Seal(ctx, "phone", "u1", "+254700000001")
+
+	rotated, err := NewLocalKeyProvider("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, _ := NewCipher(rotated, index)
+	if got, err := c2.Open(ctx, "phone", "u1", sealed); err != nil || got != "+254700000001" {
+		t.Fatalf("expected the old key to still open, got %q %v", got, err)
+	}
+	resealed, _ := c2.Seal(ctx, "phone", "u1", "+254700000001")
+	if KeyID(resealed) != "k2" || c2.CurrentKeyID() != "k2" {
+		t.Fatalf("expected new values under k2, got %q", resealed)
+	}
+
+	retired, _ := NewLocalKeyProvider("k2", map[string][]byte{"k2": testKey(2)}, index)
+	c3, _ := NewCipher(retired, index)
+	if _, err := c3.Open(ctx, "phone", "u1", sealed); err == nil {
+		t.Fatal("expected a value under a removed key to fail")
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	enc := base64.StdEncoding.EncodeToString
+	dir := t.TempDir()
+	write := func(body string) string {
+		path := filepath.Join(dir, "keys.json")
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	p, err := LoadKeyFile(write(`{"current":"k2","keys":{"k1":"` + enc(testKey(1)) + `","k2":"` + enc(testKey(2)) + `"},"blindIndexKey":"` + enc(testKey(3)) + `"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CurrentKeyID() != "k2" || !bytes.Equal(p.BlindIndexKey(), testKey(3)) {
+		t.Fatalf("unexpected provider %+v", p)
+	}
+	for name, body := range map[string]string{
+		"unknown current": `{"current":"k9","keys":{"k1":"` + enc(testKey(1)) + `"},"blindIndexKey":"` + enc(testKey(3)) + `"}`,
+		"short key":       `{"current":"k1","keys":{"k1":"` + enc(testKey(1)[:16]) + `"},"blindIndexKey":"` + enc(testKey(3)) + `"}`,
+		"colon in id":     `{"current":"k:1","keys":{"k:1":"` + enc(testKey(1)) + `"},"blindIndexKey":"` + enc(testKey(3)) + `"}`,
+		"no index key":    `{"current":"k1","keys":{"k1":"` + enc(testKey(1)) + `"}}`,
+	} {
+		if _, err := LoadKeyFile(write(body)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type AMLRepository interface {
+	// CreateAlert returns domain.ErrAlertExists if an unresolved alert for
+	// the same rule and subject already exists.
+	CreateAlert(ctx context.Context, alert *domain.AMLAlert) error
+	GetAlert(ctx context.Context, id string) (*domain.AMLAlert, error)
+	// GetOpenAlert returns the unresolved alert for rule and subject, or
+	// domain.ErrAlertNotFound.
+	GetOpenAlert(ctx context.Context, ruleID, subjectKey string) (*domain.AMLAlert, error)
+	// ListAlerts returns alerts with status, or all when status is empty,
+	// oldest first.
+	ListAlerts(ctx context.Context, status domain.AlertStatus) ([]*domain.AMLAlert, error)
+	// AddAlertEntries links further evidence to an alert, ignoring entries
+	// it already holds.
+	AddAlertEntries(ctx context.Context, id string, entryIDs []string, at time.Time) error
+	// TransitionAlert saves alert if it is still in status from, and
+	// returns domain.ErrInvalidTransition otherwise.
+	TransitionAlert(ctx context.Context, alert *domain.AMLAlert, from domain.AlertStatus) error
+	// GetCursor returns the position of the last journal entry the monitor
+	// evaluated; both are zero before the first scan.
+	GetCursor(ctx context.Context) (time.Time, string, error)
+	SaveCursor(ctx context.Context, at time.Time, entryID string) error
+	EnsureIndexes(ctx context.Context) error
+}
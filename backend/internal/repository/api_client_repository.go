@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type APIClientRepository interface {
+	Create(ctx context.Context, client *domain.APIClient) error
+	GetByID(ctx context.Context, id string) (*domain.APIClient, error)
+	// GetByKeyPrefix returns the client owning the key with prefix, or
+	// domain.ErrAPIClientNotFound.
+	GetByKeyPrefix(ctx context.Context, prefix string) (*domain.APIClient, error)
+	// List returns every client, newest first.
+	List(ctx context.Context) ([]*domain.APIClient, error)
+	AddKey(ctx context.Context, id string, key domain.APIKey) error
+	// RevokeKey stamps the key's RevokedAt unless it is already revoked.
+	RevokeKey(ctx context.Context, id, prefix string, at time.Time) error
+	// TouchKey records that the key was used at at.
+	TouchKey(ctx context.Context, id, prefix string, at time.Time) error
+	SetStatus(ctx context.Context, id string, status domain.APIClientStatus, at time.Time) error
+	EnsureIndexes(ctx context.Context) error
+}
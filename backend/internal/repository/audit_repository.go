@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+)
+
+// AuditRepository stores the audit log. It has no way to change or remove
+// a record once appended.
+type AuditRepository interface {
+	// Append seals record after the newest stored record and stores it. A
+	// concurrent append that takes the same sequence number makes it seal
+	// against the new head and try again.
+	Append(ctx context.Context, record *domain.AuditRecord) error
+	// Search returns up to filter.Limit matching records, newest first.
+	Search(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditRecord, error)
+	// ListAfter returns up to limit records with Seq above afterSeq, in
+	// sequence order.
+	ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*domain.AuditRecord, error)
+	EnsureIndexes(ctx context.Context) error
+}
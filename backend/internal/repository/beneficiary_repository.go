@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type BeneficiaryRepository interface {
+	// Create returns domain.ErrBeneficiaryExists if the owner already saved
+	// the same destination or nickname.
+	Create(ctx context.Context, beneficiary *domain.Beneficiary) error
+	GetByID(ctx context.Context, id string) (*domain.Beneficiary, error)
+	ListByOwner(ctx context.Context, ownerID string) ([]*domain.Beneficiary, error)
+	UpdateNickname(ctx context.Context, id, nickname string, at time.Time) error
+	Delete(ctx context.Context, id string) error
+	EnsureIndexes(ctx context.Context) error
+}
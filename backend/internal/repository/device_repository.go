@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type DeviceRepository interface {
+	// Upsert records device for its user, keyed by DeviceID. An existing
+	// record keeps its FirstSeenAt and ID; every other field is replaced.
+	Upsert(ctx context.Context, device *domain.Device) error
+	Get(ctx context.Context, userID, deviceID string) (*domain.Device, error)
+	ListByUser(ctx context.Context, userID string) ([]*domain.Device, error)
+	// Touch records a sign-in from a trusted device, refreshing its push
+	// token when one is given.
+	Touch(ctx context.Context, id, pushToken string, at time.Time) error
+	Delete(ctx context.Context, userID, id string) error
+	CreateOTP(ctx context.Context, challenge *domain.OTPChallenge) error
+	GetOTP(ctx context.Context, id string) (*domain.OTPChallenge, error)
+	// RecordOTPFailure counts a wrong code and returns the attempts so far.
+	RecordOTPFailure(ctx context.Context, id string) (int, error)
+	DeleteOTP(ctx context.Context, id string) error
+	// UseAssertion records the digest of a device assertion accepted at at
+	// until expiresAt, returning domain.ErrAssertionReplayed if it is
+	// already recorded.
+	UseAssertion(ctx context.Context, digest string, at, expiresAt time.Time) error
+	EnsureIndexes(ctx context.Context) error
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type DisputeRepository interface {
+	// Create returns domain.ErrDisputeExists if the entry already has an
+	// unresolved dispute.
+	Create(ctx context.Context, dispute *domain.Dispute) error
+	GetByID(ctx context.Context, id string) (*domain.Dispute, error)
+	// GetActiveByEntry returns the unresolved dispute on entryID, or
+	// domain.ErrDisputeNotFound.
+	GetActiveByEntry(ctx context.Context, entryID string) (*domain.Dispute, error)
+	// ListByParty returns disputes where userID is the payer or the payee.
+	ListByParty(ctx context.Context, userID string) ([]*domain.Dispute, error)
+	// ListByStatus returns all disputes in status, or every dispute when
+	// status is empty.
+	ListByStatus(ctx context.Context, status domain.DisputeStatus) ([]*domain.Dispute, error)
+	SetHold(ctx context.Context, id, entryID string, amount int64, at time.Time) error
+	AddEvidence(ctx context.Context, id string, evidence domain.Evidence) error
+	// Transition persists dispute's status and resolution fields if the
+	// stored status is still from, else returns domain.ErrInvalidTransition.
+	Transition(ctx context.Context, dispute *domain.Dispute, from domain.DisputeStatus) error
+	EnsureIndexes(ctx context.Context) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type FXQuoteRepository interface {
+	Create(ctx context.Context, quote *domain.FXQuote) error
+	GetByID(ctx context.Context, id string) (*domain.FXQuote, error)
+	// MarkExecuted moves an open quote to executed. It returns
+	// domain.ErrQuoteExecuted if the quote is no longer open.
+	MarkExecuted(ctx context.Context, id, entryID string, at time.Time) error
+	EnsureIndexes(ctx context.Context) error
+}
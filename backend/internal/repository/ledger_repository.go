@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type LedgerRepository interface {
+	// GetOrCreateAccount returns the single account for owner, type and
+	// currency, opening it with a zero balance on first use.
+	GetOrCreateAccount(ctx context.Context, ownerID string, accountType domain.AccountType, currency string) (*domain.Account, error)
+	GetAccount(ctx context.Context, id string) (*domain.Account, error)
+	ListAccountsByOwner(ctx context.Context, ownerID string) ([]*domain.Account, error)
+	// Post applies every posting of entry atomically. It returns
+	// domain.ErrInsufficientFunds if a debit would exceed the available
+	// balance of an account that may not go negative, and
+	// domain.ErrDuplicateEntry if entry.Reference was used before.
+	Post(ctx context.Context, entry *domain.JournalEntry) error
+	// PostWithinCap is Post, refused with domain.ErrCoolingOffLimit when the
+	// entry would take what limit.AccountID has sent the payee past
+	// limit.Limit. The check runs in the same transaction as the post, so
+	// concurrent payments cannot both pass it.
+	PostWithinCap(ctx context.Context, entry *domain.JournalEntry, limit domain.PayeeCap) error
+	// SentWithinCap returns what limit.AccountID has sent the payee since
+	// limit.Since, counting active holds.
+	SentWithinCap(ctx context.Context, limit domain.PayeeCap) (int64, error)
+	// FirstPaymentTo returns when accountID first posted an entry whose
+	// Metadata[field] is payee, or the zero time if it never has.
+	FirstPaymentTo(ctx context.Context, accountID, field, payee string) (time.Time, error)
+	ListEntriesByAccount(ctx context.Context, accountID string, from, to time.Time) ([]*domain.JournalEntry, error)
+	GetEntry(ctx context.Context, id string) (*domain.JournalEntry, error)
+	// GetEntryByReference returns the entry posted under reference, or
+	// domain.ErrEntryNotFound.
+	GetEntryByReference(ctx context.Context, reference string) (*domain.JournalEntry, error)
+	// ListEntriesAfter pages through every entry in (createdAt, id) order,
+	// starting after the entry at (after, afterID) and stopping before
+	// until. Zero values start from the beginning.
+	ListEntriesAfter(ctx context.Context, after time.Time, afterID string, until time.Time, limit int) ([]*domain.JournalEntry, error)
+	// ListReversals returns every entry whose ReversalOf is entryID, oldest
+	// first.
+	ListReversals(ctx context.Context, entryID string) ([]*domain.JournalEntry, error)
+	// PlaceHold reserves hold.Amount of the account's available balance, or
+	// returns domain.ErrInsufficientFunds.
+	PlaceHold(ctx context.Context, hold *domain.Hold) error
+	// PlaceHoldWithinCap is PlaceHold under the check of PostWithinCap.
+	PlaceHoldWithinCap(ctx context.Context, hold *domain.Hold, limit domain.PayeeCap) error
+	GetHold(ctx context.Context, id string) (*domain.Hold, error)
+	// ListActiveHolds returns the account's active holds, oldest first.
+	ListActiveHolds(ctx context.Context, accountID string) ([]*domain.Hold, error)
+	// ListExpiredHolds returns up to limit active holds whose expiry is at
+	// or before now.
+	ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*domain.Hold, error)
+	// CaptureHold releases the whole hold and posts entry in one
+	// transaction, recording capturedAmount. It returns
+	// domain.ErrHoldNotActive if the hold was settled or expired first.
+	CaptureHold(ctx context.Context, id string, capturedAmount int64, entry *domain.JournalEntry) error
+	// ReleaseHold moves an active hold to status (voided or expired) without
+	// posting anything. Releasing as expired only succeeds once the hold's
+	// expiry has passed.
+	ReleaseHold(ctx context.Context, id string, status domain.HoldStatus, at time.Time) error
+	EnsureIndexes(ctx context.Context) error
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+)
+
+type MerchantRepository interface {
+	Create(ctx context.Context, merchant *domain.Merchant) error
+	GetByID(ctx context.Context, id string) (*domain.Merchant, error)
+	ListByOwner(ctx context.Context, ownerID string) ([]*domain.Merchant, error)
+	EnsureIndexes(ctx context.Context) error
+}
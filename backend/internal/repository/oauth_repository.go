@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type OAuthRepository interface {
+	// UpsertConsent stores the consent for its user and client, replacing
+	// the scopes and clearing RevokedAt of an existing one, and sets its ID.
+	UpsertConsent(ctx context.Context, consent *domain.OAuthConsent) error
+	// GetConsent returns the consent of userID for clientID, revoked or
+	// not, or domain.ErrConsentNotFound.
+	GetConsent(ctx context.Context, userID, clientID string) (*domain.OAuthConsent, error)
+	GetConsentByID(ctx context.Context, id string) (*domain.OAuthConsent, error)
+	// ListConsents returns the user's active consents, newest first.
+	ListConsents(ctx context.Context, userID string) ([]*domain.OAuthConsent, error)
+	RevokeConsent(ctx context.Context, id string, at time.Time) error
+
+	CreateCode(ctx context.Context, code *domain.OAuthCode) error
+	// ConsumeCode stamps the code's UsedAt and returns it. A code already
+	// used is returned with domain.ErrTokenReused; an unknown one is
+	// domain.ErrInvalidGrant.
+	ConsumeCode(ctx context.Context, hash string, at time.Time) (*domain.OAuthCode, error)
+
+	CreateGrant(ctx context.Context, grant *domain.OAuthGrant) error
+	GetGrant(ctx context.Context, id string) (*domain.OAuthGrant, error)
+	// GetGrantByRefresh returns the grant whose current or recently used
+	// refresh token has hash, or domain.ErrGrantNotFound.
+	GetGrantByRefresh(ctx context.Context, hash string) (*domain.OAuthGrant, error)
+	// RotateRefresh replaces the grant's refresh token. It returns
+	// domain.ErrInvalidGrant unless the current token is still oldHash and
+	// the grant is unrevoked, so two concurrent refreshes cannot both win.
+	RotateRefresh(ctx context.Context, id, oldHash, newHash string, at time.Time) error
+	RevokeGrant(ctx context.Context, id string, at time.Time) error
+	// RevokeGrants revokes every grant userID gave clientID.
+	RevokeGrants(ctx context.Context, userID, clientID string, at time.Time) error
+	// RevokeGrantsByCode revokes the grant issued from the code with hash.
+	RevokeGrantsByCode(ctx context.Context, codeHash string, at time.Time) error
+	EnsureIndexes(ctx context.Context) error
+}
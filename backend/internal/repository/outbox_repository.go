@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+// OutboxRepository is the relay's view of the outbox. Events are written by
+// the repositories whose state change they describe, in the same
+// transaction.
+type OutboxRepository interface {
+	// ListDue returns up to limit pending events whose next attempt is at or
+	// before now, oldest first.
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxEvent, error)
+	// PendingBefore reports whether an event of the same aggregate with a
+	// lower Seq is still pending, due or not.
+	PendingBefore(ctx context.Context, event *domain.OutboxEvent) (bool, error)
+	MarkPublished(ctx context.Context, id string, at time.Time) error
+	// MarkFailed records a failed attempt and when to try again.
+	MarkFailed(ctx context.Context, id string, attempts int, lastError string, nextAttemptAt time.Time) error
+	// DeadLetter moves the event out of the outbox into the dead-letter
+	// store, which unblocks later events of its aggregate.
+	DeadLetter(ctx context.Context, event *domain.OutboxEvent) error
+	EnsureIndexes(ctx context.Context) error
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type PasskeyRepository interface {
+	// Create returns domain.ErrPasskeyExists if the credential ID is already
+	// registered, to this or any other user.
+	Create(ctx context.Context, passkey *domain.Passkey) error
+	GetByCredentialID(ctx context.Context, credentialID string) (*domain.Passkey, error)
+	ListByUser(ctx context.Context, userID string) ([]*domain.Passkey, error)
+	// UpdateSignCount stores the counter from a verified assertion. It
+	// returns domain.ErrPasskeyInvalid unless the stored counter is still
+	// from, so a concurrent assertion with the same counter cannot also pass.
+	UpdateSignCount(ctx context.Context, id string, from, to uint32, at time.Time) error
+	Delete(ctx context.Context, userID, id string) error
+	CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error
+	// ConsumeChallenge removes and returns the challenge so it cannot be
+	// used twice.
+	ConsumeChallenge(ctx context.Context, id string, purpose domain.WebAuthnPurpose) (*domain.WebAuthnChallenge, error)
+	EnsureIndexes(ctx context.Context) error
+}
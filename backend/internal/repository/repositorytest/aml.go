@@ -0,0 +1,135 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewAMLRepository returns an empty repository with its indexes ensured.
+type NewAMLRepository func(t *testing.T) repository.AMLRepository
+
+// TestAMLRepository runs the AMLRepository contract.
+func TestAMLRepository(t *testing.T, newRepo NewAMLRepository) {
+	t.Run("Alerts", func(t *testing.T) { testAMLAlerts(t, newRepo(t)) })
+	t.Run("Transitions", func(t *testing.T) { testAMLTransitions(t, newRepo(t)) })
+	t.Run("Cursor", func(t *testing.T) { testAMLCursor(t, newRepo(t)) })
+}
+
+func newAlert(ruleID, subject string, n int) *domain.AMLAlert {
+	at := base.Add(time.Duration(n) * time.Minute)
+	return &domain.AMLAlert{RuleID: ruleID, RuleName: ruleID, Severity: "high", SubjectKey: subject, OwnerID: "alice", Currency: "KES",
+		Value: 500, Threshold: 100, TriggerEntryID: "e1", EntryIDs: []string{"e1"}, Status: domain.AlertStatusOpen, CreatedAt: at, UpdatedAt: at}
+}
+
+func testAMLAlerts(t *testing.T, repo repository.AMLRepository) {
+	ctx := context.Background()
+	alert := newAlert("velocity", "user:alice", 1)
+	if err := repo.CreateAlert(ctx, alert); err != nil || alert.ID == "" {
+		t.Fatalf("create: %v, id %q", err, alert.ID)
+	}
+	if err := repo.CreateAlert(ctx, newAlert("velocity", "user:alice", 2)); !errors.Is(err, domain.ErrAlertExists) {
+		t.Fatalf("expected ErrAlertExists, got %v", err)
+	}
+	if err := repo.CreateAlert(ctx, newAlert("structuring", "user:alice", 2)); err != nil {
+		t.Fatalf("other rule, same subject: %v", err)
+	}
+
+	open, err := repo.GetOpenAlert(ctx, "velocity", "user:alice")
+	if err != nil || open.ID != alert.ID {
+		t.Fatalf("open alert: %+v %v", open, err)
+	}
+	if _, err := repo.GetOpenAlert(ctx, "velocity", "user:bob"); !errors.Is(err, domain.ErrAlertNotFound) {
+		t.Fatalf("expected ErrAlertNotFound, got %v", err)
+	}
+	if _, err := repo.GetAlert(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrAlertNotFound) {
+		t.Fatalf("expected ErrAlertNotFound, got %v", err)
+	}
+
+	if err := repo.AddAlertEntries(ctx, alert.ID, []string{"e1", "e2", "e3"}, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetAlert(ctx, alert.ID)
+	if err != nil || fmt.Sprint(got.EntryIDs) != "[e1 e2 e3]" || got.Value != 500 || got.RuleName != "velocity" {
+		t.Fatalf("alert after adding entries: %+v %v", got, err)
+	}
+
+	all, err := repo.ListAlerts(ctx, "")
+	if err != nil || len(all) != 2 || all[0].ID != alert.ID {
+		t.Fatalf("all alerts, oldest first: %v %v", all, err)
+	}
+	escalated, err := repo.ListAlerts(ctx, domain.AlertStatusEscalated)
+	if err != nil || len(escalated) != 0 {
+		t.Fatalf("no escalated alerts yet: %v %v", escalated, err)
+	}
+}
+
+func testAMLTransitions(t *testing.T, repo repository.AMLRepository) {
+	ctx := context.Background()
+	alert := newAlert("velocity", "user:alice", 1)
+	if err := repo.CreateAlert(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+	alert.Status, alert.AssignedTo, alert.UpdatedAt = domain.AlertStatusInvestigating, "analyst", base.Add(time.Hour)
+	if err := repo.TransitionAlert(ctx, alert, domain.AlertStatusOpen); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.TransitionAlert(ctx, alert, domain.AlertStatusOpen); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("stale transition: expected ErrInvalidTransition, got %v", err)
+	}
+	// An alert under investigation still blocks a new one.
+	if err := repo.CreateAlert(ctx, newAlert("velocity", "user:alice", 2)); !errors.Is(err, domain.ErrAlertExists) {
+		t.Fatalf("expected ErrAlertExists, got %v", err)
+	}
+
+	alert.Status, alert.ResolutionNote, alert.ResolvedBy = domain.AlertStatusDismissed, "false positive", "analyst"
+	alert.ResolvedAt, alert.UpdatedAt = base.Add(2*time.Hour), base.Add(2*time.Hour)
+	if err := repo.TransitionAlert(ctx, alert, domain.AlertStatusInvestigating); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetAlert(ctx, alert.ID)
+	if err != nil || got.Status != domain.AlertStatusDismissed || got.AssignedTo != "analyst" || got.ResolutionNote != "false positive" ||
+		got.ResolvedBy != "analyst" || !got.ResolvedAt.Equal(alert.ResolvedAt) {
+		t.Fatalf("dismissed alert: %+v %v", got, err)
+	}
+	if _, err := repo.GetOpenAlert(ctx, "velocity", "user:alice"); !errors.Is(err, domain.ErrAlertNotFound) {
+		t.Fatalf("a closed alert is not open: %v", err)
+	}
+	// Once closed, the rule may fire again for the subject.
+	again := newAlert("velocity", "user:alice", 3)
+	if err := repo.CreateAlert(ctx, again); err != nil {
+		t.Fatalf("new alert after dismissal: %v", err)
+	}
+	dismissed, err := repo.ListAlerts(ctx, domain.AlertStatusDismissed)
+	if err != nil || len(dismissed) != 1 || dismissed[0].ID != alert.ID {
+		t.Fatalf("dismissed alerts: %v %v", dismissed, err)
+	}
+
+	missing := newAlert("velocity", "user:bob", 1)
+	missing.ID = "000000000000000000000000"
+	if err := repo.TransitionAlert(ctx, missing, domain.AlertStatusOpen); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("missing alert: expected ErrInvalidTransition, got %v", err)
+	}
+}
+
+func testAMLCursor(t *testing.T, repo repository.AMLRepository) {
+	ctx := context.Background()
+	at, entryID, err := repo.GetCursor(ctx)
+	if err != nil || !at.IsZero() || entryID != "" {
+		t.Fatalf("expected a zero cursor, got %v %q %v", at, entryID, err)
+	}
+	for _, id := range []string{"e1", "e2"} {
+		if err := repo.SaveCursor(ctx, base, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at, entryID, err = repo.GetCursor(ctx)
+	if err != nil || !at.Equal(base) || entryID != "e2" {
+		t.Fatalf("cursor: %v %q %v", at, entryID, err)
+	}
+}
@@ -0,0 +1,125 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewAPIClientRepository returns an empty repository with its indexes
+// ensured.
+type NewAPIClientRepository func(t *testing.T) repository.APIClientRepository
+
+// TestAPIClientRepository runs the APIClientRepository contract.
+func TestAPIClientRepository(t *testing.T, newRepo NewAPIClientRepository) {
+	t.Run("CreateAndLookup", func(t *testing.T) { testAPIClientCreateAndLookup(t, newRepo(t)) })
+	t.Run("Keys", func(t *testing.T) { testAPIClientKeys(t, newRepo(t)) })
+}
+
+func newAPIClient(n int, prefixes ...string) *domain.APIClient {
+	at := base.Add(time.Duration(n) * time.Minute)
+	c := &domain.APIClient{Name: fmt.Sprintf("client %d", n), Scopes: []domain.Scope{domain.ScopeProfile},
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, RedirectURIs: []string{"https://app.example.com/cb"},
+		Status: domain.APIClientActive, CreatedBy: "admin", CreatedAt: at, UpdatedAt: at}
+	for _, p := range prefixes {
+		c.Keys = append(c.Keys, domain.APIKey{Prefix: p, Hash: "hash-" + p, CreatedAt: at})
+	}
+	return c
+}
+
+func testAPIClientCreateAndLookup(t *testing.T, repo repository.APIClientRepository) {
+	ctx := context.Background()
+	first := newAPIClient(1, "ak_one")
+	if err := repo.Create(ctx, first); err != nil || first.ID == "" {
+		t.Fatalf("create: %v, id %q", err, first.ID)
+	}
+	second := newAPIClient(2, "ak_two")
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(ctx, newAPIClient(3, "ak_one")); !errors.Is(err, domain.ErrDuplicateEntry) {
+		t.Fatalf("reused prefix: expected ErrDuplicateEntry, got %v", err)
+	}
+
+	got, err := repo.GetByKeyPrefix(ctx, "ak_one")
+	if err != nil || got.ID != first.ID || got.Name != "client 1" || !got.HasScope(domain.ScopeProfile) || !got.AllowsAddr("10.1.2.3") ||
+		fmt.Sprint(got.RedirectURIs) != "[https://app.example.com/cb]" {
+		t.Fatalf("lookup by prefix: %+v %v", got, err)
+	}
+	if key, ok := got.Key("ak_one"); !ok || key.Hash != "hash-ak_one" || !key.Active() {
+		t.Fatalf("stored key: %+v %v", key, ok)
+	}
+	if _, err := repo.GetByKeyPrefix(ctx, "ak_none"); !errors.Is(err, domain.ErrAPIClientNotFound) {
+		t.Fatalf("expected ErrAPIClientNotFound, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrAPIClientNotFound) {
+		t.Fatalf("expected ErrAPIClientNotFound, got %v", err)
+	}
+	all, err := repo.List(ctx)
+	if err != nil || len(all) != 2 || all[0].ID != second.ID {
+		t.Fatalf("clients, newest first: %v %v", all, err)
+	}
+
+	if err := repo.SetStatus(ctx, first.ID, domain.APIClientDisabled, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetByID(ctx, first.ID); err != nil || got.Status != domain.APIClientDisabled || !got.UpdatedAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("disabled client: %+v %v", got, err)
+	}
+	if err := repo.SetStatus(ctx, "000000000000000000000000", domain.APIClientDisabled, base); !errors.Is(err, domain.ErrAPIClientNotFound) {
+		t.Fatalf("expected ErrAPIClientNotFound, got %v", err)
+	}
+}
+
+func testAPIClientKeys(t *testing.T, repo repository.APIClientRepository) {
+	ctx := context.Background()
+	client := newAPIClient(1, "ak_one")
+	other := newAPIClient(2, "ak_other")
+	for _, c := range []*domain.APIClient{client, other} {
+		if err := repo.Create(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rotated := domain.APIKey{Prefix: "ak_two", Hash: "hash-ak_two", CreatedAt: base.Add(time.Hour)}
+	if err := repo.AddKey(ctx, client.ID, rotated); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"ak_two", "ak_other"} {
+		if err := repo.AddKey(ctx, client.ID, domain.APIKey{Prefix: prefix, Hash: "h", CreatedAt: base}); !errors.Is(err, domain.ErrDuplicateEntry) {
+			t.Fatalf("adding %s again: expected ErrDuplicateEntry, got %v", prefix, err)
+		}
+	}
+	if err := repo.AddKey(ctx, "000000000000000000000000", rotated); !errors.Is(err, domain.ErrAPIClientNotFound) {
+		t.Fatalf("expected ErrAPIClientNotFound, got %v", err)
+	}
+	if got, err := repo.GetByKeyPrefix(ctx, "ak_two"); err != nil || got.ID != client.ID || got.ActiveKeys() != 2 {
+		t.Fatalf("client after adding a key: %+v %v", got, err)
+	}
+
+	if err := repo.TouchKey(ctx, client.ID, "ak_one", base.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RevokeKey(ctx, client.ID, "ak_one", base.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RevokeKey(ctx, client.ID, "ak_one", base.Add(4*time.Hour)); !errors.Is(err, domain.ErrAPIClientNotFound) {
+		t.Fatalf("second revoke: expected ErrAPIClientNotFound, got %v", err)
+	}
+	if err := repo.RevokeKey(ctx, other.ID, "ak_two", base); !errors.Is(err, domain.ErrAPIClientNotFound) {
+		t.Fatalf("another client's key: expected ErrAPIClientNotFound, got %v", err)
+	}
+	got, err := repo.GetByID(ctx, client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := got.Key("ak_one")
+	if !key.LastUsedAt.Equal(base.Add(2*time.Hour)) || !key.RevokedAt.Equal(base.Add(3*time.Hour)) || got.ActiveKeys() != 1 {
+		t.Fatalf("revoked key: %+v, %d active", key, got.ActiveKeys())
+	}
+}
@@ -0,0 +1,91 @@
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewAuditRepository returns an empty repository with its indexes ensured.
+type NewAuditRepository func(t *testing.T) repository.AuditRepository
+
+// TestAuditRepository runs the AuditRepository contract.
+func TestAuditRepository(t *testing.T, newRepo NewAuditRepository) {
+	t.Run("Chain", func(t *testing.T) { testAuditChain(t, newRepo(t)) })
+	t.Run("Search", func(t *testing.T) { testAuditSearch(t, newRepo(t)) })
+}
+
+// appendAudit stores one record per action, a minute apart, all by actor
+// "admin" against target "t<n>".
+func appendAudit(t *testing.T, repo repository.AuditRepository, actions ...domain.AuditAction) []*domain.AuditRecord {
+	t.Helper()
+	var out []*domain.AuditRecord
+	for i, action := range actions {
+		r := &domain.AuditRecord{Action: action, ActorID: "admin", TargetType: "user", TargetID: fmt.Sprintf("t%d", i),
+			IP: "10.0.0.1", RequestID: "req", Details: map[string]string{"n": fmt.Sprint(i)}, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := repo.Append(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func testAuditChain(t *testing.T, repo repository.AuditRepository) {
+	ctx := context.Background()
+	appended := appendAudit(t, repo, domain.AuditSignup, domain.AuditLoginFailed, domain.AuditSignup)
+	if appended[0].Seq != 1 || appended[0].PrevHash != "" || appended[2].Seq != 3 || appended[2].PrevHash != appended[1].Hash {
+		t.Fatalf("records not chained: %+v", appended)
+	}
+
+	stored, err := repo.ListAfter(ctx, 0, 10)
+	if err != nil || len(stored) != 3 {
+		t.Fatalf("expected 3 records, got %d %v", len(stored), err)
+	}
+	for i, r := range stored {
+		if r.Seq != int64(i+1) || r.Hash != appended[i].Hash || r.ComputeHash() != r.Hash {
+			t.Fatalf("record %d does not verify after storage: %+v", i, r)
+		}
+	}
+	after, err := repo.ListAfter(ctx, 1, 1)
+	if err != nil || len(after) != 1 || after[0].Seq != 2 {
+		t.Fatalf("one record after seq 1: %v %v", after, err)
+	}
+	if none, err := repo.ListAfter(ctx, 3, 10); err != nil || len(none) != 0 {
+		t.Fatalf("nothing after the head: %v %v", none, err)
+	}
+}
+
+func testAuditSearch(t *testing.T, repo repository.AuditRepository) {
+	ctx := context.Background()
+	appendAudit(t, repo, domain.AuditSignup, domain.AuditLoginFailed, domain.AuditSignup, domain.AuditLoginFailed)
+
+	for name, c := range map[string]struct {
+		filter domain.AuditFilter
+		want   []int64
+	}{
+		"all":       {domain.AuditFilter{}, []int64{4, 3, 2, 1}},
+		"limit":     {domain.AuditFilter{Limit: 2}, []int64{4, 3}},
+		"action":    {domain.AuditFilter{Action: domain.AuditSignup}, []int64{3, 1}},
+		"target":    {domain.AuditFilter{TargetID: "t1"}, []int64{2}},
+		"actor":     {domain.AuditFilter{ActorID: "nobody"}, nil},
+		"window":    {domain.AuditFilter{From: base.Add(time.Minute), To: base.Add(3 * time.Minute)}, []int64{3, 2}},
+		"open from": {domain.AuditFilter{From: base.Add(3 * time.Minute)}, []int64{4}},
+	} {
+		got, err := repo.Search(ctx, c.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var seqs []int64
+		for _, r := range got {
+			seqs = append(seqs, r.Seq)
+		}
+		if fmt.Sprint(seqs) != fmt.Sprint(c.want) {
+			t.Fatalf("%s: got %v, want %v", name, seqs, c.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewBeneficiaryRepository returns an empty repository with its indexes
+// ensured.
+type NewBeneficiaryRepository func(t *testing.T) repository.BeneficiaryRepository
+
+// TestBeneficiaryRepository runs the BeneficiaryRepository contract.
+func TestBeneficiaryRepository(t *testing.T, newRepo NewBeneficiaryRepository) {
+	t.Run("Uniqueness", func(t *testing.T) { testBeneficiaryUniqueness(t, newRepo(t)) })
+	t.Run("Updates", func(t *testing.T) { testBeneficiaryUpdates(t, newRepo(t)) })
+}
+
+func newBeneficiary(ownerID, nickname, phone string) *domain.Beneficiary {
+	return &domain.Beneficiary{OwnerID: ownerID, Type: domain.BeneficiaryTypeMpesa, Nickname: nickname, Phone: phone, AccountName: "Jane",
+		Verification: domain.BeneficiaryVerified, CoolingOffUntil: base.Add(24 * time.Hour), CreatedAt: base, UpdatedAt: base}
+}
+
+func testBeneficiaryUniqueness(t *testing.T, repo repository.BeneficiaryRepository) {
+	ctx := context.Background()
+	mum := newBeneficiary("alice", "Mum", "+254700000001")
+	if err := repo.Create(ctx, mum); err != nil || mum.ID == "" {
+		t.Fatalf("create: %v, id %q", err, mum.ID)
+	}
+	for name, b := range map[string]*domain.Beneficiary{
+		"same destination":            newBeneficiary("alice", "Mother", "+254700000001"),
+		"same nickname, another case": newBeneficiary("alice", "MUM", "+254700000002"),
+	} {
+		if err := repo.Create(ctx, b); !errors.Is(err, domain.ErrBeneficiaryExists) {
+			t.Fatalf("%s: expected ErrBeneficiaryExists, got %v", name, err)
+		}
+	}
+	// Another owner may save the same payee under the same name.
+	if err := repo.Create(ctx, newBeneficiary("bob", "Mum", "+254700000001")); err != nil {
+		t.Fatalf("another owner: %v", err)
+	}
+	bank := &domain.Beneficiary{OwnerID: "alice", Type: domain.BeneficiaryTypeBank, Nickname: "Rent", BankCode: "01", AccountNumber: "123",
+		Verification: domain.BeneficiaryPending, CreatedAt: base, UpdatedAt: base}
+	if err := repo.Create(ctx, bank); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetByID(ctx, mum.ID)
+	if err != nil || got.Phone != "+254700000001" || got.AccountName != "Jane" || got.Verification != domain.BeneficiaryVerified ||
+		!got.CoolingOffUntil.Equal(mum.CoolingOffUntil) {
+		t.Fatalf("stored beneficiary differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetByID(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrBeneficiaryNotFound) {
+		t.Fatalf("expected ErrBeneficiaryNotFound, got %v", err)
+	}
+	owned, err := repo.ListByOwner(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range owned {
+		names = append(names, b.Nickname)
+	}
+	if fmt.Sprint(names) != "[Mum Rent]" {
+		t.Fatalf("expected beneficiaries by nickname, got %v", names)
+	}
+}
+
+func testBeneficiaryUpdates(t *testing.T, repo repository.BeneficiaryRepository) {
+	ctx := context.Background()
+	mum := newBeneficiary("alice", "Mum", "+254700000001")
+	dad := newBeneficiary("alice", "Dad", "+254700000002")
+	for _, b := range []*domain.Beneficiary{mum, dad} {
+		if err := repo.Create(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.UpdateNickname(ctx, dad.ID, "mum", base.Add(time.Hour)); !errors.Is(err, domain.ErrBeneficiaryExists) {
+		t.Fatalf("taken nickname: expected ErrBeneficiaryExists, got %v", err)
+	}
+	if err := repo.UpdateNickname(ctx, dad.ID, "Father", base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetByID(ctx, dad.ID); err != nil || got.Nickname != "Father" || !got.UpdatedAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("renamed beneficiary: %+v %v", got, err)
+	}
+	if err := repo.UpdateNickname(ctx, "000000000000000000000000", "x", base); !errors.Is(err, domain.ErrBeneficiaryNotFound) {
+		t.Fatalf("expected ErrBeneficiaryNotFound, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, mum.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, mum.ID); !errors.Is(err, domain.ErrBeneficiaryNotFound) {
+		t.Fatalf("second delete: expected ErrBeneficiaryNotFound, got %v", err)
+	}
+	// Deleting frees the destination and nickname for reuse.
+	if err := repo.Create(ctx, newBeneficiary("alice", "Mum", "+254700000001")); err != nil {
+		t.Fatalf("recreate after delete: %v", err)
+	}
+}
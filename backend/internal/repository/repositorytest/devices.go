@@ -0,0 +1,129 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewDeviceRepository returns an empty repository with its indexes ensured.
+type NewDeviceRepository func(t *testing.T) repository.DeviceRepository
+
+// TestDeviceRepository runs the DeviceRepository contract.
+func TestDeviceRepository(t *testing.T, newRepo NewDeviceRepository) {
+	t.Run("Devices", func(t *testing.T) { testDevices(t, newRepo(t)) })
+	t.Run("OTP", func(t *testing.T) { testDeviceOTP(t, newRepo(t)) })
+	t.Run("Assertions", func(t *testing.T) { testDeviceAssertions(t, newRepo(t)) })
+}
+
+func newDevice(userID, deviceID string, seen time.Time) *domain.Device {
+	return &domain.Device{UserID: userID, DeviceID: deviceID, Platform: domain.DevicePlatformAndroid, PublicKey: "pk", PushToken: "push-1",
+		FirstSeenAt: seen, LastSeenAt: seen}
+}
+
+func testDevices(t *testing.T, repo repository.DeviceRepository) {
+	ctx := context.Background()
+	phone := newDevice("alice", "phone", base)
+	if err := repo.Upsert(ctx, phone); err != nil || phone.ID == "" {
+		t.Fatalf("upsert: %v, id %q", err, phone.ID)
+	}
+	again := newDevice("alice", "phone", base.Add(time.Hour))
+	again.Trusted, again.Platform = true, domain.DevicePlatformIOS
+	if err := repo.Upsert(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != phone.ID || !again.FirstSeenAt.Equal(base) {
+		t.Fatalf("second upsert must keep ID and FirstSeenAt: %+v", again)
+	}
+	got, err := repo.Get(ctx, "alice", "phone")
+	if err != nil || !got.Trusted || got.Platform != domain.DevicePlatformIOS || !got.FirstSeenAt.Equal(base) || !got.LastSeenAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("upserted device: %+v %v", got, err)
+	}
+	if _, err := repo.Get(ctx, "bob", "phone"); !errors.Is(err, domain.ErrDeviceNotFound) {
+		t.Fatalf("another user's device: expected ErrDeviceNotFound, got %v", err)
+	}
+
+	laptop := newDevice("alice", "laptop", base.Add(2*time.Hour))
+	if err := repo.Upsert(ctx, laptop); err != nil {
+		t.Fatal(err)
+	}
+	listed, err := repo.ListByUser(ctx, "alice")
+	if err != nil || len(listed) != 2 || listed[0].ID != laptop.ID {
+		t.Fatalf("devices, most recently seen first: %v %v", listed, err)
+	}
+
+	if err := repo.Touch(ctx, phone.ID, "", base.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.Get(ctx, "alice", "phone"); err != nil || got.PushToken != "push-1" || !got.LastSeenAt.Equal(base.Add(3*time.Hour)) {
+		t.Fatalf("touch without a push token keeps the old one: %+v %v", got, err)
+	}
+	if err := repo.Touch(ctx, phone.ID, "push-2", base.Add(4*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.Get(ctx, "alice", "phone"); err != nil || got.PushToken != "push-2" {
+		t.Fatalf("touch with a push token replaces it: %+v %v", got, err)
+	}
+
+	if err := repo.Delete(ctx, "bob", phone.ID); !errors.Is(err, domain.ErrDeviceNotFound) {
+		t.Fatalf("deleting another user's device: expected ErrDeviceNotFound, got %v", err)
+	}
+	if err := repo.Delete(ctx, "alice", phone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, "alice", "phone"); !errors.Is(err, domain.ErrDeviceNotFound) {
+		t.Fatalf("deleted device: expected ErrDeviceNotFound, got %v", err)
+	}
+}
+
+func testDeviceOTP(t *testing.T, repo repository.DeviceRepository) {
+	ctx := context.Background()
+	challenge := &domain.OTPChallenge{UserID: "alice", CodeHash: "hash", Device: newDevice("alice", "tablet", base), Method: "hwk", ExpiresAt: base.Add(5 * time.Minute), CreatedAt: base}
+	if err := repo.CreateOTP(ctx, challenge); err != nil || challenge.ID == "" {
+		t.Fatalf("create: %v, id %q", err, challenge.ID)
+	}
+	bare := &domain.OTPChallenge{UserID: "bob", CodeHash: "hash", ExpiresAt: base.Add(5 * time.Minute), CreatedAt: base}
+	if err := repo.CreateOTP(ctx, bare); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetOTP(ctx, challenge.ID)
+	if err != nil || got.CodeHash != "hash" || got.Method != "hwk" || got.Device == nil || got.Device.DeviceID != "tablet" || got.Device.PublicKey != "pk" || !got.ExpiresAt.Equal(challenge.ExpiresAt) {
+		t.Fatalf("stored challenge: %+v %v", got, err)
+	}
+	if got, err := repo.GetOTP(ctx, bare.ID); err != nil || got.Device != nil {
+		t.Fatalf("challenge without a device: %+v %v", got, err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		if n, err := repo.RecordOTPFailure(ctx, challenge.ID); err != nil || n != want {
+			t.Fatalf("failure %d: got %d %v", want, n, err)
+		}
+	}
+	if err := repo.DeleteOTP(ctx, challenge.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetOTP(ctx, challenge.ID); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("deleted challenge: expected ErrChallengeNotFound, got %v", err)
+	}
+	if _, err := repo.RecordOTPFailure(ctx, challenge.ID); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("deleted challenge: expected ErrChallengeNotFound, got %v", err)
+	}
+}
+
+func testDeviceAssertions(t *testing.T, repo repository.DeviceRepository) {
+	ctx := context.Background()
+	if err := repo.UseAssertion(ctx, "digest-1", base, base.Add(5*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UseAssertion(ctx, "digest-1", base.Add(time.Minute), base.Add(6*time.Minute)); !errors.Is(err, domain.ErrAssertionReplayed) {
+		t.Fatalf("replayed assertion: expected ErrAssertionReplayed, got %v", err)
+	}
+	if err := repo.UseAssertion(ctx, "digest-2", base.Add(time.Minute), base.Add(6*time.Minute)); err != nil {
+		t.Fatalf("another assertion: %v", err)
+	}
+}
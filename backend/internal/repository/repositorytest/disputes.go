@@ -0,0 +1,122 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewDisputeRepository returns an empty repository with its indexes ensured.
+type NewDisputeRepository func(t *testing.T) repository.DisputeRepository
+
+// TestDisputeRepository runs the DisputeRepository contract.
+func TestDisputeRepository(t *testing.T, newRepo NewDisputeRepository) {
+	t.Run("CreateAndList", func(t *testing.T) { testDisputeCreateAndList(t, newRepo(t)) })
+	t.Run("Lifecycle", func(t *testing.T) { testDisputeLifecycle(t, newRepo(t)) })
+}
+
+func newDispute(entryID string, n int) *domain.Dispute {
+	at := base.Add(time.Duration(n) * time.Minute)
+	return &domain.Dispute{EntryID: entryID, PayerID: "alice", PayeeOwnerID: "shop", PayerAccountID: "a1", PayeeAccountID: "a2",
+		Currency: "KES", Amount: 500, Reason: "not delivered", Status: domain.DisputeStatusOpen, CreatedAt: at, UpdatedAt: at}
+}
+
+func testDisputeCreateAndList(t *testing.T, repo repository.DisputeRepository) {
+	ctx := context.Background()
+	first := newDispute("e1", 1)
+	if err := repo.Create(ctx, first); err != nil || first.ID == "" {
+		t.Fatalf("create: %v, id %q", err, first.ID)
+	}
+	if err := repo.Create(ctx, newDispute("e1", 2)); !errors.Is(err, domain.ErrDisputeExists) {
+		t.Fatalf("second dispute on an entry: expected ErrDisputeExists, got %v", err)
+	}
+	second := newDispute("e2", 2)
+	second.PayerID, second.PayeeOwnerID = "shop", "bob"
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetActiveByEntry(ctx, "e1")
+	if err != nil || got.ID != first.ID || got.Amount != 500 || got.Reason != "not delivered" || len(got.Evidence) != 0 {
+		t.Fatalf("active dispute: %+v %v", got, err)
+	}
+	if _, err := repo.GetActiveByEntry(ctx, "e3"); !errors.Is(err, domain.ErrDisputeNotFound) {
+		t.Fatalf("expected ErrDisputeNotFound, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrDisputeNotFound) {
+		t.Fatalf("expected ErrDisputeNotFound, got %v", err)
+	}
+
+	for party, want := range map[string][]string{"alice": {first.ID}, "bob": {second.ID}, "shop": {second.ID, first.ID}} {
+		listed, err := repo.ListByParty(ctx, party)
+		if err != nil || len(listed) != len(want) {
+			t.Fatalf("%s: expected %d disputes, got %d %v", party, len(want), len(listed), err)
+		}
+		for i := range want {
+			if listed[i].ID != want[i] {
+				t.Fatalf("%s: disputes not newest first: %v", party, listed)
+			}
+		}
+	}
+	all, err := repo.ListByStatus(ctx, "")
+	if err != nil || len(all) != 2 || all[0].ID != second.ID {
+		t.Fatalf("all disputes, newest first: %v %v", all, err)
+	}
+}
+
+func testDisputeLifecycle(t *testing.T, repo repository.DisputeRepository) {
+	ctx := context.Background()
+	dispute := newDispute("e1", 1)
+	if err := repo.Create(ctx, dispute); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetHold(ctx, dispute.ID, "hold-entry", 500, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	evidence := domain.Evidence{ID: "ev1", SubmittedBy: "alice", Note: "receipt", FileName: "r.pdf", ContentType: "application/pdf",
+		SHA256: "abc", Size: 3, Data: []byte("pdf"), CreatedAt: base.Add(2 * time.Hour)}
+	if err := repo.AddEvidence(ctx, dispute.ID, evidence); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddEvidence(ctx, "000000000000000000000000", evidence); !errors.Is(err, domain.ErrDisputeNotFound) {
+		t.Fatalf("expected ErrDisputeNotFound, got %v", err)
+	}
+	got, err := repo.GetByID(ctx, dispute.ID)
+	if err != nil || got.HoldEntryID != "hold-entry" || got.HeldAmount != 500 || len(got.Evidence) != 1 ||
+		string(got.Evidence[0].Data) != "pdf" || got.Evidence[0].FileName != "r.pdf" || !got.UpdatedAt.Equal(evidence.CreatedAt) {
+		t.Fatalf("dispute with hold and evidence: %+v %v", got, err)
+	}
+
+	dispute.Status, dispute.UpdatedAt = domain.DisputeStatusUnderReview, base.Add(3*time.Hour)
+	if err := repo.Transition(ctx, dispute, domain.DisputeStatusOpen); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Transition(ctx, dispute, domain.DisputeStatusOpen); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("stale transition: expected ErrInvalidTransition, got %v", err)
+	}
+	reviewing, err := repo.ListByStatus(ctx, domain.DisputeStatusUnderReview)
+	if err != nil || len(reviewing) != 1 || reviewing[0].ID != dispute.ID {
+		t.Fatalf("disputes under review: %v %v", reviewing, err)
+	}
+
+	dispute.Status, dispute.ResolutionEntryID, dispute.ResolutionNote, dispute.ResolvedBy = domain.DisputeStatusResolvedCustomer, "refund", "refunded", "agent"
+	dispute.ResolvedAt, dispute.UpdatedAt = base.Add(4*time.Hour), base.Add(4*time.Hour)
+	if err := repo.Transition(ctx, dispute, domain.DisputeStatusUnderReview); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetByID(ctx, dispute.ID)
+	if err != nil || got.Status != domain.DisputeStatusResolvedCustomer || got.ResolutionEntryID != "refund" || got.ResolvedBy != "agent" || !got.ResolvedAt.Equal(dispute.ResolvedAt) {
+		t.Fatalf("resolved dispute: %+v %v", got, err)
+	}
+	if _, err := repo.GetActiveByEntry(ctx, "e1"); !errors.Is(err, domain.ErrDisputeNotFound) {
+		t.Fatalf("a resolved dispute is not active: %v", err)
+	}
+	// Once resolved, the entry may be disputed again.
+	if err := repo.Create(ctx, newDispute("e1", 5)); err != nil {
+		t.Fatalf("new dispute after resolution: %v", err)
+	}
+}
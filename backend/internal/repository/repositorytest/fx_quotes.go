@@ -0,0 +1,43 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewFXQuoteRepository returns an empty repository with its indexes ensured.
+type NewFXQuoteRepository func(t *testing.T) repository.FXQuoteRepository
+
+// TestFXQuoteRepository runs the FXQuoteRepository contract.
+func TestFXQuoteRepository(t *testing.T, newRepo NewFXQuoteRepository) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	quote := &domain.FXQuote{UserID: "alice", SellCurrency: "KES", BuyCurrency: "USD", SellAmount: 13000, BuyAmount: 99, Fee: 50,
+		MidRate: "0.0077", Rate: "0.0076", SpreadBps: 150, Status: domain.FXQuoteStatusOpen, ExpiresAt: base.Add(time.Minute), CreatedAt: base}
+	if err := repo.Create(ctx, quote); err != nil || quote.ID == "" {
+		t.Fatalf("create: %v, id %q", err, quote.ID)
+	}
+	got, err := repo.GetByID(ctx, quote.ID)
+	if err != nil || got.Rate != "0.0076" || got.SpreadBps != 150 || got.BuyAmount != 99 || !got.ExpiresAt.Equal(quote.ExpiresAt) {
+		t.Fatalf("stored quote differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetByID(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrQuoteNotFound) {
+		t.Fatalf("expected ErrQuoteNotFound, got %v", err)
+	}
+
+	if err := repo.MarkExecuted(ctx, quote.ID, "e1", base.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkExecuted(ctx, quote.ID, "e2", base.Add(2*time.Second)); !errors.Is(err, domain.ErrQuoteExecuted) {
+		t.Fatalf("second execution: expected ErrQuoteExecuted, got %v", err)
+	}
+	got, err = repo.GetByID(ctx, quote.ID)
+	if err != nil || got.Status != domain.FXQuoteStatusExecuted || got.EntryID != "e1" || !got.ExecutedAt.Equal(base.Add(time.Second)) {
+		t.Fatalf("executed quote: %+v %v", got, err)
+	}
+}
@@ -0,0 +1,352 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewLedgerRepository returns an empty repository with its indexes ensured.
+type NewLedgerRepository func(t *testing.T) repository.LedgerRepository
+
+// TestLedgerRepository runs the LedgerRepository contract.
+func TestLedgerRepository(t *testing.T, newRepo NewLedgerRepository) {
+	t.Run("Accounts", func(t *testing.T) { testLedgerAccounts(t, newRepo(t)) })
+	t.Run("Post", func(t *testing.T) { testLedgerPost(t, newRepo(t)) })
+	t.Run("Entries", func(t *testing.T) { testLedgerEntries(t, newRepo(t)) })
+	t.Run("Holds", func(t *testing.T) { testLedgerHolds(t, newRepo(t)) })
+	t.Run("PayeeCap", func(t *testing.T) { testLedgerPayeeCap(t, newRepo(t)) })
+}
+
+// ledgerFixture opens a house account and two wallets in KES, funding the
+// first wallet with 1000 from the house account.
+type ledgerFixture struct {
+	repo              repository.LedgerRepository
+	house, alice, bob *domain.Account
+}
+
+func newLedgerFixture(t *testing.T, repo repository.LedgerRepository) *ledgerFixture {
+	t.Helper()
+	f := &ledgerFixture{repo: repo}
+	f.house = mustAccount(t, repo, domain.SystemOwnerSuspense, domain.AccountTypeSystem)
+	f.alice = mustAccount(t, repo, "alice", domain.AccountTypeWallet)
+	f.bob = mustAccount(t, repo, "bob", domain.AccountTypeWallet)
+	if err := repo.Post(context.Background(), transfer(f.house, f.alice, 1000, "fund", base)); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func mustAccount(t *testing.T, repo repository.LedgerRepository, ownerID string, accountType domain.AccountType) *domain.Account {
+	t.Helper()
+	a, err := repo.GetOrCreateAccount(context.Background(), ownerID, accountType, "KES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func transfer(from, to *domain.Account, amount int64, reference string, at time.Time) *domain.JournalEntry {
+	return &domain.JournalEntry{Kind: domain.EntryKindTransfer, Reference: reference, Postings: []domain.Posting{
+		{AccountID: from.ID, Amount: -amount, Currency: "KES"},
+		{AccountID: to.ID, Amount: amount, Currency: "KES"},
+	}, CreatedAt: at}
+}
+
+// balances reports the stored balance and held amount of a.
+func (f *ledgerFixture) balances(t *testing.T, a *domain.Account) (int64, int64) {
+	t.Helper()
+	got, err := f.repo.GetAccount(context.Background(), a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got.Balance, got.Held
+}
+
+func (f *ledgerFixture) expect(t *testing.T, a *domain.Account, balance, held int64) {
+	t.Helper()
+	if b, h := f.balances(t, a); b != balance || h != held {
+		t.Fatalf("account %s: balance %d held %d, want %d and %d", a.OwnerID, b, h, balance, held)
+	}
+}
+
+func testLedgerAccounts(t *testing.T, repo repository.LedgerRepository) {
+	ctx := context.Background()
+	a := mustAccount(t, repo, "alice", domain.AccountTypeWallet)
+	if again := mustAccount(t, repo, "alice", domain.AccountTypeWallet); again.ID != a.ID {
+		t.Fatalf("second open made a new account: %s vs %s", again.ID, a.ID)
+	}
+	usd, err := repo.GetOrCreateAccount(ctx, "alice", domain.AccountTypeWallet, "USD")
+	if err != nil || usd.ID == a.ID || usd.Balance != 0 {
+		t.Fatalf("USD account: %+v %v", usd, err)
+	}
+	owned, err := repo.ListAccountsByOwner(ctx, "alice")
+	if err != nil || len(owned) != 2 {
+		t.Fatalf("expected 2 accounts, got %d %v", len(owned), err)
+	}
+	if _, err := repo.GetAccount(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func testLedgerPost(t *testing.T, repo repository.LedgerRepository) {
+	ctx := context.Background()
+	f := newLedgerFixture(t, repo)
+	f.expect(t, f.house, -1000, 0)
+	f.expect(t, f.alice, 1000, 0)
+
+	for name, c := range map[string]struct {
+		entry *domain.JournalEntry
+		want  error
+	}{
+		"overdraft":         {transfer(f.alice, f.bob, 1001, "", base), domain.ErrInsufficientFunds},
+		"reused reference":  {transfer(f.alice, f.bob, 10, "fund", base), domain.ErrDuplicateEntry},
+		"unbalanced":        {&domain.JournalEntry{Kind: domain.EntryKindTransfer, Postings: []domain.Posting{{AccountID: f.alice.ID, Amount: -5, Currency: "KES"}, {AccountID: f.bob.ID, Amount: 4, Currency: "KES"}}, CreatedAt: base}, domain.ErrUnbalancedEntry},
+		"currency mismatch": {&domain.JournalEntry{Kind: domain.EntryKindTransfer, Postings: []domain.Posting{{AccountID: f.alice.ID, Amount: -5, Currency: "USD"}, {AccountID: f.bob.ID, Amount: 5, Currency: "USD"}}, CreatedAt: base}, domain.ErrAccountNotFound},
+		"missing account":   {transfer(f.alice, &domain.Account{ID: "000000000000000000000000"}, 5, "", base), domain.ErrAccountNotFound},
+	} {
+		if err := repo.Post(ctx, c.entry); !errors.Is(err, c.want) {
+			t.Fatalf("%s: expected %v, got %v", name, c.want, err)
+		}
+	}
+	// A rejected entry leaves every balance as it was.
+	f.expect(t, f.alice, 1000, 0)
+	f.expect(t, f.bob, 0, 0)
+
+	entry := transfer(f.alice, f.bob, 1000, "t1", base.Add(time.Minute))
+	if err := repo.Post(ctx, entry); err != nil || entry.ID == "" {
+		t.Fatalf("post: %v, id %q", err, entry.ID)
+	}
+	f.expect(t, f.alice, 0, 0)
+	f.expect(t, f.bob, 1000, 0)
+	got, err := repo.GetEntry(ctx, entry.ID)
+	if err != nil || got.Reference != "t1" || len(got.Postings) != 2 || got.AmountFor(f.bob.ID) != 1000 || !got.CreatedAt.Equal(entry.CreatedAt) {
+		t.Fatalf("stored entry differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetEntry(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrEntryNotFound) {
+		t.Fatalf("expected ErrEntryNotFound, got %v", err)
+	}
+	if got, err := repo.GetEntryByReference(ctx, "t1"); err != nil || got.ID != entry.ID {
+		t.Fatalf("expected the entry by reference, got %+v %v", got, err)
+	}
+	for _, reference := range []string{"t2", ""} {
+		if _, err := repo.GetEntryByReference(ctx, reference); !errors.Is(err, domain.ErrEntryNotFound) {
+			t.Fatalf("reference %q: expected ErrEntryNotFound, got %v", reference, err)
+		}
+	}
+}
+
+func testLedgerEntries(t *testing.T, repo repository.LedgerRepository) {
+	ctx := context.Background()
+	f := newLedgerFixture(t, repo)
+	var posted []*domain.JournalEntry
+	for i := 1; i <= 3; i++ {
+		e := transfer(f.alice, f.bob, 10, "", base.Add(time.Duration(i)*time.Minute))
+		if err := repo.Post(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		posted = append(posted, e)
+	}
+	reversal := transfer(f.bob, f.alice, 10, "", base.Add(10*time.Minute))
+	reversal.Kind, reversal.ReversalOf = domain.EntryKindReversal, posted[0].ID
+	if err := repo.Post(ctx, reversal); err != nil {
+		t.Fatal(err)
+	}
+
+	window, err := repo.ListEntriesByAccount(ctx, f.bob.ID, base.Add(2*time.Minute), base.Add(10*time.Minute))
+	if err != nil || len(window) != 2 || window[0].ID != posted[1].ID || window[1].ID != posted[2].ID {
+		t.Fatalf("window [2m, 10m): %v %v", window, err)
+	}
+	reversals, err := repo.ListReversals(ctx, posted[0].ID)
+	if err != nil || len(reversals) != 1 || reversals[0].ID != reversal.ID {
+		t.Fatalf("reversals: %v %v", reversals, err)
+	}
+
+	// Paging two at a time visits every entry once, in order.
+	var seen []string
+	var after time.Time
+	var afterID string
+	for {
+		page, err := repo.ListEntriesAfter(ctx, after, afterID, base.Add(time.Hour), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, e := range page {
+			seen = append(seen, e.ID)
+		}
+		last := page[len(page)-1]
+		after, afterID = last.CreatedAt, last.ID
+	}
+	if len(seen) != 5 || seen[1] != posted[0].ID || seen[4] != reversal.ID {
+		t.Fatalf("paged entries out of order: %v", seen)
+	}
+	capped, err := repo.ListEntriesAfter(ctx, time.Time{}, "", base.Add(2*time.Minute), 10)
+	if err != nil || len(capped) != 2 {
+		t.Fatalf("expected the entries before until, got %d %v", len(capped), err)
+	}
+}
+
+func testLedgerHolds(t *testing.T, repo repository.LedgerRepository) {
+	ctx := context.Background()
+	f := newLedgerFixture(t, repo)
+	newHold := func(amount int64, expires time.Duration) *domain.Hold {
+		return &domain.Hold{AccountID: f.alice.ID, OwnerID: "alice", Currency: "KES", Amount: amount, Kind: domain.HoldKindMerchantPayment, Status: domain.HoldStatusActive,
+			PayeeAccountID: f.bob.ID, ExpiresAt: base.Add(expires), CreatedAt: base, UpdatedAt: base}
+	}
+	capture := newHold(600, time.Hour)
+	if err := repo.PlaceHold(ctx, capture); err != nil || capture.ID == "" {
+		t.Fatalf("place: %v, id %q", err, capture.ID)
+	}
+	f.expect(t, f.alice, 1000, 600)
+	if err := repo.PlaceHold(ctx, newHold(401, time.Hour)); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("hold beyond available: expected ErrInsufficientFunds, got %v", err)
+	}
+	if err := repo.Post(ctx, transfer(f.alice, f.bob, 401, "", base)); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("spending held funds: expected ErrInsufficientFunds, got %v", err)
+	}
+	for _, amount := range []int64{0, -1000} {
+		if err := repo.PlaceHold(ctx, newHold(amount, time.Hour)); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Fatalf("hold of %d: expected ErrInvalidInput, got %v", amount, err)
+		}
+	}
+	f.expect(t, f.alice, 1000, 600)
+	houseHold := newHold(1, time.Hour)
+	houseHold.AccountID = f.house.ID
+	if err := repo.PlaceHold(ctx, houseHold); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("hold on a house account: expected ErrInsufficientFunds, got %v", err)
+	}
+	expiring := newHold(100, time.Minute)
+	if err := repo.PlaceHold(ctx, expiring); err != nil {
+		t.Fatal(err)
+	}
+	active, err := repo.ListActiveHolds(ctx, f.alice.ID)
+	if err != nil || len(active) != 2 || active[0].ID != capture.ID {
+		t.Fatalf("active holds: %v %v", active, err)
+	}
+
+	// Capturing takes less than was held and releases the rest.
+	entry := transfer(f.alice, f.bob, 500, "capture", base.Add(time.Second))
+	entry.Kind = domain.EntryKindMerchantPayment
+	if err := repo.CaptureHold(ctx, capture.ID, 500, entry); err != nil || entry.ID == "" {
+		t.Fatalf("capture: %v, id %q", err, entry.ID)
+	}
+	f.expect(t, f.alice, 500, 100)
+	f.expect(t, f.bob, 500, 0)
+	got, err := repo.GetHold(ctx, capture.ID)
+	if err != nil || got.Status != domain.HoldStatusCaptured || got.CapturedAmount != 500 || got.EntryID != entry.ID {
+		t.Fatalf("captured hold: %+v %v", got, err)
+	}
+	if err := repo.CaptureHold(ctx, capture.ID, 500, transfer(f.alice, f.bob, 1, "", base.Add(time.Second))); !errors.Is(err, domain.ErrHoldNotActive) {
+		t.Fatalf("second capture: expected ErrHoldNotActive, got %v", err)
+	}
+	if err := repo.CaptureHold(ctx, "000000000000000000000000", 1, transfer(f.alice, f.bob, 1, "", base)); !errors.Is(err, domain.ErrHoldNotFound) {
+		t.Fatalf("missing hold: expected ErrHoldNotFound, got %v", err)
+	}
+
+	// A capture the ledger rejects leaves the hold in place.
+	overdraw := transfer(f.alice, f.bob, 601, "", base.Add(time.Second))
+	if err := repo.CaptureHold(ctx, expiring.ID, 601, overdraw); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("overdrawing capture: expected ErrInsufficientFunds, got %v", err)
+	}
+	f.expect(t, f.alice, 500, 100)
+
+	if err := repo.ReleaseHold(ctx, expiring.ID, domain.HoldStatusExpired, base); !errors.Is(err, domain.ErrHoldNotActive) {
+		t.Fatalf("expiring early: expected ErrHoldNotActive, got %v", err)
+	}
+	expired, err := repo.ListExpiredHolds(ctx, base.Add(time.Minute), 10)
+	if err != nil || len(expired) != 1 || expired[0].ID != expiring.ID {
+		t.Fatalf("expired holds: %v %v", expired, err)
+	}
+	if err := repo.ReleaseHold(ctx, expiring.ID, domain.HoldStatusExpired, base.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	f.expect(t, f.alice, 500, 0)
+	if err := repo.ReleaseHold(ctx, expiring.ID, domain.HoldStatusVoided, base.Add(time.Minute)); !errors.Is(err, domain.ErrHoldNotActive) {
+		t.Fatalf("second release: expected ErrHoldNotActive, got %v", err)
+	}
+
+	// A hold without an expiry never lapses but can still be captured.
+	open := newHold(200, 0)
+	open.ExpiresAt = time.Time{}
+	if err := repo.PlaceHold(ctx, open); err != nil {
+		t.Fatal(err)
+	}
+	later := base.Add(365 * 24 * time.Hour)
+	if expired, err := repo.ListExpiredHolds(ctx, later, 10); err != nil || len(expired) != 0 {
+		t.Fatalf("expired holds without expiry: %v %v", expired, err)
+	}
+	if err := repo.ReleaseHold(ctx, open.ID, domain.HoldStatusExpired, later); !errors.Is(err, domain.ErrHoldNotActive) {
+		t.Fatalf("expiring a hold without expiry: expected ErrHoldNotActive, got %v", err)
+	}
+	late := transfer(f.alice, f.bob, 200, "late capture", later)
+	if err := repo.CaptureHold(ctx, open.ID, 200, late); err != nil {
+		t.Fatalf("capturing a hold without expiry: %v", err)
+	}
+	f.expect(t, f.alice, 300, 0)
+}
+
+func testLedgerPayeeCap(t *testing.T, repo repository.LedgerRepository) {
+	ctx := context.Background()
+	f := newLedgerFixture(t, repo)
+	if first, err := repo.FirstPaymentTo(ctx, f.alice.ID, "recipientId", "bob"); err != nil || !first.IsZero() {
+		t.Fatalf("first payment before any: %v %v", first, err)
+	}
+	limit := domain.PayeeCap{AccountID: f.alice.ID, Field: "recipientId", Payee: "bob", Since: base, Limit: 500}
+	pay := func(amount, fee int64, at time.Time) *domain.JournalEntry {
+		e := transfer(f.alice, f.bob, amount, "", at)
+		if fee > 0 {
+			e.Postings[0].Amount -= fee
+			e.Postings = append(e.Postings, domain.Posting{AccountID: f.house.ID, Amount: fee, Currency: "KES"})
+		}
+		e.Metadata = map[string]string{"recipientId": "bob", "fee": strconv.FormatInt(fee, 10)}
+		return e
+	}
+	if err := repo.PostWithinCap(ctx, pay(300, 50, base.Add(time.Minute)), limit); err != nil {
+		t.Fatalf("payment within cap: %v", err)
+	}
+	hold := &domain.Hold{AccountID: f.alice.ID, OwnerID: "alice", Currency: "KES", Amount: 150, Kind: domain.HoldKindWithdrawal, Status: domain.HoldStatusActive,
+		Metadata: map[string]string{"recipientId": "bob", "fee": "50"}, CreatedAt: base, UpdatedAt: base}
+	if err := repo.PlaceHoldWithinCap(ctx, hold, limit); err != nil {
+		t.Fatalf("hold within cap: %v", err)
+	}
+	// Fees do not count: 300 posted and 100 held leave 100.
+	if sent, err := repo.SentWithinCap(ctx, limit); err != nil || sent != 400 {
+		t.Fatalf("sent within cap: %d %v", sent, err)
+	}
+	if err := repo.PostWithinCap(ctx, pay(101, 0, base.Add(2*time.Minute)), limit); !errors.Is(err, domain.ErrCoolingOffLimit) {
+		t.Fatalf("payment past the cap: expected ErrCoolingOffLimit, got %v", err)
+	}
+	over := &domain.Hold{AccountID: f.alice.ID, OwnerID: "alice", Currency: "KES", Amount: 101, Kind: domain.HoldKindWithdrawal, Status: domain.HoldStatusActive,
+		Metadata: map[string]string{"recipientId": "bob"}, CreatedAt: base, UpdatedAt: base}
+	if err := repo.PlaceHoldWithinCap(ctx, over, limit); !errors.Is(err, domain.ErrCoolingOffLimit) {
+		t.Fatalf("hold past the cap: expected ErrCoolingOffLimit, got %v", err)
+	}
+	f.expect(t, f.alice, 650, 150)
+	// Releasing the hold frees its share of the cap.
+	if err := repo.ReleaseHold(ctx, hold.ID, domain.HoldStatusVoided, base.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PostWithinCap(ctx, pay(200, 0, base.Add(3*time.Minute)), limit); err != nil {
+		t.Fatalf("payment after the release: %v", err)
+	}
+	// A later window no longer counts the earlier payments.
+	later := limit
+	later.Since = base.Add(3 * time.Minute)
+	if sent, err := repo.SentWithinCap(ctx, later); err != nil || sent != 200 {
+		t.Fatalf("sent since the later start: %d %v", sent, err)
+	}
+	if first, err := repo.FirstPaymentTo(ctx, f.alice.ID, "recipientId", "bob"); err != nil || !first.Equal(base.Add(time.Minute)) {
+		t.Fatalf("first payment: %v %v", first, err)
+	}
+	if first, err := repo.FirstPaymentTo(ctx, f.bob.ID, "recipientId", "bob"); err != nil || !first.IsZero() {
+		t.Fatalf("payments into an account are not payments from it: %v %v", first, err)
+	}
+}
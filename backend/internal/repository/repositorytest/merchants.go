@@ -0,0 +1,45 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewMerchantRepository returns an empty repository with its indexes
+// ensured.
+type NewMerchantRepository func(t *testing.T) repository.MerchantRepository
+
+// TestMerchantRepository runs the MerchantRepository contract.
+func TestMerchantRepository(t *testing.T, newRepo NewMerchantRepository) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	var created []*domain.Merchant
+	for i, name := range []string{"Corner Shop", "Kiosk"} {
+		at := base.Add(time.Duration(i) * time.Minute)
+		m := &domain.Merchant{OwnerID: "alice", Name: name, City: "Nairobi", CategoryCode: "5411", CountryCode: "KE", Currency: "KES",
+			Status: domain.MerchantStatusActive, CreatedAt: at, UpdatedAt: at}
+		if err := repo.Create(ctx, m); err != nil || m.ID == "" {
+			t.Fatalf("create: %v, id %q", err, m.ID)
+		}
+		created = append(created, m)
+	}
+	got, err := repo.GetByID(ctx, created[0].ID)
+	if err != nil || got.Name != "Corner Shop" || got.CategoryCode != "5411" || got.Status != domain.MerchantStatusActive {
+		t.Fatalf("stored merchant differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetByID(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrMerchantNotFound) {
+		t.Fatalf("expected ErrMerchantNotFound, got %v", err)
+	}
+	owned, err := repo.ListByOwner(ctx, "alice")
+	if err != nil || len(owned) != 2 || owned[0].ID != created[0].ID {
+		t.Fatalf("merchants, oldest first: %v %v", owned, err)
+	}
+	if none, err := repo.ListByOwner(ctx, "bob"); err != nil || len(none) != 0 {
+		t.Fatalf("another owner has none: %v %v", none, err)
+	}
+}
@@ -0,0 +1,151 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewOAuthRepository returns an empty repository with its indexes ensured.
+type NewOAuthRepository func(t *testing.T) repository.OAuthRepository
+
+// TestOAuthRepository runs the OAuthRepository contract.
+func TestOAuthRepository(t *testing.T, newRepo NewOAuthRepository) {
+	t.Run("Consents", func(t *testing.T) { testOAuthConsents(t, newRepo(t)) })
+	t.Run("Codes", func(t *testing.T) { testOAuthCodes(t, newRepo(t)) })
+	t.Run("Grants", func(t *testing.T) { testOAuthGrants(t, newRepo(t)) })
+}
+
+func testOAuthConsents(t *testing.T, repo repository.OAuthRepository) {
+	ctx := context.Background()
+	consent := &domain.OAuthConsent{UserID: "alice", ClientID: "app", Scopes: []domain.Scope{domain.ScopeOpenID}, CreatedAt: base, UpdatedAt: base}
+	if err := repo.UpsertConsent(ctx, consent); err != nil || consent.ID == "" {
+		t.Fatalf("upsert: %v, id %q", err, consent.ID)
+	}
+	other := &domain.OAuthConsent{UserID: "alice", ClientID: "other", Scopes: []domain.Scope{domain.ScopeOpenID}, CreatedAt: base, UpdatedAt: base.Add(time.Minute)}
+	if err := repo.UpsertConsent(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RevokeConsent(ctx, consent.ID, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetConsentByID(ctx, consent.ID); err != nil || got.Active() {
+		t.Fatalf("revoked consent: %+v %v", got, err)
+	}
+
+	// Granting again reuses the record, replaces the scopes and lifts the
+	// revocation.
+	again := &domain.OAuthConsent{UserID: "alice", ClientID: "app", Scopes: []domain.Scope{domain.ScopeOpenID, domain.ScopeProfile}, CreatedAt: base.Add(2 * time.Hour), UpdatedAt: base.Add(2 * time.Hour)}
+	if err := repo.UpsertConsent(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != consent.ID || !again.CreatedAt.Equal(base) || !again.Active() {
+		t.Fatalf("second upsert must keep the record: %+v", again)
+	}
+	got, err := repo.GetConsent(ctx, "alice", "app")
+	if err != nil || !got.Covers([]domain.Scope{domain.ScopeProfile}) {
+		t.Fatalf("consent after second upsert: %+v %v", got, err)
+	}
+	if _, err := repo.GetConsent(ctx, "bob", "app"); !errors.Is(err, domain.ErrConsentNotFound) {
+		t.Fatalf("expected ErrConsentNotFound, got %v", err)
+	}
+
+	if err := repo.RevokeConsent(ctx, other.ID, base.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	listed, err := repo.ListConsents(ctx, "alice")
+	if err != nil || len(listed) != 1 || listed[0].ID != consent.ID {
+		t.Fatalf("only active consents are listed: %v %v", listed, err)
+	}
+	if err := repo.RevokeConsent(ctx, "000000000000000000000000", base); !errors.Is(err, domain.ErrConsentNotFound) {
+		t.Fatalf("expected ErrConsentNotFound, got %v", err)
+	}
+}
+
+func testOAuthCodes(t *testing.T, repo repository.OAuthRepository) {
+	ctx := context.Background()
+	code := &domain.OAuthCode{Hash: "code-hash", ClientID: "app", UserID: "alice", RedirectURI: "https://app.example.com/cb", Scopes: []domain.Scope{domain.ScopeOpenID},
+		CodeChallenge: "challenge", Nonce: "n", AuthTime: base, ExpiresAt: base.Add(time.Minute), CreatedAt: base}
+	if err := repo.CreateCode(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateCode(ctx, code); !errors.Is(err, domain.ErrDuplicateEntry) {
+		t.Fatalf("reused hash: expected ErrDuplicateEntry, got %v", err)
+	}
+	got, err := repo.ConsumeCode(ctx, "code-hash", base.Add(time.Second))
+	if err != nil || got.UserID != "alice" || got.CodeChallenge != "challenge" || got.Nonce != "n" || fmt.Sprint(got.Scopes) != "[openid]" || !got.AuthTime.Equal(base) {
+		t.Fatalf("consumed code: %+v %v", got, err)
+	}
+	replayed, err := repo.ConsumeCode(ctx, "code-hash", base.Add(2*time.Second))
+	if !errors.Is(err, domain.ErrTokenReused) || replayed == nil || replayed.UserID != "alice" {
+		t.Fatalf("replayed code: expected ErrTokenReused with the code, got %+v %v", replayed, err)
+	}
+	if _, err := repo.ConsumeCode(ctx, "unknown", base); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("unknown code: expected ErrInvalidGrant, got %v", err)
+	}
+}
+
+func testOAuthGrants(t *testing.T, repo repository.OAuthRepository) {
+	ctx := context.Background()
+	grant := &domain.OAuthGrant{ClientID: "app", UserID: "alice", CodeHash: "code-hash", Scopes: []domain.Scope{domain.ScopeOpenID}, RefreshHash: "r1",
+		ExpiresAt: base.Add(24 * time.Hour), CreatedAt: base, UpdatedAt: base}
+	if err := repo.CreateGrant(ctx, grant); err != nil || grant.ID == "" {
+		t.Fatalf("create: %v, id %q", err, grant.ID)
+	}
+	dup := *grant
+	dup.ID = ""
+	if err := repo.CreateGrant(ctx, &dup); !errors.Is(err, domain.ErrDuplicateEntry) {
+		t.Fatalf("reused refresh hash: expected ErrDuplicateEntry, got %v", err)
+	}
+
+	if err := repo.RotateRefresh(ctx, grant.ID, "r1", "r2", base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RotateRefresh(ctx, grant.ID, "r1", "r3", base.Add(time.Hour)); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("rotating a used token: expected ErrInvalidGrant, got %v", err)
+	}
+	// A rotated-out token still finds its grant, so reuse can revoke it.
+	for _, hash := range []string{"r1", "r2"} {
+		if got, err := repo.GetGrantByRefresh(ctx, hash); err != nil || got.ID != grant.ID || got.RefreshHash != "r2" {
+			t.Fatalf("grant by %s: %+v %v", hash, got, err)
+		}
+	}
+	if _, err := repo.GetGrantByRefresh(ctx, "r9"); !errors.Is(err, domain.ErrGrantNotFound) {
+		t.Fatalf("expected ErrGrantNotFound, got %v", err)
+	}
+
+	other := &domain.OAuthGrant{ClientID: "app", UserID: "alice", CodeHash: "other-code", RefreshHash: "o1", ExpiresAt: base.Add(24 * time.Hour), CreatedAt: base, UpdatedAt: base}
+	if err := repo.CreateGrant(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RevokeGrantsByCode(ctx, "code-hash", base.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetGrant(ctx, grant.ID); err != nil || got.Active(base.Add(3*time.Hour)) || !got.RevokedAt.Equal(base.Add(2*time.Hour)) {
+		t.Fatalf("grant revoked by code: %+v %v", got, err)
+	}
+	if err := repo.RotateRefresh(ctx, grant.ID, "r2", "r3", base.Add(3*time.Hour)); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("rotating a revoked grant: expected ErrInvalidGrant, got %v", err)
+	}
+	if got, err := repo.GetGrant(ctx, other.ID); err != nil || !got.Active(base.Add(3*time.Hour)) {
+		t.Fatalf("other grant untouched: %+v %v", got, err)
+	}
+	if err := repo.RevokeGrants(ctx, "alice", "app", base.Add(4*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetGrant(ctx, grant.ID)
+	if err != nil || !got.RevokedAt.Equal(base.Add(2*time.Hour)) {
+		t.Fatalf("revoking again keeps the first revocation: %+v %v", got, err)
+	}
+	if got, err := repo.GetGrant(ctx, other.ID); err != nil || got.RevokedAt.IsZero() {
+		t.Fatalf("grants revoked by user and client: %+v %v", got, err)
+	}
+	if err := repo.RevokeGrant(ctx, "not-an-id", base); !errors.Is(err, domain.ErrGrantNotFound) {
+		t.Fatalf("expected ErrGrantNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewOutbox returns an empty outbox and a user repository writing to it, so
+// the suite can raise events the way the use cases do.
+type NewOutbox func(t *testing.T) (repository.OutboxRepository, repository.UserRepository)
+
+// TestOutboxRepository runs the OutboxRepository contract.
+func TestOutboxRepository(t *testing.T, newOutbox NewOutbox) {
+	t.Run("Sequencing", func(t *testing.T) {
+		outbox, users := newOutbox(t)
+		testOutboxSequencing(t, outbox, users)
+	})
+	t.Run("Relay", func(t *testing.T) {
+		outbox, users := newOutbox(t)
+		testOutboxRelay(t, outbox, users)
+	})
+}
+
+// signUpAndActivate creates a user and activates it, raising two events
+// on the user's aggregate.
+func signUpAndActivate(t *testing.T, users repository.UserRepository, n int) *domain.User {
+	t.Helper()
+	u := mustCreate(t, users, newUser(n))
+	activate := &domain.UserStatusEvent{UserID: u.ID, From: domain.UserStatusPendingVerification, To: domain.UserStatusActive, Reason: "verified", ActorID: u.ID, CreatedAt: u.CreatedAt}
+	if err := users.TransitionStatus(context.Background(), activate); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func testOutboxSequencing(t *testing.T, outbox repository.OutboxRepository, users repository.UserRepository) {
+	ctx := context.Background()
+	u := signUpAndActivate(t, users, 1)
+	other := signUpAndActivate(t, users, 2)
+
+	due, err := outbox.ListDue(ctx, base.Add(time.Hour), 10)
+	if err != nil || len(due) != 4 {
+		t.Fatalf("expected 4 due events, got %d %v", len(due), err)
+	}
+	signup, activated := due[0], due[1]
+	if signup.Type != domain.EventUserSignedUp || signup.AggregateID != u.ID || signup.Seq != 1 || signup.Status != domain.OutboxStatusPending {
+		t.Fatalf("signup event: %+v", signup)
+	}
+	if activated.Type != domain.EventUserStatusChanged || activated.AggregateID != u.ID || activated.Seq != 2 {
+		t.Fatalf("status event: %+v", activated)
+	}
+	if due[2].AggregateID != other.ID || due[2].Seq != 1 {
+		t.Fatalf("each aggregate numbers its own events: %+v", due[2])
+	}
+	if len(signup.Payload) == 0 || signup.ID == "" {
+		t.Fatalf("event stored without ID or payload: %+v", signup)
+	}
+
+	notYet, err := outbox.ListDue(ctx, base.Add(time.Minute), 10)
+	if err != nil || len(notYet) != 2 {
+		t.Fatalf("expected only the first user's events due, got %d %v", len(notYet), err)
+	}
+	limited, err := outbox.ListDue(ctx, base.Add(time.Hour), 3)
+	if err != nil || len(limited) != 3 {
+		t.Fatalf("expected the limit to apply, got %d %v", len(limited), err)
+	}
+
+	if blocked, err := outbox.PendingBefore(ctx, activated); err != nil || !blocked {
+		t.Fatalf("status event must wait for the signup: %v %v", blocked, err)
+	}
+	if blocked, err := outbox.PendingBefore(ctx, signup); err != nil || blocked {
+		t.Fatalf("signup has nothing before it: %v %v", blocked, err)
+	}
+	if err := outbox.MarkPublished(ctx, signup.ID, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, err := outbox.PendingBefore(ctx, activated); err != nil || blocked {
+		t.Fatalf("status event is unblocked once the signup is published: %v %v", blocked, err)
+	}
+}
+
+func testOutboxRelay(t *testing.T, outbox repository.OutboxRepository, users repository.UserRepository) {
+	ctx := context.Background()
+	signUpAndActivate(t, users, 1)
+	due, err := outbox.ListDue(ctx, base.Add(time.Hour), 10)
+	if err != nil || len(due) != 2 {
+		t.Fatalf("expected 2 due events, got %d %v", len(due), err)
+	}
+	signup, activated := due[0], due[1]
+
+	retry := base.Add(2 * time.Hour)
+	if err := outbox.MarkFailed(ctx, signup.ID, 1, "broker down", retry); err != nil {
+		t.Fatal(err)
+	}
+	due, err = outbox.ListDue(ctx, base.Add(time.Hour), 10)
+	if err != nil || len(due) != 1 || due[0].ID != activated.ID {
+		t.Fatalf("failed event must wait for its retry: %v %v", due, err)
+	}
+	due, err = outbox.ListDue(ctx, retry, 10)
+	if err != nil || len(due) != 2 || due[0].Attempts != 1 || due[0].LastError != "broker down" {
+		t.Fatalf("failed event due again with its attempt recorded: %v %v", due, err)
+	}
+
+	if err := outbox.DeadLetter(ctx, due[0]); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, err := outbox.PendingBefore(ctx, activated); err != nil || blocked {
+		t.Fatalf("dead-lettering unblocks the aggregate: %v %v", blocked, err)
+	}
+	if err := outbox.DeadLetter(ctx, due[0]); !errors.Is(err, domain.ErrEventNotFound) {
+		t.Fatalf("second dead-letter: expected ErrEventNotFound, got %v", err)
+	}
+	if err := outbox.MarkPublished(ctx, activated.ID, retry); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.MarkPublished(ctx, activated.ID, retry); !errors.Is(err, domain.ErrEventNotFound) {
+		t.Fatalf("second publish: expected ErrEventNotFound, got %v", err)
+	}
+	if err := outbox.MarkFailed(ctx, "000000000000000000000000", 1, "x", retry); !errors.Is(err, domain.ErrEventNotFound) {
+		t.Fatalf("missing event: expected ErrEventNotFound, got %v", err)
+	}
+	if due, err := outbox.ListDue(ctx, retry, 10); err != nil || len(due) != 0 {
+		t.Fatalf("expected an empty outbox, got %v %v", due, err)
+	}
+}
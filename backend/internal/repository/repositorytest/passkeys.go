@@ -0,0 +1,94 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewPasskeyRepository returns an empty repository with its indexes ensured.
+type NewPasskeyRepository func(t *testing.T) repository.PasskeyRepository
+
+// TestPasskeyRepository runs the PasskeyRepository contract.
+func TestPasskeyRepository(t *testing.T, newRepo NewPasskeyRepository) {
+	t.Run("Passkeys", func(t *testing.T) { testPasskeys(t, newRepo(t)) })
+	t.Run("Challenges", func(t *testing.T) { testPasskeyChallenges(t, newRepo(t)) })
+}
+
+func newPasskey(userID, credentialID string, n int) *domain.Passkey {
+	return &domain.Passkey{UserID: userID, CredentialID: credentialID, PublicKey: []byte("cose"), Algorithm: -7, SignCount: 1,
+		AAGUID: []byte("aaguid"), Attestation: "none", BackupEligible: true, Name: credentialID, CreatedAt: base.Add(time.Duration(n) * time.Minute)}
+}
+
+func testPasskeys(t *testing.T, repo repository.PasskeyRepository) {
+	ctx := context.Background()
+	first := newPasskey("alice", "cred-1", 1)
+	if err := repo.Create(ctx, first); err != nil || first.ID == "" {
+		t.Fatalf("create: %v, id %q", err, first.ID)
+	}
+	if err := repo.Create(ctx, newPasskey("bob", "cred-1", 2)); !errors.Is(err, domain.ErrPasskeyExists) {
+		t.Fatalf("reused credential: expected ErrPasskeyExists, got %v", err)
+	}
+	second := newPasskey("alice", "cred-2", 2)
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetByCredentialID(ctx, "cred-1")
+	if err != nil || got.ID != first.ID || string(got.PublicKey) != "cose" || got.Algorithm != -7 || string(got.AAGUID) != "aaguid" || !got.BackupEligible {
+		t.Fatalf("stored passkey differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetByCredentialID(ctx, "cred-9"); !errors.Is(err, domain.ErrPasskeyNotFound) {
+		t.Fatalf("expected ErrPasskeyNotFound, got %v", err)
+	}
+	owned, err := repo.ListByUser(ctx, "alice")
+	if err != nil || len(owned) != 2 || owned[0].ID != first.ID {
+		t.Fatalf("passkeys, oldest first: %v %v", owned, err)
+	}
+
+	if err := repo.UpdateSignCount(ctx, first.ID, 1, 2, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// A concurrent login that read the old count loses.
+	if err := repo.UpdateSignCount(ctx, first.ID, 1, 2, base.Add(time.Hour)); !errors.Is(err, domain.ErrPasskeyInvalid) {
+		t.Fatalf("stale sign count: expected ErrPasskeyInvalid, got %v", err)
+	}
+	if got, err := repo.GetByCredentialID(ctx, "cred-1"); err != nil || got.SignCount != 2 || !got.LastUsedAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("passkey after sign-in: %+v %v", got, err)
+	}
+	if err := repo.UpdateSignCount(ctx, "not-an-id", 1, 2, base); !errors.Is(err, domain.ErrPasskeyNotFound) {
+		t.Fatalf("expected ErrPasskeyNotFound, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, "bob", first.ID); !errors.Is(err, domain.ErrPasskeyNotFound) {
+		t.Fatalf("deleting another user's passkey: expected ErrPasskeyNotFound, got %v", err)
+	}
+	if err := repo.Delete(ctx, "alice", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByCredentialID(ctx, "cred-1"); !errors.Is(err, domain.ErrPasskeyNotFound) {
+		t.Fatalf("deleted passkey: expected ErrPasskeyNotFound, got %v", err)
+	}
+}
+
+func testPasskeyChallenges(t *testing.T, repo repository.PasskeyRepository) {
+	ctx := context.Background()
+	challenge := &domain.WebAuthnChallenge{UserID: "alice", Purpose: domain.WebAuthnLogin, Challenge: []byte("nonce"), ExpiresAt: base.Add(5 * time.Minute), CreatedAt: base}
+	if err := repo.CreateChallenge(ctx, challenge); err != nil || challenge.ID == "" {
+		t.Fatalf("create: %v, id %q", err, challenge.ID)
+	}
+	if _, err := repo.ConsumeChallenge(ctx, challenge.ID, domain.WebAuthnRegistration); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("wrong purpose: expected ErrChallengeNotFound, got %v", err)
+	}
+	got, err := repo.ConsumeChallenge(ctx, challenge.ID, domain.WebAuthnLogin)
+	if err != nil || got.UserID != "alice" || string(got.Challenge) != "nonce" || !got.ExpiresAt.Equal(challenge.ExpiresAt) {
+		t.Fatalf("consumed challenge: %+v %v", got, err)
+	}
+	if _, err := repo.ConsumeChallenge(ctx, challenge.ID, domain.WebAuthnLogin); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("second use: expected ErrChallengeNotFound, got %v", err)
+	}
+}
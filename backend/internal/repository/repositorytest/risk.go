@@ -0,0 +1,75 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewRiskRepository returns an empty repository with its indexes ensured.
+type NewRiskRepository func(t *testing.T) repository.RiskRepository
+
+// TestRiskRepository runs the RiskRepository contract.
+func TestRiskRepository(t *testing.T, newRepo NewRiskRepository) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	var created []*domain.RiskDecision
+	for i, c := range []struct {
+		user, device, decision string
+		steppedUp              bool
+	}{
+		{"alice", "phone", "allow", false},
+		{"alice", "laptop", "challenge", true},
+		{"alice", "tablet", "challenge", false},
+		{"bob", "phone", "block", false},
+	} {
+		d := &domain.RiskDecision{UserID: c.user, Action: "transfer", Amount: 100, Currency: "KES", DeviceID: c.device, Score: 10 * i,
+			Decision: c.decision, SteppedUp: c.steppedUp, Factors: []domain.RiskFactor{{Signal: "new_device", Weight: 10}}, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := repo.Create(ctx, d); err != nil || d.ID == "" {
+			t.Fatalf("create: %v, id %q", err, d.ID)
+		}
+		created = append(created, d)
+	}
+
+	got, err := repo.GetByID(ctx, created[1].ID)
+	if err != nil || got.Decision != "challenge" || !got.SteppedUp || got.Score != 10 || len(got.Factors) != 1 || got.Factors[0].Signal != "new_device" {
+		t.Fatalf("stored decision differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetByID(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrRiskDecisionNotFound) {
+		t.Fatalf("expected ErrRiskDecisionNotFound, got %v", err)
+	}
+
+	for name, c := range map[string]struct {
+		user, decision string
+		limit          int
+		want           []int
+	}{
+		"all":      {"", "", 10, []int{3, 2, 1, 0}},
+		"user":     {"alice", "", 10, []int{2, 1, 0}},
+		"decision": {"alice", "challenge", 10, []int{2, 1}},
+		"limit":    {"", "", 1, []int{3}},
+	} {
+		listed, err := repo.List(ctx, c.user, c.decision, c.limit)
+		if err != nil || len(listed) != len(c.want) {
+			t.Fatalf("%s: expected %d decisions, got %d %v", name, len(c.want), len(listed), err)
+		}
+		for i, n := range c.want {
+			if listed[i].ID != created[n].ID {
+				t.Fatalf("%s: decisions not newest first", name)
+			}
+		}
+	}
+
+	for device, want := range map[string]bool{"phone": true, "laptop": true, "tablet": false, "watch": false} {
+		if got, err := repo.HasAllowedDevice(ctx, "alice", device); err != nil || got != want {
+			t.Fatalf("%s: allowed %v, want %v (%v)", device, got, want, err)
+		}
+	}
+	if got, err := repo.HasAllowedDevice(ctx, "bob", "phone"); err != nil || got {
+		t.Fatalf("a blocked device is not allowed: %v %v", got, err)
+	}
+}
@@ -0,0 +1,282 @@
+// Package repositorytest holds the contract every repository backend must
+// meet. Backends run it from their own tests against a fresh, empty store,
+// so Mongo and Postgres are held to the same behaviour the use cases rely
+// on.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewUserRepository returns an empty repository with its indexes ensured.
+type NewUserRepository func(t *testing.T) repository.UserRepository
+
+// base is truncated to milliseconds, the coarsest precision a backend
+// stores timestamps with.
+var base = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newUser(n int) *domain.User {
+	at := base.Add(time.Duration(n) * time.Minute)
+	return &domain.User{
+		EmailLower:    fmt.Sprintf("user%d@example.com", n),
+		PhoneE164:     fmt.Sprintf("+25470000%04d", n),
+		UsernameLower: fmt.Sprintf("user_%d", n),
+		PasswordHash:  "hash",
+		Status:        domain.UserStatusPendingVerification,
+		Role:          domain.UserRoleCustomer,
+		KYCStatus:     domain.KYCStatusPending,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+	}
+}
+
+func mustCreate(t *testing.T, repo repository.UserRepository, u *domain.User) *domain.User {
+	t.Helper()
+	if err := repo.Create(context.Background(), u); err != nil {
+		t.Fatalf("create %s: %v", u.UsernameLower, err)
+	}
+	if u.ID == "" {
+		t.Fatal("create did not set the ID")
+	}
+	return u
+}
+
+// TestUserRepository runs the UserRepository contract.
+func TestUserRepository(t *testing.T, newRepo NewUserRepository) {
+	t.Run("CreateAndLookup", func(t *testing.T) { testUserCreateAndLookup(t, newRepo(t)) })
+	t.Run("Uniqueness", func(t *testing.T) { testUserUniqueness(t, newRepo(t)) })
+	t.Run("Updates", func(t *testing.T) { testUserUpdates(t, newRepo(t)) })
+	t.Run("Search", func(t *testing.T) { testUserSearch(t, newRepo(t)) })
+	t.Run("StatusTransitions", func(t *testing.T) { testUserStatusTransitions(t, newRepo(t)) })
+}
+
+func testUserCreateAndLookup(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	u := mustCreate(t, repo, newUser(1))
+	got, err := repo.GetByID(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EmailLower != u.EmailLower || got.PhoneE164 != u.PhoneE164 || got.UsernameLower != u.UsernameLower || got.PasswordHash != "hash" ||
+		got.Status != u.Status || got.Role != u.Role || got.KYCStatus != u.KYCStatus || !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Fatalf("stored user differs: %+v", got)
+	}
+	for _, login := range []string{"USER1@example.com", "+254700000001", "User_1"} {
+		got, err := repo.GetByLogin(ctx, login)
+		if err != nil || got.ID != u.ID {
+			t.Fatalf("login %q: %v %v", login, got, err)
+		}
+	}
+	for _, login := range []string{"nobody@example.com", "+254799999999", "nobody"} {
+		if _, err := repo.GetByLogin(ctx, login); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("login %q: expected ErrUserNotFound, got %v", login, err)
+		}
+	}
+	for _, id := range []string{"000000000000000000000000", "not-an-id"} {
+		if _, err := repo.GetByID(ctx, id); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("id %q: expected ErrUserNotFound, got %v", id, err)
+		}
+	}
+}
+
+func testUserUniqueness(t *testing.T, repo repository.UserRepository) {
+	mustCreate(t, repo, newUser(1))
+	for name, mutate := range map[string]func(u *domain.User){
+		"email":    func(u *domain.User) { u.EmailLower = "user1@example.com" },
+		"phone":    func(u *domain.User) { u.PhoneE164 = "+254700000001" },
+		"username": func(u *domain.User) { u.UsernameLower = "user_1" },
+	} {
+		u := newUser(2)
+		mutate(u)
+		if err := repo.Create(context.Background(), u); !errors.Is(err, domain.ErrUserExists) {
+			t.Fatalf("duplicate %s: expected ErrUserExists, got %v", name, err)
+		}
+	}
+	mustCreate(t, repo, newUser(2))
+}
+
+func testUserUpdates(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	u := mustCreate(t, repo, newUser(1))
+	at := base.Add(time.Hour)
+	check := func(what string, err error, ok func(*domain.User) bool) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+		got, err := repo.GetByID(ctx, u.ID)
+		if err != nil || !ok(got) {
+			t.Fatalf("%s not stored: %+v %v", what, got, err)
+		}
+	}
+	check("password", repo.UpdatePassword(ctx, u.ID, "hash2", at), func(g *domain.User) bool { return g.PasswordHash == "hash2" && g.UpdatedAt.Equal(at) })
+	for want := 1; want <= 2; want++ {
+		n, err := repo.ClaimPINAttempt(ctx, u.ID, 2, at)
+		if err != nil || n != want {
+			t.Fatalf("pin attempt %d: got %d %v", want, n, err)
+		}
+	}
+	if _, err := repo.ClaimPINAttempt(ctx, u.ID, 2, at); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("pin attempt past max: expected ErrPINLocked, got %v", err)
+	}
+	check("reset failures", repo.ResetPINFailures(ctx, u.ID), func(g *domain.User) bool { return g.PINFailures == 0 })
+	if _, err := repo.ClaimPINAttempt(ctx, u.ID, 2, at); err != nil {
+		t.Fatalf("pin attempt after reset: %v", err)
+	}
+	_, _ = repo.ClaimPINAttempt(ctx, u.ID, 2, at)
+	check("lock", repo.LockPIN(ctx, u.ID, at), func(g *domain.User) bool { return g.PINLockedUntil.Equal(at) })
+	if _, err := repo.ClaimPINAttempt(ctx, u.ID, 2, at.Add(-time.Second)); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("pin attempt while locked: expected ErrPINLocked, got %v", err)
+	}
+	if n, err := repo.ClaimPINAttempt(ctx, u.ID, 2, at); err != nil || n != 2 {
+		t.Fatalf("pin attempt after lockout: got %d %v", n, err)
+	}
+	if _, err := repo.ClaimPINAttempt(ctx, u.ID, 2, at); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("second attempt after lockout: expected ErrPINLocked, got %v", err)
+	}
+	check("pin", repo.SetPIN(ctx, u.ID, "pin", at), func(g *domain.User) bool {
+		return g.PINHash == "pin" && g.PINFailures == 0 && g.PINLockedUntil.IsZero()
+	})
+	check("role", repo.SetRole(ctx, u.ID, domain.UserRoleSupport, at), func(g *domain.User) bool { return g.Role == domain.UserRoleSupport })
+	check("kyc", repo.SetKYC(ctx, u.ID, domain.KYCStatusRejected, "blurry", "a1", at), func(g *domain.User) bool {
+		return g.KYCStatus == domain.KYCStatusRejected && g.KYCNote == "blurry" && g.KYCReviewedBy == "a1" && g.KYCReviewedAt.Equal(at)
+	})
+	check("phone verified", repo.MarkPhoneVerified(ctx, u.ID, at), func(g *domain.User) bool { return g.PhoneVerified() && g.PhoneVerifiedAt.Equal(at) })
+
+	if err := repo.UseTOTPStep(ctx, u.ID, 1, at); !errors.Is(err, domain.ErrInvalidOTP) {
+		t.Fatalf("totp step without a secret: expected ErrInvalidOTP, got %v", err)
+	}
+	check("totp secret", repo.SetTOTP(ctx, u.ID, "JBSWY3DPEHPK3PXP", at), func(g *domain.User) bool {
+		return g.TOTPSecret == "JBSWY3DPEHPK3PXP" && !g.HasTOTP()
+	})
+	if n, err := repo.ClaimTOTPAttempt(ctx, u.ID, 1, at); err != nil || n != 1 {
+		t.Fatalf("totp attempt: got %d %v", n, err)
+	}
+	if _, err := repo.ClaimTOTPAttempt(ctx, u.ID, 1, at); !errors.Is(err, domain.ErrTOTPLocked) {
+		t.Fatalf("totp attempt past max: expected ErrTOTPLocked, got %v", err)
+	}
+	later := at.Add(time.Minute)
+	check("totp step", repo.UseTOTPStep(ctx, u.ID, 100, later), func(g *domain.User) bool {
+		return g.HasTOTP() && g.TOTPConfirmedAt.Equal(later) && g.TOTPLastStep == 100 && g.TOTPFailures == 0
+	})
+	for _, step := range []int64{100, 99} {
+		if err := repo.UseTOTPStep(ctx, u.ID, step, later); !errors.Is(err, domain.ErrInvalidOTP) {
+			t.Fatalf("totp step %d replayed: expected ErrInvalidOTP, got %v", step, err)
+		}
+	}
+	check("later totp step", repo.UseTOTPStep(ctx, u.ID, 101, later.Add(time.Minute)), func(g *domain.User) bool {
+		return g.TOTPLastStep == 101 && g.TOTPConfirmedAt.Equal(later)
+	})
+	check("totp lock", repo.LockTOTP(ctx, u.ID, later), func(g *domain.User) bool { return g.TOTPLockedUntil.Equal(later) })
+	if _, err := repo.ClaimTOTPAttempt(ctx, u.ID, 1, at); !errors.Is(err, domain.ErrTOTPLocked) {
+		t.Fatalf("totp attempt while locked: expected ErrTOTPLocked, got %v", err)
+	}
+	check("totp removed", repo.SetTOTP(ctx, u.ID, "", later), func(g *domain.User) bool {
+		return g.TOTPSecret == "" && g.TOTPConfirmedAt.IsZero() && g.TOTPLastStep == 0 && g.TOTPLockedUntil.IsZero()
+	})
+
+	missing := "000000000000000000000000"
+	if _, err := repo.ClaimPINAttempt(ctx, missing, 5, at); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	for name, err := range map[string]error{
+		"password":  repo.UpdatePassword(ctx, missing, "h", at),
+		"pin":       repo.SetPIN(ctx, missing, "p", at),
+		"role":      repo.SetRole(ctx, missing, domain.UserRoleAdmin, at),
+		"kyc":       repo.SetKYC(ctx, missing, domain.KYCStatusApproved, "", "a1", at),
+		"phone":     repo.MarkPhoneVerified(ctx, missing, at),
+		"totp":      repo.SetTOTP(ctx, missing, "JBSWY3DPEHPK3PXP", at),
+		"totp step": repo.UseTOTPStep(ctx, missing, 1, at),
+	} {
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("%s on a missing user: expected ErrUserNotFound, got %v", name, err)
+		}
+	}
+}
+
+func testUserSearch(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	var users []*domain.User
+	for i := 1; i <= 3; i++ {
+		users = append(users, mustCreate(t, repo, newUser(i)))
+	}
+	if err := repo.SetRole(ctx, users[0].ID, domain.UserRoleAdmin, base); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetKYC(ctx, users[1].ID, domain.KYCStatusApproved, "", "a1", base); err != nil {
+		t.Fatal(err)
+	}
+	ids := func(f domain.UserFilter) []string {
+		t.Helper()
+		if f.Limit == 0 {
+			f.Limit = 10
+		}
+		got, err := repo.Search(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]string, 0, len(got))
+		for _, u := range got {
+			out = append(out, u.ID)
+		}
+		return out
+	}
+	for name, c := range map[string]struct {
+		f    domain.UserFilter
+		want []string
+	}{
+		"all, newest first": {domain.UserFilter{}, []string{users[2].ID, users[1].ID, users[0].ID}},
+		"limit":             {domain.UserFilter{Limit: 2}, []string{users[2].ID, users[1].ID}},
+		"id":                {domain.UserFilter{Query: users[1].ID}, []string{users[1].ID}},
+		"whole email":       {domain.UserFilter{Query: "USER2@example.com"}, []string{users[1].ID}},
+		"whole phone":       {domain.UserFilter{Query: "+254700000003"}, []string{users[2].ID}},
+		"username prefix":   {domain.UserFilter{Query: "User_"}, []string{users[2].ID, users[1].ID, users[0].ID}},
+		"role":              {domain.UserFilter{Role: domain.UserRoleAdmin}, []string{users[0].ID}},
+		"kyc":               {domain.UserFilter{KYCStatus: domain.KYCStatusPending}, []string{users[2].ID, users[0].ID}},
+		"status":            {domain.UserFilter{Status: domain.UserStatusActive}, []string{}},
+	} {
+		if got := ids(c.f); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Fatalf("%s: got %v, want %v", name, got, c.want)
+		}
+	}
+}
+
+func testUserStatusTransitions(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	u := mustCreate(t, repo, newUser(1))
+	activate := &domain.UserStatusEvent{UserID: u.ID, From: domain.UserStatusPendingVerification, To: domain.UserStatusActive, Reason: "kyc approved", ActorID: "a1", CreatedAt: base.Add(time.Minute)}
+	if err := repo.TransitionStatus(ctx, activate); err != nil || activate.ID == "" {
+		t.Fatalf("transition: %v, id %q", err, activate.ID)
+	}
+	stale := &domain.UserStatusEvent{UserID: u.ID, From: domain.UserStatusPendingVerification, To: domain.UserStatusFrozen, Reason: "stale", ActorID: "a1", CreatedAt: base.Add(2 * time.Minute)}
+	if err := repo.TransitionStatus(ctx, stale); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	missing := &domain.UserStatusEvent{UserID: "000000000000000000000000", From: domain.UserStatusActive, To: domain.UserStatusFrozen, Reason: "x", ActorID: "a1", CreatedAt: base}
+	if err := repo.TransitionStatus(ctx, missing); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	freeze := &domain.UserStatusEvent{UserID: u.ID, From: domain.UserStatusActive, To: domain.UserStatusFrozen, Reason: "fraud report", ActorID: "a2", EntryIDs: []string{"e1"}, CreatedAt: base.Add(3 * time.Minute)}
+	if err := repo.TransitionStatus(ctx, freeze); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetByID(ctx, u.ID)
+	if err != nil || got.Status != domain.UserStatusFrozen {
+		t.Fatalf("expected frozen, got %+v %v", got, err)
+	}
+	history, err := repo.ListStatusEvents(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ID != activate.ID || history[1].To != domain.UserStatusFrozen || history[1].Reason != "fraud report" ||
+		history[1].ActorID != "a2" || len(history[1].EntryIDs) != 1 || !history[1].CreatedAt.Equal(freeze.CreatedAt) {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
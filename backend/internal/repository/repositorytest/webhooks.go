@@ -0,0 +1,139 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// NewWebhookRepository returns an empty repository with its indexes ensured.
+type NewWebhookRepository func(t *testing.T) repository.WebhookRepository
+
+// TestWebhookRepository runs the WebhookRepository contract.
+func TestWebhookRepository(t *testing.T, newRepo NewWebhookRepository) {
+	t.Run("Endpoints", func(t *testing.T) { testWebhookEndpoints(t, newRepo(t)) })
+	t.Run("Deliveries", func(t *testing.T) { testWebhookDeliveries(t, newRepo(t)) })
+}
+
+func newEndpoint(clientID string, n int, types ...domain.EventType) *domain.WebhookEndpoint {
+	at := base.Add(time.Duration(n) * time.Minute)
+	return &domain.WebhookEndpoint{ClientID: clientID, URL: fmt.Sprintf("https://hooks.example.com/%d", n), EventTypes: types,
+		Secrets: []domain.WebhookSecret{{Secret: "s1", CreatedAt: at}}, Status: domain.WebhookEndpointActive, CreatedBy: "admin", CreatedAt: at, UpdatedAt: at}
+}
+
+func endpointIDs(endpoints []*domain.WebhookEndpoint) string {
+	var ids []string
+	for _, e := range endpoints {
+		ids = append(ids, e.ID)
+	}
+	return fmt.Sprint(ids)
+}
+
+func testWebhookEndpoints(t *testing.T, repo repository.WebhookRepository) {
+	ctx := context.Background()
+	signups := newEndpoint("c1", 1, domain.EventUserSignedUp)
+	everything := newEndpoint("c1", 2, domain.WebhookEventAll)
+	statuses := newEndpoint("c2", 3, domain.EventUserStatusChanged)
+	for _, e := range []*domain.WebhookEndpoint{signups, everything, statuses} {
+		if err := repo.CreateEndpoint(ctx, e); err != nil || e.ID == "" {
+			t.Fatalf("create: %v, id %q", err, e.ID)
+		}
+	}
+
+	got, err := repo.GetEndpoint(ctx, signups.ID)
+	if err != nil || got.URL != signups.URL || !got.Subscribes(domain.EventUserSignedUp) || len(got.Secrets) != 1 || got.Secrets[0].Secret != "s1" {
+		t.Fatalf("stored endpoint differs: %+v %v", got, err)
+	}
+	if _, err := repo.GetEndpoint(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Fatalf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if listed, err := repo.ListEndpoints(ctx, "c1"); err != nil || endpointIDs(listed) != endpointIDs([]*domain.WebhookEndpoint{everything, signups}) {
+		t.Fatalf("client endpoints, newest first: %v %v", listed, err)
+	}
+	if listed, err := repo.ListEndpoints(ctx, ""); err != nil || len(listed) != 3 {
+		t.Fatalf("expected every endpoint, got %d %v", len(listed), err)
+	}
+	if subscribed, err := repo.ListSubscribed(ctx, domain.EventUserSignedUp); err != nil || len(subscribed) != 2 {
+		t.Fatalf("signup subscribers, including the wildcard: %v %v", subscribed, err)
+	}
+
+	if err := repo.SetEndpointStatus(ctx, everything.ID, domain.WebhookEndpointDisabled, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if subscribed, err := repo.ListSubscribed(ctx, domain.EventUserStatusChanged); err != nil || endpointIDs(subscribed) != endpointIDs([]*domain.WebhookEndpoint{statuses}) {
+		t.Fatalf("disabled endpoints are not subscribed: %v %v", subscribed, err)
+	}
+
+	rotated := []domain.WebhookSecret{{Secret: "s1", CreatedAt: base, ExpiresAt: base.Add(25 * time.Hour)}, {Secret: "s2", CreatedAt: base.Add(time.Hour)}}
+	if err := repo.SetSecrets(ctx, signups.ID, rotated, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetEndpoint(ctx, signups.ID)
+	if err != nil || fmt.Sprint(got.SigningSecrets(base.Add(2*time.Hour))) != "[s2 s1]" || !got.UpdatedAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("endpoint after rotation: %+v %v", got, err)
+	}
+	if err := repo.SetSecrets(ctx, "000000000000000000000000", rotated, base); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Fatalf("expected ErrWebhookNotFound, got %v", err)
+	}
+}
+
+func testWebhookDeliveries(t *testing.T, repo repository.WebhookRepository) {
+	ctx := context.Background()
+	newDelivery := func(eventID string, n int) *domain.WebhookDelivery {
+		at := base.Add(time.Duration(n) * time.Minute)
+		return &domain.WebhookDelivery{EndpointID: "ep1", ClientID: "c1", EventID: eventID, EventType: domain.EventUserSignedUp, Payload: []byte(`{"id":"` + eventID + `"}`),
+			Status: domain.WebhookDeliveryPending, NextAttemptAt: at, CreatedAt: at, UpdatedAt: at}
+	}
+	first, second := newDelivery("ev1", 2), newDelivery("ev2", 1)
+	for _, d := range []*domain.WebhookDelivery{first, second} {
+		if err := repo.CreateDelivery(ctx, d); err != nil || d.ID == "" {
+			t.Fatalf("create: %v, id %q", err, d.ID)
+		}
+	}
+	if err := repo.CreateDelivery(ctx, newDelivery("ev1", 3)); !errors.Is(err, domain.ErrDuplicateEntry) {
+		t.Fatalf("second delivery of an event: expected ErrDuplicateEntry, got %v", err)
+	}
+
+	due, err := repo.ListDueDeliveries(ctx, base.Add(time.Hour), 10)
+	if err != nil || len(due) != 2 || due[0].ID != second.ID {
+		t.Fatalf("due deliveries, earliest attempt first: %v %v", due, err)
+	}
+	if due, err := repo.ListDueDeliveries(ctx, base.Add(time.Minute), 10); err != nil || len(due) != 1 {
+		t.Fatalf("only the delivery already due: %v %v", due, err)
+	}
+	if listed, err := repo.ListDeliveries(ctx, "ep1", 1); err != nil || len(listed) != 1 || listed[0].ID != second.ID {
+		t.Fatalf("latest delivery of the endpoint: %v %v", listed, err)
+	}
+
+	failed := domain.WebhookAttempt{At: base.Add(time.Hour), StatusCode: 500, Error: "server error", DurationMs: 30}
+	if err := repo.RecordAttempt(ctx, first.ID, failed, domain.WebhookDeliveryFailed, 1, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetDelivery(ctx, first.ID)
+	if err != nil || got.Status != domain.WebhookDeliveryFailed || got.Attempts != 1 || len(got.History) != 1 || got.History[0].StatusCode != 500 ||
+		string(got.Payload) != `{"id":"ev1"}` {
+		t.Fatalf("delivery after a failed attempt: %+v %v", got, err)
+	}
+	if due, err := repo.ListDueDeliveries(ctx, base.Add(time.Hour), 10); err != nil || len(due) != 1 {
+		t.Fatalf("a failed delivery is not due: %v %v", due, err)
+	}
+
+	if err := repo.Requeue(ctx, first.ID, base.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetDelivery(ctx, first.ID)
+	if err != nil || got.Status != domain.WebhookDeliveryPending || got.Attempts != 0 || len(got.History) != 1 || !got.NextAttemptAt.Equal(base.Add(2*time.Hour)) {
+		t.Fatalf("requeued delivery keeps its history: %+v %v", got, err)
+	}
+	if err := repo.Requeue(ctx, "000000000000000000000000", base); !errors.Is(err, domain.ErrDeliveryNotFound) {
+		t.Fatalf("expected ErrDeliveryNotFound, got %v", err)
+	}
+	if _, err := repo.GetDelivery(ctx, "000000000000000000000000"); !errors.Is(err, domain.ErrDeliveryNotFound) {
+		t.Fatalf("expected ErrDeliveryNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+)
+
+type RiskRepository interface {
+	Create(ctx context.Context, decision *domain.RiskDecision) error
+	GetByID(ctx context.Context, id string) (*domain.RiskDecision, error)
+	// List returns the newest decisions first, filtered by user and
+	// decision when set.
+	List(ctx context.Context, userID, decision string, limit int) ([]*domain.RiskDecision, error)
+	// HasAllowedDevice reports whether userID was ever allowed to pay from
+	// deviceID, directly or after stepping up.
+	HasAllowedDevice(ctx context.Context, userID, deviceID string) (bool, error)
+	EnsureIndexes(ctx context.Context) error
+}
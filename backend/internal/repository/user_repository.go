@@ -3,11 +3,58 @@ package repository
 import (
 	"akiba/backend/internal/domain"
 	"context"
+	"time"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByID(ctx context.Context, id string) (*domain.User, error)
 	GetByLogin(ctx context.Context, login string) (*domain.User, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string, at time.Time) error
+	// SetPIN stores a new PIN hash and clears failures and any lockout.
+	SetPIN(ctx context.Context, id, pinHash string, at time.Time) error
+	// ClaimPINAttempt atomically counts a PIN attempt before it is checked
+	// and returns the number of consecutive attempts not yet cleared. It
+	// returns domain.ErrPINLocked without counting while PIN entry is
+	// locked at now or max attempts are already uncleared. Once a lockout
+	// has passed it allows exactly one more attempt.
+	ClaimPINAttempt(ctx context.Context, id string, max int, now time.Time) (int, error)
+	// ResetPINFailures clears the failure count after a correct PIN.
+	ResetPINFailures(ctx context.Context, id string) error
+	LockPIN(ctx context.Context, id string, until time.Time) error
+	// SetTOTP stores a new, unconfirmed TOTP secret and clears its last
+	// step, failures and lockout. An empty secret removes TOTP.
+	SetTOTP(ctx context.Context, id, secret string, at time.Time) error
+	// UseTOTPStep atomically records that a code for step was accepted,
+	// confirming the secret at at if it is not yet and clearing failures.
+	// It returns domain.ErrInvalidOTP if the user has no secret or a code
+	// for step or a later one was already accepted.
+	UseTOTPStep(ctx context.Context, id string, step int64, at time.Time) error
+	// ClaimTOTPAttempt counts a code attempt like ClaimPINAttempt, returning
+	// domain.ErrTOTPLocked when none is allowed.
+	ClaimTOTPAttempt(ctx context.Context, id string, max int, now time.Time) (int, error)
+	LockTOTP(ctx context.Context, id string, until time.Time) error
+	// Search returns up to filter.Limit users matching filter, newest
+	// first.
+	Search(ctx context.Context, filter domain.UserFilter) ([]*domain.User, error)
+	// TransitionStatus moves the user from event.From to event.To and
+	// records event, or returns domain.ErrInvalidTransition if the stored
+	// status is no longer event.From.
+	TransitionStatus(ctx context.Context, event *domain.UserStatusEvent) error
+	// ListStatusEvents returns the user's lifecycle history, oldest first.
+	ListStatusEvents(ctx context.Context, userID string) ([]*domain.UserStatusEvent, error)
+	SetRole(ctx context.Context, id string, role domain.UserRole, at time.Time) error
+	// SetKYC records a KYC decision with the reviewing operator.
+	SetKYC(ctx context.Context, id string, status domain.KYCStatus, note, reviewerID string, at time.Time) error
+	// MarkPhoneVerified records that the user confirmed a code sent to
+	// their phone at at.
+	MarkPhoneVerified(ctx context.Context, id string, at time.Time) error
 	EnsureIndexes(ctx context.Context) error
 }
+
+// PIIReencrypter re-seals stored personal data under the current key.
+type PIIReencrypter interface {
+	// ReencryptPII re-seals up to limit users not yet under the current
+	// key and returns how many it updated.
+	ReencryptPII(ctx context.Context, limit int) (int, error)
+}
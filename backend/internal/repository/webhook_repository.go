@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"akiba/backend/internal/domain"
+	"context"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id string) (*domain.WebhookEndpoint, error)
+	// ListEndpoints returns the client's endpoints, or every endpoint when
+	// clientID is empty, newest first.
+	ListEndpoints(ctx context.Context, clientID string) ([]*domain.WebhookEndpoint, error)
+	// ListSubscribed returns the active endpoints subscribed to eventType.
+	ListSubscribed(ctx context.Context, eventType domain.EventType) ([]*domain.WebhookEndpoint, error)
+	SetSecrets(ctx context.Context, id string, secrets []domain.WebhookSecret, at time.Time) error
+	SetEndpointStatus(ctx context.Context, id string, status domain.WebhookEndpointStatus, at time.Time) error
+	// CreateDelivery stores a delivery unless the endpoint already has one
+	// for the event, in which case it returns domain.ErrDuplicateEntry.
+	CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error)
+	// ListDueDeliveries returns up to limit pending deliveries whose next
+	// attempt is at or before now, oldest first.
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error)
+	// ListDeliveries returns the endpoint's latest deliveries, newest first.
+	ListDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error)
+	// RecordAttempt appends attempt to the delivery's history and sets its
+	// status, attempt count and next attempt.
+	RecordAttempt(ctx context.Context, id string, attempt domain.WebhookAttempt, status domain.WebhookDeliveryStatus, attempts int, nextAttemptAt time.Time) error
+	// Requeue makes a delivery pending again from now with a fresh attempt
+	// count, keeping its history.
+	Requeue(ctx context.Context, id string, at time.Time) error
+	EnsureIndexes(ctx context.Context) error
+}
@@ -0,0 +1,263 @@
+package risk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+	// Embedded so Timezone resolves on hosts without a zoneinfo database.
+	_ "time/tzdata"
+)
+
+// Signal names one input to the score.
+type Signal string
+
+const (
+	// SignalDeviceNovelty is set when the payment comes from a device the
+	// user has not paid from before, or from no identified device at all.
+	SignalDeviceNovelty Signal = "device_novelty"
+	// SignalNewBeneficiary is set for a recipient the user has not paid
+	// within the history window.
+	SignalNewBeneficiary Signal = "new_beneficiary"
+	// SignalAmountDeviation grows with the amount's multiple of the user's
+	// median payment.
+	SignalAmountDeviation Signal = "amount_deviation"
+	// SignalVelocity grows with the number of recent payments.
+	SignalVelocity Signal = "velocity"
+	// SignalTimeOfDay is set during the configured night hours.
+	SignalTimeOfDay Signal = "time_of_day"
+)
+
+type Decision string
+
+const (
+	DecisionAllow  Decision = "allow"
+	DecisionStepUp Decision = "step_up"
+	DecisionBlock  Decision = "block"
+)
+
+var ErrInvalidConfig = errors.New("invalid risk config")
+
+// Config weighs each signal and sets the score cut-offs. A signal's value is
+// between 0 and 1 and contributes value*weight points; scores at or above
+// StepUpAt require step-up and at or above BlockAt are blocked. Night hours
+// run from NightStartHour up to NightEndHour in Timezone and may wrap past
+// midnight.
+type Config struct {
+	Weights               map[Signal]int `json:"weights"`
+	StepUpAt              int            `json:"stepUpAt"`
+	BlockAt               int            `json:"blockAt"`
+	HistoryDays           int            `json:"historyDays"`
+	MinHistory            int            `json:"minHistory"`
+	DeviationCap          float64        `json:"deviationCap"`
+	VelocityWindowMinutes int            `json:"velocityWindowMinutes"`
+	VelocityLimit         int            `json:"velocityLimit"`
+	NightStartHour        int            `json:"nightStartHour"`
+	NightEndHour          int            `json:"nightEndHour"`
+	Timezone              string         `json:"timezone"`
+
+	location *time.Location
+}
+
+// Inputs are the raw observations about one payment.
+type Inputs struct {
+	Amount int64
+	// History holds the user's earlier payment amounts within the history
+	// window.
+	History        []int64
+	KnownDevice    bool
+	NewBeneficiary bool
+	RecentPayments int
+	At             time.Time
+}
+
+// Factor is one signal's share of a score.
+type Factor struct {
+	Signal       Signal  `json:"signal"`
+	Value        float64 `json:"value"`
+	Weight       int     `json:"weight"`
+	Contribution float64 `json:"contribution"`
+	Detail       string  `json:"detail"`
+}
+
+type Assessment struct {
+	Score    int
+	Decision Decision
+	Factors  []Factor
+}
+
+func (c *Config) HistoryWindow() time.Duration {
+	return time.Duration(c.HistoryDays) * 24 * time.Hour
+}
+
+func (c *Config) VelocityWindow() time.Duration {
+	return time.Duration(c.VelocityWindowMinutes) * time.Minute
+}
+
+// Assess scores in. Factors are listed for every weighted signal, largest
+// contribution first, so reviewers can see what did and did not count.
+func (c *Config) Assess(in Inputs) Assessment {
+	values := map[Signal]Factor{
+		SignalDeviceNovelty:   c.deviceNovelty(in),
+		SignalNewBeneficiary:  c.newBeneficiary(in),
+		SignalAmountDeviation: c.amountDeviation(in),
+		SignalVelocity:        c.velocity(in),
+		SignalTimeOfDay:       c.timeOfDay(in),
+	}
+	var out Assessment
+	var total float64
+	for signal, weight := range c.Weights {
+		f, ok := values[signal]
+		if !ok || weight == 0 {
+			continue
+		}
+		f.Signal, f.Weight, f.Contribution = signal, weight, f.Value*float64(weight)
+		total += f.Contribution
+		out.Factors = append(out.Factors, f)
+	}
+	sort.Slice(out.Factors, func(i, j int) bool {
+		if out.Factors[i].Contribution != out.Factors[j].Contribution {
+			return out.Factors[i].Contribution > out.Factors[j].Contribution
+		}
+		return out.Factors[i].Signal < out.Factors[j].Signal
+	})
+	out.Score = int(total + 0.5)
+	switch {
+	case out.Score >= c.BlockAt:
+		out.Decision = DecisionBlock
+	case out.Score >= c.StepUpAt:
+		out.Decision = DecisionStepUp
+	default:
+		out.Decision = DecisionAllow
+	}
+	return out
+}
+
+func (c *Config) deviceNovelty(in Inputs) Factor {
+	if in.KnownDevice {
+		return Factor{Detail: "device used before"}
+	}
+	return Factor{Value: 1, Detail: "first payment from this device"}
+}
+
+func (c *Config) newBeneficiary(in Inputs) Factor {
+	if in.NewBeneficiary {
+		return Factor{Value: 1, Detail: "recipient not paid within the history window"}
+	}
+	return Factor{Detail: "established recipient"}
+}
+
+// amountDeviation is 0 at or below the median and reaches 1 at DeviationCap
+// times the median. Without enough history it scores half.
+func (c *Config) amountDeviation(in Inputs) Factor {
+	if len(in.History) < c.MinHistory || len(in.History) == 0 {
+		return Factor{Value: 0.5, Detail: fmt.Sprintf("only %d earlier payments", len(in.History))}
+	}
+	sorted := append([]int64(nil), in.History...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+	if median <= 0 {
+		median = 1
+	}
+	ratio := float64(in.Amount) / float64(median)
+	return Factor{Value: clamp((ratio - 1) / (c.DeviationCap - 1)), Detail: fmt.Sprintf("%.1fx the median of %d", ratio, median)}
+}
+
+func (c *Config) velocity(in Inputs) Factor {
+	return Factor{Value: clamp(float64(in.RecentPayments) / float64(c.VelocityLimit)), Detail: fmt.Sprintf("%d payments in %d minutes", in.RecentPayments, c.VelocityWindowMinutes)}
+}
+
+func (c *Config) timeOfDay(in Inputs) Factor {
+	hour := in.At.In(c.location).Hour()
+	night := hour >= c.NightStartHour && hour < c.NightEndHour
+	if c.NightStartHour > c.NightEndHour {
+		night = hour >= c.NightStartHour || hour < c.NightEndHour
+	}
+	if night {
+		return Factor{Value: 1, Detail: fmt.Sprintf("%02d:00 local time", hour)}
+	}
+	return Factor{Detail: fmt.Sprintf("%02d:00 local time", hour)}
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Validate rejects configs that cannot score and resolves Timezone.
+func (c *Config) Validate() error {
+	known := map[Signal]bool{SignalDeviceNovelty: true, SignalNewBeneficiary: true, SignalAmountDeviation: true, SignalVelocity: true, SignalTimeOfDay: true}
+	for signal, weight := range c.Weights {
+		if !known[signal] || weight < 0 {
+			return fmt.Errorf("%w: weight for %q is unknown or negative", ErrInvalidConfig, signal)
+		}
+	}
+	if c.StepUpAt <= 0 || c.BlockAt < c.StepUpAt {
+		return fmt.Errorf("%w: need 0 < stepUpAt <= blockAt", ErrInvalidConfig)
+	}
+	if c.HistoryDays <= 0 || c.MinHistory < 0 || c.DeviationCap <= 1 || c.VelocityWindowMinutes <= 0 || c.VelocityLimit <= 0 {
+		return fmt.Errorf("%w: history, deviation and velocity settings must be positive", ErrInvalidConfig)
+	}
+	if c.NightStartHour < 0 || c.NightStartHour > 23 || c.NightEndHour < 0 || c.NightEndHour > 23 {
+		return fmt.Errorf("%w: night hours must be 0-23", ErrInvalidConfig)
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: timezone: %v", ErrInvalidConfig, err)
+	}
+	c.location = loc
+	return nil
+}
+
+// DefaultConfig weighs signals to 100 points. A new device paying a new
+// recipient alone steps up; adding an unusual amount or burst blocks.
+func DefaultConfig() *Config {
+	c := &Config{
+		Weights: map[Signal]int{
+			SignalDeviceNovelty:   25,
+			SignalNewBeneficiary:  20,
+			SignalAmountDeviation: 25,
+			SignalVelocity:        20,
+			SignalTimeOfDay:       10,
+		},
+		StepUpAt:              45,
+		BlockAt:               80,
+		HistoryDays:           90,
+		MinHistory:            3,
+		DeviationCap:          5,
+		VelocityWindowMinutes: 60,
+		VelocityLimit:         10,
+		NightStartHour:        0,
+		NightEndHour:          5,
+		Timezone:              "Africa/Nairobi",
+	}
+	// The defaults always validate; this resolves the timezone.
+	_ = c.Validate()
+	return c
+}
+
+// LoadFile reads a JSON-encoded Config and validates it.
+func LoadFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
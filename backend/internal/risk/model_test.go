@@ -0,0 +1,74 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// noon in Nairobi, outside the default night hours.
+var noon = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+func TestAssessThresholds(t *testing.T) {
+	c := DefaultConfig()
+	known := Inputs{Amount: 1000, History: []int64{900, 1000, 1100}, KnownDevice: true, At: noon}
+	if a := c.Assess(known); a.Decision != DecisionAllow || a.Score != 0 || len(a.Factors) != 5 {
+		t.Fatalf("expected clean allow, got %#v", a)
+	}
+	fresh := known
+	fresh.KnownDevice, fresh.NewBeneficiary = false, true
+	if a := c.Assess(fresh); a.Decision != DecisionStepUp || a.Score != 45 || a.Factors[0].Signal != SignalDeviceNovelty {
+		t.Fatalf("expected step-up led by device novelty, got %#v", a)
+	}
+	fresh.Amount, fresh.RecentPayments = 10000, 10
+	if a := c.Assess(fresh); a.Decision != DecisionBlock || a.Score != 90 {
+		t.Fatalf("expected block, got %#v", a)
+	}
+}
+
+func TestAmountDeviationAgainstMedian(t *testing.T) {
+	c := DefaultConfig()
+	in := Inputs{History: []int64{100, 1000, 1000, 5000}, At: noon}
+	for _, tc := range []struct {
+		amount int64
+		want   float64
+	}{{500, 0}, {1000, 0}, {3000, 0.5}, {9000, 1}} {
+		in.Amount = tc.amount
+		if f := c.amountDeviation(in); f.Value != tc.want {
+			t.Fatalf("amount %d: got %v want %v", tc.amount, f.Value, tc.want)
+		}
+	}
+	if f := c.amountDeviation(Inputs{Amount: 1, History: []int64{1}}); f.Value != 0.5 {
+		t.Fatalf("thin history should score half, got %v", f.Value)
+	}
+}
+
+func TestNightHoursWrapMidnight(t *testing.T) {
+	c := DefaultConfig()
+	c.NightStartHour, c.NightEndHour = 22, 5
+	if err := c.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	// 20:00 UTC is 23:00 in Nairobi.
+	if f := c.timeOfDay(Inputs{At: time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)}); f.Value != 1 {
+		t.Fatalf("expected night, got %#v", f)
+	}
+	if f := c.timeOfDay(Inputs{At: noon}); f.Value != 0 {
+		t.Fatalf("expected day, got %#v", f)
+	}
+}
+
+func TestValidateRejectsBadConfig(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"unknown signal": func(c *Config) { c.Weights["ip_reputation"] = 10 },
+		"thresholds":     func(c *Config) { c.BlockAt = c.StepUpAt - 1 },
+		"deviation cap":  func(c *Config) { c.DeviationCap = 1 },
+		"timezone":       func(c *Config) { c.Timezone = "Mars/Olympus" },
+	} {
+		c := DefaultConfig()
+		mutate(c)
+		if err := c.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%s: expected invalid config, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type AdminHandler struct {
+	adminService *usecase.AdminService
+	audit        *auditor
+	logger       *slog.Logger
+}
+
+// NewAdminHandler records every change an operator makes with
+// auditService, which may be nil.
+func NewAdminHandler(adminService *usecase.AdminService, auditService *usecase.AuditService, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{adminService: adminService, audit: &auditor{service: auditService, logger: logger}, logger: logger}
+}
+
+type setUserStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+type closeAccountRequest struct {
+	Reason      string `json:"reason"`
+	PayoutPhone string `json:"payoutPhone"`
+}
+type setUserRoleRequest struct {
+	Role string `json:"role"`
+}
+type impersonateRequest struct {
+	Reason string `json:"reason"`
+}
+type reviewKYCRequest struct {
+	Decision string `json:"decision"`
+	Note     string `json:"note"`
+}
+
+type adminUserResponse struct {
+	userResponse
+	UpdatedAt     string `json:"updatedAt"`
+	PINLocked     bool   `json:"pinLocked"`
+	KYCNote       string `json:"kycNote,omitempty"`
+	KYCReviewedBy string `json:"kycReviewedBy,omitempty"`
+	KYCReviewedAt string `json:"kycReviewedAt,omitempty"`
+}
+type userStatusEventResponse struct {
+	ID        string   `json:"id"`
+	UserID    string   `json:"userId"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Reason    string   `json:"reason"`
+	ActorID   string   `json:"actorId"`
+	EntryIDs  []string `json:"entryIds,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+}
+type adminAccountResponse struct {
+	accountResponse
+	OwnerID string `json:"ownerId"`
+	Type    string `json:"type"`
+}
+type postingResponse struct {
+	AccountID string `json:"accountId"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+type ledgerEntryResponse struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Reference  string            `json:"reference"`
+	ReversalOf string            `json:"reversalOf,omitempty"`
+	Postings   []postingResponse `json:"postings"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CreatedAt  string            `json:"createdAt"`
+}
+
+func mapAdminUser(u *domain.User) adminUserResponse {
+	out := adminUserResponse{userResponse: mapUser(u), UpdatedAt: u.UpdatedAt.UTC().Format(time.RFC3339), PINLocked: u.PINLocked(time.Now()), KYCNote: u.KYCNote, KYCReviewedBy: u.KYCReviewedBy}
+	if !u.KYCReviewedAt.IsZero() {
+		out.KYCReviewedAt = u.KYCReviewedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func mapUserStatusEvent(e *domain.UserStatusEvent) userStatusEventResponse {
+	return userStatusEventResponse{ID: e.ID, UserID: e.UserID, From: string(e.From), To: string(e.To), Reason: e.Reason, ActorID: e.ActorID, EntryIDs: e.EntryIDs, CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func mapLedgerEntry(e *domain.JournalEntry) ledgerEntryResponse {
+	out := ledgerEntryResponse{ID: e.ID, Kind: string(e.Kind), Reference: e.Reference, ReversalOf: e.ReversalOf, Postings: make([]postingResponse, 0, len(e.Postings)), Metadata: e.Metadata, CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339)}
+	for _, p := range e.Postings {
+		out.Postings = append(out.Postings, postingResponse(p))
+	}
+	return out
+}
+
+// SearchUsers filters by q (a user ID or a prefix of the email, phone or
+// username), status, role and kycStatus.
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := domain.UserFilter{Query: q.Get("q"), Status: domain.UserStatus(q.Get("status")), Role: domain.UserRole(q.Get("role")), KYCStatus: domain.KYCStatus(q.Get("kycStatus"))}
+	h.searchUsers(w, r, filter)
+}
+
+// KYCQueue lists users awaiting KYC review, or those with kycStatus when
+// given.
+func (h *AdminHandler) KYCQueue(w http.ResponseWriter, r *http.Request) {
+	filter := domain.UserFilter{KYCStatus: domain.KYCStatusPending}
+	if v := r.URL.Query().Get("kycStatus"); v != "" {
+		filter.KYCStatus = domain.KYCStatus(v)
+	}
+	h.searchUsers(w, r, filter)
+}
+
+func (h *AdminHandler) searchUsers(w http.ResponseWriter, r *http.Request, filter domain.UserFilter) {
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid user search", domain.FieldErrors{"limit": "must be between 1 and 200"})
+			return
+		}
+		filter.Limit = n
+	}
+	users, fields, err := h.adminService.SearchUsers(r.Context(), currentUserID(r), filter)
+	if err != nil {
+		writeAdminError(w, err, "invalid user search", fields)
+		return
+	}
+	out := make([]adminUserResponse, 0, len(users))
+	for _, u := range users {
+		out = append(out, mapAdminUser(u))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"users": out})
+}
+
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.adminService.GetUser(r.Context(), currentUserID(r), chi.URLParam(r, "userID"))
+	if err != nil {
+		writeAdminError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapAdminUser(user)})
+}
+
+func (h *AdminHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	var req setUserStatusRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	user, event, fields, err := h.adminService.SetUserStatus(r.Context(), usecase.SetUserStatusInput{ActorID: currentUserID(r), UserID: chi.URLParam(r, "userID"), Status: req.Status, Reason: req.Reason})
+	if err != nil {
+		writeAdminError(w, err, "invalid status payload", fields)
+		return
+	}
+	h.logStatusChange(r, event)
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapAdminUser(user), "event": mapUserStatusEvent(event)})
+}
+
+// Close closes the account, paying any remaining balance out to
+// payoutPhone.
+func (h *AdminHandler) Close(w http.ResponseWriter, r *http.Request) {
+	var req closeAccountRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	user, event, fields, err := h.adminService.CloseAccount(r.Context(), usecase.CloseAccountInput{ActorID: currentUserID(r), UserID: chi.URLParam(r, "userID"), Reason: req.Reason, PayoutPhone: req.PayoutPhone})
+	if err != nil {
+		writeAdminError(w, err, "invalid close request", fields)
+		return
+	}
+	h.logStatusChange(r, event)
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapAdminUser(user), "event": mapUserStatusEvent(event)})
+}
+
+func (h *AdminHandler) StatusHistory(w http.ResponseWriter, r *http.Request) {
+	events, err := h.adminService.StatusHistory(r.Context(), currentUserID(r), chi.URLParam(r, "userID"))
+	if err != nil {
+		writeAdminError(w, err, "", nil)
+		return
+	}
+	out := make([]userStatusEventResponse, 0, len(events))
+	for _, e := range events {
+		out = append(out, mapUserStatusEvent(e))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": out})
+}
+
+func (h *AdminHandler) logStatusChange(r *http.Request, e *domain.UserStatusEvent) {
+	h.logger.Info("user_status_changed", "user_id", e.UserID, "from", string(e.From), "to", string(e.To), "actor_id", e.ActorID, "reason", e.Reason, "event_id", e.ID, "request_id", middleware.GetReqID(r.Context()))
+	details := map[string]string{"from": string(e.From), "to": string(e.To), "reason": e.Reason, "eventId": e.ID}
+	if len(e.EntryIDs) > 0 {
+		details["entryIds"] = strings.Join(e.EntryIDs, ",")
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditUserStatusChanged, ActorID: e.ActorID, TargetType: "user", TargetID: e.UserID, Details: details})
+}
+
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	var req setUserRoleRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	user, fields, err := h.adminService.SetUserRole(r.Context(), usecase.SetUserRoleInput{ActorID: currentUserID(r), UserID: chi.URLParam(r, "userID"), Role: req.Role})
+	if err != nil {
+		writeAdminError(w, err, "invalid role payload", fields)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditRoleChanged, ActorID: currentUserID(r), TargetType: "user", TargetID: user.ID, Details: map[string]string{"role": string(user.Role)}})
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapAdminUser(user)})
+}
+
+func (h *AdminHandler) ReviewKYC(w http.ResponseWriter, r *http.Request) {
+	var req reviewKYCRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	user, fields, err := h.adminService.ReviewKYC(r.Context(), usecase.ReviewKYCInput{ActorID: currentUserID(r), UserID: chi.URLParam(r, "userID"), Decision: req.Decision, Note: req.Note})
+	if err != nil {
+		writeAdminError(w, err, "invalid KYC review", fields)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditKYCReviewed, ActorID: currentUserID(r), TargetType: "user", TargetID: user.ID, Details: map[string]string{"decision": string(user.KYCStatus), "note": user.KYCNote, "status": string(user.Status)}})
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapAdminUser(user)})
+}
+
+// Impersonate issues a read-only token acting as the user. The grant is
+// logged with the reason; requests made with the token are logged with both
+// identities by Logging.
+func (h *AdminHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	var req impersonateRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	imp, fields, err := h.adminService.Impersonate(r.Context(), usecase.ImpersonateInput{ActorID: currentUserID(r), UserID: chi.URLParam(r, "userID"), Reason: req.Reason})
+	if err != nil {
+		writeAdminError(w, err, "invalid impersonation request", fields)
+		return
+	}
+	h.logger.Info("impersonation_started", "actor_id", imp.ActorID, "user_id", imp.User.ID, "impersonation_id", imp.ID, "reason", imp.Reason, "expires_at", imp.ExpiresAt.Format(time.RFC3339), "request_id", middleware.GetReqID(r.Context()))
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditImpersonationStarted, ActorID: imp.ActorID, TargetType: "user", TargetID: imp.User.ID, Details: map[string]string{"impersonationId": imp.ID, "reason": imp.Reason, "expiresAt": imp.ExpiresAt.Format(time.RFC3339)}})
+	writeJSON(w, http.StatusCreated, map[string]any{"impersonationId": imp.ID, "user": mapUser(imp.User), "accessToken": imp.AccessToken, "expiresAt": imp.ExpiresAt.Format(time.RFC3339), "readOnly": true})
+}
+
+func (h *AdminHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.adminService.ListAccounts(r.Context(), currentUserID(r), chi.URLParam(r, "userID"))
+	if err != nil {
+		writeAdminError(w, err, "", nil)
+		return
+	}
+	out := make([]adminAccountResponse, 0, len(accounts))
+	for _, a := range accounts {
+		out = append(out, adminAccountResponse{accountResponse: mapAccount(a.Account, a.Holds), OwnerID: a.Account.OwnerID, Type: string(a.Account.Type)})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"accounts": out})
+}
+
+// AccountEntries accepts from/to like the merchant settlement report and
+// defaults to the current UTC day.
+func (h *AdminHandler) AccountEntries(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseReportWindow(w, r)
+	if !ok {
+		return
+	}
+	account, entries, fields, err := h.adminService.AccountEntries(r.Context(), usecase.AccountEntriesInput{ActorID: currentUserID(r), AccountID: chi.URLParam(r, "accountID"), From: from, To: to})
+	if err != nil {
+		writeAdminError(w, err, "invalid lookup window", fields)
+		return
+	}
+	out := make([]ledgerEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, mapLedgerEntry(e))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"account": adminAccountResponse{accountResponse: mapAccount(account, nil), OwnerID: account.OwnerID, Type: string(account.Type)},
+		"from":    from.UTC().Format(time.RFC3339),
+		"to":      to.UTC().Format(time.RFC3339),
+		"entries": out,
+	})
+}
+
+func (h *AdminHandler) GetEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := h.adminService.GetEntry(r.Context(), currentUserID(r), chi.URLParam(r, "entryID"))
+	if err != nil {
+		writeAdminError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"entry": mapLedgerEntry(entry)})
+}
+
+func writeAdminError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrUserNotFound):
+		writeError(w, http.StatusNotFound, "user_not_found", "user not found", nil)
+	case errors.Is(err, domain.ErrAccountNotFound):
+		writeError(w, http.StatusNotFound, "account_not_found", "account not found", nil)
+	case errors.Is(err, domain.ErrEntryNotFound):
+		writeError(w, http.StatusNotFound, "entry_not_found", "entry not found", nil)
+	case errors.Is(err, domain.ErrInvalidTransition):
+		writeError(w, http.StatusConflict, "invalid_transition", "status change not allowed from the user's current status", nil)
+	case errors.Is(err, domain.ErrBalanceRemaining):
+		writeError(w, http.StatusConflict, "balance_remaining", "account still holds funds", fields)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		writeError(w, http.StatusConflict, "insufficient_funds", "balance changed while closing; retry", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
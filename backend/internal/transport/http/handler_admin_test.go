@@ -0,0 +1,143 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+func TestAdminRoutesRequirePermission(t *testing.T) {
+	repo := memory.NewUserRepository(memory.NewStore())
+	c1 := addUser(t, repo, domain.User{UsernameLower: "wanjiku", KYCStatus: domain.KYCStatusPending})
+	s1 := addUser(t, repo, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport, KYCStatus: domain.KYCStatusApproved})
+	a1 := addUser(t, repo, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin, KYCStatus: domain.KYCStatusApproved})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	services := Services{Auth: usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, nil), Admin: usecase.NewAdminService(repo, nil, nil, jwtMgr, usecase.AdminConfig{ImpersonationTTL: 15 * time.Minute})}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	token := func(u *domain.User) string {
+		opts := auth.TokenOptions{Role: string(u.Role), AMR: []string{auth.MethodPassword}}
+		for _, p := range u.Role.Permissions() {
+			opts.Permissions = append(opts.Permissions, string(p))
+		}
+		tok, _ := jwtMgr.IssueToken(u.ID, time.Hour, opts)
+		return tok
+	}
+	do := func(method, path, tok, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tok)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	customer, support := token(c1), token(s1)
+
+	if w := do(http.MethodGet, "/api/v1/admin/users", customer, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected customer refused, got %d", w.Code)
+	}
+	w := do(http.MethodGet, "/api/v1/admin/users?q=wan", support, "")
+	var out struct {
+		Users []adminUserResponse `json:"users"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	if w.Code != http.StatusOK || len(out.Users) != 1 || out.Users[0].ID != c1.ID || out.Users[0].KYCStatus != "pending" {
+		t.Fatalf("expected search to find c1, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/v1/admin/users/"+c1.ID+"/kyc", support, `{"decision":"approved"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected support refused KYC review, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/v1/admin/users/"+c1.ID+"/role", support, `{"role":"admin"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected support refused role change, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/v1/admin/users/"+a1.ID+"/status", support, `{"status":"suspended","reason":"test"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected support refused suspending an admin, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/v1/admin/users/"+c1.ID+"/status", support, `{"status":"suspended","reason":"fraud report"}`); w.Code != http.StatusOK || !hasStatus(repo, c1.ID, domain.UserStatusSuspended) {
+		t.Fatalf("expected customer suspended, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/api/v1/admin/users/"+c1.ID+"/status", support, `{"status":"suspended","reason":"again"}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected repeated suspension refused, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/v1/admin/users/"+c1.ID+"/status-events", support, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"reason":"fraud report"`) {
+		t.Fatalf("expected status history, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Tokens outlive role changes; the stored role decides.
+	if err := repo.SetRole(context.Background(), s1.ID, domain.UserRoleCustomer, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(http.MethodGet, "/api/v1/admin/users/"+c1.ID, support, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected demoted operator refused, got %d", w.Code)
+	}
+}
+
+func TestImpersonationIsReadOnlyAndLogged(t *testing.T) {
+	repo := memory.NewUserRepository(memory.NewStore())
+	c1 := addUser(t, repo, domain.User{UsernameLower: "wanjiku"})
+	s1 := addUser(t, repo, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	var logs bytes.Buffer
+	services := Services{Auth: usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, nil), Admin: usecase.NewAdminService(repo, nil, nil, jwtMgr, usecase.AdminConfig{ImpersonationTTL: 15 * time.Minute})}
+	r := NewRouter(slog.New(slog.NewJSONHandler(&logs, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	do := func(method, path, tok, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tok)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	support, _ := jwtMgr.IssueToken(s1.ID, time.Hour, auth.TokenOptions{Role: "support", Permissions: []string{string(domain.PermUsersImpersonate)}, AMR: []string{auth.MethodPassword}})
+
+	if w := do(http.MethodPost, "/api/v1/admin/users/"+c1.ID+"/impersonate", support, `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected reason required, got %d", w.Code)
+	}
+	w := do(http.MethodPost, "/api/v1/admin/users/"+c1.ID+"/impersonate", support, `{"reason":"ticket 4411: balance looks wrong"}`)
+	var out struct {
+		ImpersonationID string `json:"impersonationId"`
+		AccessToken     string `json:"accessToken"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	if w.Code != http.StatusCreated || out.AccessToken == "" {
+		t.Fatalf("expected impersonation token, got %d %s", w.Code, w.Body.String())
+	}
+	claims, err := jwtMgr.Verify(out.AccessToken)
+	if err != nil || claims.Sub != c1.ID || claims.Act == nil || claims.Act.Sub != s1.ID || claims.ID != out.ImpersonationID || len(claims.Permissions) != 0 {
+		t.Fatalf("unexpected impersonation claims %#v %v", claims, err)
+	}
+
+	logs.Reset()
+	w = do(http.MethodGet, "/api/v1/me", out.AccessToken, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id":"`+c1.ID+`"`) {
+		t.Fatalf("expected customer's view, got %d %s", w.Code, w.Body.String())
+	}
+	var line map[string]any
+	_ = json.Unmarshal(logs.Bytes(), &line)
+	if line["user_id"] != c1.ID || line["actor_id"] != s1.ID || line["impersonation_id"] != out.ImpersonationID {
+		t.Fatalf("expected both identities logged, got %s", logs.String())
+	}
+
+	w = do(http.MethodPost, "/api/v1/me/password", out.AccessToken, `{"newPassword":"Password2"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "impersonation_read_only") {
+		t.Fatalf("expected mutation refused, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/v1/admin/users/"+s1.ID+"/impersonate", support, `{"reason":"self"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected self impersonation refused, got %d", w.Code)
+	}
+}
+
+// hasStatus reports whether the stored user is in status.
+func hasStatus(users *memory.UserRepository, id string, status domain.UserStatus) bool {
+	u, err := users.GetByID(context.Background(), id)
+	return err == nil && u.Status == status
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/aml"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AMLHandler struct{ monitoringService *usecase.MonitoringService }
+
+func NewAMLHandler(monitoringService *usecase.MonitoringService) *AMLHandler {
+	return &AMLHandler{monitoringService: monitoringService}
+}
+
+type replayRequest struct {
+	From  time.Time  `json:"from"`
+	To    time.Time  `json:"to"`
+	Rules []aml.Rule `json:"rules"`
+}
+type closeAlertRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+type alertResponse struct {
+	ID             string   `json:"id"`
+	RuleID         string   `json:"ruleId"`
+	RuleName       string   `json:"ruleName"`
+	Severity       string   `json:"severity"`
+	SubjectKey     string   `json:"subjectKey"`
+	OwnerID        string   `json:"ownerId"`
+	AccountID      string   `json:"accountId,omitempty"`
+	Currency       string   `json:"currency"`
+	Value          int64    `json:"value"`
+	Threshold      int64    `json:"threshold"`
+	TriggerEntryID string   `json:"triggerEntryId"`
+	EntryIDs       []string `json:"entryIds"`
+	Status         string   `json:"status"`
+	AssignedTo     string   `json:"assignedTo,omitempty"`
+	ResolutionNote string   `json:"resolutionNote,omitempty"`
+	ResolvedBy     string   `json:"resolvedBy,omitempty"`
+	CreatedAt      string   `json:"createdAt"`
+	UpdatedAt      string   `json:"updatedAt"`
+	ResolvedAt     string   `json:"resolvedAt,omitempty"`
+}
+type replayHitResponse struct {
+	RuleID         string   `json:"ruleId"`
+	SubjectKey     string   `json:"subjectKey"`
+	OwnerID        string   `json:"ownerId"`
+	AccountID      string   `json:"accountId,omitempty"`
+	TriggerEntryID string   `json:"triggerEntryId"`
+	Triggers       int      `json:"triggers"`
+	Value          int64    `json:"value"`
+	EntryIDs       []string `json:"entryIds"`
+	At             string   `json:"at"`
+}
+
+func mapAlert(a *domain.AMLAlert) alertResponse {
+	out := alertResponse{ID: a.ID, RuleID: a.RuleID, RuleName: a.RuleName, Severity: a.Severity, SubjectKey: a.SubjectKey, OwnerID: a.OwnerID, AccountID: a.AccountID, Currency: a.Currency, Value: a.Value, Threshold: a.Threshold, TriggerEntryID: a.TriggerEntryID, EntryIDs: a.EntryIDs, Status: string(a.Status), AssignedTo: a.AssignedTo, ResolutionNote: a.ResolutionNote, ResolvedBy: a.ResolvedBy, CreatedAt: a.CreatedAt.UTC().Format(time.RFC3339), UpdatedAt: a.UpdatedAt.UTC().Format(time.RFC3339)}
+	if !a.ResolvedAt.IsZero() {
+		out.ResolvedAt = a.ResolvedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func (h *AMLHandler) Rules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.monitoringService.Rules(r.Context(), currentUserID(r))
+	if err != nil {
+		writeAMLError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules})
+}
+
+// Replay backtests rules over historical postings; nothing is raised.
+func (h *AMLHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	var req replayRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, fields, err := h.monitoringService.Replay(r.Context(), usecase.ReplayInput{ActorID: currentUserID(r), Rules: req.Rules, From: req.From, To: req.To})
+	if err != nil {
+		writeAMLError(w, err, "invalid replay payload", fields)
+		return
+	}
+	hits := make([]replayHitResponse, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		hits = append(hits, replayHitResponse{RuleID: hit.RuleID, SubjectKey: hit.SubjectKey, OwnerID: hit.OwnerID, AccountID: hit.AccountID, TriggerEntryID: hit.TriggerEntryID, Triggers: hit.Triggers, Value: hit.Value, EntryIDs: hit.EntryIDs, At: hit.At.UTC().Format(time.RFC3339)})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"scanned": res.Scanned, "truncated": res.Truncated, "hits": hits})
+}
+
+func (h *AMLHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := h.monitoringService.ListAlerts(r.Context(), currentUserID(r), domain.AlertStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		writeAMLError(w, err, "", nil)
+		return
+	}
+	out := make([]alertResponse, 0, len(alerts))
+	for _, a := range alerts {
+		out = append(out, mapAlert(a))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alerts": out})
+}
+
+func (h *AMLHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	alert, err := h.monitoringService.GetAlert(r.Context(), currentUserID(r), chi.URLParam(r, "alertID"))
+	if err != nil {
+		writeAMLError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alert": mapAlert(alert)})
+}
+
+func (h *AMLHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	alert, err := h.monitoringService.Assign(r.Context(), currentUserID(r), chi.URLParam(r, "alertID"))
+	if err != nil {
+		writeAMLError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alert": mapAlert(alert)})
+}
+
+func (h *AMLHandler) Close(w http.ResponseWriter, r *http.Request) {
+	var req closeAlertRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	alert, fields, err := h.monitoringService.Close(r.Context(), usecase.CloseAlertInput{ActorID: currentUserID(r), AlertID: chi.URLParam(r, "alertID"), Status: req.Status, Note: req.Note})
+	if err != nil {
+		writeAMLError(w, err, "invalid close payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alert": mapAlert(alert)})
+}
+
+func writeAMLError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrAlertNotFound):
+		writeError(w, http.StatusNotFound, "alert_not_found", "alert not found", nil)
+	case errors.Is(err, domain.ErrInvalidTransition):
+		writeError(w, http.StatusConflict, "invalid_transition", "alert is not in a state that allows this", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type APIClientHandler struct {
+	clients *usecase.APIClientService
+	audit   *auditor
+}
+
+func NewAPIClientHandler(clients *usecase.APIClientService, auditService *usecase.AuditService, logger *slog.Logger) *APIClientHandler {
+	return &APIClientHandler{clients: clients, audit: &auditor{service: auditService, logger: logger}}
+}
+
+type createAPIClientRequest struct {
+	Name            string   `json:"name"`
+	Scopes          []string `json:"scopes"`
+	AllowedNetworks []string `json:"allowedNetworks"`
+	RedirectURIs    []string `json:"redirectUris"`
+	Public          bool     `json:"public"`
+}
+
+type apiKeyResponse struct {
+	Prefix     string `json:"prefix"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt,omitempty"`
+	RevokedAt  string `json:"revokedAt,omitempty"`
+}
+type apiClientResponse struct {
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	Scopes          []domain.Scope   `json:"scopes"`
+	AllowedNetworks []string         `json:"allowedNetworks"`
+	RedirectURIs    []string         `json:"redirectUris"`
+	Public          bool             `json:"public"`
+	Keys            []apiKeyResponse `json:"keys"`
+	Status          string           `json:"status"`
+	CreatedBy       string           `json:"createdBy"`
+	CreatedAt       string           `json:"createdAt"`
+	UpdatedAt       string           `json:"updatedAt"`
+}
+
+// mapAPIClient lists keys by prefix only; a key itself is shown once when
+// created.
+func mapAPIClient(c *domain.APIClient) apiClientResponse {
+	keys := make([]apiKeyResponse, 0, len(c.Keys))
+	for _, k := range c.Keys {
+		out := apiKeyResponse{Prefix: k.Prefix, CreatedAt: k.CreatedAt.Format(time.RFC3339)}
+		if !k.LastUsedAt.IsZero() {
+			out.LastUsedAt = k.LastUsedAt.Format(time.RFC3339)
+		}
+		if !k.RevokedAt.IsZero() {
+			out.RevokedAt = k.RevokedAt.Format(time.RFC3339)
+		}
+		keys = append(keys, out)
+	}
+	networks := make([]string, 0, len(c.AllowedNetworks))
+	for _, n := range c.AllowedNetworks {
+		networks = append(networks, n.String())
+	}
+	redirectURIs := c.RedirectURIs
+	if redirectURIs == nil {
+		redirectURIs = []string{}
+	}
+	return apiClientResponse{ID: c.ID, Name: c.Name, Scopes: c.Scopes, AllowedNetworks: networks, RedirectURIs: redirectURIs, Public: c.Public, Keys: keys, Status: string(c.Status), CreatedBy: c.CreatedBy, CreatedAt: c.CreatedAt.Format(time.RFC3339), UpdatedAt: c.UpdatedAt.Format(time.RFC3339)}
+}
+
+func (h *APIClientHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createAPIClientRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	client, key, fields, err := h.clients.Create(r.Context(), usecase.CreateAPIClientInput{ActorID: currentUserID(r), Name: req.Name, Scopes: req.Scopes, AllowedNetworks: req.AllowedNetworks, RedirectURIs: req.RedirectURIs, Public: req.Public})
+	if err != nil {
+		writeAPIClientError(w, err, "invalid API client", fields)
+		return
+	}
+	details := map[string]string{"name": client.Name, "scope": domain.ScopeString(client.Scopes)}
+	if len(client.Keys) > 0 {
+		details["keyPrefix"] = client.Keys[0].Prefix
+	}
+	if client.Public {
+		details["public"] = "true"
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditAPIClientCreated, ActorID: currentUserID(r), TargetType: "api_client", TargetID: client.ID, Details: details})
+	// Public clients have no key to hand out.
+	out := map[string]any{"client": mapAPIClient(client)}
+	if key != "" {
+		out["key"] = key
+	}
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (h *APIClientHandler) List(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.clients.List(r.Context(), currentUserID(r))
+	if err != nil {
+		writeAPIClientError(w, err, "", nil)
+		return
+	}
+	out := make([]apiClientResponse, 0, len(clients))
+	for _, c := range clients {
+		out = append(out, mapAPIClient(c))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"clients": out})
+}
+
+func (h *APIClientHandler) Get(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clients.Get(r.Context(), currentUserID(r), chi.URLParam(r, "clientID"))
+	if err != nil {
+		writeAPIClientError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"client": mapAPIClient(client)})
+}
+
+func (h *APIClientHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	client, key, fields, err := h.clients.CreateKey(r.Context(), currentUserID(r), chi.URLParam(r, "clientID"))
+	if err != nil {
+		writeAPIClientError(w, err, "cannot add key", fields)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditAPIKeyCreated, ActorID: currentUserID(r), TargetType: "api_client", TargetID: client.ID, Details: map[string]string{"keyPrefix": client.Keys[len(client.Keys)-1].Prefix}})
+	writeJSON(w, http.StatusCreated, map[string]any{"client": mapAPIClient(client), "key": key})
+}
+
+func (h *APIClientHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "keyPrefix")
+	client, err := h.clients.RevokeKey(r.Context(), currentUserID(r), chi.URLParam(r, "clientID"), prefix)
+	if err != nil {
+		writeAPIClientError(w, err, "", nil)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditAPIKeyRevoked, ActorID: currentUserID(r), TargetType: "api_client", TargetID: client.ID, Details: map[string]string{"keyPrefix": prefix}})
+	writeJSON(w, http.StatusOK, map[string]any{"client": mapAPIClient(client)})
+}
+
+func (h *APIClientHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clients.Disable(r.Context(), currentUserID(r), chi.URLParam(r, "clientID"))
+	if err != nil {
+		writeAPIClientError(w, err, "", nil)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditAPIClientDisabled, ActorID: currentUserID(r), TargetType: "api_client", TargetID: client.ID})
+	writeJSON(w, http.StatusOK, map[string]any{"client": mapAPIClient(client)})
+}
+
+func writeAPIClientError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrAPIClientNotFound):
+		writeError(w, http.StatusNotFound, "api_client_not_found", "API client not found", nil)
+	case errors.Is(err, domain.ErrAPIKeyNotFound):
+		writeError(w, http.StatusNotFound, "api_key_not_found", "API key not found", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// maxAuditUserAgent caps the user agent kept on an audit record.
+const maxAuditUserAgent = 512
+
+// auditor writes a request's security events to the audit log with the
+// caller's IP, user agent and request ID. A failed write is logged rather
+// than failing the request, whose effect has already happened.
+type auditor struct {
+	service *usecase.AuditService
+	logger  *slog.Logger
+}
+
+func (a *auditor) record(r *http.Request, rec domain.AuditRecord) {
+	if a == nil {
+		return
+	}
+	rec.IP, rec.UserAgent, rec.RequestID = clientIP(r), r.UserAgent(), middleware.GetReqID(r.Context())
+	if len(rec.UserAgent) > maxAuditUserAgent {
+		rec.UserAgent = rec.UserAgent[:maxAuditUserAgent]
+	}
+	// The client hanging up must not cost the record.
+	if err := a.service.Record(context.WithoutCancel(r.Context()), &rec); err != nil {
+		a.logger.Error("audit_write_failed", "action", string(rec.Action), "actor_id", rec.ActorID, "target_id", rec.TargetID, "request_id", rec.RequestID, "error", err)
+	}
+}
+
+// clientIP is the peer address. Forwarded headers are not trusted because
+// the router does not sit behind a known proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type AuditHandler struct{ auditService *usecase.AuditService }
+
+func NewAuditHandler(auditService *usecase.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+type auditRecordResponse struct {
+	Seq        int64             `json:"seq"`
+	Action     string            `json:"action"`
+	ActorID    string            `json:"actorId,omitempty"`
+	TargetType string            `json:"targetType,omitempty"`
+	TargetID   string            `json:"targetId,omitempty"`
+	IP         string            `json:"ip,omitempty"`
+	UserAgent  string            `json:"userAgent,omitempty"`
+	RequestID  string            `json:"requestId,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	CreatedAt  string            `json:"createdAt"`
+	PrevHash   string            `json:"prevHash"`
+	Hash       string            `json:"hash"`
+}
+
+func mapAuditRecord(rec *domain.AuditRecord) auditRecordResponse {
+	return auditRecordResponse{Seq: rec.Seq, Action: string(rec.Action), ActorID: rec.ActorID, TargetType: rec.TargetType, TargetID: rec.TargetID, IP: rec.IP, UserAgent: rec.UserAgent, RequestID: rec.RequestID, Details: rec.Details, CreatedAt: rec.CreatedAt.UTC().Format(time.RFC3339Nano), PrevHash: rec.PrevHash, Hash: rec.Hash}
+}
+
+// Search filters by actorId, targetId, action and an optional RFC 3339
+// from/to window.
+func (h *AuditHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := domain.AuditFilter{ActorID: q.Get("actorId"), TargetID: q.Get("targetId"), Action: domain.AuditAction(q.Get("action"))}
+	fields := domain.FieldErrors{}
+	for key, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := q.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				fields[key] = "must be RFC 3339"
+				continue
+			}
+			*dst = t.UTC()
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			fields["limit"] = "must be between 1 and 500"
+		}
+		filter.Limit = n
+	}
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid audit search", fields)
+		return
+	}
+	records, fields, err := h.auditService.Search(r.Context(), currentUserID(r), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid audit search", fields)
+		case errors.Is(err, domain.ErrForbidden):
+			writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+		case errors.Is(err, domain.ErrUnauthorized):
+			writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+		}
+		return
+	}
+	out := make([]auditRecordResponse, 0, len(records))
+	for _, rec := range records {
+		out = append(out, mapAuditRecord(rec))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"records": out})
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+// auditActions lists the recorded audit actions, oldest first.
+func auditActions(t *testing.T, records *memory.AuditRepository) []domain.AuditAction {
+	t.Helper()
+	stored, err := records.ListAfter(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []domain.AuditAction
+	for _, rec := range stored {
+		actions = append(actions, rec.Action)
+	}
+	return actions
+}
+
+func TestLoginAttemptsAreAudited(t *testing.T) {
+	store := memory.NewStore()
+	repo, records := memory.NewUserRepository(store), memory.NewAuditRepository(store)
+	k1 := addUser(t, repo, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance})
+	s1 := addUser(t, repo, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	services := Services{
+		Auth:  usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, nil),
+		Admin: usecase.NewAdminService(repo, nil, nil, jwtMgr, usecase.AdminConfig{ImpersonationTTL: 15 * time.Minute}),
+		Audit: usecase.NewAuditService(records, repo),
+	}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	do := func(method, path, tok string, body any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("User-Agent", "akiba-test/1.0")
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	token := func(u *domain.User) string {
+		opts := auth.TokenOptions{Role: string(u.Role), AMR: []string{auth.MethodPassword}}
+		for _, p := range u.Role.Permissions() {
+			opts.Permissions = append(opts.Permissions, string(p))
+		}
+		tok, _ := jwtMgr.IssueToken(u.ID, time.Hour, opts)
+		return tok
+	}
+
+	if w := do(http.MethodPost, "/api/v1/auth/signup", "", map[string]string{"email": "user@example.com", "phone": "+14155552671", "username": "user_1", "password": "Password1"}); w.Code != http.StatusCreated {
+		t.Fatalf("signup: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"login": "user_1", "password": "Wrong1234"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected bad login refused, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"login": "user_1", "password": "Password1"}); w.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodGet, "/api/v1/admin/audit", token(s1), nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected support refused, got %d", w.Code)
+	}
+	w := do(http.MethodGet, "/api/v1/admin/audit?action=auth.login_failed", token(k1), nil)
+	var out struct {
+		Records []auditRecordResponse `json:"records"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || w.Code != http.StatusOK || len(out.Records) != 1 {
+		t.Fatalf("unexpected audit search %d %s", w.Code, w.Body.String())
+	}
+	failed := out.Records[0]
+	if failed.ActorID != "" || failed.Details["login"] != "user_1" || failed.UserAgent != "akiba-test/1.0" || failed.IP == "" || failed.RequestID == "" {
+		t.Fatalf("failed login missing context: %#v", failed)
+	}
+	if w := do(http.MethodGet, "/api/v1/admin/audit?from=yesterday", token(k1), nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad from refused, got %d", w.Code)
+	}
+
+	res, err := services.Audit.Verify(context.Background(), nil)
+	if err != nil || !res.OK() || res.Records < 4 {
+		t.Fatalf("expected intact chain of signup, token, failure and login, got %#v %v", res, err)
+	}
+}
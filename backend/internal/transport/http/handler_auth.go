@@ -2,27 +2,56 @@ package http
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"akiba/backend/internal/domain"
 	"akiba/backend/internal/usecase"
 )
 
-type AuthHandler struct{ authService *usecase.AuthService }
+type AuthHandler struct {
+	authService *usecase.AuthService
+	audit       *auditor
+}
 
-func NewAuthHandler(authService *usecase.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+// NewAuthHandler records signups, logins, token issues and password
+// changes with auditService, which may be nil.
+func NewAuthHandler(authService *usecase.AuthService, auditService *usecase.AuditService, logger *slog.Logger) *AuthHandler {
+	return &AuthHandler{authService: authService, audit: &auditor{service: auditService, logger: logger}}
 }
 
+type deviceRequest struct {
+	ID        string `json:"id"`
+	Platform  string `json:"platform"`
+	PublicKey string `json:"publicKey"`
+	PushToken string `json:"pushToken"`
+}
 type signupRequest struct {
-	Email    string `json:"email"`
-	Phone    string `json:"phone"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Email    string         `json:"email"`
+	Phone    string         `json:"phone"`
+	Username string         `json:"username"`
+	Password string         `json:"password"`
+	Device   *deviceRequest `json:"device"`
 }
 type loginRequest struct {
-	Login    string `json:"login"`
+	Login    string         `json:"login"`
+	Password string         `json:"password"`
+	Device   *deviceRequest `json:"device"`
+}
+type loginOTPRequest struct {
+	ChallengeID string `json:"challengeId"`
+	Code        string `json:"code"`
+}
+type stepUpRequest struct {
+	Method   string `json:"method"`
 	Password string `json:"password"`
+	PIN      string `json:"pin"`
+	Code     string `json:"code"`
+}
+type changePasswordRequest struct {
+	NewPassword string `json:"newPassword"`
 }
 type userResponse struct {
 	ID        string `json:"id"`
@@ -31,10 +60,20 @@ type userResponse struct {
 	Username  string `json:"username"`
 	CreatedAt string `json:"createdAt"`
 	Status    string `json:"status,omitempty"`
+	Role      string `json:"role"`
+	KYCStatus string `json:"kycStatus"`
+	PINSet    bool   `json:"pinSet"`
 }
 
 func mapUser(u *domain.User) userResponse {
-	return userResponse{ID: u.ID, Email: u.EmailLower, Phone: u.PhoneE164, Username: u.UsernameLower, CreatedAt: u.CreatedAt.UTC().Format("2006-01-02T15:04:05Z07:00"), Status: string(u.Status)}
+	return userResponse{ID: u.ID, Email: u.EmailLower, Phone: u.PhoneE164, Username: u.UsernameLower, CreatedAt: u.CreatedAt.UTC().Format("2006-01-02T15:04:05Z07:00"), Status: string(u.Status), Role: string(u.Role), KYCStatus: string(u.KYCStatus), PINSet: u.HasPIN()}
+}
+
+func (d *deviceRequest) input() *usecase.DeviceInput {
+	if d == nil {
+		return nil
+	}
+	return &usecase.DeviceInput{DeviceID: d.ID, Platform: d.Platform, PublicKey: d.PublicKey, PushToken: d.PushToken}
 }
 
 func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
@@ -42,7 +81,7 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	if !decodeJSONBody(w, r, &req) {
 		return
 	}
-	res, fields, err := h.authService.Signup(r.Context(), usecase.SignupInput(req))
+	res, fields, err := h.authService.Signup(r.Context(), usecase.SignupInput{Email: req.Email, Phone: req.Phone, Username: req.Username, Password: req.Password, Device: req.Device.input()})
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrInvalidInput):
@@ -54,6 +93,8 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditSignup, ActorID: res.User.ID, TargetType: "user", TargetID: res.User.ID})
+	auditTokenIssued(h.audit, r, res, "password")
 	writeJSON(w, http.StatusCreated, map[string]any{"user": mapUser(res.User), "accessToken": res.AccessToken})
 }
 
@@ -62,24 +103,57 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if !decodeJSONBody(w, r, &req) {
 		return
 	}
-	res, fields, err := h.authService.Login(r.Context(), usecase.LoginInput(req))
+	res, fields, err := h.authService.Login(r.Context(), usecase.LoginInput{Login: req.Login, Password: req.Password, Device: req.Device.input()})
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrInvalidInput):
 			writeError(w, http.StatusBadRequest, "validation_error", "invalid login payload", fields)
 		case errors.Is(err, domain.ErrInvalidCredentials):
+			auditLoginFailed(h.audit, r, "", "password", map[string]string{"login": strings.TrimSpace(req.Login)})
+			writeError(w, http.StatusUnauthorized, "invalid_credentials", "invalid login or password", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+		}
+		return
+	}
+	if res.OTPChallengeID != "" {
+		writeJSON(w, http.StatusAccepted, map[string]any{"otpRequired": true, "challengeId": res.OTPChallengeID, "expiresAt": res.OTPExpiresAt.UTC().Format(time.RFC3339)})
+		return
+	}
+	auditLogin(h.audit, r, res, "password")
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapUser(res.User), "accessToken": res.AccessToken})
+}
+
+// VerifyLoginOTP finishes a login from a new device with the code sent to
+// the user's phone.
+func (h *AuthHandler) VerifyLoginOTP(w http.ResponseWriter, r *http.Request) {
+	var req loginOTPRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, fields, err := h.authService.VerifyLoginOTP(r.Context(), usecase.VerifyLoginOTPInput(req))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid OTP payload", fields)
+		case errors.Is(err, domain.ErrChallengeNotFound):
+			writeError(w, http.StatusBadRequest, "challenge_not_found", "code expired or already used; log in again", nil)
+		case errors.Is(err, domain.ErrInvalidOTP):
+			auditLoginFailed(h.audit, r, "", "otp", map[string]string{"challengeId": req.ChallengeID})
+			writeError(w, http.StatusUnauthorized, "invalid_otp", "incorrect code", nil)
+		case errors.Is(err, domain.ErrInvalidCredentials), errors.Is(err, domain.ErrUserNotFound):
 			writeError(w, http.StatusUnauthorized, "invalid_credentials", "invalid login or password", nil)
 		default:
 			writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
 		}
 		return
 	}
+	auditLogin(h.audit, r, res, "otp")
 	writeJSON(w, http.StatusOK, map[string]any{"user": mapUser(res.User), "accessToken": res.AccessToken})
 }
 
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
-	userID, _ := r.Context().Value(ctxKeyUserID{}).(string)
-	user, err := h.authService.Me(r.Context(), userID)
+	user, err := h.authService.Me(r.Context(), currentUserID(r))
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrUnauthorized) {
 			writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
@@ -90,3 +164,84 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"user": mapUser(user)})
 }
+
+// StepUp exchanges a re-authentication for a short-lived token that passes
+// RequireFreshAuth.
+func (h *AuthHandler) StepUp(w http.ResponseWriter, r *http.Request) {
+	var req stepUpRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, fields, err := h.authService.StepUp(r.Context(), usecase.StepUpInput{UserID: currentUserID(r), Method: req.Method, Password: req.Password, PIN: req.PIN, Code: req.Code, Device: currentDevice(r)})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) || errors.Is(err, domain.ErrInvalidPIN) || errors.Is(err, domain.ErrPINLocked) || errors.Is(err, domain.ErrInvalidOTP) || errors.Is(err, domain.ErrTOTPLocked) {
+			auditLoginFailed(h.audit, r, currentUserID(r), "step_up_"+strings.ToLower(strings.TrimSpace(req.Method)), nil)
+		}
+		if writePINVerifyError(w, err) || writeTOTPVerifyError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid step-up payload", fields)
+		case errors.Is(err, domain.ErrInvalidCredentials):
+			writeError(w, http.StatusUnauthorized, "invalid_credentials", "verification failed", nil)
+		case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrUnauthorized):
+			writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+		}
+		return
+	}
+	auditTokenIssued(h.audit, r, res, "step_up_"+strings.ToLower(strings.TrimSpace(req.Method)))
+	writeJSON(w, http.StatusOK, map[string]any{"accessToken": res.AccessToken, "expiresAt": res.ExpiresAt.UTC().Format(time.RFC3339)})
+}
+
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req changePasswordRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	fields, err := h.authService.ChangePassword(r.Context(), usecase.ChangePasswordInput{UserID: currentUserID(r), NewPassword: req.NewPassword})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid password payload", fields)
+		case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrUnauthorized):
+			writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+		}
+		return
+	}
+	userID := currentUserID(r)
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditPasswordChanged, ActorID: userID, TargetType: "user", TargetID: userID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// auditLogin records a completed login and the token it issued.
+func auditLogin(a *auditor, r *http.Request, res *usecase.AuthResult, method string) {
+	a.record(r, domain.AuditRecord{Action: domain.AuditLoginSucceeded, ActorID: res.User.ID, TargetType: "user", TargetID: res.User.ID, Details: map[string]string{"method": method}})
+	auditTokenIssued(a, r, res, method)
+}
+
+func auditTokenIssued(a *auditor, r *http.Request, res *usecase.AuthResult, method string) {
+	details := map[string]string{"method": method}
+	if !res.ExpiresAt.IsZero() {
+		details["expiresAt"] = res.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	a.record(r, domain.AuditRecord{Action: domain.AuditTokenIssued, ActorID: res.User.ID, TargetType: "user", TargetID: res.User.ID, Details: details})
+}
+
+// auditLoginFailed records a rejected credential. userID is empty when the
+// login did not identify a user.
+func auditLoginFailed(a *auditor, r *http.Request, userID, method string, details map[string]string) {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["method"] = method
+	rec := domain.AuditRecord{Action: domain.AuditLoginFailed, ActorID: userID, Details: details}
+	if userID != "" {
+		rec.TargetType, rec.TargetID = "user", userID
+	}
+	a.record(r, rec)
+}
@@ -3,54 +3,70 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"akiba/backend/internal/auth"
 	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/notify"
 	"akiba/backend/internal/usecase"
 )
 
-type memRepo struct{ users map[string]*domain.User }
-
-func (m *memRepo) EnsureIndexes(ctx context.Context) error { return nil }
-func (m *memRepo) Create(ctx context.Context, user *domain.User) error {
-	for _, u := range m.users {
-		if u.EmailLower == user.EmailLower || u.PhoneE164 == user.PhoneE164 || u.UsernameLower == user.UsernameLower {
-			return domain.ErrUserExists
-		}
+// addUser stores u as an active customer unless it says otherwise and
+// returns it as stored. The email defaults to one derived from the
+// username, since the repository keeps emails unique.
+func addUser(t *testing.T, users *memory.UserRepository, u domain.User) *domain.User {
+	t.Helper()
+	if u.EmailLower == "" {
+		u.EmailLower = u.UsernameLower + "@example.com"
 	}
-	user.ID = "u1"
-	m.users[user.ID] = user
-	return nil
-}
-func (m *memRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
-	u, ok := m.users[id]
-	if !ok {
-		return nil, domain.ErrUserNotFound
+	if u.Status == "" {
+		u.Status = domain.UserStatusActive
+	}
+	if u.Role == "" {
+		u.Role = domain.UserRoleCustomer
+	}
+	if err := users.Create(context.Background(), &u); err != nil {
+		t.Fatalf("add user: %v", err)
 	}
-	return u, nil
+	return &u
 }
-func (m *memRepo) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
-	for _, u := range m.users {
-		if u.EmailLower == login || u.PhoneE164 == login || u.UsernameLower == login {
-			return u, nil
-		}
+
+// signup registers user_1 through the API and returns the new user's ID.
+func signup(t *testing.T, r http.Handler) string {
+	t.Helper()
+	b, _ := json.Marshal(map[string]string{"email": "user@example.com", "phone": "+14155552671", "username": "user_1", "password": "Password1"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/signup", bytes.NewReader(b)))
+	var out struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); w.Code != http.StatusCreated || err != nil || out.User.ID == "" {
+		t.Fatalf("signup: expected 201, got %d %s", w.Code, w.Body.String())
 	}
-	return nil, domain.ErrUserNotFound
+	return out.User.ID
 }
 
 func testRouter() http.Handler {
-	repo := &memRepo{users: map[string]*domain.User{}}
+	repo := memory.NewUserRepository(memory.NewStore())
 	jwtMgr := auth.NewJWTManager("secret", "test")
-	authSvc := usecase.NewAuthService(repo, jwtMgr, time.Hour)
+	authSvc := usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute, TransferThresholds: map[string]int64{"KES": 5000000}}, nil, nil, nil, nil)
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	return NewRouter(logger, authSvc, jwtMgr, func(ctx context.Context) error { return nil })
+	return NewRouter(logger, Services{Auth: authSvc}, jwtMgr, func(ctx context.Context) error { return nil })
 }
 
 func TestSignupValidationError(t *testing.T) {
@@ -123,3 +139,88 @@ func TestReadyEndpoint(t *testing.T) {
 		t.Fatalf("expected 200, got %d", w.Code)
 	}
 }
+
+func TestPasswordChangeRequiresFreshAuth(t *testing.T) {
+	r := testRouter()
+	id := signup(t, r)
+	// A token without auth_time, as issued before step-up existed.
+	stale, _ := auth.NewJWTManager("secret", "test").IssueAccessToken(id, time.Hour)
+	do := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("/api/v1/me/password", stale, `{"newPassword":"Password2"}`); w.Code != http.StatusForbidden || !bytes.Contains(w.Body.Bytes(), []byte("step_up_required")) {
+		t.Fatalf("expected step_up_required, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do("/api/v1/auth/step-up", stale, `{"method":"password","password":"wrong1234"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong password, got %d", w.Code)
+	}
+	w := do("/api/v1/auth/step-up", stale, `{"method":"password","password":"Password1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("step-up: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	elevated, _ := out["accessToken"].(string)
+	if w := do("/api/v1/me/password", elevated, `{"newPassword":"Password2"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeviceBoundTokenNeedsAssertion(t *testing.T) {
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	devices := usecase.NewDeviceService(users, memory.NewDeviceRepository(store), notify.NewLogSender(logger), usecase.DeviceConfig{OTPTTL: 5 * time.Minute, OTPMaxAttempts: 3})
+	authSvc := usecase.NewAuthService(users, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute, TransferThresholds: map[string]int64{"KES": 5000000}}, nil, nil, devices, nil)
+	r := NewRouter(logger, Services{Auth: authSvc, Devices: devices}, jwtMgr, func(ctx context.Context) error { return nil })
+	id := signup(t, r)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	device := &auth.DeviceClaim{ID: "phone-0001", Key: base64.StdEncoding.EncodeToString(der)}
+	token, _ := auth.NewJWTManager("secret", "test").IssueDeviceToken(id, time.Hour, device, auth.MethodPassword)
+	me := func(assertion string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if assertion != "" {
+			req.Header.Set(auth.DeviceAssertionHeader, assertion)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := me(""); w.Code != http.StatusUnauthorized || !bytes.Contains(w.Body.Bytes(), []byte("device_assertion_invalid")) {
+		t.Fatalf("expected missing assertion rejected, got %d %s", w.Code, w.Body.String())
+	}
+	other, _ := auth.SignDeviceAssertion(key, time.Now(), http.MethodGet, "/api/v1/me/accounts", nil, token)
+	if w := me(other); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected assertion for another path rejected, got %d", w.Code)
+	}
+	assertion, _ := auth.SignDeviceAssertion(key, time.Now(), http.MethodGet, "/api/v1/me", nil, token)
+	if w := me(assertion); w.Code != http.StatusOK {
+		t.Fatalf("expected signed request accepted, got %d %s", w.Code, w.Body.String())
+	}
+	if w := me(assertion); w.Code != http.StatusUnauthorized || !bytes.Contains(w.Body.Bytes(), []byte("device_assertion_replayed")) {
+		t.Fatalf("expected replayed assertion rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	changePassword := func(signed, sent string) *httptest.ResponseRecorder {
+		assertion, _ := auth.SignDeviceAssertion(key, time.Now(), http.MethodPost, "/api/v1/me/password", []byte(signed), token)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/me/password", strings.NewReader(sent))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(auth.DeviceAssertionHeader, assertion)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := changePassword(`{"newPassword":"Password2"}`, `{"newPassword":"Hijacked9"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected assertion for another body rejected, got %d", w.Code)
+	}
+	if w := changePassword(`{"newPassword":"Password2"}`, `{"newPassword":"Password2"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("expected signed body passed on to the handler, got %d %s", w.Code, w.Body.String())
+	}
+}
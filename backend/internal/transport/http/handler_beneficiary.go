@@ -0,0 +1,123 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type BeneficiaryHandler struct{ beneficiaryService *usecase.BeneficiaryService }
+
+func NewBeneficiaryHandler(beneficiaryService *usecase.BeneficiaryService) *BeneficiaryHandler {
+	return &BeneficiaryHandler{beneficiaryService: beneficiaryService}
+}
+
+type createBeneficiaryRequest struct {
+	Type          string `json:"type"`
+	Nickname      string `json:"nickname"`
+	Recipient     string `json:"recipient"`
+	Phone         string `json:"phone"`
+	BankCode      string `json:"bankCode"`
+	AccountNumber string `json:"accountNumber"`
+	AccountName   string `json:"accountName"`
+}
+type renameBeneficiaryRequest struct {
+	Nickname string `json:"nickname"`
+}
+type beneficiaryResponse struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Nickname        string `json:"nickname"`
+	RecipientID     string `json:"recipientId,omitempty"`
+	Phone           string `json:"phone,omitempty"`
+	BankCode        string `json:"bankCode,omitempty"`
+	AccountNumber   string `json:"accountNumber,omitempty"`
+	AccountName     string `json:"accountName,omitempty"`
+	Verification    string `json:"verification"`
+	CoolingOff      bool   `json:"coolingOff"`
+	CoolingOffUntil string `json:"coolingOffUntil"`
+	CreatedAt       string `json:"createdAt"`
+}
+
+func mapBeneficiary(b *domain.Beneficiary) beneficiaryResponse {
+	return beneficiaryResponse{ID: b.ID, Type: string(b.Type), Nickname: b.Nickname, RecipientID: b.RecipientID, Phone: b.Phone, BankCode: b.BankCode, AccountNumber: b.AccountNumber, AccountName: b.AccountName, Verification: string(b.Verification), CoolingOff: b.InCoolingOff(time.Now()), CoolingOffUntil: b.CoolingOffUntil.UTC().Format(time.RFC3339), CreatedAt: b.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *BeneficiaryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createBeneficiaryRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.CreateBeneficiaryInput{OwnerID: currentUserID(r), Type: req.Type, Nickname: req.Nickname, Recipient: req.Recipient, Phone: req.Phone, BankCode: req.BankCode, AccountNumber: req.AccountNumber, AccountName: req.AccountName}
+	b, fields, err := h.beneficiaryService.Create(r.Context(), in)
+	if err != nil {
+		writeBeneficiaryError(w, err, fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"beneficiary": mapBeneficiary(b)})
+}
+
+func (h *BeneficiaryHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := h.beneficiaryService.List(r.Context(), currentUserID(r))
+	if err != nil {
+		writeBeneficiaryError(w, err, nil)
+		return
+	}
+	out := make([]beneficiaryResponse, 0, len(list))
+	for _, b := range list {
+		out = append(out, mapBeneficiary(b))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"beneficiaries": out})
+}
+
+func (h *BeneficiaryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	b, err := h.beneficiaryService.Get(r.Context(), currentUserID(r), chi.URLParam(r, "beneficiaryID"))
+	if err != nil {
+		writeBeneficiaryError(w, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"beneficiary": mapBeneficiary(b)})
+}
+
+func (h *BeneficiaryHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	var req renameBeneficiaryRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	b, fields, err := h.beneficiaryService.Rename(r.Context(), currentUserID(r), chi.URLParam(r, "beneficiaryID"), req.Nickname)
+	if err != nil {
+		writeBeneficiaryError(w, err, fields)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"beneficiary": mapBeneficiary(b)})
+}
+
+func (h *BeneficiaryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.beneficiaryService.Delete(r.Context(), currentUserID(r), chi.URLParam(r, "beneficiaryID")); err != nil {
+		writeBeneficiaryError(w, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeBeneficiaryError(w http.ResponseWriter, err error, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid beneficiary payload", fields)
+	case errors.Is(err, domain.ErrRecipientNotFound):
+		writeError(w, http.StatusNotFound, "recipient_not_found", "recipient not found", nil)
+	case errors.Is(err, domain.ErrBeneficiaryNotFound):
+		writeError(w, http.StatusNotFound, "beneficiary_not_found", "beneficiary not found", nil)
+	case errors.Is(err, domain.ErrBeneficiaryExists):
+		writeError(w, http.StatusConflict, "beneficiary_exists", "beneficiary or nickname already saved", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
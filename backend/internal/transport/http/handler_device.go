@@ -0,0 +1,69 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type DeviceHandler struct{ deviceService *usecase.DeviceService }
+
+func NewDeviceHandler(deviceService *usecase.DeviceService) *DeviceHandler {
+	return &DeviceHandler{deviceService: deviceService}
+}
+
+type deviceResponse struct {
+	ID          string `json:"id"`
+	DeviceID    string `json:"deviceId"`
+	Platform    string `json:"platform"`
+	Trusted     bool   `json:"trusted"`
+	PushEnabled bool   `json:"pushEnabled"`
+	Current     bool   `json:"current"`
+	FirstSeenAt string `json:"firstSeenAt"`
+	LastSeenAt  string `json:"lastSeenAt"`
+}
+
+func mapDevice(d *domain.Device, current string) deviceResponse {
+	return deviceResponse{ID: d.ID, DeviceID: d.DeviceID, Platform: string(d.Platform), Trusted: d.Trusted, PushEnabled: d.PushToken != "", Current: d.DeviceID == current, FirstSeenAt: d.FirstSeenAt.UTC().Format(time.RFC3339), LastSeenAt: d.LastSeenAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *DeviceHandler) List(w http.ResponseWriter, r *http.Request) {
+	devices, err := h.deviceService.List(r.Context(), currentUserID(r))
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	current := ""
+	if d := currentDevice(r); d != nil {
+		current = d.ID
+	}
+	out := make([]deviceResponse, 0, len(devices))
+	for _, d := range devices {
+		out = append(out, mapDevice(d, current))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"devices": out})
+}
+
+func (h *DeviceHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := h.deviceService.Revoke(r.Context(), currentUserID(r), chi.URLParam(r, "deviceID")); err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeDeviceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDeviceNotFound):
+		writeError(w, http.StatusNotFound, "device_not_found", "device not found", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
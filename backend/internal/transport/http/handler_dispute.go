@@ -0,0 +1,239 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type DisputeHandler struct {
+	reversalService *usecase.ReversalService
+	disputeService  *usecase.DisputeService
+}
+
+func NewDisputeHandler(reversalService *usecase.ReversalService, disputeService *usecase.DisputeService) *DisputeHandler {
+	return &DisputeHandler{reversalService: reversalService, disputeService: disputeService}
+}
+
+type reversalRequest struct {
+	Reason string `json:"reason"`
+}
+type refundRequest struct {
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+}
+type openDisputeRequest struct {
+	EntryID string `json:"entryId"`
+	Amount  int64  `json:"amount"`
+	Reason  string `json:"reason"`
+}
+
+// evidenceRequest carries attachments base64-encoded in Data.
+type evidenceRequest struct {
+	Note        string `json:"note"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+}
+type resolveDisputeRequest struct {
+	Outcome string `json:"outcome"`
+	Note    string `json:"note"`
+}
+type evidenceResponse struct {
+	ID          string `json:"id"`
+	SubmittedBy string `json:"submittedBy"`
+	Note        string `json:"note,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	Size        int    `json:"size,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+}
+type disputeResponse struct {
+	ID                string             `json:"id"`
+	EntryID           string             `json:"entryId"`
+	Currency          string             `json:"currency"`
+	Amount            int64              `json:"amount"`
+	HeldAmount        int64              `json:"heldAmount"`
+	Reason            string             `json:"reason"`
+	Status            string             `json:"status"`
+	Evidence          []evidenceResponse `json:"evidence"`
+	ResolutionEntryID string             `json:"resolutionEntryId,omitempty"`
+	ResolutionNote    string             `json:"resolutionNote,omitempty"`
+	CreatedAt         string             `json:"createdAt"`
+	UpdatedAt         string             `json:"updatedAt"`
+	ResolvedAt        string             `json:"resolvedAt,omitempty"`
+}
+
+func mapEvidence(e domain.Evidence) evidenceResponse {
+	return evidenceResponse{ID: e.ID, SubmittedBy: e.SubmittedBy, Note: e.Note, FileName: e.FileName, ContentType: e.ContentType, SHA256: e.SHA256, Size: e.Size, CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func mapDispute(d *domain.Dispute) disputeResponse {
+	evidence := make([]evidenceResponse, 0, len(d.Evidence))
+	for _, e := range d.Evidence {
+		evidence = append(evidence, mapEvidence(e))
+	}
+	out := disputeResponse{ID: d.ID, EntryID: d.EntryID, Currency: d.Currency, Amount: d.Amount, HeldAmount: d.HeldAmount, Reason: d.Reason, Status: string(d.Status), Evidence: evidence, ResolutionEntryID: d.ResolutionEntryID, ResolutionNote: d.ResolutionNote, CreatedAt: d.CreatedAt.UTC().Format(time.RFC3339), UpdatedAt: d.UpdatedAt.UTC().Format(time.RFC3339)}
+	if !d.ResolvedAt.IsZero() {
+		out.ResolvedAt = d.ResolvedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func mapEntry(e *domain.JournalEntry) map[string]any {
+	return map[string]any{"entryId": e.ID, "kind": e.Kind, "reversalOf": e.ReversalOf, "createdAt": e.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *DisputeHandler) Reverse(w http.ResponseWriter, r *http.Request) {
+	var req reversalRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	entry, fields, err := h.reversalService.Reverse(r.Context(), usecase.ReverseInput{ActorID: currentUserID(r), EntryID: chi.URLParam(r, "entryID"), Reason: req.Reason})
+	if err != nil {
+		writeDisputeError(w, err, "invalid reversal payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"reversal": mapEntry(entry)})
+}
+
+func (h *DisputeHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	var req refundRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.RefundInput{ActorID: currentUserID(r), EntryID: chi.URLParam(r, "entryID"), Amount: req.Amount, Reason: req.Reason}
+	entry, fields, err := h.reversalService.Refund(r.Context(), in)
+	if err != nil {
+		writeDisputeError(w, err, "invalid refund payload", fields)
+		return
+	}
+	refund := mapEntry(entry)
+	refund["amount"] = req.Amount
+	writeJSON(w, http.StatusCreated, map[string]any{"refund": refund})
+}
+
+func (h *DisputeHandler) Open(w http.ResponseWriter, r *http.Request) {
+	var req openDisputeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	dispute, fields, err := h.disputeService.Open(r.Context(), usecase.OpenDisputeInput{PayerID: currentUserID(r), EntryID: req.EntryID, Amount: req.Amount, Reason: req.Reason})
+	if err != nil {
+		writeDisputeError(w, err, "invalid dispute payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"dispute": mapDispute(dispute)})
+}
+
+func (h *DisputeHandler) List(w http.ResponseWriter, r *http.Request) {
+	disputes, err := h.disputeService.List(r.Context(), currentUserID(r), domain.DisputeStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		writeDisputeError(w, err, "", nil)
+		return
+	}
+	out := make([]disputeResponse, 0, len(disputes))
+	for _, d := range disputes {
+		out = append(out, mapDispute(d))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"disputes": out})
+}
+
+func (h *DisputeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	dispute, err := h.disputeService.Get(r.Context(), currentUserID(r), chi.URLParam(r, "disputeID"))
+	if err != nil {
+		writeDisputeError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dispute": mapDispute(dispute)})
+}
+
+func (h *DisputeHandler) AddEvidence(w http.ResponseWriter, r *http.Request) {
+	var req evidenceRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.AddEvidenceInput{ActorID: currentUserID(r), DisputeID: chi.URLParam(r, "disputeID"), Note: req.Note, FileName: req.FileName, ContentType: req.ContentType, Data: req.Data}
+	evidence, fields, err := h.disputeService.AddEvidence(r.Context(), in)
+	if err != nil {
+		writeDisputeError(w, err, "invalid evidence payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"evidence": mapEvidence(*evidence)})
+}
+
+// DownloadEvidence streams an attachment with its stored content type.
+func (h *DisputeHandler) DownloadEvidence(w http.ResponseWriter, r *http.Request) {
+	evidence, err := h.disputeService.GetEvidence(r.Context(), currentUserID(r), chi.URLParam(r, "disputeID"), chi.URLParam(r, "evidenceID"))
+	if err != nil {
+		writeDisputeError(w, err, "", nil)
+		return
+	}
+	if len(evidence.Data) == 0 {
+		writeError(w, http.StatusNotFound, "evidence_not_found", "evidence has no attachment", nil)
+		return
+	}
+	w.Header().Set("Content-Type", evidence.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(evidence.Data)))
+	w.Header().Set("Content-Disposition", "attachment")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(evidence.Data)
+}
+
+func (h *DisputeHandler) StartReview(w http.ResponseWriter, r *http.Request) {
+	dispute, err := h.disputeService.StartReview(r.Context(), currentUserID(r), chi.URLParam(r, "disputeID"))
+	if err != nil {
+		writeDisputeError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dispute": mapDispute(dispute)})
+}
+
+func (h *DisputeHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	var req resolveDisputeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.ResolveDisputeInput{ActorID: currentUserID(r), DisputeID: chi.URLParam(r, "disputeID"), Outcome: req.Outcome, Note: req.Note}
+	dispute, fields, err := h.disputeService.Resolve(r.Context(), in)
+	if err != nil {
+		writeDisputeError(w, err, "invalid resolution payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dispute": mapDispute(dispute)})
+}
+
+func writeDisputeError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrEntryNotFound):
+		writeError(w, http.StatusNotFound, "entry_not_found", "transaction not found", nil)
+	case errors.Is(err, domain.ErrDisputeNotFound):
+		writeError(w, http.StatusNotFound, "dispute_not_found", "dispute not found", nil)
+	case errors.Is(err, domain.ErrEvidenceNotFound):
+		writeError(w, http.StatusNotFound, "evidence_not_found", "evidence not found", nil)
+	case errors.Is(err, domain.ErrNotReversible):
+		writeError(w, http.StatusConflict, "not_reversible", "transaction cannot be reversed or refunded", nil)
+	case errors.Is(err, domain.ErrDisputeExists):
+		writeError(w, http.StatusConflict, "dispute_exists", "transaction already has an open dispute", nil)
+	case errors.Is(err, domain.ErrInvalidTransition):
+		writeError(w, http.StatusConflict, "invalid_transition", "dispute is not in a state that allows this", nil)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		writeError(w, http.StatusUnprocessableEntity, "insufficient_funds", "insufficient funds", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+)
+
+type FeeHandler struct{ feeService *usecase.FeeService }
+
+func NewFeeHandler(feeService *usecase.FeeService) *FeeHandler {
+	return &FeeHandler{feeService: feeService}
+}
+
+type feePreviewRequest struct {
+	Type      string `json:"type"`
+	Currency  string `json:"currency"`
+	Amount    int64  `json:"amount"`
+	PromoCode string `json:"promoCode"`
+}
+type feePreviewResponse struct {
+	Type     string `json:"type"`
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+	Fee      int64  `json:"fee"`
+	GrossFee int64  `json:"grossFee"`
+	Waived   int64  `json:"waived"`
+	Total    int64  `json:"total"`
+	Promo    string `json:"promo,omitempty"`
+}
+
+func (h *FeeHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var req feePreviewRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	p, fields, err := h.feeService.Preview(r.Context(), usecase.PreviewFeeInput(req))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid fee preview payload", fields)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"fee": feePreviewResponse{Type: string(p.Type), Currency: p.Currency, Amount: p.Amount, Fee: p.Fee, GrossFee: p.Gross, Waived: p.Waived, Total: p.Total, Promo: p.Promo}})
+}
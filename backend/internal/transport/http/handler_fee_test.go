@@ -0,0 +1,50 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+func TestFeePreviewRequiresAuthAndQuotesCharge(t *testing.T) {
+	repo := memory.NewUserRepository(memory.NewStore())
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	schedule := &fees.Schedule{Rules: []fees.Rule{{Type: fees.TypeWithdrawal, Currency: "KES", Flat: 2900, PercentBps: 50, Max: 30900}}}
+	services := Services{Auth: usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute, TransferThresholds: map[string]int64{"KES": 5000000}}, nil, nil, nil, nil), Fees: usecase.NewFeeService(schedule)}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+
+	body := []byte(`{"type":"withdrawal","currency":"KES","amount":100000}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/fees/preview", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+
+	tok, _ := jwtMgr.IssueAccessToken("u1", time.Hour)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/fees/preview", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+tok)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var out struct {
+		Fee feePreviewResponse `json:"fee"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	if out.Fee.Fee != 3400 || out.Fee.Total != 103400 {
+		t.Fatalf("unexpected preview: %#v", out.Fee)
+	}
+}
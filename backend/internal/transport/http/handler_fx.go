@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+)
+
+type FXHandler struct{ fxService *usecase.FXService }
+
+func NewFXHandler(fxService *usecase.FXService) *FXHandler {
+	return &FXHandler{fxService: fxService}
+}
+
+type quoteRequest struct {
+	SellCurrency string `json:"sellCurrency"`
+	BuyCurrency  string `json:"buyCurrency"`
+	SellAmount   int64  `json:"sellAmount"`
+	PromoCode    string `json:"promoCode"`
+}
+type conversionRequest struct {
+	QuoteID string `json:"quoteId"`
+}
+type quoteResponse struct {
+	ID           string `json:"id"`
+	SellCurrency string `json:"sellCurrency"`
+	BuyCurrency  string `json:"buyCurrency"`
+	SellAmount   int64  `json:"sellAmount"`
+	BuyAmount    int64  `json:"buyAmount"`
+	Fee          int64  `json:"fee"`
+	MidRate      string `json:"midRate"`
+	Rate         string `json:"rate"`
+	SpreadBps    int    `json:"spreadBps"`
+	Status       string `json:"status"`
+	ExpiresAt    string `json:"expiresAt"`
+}
+
+func mapQuote(q *domain.FXQuote) quoteResponse {
+	return quoteResponse{ID: q.ID, SellCurrency: q.SellCurrency, BuyCurrency: q.BuyCurrency, SellAmount: q.SellAmount, BuyAmount: q.BuyAmount, Fee: q.Fee, MidRate: q.MidRate, Rate: q.Rate, SpreadBps: q.SpreadBps, Status: string(q.Status), ExpiresAt: q.ExpiresAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *FXHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	var req quoteRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	quote, fields, err := h.fxService.Quote(r.Context(), usecase.QuoteInput{UserID: currentUserID(r), SellCurrency: req.SellCurrency, BuyCurrency: req.BuyCurrency, SellAmount: req.SellAmount, PromoCode: req.PromoCode})
+	if err != nil {
+		writeFXError(w, err, fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"quote": mapQuote(quote)})
+}
+
+func (h *FXHandler) Convert(w http.ResponseWriter, r *http.Request) {
+	var req conversionRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, err := h.fxService.Convert(r.Context(), usecase.ConvertInput{UserID: currentUserID(r), QuoteID: req.QuoteID})
+	if err != nil {
+		writeFXError(w, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"quote": mapQuote(res.Quote), "entryId": res.Entry.ID})
+}
+
+func writeFXError(w http.ResponseWriter, err error, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid quote request", fields)
+	case errors.Is(err, domain.ErrQuoteNotFound):
+		writeError(w, http.StatusNotFound, "quote_not_found", "quote not found", nil)
+	case errors.Is(err, domain.ErrQuoteExpired):
+		writeError(w, http.StatusConflict, "quote_expired", "quote has expired", nil)
+	case errors.Is(err, domain.ErrQuoteExecuted):
+		writeError(w, http.StatusConflict, "quote_executed", "quote has already been executed", nil)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		writeError(w, http.StatusUnprocessableEntity, "insufficient_funds", "insufficient funds", nil)
+	case errors.Is(err, domain.ErrAccountRestricted):
+		writeError(w, http.StatusForbidden, "account_restricted", "account cannot move money", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
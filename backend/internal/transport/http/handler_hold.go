@@ -0,0 +1,118 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type HoldHandler struct{ holdService *usecase.HoldService }
+
+func NewHoldHandler(holdService *usecase.HoldService) *HoldHandler {
+	return &HoldHandler{holdService: holdService}
+}
+
+type authorizePaymentRequest struct {
+	MerchantID string `json:"merchantId"`
+	Amount     int64  `json:"amount"`
+	PIN        string `json:"pin"`
+}
+type captureHoldRequest struct {
+	Amount int64 `json:"amount"`
+}
+type holdResponse struct {
+	ID             string `json:"id"`
+	AccountID      string `json:"accountId"`
+	Kind           string `json:"kind"`
+	Status         string `json:"status"`
+	Currency       string `json:"currency"`
+	Amount         int64  `json:"amount"`
+	CapturedAmount int64  `json:"capturedAmount,omitempty"`
+	EntryID        string `json:"entryId,omitempty"`
+	MerchantID     string `json:"merchantId,omitempty"`
+	ExpiresAt      string `json:"expiresAt,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+func mapHold(h *domain.Hold) holdResponse {
+	res := holdResponse{ID: h.ID, AccountID: h.AccountID, Kind: string(h.Kind), Status: string(h.Status), Currency: h.Currency, Amount: h.Amount, CapturedAmount: h.CapturedAmount, EntryID: h.EntryID, MerchantID: h.Metadata["merchantId"], CreatedAt: h.CreatedAt.UTC().Format(time.RFC3339)}
+	if !h.ExpiresAt.IsZero() {
+		res.ExpiresAt = h.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return res
+}
+
+func (h *HoldHandler) AuthorizePayment(w http.ResponseWriter, r *http.Request) {
+	var req authorizePaymentRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	hold, fields, err := h.holdService.AuthorizeMerchantPayment(r.Context(), usecase.AuthorizePaymentInput{PayerID: currentUserID(r), MerchantID: req.MerchantID, Amount: req.Amount, PIN: req.PIN})
+	if err != nil {
+		writeHoldError(w, err, "invalid authorization payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"hold": mapHold(hold)})
+}
+
+func (h *HoldHandler) Get(w http.ResponseWriter, r *http.Request) {
+	hold, err := h.holdService.Get(r.Context(), currentUserID(r), chi.URLParam(r, "holdID"))
+	if err != nil {
+		writeHoldError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"hold": mapHold(hold)})
+}
+
+func (h *HoldHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	var req captureHoldRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	hold, _, fields, err := h.holdService.Capture(r.Context(), usecase.CaptureHoldInput{ActorID: currentUserID(r), HoldID: chi.URLParam(r, "holdID"), Amount: req.Amount})
+	if err != nil {
+		writeHoldError(w, err, "invalid capture payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"hold": mapHold(hold)})
+}
+
+func (h *HoldHandler) Void(w http.ResponseWriter, r *http.Request) {
+	hold, err := h.holdService.Void(r.Context(), currentUserID(r), chi.URLParam(r, "holdID"))
+	if err != nil {
+		writeHoldError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"hold": mapHold(hold)})
+}
+
+func writeHoldError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	if writePINVerifyError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrHoldNotFound):
+		writeError(w, http.StatusNotFound, "hold_not_found", "hold not found", nil)
+	case errors.Is(err, domain.ErrMerchantNotFound):
+		writeError(w, http.StatusNotFound, "merchant_not_found", "merchant not found", nil)
+	case errors.Is(err, domain.ErrHoldNotActive):
+		writeError(w, http.StatusConflict, "hold_not_active", "hold was already captured, voided or expired", nil)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		writeError(w, http.StatusUnprocessableEntity, "insufficient_funds", "insufficient funds", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrAccountRestricted):
+		writeError(w, http.StatusForbidden, "account_restricted", "account cannot move money", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type MerchantHandler struct{ merchantService *usecase.MerchantService }
+
+func NewMerchantHandler(merchantService *usecase.MerchantService) *MerchantHandler {
+	return &MerchantHandler{merchantService: merchantService}
+}
+
+type createMerchantRequest struct {
+	Name         string `json:"name"`
+	City         string `json:"city"`
+	CategoryCode string `json:"categoryCode"`
+	CountryCode  string `json:"countryCode"`
+	Currency     string `json:"currency"`
+}
+type generateQRRequest struct {
+	Amount    int64  `json:"amount"`
+	Reference string `json:"reference"`
+}
+type payQRRequest struct {
+	Payload string `json:"payload"`
+	Amount  int64  `json:"amount"`
+	PIN     string `json:"pin"`
+}
+type merchantResponse struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	City         string `json:"city"`
+	CategoryCode string `json:"categoryCode"`
+	CountryCode  string `json:"countryCode"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+	CreatedAt    string `json:"createdAt"`
+}
+type settlementDayResponse struct {
+	Date     string `json:"date"`
+	Count    int    `json:"count"`
+	Amount   int64  `json:"amount"`
+	Refunded int64  `json:"refunded"`
+}
+type settlementPaymentResponse struct {
+	EntryID    string `json:"entryId"`
+	Amount     int64  `json:"amount"`
+	Reference  string `json:"reference,omitempty"`
+	ReversalOf string `json:"reversalOf,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+func mapSettlementPayments(lines []usecase.SettlementPayment) []settlementPaymentResponse {
+	out := make([]settlementPaymentResponse, 0, len(lines))
+	for _, p := range lines {
+		out = append(out, settlementPaymentResponse{EntryID: p.EntryID, Amount: p.Amount, Reference: p.Reference, ReversalOf: p.ReversalOf, CreatedAt: p.CreatedAt.Format(time.RFC3339)})
+	}
+	return out
+}
+
+func mapMerchant(m *domain.Merchant) merchantResponse {
+	return merchantResponse{ID: m.ID, Name: m.Name, City: m.City, CategoryCode: m.CategoryCode, CountryCode: m.CountryCode, Currency: m.Currency, Status: string(m.Status), CreatedAt: m.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *MerchantHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createMerchantRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.CreateMerchantInput{OwnerID: currentUserID(r), Name: req.Name, City: req.City, CategoryCode: req.CategoryCode, CountryCode: req.CountryCode, Currency: req.Currency}
+	merchant, fields, err := h.merchantService.Create(r.Context(), in)
+	if err != nil {
+		writeMerchantError(w, err, "invalid merchant payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"merchant": mapMerchant(merchant)})
+}
+
+func (h *MerchantHandler) List(w http.ResponseWriter, r *http.Request) {
+	merchants, err := h.merchantService.ListMine(r.Context(), currentUserID(r))
+	if err != nil {
+		writeMerchantError(w, err, "", nil)
+		return
+	}
+	out := make([]merchantResponse, 0, len(merchants))
+	for _, m := range merchants {
+		out = append(out, mapMerchant(m))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"merchants": out})
+}
+
+func (h *MerchantHandler) GenerateQR(w http.ResponseWriter, r *http.Request) {
+	var req generateQRRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.GenerateQRInput{OwnerID: currentUserID(r), MerchantID: chi.URLParam(r, "merchantID"), Amount: req.Amount, Reference: req.Reference}
+	qr, fields, err := h.merchantService.GenerateQR(r.Context(), in)
+	if err != nil {
+		writeMerchantError(w, err, "invalid QR request", fields)
+		return
+	}
+	kind := "static"
+	if qr.Dynamic {
+		kind = "dynamic"
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"payload": qr.Payload, "type": kind, "amount": qr.Amount, "currency": qr.Merchant.Currency, "reference": qr.Reference})
+}
+
+func (h *MerchantHandler) PayQR(w http.ResponseWriter, r *http.Request) {
+	var req payQRRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, fields, err := h.merchantService.PayQR(r.Context(), usecase.PayQRInput{PayerID: currentUserID(r), Payload: req.Payload, Amount: req.Amount, PIN: req.PIN})
+	if err != nil {
+		writeMerchantError(w, err, "invalid QR payment", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"payment": map[string]any{"entryId": res.Entry.ID, "merchant": mapMerchant(res.Merchant), "amount": res.Amount, "currency": res.Currency, "createdAt": res.Entry.CreatedAt.UTC().Format(time.RFC3339)},
+	})
+}
+
+// SettlementReport accepts from/to as RFC 3339 timestamps or YYYY-MM-DD
+// dates and defaults to the current UTC day.
+func (h *MerchantHandler) SettlementReport(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseReportWindow(w, r)
+	if !ok {
+		return
+	}
+	in := usecase.SettlementReportInput{OwnerID: currentUserID(r), MerchantID: chi.URLParam(r, "merchantID"), From: from, To: to}
+	report, fields, err := h.merchantService.SettlementReport(r.Context(), in)
+	if err != nil {
+		writeMerchantError(w, err, "invalid report window", fields)
+		return
+	}
+	days := make([]settlementDayResponse, 0, len(report.Days))
+	for _, d := range report.Days {
+		days = append(days, settlementDayResponse(d))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"merchant":       mapMerchant(report.Merchant),
+		"currency":       report.Merchant.Currency,
+		"from":           report.From.Format(time.RFC3339),
+		"to":             report.To.Format(time.RFC3339),
+		"paymentCount":   report.PaymentCount,
+		"grossAmount":    report.GrossAmount,
+		"refundCount":    report.RefundCount,
+		"refundedAmount": report.RefundedAmount,
+		"netAmount":      report.NetAmount,
+		"balance":        report.Balance,
+		"days":           days,
+		"payments":       mapSettlementPayments(report.Payments),
+		"refunds":        mapSettlementPayments(report.Refunds),
+	})
+}
+
+func parseReportWindow(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today, today.Add(24*time.Hour)
+	fields := domain.FieldErrors{}
+	for key, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := r.URL.Query().Get(key)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", v)
+		}
+		if err != nil {
+			fields[key] = "must be RFC 3339 or YYYY-MM-DD"
+			continue
+		}
+		*dst = t
+	}
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid report window", fields)
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+func writeMerchantError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	if writePINVerifyError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrInvalidQRPayload):
+		writeError(w, http.StatusUnprocessableEntity, "invalid_qr_payload", "QR code could not be accepted", fields)
+	case errors.Is(err, domain.ErrMerchantNotFound):
+		writeError(w, http.StatusNotFound, "merchant_not_found", "merchant not found", nil)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		writeError(w, http.StatusUnprocessableEntity, "insufficient_funds", "insufficient funds", nil)
+	case errors.Is(err, domain.ErrDuplicateEntry):
+		writeError(w, http.StatusConflict, "already_paid", "this QR code has already been paid", nil)
+	case errors.Is(err, domain.ErrAccountRestricted):
+		writeError(w, http.StatusForbidden, "account_restricted", "account cannot move money", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,505 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxOAuthFormBytes caps a token request body.
+const maxOAuthFormBytes = 16 << 10
+
+// OAuthHandler serves the OAuth2 endpoints. Its requests and errors follow
+// RFC 6749 rather than the API's JSON error contract, so standard OAuth
+// client libraries work against it. oauth is nil when only the
+// client-credentials grant is offered.
+type OAuthHandler struct {
+	clients *usecase.APIClientService
+	oauth   *usecase.OAuthService
+	audit   *auditor
+}
+
+func NewOAuthHandler(clients *usecase.APIClientService, oauth *usecase.OAuthService, auditService *usecase.AuditService, logger *slog.Logger) *OAuthHandler {
+	return &OAuthHandler{clients: clients, oauth: oauth, audit: &auditor{service: auditService, logger: logger}}
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// oauthAuthorizeErrorResponse adds where the consent screen should send
+// the user; RedirectTo is empty when the app cannot be trusted with the
+// error.
+type oauthAuthorizeErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	RedirectTo       string `json:"redirectTo,omitempty"`
+}
+
+type authorizeDecisionRequest struct {
+	Approve bool `json:"approve"`
+}
+
+type oauthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+}
+
+// oidcDiscoveryResponse is the OpenID Connect Discovery 1.0 provider
+// metadata.
+type oidcDiscoveryResponse struct {
+	Issuer                            string         `json:"issuer"`
+	AuthorizationEndpoint             string         `json:"authorization_endpoint"`
+	TokenEndpoint                     string         `json:"token_endpoint"`
+	UserinfoEndpoint                  string         `json:"userinfo_endpoint"`
+	JWKSURI                           string         `json:"jwks_uri"`
+	RevocationEndpoint                string         `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string         `json:"introspection_endpoint"`
+	ScopesSupported                   []domain.Scope `json:"scopes_supported"`
+	ResponseTypesSupported            []string       `json:"response_types_supported"`
+	GrantTypesSupported               []string       `json:"grant_types_supported"`
+	SubjectTypesSupported             []string       `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string       `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string       `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string       `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string       `json:"claims_supported"`
+}
+
+// userInfoResponse leaves out the claims of scopes the app was not
+// granted; a verified flag is sent with its claim.
+type userInfoResponse struct {
+	Sub                 string `json:"sub"`
+	PreferredUsername   string `json:"preferred_username,omitempty"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       *bool  `json:"email_verified,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool  `json:"phone_number_verified,omitempty"`
+}
+
+type consentResponse struct {
+	ID         string         `json:"id"`
+	ClientID   string         `json:"clientId"`
+	ClientName string         `json:"clientName"`
+	Scopes     []domain.Scope `json:"scopes"`
+	CreatedAt  string         `json:"createdAt"`
+	UpdatedAt  string         `json:"updatedAt"`
+}
+
+// Token is POST /oauth/token. The client authenticates with HTTP Basic
+// (client ID and API key) or with client_id and client_secret form fields,
+// not both; a public client sends only client_id.
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	form, client, ok := parseOAuthForm(w, r)
+	if !ok {
+		return
+	}
+	switch grantType := form.Get("grant_type"); {
+	case grantType == "client_credentials":
+		h.clientCredentials(w, r, form, client)
+	case grantType == "authorization_code" && h.oauth != nil:
+		res, err := h.oauth.ExchangeCode(r.Context(), usecase.CodeExchangeInput{ClientAuth: client, Code: form.Get("code"), RedirectURI: form.Get("redirect_uri"), CodeVerifier: form.Get("code_verifier")})
+		h.writeGrantResult(w, r, grantType, client.ClientID, res, err)
+	case grantType == "refresh_token" && h.oauth != nil:
+		res, err := h.oauth.Refresh(r.Context(), usecase.RefreshInput{ClientAuth: client, RefreshToken: form.Get("refresh_token"), Scope: form.Get("scope")})
+		h.writeGrantResult(w, r, grantType, client.ClientID, res, err)
+	case grantType == "":
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+func (h *OAuthHandler) clientCredentials(w http.ResponseWriter, r *http.Request, form url.Values, client usecase.ClientAuth) {
+	res, err := h.clients.IssueToken(r.Context(), usecase.ClientCredentialsInput{ClientID: client.ClientID, Secret: client.Secret, Scope: form.Get("scope"), IP: client.IP})
+	if err != nil {
+		var c *domain.APIClient
+		if res != nil {
+			c = res.Client
+		}
+		h.auditClientAuthFailed(r, client.ClientID, c, err)
+		writeOAuthTokenError(w, err)
+		return
+	}
+	scope := domain.ScopeString(res.Scopes)
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditClientTokenIssued, ActorID: res.Client.ID, TargetType: "api_client", TargetID: res.Client.ID, Details: map[string]string{"keyPrefix": res.KeyPrefix, "scope": scope}})
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, oauthTokenResponse{AccessToken: res.AccessToken, TokenType: "Bearer", ExpiresIn: int64(res.ExpiresIn.Seconds()), Scope: scope})
+}
+
+// writeGrantResult answers an authorization code or refresh token grant.
+// A replayed code or refresh token has already revoked its grant and is
+// audited as such.
+func (h *OAuthHandler) writeGrantResult(w http.ResponseWriter, r *http.Request, grantType, clientID string, res *usecase.OAuthTokenResult, err error) {
+	if err != nil {
+		var client *domain.APIClient
+		if res != nil {
+			client = res.Client
+		}
+		if errors.Is(err, domain.ErrTokenReused) && client != nil {
+			details := map[string]string{"grantType": grantType}
+			if res.Grant != nil {
+				details["grantId"] = res.Grant.ID
+			}
+			h.audit.record(r, domain.AuditRecord{Action: domain.AuditOAuthTokenReused, ActorID: client.ID, TargetType: "api_client", TargetID: client.ID, Details: details})
+		}
+		h.auditClientAuthFailed(r, clientID, client, err)
+		writeOAuthTokenError(w, err)
+		return
+	}
+	scope := domain.ScopeString(res.Scopes)
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditOAuthTokenIssued, ActorID: res.Client.ID, TargetType: "user", TargetID: res.Grant.UserID, Details: map[string]string{"grantType": grantType, "grantId": res.Grant.ID, "scope": scope}})
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, oauthTokenResponse{AccessToken: res.AccessToken, TokenType: "Bearer", ExpiresIn: int64(res.ExpiresIn.Seconds()), RefreshToken: res.RefreshToken, IDToken: res.IDToken, Scope: scope})
+}
+
+// AuthorizePreview is GET /oauth/authorize. The consent screen passes the
+// app's authorization request through unchanged with the user's token and
+// shows the app and scopes returned.
+func (h *OAuthHandler) AuthorizePreview(w http.ResponseWriter, r *http.Request) {
+	p, err := h.oauth.ValidateAuthorization(r.Context(), authorizationRequest(r))
+	if err != nil {
+		writeAuthorizeError(w, p, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"client":      map[string]string{"id": p.Client.ID, "name": p.Client.Name},
+		"scopes":      p.Scopes,
+		"redirectUri": p.RedirectURI,
+		"consented":   p.Consented,
+	})
+}
+
+// Authorize is POST /oauth/authorize with the same query as the preview
+// and the user's decision as the body. The response says where to send the
+// user: back to the app with a code, or with access_denied.
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	var req authorizeDecisionRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, err := h.oauth.Authorize(r.Context(), authorizationRequest(r), req.Approve)
+	if err != nil {
+		var p *usecase.AuthorizationPreview
+		if res != nil {
+			p = &res.AuthorizationPreview
+		}
+		writeAuthorizeError(w, p, err)
+		return
+	}
+	if res.Code == "" {
+		writeJSON(w, http.StatusOK, map[string]string{"redirectTo": authorizationRedirect(res.RedirectURI, url.Values{"error": {"access_denied"}}, res.State)})
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditOAuthConsentGranted, ActorID: currentUserID(r), TargetType: "api_client", TargetID: res.Client.ID, Details: map[string]string{"scope": domain.ScopeString(res.Scopes)}})
+	writeJSON(w, http.StatusOK, map[string]string{"redirectTo": authorizationRedirect(res.RedirectURI, url.Values{"code": {res.Code}}, res.State)})
+}
+
+// Revoke is POST /oauth/revoke (RFC 7009). It answers 200 for any token,
+// known or not, once the client has authenticated.
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	form, client, ok := parseOAuthForm(w, r)
+	if !ok {
+		return
+	}
+	if form.Get("token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+	c, grant, err := h.oauth.Revoke(r.Context(), client, form.Get("token"))
+	if err != nil {
+		h.auditClientAuthFailed(r, client.ClientID, c, err)
+		writeOAuthTokenError(w, err)
+		return
+	}
+	if grant != nil {
+		h.audit.record(r, domain.AuditRecord{Action: domain.AuditOAuthTokenRevoked, ActorID: c.ID, TargetType: "user", TargetID: grant.UserID, Details: map[string]string{"grantId": grant.ID}})
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// Introspect is POST /oauth/introspect (RFC 7662). A client may only
+// introspect its own tokens.
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	form, client, ok := parseOAuthForm(w, r)
+	if !ok {
+		return
+	}
+	if form.Get("token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+	c, info, err := h.oauth.Introspect(r.Context(), client, form.Get("token"))
+	if err != nil {
+		h.auditClientAuthFailed(r, client.ClientID, c, err)
+		writeOAuthTokenError(w, err)
+		return
+	}
+	out := oauthIntrospectionResponse{Active: info.Active}
+	if info.Active {
+		out.Scope, out.ClientID, out.Sub, out.TokenType = domain.ScopeString(info.Scopes), info.ClientID, info.Subject, info.TokenType
+		out.Exp, out.Iat = info.ExpiresAt.Unix(), info.IssuedAt.Unix()
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, out)
+}
+
+// Discovery is GET /.well-known/openid-configuration.
+func (h *OAuthHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	p := h.oauth.Provider()
+	if p == nil {
+		writeError(w, http.StatusNotFound, "not_found", "openid connect is not enabled", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, oidcDiscoveryResponse{
+		Issuer:                            p.Issuer,
+		AuthorizationEndpoint:             p.AuthorizationEndpoint,
+		TokenEndpoint:                     p.Issuer + "/oauth/token",
+		UserinfoEndpoint:                  p.Issuer + "/userinfo",
+		JWKSURI:                           p.Issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:                p.Issuer + "/oauth/revoke",
+		IntrospectionEndpoint:             p.Issuer + "/oauth/introspect",
+		ScopesSupported:                   p.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{auth.IDTokenAlg},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   p.Claims,
+	})
+}
+
+// JWKS is GET /.well-known/jwks.json: the keys ID tokens are verified with.
+func (h *OAuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]auth.JWK{"keys": h.oauth.JWKS()})
+}
+
+// UserInfo is GET or POST /userinfo (OpenID Connect Core section 5.3). It
+// only answers app tokens granted openid; the route's middleware has
+// already checked the grant.
+func (h *OAuthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	claims, _ := r.Context().Value(ctxKeyClaims{}).(*auth.Claims)
+	if claims == nil || !claims.Delegated() {
+		writeError(w, http.StatusForbidden, "insufficient_scope", "userinfo needs an app token with scope openid", nil)
+		return
+	}
+	info, err := h.oauth.UserInfo(r.Context(), claims.Sub, domain.NormalizeScopes([]string{claims.Scope}))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidScope):
+			writeError(w, http.StatusForbidden, "insufficient_scope", "missing scope openid", nil)
+		case errors.Is(err, domain.ErrUnauthorized):
+			writeError(w, http.StatusUnauthorized, "unauthorized", "token revoked", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+		}
+		return
+	}
+	out := userInfoResponse{Sub: info.Subject, PreferredUsername: info.PreferredUsername, Email: info.Email, PhoneNumber: info.PhoneNumber}
+	if info.Email != "" {
+		out.EmailVerified = &info.EmailVerified
+	}
+	if info.PhoneNumber != "" {
+		out.PhoneNumberVerified = &info.PhoneNumberVerified
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, out)
+}
+
+// ListConsents is GET /me/consents: the apps the user has let act for them.
+func (h *OAuthHandler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	consents, err := h.oauth.ListConsents(r.Context(), currentUserID(r))
+	if err != nil {
+		writeConsentError(w, err)
+		return
+	}
+	out := make([]consentResponse, 0, len(consents))
+	for _, c := range consents {
+		item := consentResponse{ID: c.Consent.ID, ClientID: c.Consent.ClientID, Scopes: c.Consent.Scopes, CreatedAt: c.Consent.CreatedAt.Format(time.RFC3339), UpdatedAt: c.Consent.UpdatedAt.Format(time.RFC3339)}
+		if c.Client != nil {
+			item.ClientName = c.Client.Name
+		}
+		out = append(out, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"consents": out})
+}
+
+// RevokeConsent is DELETE /me/consents/{consentID}. The app's tokens for
+// the user stop working at once.
+func (h *OAuthHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	consent, err := h.oauth.RevokeConsent(r.Context(), currentUserID(r), chi.URLParam(r, "consentID"))
+	if err != nil {
+		writeConsentError(w, err)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditOAuthConsentRevoked, ActorID: currentUserID(r), TargetType: "api_client", TargetID: consent.ClientID, Details: map[string]string{"consentId": consent.ID}})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// auditClientAuthFailed records a refused grant. The client is only named
+// as target once its key matched; before that clientID is whatever the
+// caller sent. Server errors are not refusals and are not recorded.
+func (h *OAuthHandler) auditClientAuthFailed(r *http.Request, clientID string, client *domain.APIClient, err error) {
+	if !errors.Is(err, domain.ErrInvalidClient) && !errors.Is(err, domain.ErrNetworkNotAllowed) && !errors.Is(err, domain.ErrInvalidScope) {
+		return
+	}
+	if len(clientID) > 64 {
+		clientID = clientID[:64]
+	}
+	rec := domain.AuditRecord{Action: domain.AuditClientAuthFailed, Details: map[string]string{"clientId": clientID, "reason": err.Error()}}
+	if client != nil {
+		rec.TargetType, rec.TargetID = "api_client", client.ID
+	}
+	h.audit.record(r, rec)
+}
+
+// parseOAuthForm reads a form-encoded OAuth request and the client's
+// credentials. It writes the error response itself and reports false on
+// failure.
+func parseOAuthForm(w http.ResponseWriter, r *http.Request) (url.Values, usecase.ClientAuth, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxOAuthFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "body must be application/x-www-form-urlencoded")
+		return nil, usecase.ClientAuth{}, false
+	}
+	form := r.PostForm
+	clientID, secret, basic := r.BasicAuth()
+	switch {
+	case basic && (form.Has("client_id") || form.Has("client_secret")):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "use one client authentication method")
+		return nil, usecase.ClientAuth{}, false
+	case basic:
+		// RFC 6749 section 2.3.1 form-encodes both parts before Basic.
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	default:
+		clientID, secret = form.Get("client_id"), form.Get("client_secret")
+	}
+	return form, usecase.ClientAuth{ClientID: clientID, Secret: secret, IP: clientIP(r)}, true
+}
+
+// authorizationRequest reads the app's request from the query. The user's
+// auth_time, when their token has one, goes into the ID token.
+func authorizationRequest(r *http.Request) usecase.AuthorizationRequest {
+	q := r.URL.Query()
+	in := usecase.AuthorizationRequest{
+		UserID:              currentUserID(r),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		ResponseType:        q.Get("response_type"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+		Nonce:               q.Get("nonce"),
+	}
+	if claims, _ := r.Context().Value(ctxKeyClaims{}).(*auth.Claims); claims != nil && claims.AuthTime != nil {
+		in.AuthTime = claims.AuthTime.Time
+	}
+	return in
+}
+
+// authorizationRedirect appends params and state to a registered redirect
+// URI, keeping any query it already has.
+func authorizationRedirect(redirectURI string, params url.Values, state string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// writeAuthorizeError answers a bad authorization request. Once the
+// redirect URI is known to be the app's, p is set and the response says
+// how to send the error back to it.
+func writeAuthorizeError(w http.ResponseWriter, p *usecase.AuthorizationPreview, err error) {
+	var code, description string
+	switch {
+	case errors.Is(err, domain.ErrInvalidClient):
+		code, description = "invalid_client", "unknown or disabled client"
+	case errors.Is(err, domain.ErrInvalidRedirectURI):
+		code, description = "invalid_request", "redirect_uri is not registered for this client"
+	case errors.Is(err, domain.ErrUnsupportedResponse):
+		code, description = "unsupported_response_type", "response_type must be code"
+	case errors.Is(err, domain.ErrInvalidRequest):
+		code, description = "invalid_request", "code_challenge with code_challenge_method S256 is required and nonce is at most 255 characters"
+	case errors.Is(err, domain.ErrInvalidScope):
+		code, description = "invalid_scope", "scope not granted to this client"
+	case errors.Is(err, domain.ErrForbidden), errors.Is(err, domain.ErrUnauthorized):
+		code, description = "access_denied", "user cannot authorize apps"
+	default:
+		code = "server_error"
+	}
+	status := http.StatusBadRequest
+	if code == "server_error" {
+		status = http.StatusInternalServerError
+	}
+	out := oauthAuthorizeErrorResponse{Error: code, ErrorDescription: description}
+	if p != nil {
+		out.RedirectTo = authorizationRedirect(p.RedirectURI, url.Values{"error": {code}}, p.State)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, out)
+}
+
+func writeOAuthTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidClient):
+		w.Header().Set("WWW-Authenticate", `Basic realm="akiba"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	case errors.Is(err, domain.ErrNetworkNotAllowed):
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client address not allowed")
+	case errors.Is(err, domain.ErrInvalidScope):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "scope not granted to this client")
+	case errors.Is(err, domain.ErrInvalidGrant), errors.Is(err, domain.ErrTokenReused):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "code or refresh token is invalid, expired or revoked")
+	default:
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+	}
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, oauthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+func writeConsentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrConsentNotFound):
+		writeError(w, http.StatusNotFound, "consent_not_found", "consent not found", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
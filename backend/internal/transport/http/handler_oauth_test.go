@@ -0,0 +1,322 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+func TestClientCredentialsGrant(t *testing.T) {
+	store := memory.NewStore()
+	repo, audits := memory.NewUserRepository(store), memory.NewAuditRepository(store)
+	a1 := addUser(t, repo, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	services := Services{
+		Auth:       usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, nil),
+		Admin:      usecase.NewAdminService(repo, nil, nil, jwtMgr, usecase.AdminConfig{ImpersonationTTL: 15 * time.Minute}),
+		Audit:      usecase.NewAuditService(audits, repo),
+		APIClients: usecase.NewAPIClientService(memory.NewAPIClientRepository(store), repo, jwtMgr, usecase.APIClientConfig{TokenTTL: 10 * time.Minute}),
+	}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	opts := auth.TokenOptions{AMR: []string{auth.MethodPassword}}
+	for _, p := range domain.UserRoleAdmin.Permissions() {
+		opts.Permissions = append(opts.Permissions, string(p))
+	}
+	admin, _ := jwtMgr.IssueToken(a1.ID, time.Hour, opts)
+
+	// httptest requests come from 192.0.2.1.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/api-clients", strings.NewReader(`{"name":"Acme","scopes":["webhooks:read"],"allowedNetworks":["192.0.2.0/24"]}`))
+	req.Header.Set("Authorization", "Bearer "+admin)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var created struct {
+		Client apiClientResponse `json:"client"`
+		Key    string            `json:"key"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusCreated || created.Key == "" || len(created.Client.Keys) != 1 || !strings.HasPrefix(created.Key, created.Client.Keys[0].Prefix+".") || strings.Contains(w.Body.String(), "hash") {
+		t.Fatalf("expected client created, got %d %s", w.Code, w.Body.String())
+	}
+
+	grant := func(form url.Values, basic bool, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basic {
+			req.SetBasicAuth(url.QueryEscape(created.Client.ID), url.QueryEscape(created.Key))
+		}
+		if remote != "" {
+			req.RemoteAddr = remote
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w = grant(url.Values{"grant_type": {"client_credentials"}}, true, "")
+	var tok oauthTokenResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &tok)
+	if w.Code != http.StatusOK || tok.TokenType != "Bearer" || tok.ExpiresIn != 600 || tok.Scope != "webhooks:read" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected token, got %d %s", w.Code, w.Body.String())
+	}
+	if w := grant(url.Values{"grant_type": {"client_credentials"}, "client_id": {created.Client.ID}, "client_secret": {created.Key + "x"}}, false, ""); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"error":"invalid_client"`) {
+		t.Fatalf("expected wrong key refused, got %d %s", w.Code, w.Body.String())
+	}
+	if w := grant(url.Values{"grant_type": {"password"}}, true, ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unsupported_grant_type") {
+		t.Fatalf("expected other grants refused, got %d %s", w.Code, w.Body.String())
+	}
+	if w := grant(url.Values{"grant_type": {"client_credentials"}}, true, "198.51.100.7:4000"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected address outside the allow-list refused, got %d", w.Code)
+	}
+	actions := auditActions(t, audits)
+	if len(actions) != 4 || actions[0] != domain.AuditAPIClientCreated || actions[1] != domain.AuditClientTokenIssued || actions[2] != domain.AuditClientAuthFailed || actions[3] != domain.AuditClientAuthFailed {
+		t.Fatalf("unexpected audit trail %v", actions)
+	}
+
+	// Client tokens only open client routes, only with their scopes, and
+	// only from the allowed networks.
+	call := func(h http.Handler, path, token, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, bytes.NewReader(nil))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if remote != "" {
+			req.RemoteAddr = remote
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"client": currentClientID(r)})
+	})
+	if w := call(RequireClient(jwtMgr, domain.ScopeWebhooksRead)(ok), "/", tok.AccessToken, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), created.Client.ID) {
+		t.Fatalf("expected client route open, got %d %s", w.Code, w.Body.String())
+	}
+	if w := call(RequireClient(jwtMgr, domain.ScopeWebhooksWrite)(ok), "/", tok.AccessToken, ""); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "insufficient_scope") {
+		t.Fatalf("expected missing scope refused, got %d %s", w.Code, w.Body.String())
+	}
+	if w := call(RequireClient(jwtMgr, domain.ScopeWebhooksRead)(ok), "/", tok.AccessToken, "198.51.100.7:4000"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "network_not_allowed") {
+		t.Fatalf("expected token refused outside the allow-list, got %d %s", w.Code, w.Body.String())
+	}
+	if w := call(RequireClient(jwtMgr, domain.ScopeWebhooksRead)(ok), "/", admin, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected user token refused on client route, got %d", w.Code)
+	}
+	if w := call(r, "/api/v1/me", tok.AccessToken, ""); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "user_token_required") {
+		t.Fatalf("expected client token refused on user route, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	store := memory.NewStore()
+	repo, audits := memory.NewUserRepository(store), memory.NewAuditRepository(store)
+	a1 := addUser(t, repo, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	c1 := addUser(t, repo, domain.User{UsernameLower: "ada"})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	clients := usecase.NewAPIClientService(memory.NewAPIClientRepository(store), repo, jwtMgr, usecase.APIClientConfig{})
+	services := Services{
+		Auth:       usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, nil),
+		Audit:      usecase.NewAuditService(audits, repo),
+		APIClients: clients,
+		OAuth:      usecase.NewOAuthService(memory.NewOAuthRepository(store), clients, repo, jwtMgr, usecase.OAuthConfig{}),
+	}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	app, key, _, err := clients.Create(context.Background(), usecase.CreateAPIClientInput{ActorID: a1.ID, Name: "Budget App", Scopes: []string{"profile"}, RedirectURIs: []string{"https://app.example.com/cb?src=akiba"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := jwtMgr.IssueToken(c1.ID, time.Hour, auth.TokenOptions{AMR: []string{auth.MethodPassword}})
+	send := func(method, path, token string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, body)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if method == http.MethodPost && strings.HasPrefix(path, "/oauth/") && !strings.HasPrefix(path, "/oauth/authorize") {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(app.ID, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	query := url.Values{"response_type": {"code"}, "client_id": {app.ID}, "redirect_uri": {"https://app.example.com/cb?src=akiba"}, "scope": {"profile"}, "state": {"s1"}, "code_challenge": {base64.RawURLEncoding.EncodeToString(sum[:])}, "code_challenge_method": {"S256"}}
+	if w := send(http.MethodGet, "/oauth/authorize?"+query.Encode(), "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the consent step to need the user, got %d", w.Code)
+	}
+	bad := url.Values{}
+	for k, v := range query {
+		bad[k] = v
+	}
+	bad.Set("redirect_uri", "https://evil.example/cb")
+	if w := send(http.MethodGet, "/oauth/authorize?"+bad.Encode(), user, nil); w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "redirectTo") {
+		t.Fatalf("expected unregistered redirect refused without redirecting, got %d %s", w.Code, w.Body.String())
+	}
+	bad.Set("redirect_uri", query.Get("redirect_uri"))
+	bad.Set("code_challenge_method", "plain")
+	if w := send(http.MethodGet, "/oauth/authorize?"+bad.Encode(), user, nil); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "error=invalid_request") {
+		t.Fatalf("expected plain PKCE sent back to the app, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodGet, "/oauth/authorize?"+query.Encode(), user, nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Budget App"`) || !strings.Contains(w.Body.String(), `"consented":false`) {
+		t.Fatalf("expected consent preview, got %d %s", w.Code, w.Body.String())
+	}
+	w := send(http.MethodPost, "/oauth/authorize?"+query.Encode(), user, strings.NewReader(`{"approve":true}`))
+	var decision struct {
+		RedirectTo string `json:"redirectTo"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &decision)
+	back, err := url.Parse(decision.RedirectTo)
+	if w.Code != http.StatusOK || err != nil || back.Host != "app.example.com" || back.Query().Get("src") != "akiba" || back.Query().Get("state") != "s1" || back.Query().Get("code") == "" {
+		t.Fatalf("expected redirect with code and state, got %d %s", w.Code, w.Body.String())
+	}
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {back.Query().Get("code")}, "redirect_uri": {query.Get("redirect_uri")}, "code_verifier": {verifier}}
+	w = send(http.MethodPost, "/oauth/token", "", strings.NewReader(form.Encode()))
+	var tok oauthTokenResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &tok)
+	if w.Code != http.StatusOK || tok.Scope != "profile" || tok.RefreshToken != "" {
+		t.Fatalf("expected code exchanged, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodPost, "/oauth/token", "", strings.NewReader(form.Encode())); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_grant") {
+		t.Fatalf("expected replayed code refused, got %d %s", w.Code, w.Body.String())
+	}
+
+	// The replay revoked the first token; authorize again for a live one.
+	w = send(http.MethodPost, "/oauth/authorize?"+query.Encode(), user, strings.NewReader(`{"approve":true}`))
+	_ = json.Unmarshal(w.Body.Bytes(), &decision)
+	back, _ = url.Parse(decision.RedirectTo)
+	form.Set("code", back.Query().Get("code"))
+	w = send(http.MethodPost, "/oauth/token", "", strings.NewReader(form.Encode()))
+	_ = json.Unmarshal(w.Body.Bytes(), &tok)
+
+	// An app token reads what its scopes cover and nothing else.
+	if w := send(http.MethodGet, "/api/v1/me", tok.AccessToken, nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ada"`) {
+		t.Fatalf("expected profile readable, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodGet, "/api/v1/me/consents", tok.AccessToken, nil); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "user_token_required") {
+		t.Fatalf("expected app token refused on consents, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodPost, "/oauth/introspect", "", strings.NewReader("token="+tok.AccessToken)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"active":true`) || !strings.Contains(w.Body.String(), `"sub":"`+c1.ID+`"`) {
+		t.Fatalf("expected active token, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = send(http.MethodGet, "/api/v1/me/consents", user, nil)
+	var list struct {
+		Consents []consentResponse `json:"consents"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list.Consents) != 1 || list.Consents[0].ClientName != "Budget App" {
+		t.Fatalf("expected one consent, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodDelete, "/api/v1/me/consents/"+list.Consents[0].ID, user, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected consent revoked, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodGet, "/api/v1/me", tok.AccessToken, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected app token dead after revocation, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodPost, "/oauth/introspect", "", strings.NewReader("token="+tok.AccessToken)); !strings.Contains(w.Body.String(), `{"active":false}`) {
+		t.Fatalf("expected inactive token, got %s", w.Body.String())
+	}
+
+	actions := auditActions(t, audits)
+	want := []domain.AuditAction{domain.AuditOAuthConsentGranted, domain.AuditOAuthTokenIssued, domain.AuditOAuthTokenReused, domain.AuditOAuthConsentGranted, domain.AuditOAuthTokenIssued, domain.AuditOAuthConsentRevoked}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("unexpected audit trail %v", actions)
+	}
+}
+
+func TestOpenIDConnectEndpoints(t *testing.T) {
+	store := memory.NewStore()
+	repo := memory.NewUserRepository(store)
+	a1 := addUser(t, repo, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	c1 := addUser(t, repo, domain.User{UsernameLower: "ada", PhoneE164: "+254700000001", PhoneVerifiedAt: time.Now()})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	key, err := auth.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtMgr.SetIDTokenKey(key)
+	clients := usecase.NewAPIClientService(memory.NewAPIClientRepository(store), repo, jwtMgr, usecase.APIClientConfig{})
+	services := Services{
+		Auth:       usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, nil),
+		Audit:      usecase.NewAuditService(memory.NewAuditRepository(store), repo),
+		APIClients: clients,
+		OAuth:      usecase.NewOAuthService(memory.NewOAuthRepository(store), clients, repo, jwtMgr, usecase.OAuthConfig{Issuer: "https://id.akiba.example", AuthorizationEndpoint: "https://akiba.example/oauth/authorize"}),
+	}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	app, _, _, err := clients.Create(context.Background(), usecase.CreateAPIClientInput{ActorID: a1.ID, Name: "Sister App", Scopes: []string{"openid", "profile", "phone"}, RedirectURIs: []string{"com.example.sister:/cb"}, Public: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := jwtMgr.IssueToken(c1.ID, time.Hour, auth.TokenOptions{AMR: []string{auth.MethodPassword}})
+	send := func(method, path, token string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, body)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if method == http.MethodPost && path == "/oauth/token" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodGet, "/.well-known/openid-configuration", "", nil)
+	var discovery oidcDiscoveryResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &discovery)
+	if w.Code != http.StatusOK || discovery.Issuer != "https://id.akiba.example" || discovery.JWKSURI != "https://id.akiba.example/.well-known/jwks.json" || discovery.AuthorizationEndpoint != "https://akiba.example/oauth/authorize" || !slices.Equal(discovery.IDTokenSigningAlgValuesSupported, []string{"ES256"}) {
+		t.Fatalf("unexpected discovery document %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodGet, "/.well-known/jwks.json", "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"kty":"EC"`) || strings.Contains(w.Body.String(), `"d"`) {
+		t.Fatalf("unexpected key set %d %s", w.Code, w.Body.String())
+	}
+
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	query := url.Values{"response_type": {"code"}, "client_id": {app.ID}, "redirect_uri": {"com.example.sister:/cb"}, "scope": {"openid profile phone"}, "state": {"s1"}, "nonce": {"n1"}, "code_challenge": {base64.RawURLEncoding.EncodeToString(sum[:])}, "code_challenge_method": {"S256"}}
+	w = send(http.MethodPost, "/oauth/authorize?"+query.Encode(), user, strings.NewReader(`{"approve":true}`))
+	var decision struct {
+		RedirectTo string `json:"redirectTo"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &decision)
+	back, err := url.Parse(decision.RedirectTo)
+	if w.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected approval, got %d %s", w.Code, w.Body.String())
+	}
+	form := url.Values{"grant_type": {"authorization_code"}, "client_id": {app.ID}, "code": {back.Query().Get("code")}, "redirect_uri": {"com.example.sister:/cb"}, "code_verifier": {verifier}}
+	w = send(http.MethodPost, "/oauth/token", "", strings.NewReader(form.Encode()))
+	var tok oauthTokenResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &tok)
+	if w.Code != http.StatusOK || tok.IDToken == "" {
+		t.Fatalf("expected an id token, got %d %s", w.Code, w.Body.String())
+	}
+	idToken, err := jwtMgr.VerifyIDToken(tok.IDToken, "https://id.akiba.example", app.ID)
+	if err != nil || idToken.Nonce != "n1" || idToken.AuthTime == nil || idToken.PreferredUsername != "ada" {
+		t.Fatalf("unexpected id token %#v %v", idToken, err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w = send(method, "/userinfo", tok.AccessToken, nil)
+		if w.Code != http.StatusOK || w.Body.String() != `{"sub":"`+c1.ID+`","preferred_username":"ada","phone_number":"+254700000001","phone_number_verified":true}`+"\n" {
+			t.Fatalf("%s: unexpected userinfo %d %s", method, w.Code, w.Body.String())
+		}
+	}
+	if w := send(http.MethodGet, "/userinfo", user, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the user's own token refused, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PasskeyHandler struct {
+	passkeyService *usecase.PasskeyService
+	authService    *usecase.AuthService
+	audit          *auditor
+}
+
+func NewPasskeyHandler(passkeyService *usecase.PasskeyService, authService *usecase.AuthService, auditService *usecase.AuditService, logger *slog.Logger) *PasskeyHandler {
+	return &PasskeyHandler{passkeyService: passkeyService, authService: authService, audit: &auditor{service: auditService, logger: logger}}
+}
+
+type registerPasskeyRequest struct {
+	ChallengeID       string `json:"challengeId"`
+	Name              string `json:"name"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+}
+type passkeyLoginRequest struct {
+	ChallengeID       string         `json:"challengeId"`
+	CredentialID      string         `json:"credentialId"`
+	ClientDataJSON    string         `json:"clientDataJSON"`
+	AuthenticatorData string         `json:"authenticatorData"`
+	Signature         string         `json:"signature"`
+	UserHandle        string         `json:"userHandle"`
+	Device            *deviceRequest `json:"device"`
+}
+type passkeyResponse struct {
+	ID             string `json:"id"`
+	CredentialID   string `json:"credentialId"`
+	Name           string `json:"name"`
+	Algorithm      int64  `json:"algorithm"`
+	Attestation    string `json:"attestation"`
+	BackupEligible bool   `json:"backupEligible"`
+	CreatedAt      string `json:"createdAt"`
+	LastUsedAt     string `json:"lastUsedAt,omitempty"`
+}
+
+// credentialDescriptor and the option types below follow the JSON forms of
+// PublicKeyCredentialCreationOptions and PublicKeyCredentialRequestOptions,
+// with binary values base64url encoded.
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+type creationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+	Timeout     int64  `json:"timeout"`
+}
+type requestOptions struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	UserVerification string `json:"userVerification"`
+	Timeout          int64  `json:"timeout"`
+}
+
+func mapPasskey(p *domain.Passkey) passkeyResponse {
+	out := passkeyResponse{ID: p.ID, CredentialID: p.CredentialID, Name: p.Name, Algorithm: p.Algorithm, Attestation: p.Attestation, BackupEligible: p.BackupEligible, CreatedAt: p.CreatedAt.UTC().Format(time.RFC3339)}
+	if !p.LastUsedAt.IsZero() {
+		out.LastUsedAt = p.LastUsedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func mapCreationOptions(o *usecase.RegistrationOptions) creationOptions {
+	var out creationOptions
+	out.Challenge = base64.RawURLEncoding.EncodeToString(o.Challenge)
+	out.RP.ID, out.RP.Name = o.RPID, o.RPName
+	out.User.ID = base64.RawURLEncoding.EncodeToString(o.UserHandle)
+	out.User.Name, out.User.DisplayName = o.UserName, o.UserName
+	for _, alg := range o.Algorithms {
+		out.PubKeyCredParams = append(out.PubKeyCredParams, credentialParameter{Type: "public-key", Alg: alg})
+	}
+	out.ExcludeCredentials = make([]credentialDescriptor, 0, len(o.ExcludeCredentials))
+	for _, id := range o.ExcludeCredentials {
+		out.ExcludeCredentials = append(out.ExcludeCredentials, credentialDescriptor{Type: "public-key", ID: id})
+	}
+	out.AuthenticatorSelection.ResidentKey = "required"
+	out.AuthenticatorSelection.UserVerification = o.UserVerification
+	out.Attestation = "direct"
+	out.Timeout = o.Timeout.Milliseconds()
+	return out
+}
+
+// RegistrationOptions starts adding a passkey; the route requires a fresh
+// authentication.
+func (h *PasskeyHandler) RegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.passkeyService.BeginRegistration(r.Context(), currentUserID(r))
+	if err != nil {
+		writePasskeyError(w, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"challengeId": opts.ChallengeID, "publicKey": mapCreationOptions(opts)})
+}
+
+func (h *PasskeyHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req registerPasskeyRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	p, fields, err := h.passkeyService.FinishRegistration(r.Context(), usecase.FinishRegistrationInput{UserID: currentUserID(r), ChallengeID: req.ChallengeID, Name: req.Name, ClientDataJSON: req.ClientDataJSON, AttestationObject: req.AttestationObject})
+	if err != nil {
+		writePasskeyError(w, err, fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"passkey": mapPasskey(p)})
+}
+
+func (h *PasskeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	passkeys, err := h.passkeyService.List(r.Context(), currentUserID(r))
+	if err != nil {
+		writePasskeyError(w, err, nil)
+		return
+	}
+	out := make([]passkeyResponse, 0, len(passkeys))
+	for _, p := range passkeys {
+		out = append(out, mapPasskey(p))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"passkeys": out})
+}
+
+func (h *PasskeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.passkeyService.Delete(r.Context(), currentUserID(r), chi.URLParam(r, "passkeyID")); err != nil {
+		writePasskeyError(w, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PasskeyHandler) LoginOptions(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.passkeyService.BeginLogin(r.Context())
+	if err != nil {
+		writePasskeyError(w, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"challengeId": opts.ChallengeID, "publicKey": requestOptions{Challenge: base64.RawURLEncoding.EncodeToString(opts.Challenge), RPID: opts.RPID, UserVerification: opts.UserVerification, Timeout: opts.Timeout.Milliseconds()}})
+}
+
+func (h *PasskeyHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req passkeyLoginRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	res, fields, err := h.authService.LoginWithPasskey(r.Context(), usecase.PasskeyLoginInput{ChallengeID: req.ChallengeID, CredentialID: req.CredentialID, ClientDataJSON: req.ClientDataJSON, AuthenticatorData: req.AuthenticatorData, Signature: req.Signature, UserHandle: req.UserHandle, Device: req.Device.input()})
+	if err != nil {
+		if errors.Is(err, domain.ErrPasskeyInvalid) || errors.Is(err, domain.ErrInvalidCredentials) || errors.Is(err, domain.ErrForbidden) {
+			auditLoginFailed(h.audit, r, "", "passkey", map[string]string{"credentialId": req.CredentialID})
+		}
+		writePasskeyError(w, err, fields)
+		return
+	}
+	if res.OTPChallengeID != "" {
+		writeJSON(w, http.StatusAccepted, map[string]any{"otpRequired": true, "challengeId": res.OTPChallengeID, "expiresAt": res.OTPExpiresAt.UTC().Format(time.RFC3339)})
+		return
+	}
+	auditLogin(h.audit, r, res, "passkey")
+	writeJSON(w, http.StatusOK, map[string]any{"user": mapUser(res.User), "accessToken": res.AccessToken})
+}
+
+func writePasskeyError(w http.ResponseWriter, err error, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid passkey payload", fields)
+	case errors.Is(err, domain.ErrChallengeNotFound):
+		writeError(w, http.StatusBadRequest, "challenge_not_found", "challenge expired or already used; request new options", nil)
+	case errors.Is(err, domain.ErrPasskeyInvalid):
+		writeError(w, http.StatusBadRequest, "passkey_invalid", "passkey could not be verified", nil)
+	case errors.Is(err, domain.ErrPasskeyExists):
+		writeError(w, http.StatusConflict, "passkey_exists", "passkey already registered", nil)
+	case errors.Is(err, domain.ErrPasskeyNotFound):
+		writeError(w, http.StatusNotFound, "passkey_not_found", "passkey not found", nil)
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		writeError(w, http.StatusUnauthorized, "invalid_credentials", "passkey login failed", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "account is not active", nil)
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+)
+
+type PINHandler struct{ pinService *usecase.PINService }
+
+func NewPINHandler(pinService *usecase.PINService) *PINHandler {
+	return &PINHandler{pinService: pinService}
+}
+
+type setPINRequest struct {
+	PIN string `json:"pin"`
+}
+type changePINRequest struct {
+	CurrentPIN string `json:"currentPin"`
+	NewPIN     string `json:"newPin"`
+}
+type resetPINRequest struct {
+	NewPIN string `json:"newPin"`
+}
+
+func (h *PINHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req setPINRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	fields, err := h.pinService.Set(r.Context(), usecase.SetPINInput{UserID: currentUserID(r), PIN: req.PIN})
+	if err != nil {
+		writePINError(w, err, fields)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PINHandler) Change(w http.ResponseWriter, r *http.Request) {
+	var req changePINRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	fields, err := h.pinService.Change(r.Context(), usecase.ChangePINInput{UserID: currentUserID(r), CurrentPIN: req.CurrentPIN, NewPIN: req.NewPIN})
+	if err != nil {
+		writePINError(w, err, fields)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reset replaces a forgotten PIN; the route requires a fresh password
+// authentication instead of the old PIN.
+func (h *PINHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	var req resetPINRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	fields, err := h.pinService.Reset(r.Context(), usecase.SetPINInput{UserID: currentUserID(r), PIN: req.NewPIN})
+	if err != nil {
+		writePINError(w, err, fields)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writePINError(w http.ResponseWriter, err error, fields domain.FieldErrors) {
+	if writePINVerifyError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid PIN payload", fields)
+	case errors.Is(err, domain.ErrPINAlreadySet):
+		writeError(w, http.StatusConflict, "pin_already_set", "PIN already set; change or reset it instead", nil)
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
+
+// writePINVerifyError writes the responses shared by every endpoint that
+// checks a PIN and reports whether err was one of them.
+func writePINVerifyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrInvalidPIN):
+		writeError(w, http.StatusForbidden, "invalid_pin", "incorrect PIN", nil)
+	case errors.Is(err, domain.ErrPINLocked):
+		writeError(w, http.StatusLocked, "pin_locked", "too many incorrect PINs; try later or reset your PIN", nil)
+	case errors.Is(err, domain.ErrPINNotSet):
+		writeError(w, http.StatusConflict, "pin_not_set", "no PIN set", nil)
+	default:
+		return false
+	}
+	return true
+}
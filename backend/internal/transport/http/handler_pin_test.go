@@ -0,0 +1,57 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+func TestPINNeverLogged(t *testing.T) {
+	var logs bytes.Buffer
+	repo := memory.NewUserRepository(memory.NewStore())
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	pins := usecase.NewPINService(repo, usecase.PINConfig{MaxAttempts: 5, Lockout: time.Hour})
+	authSvc := usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute, TransferThresholds: map[string]int64{"KES": 5000000}}, pins, nil, nil, nil)
+	r := NewRouter(slog.New(slog.NewJSONHandler(&logs, nil)), Services{Auth: authSvc, PINs: pins}, jwtMgr, func(ctx context.Context) error { return nil })
+
+	b, _ := json.Marshal(map[string]string{"email": "user@example.com", "phone": "+14155552671", "username": "user_1", "password": "Password1"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/signup", bytes.NewReader(b)))
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	tok, _ := out["accessToken"].(string)
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tok)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := do(http.MethodPost, "/api/v1/me/pin", `{"pin":"582914"}`); code != http.StatusNoContent {
+		t.Fatalf("set PIN: expected 204, got %d", code)
+	}
+	if code := do(http.MethodPut, "/api/v1/me/pin", `{"currentPin":"730461","newPin":"905173"}`); code != http.StatusForbidden {
+		t.Fatalf("change with wrong PIN: expected 403, got %d", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/auth/step-up", `{"method":"pin","pin":"582914"}`); code != http.StatusOK {
+		t.Fatalf("step-up by PIN: expected 200, got %d", code)
+	}
+	if logs.Len() == 0 {
+		t.Fatalf("expected request logs")
+	}
+	for _, pin := range []string{"582914", "730461", "905173"} {
+		if strings.Contains(logs.String(), pin) {
+			t.Fatalf("PIN %s leaked into logs: %s", pin, logs.String())
+		}
+	}
+}
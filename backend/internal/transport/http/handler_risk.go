@@ -0,0 +1,84 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type RiskHandler struct{ riskService *usecase.RiskService }
+
+func NewRiskHandler(riskService *usecase.RiskService) *RiskHandler {
+	return &RiskHandler{riskService: riskService}
+}
+
+type riskFactorResponse struct {
+	Signal       string  `json:"signal"`
+	Value        float64 `json:"value"`
+	Weight       int     `json:"weight"`
+	Contribution float64 `json:"contribution"`
+	Detail       string  `json:"detail,omitempty"`
+}
+type riskDecisionResponse struct {
+	ID          string               `json:"id"`
+	UserID      string               `json:"userId"`
+	Action      string               `json:"action"`
+	Amount      int64                `json:"amount"`
+	Currency    string               `json:"currency"`
+	DeviceID    string               `json:"deviceId,omitempty"`
+	RecipientID string               `json:"recipientId,omitempty"`
+	Score       int                  `json:"score"`
+	Decision    string               `json:"decision"`
+	SteppedUp   bool                 `json:"steppedUp"`
+	Factors     []riskFactorResponse `json:"factors"`
+	CreatedAt   string               `json:"createdAt"`
+}
+
+func mapRiskDecision(d *domain.RiskDecision) riskDecisionResponse {
+	factors := make([]riskFactorResponse, 0, len(d.Factors))
+	for _, f := range d.Factors {
+		factors = append(factors, riskFactorResponse{Signal: f.Signal, Value: f.Value, Weight: f.Weight, Contribution: f.Contribution, Detail: f.Detail})
+	}
+	return riskDecisionResponse{ID: d.ID, UserID: d.UserID, Action: d.Action, Amount: d.Amount, Currency: d.Currency, DeviceID: d.DeviceID, RecipientID: d.RecipientID, Score: d.Score, Decision: d.Decision, SteppedUp: d.SteppedUp, Factors: factors, CreatedAt: d.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *RiskHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	decisions, err := h.riskService.List(r.Context(), currentUserID(r), q.Get("userId"), q.Get("decision"))
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	out := make([]riskDecisionResponse, 0, len(decisions))
+	for _, d := range decisions {
+		out = append(out, mapRiskDecision(d))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"decisions": out})
+}
+
+func (h *RiskHandler) Get(w http.ResponseWriter, r *http.Request) {
+	decision, err := h.riskService.Get(r.Context(), currentUserID(r), chi.URLParam(r, "decisionID"))
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"decision": mapRiskDecision(decision)})
+}
+
+func writeRiskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrRiskDecisionNotFound):
+		writeError(w, http.StatusNotFound, "risk_decision_not_found", "risk decision not found", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
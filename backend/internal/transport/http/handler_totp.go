@@ -0,0 +1,83 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+)
+
+type TOTPHandler struct{ totpService *usecase.TOTPService }
+
+func NewTOTPHandler(totpService *usecase.TOTPService) *TOTPHandler {
+	return &TOTPHandler{totpService: totpService}
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+// Enroll returns a new secret for the user to add to an authenticator app.
+// It is shown once; the route requires a fresh authentication.
+func (h *TOTPHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := h.totpService.Enroll(r.Context(), currentUserID(r))
+	if err != nil {
+		writeTOTPError(w, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"secret": enrollment.Secret, "uri": enrollment.URI})
+}
+
+func (h *TOTPHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	var req confirmTOTPRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	fields, err := h.totpService.Confirm(r.Context(), currentUserID(r), req.Code)
+	if err != nil {
+		writeTOTPError(w, err, fields)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TOTPHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if err := h.totpService.Disable(r.Context(), currentUserID(r)); err != nil {
+		writeTOTPError(w, err, nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTOTPError(w http.ResponseWriter, err error, fields domain.FieldErrors) {
+	if writeTOTPVerifyError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid TOTP payload", fields)
+	case errors.Is(err, domain.ErrTOTPAlreadyEnrolled):
+		writeError(w, http.StatusConflict, "totp_already_enrolled", "an authenticator app is already enrolled; remove it first", nil)
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
+
+// writeTOTPVerifyError writes the responses shared by every endpoint that
+// checks an authenticator app code and reports whether err was one of them.
+func writeTOTPVerifyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrInvalidOTP):
+		writeError(w, http.StatusForbidden, "invalid_otp", "incorrect code", nil)
+	case errors.Is(err, domain.ErrTOTPLocked):
+		writeError(w, http.StatusLocked, "totp_locked", "too many incorrect codes; try later", nil)
+	case errors.Is(err, domain.ErrTOTPNotEnrolled):
+		writeError(w, http.StatusConflict, "totp_not_enrolled", "no authenticator app enrolled", nil)
+	default:
+		return false
+	}
+	return true
+}
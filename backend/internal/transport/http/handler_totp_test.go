@@ -0,0 +1,68 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+func TestStepUpByTOTP(t *testing.T) {
+	repo := memory.NewUserRepository(memory.NewStore())
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	totp := usecase.NewTOTPService(repo, usecase.TOTPConfig{Issuer: "Akiba", MaxAttempts: 5, Lockout: time.Hour})
+	authSvc := usecase.NewAuthService(repo, jwtMgr, time.Hour, usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute}, nil, nil, nil, totp)
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), Services{Auth: authSvc, TOTP: totp}, jwtMgr, func(ctx context.Context) error { return nil })
+
+	b, _ := json.Marshal(map[string]string{"email": "user@example.com", "phone": "+14155552671", "username": "user_1", "password": "Password1"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/signup", bytes.NewReader(b)))
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	tok, _ := out["accessToken"].(string)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tok)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/api/v1/auth/step-up", `{"method":"totp","code":"123456"}`); w.Code != http.StatusConflict {
+		t.Fatalf("step-up before enrolling: expected 409, got %d", w.Code)
+	}
+	w = do(http.MethodPost, "/api/v1/me/totp", "")
+	var enrollment struct{ Secret, URI string }
+	if err := json.Unmarshal(w.Body.Bytes(), &enrollment); w.Code != http.StatusCreated || err != nil || enrollment.Secret == "" {
+		t.Fatalf("enroll: %d %s", w.Code, w.Body.String())
+	}
+	now := time.Now()
+	code, _ := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(now))
+	if w := do(http.MethodPost, "/api/v1/me/totp/confirm", `{"code":"`+code+`"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("confirm: expected 204, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/v1/auth/step-up", `{"method":"totp","code":"`+code+`"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("replayed code: expected 403, got %d", w.Code)
+	}
+	next, _ := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(now)+1)
+	w = do(http.MethodPost, "/api/v1/auth/step-up", `{"method":"totp","code":"`+next+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("step-up by TOTP: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	claims, err := jwtMgr.Verify(out["accessToken"].(string))
+	if err != nil || !slices.Contains(claims.AMR, auth.MethodOTP) {
+		t.Fatalf("expected an otp step-up token, got %+v %v", claims, err)
+	}
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+)
+
+// deviceIDHeader carries the client's stable device identifier, used to
+// score payments from unfamiliar devices. Device-bound tokens name the
+// device themselves and the header is ignored.
+const deviceIDHeader = "X-Device-ID"
+
+func requestDeviceID(r *http.Request) string {
+	if d := currentDevice(r); d != nil {
+		return d.ID
+	}
+	return r.Header.Get(deviceIDHeader)
+}
+
+type TransferHandler struct {
+	transferService *usecase.TransferService
+	stepUp          usecase.StepUpConfig
+}
+
+func NewTransferHandler(transferService *usecase.TransferService, stepUp usecase.StepUpConfig) *TransferHandler {
+	return &TransferHandler{transferService: transferService, stepUp: stepUp}
+}
+
+type transferRequest struct {
+	Recipient     string `json:"recipient"`
+	BeneficiaryID string `json:"beneficiaryId"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Note          string `json:"note"`
+	PromoCode     string `json:"promoCode"`
+	PIN           string `json:"pin"`
+}
+type withdrawalRequest struct {
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Phone         string `json:"phone"`
+	BeneficiaryID string `json:"beneficiaryId"`
+	PromoCode     string `json:"promoCode"`
+	PIN           string `json:"pin"`
+}
+
+func (h *TransferHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	steppedUp := freshAuth(r, h.stepUp.MaxAge)
+	threshold, ok := h.stepUp.TransferThresholds[domain.NormalizeCurrency(req.Currency)]
+	if !ok {
+		writeTransferError(w, domain.ErrInvalidInput, "invalid transfer payload", domain.FieldErrors{"currency": "unsupported currency"})
+		return
+	}
+	if req.Amount > threshold && !steppedUp {
+		writeTransferError(w, domain.ErrStepUpRequired, "", nil)
+		return
+	}
+	in := usecase.TransferInput{SenderID: currentUserID(r), Recipient: req.Recipient, BeneficiaryID: req.BeneficiaryID, Amount: req.Amount, Currency: req.Currency, Note: req.Note, PromoCode: req.PromoCode, DeviceID: requestDeviceID(r), SteppedUp: steppedUp, PIN: req.PIN}
+	res, fields, err := h.transferService.Transfer(r.Context(), in)
+	if err != nil {
+		writeTransferError(w, err, "invalid transfer payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"transfer": map[string]any{"entryId": res.Entry.ID, "recipient": res.Recipient.UsernameLower, "amount": res.Amount, "fee": res.Fee, "currency": res.Currency, "createdAt": res.Entry.CreatedAt.UTC().Format(time.RFC3339)},
+	})
+}
+
+func (h *TransferHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	var req withdrawalRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	in := usecase.WithdrawalInput{UserID: currentUserID(r), Amount: req.Amount, Currency: req.Currency, Phone: req.Phone, BeneficiaryID: req.BeneficiaryID, PromoCode: req.PromoCode, PIN: req.PIN}
+	res, fields, err := h.transferService.Withdraw(r.Context(), in)
+	if err != nil {
+		writeTransferError(w, err, "invalid withdrawal payload", fields)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"withdrawal": map[string]any{"holdId": res.Hold.ID, "status": "pending", "phone": res.Phone, "amount": res.Amount, "fee": res.Fee, "currency": res.Currency, "createdAt": res.Hold.CreatedAt.UTC().Format(time.RFC3339)},
+	})
+}
+
+func writeTransferError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	if writePINVerifyError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrRecipientNotFound):
+		writeError(w, http.StatusNotFound, "recipient_not_found", "recipient not found", nil)
+	case errors.Is(err, domain.ErrBeneficiaryNotFound):
+		writeError(w, http.StatusNotFound, "beneficiary_not_found", "beneficiary not found", nil)
+	case errors.Is(err, domain.ErrCoolingOffLimit):
+		writeError(w, http.StatusUnprocessableEntity, "cooling_off_limit", "new payee is limited during its cooling-off period", fields)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		writeError(w, http.StatusUnprocessableEntity, "insufficient_funds", "insufficient funds", nil)
+	case errors.Is(err, domain.ErrStepUpRequired):
+		writeError(w, http.StatusForbidden, "step_up_required", "additional verification required", nil)
+	case errors.Is(err, domain.ErrRiskBlocked):
+		writeError(w, http.StatusForbidden, "transfer_blocked", "transfer blocked for review", nil)
+	case errors.Is(err, domain.ErrAccountRestricted):
+		writeError(w, http.StatusForbidden, "account_restricted", "account cannot move money", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
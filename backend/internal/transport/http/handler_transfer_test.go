@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/usecase"
+)
+
+func TestTransferRefusesCurrencyWithoutStepUpThreshold(t *testing.T) {
+	s := memory.NewStore()
+	users, ledger := memory.NewUserRepository(s), memory.NewLedgerRepository(s)
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	stepUp := usecase.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute, TransferThresholds: map[string]int64{"KES": 5000000}}
+	services := Services{
+		Auth:      usecase.NewAuthService(users, jwtMgr, time.Hour, stepUp, nil, nil, nil, nil),
+		Transfers: usecase.NewTransferService(users, ledger, usecase.NewFeeService(&fees.Schedule{}), nil, nil, nil, nil),
+	}
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), services, jwtMgr, func(ctx context.Context) error { return nil })
+	tok, _ := jwtMgr.IssueAccessToken("u1", time.Hour)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", strings.NewReader(`{"recipient":"bob","amount":100,"currency":"usd"}`))
+	req.Header.Set("Authorization", "Bearer "+tok)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var out APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &out); w.Code != http.StatusBadRequest || err != nil || out.Error.Fields["currency"] == "" {
+		t.Fatalf("expected 400 naming the currency, got %d %s", w.Code, w.Body.String())
+	}
+}
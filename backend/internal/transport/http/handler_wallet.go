@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+)
+
+type WalletHandler struct{ walletService *usecase.WalletService }
+
+func NewWalletHandler(walletService *usecase.WalletService) *WalletHandler {
+	return &WalletHandler{walletService: walletService}
+}
+
+type openWalletRequest struct {
+	Currency string `json:"currency"`
+}
+
+// accountResponse reports Current, the posted ledger balance, separately
+// from Available, which is Current less the active holds listed in Holds.
+type accountResponse struct {
+	ID        string         `json:"id"`
+	Currency  string         `json:"currency"`
+	Current   int64          `json:"current"`
+	Available int64          `json:"available"`
+	Held      int64          `json:"held"`
+	Holds     []holdResponse `json:"holds"`
+	CreatedAt string         `json:"createdAt"`
+}
+
+func mapAccount(a *domain.Account, holds []*domain.Hold) accountResponse {
+	out := accountResponse{ID: a.ID, Currency: a.Currency, Current: a.Balance, Available: a.Available(), Held: a.Held, Holds: make([]holdResponse, 0, len(holds)), CreatedAt: a.CreatedAt.UTC().Format(time.RFC3339)}
+	for _, h := range holds {
+		out.Holds = append(out.Holds, mapHold(h))
+	}
+	return out
+}
+
+func (h *WalletHandler) List(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.walletService.List(r.Context(), currentUserID(r))
+	if err != nil {
+		writeWalletError(w, err, nil)
+		return
+	}
+	out := make([]accountResponse, 0, len(accounts))
+	for _, a := range accounts {
+		out = append(out, mapAccount(a.Account, a.Holds))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"accounts": out})
+}
+
+func (h *WalletHandler) Open(w http.ResponseWriter, r *http.Request) {
+	var req openWalletRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	account, fields, err := h.walletService.Open(r.Context(), currentUserID(r), req.Currency)
+	if err != nil {
+		writeWalletError(w, err, fields)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"account": mapAccount(account, nil)})
+}
+
+func writeWalletError(w http.ResponseWriter, err error, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", "invalid wallet payload", fields)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
@@ -0,0 +1,235 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/usecase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookHandler struct {
+	webhookService *usecase.WebhookService
+	audit          *auditor
+}
+
+func NewWebhookHandler(webhookService *usecase.WebhookService, auditService *usecase.AuditService, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService, audit: &auditor{service: auditService, logger: logger}}
+}
+
+type createWebhookRequest struct {
+	ClientID   string   `json:"clientId"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+}
+type rotateWebhookSecretRequest struct {
+	// Overlap is a Go duration such as "24h"; empty means 24h.
+	Overlap string `json:"overlap"`
+}
+
+type webhookSecretResponse struct {
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+type webhookEndpointResponse struct {
+	ID         string                  `json:"id"`
+	ClientID   string                  `json:"clientId"`
+	URL        string                  `json:"url"`
+	EventTypes []domain.EventType      `json:"eventTypes"`
+	Status     string                  `json:"status"`
+	Secrets    []webhookSecretResponse `json:"secrets"`
+	CreatedBy  string                  `json:"createdBy"`
+	CreatedAt  string                  `json:"createdAt"`
+	UpdatedAt  string                  `json:"updatedAt"`
+}
+type webhookAttemptResponse struct {
+	At         string `json:"at"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+type webhookDeliveryResponse struct {
+	ID            string                   `json:"id"`
+	EndpointID    string                   `json:"endpointId"`
+	EventID       string                   `json:"eventId"`
+	EventType     string                   `json:"eventType"`
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt string                   `json:"nextAttemptAt,omitempty"`
+	History       []webhookAttemptResponse `json:"history"`
+	CreatedAt     string                   `json:"createdAt"`
+	UpdatedAt     string                   `json:"updatedAt"`
+}
+
+// mapWebhookEndpoint never includes secret values; they are shown once when
+// created or rotated.
+func mapWebhookEndpoint(e *domain.WebhookEndpoint) webhookEndpointResponse {
+	secrets := make([]webhookSecretResponse, 0, len(e.Secrets))
+	for _, s := range e.Secrets {
+		out := webhookSecretResponse{CreatedAt: s.CreatedAt.Format(time.RFC3339)}
+		if !s.ExpiresAt.IsZero() {
+			out.ExpiresAt = s.ExpiresAt.Format(time.RFC3339)
+		}
+		secrets = append(secrets, out)
+	}
+	return webhookEndpointResponse{ID: e.ID, ClientID: e.ClientID, URL: e.URL, EventTypes: e.EventTypes, Status: string(e.Status), Secrets: secrets, CreatedBy: e.CreatedBy, CreatedAt: e.CreatedAt.Format(time.RFC3339), UpdatedAt: e.UpdatedAt.Format(time.RFC3339)}
+}
+
+func mapWebhookDelivery(d *domain.WebhookDelivery) webhookDeliveryResponse {
+	history := make([]webhookAttemptResponse, 0, len(d.History))
+	for _, a := range d.History {
+		history = append(history, webhookAttemptResponse{At: a.At.Format(time.RFC3339), StatusCode: a.StatusCode, Error: a.Error, DurationMs: a.DurationMs})
+	}
+	out := webhookDeliveryResponse{ID: d.ID, EndpointID: d.EndpointID, EventID: d.EventID, EventType: string(d.EventType), Status: string(d.Status), Attempts: d.Attempts, History: history, CreatedAt: d.CreatedAt.Format(time.RFC3339), UpdatedAt: d.UpdatedAt.Format(time.RFC3339)}
+	if d.Status == domain.WebhookDeliveryPending {
+		out.NextAttemptAt = d.NextAttemptAt.Format(time.RFC3339)
+	}
+	return out
+}
+
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	endpoint, secret, fields, err := h.webhookService.CreateEndpoint(r.Context(), usecase.CreateWebhookInput{ActorID: currentUserID(r), ClientID: req.ClientID, URL: req.URL, EventTypes: req.EventTypes})
+	if err != nil {
+		writeWebhookError(w, err, "invalid webhook endpoint", fields)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditWebhookCreated, ActorID: currentUserID(r), TargetType: "webhook_endpoint", TargetID: endpoint.ID, Details: map[string]string{"clientId": endpoint.ClientID, "url": endpoint.URL}})
+	writeJSON(w, http.StatusCreated, map[string]any{"endpoint": mapWebhookEndpoint(endpoint), "secret": secret})
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.webhookService.ListEndpoints(r.Context(), currentUserID(r), r.URL.Query().Get("clientId"))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	out := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, e := range endpoints {
+		out = append(out, mapWebhookEndpoint(e))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"endpoints": out})
+}
+
+func (h *WebhookHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	endpoint, err := h.webhookService.DisableEndpoint(r.Context(), currentUserID(r), chi.URLParam(r, "endpointID"))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditWebhookDisabled, ActorID: currentUserID(r), TargetType: "webhook_endpoint", TargetID: endpoint.ID, Details: map[string]string{"clientId": endpoint.ClientID}})
+	writeJSON(w, http.StatusOK, map[string]any{"endpoint": mapWebhookEndpoint(endpoint)})
+}
+
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	var req rotateWebhookSecretRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	var overlap time.Duration
+	if v := strings.TrimSpace(req.Overlap); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "validation_error", "invalid secret rotation", domain.FieldErrors{"overlap": "must be a positive duration such as 24h"})
+			return
+		}
+		overlap = d
+	}
+	endpoint, secret, fields, err := h.webhookService.RotateSecret(r.Context(), usecase.RotateWebhookSecretInput{ActorID: currentUserID(r), EndpointID: chi.URLParam(r, "endpointID"), Overlap: overlap})
+	if err != nil {
+		writeWebhookError(w, err, "invalid secret rotation", fields)
+		return
+	}
+	details := map[string]string{"clientId": endpoint.ClientID}
+	for _, s := range endpoint.Secrets {
+		if !s.ExpiresAt.IsZero() {
+			details["previousExpiresAt"] = s.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+	h.audit.record(r, domain.AuditRecord{Action: domain.AuditWebhookSecretRotated, ActorID: currentUserID(r), TargetType: "webhook_endpoint", TargetID: endpoint.ID, Details: details})
+	writeJSON(w, http.StatusOK, map[string]any{"endpoint": mapWebhookEndpoint(endpoint), "secret": secret})
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), currentUserID(r), chi.URLParam(r, "endpointID"))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	out := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		out = append(out, mapWebhookDelivery(d))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deliveries": out})
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.webhookService.Redeliver(r.Context(), currentUserID(r), chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"delivery": mapWebhookDelivery(delivery)})
+}
+
+// ClientList, ClientDeliveries and ClientRedeliver serve the /partner
+// routes, where an API client works with its own endpoints.
+func (h *WebhookHandler) ClientList(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.webhookService.ListClientEndpoints(r.Context(), currentClientID(r))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	out := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, e := range endpoints {
+		out = append(out, mapWebhookEndpoint(e))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"endpoints": out})
+}
+
+func (h *WebhookHandler) ClientDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.webhookService.ListClientDeliveries(r.Context(), currentClientID(r), chi.URLParam(r, "endpointID"))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	out := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		out = append(out, mapWebhookDelivery(d))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deliveries": out})
+}
+
+func (h *WebhookHandler) ClientRedeliver(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.webhookService.RedeliverForClient(r.Context(), currentClientID(r), chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeWebhookError(w, err, "", nil)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"delivery": mapWebhookDelivery(delivery)})
+}
+
+func writeWebhookError(w http.ResponseWriter, err error, validationMessage string, fields domain.FieldErrors) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "validation_error", validationMessage, fields)
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		writeError(w, http.StatusNotFound, "webhook_not_found", "webhook endpoint not found", nil)
+	case errors.Is(err, domain.ErrDeliveryNotFound):
+		writeError(w, http.StatusNotFound, "delivery_not_found", "webhook delivery not found", nil)
+	case errors.Is(err, domain.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", "forbidden", nil)
+	case errors.Is(err, domain.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+	}
+}
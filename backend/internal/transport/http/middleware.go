@@ -1,18 +1,61 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
 
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 type ctxKeyUserID struct{}
+type ctxKeyClientID struct{}
+type ctxKeyClaims struct{}
+type ctxKeyIdentity struct{}
+
+// requestIdentity is filled in by RequireAuth and RequireClient so the request log, written
+// after the handler returns, can name who made the request.
+type requestIdentity struct {
+	userID          string
+	clientID        string
+	actorID         string
+	impersonationID string
+}
+
+func currentUserID(r *http.Request) string {
+	userID, _ := r.Context().Value(ctxKeyUserID{}).(string)
+	return userID
+}
+
+// currentClientID returns the API client a client token was issued to.
+func currentClientID(r *http.Request) string {
+	clientID, _ := r.Context().Value(ctxKeyClientID{}).(string)
+	return clientID
+}
+
+// currentDevice returns the device the request's token is bound to, if any.
+func currentDevice(r *http.Request) *auth.DeviceClaim {
+	claims, _ := r.Context().Value(ctxKeyClaims{}).(*auth.Claims)
+	if claims == nil {
+		return nil
+	}
+	return claims.Device
+}
+
+// freshAuth reports whether the request's token shows an authentication
+// within maxAge using one of methods (any when none are given).
+func freshAuth(r *http.Request, maxAge time.Duration, methods ...string) bool {
+	claims, _ := r.Context().Value(ctxKeyClaims{}).(*auth.Claims)
+	return claims != nil && claims.FreshAuth(time.Now(), maxAge, methods...)
+}
 
 type statusRecorder struct {
 	http.ResponseWriter
@@ -24,33 +67,217 @@ func (r *statusRecorder) WriteHeader(code int) { r.status = code; r.ResponseWrit
 func Recoverer() func(http.Handler) http.Handler { return middleware.Recoverer }
 func RequestID() func(http.Handler) http.Handler { return middleware.RequestID }
 
+// Logging writes one line per request. Authenticated requests carry
+// user_id, client_id for API clients, or both for an app acting for a
+// user; impersonated ones also carry the operator's actor_id and the
+// impersonation_id of the token.
 func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
-			next.ServeHTTP(rec, r)
-			logger.Info("http_request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "latency_ms", time.Since(start).Milliseconds(), "request_id", middleware.GetReqID(r.Context()))
+			id := &requestIdentity{}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), ctxKeyIdentity{}, id)))
+			attrs := []any{"method", r.Method, "path", r.URL.Path, "status", rec.status, "latency_ms", time.Since(start).Milliseconds(), "request_id", middleware.GetReqID(r.Context())}
+			if id.userID != "" {
+				attrs = append(attrs, "user_id", id.userID)
+			}
+			if id.clientID != "" {
+				attrs = append(attrs, "client_id", id.clientID)
+			}
+			if id.actorID != "" {
+				attrs = append(attrs, "actor_id", id.actorID, "impersonation_id", id.impersonationID)
+			}
+			logger.Info("http_request", attrs...)
+		})
+	}
+}
+
+// GrantChecker re-checks the grant behind a token an app holds for a user,
+// returning domain.ErrUnauthorized once it no longer stands.
+type GrantChecker interface {
+	CheckGrant(ctx context.Context, userID, clientID, grantID string) error
+}
+
+// AssertionRecorder records each device assertion as it is accepted,
+// returning domain.ErrAssertionReplayed for one already seen.
+type AssertionRecorder interface {
+	UseAssertion(ctx context.Context, a *auth.VerifiedAssertion) error
+}
+
+// RequireAuth accepts the user's own tokens only; API clients call routes
+// guarded by RequireClient, and apps acting for users those guarded by
+// RequireAuthScoped. Device assertions are recorded with assertions, when
+// set, so none is accepted twice.
+func RequireAuth(jwtMgr *auth.JWTManager, assertions AssertionRecorder) func(http.Handler) http.Handler {
+	return requireUser(jwtMgr, assertions, "", nil)
+}
+
+// RequireAuthScoped is RequireAuth that also accepts a token an app holds
+// for the user, when it grants scope and grants confirms the grant still
+// stands. With nil grants it is RequireAuth. Only read routes should
+// accept app tokens.
+func RequireAuthScoped(jwtMgr *auth.JWTManager, assertions AssertionRecorder, scope domain.Scope, grants GrantChecker) func(http.Handler) http.Handler {
+	return requireUser(jwtMgr, assertions, scope, grants)
+}
+
+func requireUser(jwtMgr *auth.JWTManager, assertions AssertionRecorder, scope domain.Scope, grants GrantChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, token, ok := authenticate(w, r, jwtMgr)
+			if !ok {
+				return
+			}
+			if claims.IsClient() || (claims.Delegated() && grants == nil) {
+				writeError(w, http.StatusForbidden, "user_token_required", "this route needs a user token", nil)
+				return
+			}
+			if id, ok := r.Context().Value(ctxKeyIdentity{}).(*requestIdentity); ok {
+				id.userID, id.clientID = claims.Sub, claims.ClientID
+				if claims.Impersonated() {
+					id.actorID, id.impersonationID = claims.Act.Sub, claims.ID
+				}
+			}
+			ctx := r.Context()
+			if claims.Delegated() {
+				if !claims.HasScope(string(scope)) {
+					writeError(w, http.StatusForbidden, "insufficient_scope", "missing scope "+string(scope), nil)
+					return
+				}
+				if err := grants.CheckGrant(ctx, claims.Sub, claims.ClientID, claims.GrantID); err != nil {
+					if errors.Is(err, domain.ErrUnauthorized) {
+						writeError(w, http.StatusUnauthorized, "unauthorized", "token revoked", nil)
+						return
+					}
+					writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+					return
+				}
+				ctx = context.WithValue(ctx, ctxKeyClientID{}, claims.ClientID)
+			}
+			// Impersonation lets an operator see what the customer sees,
+			// never act for them.
+			if claims.Impersonated() && !safeMethod(r.Method) {
+				writeError(w, http.StatusForbidden, "impersonation_read_only", "impersonation tokens are read-only", nil)
+				return
+			}
+			// Device-bound tokens are only usable with a fresh signature from
+			// the device's key over the request and its body, so a copied
+			// token or assertion alone is not enough, and each signed request
+			// is accepted once. The body is read here and put back for the
+			// handler.
+			if claims.Device != nil {
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "bad_request", "request body too large", nil)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				verified, err := auth.VerifyDeviceAssertion(claims.Device, r.Header.Get(auth.DeviceAssertionHeader), r.Method, r.URL.RequestURI(), body, token, time.Now())
+				if err != nil {
+					writeError(w, http.StatusUnauthorized, "device_assertion_invalid", "missing or invalid device assertion", nil)
+					return
+				}
+				if assertions != nil {
+					if err := assertions.UseAssertion(ctx, verified); err != nil {
+						if errors.Is(err, domain.ErrAssertionReplayed) {
+							writeError(w, http.StatusUnauthorized, "device_assertion_replayed", "device assertion already used; sign the request again", nil)
+							return
+						}
+						writeError(w, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+						return
+					}
+				}
+			}
+			ctx = context.WithValue(ctx, ctxKeyUserID{}, claims.Sub)
+			ctx = context.WithValue(ctx, ctxKeyClaims{}, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func RequireAuth(jwtMgr *auth.JWTManager) func(http.Handler) http.Handler {
+// RequireClient accepts client-credentials tokens granting scope. Use cases
+// re-check the stored client, so disabling it takes effect at once.
+func RequireClient(jwtMgr *auth.JWTManager, scope domain.Scope) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-				writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid bearer token", nil)
+			claims, _, ok := authenticate(w, r, jwtMgr)
+			if !ok {
+				return
+			}
+			if !claims.IsClient() {
+				writeError(w, http.StatusForbidden, "client_token_required", "this route needs an API client token", nil)
 				return
 			}
-			claims, err := jwtMgr.Verify(strings.TrimSpace(parts[1]))
-			if err != nil || claims.Sub == "" {
-				writeError(w, http.StatusUnauthorized, "unauthorized", "invalid token", nil)
+			if id, ok := r.Context().Value(ctxKeyIdentity{}).(*requestIdentity); ok {
+				id.clientID = claims.ClientID
+			}
+			if !claims.HasScope(string(scope)) {
+				writeError(w, http.StatusForbidden, "insufficient_scope", "missing scope "+string(scope), nil)
 				return
 			}
-			ctx := context.WithValue(r.Context(), ctxKeyUserID{}, claims.Sub)
+			ctx := context.WithValue(r.Context(), ctxKeyClientID{}, claims.ClientID)
+			ctx = context.WithValue(ctx, ctxKeyClaims{}, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// authenticate verifies the request's bearer token and, when the token is
+// limited to networks, the caller's address. It writes the error response
+// itself and reports false on failure.
+func authenticate(w http.ResponseWriter, r *http.Request, jwtMgr *auth.JWTManager) (*auth.Claims, string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid bearer token", nil)
+		return nil, "", false
+	}
+	token := strings.TrimSpace(parts[1])
+	claims, err := jwtMgr.Verify(token)
+	if err != nil || claims.Sub == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid token", nil)
+		return nil, "", false
+	}
+	if len(claims.Networks) > 0 {
+		networks, _, ok := domain.ParseNetworks(claims.Networks)
+		if !ok || !domain.NetworksAllow(networks, clientIP(r)) {
+			writeError(w, http.StatusForbidden, "network_not_allowed", "token not usable from this address", nil)
+			return nil, "", false
+		}
+	}
+	return claims, token, true
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireFreshAuth rejects requests whose token does not show a recent
+// authentication, telling the client to call POST /auth/step-up. It must run
+// after RequireAuth.
+func RequireFreshAuth(maxAge time.Duration, methods ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !freshAuth(r, maxAge, methods...) {
+				writeError(w, http.StatusForbidden, "step_up_required", "additional verification required", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission rejects requests whose token does not grant perm. It
+// must run after RequireAuth. Tokens carry the permissions of the user's
+// role when they were issued; use cases still check the stored role.
+func RequirePermission(perm domain.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(ctxKeyClaims{}).(*auth.Claims)
+			if claims == nil || !claims.HasPermission(string(perm)) {
+				writeError(w, http.StatusForbidden, "forbidden", "missing permission "+string(perm), nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
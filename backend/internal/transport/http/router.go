@@ -7,24 +7,256 @@ import (
 	"time"
 
 	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
 	"akiba/backend/internal/usecase"
 
 	"github.com/go-chi/chi/v5"
 )
 
-func NewRouter(logger *slog.Logger, authService *usecase.AuthService, jwtMgr *auth.JWTManager, readinessCheck func(context.Context) error) http.Handler {
+// Services bundles the use cases the router exposes. Auth is required; the
+// routes of any other nil service are not mounted.
+type Services struct {
+	Auth          *usecase.AuthService
+	Merchants     *usecase.MerchantService
+	Wallets       *usecase.WalletService
+	FX            *usecase.FXService
+	Fees          *usecase.FeeService
+	Transfers     *usecase.TransferService
+	Holds         *usecase.HoldService
+	Beneficiaries *usecase.BeneficiaryService
+	Reversals     *usecase.ReversalService
+	Disputes      *usecase.DisputeService
+	Monitoring    *usecase.MonitoringService
+	Risk          *usecase.RiskService
+	PINs          *usecase.PINService
+	TOTP          *usecase.TOTPService
+	Passkeys      *usecase.PasskeyService
+	Devices       *usecase.DeviceService
+	Admin         *usecase.AdminService
+	Audit         *usecase.AuditService
+	Webhooks      *usecase.WebhookService
+	APIClients    *usecase.APIClientService
+	OAuth         *usecase.OAuthService
+}
+
+func NewRouter(logger *slog.Logger, services Services, jwtMgr *auth.JWTManager, readinessCheck func(context.Context) error) http.Handler {
 	r := chi.NewRouter()
 	r.Use(RequestID())
 	r.Use(Recoverer())
 	r.Use(Logging(logger))
 
-	h := NewAuthHandler(authService)
+	h := NewAuthHandler(services.Auth, services.Audit, logger)
+	stepUp := services.Auth.StepUpConfig()
+	// Account credentials may only be changed after proving the account
+	// holder's identity, not just knowledge of the payment PIN.
+	strongAuth := RequireFreshAuth(stepUp.MaxAge, auth.MethodPassword, auth.MethodOTP, auth.MethodHardwareKey)
+	// Apps a user approved may read what their scopes cover; without OAuth
+	// those routes take the user's own tokens only.
+	var grants GrantChecker
+	if services.OAuth != nil {
+		grants = services.OAuth
+	}
+	// Device-bound tokens are only issued with device binding enabled.
+	var assertions AssertionRecorder
+	if services.Devices != nil {
+		assertions = services.Devices
+	}
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Post("/auth/signup", h.Signup)
 		r.Post("/auth/login", h.Login)
-		r.With(RequireAuth(jwtMgr)).Get("/me", h.Me)
+		r.Post("/auth/login/otp", h.VerifyLoginOTP)
+		r.With(RequireAuth(jwtMgr, assertions)).Post("/auth/step-up", h.StepUp)
+		r.With(RequireAuthScoped(jwtMgr, assertions, domain.ScopeProfile, grants)).Get("/me", h.Me)
+		r.With(RequireAuth(jwtMgr, assertions), strongAuth).Post("/me/password", h.ChangePassword)
+
+		if services.PINs != nil {
+			ph := NewPINHandler(services.PINs)
+			r.With(RequireAuth(jwtMgr, assertions), strongAuth).Post("/me/pin", ph.Set)
+			r.With(RequireAuth(jwtMgr, assertions)).Put("/me/pin", ph.Change)
+			r.With(RequireAuth(jwtMgr, assertions), strongAuth).Post("/me/pin/reset", ph.Reset)
+		}
+		if services.TOTP != nil {
+			th := NewTOTPHandler(services.TOTP)
+			r.With(RequireAuth(jwtMgr, assertions), strongAuth).Post("/me/totp", th.Enroll)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/me/totp/confirm", th.Confirm)
+			r.With(RequireAuth(jwtMgr, assertions), strongAuth).Delete("/me/totp", th.Disable)
+		}
+		if services.Devices != nil {
+			dvh := NewDeviceHandler(services.Devices)
+			r.With(RequireAuth(jwtMgr, assertions)).Get("/me/devices", dvh.List)
+			r.With(RequireAuth(jwtMgr, assertions)).Delete("/me/devices/{deviceID}", dvh.Revoke)
+		}
+		if services.Passkeys != nil {
+			pkh := NewPasskeyHandler(services.Passkeys, services.Auth, services.Audit, logger)
+			r.Post("/auth/passkey/options", pkh.LoginOptions)
+			r.Post("/auth/passkey/login", pkh.Login)
+			r.Group(func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.With(strongAuth).Post("/me/passkeys/options", pkh.RegistrationOptions)
+				r.With(strongAuth).Post("/me/passkeys", pkh.Register)
+				r.Get("/me/passkeys", pkh.List)
+				r.Delete("/me/passkeys/{passkeyID}", pkh.Delete)
+			})
+		}
+		if services.Merchants != nil {
+			mh := NewMerchantHandler(services.Merchants)
+			r.Group(func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.Post("/merchants", mh.Create)
+				r.Get("/merchants", mh.List)
+				r.Post("/merchants/{merchantID}/qr", mh.GenerateQR)
+				r.Get("/merchants/{merchantID}/settlements", mh.SettlementReport)
+				r.Post("/payments/qr", mh.PayQR)
+			})
+		}
+		if services.Wallets != nil {
+			wh := NewWalletHandler(services.Wallets)
+			r.With(RequireAuthScoped(jwtMgr, assertions, domain.ScopeAccountsRead, grants)).Get("/me/accounts", wh.List)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/me/accounts", wh.Open)
+		}
+		if services.FX != nil {
+			fh := NewFXHandler(services.FX)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/fx/quotes", fh.Quote)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/fx/conversions", fh.Convert)
+		}
+		if services.Fees != nil {
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/fees/preview", NewFeeHandler(services.Fees).Preview)
+		}
+		if services.Transfers != nil {
+			th := NewTransferHandler(services.Transfers, stepUp)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/transfers", th.Transfer)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/withdrawals", th.Withdraw)
+		}
+		if services.Beneficiaries != nil {
+			bh := NewBeneficiaryHandler(services.Beneficiaries)
+			r.Group(func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.With(RequireFreshAuth(stepUp.MaxAge)).Post("/me/beneficiaries", bh.Create)
+				r.Get("/me/beneficiaries", bh.List)
+				r.Get("/me/beneficiaries/{beneficiaryID}", bh.Get)
+				r.Patch("/me/beneficiaries/{beneficiaryID}", bh.Rename)
+				r.Delete("/me/beneficiaries/{beneficiaryID}", bh.Delete)
+			})
+		}
+		if services.Holds != nil {
+			hh := NewHoldHandler(services.Holds)
+			r.Group(func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.Post("/payments/authorizations", hh.AuthorizePayment)
+				r.Get("/holds/{holdID}", hh.Get)
+				r.Post("/holds/{holdID}/capture", hh.Capture)
+				r.Post("/holds/{holdID}/void", hh.Void)
+			})
+		}
+		if services.Reversals != nil && services.Disputes != nil {
+			dh := NewDisputeHandler(services.Reversals, services.Disputes)
+			r.Group(func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.Post("/transactions/{entryID}/reversal", dh.Reverse)
+				r.Post("/transactions/{entryID}/refunds", dh.Refund)
+				r.Post("/disputes", dh.Open)
+				r.Get("/disputes", dh.List)
+				r.Get("/disputes/{disputeID}", dh.Get)
+				r.Post("/disputes/{disputeID}/evidence", dh.AddEvidence)
+				r.Get("/disputes/{disputeID}/evidence/{evidenceID}", dh.DownloadEvidence)
+				r.Post("/disputes/{disputeID}/review", dh.StartReview)
+				r.Post("/disputes/{disputeID}/resolve", dh.Resolve)
+			})
+		}
+		if services.Monitoring != nil {
+			ah := NewAMLHandler(services.Monitoring)
+			r.Group(func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.Get("/aml/rules", ah.Rules)
+				r.Post("/aml/replay", ah.Replay)
+				r.Get("/aml/alerts", ah.ListAlerts)
+				r.Get("/aml/alerts/{alertID}", ah.GetAlert)
+				r.Post("/aml/alerts/{alertID}/assign", ah.Assign)
+				r.Post("/aml/alerts/{alertID}/close", ah.Close)
+			})
+		}
+		if services.Risk != nil {
+			rh := NewRiskHandler(services.Risk)
+			r.With(RequireAuth(jwtMgr, assertions)).Get("/risk/decisions", rh.List)
+			r.With(RequireAuth(jwtMgr, assertions)).Get("/risk/decisions/{decisionID}", rh.Get)
+		}
+		if services.APIClients != nil && services.OAuth != nil {
+			oh := NewOAuthHandler(services.APIClients, services.OAuth, services.Audit, logger)
+			r.With(RequireAuth(jwtMgr, assertions)).Get("/me/consents", oh.ListConsents)
+			r.With(RequireAuth(jwtMgr, assertions)).Delete("/me/consents/{consentID}", oh.RevokeConsent)
+		}
+		if services.Webhooks != nil {
+			wh := NewWebhookHandler(services.Webhooks, services.Audit, logger)
+			r.With(RequireClient(jwtMgr, domain.ScopeWebhooksRead)).Get("/partner/webhooks", wh.ClientList)
+			r.With(RequireClient(jwtMgr, domain.ScopeWebhooksRead)).Get("/partner/webhooks/{endpointID}/deliveries", wh.ClientDeliveries)
+			r.With(RequireClient(jwtMgr, domain.ScopeWebhooksWrite)).Post("/partner/webhooks/deliveries/{deliveryID}/redeliver", wh.ClientRedeliver)
+		}
+		if services.Admin != nil {
+			adh := NewAdminHandler(services.Admin, services.Audit, logger)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(RequireAuth(jwtMgr, assertions))
+				r.With(RequirePermission(domain.PermUsersRead)).Get("/users", adh.SearchUsers)
+				r.With(RequirePermission(domain.PermUsersRead)).Get("/users/{userID}", adh.GetUser)
+				r.With(RequirePermission(domain.PermUsersWrite)).Put("/users/{userID}/status", adh.SetStatus)
+				r.With(RequirePermission(domain.PermUsersWrite), strongAuth).Post("/users/{userID}/close", adh.Close)
+				r.With(RequirePermission(domain.PermUsersRead)).Get("/users/{userID}/status-events", adh.StatusHistory)
+				r.With(RequirePermission(domain.PermUsersImpersonate), strongAuth).Post("/users/{userID}/impersonate", adh.Impersonate)
+				r.With(RequirePermission(domain.PermRolesWrite), strongAuth).Put("/users/{userID}/role", adh.SetRole)
+				r.With(RequirePermission(domain.PermKYCReview)).Get("/kyc", adh.KYCQueue)
+				r.With(RequirePermission(domain.PermKYCReview)).Post("/users/{userID}/kyc", adh.ReviewKYC)
+				r.With(RequirePermission(domain.PermLedgerRead)).Get("/users/{userID}/accounts", adh.ListAccounts)
+				r.With(RequirePermission(domain.PermLedgerRead)).Get("/accounts/{accountID}/entries", adh.AccountEntries)
+				r.With(RequirePermission(domain.PermLedgerRead)).Get("/entries/{entryID}", adh.GetEntry)
+				if services.Audit != nil {
+					auh := NewAuditHandler(services.Audit)
+					r.With(RequirePermission(domain.PermAuditRead)).Get("/audit", auh.Search)
+				}
+				if services.Webhooks != nil {
+					wh := NewWebhookHandler(services.Webhooks, services.Audit, logger)
+					r.Group(func(r chi.Router) {
+						r.Use(RequirePermission(domain.PermWebhooksManage))
+						r.Get("/webhooks", wh.List)
+						r.With(strongAuth).Post("/webhooks", wh.Create)
+						r.Post("/webhooks/{endpointID}/disable", wh.Disable)
+						r.With(strongAuth).Post("/webhooks/{endpointID}/rotate-secret", wh.RotateSecret)
+						r.Get("/webhooks/{endpointID}/deliveries", wh.ListDeliveries)
+						r.Post("/webhooks/deliveries/{deliveryID}/redeliver", wh.Redeliver)
+					})
+				}
+				if services.APIClients != nil {
+					ach := NewAPIClientHandler(services.APIClients, services.Audit, logger)
+					r.Group(func(r chi.Router) {
+						r.Use(RequirePermission(domain.PermAPIClientsManage))
+						r.Get("/api-clients", ach.List)
+						r.With(strongAuth).Post("/api-clients", ach.Create)
+						r.Get("/api-clients/{clientID}", ach.Get)
+						r.With(strongAuth).Post("/api-clients/{clientID}/keys", ach.CreateKey)
+						r.Delete("/api-clients/{clientID}/keys/{keyPrefix}", ach.RevokeKey)
+						r.Post("/api-clients/{clientID}/disable", ach.Disable)
+					})
+				}
+			})
+		}
 	})
 
+	if services.APIClients != nil {
+		oh := NewOAuthHandler(services.APIClients, services.OAuth, services.Audit, logger)
+		r.Post("/oauth/token", oh.Token)
+		if services.OAuth != nil {
+			r.With(RequireAuth(jwtMgr, assertions)).Get("/oauth/authorize", oh.AuthorizePreview)
+			r.With(RequireAuth(jwtMgr, assertions)).Post("/oauth/authorize", oh.Authorize)
+			r.Post("/oauth/revoke", oh.Revoke)
+			r.Post("/oauth/introspect", oh.Introspect)
+			if services.OAuth.OIDCEnabled() {
+				r.Get("/.well-known/openid-configuration", oh.Discovery)
+				r.Get("/.well-known/jwks.json", oh.JWKS)
+				userInfo := RequireAuthScoped(jwtMgr, assertions, domain.ScopeOpenID, grants)
+				r.With(userInfo).Get("/userinfo", oh.UserInfo)
+				r.With(userInfo).Post("/userinfo", oh.UserInfo)
+			}
+		}
+	}
+
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 	})
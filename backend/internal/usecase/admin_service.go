@@ -0,0 +1,509 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const (
+	defaultUserSearchLimit = 50
+	maxUserSearchLimit     = 200
+	maxKYCNoteLength       = 500
+	maxImpersonationReason = 500
+	maxStatusReasonLength  = 500
+	maxLedgerLookupWindow  = 93 * 24 * time.Hour
+)
+
+// SetUserStatusInput moves a user to Status. Reason is kept on the
+// lifecycle event.
+type SetUserStatusInput struct {
+	ActorID string
+	UserID  string
+	Status  string
+	Reason  string
+}
+
+// CloseAccountInput closes a user's account. PayoutPhone receives any
+// remaining wallet balances and is required while they are non-zero.
+type CloseAccountInput struct {
+	ActorID     string
+	UserID      string
+	Reason      string
+	PayoutPhone string
+}
+
+type SetUserRoleInput struct {
+	ActorID string
+	UserID  string
+	Role    string
+}
+
+// ReviewKYCInput decides a user's KYC review. Decision is approved or
+// rejected; a rejection needs a Note explaining it.
+type ReviewKYCInput struct {
+	ActorID  string
+	UserID   string
+	Decision string
+	Note     string
+}
+
+// ImpersonateInput asks for a token to act as UserID. Reason is logged
+// with the token's ID so every request under it can be traced back.
+type ImpersonateInput struct {
+	ActorID string
+	UserID  string
+	Reason  string
+}
+
+// Impersonation is a read-only token for User held by ActorID. ID is the
+// token's jti.
+type Impersonation struct {
+	ID          string
+	ActorID     string
+	User        *domain.User
+	Reason      string
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+// AdminConfig sets how long impersonation tokens live.
+type AdminConfig struct {
+	ImpersonationTTL time.Duration
+}
+
+type AccountEntriesInput struct {
+	ActorID   string
+	AccountID string
+	From      time.Time
+	To        time.Time
+}
+
+// AdminService backs the operator console. Every method checks the actor's
+// stored role, so the permissions in a token only decide which routes are
+// reachable.
+type AdminService struct {
+	users     repository.UserRepository
+	ledger    repository.LedgerRepository
+	merchants repository.MerchantRepository
+	jwt       *auth.JWTManager
+	cfg       AdminConfig
+	now       func() time.Time
+}
+
+func NewAdminService(users repository.UserRepository, ledger repository.LedgerRepository, merchants repository.MerchantRepository, jwtMgr *auth.JWTManager, cfg AdminConfig) *AdminService {
+	return &AdminService{users: users, ledger: ledger, merchants: merchants, jwt: jwtMgr, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+func (s *AdminService) SearchUsers(ctx context.Context, actorID string, filter domain.UserFilter) ([]*domain.User, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermUsersRead); err != nil {
+		return nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Status != "" && !filter.Status.Valid() {
+		fields["status"] = "must be pending_verification, active, frozen, suspended, closing or closed"
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		fields["role"] = "must be customer, support, compliance or admin"
+	}
+	if filter.KYCStatus != "" && !filter.KYCStatus.Valid() {
+		fields["kycStatus"] = "must be pending, approved or rejected"
+	}
+	if filter.Limit < 0 || filter.Limit > maxUserSearchLimit {
+		fields["limit"] = "must be between 1 and 200"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultUserSearchLimit
+	}
+	users, err := s.users.Search(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, nil, nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, actorID, userID string) (*domain.User, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermUsersRead); err != nil {
+		return nil, err
+	}
+	return s.users.GetByID(ctx, userID)
+}
+
+// SetUserStatus moves a user along their lifecycle and records the
+// transition. Suspended users cannot log in and frozen users cannot move
+// money; tokens they already hold stay valid until they expire, but every
+// money-moving use case reads the stored status. Closing goes through
+// CloseAccount so balances are settled first; reopening a closing or
+// closed account makes it active. Changing another operator's
+// status needs PermRolesWrite, so support cannot lock out an admin, and
+// nobody can change their own.
+func (s *AdminService) SetUserStatus(ctx context.Context, in SetUserStatusInput) (*domain.User, *domain.UserStatusEvent, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermUsersWrite); err != nil {
+		return nil, nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	status := domain.UserStatus(strings.ToLower(strings.TrimSpace(in.Status)))
+	if !status.Valid() {
+		fields["status"] = "must be active, frozen or suspended"
+	} else if status == domain.UserStatusClosing || status == domain.UserStatusClosed {
+		fields["status"] = "use the close endpoint to close an account"
+	}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" || len(reason) > maxStatusReasonLength {
+		fields["reason"] = "must be 1-500 characters"
+	}
+	if len(fields) > 0 {
+		return nil, nil, fields, domain.ErrInvalidInput
+	}
+	user, err := s.lifecycleTarget(ctx, in.ActorID, in.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !user.Status.CanTransition(status) {
+		return nil, nil, nil, domain.ErrInvalidTransition
+	}
+	event, err := s.transition(ctx, user, status, in.ActorID, reason, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return user, event, nil, nil
+}
+
+// CloseAccount closes a user's account. Wallets and merchant accounts with
+// active holds block closing until the holds are captured or voided. Any
+// remaining balance is posted to payout clearing for PayoutPhone, which
+// needs PermPaymentsOperate; an account whose balances are all empty closes
+// without one. The user is moved to closing first, so no payment can reach
+// them while balances are paid out, and only marked closed once every
+// balance is empty. If a payment that was already under way lands
+// meanwhile, the user stays closing and closing again pays it out.
+func (s *AdminService) CloseAccount(ctx context.Context, in CloseAccountInput) (*domain.User, *domain.UserStatusEvent, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermUsersWrite); err != nil {
+		return nil, nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" || len(reason) > maxStatusReasonLength {
+		fields["reason"] = "must be 1-500 characters"
+	}
+	phone := domain.NormalizePhone(in.PayoutPhone)
+	if phone != "" && !domain.ValidatePhoneE164(phone) {
+		fields["payoutPhone"] = "must be valid E.164 format"
+	}
+	if len(fields) > 0 {
+		return nil, nil, fields, domain.ErrInvalidInput
+	}
+	user, err := s.lifecycleTarget(ctx, in.ActorID, in.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if user.Status != domain.UserStatusClosing && !user.Status.CanTransition(domain.UserStatusClosing) {
+		return nil, nil, nil, domain.ErrInvalidTransition
+	}
+	funded, fields, err := s.closingBalances(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fields, err
+	}
+	if len(funded) > 0 {
+		if phone == "" {
+			return nil, nil, domain.FieldErrors{"payoutPhone": "is required while balances remain"}, domain.ErrBalanceRemaining
+		}
+		if err := requirePermission(ctx, s.users, in.ActorID, domain.PermPaymentsOperate); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if user.Status != domain.UserStatusClosing {
+		if _, err := s.transition(ctx, user, domain.UserStatusClosing, in.ActorID, reason, nil); err != nil {
+			return nil, nil, nil, err
+		}
+		// Balances are read again now no new payment can arrive.
+		if funded, fields, err = s.closingBalances(ctx, user.ID); err != nil {
+			return nil, nil, fields, err
+		}
+	}
+	var entryIDs []string
+	now := s.now()
+	for _, a := range funded {
+		entry, err := s.payOut(ctx, user.ID, a, phone, in.ActorID, now)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		entryIDs = append(entryIDs, entry.ID)
+	}
+	if remaining, fields, err := s.closingBalances(ctx, user.ID); err != nil || len(remaining) > 0 {
+		if err == nil {
+			fields, err = domain.FieldErrors{"balances": "a payment arrived while closing; close again to pay it out"}, domain.ErrBalanceRemaining
+		}
+		return nil, nil, fields, err
+	}
+	event, err := s.transition(ctx, user, domain.UserStatusClosed, in.ActorID, reason, entryIDs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return user, event, nil, nil
+}
+
+// StatusHistory lists a user's lifecycle transitions, oldest first.
+func (s *AdminService) StatusHistory(ctx context.Context, actorID, userID string) ([]*domain.UserStatusEvent, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermUsersRead); err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.users.ListStatusEvents(ctx, user.ID)
+}
+
+// SetUserRole changes a user's role. The new permissions reach the user's
+// token at their next login; operator use cases see the change at once.
+func (s *AdminService) SetUserRole(ctx context.Context, in SetUserRoleInput) (*domain.User, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermRolesWrite); err != nil {
+		return nil, nil, err
+	}
+	role := domain.UserRole(strings.ToLower(strings.TrimSpace(in.Role)))
+	if !role.Valid() {
+		return nil, domain.FieldErrors{"role": "must be customer, support, compliance or admin"}, domain.ErrInvalidInput
+	}
+	user, err := s.target(ctx, in.ActorID, in.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := s.now()
+	if err := s.users.SetRole(ctx, user.ID, role, now); err != nil {
+		return nil, nil, err
+	}
+	user.Role, user.UpdatedAt = role, now
+	return user, nil, nil
+}
+
+// ReviewKYC records a KYC decision. Approval activates a user still pending
+// verification. A decided review may be decided again, for example to
+// reject a user approved in error; that does not change their status.
+func (s *AdminService) ReviewKYC(ctx context.Context, in ReviewKYCInput) (*domain.User, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermKYCReview); err != nil {
+		return nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	decision := domain.KYCStatus(strings.ToLower(strings.TrimSpace(in.Decision)))
+	if decision != domain.KYCStatusApproved && decision != domain.KYCStatusRejected {
+		fields["decision"] = "must be approved or rejected"
+	}
+	note := strings.TrimSpace(in.Note)
+	if len(note) > maxKYCNoteLength {
+		fields["note"] = "must be at most 500 characters"
+	} else if decision == domain.KYCStatusRejected && note == "" {
+		fields["note"] = "is required when rejecting"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	user, err := s.target(ctx, in.ActorID, in.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := s.now()
+	if err := s.users.SetKYC(ctx, user.ID, decision, note, in.ActorID, now); err != nil {
+		return nil, nil, err
+	}
+	user.KYCStatus, user.KYCNote, user.KYCReviewedBy, user.KYCReviewedAt, user.UpdatedAt = decision, note, in.ActorID, now, now
+	if decision == domain.KYCStatusApproved && user.Status == domain.UserStatusPendingVerification {
+		if _, err := s.transition(ctx, user, domain.UserStatusActive, in.ActorID, "kyc approved", nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return user, nil, nil
+}
+
+// Impersonate issues a short-lived token that acts as a customer, with the
+// operator in its act claim. The token has no permissions and no
+// authentication methods, so it cannot pass step-up checks, and the
+// transport refuses it on anything but reads. Operators cannot be
+// impersonated.
+func (s *AdminService) Impersonate(ctx context.Context, in ImpersonateInput) (*Impersonation, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermUsersImpersonate); err != nil {
+		return nil, nil, err
+	}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" || len(reason) > maxImpersonationReason {
+		return nil, domain.FieldErrors{"reason": "must be 1-500 characters"}, domain.ErrInvalidInput
+	}
+	user, err := s.target(ctx, in.ActorID, in.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Role != domain.UserRoleCustomer {
+		return nil, nil, domain.ErrForbidden
+	}
+	id, err := newImpersonationID()
+	if err != nil {
+		return nil, nil, err
+	}
+	expiresAt := s.now().Add(s.cfg.ImpersonationTTL)
+	token, err := s.jwt.IssueToken(user.ID, s.cfg.ImpersonationTTL, auth.TokenOptions{ID: id, Role: string(user.Role), Actor: in.ActorID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Impersonation{ID: id, ActorID: in.ActorID, User: user, Reason: reason, AccessToken: token, ExpiresAt: expiresAt}, nil, nil
+}
+
+// ListAccounts returns every ledger account ownerID holds, wallets and
+// merchant accounts alike, with their active holds.
+func (s *AdminService) ListAccounts(ctx context.Context, actorID, ownerID string) ([]AccountBalance, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermLedgerRead); err != nil {
+		return nil, err
+	}
+	accounts, err := s.ledger.ListAccountsByOwner(ctx, strings.TrimSpace(ownerID))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AccountBalance, 0, len(accounts))
+	for _, a := range accounts {
+		holds, err := s.ledger.ListActiveHolds(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, AccountBalance{Account: a, Holds: holds})
+	}
+	return out, nil
+}
+
+// AccountEntries returns the account and the entries that touched it in
+// [From, To), which may span at most 93 days.
+func (s *AdminService) AccountEntries(ctx context.Context, in AccountEntriesInput) (*domain.Account, []*domain.JournalEntry, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermLedgerRead); err != nil {
+		return nil, nil, nil, err
+	}
+	if in.From.IsZero() || in.To.IsZero() || !in.From.Before(in.To) {
+		return nil, nil, domain.FieldErrors{"from": "must be before to"}, domain.ErrInvalidInput
+	}
+	if in.To.Sub(in.From) > maxLedgerLookupWindow {
+		return nil, nil, domain.FieldErrors{"to": "must be at most 93 days after from"}, domain.ErrInvalidInput
+	}
+	account, err := s.ledger.GetAccount(ctx, in.AccountID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	entries, err := s.ledger.ListEntriesByAccount(ctx, account.ID, in.From.UTC(), in.To.UTC())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return account, entries, nil, nil
+}
+
+func (s *AdminService) GetEntry(ctx context.Context, actorID, entryID string) (*domain.JournalEntry, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermLedgerRead); err != nil {
+		return nil, err
+	}
+	return s.ledger.GetEntry(ctx, entryID)
+}
+
+// target loads the user an operator acts on. Operators may not act on
+// themselves, so no one can raise their own role or undo their own
+// suspension.
+func (s *AdminService) target(ctx context.Context, actorID, userID string) (*domain.User, error) {
+	if strings.TrimSpace(userID) == actorID {
+		return nil, domain.ErrForbidden
+	}
+	return s.users.GetByID(ctx, userID)
+}
+
+// lifecycleTarget loads the user whose status an operator changes.
+// Operators' statuses need PermRolesWrite.
+func (s *AdminService) lifecycleTarget(ctx context.Context, actorID, userID string) (*domain.User, error) {
+	user, err := s.target(ctx, actorID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != domain.UserRoleCustomer {
+		if err := requirePermission(ctx, s.users, actorID, domain.PermRolesWrite); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// transition moves user to status and records why, returning the event.
+func (s *AdminService) transition(ctx context.Context, user *domain.User, status domain.UserStatus, actorID, reason string, entryIDs []string) (*domain.UserStatusEvent, error) {
+	now := s.now()
+	event := &domain.UserStatusEvent{UserID: user.ID, From: user.Status, To: status, Reason: reason, ActorID: actorID, EntryIDs: entryIDs, CreatedAt: now}
+	if err := s.users.TransitionStatus(ctx, event); err != nil {
+		return nil, err
+	}
+	user.Status, user.UpdatedAt = status, now
+	return event, nil
+}
+
+// closingBalances lists the funded accounts of userID's wallets and
+// merchants. An account with active holds fails with
+// domain.ErrBalanceRemaining.
+func (s *AdminService) closingBalances(ctx context.Context, userID string) ([]*domain.Account, domain.FieldErrors, error) {
+	accounts, err := s.ledger.ListAccountsByOwner(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	merchants, err := s.merchants.ListByOwner(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range merchants {
+		owned, err := s.ledger.ListAccountsByOwner(ctx, m.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		accounts = append(accounts, owned...)
+	}
+	var funded []*domain.Account
+	for _, a := range accounts {
+		if a.Held > 0 {
+			return nil, domain.FieldErrors{"holds": "active holds must be captured or voided first"}, domain.ErrBalanceRemaining
+		}
+		if a.Balance > 0 {
+			funded = append(funded, a)
+		}
+	}
+	return funded, nil, nil
+}
+
+// payOut moves the whole balance of a closing account to payout clearing.
+// The reference carries the time so a reopened account can be closed
+// again.
+func (s *AdminService) payOut(ctx context.Context, userID string, account *domain.Account, phone, actorID string, now time.Time) (*domain.JournalEntry, error) {
+	clearing, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerPayoutClearing, domain.AccountTypeSystem, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	entry := &domain.JournalEntry{
+		Kind:      domain.EntryKindClosurePayout,
+		Reference: "closure:" + account.ID + ":" + strconv.FormatInt(now.UnixNano(), 10),
+		Postings: []domain.Posting{
+			{AccountID: account.ID, Amount: -account.Balance, Currency: account.Currency},
+			{AccountID: clearing.ID, Amount: account.Balance, Currency: account.Currency},
+		},
+		Metadata:  map[string]string{"userId": userID, "phone": phone, "actorId": actorID},
+		CreatedAt: now,
+	}
+	if err := s.ledger.Post(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func newImpersonationID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "imp_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+)
+
+func TestReviewKYC(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewAdminService(st.users, st.ledger, st.merchants, auth.NewJWTManager("secret", "test"), AdminConfig{ImpersonationTTL: 15 * time.Minute})
+	c1 := addUser(t, st.users, domain.User{UsernameLower: "wanjiku", Status: domain.UserStatusPendingVerification, KYCStatus: domain.KYCStatusPending})
+	k1 := addUser(t, st.users, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance, KYCStatus: domain.KYCStatusApproved})
+
+	if _, fields, err := svc.ReviewKYC(ctx, ReviewKYCInput{ActorID: k1, UserID: c1, Decision: "rejected"}); !errors.Is(err, domain.ErrInvalidInput) || fields["note"] == "" {
+		t.Fatalf("expected rejection without note refused, got %v %v", fields, err)
+	}
+	if _, _, err := svc.ReviewKYC(ctx, ReviewKYCInput{ActorID: c1, UserID: c1, Decision: "approved"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customer refused, got %v", err)
+	}
+	if _, _, err := svc.ReviewKYC(ctx, ReviewKYCInput{ActorID: k1, UserID: k1, Decision: "approved"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected self review refused, got %v", err)
+	}
+	user, _, err := svc.ReviewKYC(ctx, ReviewKYCInput{ActorID: k1, UserID: c1, Decision: "Approved", Note: " documents match "})
+	if err != nil {
+		t.Fatalf("review: %v", err)
+	}
+	stored := getUser(t, st.users, c1)
+	if user.KYCStatus != domain.KYCStatusApproved || stored.KYCStatus != domain.KYCStatusApproved || stored.KYCReviewedBy != k1 || stored.KYCNote != "documents match" || stored.KYCReviewedAt.IsZero() {
+		t.Fatalf("unexpected review recorded: %#v", stored)
+	}
+	events, _ := st.users.ListStatusEvents(ctx, c1)
+	if stored.Status != domain.UserStatusActive || len(events) != 1 || events[0].From != domain.UserStatusPendingVerification || events[0].ActorID != k1 {
+		t.Fatalf("expected approval to activate c1, got %s %#v", stored.Status, events)
+	}
+
+	queue, _, err := svc.SearchUsers(ctx, k1, domain.UserFilter{KYCStatus: domain.KYCStatusPending})
+	if err != nil || len(queue) != 0 {
+		t.Fatalf("expected empty queue, got %v %v", queue, err)
+	}
+}
+
+func TestSetUserRoleAndStatus(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewAdminService(st.users, st.ledger, st.merchants, auth.NewJWTManager("secret", "test"), AdminConfig{ImpersonationTTL: 15 * time.Minute})
+	c1 := addUser(t, st.users, domain.User{UsernameLower: "wanjiku", Status: domain.UserStatusPendingVerification, KYCStatus: domain.KYCStatusPending})
+	k1 := addUser(t, st.users, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance, KYCStatus: domain.KYCStatusApproved})
+	a1 := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin, KYCStatus: domain.KYCStatusApproved})
+
+	if _, _, err := svc.SetUserRole(ctx, SetUserRoleInput{ActorID: k1, UserID: c1, Role: "support"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected compliance refused role change, got %v", err)
+	}
+	if _, fields, err := svc.SetUserRole(ctx, SetUserRoleInput{ActorID: a1, UserID: c1, Role: "root"}); !errors.Is(err, domain.ErrInvalidInput) || fields["role"] == "" {
+		t.Fatalf("expected unknown role refused, got %v", err)
+	}
+	if _, _, err := svc.SetUserRole(ctx, SetUserRoleInput{ActorID: a1, UserID: a1, Role: "customer"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected own role change refused, got %v", err)
+	}
+	if _, _, err := svc.SetUserRole(ctx, SetUserRoleInput{ActorID: a1, UserID: c1, Role: "support"}); err != nil || getUser(t, st.users, c1).Role != domain.UserRoleSupport {
+		t.Fatalf("expected c1 promoted, got %v", err)
+	}
+
+	// c1 is an operator now, so compliance may no longer suspend them.
+	if _, _, _, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: k1, UserID: c1, Status: "suspended", Reason: "leaver"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected compliance refused suspending an operator, got %v", err)
+	}
+	if _, _, _, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: a1, UserID: c1, Status: "suspended", Reason: "leaver"}); err != nil || getUser(t, st.users, c1).Status != domain.UserStatusSuspended {
+		t.Fatalf("expected c1 suspended, got %v", err)
+	}
+
+	// A suspended operator cannot log in, so their role no longer counts.
+	if _, err := svc.GetUser(ctx, c1, a1); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected suspended operator refused, got %v", err)
+	}
+}
+
+func TestUserLifecycleTransitions(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewAdminService(st.users, st.ledger, st.merchants, auth.NewJWTManager("secret", "test"), AdminConfig{ImpersonationTTL: 15 * time.Minute})
+	c1 := addUser(t, st.users, domain.User{UsernameLower: "wanjiku", Status: domain.UserStatusPendingVerification, KYCStatus: domain.KYCStatusPending})
+	k1 := addUser(t, st.users, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance, KYCStatus: domain.KYCStatusApproved})
+	a1 := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin, KYCStatus: domain.KYCStatusApproved})
+	setStatus(t, st.users, c1, domain.UserStatusActive)
+
+	if _, _, fields, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: k1, UserID: c1, Status: "frozen"}); !errors.Is(err, domain.ErrInvalidInput) || fields["reason"] == "" {
+		t.Fatalf("expected reason required, got %v %v", fields, err)
+	}
+	for _, status := range []string{"closing", "closed"} {
+		if _, _, fields, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: k1, UserID: c1, Status: status, Reason: "request"}); !errors.Is(err, domain.ErrInvalidInput) || fields["status"] == "" {
+			t.Fatalf("expected %s refused here, got %v %v", status, fields, err)
+		}
+	}
+	_, event, _, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: k1, UserID: c1, Status: "frozen", Reason: " aml review "})
+	if err != nil || event.From != domain.UserStatusActive || event.To != domain.UserStatusFrozen || event.Reason != "aml review" || event.ActorID != k1 {
+		t.Fatalf("unexpected freeze %#v %v", event, err)
+	}
+	if _, _, _, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: k1, UserID: c1, Status: "pending_verification", Reason: "redo"}); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("expected frozen to pending refused, got %v", err)
+	}
+	if _, _, _, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: k1, UserID: c1, Status: "active", Reason: "cleared"}); err != nil {
+		t.Fatalf("unfreeze: %v", err)
+	}
+	history, err := svc.StatusHistory(ctx, a1, c1)
+	if err != nil || len(history) != 3 || history[1].To != domain.UserStatusFrozen || history[2].To != domain.UserStatusActive {
+		t.Fatalf("unexpected history %#v %v", history, err)
+	}
+}
+
+func TestCloseAccountRequiresEmptyWalletsOrPayout(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewAdminService(st.users, st.ledger, st.merchants, auth.NewJWTManager("secret", "test"), AdminConfig{ImpersonationTTL: 15 * time.Minute})
+	c1 := addUser(t, st.users, domain.User{UsernameLower: "wanjiku", Status: domain.UserStatusPendingVerification, KYCStatus: domain.KYCStatusPending})
+	k1 := addUser(t, st.users, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance, KYCStatus: domain.KYCStatusApproved})
+	setStatus(t, st.users, c1, domain.UserStatusActive)
+	s1 := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	fund(t, st.ledger, c1, "KES", 5000)
+	shop := addMerchant(t, st.merchants, c1)
+	house, _ := st.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerPrefix+"funding", domain.AccountTypeSystem, "KES")
+	till, _ := st.ledger.GetOrCreateAccount(ctx, shop.ID, domain.AccountTypeMerchant, "KES")
+	if err := st.ledger.Post(ctx, &domain.JournalEntry{Kind: "test_funding", Postings: []domain.Posting{{AccountID: house.ID, Amount: -700, Currency: "KES"}, {AccountID: till.ID, Amount: 700, Currency: "KES"}}, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, fields, err := svc.CloseAccount(ctx, CloseAccountInput{ActorID: s1, UserID: c1, Reason: "customer request"}); !errors.Is(err, domain.ErrBalanceRemaining) || fields["payoutPhone"] == "" {
+		t.Fatalf("expected balance remaining, got %v %v", fields, err)
+	}
+	// Compliance may close empty accounts but cannot pay funds out.
+	if _, _, _, err := svc.CloseAccount(ctx, CloseAccountInput{ActorID: k1, UserID: c1, Reason: "customer request", PayoutPhone: "+254700000001"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected compliance refused payout, got %v", err)
+	}
+	user, event, _, err := svc.CloseAccount(ctx, CloseAccountInput{ActorID: s1, UserID: c1, Reason: "customer request", PayoutPhone: "+254700000001"})
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	wallet, _ := st.ledger.GetOrCreateAccount(ctx, c1, domain.AccountTypeWallet, "KES")
+	till, _ = st.ledger.GetOrCreateAccount(ctx, shop.ID, domain.AccountTypeMerchant, "KES")
+	if user.Status != domain.UserStatusClosed || wallet.Balance != 0 || till.Balance != 0 || len(event.EntryIDs) != 2 {
+		t.Fatalf("expected the wallet and the merchant paid out, got %#v %#v", user, event)
+	}
+	for _, id := range event.EntryIDs {
+		payout, err := st.ledger.GetEntry(ctx, id)
+		if err != nil || payout.Kind != domain.EntryKindClosurePayout || payout.Metadata["phone"] != "+254700000001" {
+			t.Fatalf("unexpected payout %#v %v", payout, err)
+		}
+	}
+	history, _ := svc.StatusHistory(ctx, s1, c1)
+	if n := len(history); n < 2 || history[n-2].To != domain.UserStatusClosing || history[n-1].To != domain.UserStatusClosed {
+		t.Fatalf("expected the account closing before it closed, got %#v", history)
+	}
+	if _, _, _, err := svc.CloseAccount(ctx, CloseAccountInput{ActorID: s1, UserID: c1, Reason: "again"}); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("expected closed account not closed twice, got %v", err)
+	}
+	if _, _, _, err := svc.SetUserStatus(ctx, SetUserStatusInput{ActorID: s1, UserID: c1, Status: "active", Reason: "reopened on request"}); err != nil || getUser(t, st.users, c1).Status != domain.UserStatusActive {
+		t.Fatalf("expected reopen, got %v", err)
+	}
+}
+
+func TestImpersonateOnlyCustomers(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewAdminService(st.users, st.ledger, st.merchants, auth.NewJWTManager("secret", "test"), AdminConfig{ImpersonationTTL: 15 * time.Minute})
+	c1 := addUser(t, st.users, domain.User{UsernameLower: "wanjiku", Status: domain.UserStatusPendingVerification, KYCStatus: domain.KYCStatusPending})
+	k1 := addUser(t, st.users, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance, KYCStatus: domain.KYCStatusApproved})
+	a1 := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin, KYCStatus: domain.KYCStatusApproved})
+	s1 := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+
+	if _, _, err := svc.Impersonate(ctx, ImpersonateInput{ActorID: k1, UserID: c1, Reason: "ticket"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected compliance refused, got %v", err)
+	}
+	if _, _, err := svc.Impersonate(ctx, ImpersonateInput{ActorID: s1, UserID: a1, Reason: "ticket"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected operator target refused, got %v", err)
+	}
+	imp, _, err := svc.Impersonate(ctx, ImpersonateInput{ActorID: s1, UserID: c1, Reason: " ticket 12 "})
+	if err != nil {
+		t.Fatalf("impersonate: %v", err)
+	}
+	claims, err := auth.NewJWTManager("secret", "test").Verify(imp.AccessToken)
+	if err != nil || claims.Act.Sub != s1 || claims.AuthTime != nil || imp.Reason != "ticket 12" {
+		t.Fatalf("unexpected impersonation %#v %#v %v", imp, claims, err)
+	}
+}
@@ -0,0 +1,389 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const (
+	apiKeyPrefix          = "ak_"
+	maxActiveAPIKeys      = 2
+	maxAPIClientName      = 100
+	maxAllowedNetworks    = 20
+	maxRedirectURIs       = 10
+	defaultClientTokenTTL = 15 * time.Minute
+)
+
+type APIClientConfig struct {
+	// TokenTTL is how long a client-credentials access token lives.
+	TokenTTL time.Duration
+}
+
+// CreateAPIClientInput registers a client. Apps asking users for
+// delegated scopes must list their RedirectURIs; Public apps get no key
+// and may only ask for delegated scopes.
+type CreateAPIClientInput struct {
+	ActorID         string
+	Name            string
+	Scopes          []string
+	AllowedNetworks []string
+	RedirectURIs    []string
+	Public          bool
+}
+
+// ClientCredentialsInput is an OAuth2 client-credentials grant (RFC 6749
+// section 4.4). Secret is one of the client's API keys; Scope is the
+// space-separated scope parameter, empty for every granted scope. IP is
+// the caller's address, checked against the allow-list.
+type ClientCredentialsInput struct {
+	ClientID string
+	Secret   string
+	Scope    string
+	IP       string
+}
+
+type ClientTokenResult struct {
+	Client      *domain.APIClient
+	KeyPrefix   string
+	AccessToken string
+	ExpiresIn   time.Duration
+	Scopes      []domain.Scope
+}
+
+// APIClientService manages API clients and their keys, and exchanges a key
+// for a short-lived scoped access token.
+type APIClientService struct {
+	clients repository.APIClientRepository
+	users   repository.UserRepository
+	tokens  *auth.JWTManager
+	cfg     APIClientConfig
+	now     func() time.Time
+}
+
+func NewAPIClientService(clients repository.APIClientRepository, users repository.UserRepository, tokens *auth.JWTManager, cfg APIClientConfig) *APIClientService {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultClientTokenTTL
+	}
+	return &APIClientService{clients: clients, users: users, tokens: tokens, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Create registers a client with its first key. The key is returned once;
+// only its hash is stored. Public clients are created without a key.
+func (s *APIClientService) Create(ctx context.Context, in CreateAPIClientInput) (*domain.APIClient, string, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermAPIClientsManage); err != nil {
+		return nil, "", nil, err
+	}
+	name := strings.TrimSpace(in.Name)
+	scopes := domain.NormalizeScopes(in.Scopes)
+	fields := domain.FieldErrors{}
+	if name == "" || len(name) > maxAPIClientName {
+		fields["name"] = "must be 1-100 characters"
+	}
+	if len(scopes) == 0 {
+		fields["scopes"] = "grant at least one scope"
+	}
+	delegated := false
+	for _, sc := range scopes {
+		if !slices.Contains(domain.KnownScopes(), sc) {
+			fields["scopes"] = "unknown scope " + string(sc)
+			break
+		}
+		if sc.Delegated() {
+			delegated = true
+		} else if in.Public {
+			fields["scopes"] = "public clients may only hold user scopes"
+		}
+	}
+	networks, bad, ok := domain.ParseNetworks(in.AllowedNetworks)
+	switch {
+	case !ok:
+		fields["allowedNetworks"] = "invalid network " + bad
+	case len(networks) > maxAllowedNetworks:
+		fields["allowedNetworks"] = "at most 20 networks"
+	case in.Public && len(networks) > 0:
+		fields["allowedNetworks"] = "public clients run on users' devices and cannot be limited to networks"
+	}
+	var redirectURIs []string
+	for _, u := range in.RedirectURIs {
+		u = strings.TrimSpace(u)
+		if !domain.ValidRedirectURI(u) {
+			fields["redirectUris"] = "must be https, http on a loopback host, or a reverse-domain app scheme, without a fragment: " + u
+			break
+		}
+		if !slices.Contains(redirectURIs, u) {
+			redirectURIs = append(redirectURIs, u)
+		}
+	}
+	switch {
+	case len(redirectURIs) > maxRedirectURIs:
+		fields["redirectUris"] = "at most 10 redirect URIs"
+	case delegated && len(redirectURIs) == 0 && fields["redirectUris"] == "":
+		fields["redirectUris"] = "required for user scopes"
+	}
+	if len(fields) > 0 {
+		return nil, "", fields, domain.ErrInvalidInput
+	}
+	now := s.now()
+	client := &domain.APIClient{Name: name, Scopes: scopes, AllowedNetworks: networks, RedirectURIs: redirectURIs, Public: in.Public, Status: domain.APIClientActive, CreatedBy: in.ActorID, CreatedAt: now, UpdatedAt: now}
+	var secret string
+	if !in.Public {
+		key, k, err := s.newKey()
+		if err != nil {
+			return nil, "", nil, err
+		}
+		client.Keys, secret = []domain.APIKey{key}, k
+	}
+	if err := s.clients.Create(ctx, client); err != nil {
+		return nil, "", nil, err
+	}
+	return client, secret, nil, nil
+}
+
+func (s *APIClientService) List(ctx context.Context, actorID string) ([]*domain.APIClient, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAPIClientsManage); err != nil {
+		return nil, err
+	}
+	return s.clients.List(ctx)
+}
+
+func (s *APIClientService) Get(ctx context.Context, actorID, clientID string) (*domain.APIClient, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAPIClientsManage); err != nil {
+		return nil, err
+	}
+	return s.clients.GetByID(ctx, clientID)
+}
+
+// CreateKey adds a key so a client can rotate: deploy the new key, then
+// revoke the old one. A client holds at most two active keys.
+func (s *APIClientService) CreateKey(ctx context.Context, actorID, clientID string) (*domain.APIClient, string, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAPIClientsManage); err != nil {
+		return nil, "", nil, err
+	}
+	client, err := s.clients.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if client.Public {
+		return nil, "", domain.FieldErrors{"keys": "public clients have no keys"}, domain.ErrInvalidInput
+	}
+	if client.ActiveKeys() >= maxActiveAPIKeys {
+		return nil, "", domain.FieldErrors{"keys": "revoke a key first; at most 2 keys may be active"}, domain.ErrInvalidInput
+	}
+	key, secret, err := s.newKey()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := s.clients.AddKey(ctx, client.ID, key); err != nil {
+		return nil, "", nil, err
+	}
+	client.Keys, client.UpdatedAt = append(client.Keys, key), key.CreatedAt
+	return client, secret, nil, nil
+}
+
+// RevokeKey stops a key from obtaining tokens. Tokens it already obtained
+// live out their short TTL.
+func (s *APIClientService) RevokeKey(ctx context.Context, actorID, clientID, prefix string) (*domain.APIClient, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAPIClientsManage); err != nil {
+		return nil, err
+	}
+	client, err := s.clients.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := client.Key(prefix)
+	if !ok {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	if !key.Active() {
+		return client, nil
+	}
+	now := s.now()
+	if err := s.clients.RevokeKey(ctx, client.ID, prefix, now); err != nil {
+		return nil, err
+	}
+	for i := range client.Keys {
+		if client.Keys[i].Prefix == prefix {
+			client.Keys[i].RevokedAt = now
+		}
+	}
+	client.UpdatedAt = now
+	return client, nil
+}
+
+// Disable stops the client obtaining tokens, and use cases refuse the
+// tokens it already holds.
+func (s *APIClientService) Disable(ctx context.Context, actorID, clientID string) (*domain.APIClient, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAPIClientsManage); err != nil {
+		return nil, err
+	}
+	client, err := s.clients.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := s.clients.SetStatus(ctx, client.ID, domain.APIClientDisabled, now); err != nil {
+		return nil, err
+	}
+	client.Status, client.UpdatedAt = domain.APIClientDisabled, now
+	return client, nil
+}
+
+// Authenticate checks a client's credentials: one of its keys, or no secret
+// at all for a public client. Every credential failure is
+// domain.ErrInvalidClient so callers cannot tell which part was wrong; a
+// caller outside the allow-list gets domain.ErrNetworkNotAllowed only after
+// proving the key, with the client returned. It returns the prefix of the
+// key used, empty for a public client.
+func (s *APIClientService) Authenticate(ctx context.Context, clientID, secret, ip string) (*domain.APIClient, string, error) {
+	clientID, secret = strings.TrimSpace(clientID), strings.TrimSpace(secret)
+	if clientID == "" {
+		return nil, "", domain.ErrInvalidClient
+	}
+	if secret == "" {
+		client, err := s.clients.GetByID(ctx, clientID)
+		if err != nil {
+			if errors.Is(err, domain.ErrAPIClientNotFound) {
+				return nil, "", domain.ErrInvalidClient
+			}
+			return nil, "", err
+		}
+		if !client.Public || client.Status != domain.APIClientActive {
+			return nil, "", domain.ErrInvalidClient
+		}
+		return client, "", nil
+	}
+	prefix, _, ok := strings.Cut(secret, ".")
+	if !ok || !strings.HasPrefix(prefix, apiKeyPrefix) {
+		return nil, "", domain.ErrInvalidClient
+	}
+	client, err := s.clients.GetByKeyPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIClientNotFound) {
+			return nil, "", domain.ErrInvalidClient
+		}
+		return nil, "", err
+	}
+	key, _ := client.Key(prefix)
+	if client.ID != clientID || !key.Active() || subtle.ConstantTimeCompare([]byte(hashAPIKey(secret)), []byte(key.Hash)) != 1 || client.Status != domain.APIClientActive {
+		return nil, "", domain.ErrInvalidClient
+	}
+	if !client.AllowsAddr(ip) {
+		return client, prefix, domain.ErrNetworkNotAllowed
+	}
+	if err := s.clients.TouchKey(ctx, client.ID, prefix, s.now()); err != nil {
+		return nil, "", err
+	}
+	return client, prefix, nil
+}
+
+// IssueToken runs the client-credentials grant; see Authenticate for how
+// credential failures are reported. The returned client is set whenever
+// the key matched. Only scopes the client holds for itself may be asked
+// for, and none means all of them.
+func (s *APIClientService) IssueToken(ctx context.Context, in ClientCredentialsInput) (*ClientTokenResult, error) {
+	client, prefix, err := s.Authenticate(ctx, in.ClientID, in.Secret, in.IP)
+	if client == nil {
+		return nil, err
+	}
+	res := &ClientTokenResult{Client: client, KeyPrefix: prefix, ExpiresIn: s.cfg.TokenTTL}
+	if err != nil {
+		return res, err
+	}
+	if client.Public {
+		return res, domain.ErrInvalidClient
+	}
+	res.Scopes = domain.NormalizeScopes([]string{in.Scope})
+	if len(res.Scopes) == 0 {
+		for _, sc := range client.Scopes {
+			if !sc.Delegated() {
+				res.Scopes = append(res.Scopes, sc)
+			}
+		}
+		if len(res.Scopes) == 0 {
+			return res, domain.ErrInvalidScope
+		}
+	}
+	for _, sc := range res.Scopes {
+		if !client.HasScope(sc) || sc.Delegated() {
+			return res, domain.ErrInvalidScope
+		}
+	}
+	res.AccessToken, err = s.tokens.IssueToken(client.ID, s.cfg.TokenTTL, auth.TokenOptions{ClientID: client.ID, Scopes: scopeStrings(res.Scopes), Networks: networkStrings(client)})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func scopeStrings(scopes []domain.Scope) []string {
+	out := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		out = append(out, string(sc))
+	}
+	return out
+}
+
+// networkStrings lists the client's allowed networks for a token's nets
+// claim.
+func networkStrings(client *domain.APIClient) []string {
+	out := make([]string, 0, len(client.AllowedNetworks))
+	for _, n := range client.AllowedNetworks {
+		out = append(out, n.String())
+	}
+	return out
+}
+
+// newKey returns a key "ak_<12 hex>.<43 base64url>" and its stored form.
+// The part before the dot is the key's prefix.
+func (s *APIClientService) newKey() (domain.APIKey, string, error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return domain.APIKey{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return domain.APIKey{}, "", err
+	}
+	prefix := apiKeyPrefix + hex.EncodeToString(id)
+	key := prefix + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return domain.APIKey{Prefix: prefix, Hash: hashAPIKey(key), CreatedAt: s.now()}, key, nil
+}
+
+// hashAPIKey is a plain SHA-256: keys carry 256 random bits, so a slow
+// password hash would add cost without adding safety.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// requireClientScope re-checks a client token against the stored client,
+// so disabling a client or withdrawing a scope takes effect before its
+// tokens expire.
+func requireClientScope(ctx context.Context, clients repository.APIClientRepository, clientID string, scope domain.Scope) error {
+	if strings.TrimSpace(clientID) == "" {
+		return domain.ErrUnauthorized
+	}
+	client, err := clients.GetByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIClientNotFound) {
+			return domain.ErrUnauthorized
+		}
+		return err
+	}
+	if client.Status != domain.APIClientActive || !client.HasScope(scope) {
+		return domain.ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+func TestClientCredentialsIssueScopedTokens(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	repo, jwtMgr := memory.NewAPIClientRepository(st.Store), auth.NewJWTManager("secret", "akiba-api")
+	svc := NewAPIClientService(repo, st.users, jwtMgr, APIClientConfig{TokenTTL: time.Minute})
+	customer := addUser(t, st.users, domain.User{UsernameLower: "c_one"})
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+
+	if _, _, _, err := svc.Create(ctx, CreateAPIClientInput{ActorID: customer, Name: "Acme", Scopes: []string{"webhooks:read"}}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customer refused, got %v", err)
+	}
+	_, _, fields, err := svc.Create(ctx, CreateAPIClientInput{ActorID: admin, Name: "Acme", Scopes: []string{"payments:write"}, AllowedNetworks: []string{"10.0.0.0/33"}})
+	if !errors.Is(err, domain.ErrInvalidInput) || fields["scopes"] == "" || fields["allowedNetworks"] == "" {
+		t.Fatalf("expected scope and network refused, got %v %v", fields, err)
+	}
+	client, key, _, err := svc.Create(ctx, CreateAPIClientInput{ActorID: admin, Name: "Acme", Scopes: []string{"webhooks:read", "webhooks:write"}, AllowedNetworks: []string{"203.0.113.0/24", "2001:db8::1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, client.Keys[0].Prefix+".") || strings.Contains(client.Keys[0].Hash, key) || client.Keys[0].Hash == "" {
+		t.Fatalf("expected a prefixed key stored hashed, got %q %#v", key, client.Keys[0])
+	}
+
+	res, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: key, Scope: "webhooks:read", IP: "203.0.113.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := jwtMgr.Verify(res.AccessToken)
+	if err != nil || !claims.IsClient() || claims.Scope != "webhooks:read" || len(claims.Networks) != 2 || claims.ExpiresAt.Sub(claims.IssuedAt.Time) != time.Minute {
+		t.Fatalf("unexpected claims %#v %v", claims, err)
+	}
+	if stored, _ := repo.GetByID(ctx, client.ID); stored.Keys[0].LastUsedAt.IsZero() {
+		t.Fatal("expected key use recorded")
+	}
+	if res, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: key, IP: "2001:db8::1"}); err != nil || len(res.Scopes) != 2 {
+		t.Fatalf("expected every granted scope by default, got %v %v", res, err)
+	}
+
+	for name, in := range map[string]ClientCredentialsInput{
+		"wrong client": {ClientID: "cl9", Secret: key, IP: "203.0.113.9"},
+		"wrong secret": {ClientID: client.ID, Secret: client.Keys[0].Prefix + ".nope", IP: "203.0.113.9"},
+		"not a key":    {ClientID: client.ID, Secret: "hunter2", IP: "203.0.113.9"},
+	} {
+		if _, err := svc.IssueToken(ctx, in); !errors.Is(err, domain.ErrInvalidClient) {
+			t.Fatalf("%s: expected invalid client, got %v", name, err)
+		}
+	}
+	if _, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: key, IP: "198.51.100.1"}); !errors.Is(err, domain.ErrNetworkNotAllowed) {
+		t.Fatalf("expected address outside the allow-list refused, got %v", err)
+	}
+	if _, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: key, Scope: "webhooks:read audit:read", IP: "203.0.113.9"}); !errors.Is(err, domain.ErrInvalidScope) {
+		t.Fatalf("expected ungranted scope refused, got %v", err)
+	}
+
+	// Rotation: a second key works alongside the first until it is revoked.
+	_, next, _, err := svc.CreateKey(ctx, admin, client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := svc.CreateKey(ctx, admin, client.ID); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("expected a third active key refused, got %v", err)
+	}
+	if _, err := svc.RevokeKey(ctx, admin, client.ID, client.Keys[0].Prefix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: key, IP: "203.0.113.9"}); !errors.Is(err, domain.ErrInvalidClient) {
+		t.Fatalf("expected revoked key refused, got %v", err)
+	}
+	if _, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: next, IP: "203.0.113.9"}); err != nil {
+		t.Fatalf("expected new key accepted, got %v", err)
+	}
+
+	if _, err := svc.Disable(ctx, admin, client.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.IssueToken(ctx, ClientCredentialsInput{ClientID: client.ID, Secret: next, IP: "203.0.113.9"}); !errors.Is(err, domain.ErrInvalidClient) {
+		t.Fatalf("expected disabled client refused, got %v", err)
+	}
+	if err := requireClientScope(ctx, repo, client.ID, domain.ScopeWebhooksRead); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected disabled client's tokens refused, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const (
+	defaultAuditSearchLimit = 100
+	maxAuditSearchLimit     = 500
+	auditVerifyBatch        = 500
+)
+
+// AuditAnchor is a record hash kept outside the database, for example from
+// an earlier verification. Checking it also catches a log that was cut
+// short or rewritten from the start, which the chain alone cannot show.
+type AuditAnchor struct {
+	Seq  int64
+	Hash string
+}
+
+// AuditProblem is one break in the audit chain, at record Seq.
+type AuditProblem struct {
+	Seq     int64
+	Problem string
+}
+
+// AuditVerification is the outcome of walking the audit log. HeadSeq and
+// HeadHash are worth keeping as the anchor for the next run.
+type AuditVerification struct {
+	Records  int64
+	HeadSeq  int64
+	HeadHash string
+	Problems []AuditProblem
+}
+
+func (v *AuditVerification) OK() bool { return len(v.Problems) == 0 }
+
+// AuditService appends security events to the hash-chained audit log and
+// reads them back for operators and the verifier.
+type AuditService struct {
+	records repository.AuditRepository
+	users   repository.UserRepository
+	now     func() time.Time
+}
+
+func NewAuditService(records repository.AuditRepository, users repository.UserRepository) *AuditService {
+	return &AuditService{records: records, users: users, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Record stamps and appends record, setting its Seq and hashes. A nil
+// service records nothing, so callers need not check whether auditing is
+// configured.
+func (s *AuditService) Record(ctx context.Context, record *domain.AuditRecord) error {
+	if s == nil {
+		return nil
+	}
+	record.CreatedAt = s.now()
+	return s.records.Append(ctx, record)
+}
+
+// Search returns audit records matching filter, newest first. From and To
+// bound CreatedAt as [From, To).
+func (s *AuditService) Search(ctx context.Context, actorID string, filter domain.AuditFilter) ([]*domain.AuditRecord, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAuditRead); err != nil {
+		return nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	filter.ActorID, filter.TargetID = strings.TrimSpace(filter.ActorID), strings.TrimSpace(filter.TargetID)
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		fields["from"] = "must be before to"
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditSearchLimit {
+		fields["limit"] = "must be between 1 and 500"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditSearchLimit
+	}
+	records, err := s.records.Search(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return records, nil, nil
+}
+
+// Verify walks the whole log in sequence order and reports every missing
+// record, broken link and record whose contents no longer match its hash.
+// anchor, when given, must match the stored record at its Seq.
+func (s *AuditService) Verify(ctx context.Context, anchor *AuditAnchor) (*AuditVerification, error) {
+	out := &AuditVerification{}
+	var prev *domain.AuditRecord
+	anchorSeen := false
+	for {
+		var after int64
+		if prev != nil {
+			after = prev.Seq
+		}
+		batch, err := s.records.ListAfter(ctx, after, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range batch {
+			wantSeq, wantPrev := int64(1), ""
+			if prev != nil {
+				wantSeq, wantPrev = prev.Seq+1, prev.Hash
+			}
+			if rec.Seq != wantSeq {
+				out.Problems = append(out.Problems, AuditProblem{Seq: rec.Seq, Problem: fmt.Sprintf("records %d to %d are missing", wantSeq, rec.Seq-1)})
+			} else if rec.PrevHash != wantPrev {
+				out.Problems = append(out.Problems, AuditProblem{Seq: rec.Seq, Problem: "previous hash does not match the record before it"})
+			}
+			if rec.ComputeHash() != rec.Hash {
+				out.Problems = append(out.Problems, AuditProblem{Seq: rec.Seq, Problem: "contents do not match the stored hash"})
+			}
+			if anchor != nil && rec.Seq == anchor.Seq {
+				anchorSeen = true
+				if rec.Hash != anchor.Hash {
+					out.Problems = append(out.Problems, AuditProblem{Seq: rec.Seq, Problem: "hash does not match the anchor"})
+				}
+			}
+			prev = rec
+			out.Records++
+		}
+		if len(batch) < auditVerifyBatch {
+			break
+		}
+	}
+	if anchor != nil && !anchorSeen {
+		out.Problems = append(out.Problems, AuditProblem{Seq: anchor.Seq, Problem: "anchored record is missing"})
+	}
+	if prev != nil {
+		out.HeadSeq, out.HeadHash = prev.Seq, prev.Hash
+	}
+	return out, nil
+}
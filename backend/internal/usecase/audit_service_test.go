@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+// recordLogins appends n password logins by u1 to the log.
+func recordLogins(t *testing.T, svc *AuditService, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := svc.Record(context.Background(), &domain.AuditRecord{Action: domain.AuditLoginSucceeded, ActorID: "u1", TargetType: "user", TargetID: "u1", IP: "10.0.0.1", Details: map[string]string{"method": "password"}}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+}
+
+// storedAudit returns the log oldest first.
+func storedAudit(t *testing.T, records *memory.AuditRepository) []*domain.AuditRecord {
+	t.Helper()
+	stored, err := records.ListAfter(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+// tamperedAudit reads the log through edit, as if the stored records had
+// been changed behind the application's back.
+type tamperedAudit struct {
+	*memory.AuditRepository
+	edit func([]*domain.AuditRecord) []*domain.AuditRecord
+}
+
+func (a tamperedAudit) ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*domain.AuditRecord, error) {
+	records, err := a.AuditRepository.ListAfter(ctx, afterSeq, limit)
+	return a.edit(records), err
+}
+
+func TestAuditChainVerifies(t *testing.T) {
+	st := newTestStore()
+	repo := memory.NewAuditRepository(st.Store)
+	svc := NewAuditService(repo, st.users)
+	recordLogins(t, svc, 3)
+	records := storedAudit(t, repo)
+	if records[0].PrevHash != "" || records[2].PrevHash != records[1].Hash || records[2].Seq != 3 {
+		t.Fatalf("records not chained: %#v", records)
+	}
+	res, err := svc.Verify(context.Background(), &AuditAnchor{Seq: 2, Hash: records[1].Hash})
+	if err != nil || !res.OK() || res.Records != 3 || res.HeadSeq != 3 || res.HeadHash != records[2].Hash {
+		t.Fatalf("expected intact log, got %#v %v", res, err)
+	}
+}
+
+func TestAuditVerifyDetectsEditsAndGaps(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	records := memory.NewAuditRepository(st.Store)
+	recordLogins(t, NewAuditService(records, st.users), 3)
+	edit := func(records []*domain.AuditRecord) []*domain.AuditRecord {
+		for _, r := range records {
+			if r.Seq == 2 {
+				r.Details["method"] = "passkey"
+			}
+		}
+		return records
+	}
+	res, _ := NewAuditService(tamperedAudit{AuditRepository: records, edit: edit}, st.users).Verify(ctx, nil)
+	if res.OK() || res.Problems[0].Seq != 2 || !strings.Contains(res.Problems[0].Problem, "contents") {
+		t.Fatalf("expected edit detected, got %#v", res.Problems)
+	}
+
+	// Re-hashing the edited record moves the break to the next link.
+	rehash := func(records []*domain.AuditRecord) []*domain.AuditRecord {
+		for _, r := range edit(records) {
+			if r.Seq == 2 {
+				r.Hash = r.ComputeHash()
+			}
+		}
+		return records
+	}
+	res, _ = NewAuditService(tamperedAudit{AuditRepository: records, edit: rehash}, st.users).Verify(ctx, nil)
+	if res.OK() || res.Problems[0].Seq != 3 || !strings.Contains(res.Problems[0].Problem, "previous hash") {
+		t.Fatalf("expected broken link detected, got %#v", res.Problems)
+	}
+
+	drop := func(seq int64) func([]*domain.AuditRecord) []*domain.AuditRecord {
+		return func(records []*domain.AuditRecord) []*domain.AuditRecord {
+			return slices.DeleteFunc(records, func(r *domain.AuditRecord) bool { return r.Seq == seq })
+		}
+	}
+	st = newTestStore()
+	records = memory.NewAuditRepository(st.Store)
+	recordLogins(t, NewAuditService(records, st.users), 4)
+	res, _ = NewAuditService(tamperedAudit{AuditRepository: records, edit: drop(2)}, st.users).Verify(ctx, nil)
+	if res.OK() || res.Problems[0].Seq != 3 || !strings.Contains(res.Problems[0].Problem, "missing") {
+		t.Fatalf("expected gap detected, got %#v", res.Problems)
+	}
+
+	// Dropping the newest records leaves an intact chain; only the anchor
+	// notices.
+	anchor := &AuditAnchor{Seq: 4, Hash: storedAudit(t, records)[3].Hash}
+	res, _ = NewAuditService(tamperedAudit{AuditRepository: records, edit: drop(4)}, st.users).Verify(ctx, anchor)
+	if res.OK() || res.Problems[0].Seq != 4 {
+		t.Fatalf("expected truncation detected, got %#v", res.Problems)
+	}
+}
+
+func TestAuditSearchNeedsPermission(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewAuditService(memory.NewAuditRepository(st.Store), st.users)
+	compliance := addUser(t, st.users, domain.User{UsernameLower: "compliance", Role: domain.UserRoleCompliance})
+	support := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	recordLogins(t, svc, 2)
+	if _, _, err := svc.Search(ctx, support, domain.AuditFilter{}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected support refused, got %v", err)
+	}
+	if _, fields, err := svc.Search(ctx, compliance, domain.AuditFilter{Limit: 501}); !errors.Is(err, domain.ErrInvalidInput) || fields["limit"] == "" {
+		t.Fatalf("expected limit refused, got %v %v", fields, err)
+	}
+	records, _, err := svc.Search(ctx, compliance, domain.AuditFilter{ActorID: "u1"})
+	if err != nil || len(records) != 2 || records[0].Seq != 2 {
+		t.Fatalf("unexpected search %#v %v", records, err)
+	}
+}
@@ -19,27 +19,80 @@ type SignupInput struct {
 	Phone    string `validate:"required"`
 	Username string `validate:"required"`
 	Password string `validate:"required"`
+	Device   *DeviceInput
 }
 type LoginInput struct {
 	Login    string `validate:"required"`
 	Password string `validate:"required"`
+	Device   *DeviceInput
 }
+type VerifyLoginOTPInput struct {
+	ChallengeID string
+	Code        string
+}
+type StepUpInput struct {
+	UserID   string
+	Method   string
+	Password string
+	PIN      string
+	// Code is the authenticator app code for the totp method.
+	Code string
+	// Device keeps the step-up token bound to the same device as the token
+	// it replaces.
+	Device *auth.DeviceClaim
+}
+type ChangePasswordInput struct {
+	UserID      string
+	NewPassword string
+}
+
+// AuthResult is a signed-in user and their token, bound to Device when set.
+// A login that needs an OTP first has only OTPChallengeID and
+// OTPExpiresAt.
 type AuthResult struct {
-	User        *domain.User
-	AccessToken string
+	User           *domain.User
+	AccessToken    string
+	ExpiresAt      time.Time
+	Device         *domain.Device
+	OTPChallengeID string
+	OTPExpiresAt   time.Time
+}
+
+// StepUpConfig governs re-authentication for high-risk actions. A token is
+// fresh for MaxAge after the user last authenticated; step-up tokens live
+// for TokenTTL. Transfers above the TransferThresholds entry for their
+// currency, in its minor units, need a fresh token; transfers in a currency
+// without an entry are refused.
+type StepUpConfig struct {
+	MaxAge             time.Duration
+	TokenTTL           time.Duration
+	TransferThresholds map[string]int64
 }
 
+// stepUpMethods maps the methods accepted by StepUp to their amr values.
+var stepUpMethods = map[string]string{"password": auth.MethodPassword, "pin": auth.MethodPIN, "totp": auth.MethodOTP}
+
 type AuthService struct {
 	users          repository.UserRepository
 	jwt            *auth.JWTManager
 	validate       *validator.Validate
 	accessTokenTTL time.Duration
+	stepUp         StepUpConfig
+	pins           *PINService
+	passkeys       *PasskeyService
+	devices        *DeviceService
+	totp           *TOTPService
 }
 
-func NewAuthService(users repository.UserRepository, jwtMgr *auth.JWTManager, accessTokenTTL time.Duration) *AuthService {
-	return &AuthService{users: users, jwt: jwtMgr, validate: validator.New(), accessTokenTTL: accessTokenTTL}
+// NewAuthService wires authentication. pins, passkeys, devices and totp may
+// be nil, in which case step-up by PIN, login with a passkey, device binding
+// (and with it login OTPs) or step-up by authenticator app is unavailable.
+func NewAuthService(users repository.UserRepository, jwtMgr *auth.JWTManager, accessTokenTTL time.Duration, stepUp StepUpConfig, pins *PINService, passkeys *PasskeyService, devices *DeviceService, totp *TOTPService) *AuthService {
+	return &AuthService{users: users, jwt: jwtMgr, validate: validator.New(), accessTokenTTL: accessTokenTTL, stepUp: stepUp, pins: pins, passkeys: passkeys, devices: devices, totp: totp}
 }
 
+func (s *AuthService) StepUpConfig() StepUpConfig { return s.stepUp }
+
 func (s *AuthService) Signup(ctx context.Context, in SignupInput) (*AuthResult, domain.FieldErrors, error) {
 	fields := domain.FieldErrors{}
 	if err := s.validate.Var(in.Email, "required,email"); err != nil {
@@ -58,6 +111,7 @@ func (s *AuthService) Signup(ctx context.Context, in SignupInput) (*AuthResult,
 	if !domain.ValidatePassword(password) {
 		fields["password"] = "must be at least 8 chars and include a letter and number"
 	}
+	device := s.parseDevice(in.Device, fields)
 	if len(fields) > 0 {
 		return nil, fields, domain.ErrInvalidInput
 	}
@@ -66,20 +120,25 @@ func (s *AuthService) Signup(ctx context.Context, in SignupInput) (*AuthResult,
 	if err != nil {
 		return nil, nil, err
 	}
-	user := &domain.User{EmailLower: email, PhoneE164: phone, UsernameLower: username, PasswordHash: string(hash), Status: domain.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	user := &domain.User{EmailLower: email, PhoneE164: phone, UsernameLower: username, PasswordHash: string(hash), Status: domain.UserStatusPendingVerification, Role: domain.UserRoleCustomer, KYCStatus: domain.KYCStatusPending, CreatedAt: now, UpdatedAt: now}
 	if err := s.users.Create(ctx, user); err != nil {
 		if errors.Is(err, domain.ErrUserExists) {
 			return nil, domain.FieldErrors{"login": "email, phone, or username already exists"}, domain.ErrUserExists
 		}
 		return nil, nil, err
 	}
-	token, err := s.jwt.IssueAccessToken(user.ID, s.accessTokenTTL)
-	if err != nil {
-		return nil, nil, err
+	// The first device needs no OTP: there is nothing to protect yet.
+	if device != nil {
+		if err := s.devices.trust(ctx, user.ID, device); err != nil {
+			return nil, nil, err
+		}
 	}
-	return &AuthResult{User: user, AccessToken: token}, nil, nil
+	return s.issue(user, s.accessTokenTTL, deviceClaim(device), auth.MethodPassword)
 }
 
+// Login checks the password. With device binding enabled, a login from a
+// trusted device returns a token bound to it; any other login returns an
+// OTP challenge to finish with VerifyLoginOTP.
 func (s *AuthService) Login(ctx context.Context, in LoginInput) (*AuthResult, domain.FieldErrors, error) {
 	fields := domain.FieldErrors{}
 	if strings.TrimSpace(in.Login) == "" {
@@ -89,35 +148,188 @@ func (s *AuthService) Login(ctx context.Context, in LoginInput) (*AuthResult, do
 	if password == "" {
 		fields["password"] = "is required"
 	}
+	device := s.parseDevice(in.Device, fields)
 	if len(fields) > 0 {
 		return nil, fields, domain.ErrInvalidInput
 	}
-	login := strings.TrimSpace(in.Login)
-	if strings.Contains(login, "@") {
-		login = domain.NormalizeEmail(login)
-	} else if strings.HasPrefix(login, "+") {
-		login = domain.NormalizePhone(login)
-	} else {
-		login = domain.NormalizeUsername(login)
-	}
-	user, err := s.users.GetByLogin(ctx, login)
+	user, err := s.users.GetByLogin(ctx, normalizeLogin(in.Login))
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, nil, domain.ErrInvalidCredentials
 		}
 		return nil, nil, err
 	}
-	if user.Status != domain.UserStatusActive {
+	if !user.Status.CanLogIn() {
 		return nil, nil, domain.ErrInvalidCredentials
 	}
 	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
 		return nil, nil, domain.ErrInvalidCredentials
 	}
-	token, err := s.jwt.IssueAccessToken(user.ID, s.accessTokenTTL)
+	return s.finishLogin(ctx, user, device, auth.MethodPassword)
+}
+
+// VerifyLoginOTP finishes a login that needed an OTP, trusting the device
+// it named. The token records both the factor the login began with and the
+// OTP.
+func (s *AuthService) VerifyLoginOTP(ctx context.Context, in VerifyLoginOTPInput) (*AuthResult, domain.FieldErrors, error) {
+	fields := domain.FieldErrors{}
+	if strings.TrimSpace(in.ChallengeID) == "" {
+		fields["challengeId"] = "is required"
+	}
+	if strings.TrimSpace(in.Code) == "" {
+		fields["code"] = "is required"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if s.devices == nil {
+		return nil, nil, domain.ErrChallengeNotFound
+	}
+	user, challenge, err := s.devices.confirm(ctx, in.ChallengeID, strings.TrimSpace(in.Code))
+	if err != nil {
+		return nil, nil, err
+	}
+	method := challenge.Method
+	if method == "" {
+		method = auth.MethodPassword
+	}
+	return s.issue(user, s.accessTokenTTL, deviceClaim(challenge.Device), method, auth.MethodOTP)
+}
+
+// LoginWithPasskey signs in with a passkey assertion answering a challenge
+// from PasskeyService.BeginLogin. Devices are checked as for Login.
+func (s *AuthService) LoginWithPasskey(ctx context.Context, in PasskeyLoginInput) (*AuthResult, domain.FieldErrors, error) {
+	if s.passkeys == nil {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	fields := domain.FieldErrors{}
+	device := s.parseDevice(in.Device, fields)
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	user, fields, err := s.passkeys.verifyLogin(ctx, in)
+	if err != nil {
+		return nil, fields, err
+	}
+	return s.finishLogin(ctx, user, device, auth.MethodHardwareKey)
+}
+
+// finishLogin completes a login whose first factor, method, has passed.
+// With device binding enabled, a trusted device gets a token bound to it
+// and any other login an OTP challenge to finish with VerifyLoginOTP.
+func (s *AuthService) finishLogin(ctx context.Context, user *domain.User, device *domain.Device, method string) (*AuthResult, domain.FieldErrors, error) {
+	if s.devices == nil {
+		return s.issue(user, s.accessTokenTTL, nil, method)
+	}
+	trusted, challenge, err := s.devices.checkLogin(ctx, user, device, method)
 	if err != nil {
 		return nil, nil, err
 	}
-	return &AuthResult{User: user, AccessToken: token}, nil, nil
+	if challenge != nil {
+		return &AuthResult{OTPChallengeID: challenge.ID, OTPExpiresAt: challenge.ExpiresAt}, nil, nil
+	}
+	return s.issue(user, s.accessTokenTTL, deviceClaim(trusted), method)
+}
+
+// StepUp re-verifies the signed-in user and issues a short-lived token whose
+// auth_time satisfies RequireFreshAuth.
+func (s *AuthService) StepUp(ctx context.Context, in StepUpInput) (*AuthResult, domain.FieldErrors, error) {
+	method := strings.ToLower(strings.TrimSpace(in.Method))
+	amr, ok := stepUpMethods[method]
+	if !ok || (amr == auth.MethodPIN && s.pins == nil) || (amr == auth.MethodOTP && s.totp == nil) {
+		return nil, domain.FieldErrors{"method": "must be password, pin or totp"}, domain.ErrInvalidInput
+	}
+	password := strings.TrimSpace(in.Password)
+	if amr == auth.MethodPassword && password == "" {
+		return nil, domain.FieldErrors{"password": "is required"}, domain.ErrInvalidInput
+	}
+	if amr == auth.MethodPIN && in.PIN == "" {
+		return nil, domain.FieldErrors{"pin": "is required"}, domain.ErrInvalidInput
+	}
+	if amr == auth.MethodOTP && strings.TrimSpace(in.Code) == "" {
+		return nil, domain.FieldErrors{"code": "is required"}, domain.ErrInvalidInput
+	}
+	user, err := s.Me(ctx, in.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Status.CanLogIn() {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	switch amr {
+	case auth.MethodPIN:
+		if err := s.pins.Verify(ctx, user.ID, in.PIN); err != nil {
+			return nil, nil, err
+		}
+	case auth.MethodOTP:
+		if err := s.totp.Verify(ctx, user.ID, in.Code); err != nil {
+			return nil, nil, err
+		}
+	default:
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+			return nil, nil, domain.ErrInvalidCredentials
+		}
+	}
+	return s.issue(user, s.stepUp.TokenTTL, in.Device, amr)
+}
+
+// ChangePassword replaces the user's password. Callers must have checked
+// for a fresh authentication.
+func (s *AuthService) ChangePassword(ctx context.Context, in ChangePasswordInput) (domain.FieldErrors, error) {
+	password := strings.TrimSpace(in.NewPassword)
+	if !domain.ValidatePassword(password) {
+		return domain.FieldErrors{"newPassword": "must be at least 8 chars and include a letter and number"}, domain.ErrInvalidInput
+	}
+	user, err := s.Me(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil {
+		return domain.FieldErrors{"newPassword": "must differ from the current password"}, domain.ErrInvalidInput
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.users.UpdatePassword(ctx, user.ID, string(hash), time.Now().UTC())
+}
+
+// issue signs a token carrying the user's role and its permissions, which
+// gate operator routes without a lookup.
+func (s *AuthService) issue(user *domain.User, ttl time.Duration, device *auth.DeviceClaim, amr ...string) (*AuthResult, domain.FieldErrors, error) {
+	expiresAt := time.Now().UTC().Add(ttl)
+	perms := user.Role.Permissions()
+	opts := auth.TokenOptions{Role: string(user.Role), Permissions: make([]string, 0, len(perms)), Device: device, AMR: amr}
+	for _, p := range perms {
+		opts.Permissions = append(opts.Permissions, string(p))
+	}
+	token, err := s.jwt.IssueToken(user.ID, ttl, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &AuthResult{User: user, AccessToken: token, ExpiresAt: expiresAt}, nil, nil
+}
+
+// parseDevice validates the device a client named, ignoring it when device
+// binding is disabled.
+func (s *AuthService) parseDevice(in *DeviceInput, fields domain.FieldErrors) *domain.Device {
+	if s.devices == nil {
+		return nil
+	}
+	return s.devices.parse(in, fields)
+}
+
+// normalizeLogin applies the signup normalization for whichever identifier
+// login looks like: email, E.164 phone, or username.
+func normalizeLogin(login string) string {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		return domain.NormalizeEmail(login)
+	}
+	if strings.HasPrefix(login, "+") {
+		return domain.NormalizePhone(login)
+	}
+	return domain.NormalizeUsername(login)
 }
 
 func (s *AuthService) Me(ctx context.Context, userID string) (*domain.User, error) {
@@ -2,45 +2,74 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"akiba/backend/internal/auth"
 	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/repository"
 )
 
-type memRepo struct{ users map[string]*domain.User }
+var testStepUp = StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 5 * time.Minute, TransferThresholds: map[string]int64{"KES": 5000000}}
 
-func (m *memRepo) EnsureIndexes(ctx context.Context) error { return nil }
-func (m *memRepo) Create(ctx context.Context, user *domain.User) error {
-	for _, u := range m.users {
-		if u.EmailLower == user.EmailLower || u.PhoneE164 == user.PhoneE164 || u.UsernameLower == user.UsernameLower {
-			return domain.ErrUserExists
-		}
-	}
-	user.ID = "u1"
-	m.users[user.ID] = user
-	return nil
+// testStore is one memory store with the repositories most use case tests
+// build services on; any other repository is made from Store as needed.
+type testStore struct {
+	*memory.Store
+	users     *memory.UserRepository
+	ledger    *memory.LedgerRepository
+	merchants *memory.MerchantRepository
 }
-func (m *memRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
-	u, ok := m.users[id]
-	if !ok {
-		return nil, domain.ErrUserNotFound
+
+func newTestStore() testStore {
+	s := memory.NewStore()
+	return testStore{Store: s, users: memory.NewUserRepository(s), ledger: memory.NewLedgerRepository(s), merchants: memory.NewMerchantRepository(s)}
+}
+
+// addUser stores u and returns its ID. Tests name users by username; the
+// email is made from it, and users are active customers unless u says
+// otherwise.
+func addUser(t *testing.T, users repository.UserRepository, u domain.User) string {
+	t.Helper()
+	if u.EmailLower == "" {
+		u.EmailLower = u.UsernameLower + "@example.com"
+	}
+	if u.Status == "" {
+		u.Status = domain.UserStatusActive
+	}
+	if u.Role == "" {
+		u.Role = domain.UserRoleCustomer
+	}
+	if err := users.Create(context.Background(), &u); err != nil {
+		t.Fatalf("create user %s: %v", u.UsernameLower, err)
 	}
-	return u, nil
+	return u.ID
 }
-func (m *memRepo) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
-	for _, u := range m.users {
-		if u.EmailLower == login || u.PhoneE164 == login || u.UsernameLower == login {
-			return u, nil
-		}
+
+// setStatus moves a user to status the way an operator would.
+func setStatus(t *testing.T, users repository.UserRepository, id string, status domain.UserStatus) {
+	t.Helper()
+	from := getUser(t, users, id).Status
+	if err := users.TransitionStatus(context.Background(), &domain.UserStatusEvent{UserID: id, From: from, To: status, ActorID: "test", Reason: "test", CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+}
+
+// getUser reads back a user as stored.
+func getUser(t *testing.T, users repository.UserRepository, id string) *domain.User {
+	t.Helper()
+	u, err := users.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
 	}
-	return nil, domain.ErrUserNotFound
+	return u
 }
 
 func TestSignupValidation(t *testing.T) {
-	repo := &memRepo{users: map[string]*domain.User{}}
-	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour)
+	repo := memory.NewUserRepository(memory.NewStore())
+	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour, testStepUp, nil, nil, nil, nil)
 	_, fields, err := svc.Signup(context.Background(), SignupInput{Email: "bad-email", Phone: "123", Username: "ab", Password: "weak"})
 	if err == nil {
 		t.Fatalf("expected error")
@@ -51,8 +80,8 @@ func TestSignupValidation(t *testing.T) {
 }
 
 func TestSignupAndLoginHappyPath(t *testing.T) {
-	repo := &memRepo{users: map[string]*domain.User{}}
-	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour)
+	repo := memory.NewUserRepository(memory.NewStore())
+	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour, testStepUp, nil, nil, nil, nil)
 	res, fields, err := svc.Signup(context.Background(), SignupInput{Email: "USER@example.com", Phone: "+14155552671", Username: "User_Name", Password: "Password1"})
 	if err != nil || len(fields) > 0 {
 		t.Fatalf("signup failed: err=%v fields=%#v", err, fields)
@@ -70,8 +99,8 @@ func TestSignupAndLoginHappyPath(t *testing.T) {
 }
 
 func TestLoginValidation(t *testing.T) {
-	repo := &memRepo{users: map[string]*domain.User{}}
-	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour)
+	repo := memory.NewUserRepository(memory.NewStore())
+	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour, testStepUp, nil, nil, nil, nil)
 	_, fields, err := svc.Login(context.Background(), LoginInput{Login: "", Password: ""})
 	if err == nil {
 		t.Fatalf("expected error")
@@ -82,8 +111,8 @@ func TestLoginValidation(t *testing.T) {
 }
 
 func TestLoginTrimsPasswordToMatchSignupNormalization(t *testing.T) {
-	repo := &memRepo{users: map[string]*domain.User{}}
-	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour)
+	repo := memory.NewUserRepository(memory.NewStore())
+	svc := NewAuthService(repo, auth.NewJWTManager("secret", "test"), time.Hour, testStepUp, nil, nil, nil, nil)
 	_, _, err := svc.Signup(context.Background(), SignupInput{
 		Email: "user@example.com", Phone: "+14155552671", Username: "user_1", Password: " Password1 ",
 	})
@@ -95,3 +124,32 @@ func TestLoginTrimsPasswordToMatchSignupNormalization(t *testing.T) {
 		t.Fatalf("login with trimmed password should succeed: %v", err)
 	}
 }
+
+func TestStepUpIssuesShortLivedFreshToken(t *testing.T) {
+	repo := memory.NewUserRepository(memory.NewStore())
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	svc := NewAuthService(repo, jwtMgr, time.Hour, testStepUp, nil, nil, nil, nil)
+	ctx := context.Background()
+	signup, _, err := svc.Signup(ctx, SignupInput{Email: "user@example.com", Phone: "+14155552671", Username: "user_1", Password: "Password1"})
+	if err != nil {
+		t.Fatalf("signup failed: %v", err)
+	}
+	u1 := signup.User.ID
+	if _, fields, err := svc.StepUp(ctx, StepUpInput{UserID: u1, Method: "sms", Password: "Password1"}); !errors.Is(err, domain.ErrInvalidInput) || fields["method"] == "" {
+		t.Fatalf("expected unsupported method, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := svc.StepUp(ctx, StepUpInput{UserID: u1, Method: "password", Password: "Password2"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	res, _, err := svc.StepUp(ctx, StepUpInput{UserID: u1, Method: "Password", Password: "Password1"})
+	if err != nil {
+		t.Fatalf("step-up: %v", err)
+	}
+	claims, err := jwtMgr.Verify(res.AccessToken)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !claims.FreshAuth(time.Now(), time.Minute, auth.MethodPassword) || claims.ExpiresAt.Sub(claims.IssuedAt.Time) != testStepUp.TokenTTL {
+		t.Fatalf("unexpected step-up claims: %#v", claims)
+	}
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// requirePermission returns nil when actorID's role grants perm and
+// domain.ErrForbidden otherwise, or when the actor can no longer log in.
+// The role is read from storage so a demotion takes effect before the
+// actor's token expires.
+func requirePermission(ctx context.Context, users repository.UserRepository, actorID string, perm domain.Permission) error {
+	if strings.TrimSpace(actorID) == "" {
+		return domain.ErrUnauthorized
+	}
+	actor, err := users.GetByID(ctx, actorID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrUnauthorized
+		}
+		return err
+	}
+	if !actor.Status.CanLogIn() || !actor.Role.Can(perm) {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// requireCanSend returns domain.ErrAccountRestricted unless userID's status
+// lets them move money out of their wallets. Frozen, suspended, closing and
+// closed users keep their tokens until they expire, so every use case that
+// moves a user's money checks the stored status first.
+func requireCanSend(ctx context.Context, users repository.UserRepository, userID string) error {
+	user, err := users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrUnauthorized
+		}
+		return err
+	}
+	if !user.Status.CanSend() {
+		return domain.ErrAccountRestricted
+	}
+	return nil
+}
+
+// merchantCanReceive reports whether merchant's owner may be paid, so the
+// merchants of an account being closed stop taking payments.
+func merchantCanReceive(ctx context.Context, users repository.UserRepository, merchant *domain.Merchant) (bool, error) {
+	owner, err := users.GetByID(ctx, merchant.OwnerID)
+	if err != nil {
+		return false, err
+	}
+	return owner.Status.CanReceive(), nil
+}
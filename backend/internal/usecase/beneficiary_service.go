@@ -0,0 +1,244 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const maxAccountNameLength = 100
+
+// The metadata fields naming the payee of a transfer and of a withdrawal.
+const (
+	payeeUser  = "recipientId"
+	payeePhone = "phone"
+)
+
+type CreateBeneficiaryInput struct {
+	OwnerID  string
+	Type     string
+	Nickname string
+	// Recipient is the email, phone or username of an Akiba user.
+	Recipient     string
+	Phone         string
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+}
+
+// BeneficiaryConfig sets the cooling-off window that starts when a
+// beneficiary is saved and the cap, in minor units of the payment currency,
+// on the total paid to it during that window.
+type BeneficiaryConfig struct {
+	CoolingOff      time.Duration
+	CoolingOffLimit int64
+}
+
+type BeneficiaryService struct {
+	beneficiaries repository.BeneficiaryRepository
+	users         repository.UserRepository
+	ledger        repository.LedgerRepository
+	cfg           BeneficiaryConfig
+	now           func() time.Time
+}
+
+func NewBeneficiaryService(beneficiaries repository.BeneficiaryRepository, users repository.UserRepository, ledger repository.LedgerRepository, cfg BeneficiaryConfig) *BeneficiaryService {
+	return &BeneficiaryService{beneficiaries: beneficiaries, users: users, ledger: ledger, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Create saves a payee. Akiba users are verified on the spot; M-Pesa and
+// bank destinations stay pending until the rail confirms the account name.
+func (s *BeneficiaryService) Create(ctx context.Context, in CreateBeneficiaryInput) (*domain.Beneficiary, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.OwnerID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	b := &domain.Beneficiary{
+		OwnerID:      in.OwnerID,
+		Type:         domain.BeneficiaryType(strings.ToLower(strings.TrimSpace(in.Type))),
+		Nickname:     domain.NormalizeNickname(in.Nickname),
+		Verification: domain.BeneficiaryPending,
+	}
+	fields := domain.FieldErrors{}
+	if !domain.ValidateNickname(b.Nickname) {
+		fields["nickname"] = "must be 1-40 characters"
+	}
+	switch b.Type {
+	case domain.BeneficiaryTypeAkiba:
+		if strings.TrimSpace(in.Recipient) == "" {
+			fields["recipient"] = "is required"
+		}
+	case domain.BeneficiaryTypeMpesa:
+		b.Phone = domain.NormalizePhone(in.Phone)
+		if !domain.ValidatePhoneE164(b.Phone) {
+			fields["phone"] = "must be valid E.164 format"
+		}
+	case domain.BeneficiaryTypeBank:
+		b.BankCode = domain.NormalizeBankCode(in.BankCode)
+		b.AccountNumber = domain.NormalizeBankAccount(in.AccountNumber)
+		b.AccountName = domain.NormalizeNickname(in.AccountName)
+		if !domain.ValidateBankCode(b.BankCode) {
+			fields["bankCode"] = "must be 2-11 letters or digits"
+		}
+		if !domain.ValidateBankAccount(b.AccountNumber) {
+			fields["accountNumber"] = "must be 6-20 digits"
+		}
+		if b.AccountName == "" || len(b.AccountName) > maxAccountNameLength {
+			fields["accountName"] = "must be 1-100 characters"
+		}
+	default:
+		fields["type"] = "must be akiba_user, mpesa or bank"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if b.Type == domain.BeneficiaryTypeAkiba {
+		recipient, err := s.users.GetByLogin(ctx, normalizeLogin(in.Recipient))
+		if err != nil {
+			if errors.Is(err, domain.ErrUserNotFound) {
+				return nil, nil, domain.ErrRecipientNotFound
+			}
+			return nil, nil, err
+		}
+		if !recipient.Status.CanReceive() {
+			return nil, nil, domain.ErrRecipientNotFound
+		}
+		if recipient.ID == in.OwnerID {
+			return nil, domain.FieldErrors{"recipient": "cannot add yourself"}, domain.ErrInvalidInput
+		}
+		b.RecipientID, b.AccountName, b.Verification = recipient.ID, recipient.UsernameLower, domain.BeneficiaryVerified
+	}
+	now := s.now()
+	b.CoolingOffUntil, b.CreatedAt, b.UpdatedAt = now.Add(s.cfg.CoolingOff), now, now
+	if err := s.beneficiaries.Create(ctx, b); err != nil {
+		return nil, nil, err
+	}
+	return b, nil, nil
+}
+
+func (s *BeneficiaryService) List(ctx context.Context, ownerID string) ([]*domain.Beneficiary, error) {
+	if strings.TrimSpace(ownerID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	return s.beneficiaries.ListByOwner(ctx, ownerID)
+}
+
+func (s *BeneficiaryService) Get(ctx context.Context, ownerID, id string) (*domain.Beneficiary, error) {
+	if strings.TrimSpace(ownerID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	b, err := s.beneficiaries.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if b.OwnerID != ownerID {
+		return nil, domain.ErrBeneficiaryNotFound
+	}
+	return b, nil
+}
+
+// Rename changes only the nickname; destinations are immutable.
+func (s *BeneficiaryService) Rename(ctx context.Context, ownerID, id, nickname string) (*domain.Beneficiary, domain.FieldErrors, error) {
+	b, err := s.Get(ctx, ownerID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	nickname = domain.NormalizeNickname(nickname)
+	if !domain.ValidateNickname(nickname) {
+		return nil, domain.FieldErrors{"nickname": "must be 1-40 characters"}, domain.ErrInvalidInput
+	}
+	now := s.now()
+	if err := s.beneficiaries.UpdateNickname(ctx, b.ID, nickname, now); err != nil {
+		return nil, nil, err
+	}
+	b.Nickname, b.UpdatedAt = nickname, now
+	return b, nil, nil
+}
+
+func (s *BeneficiaryService) Delete(ctx context.Context, ownerID, id string) error {
+	b, err := s.Get(ctx, ownerID, id)
+	if err != nil {
+		return err
+	}
+	return s.beneficiaries.Delete(ctx, b.ID)
+}
+
+// authorizePayment loads the owner's beneficiary of type t for a payment.
+// A nil service knows no beneficiaries.
+func (s *BeneficiaryService) authorizePayment(ctx context.Context, ownerID, id string, t domain.BeneficiaryType) (*domain.Beneficiary, domain.FieldErrors, error) {
+	if s == nil {
+		return nil, nil, domain.ErrBeneficiaryNotFound
+	}
+	b, err := s.Get(ctx, ownerID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.Type != t {
+		return nil, domain.FieldErrors{"beneficiaryId": fmt.Sprintf("must be a %s beneficiary", t)}, domain.ErrInvalidInput
+	}
+	if b.Verification == domain.BeneficiaryFailed {
+		return nil, domain.FieldErrors{"beneficiaryId": "beneficiary failed verification"}, domain.ErrInvalidInput
+	}
+	return b, nil, nil
+}
+
+// payeeCap returns the cooling-off cap on what wallet may send the payee
+// named by field, or nil once the payee is established. A payee is new
+// until the cooling-off window has passed since the owner first paid it or
+// saved it as a beneficiary, so paying by login or saving it again does
+// not lift the cap. A nil service caps nothing.
+func (s *BeneficiaryService) payeeCap(ctx context.Context, wallet *domain.Account, field, payee string) (*domain.PayeeCap, error) {
+	if s == nil {
+		return nil, nil
+	}
+	known, err := s.knownSince(ctx, wallet, field, payee)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	until := now.Add(s.cfg.CoolingOff)
+	if !known.IsZero() {
+		until = known.Add(s.cfg.CoolingOff)
+	}
+	if !now.Before(until) {
+		return nil, nil
+	}
+	// Nothing was sent before the payee became known, so counting the whole
+	// window back from now counts everything sent to it.
+	return &domain.PayeeCap{AccountID: wallet.ID, Field: field, Payee: payee, Since: now.Add(-s.cfg.CoolingOff), Until: until, Limit: s.cfg.CoolingOffLimit}, nil
+}
+
+// knownSince is when the wallet's owner first paid the payee from it or
+// saved it as a beneficiary, whichever was earlier, or the zero time.
+func (s *BeneficiaryService) knownSince(ctx context.Context, wallet *domain.Account, field, payee string) (time.Time, error) {
+	known, err := s.ledger.FirstPaymentTo(ctx, wallet.ID, field, payee)
+	if err != nil {
+		return time.Time{}, err
+	}
+	saved, err := s.beneficiaries.ListByOwner(ctx, wallet.OwnerID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, b := range saved {
+		pays := (field == payeeUser && b.Type == domain.BeneficiaryTypeAkiba && b.RecipientID == payee) ||
+			(field == payeePhone && b.Type == domain.BeneficiaryTypeMpesa && b.Phone == payee)
+		if pays && (known.IsZero() || b.CreatedAt.Before(known)) {
+			known = b.CreatedAt
+		}
+	}
+	return known, nil
+}
+
+// coolingOffFields names what is left under limit for a payment it refused.
+func (s *BeneficiaryService) coolingOffFields(ctx context.Context, limit *domain.PayeeCap) domain.FieldErrors {
+	sent, err := s.ledger.SentWithinCap(ctx, *limit)
+	if err != nil {
+		return nil
+	}
+	remaining := max(limit.Limit-sent, 0)
+	return domain.FieldErrors{"amount": fmt.Sprintf("must be at most %d until %s", remaining, limit.Until.Format(time.RFC3339))}
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+// testCoolingOff caps payments to a new beneficiary at 1000 for a day.
+var testCoolingOff = BeneficiaryConfig{CoolingOff: 24 * time.Hour, CoolingOffLimit: 1000}
+
+func TestCreateBeneficiaryValidatesAndNormalizes(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewBeneficiaryService(memory.NewBeneficiaryRepository(st.Store), st.users, st.ledger, testCoolingOff)
+	alice, bob := addUser(t, st.users, domain.User{UsernameLower: "alice"}), addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	_, fields, err := svc.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "bank", Nickname: "Rent", BankCode: "k!", AccountNumber: "12"})
+	if !errors.Is(err, domain.ErrInvalidInput) || fields["bankCode"] == "" || fields["accountNumber"] == "" || fields["accountName"] == "" {
+		t.Fatalf("expected bank validation errors, got err=%v fields=%#v", err, fields)
+	}
+	b, _, err := svc.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "MPESA", Nickname: "  Mum  ", Phone: " +254711000111 "})
+	if err != nil {
+		t.Fatalf("create mpesa: %v", err)
+	}
+	if b.Type != domain.BeneficiaryTypeMpesa || b.Nickname != "Mum" || b.Phone != "+254711000111" || b.Verification != domain.BeneficiaryPending {
+		t.Fatalf("unexpected beneficiary: %#v", b)
+	}
+	if _, _, err := svc.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "mpesa", Nickname: "Mother", Phone: "+254711000111"}); !errors.Is(err, domain.ErrBeneficiaryExists) {
+		t.Fatalf("expected duplicate destination, got %v", err)
+	}
+	if _, fields, err := svc.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "akiba_user", Nickname: "Me", Recipient: "alice"}); !errors.Is(err, domain.ErrInvalidInput) || fields["recipient"] == "" {
+		t.Fatalf("expected self beneficiary to be rejected, got %v", err)
+	}
+	if _, err := svc.Get(ctx, bob, b.ID); !errors.Is(err, domain.ErrBeneficiaryNotFound) {
+		t.Fatalf("expected beneficiary hidden from other users, got %v", err)
+	}
+}
+
+func TestTransferToBeneficiaryCappedDuringCoolingOff(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	beneficiaries := NewBeneficiaryService(memory.NewBeneficiaryRepository(st.Store), st.users, st.ledger, testCoolingOff)
+	alice, bob := addUser(t, st.users, domain.User{UsernameLower: "alice"}), addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(&fees.Schedule{}), nil, beneficiaries, nil, nil)
+	fund(t, st.ledger, alice, "KES", 10000)
+	b, _, err := beneficiaries.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "akiba_user", Nickname: "Bob", Recipient: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if b.RecipientID != bob || b.Verification != domain.BeneficiaryVerified {
+		t.Fatalf("expected verified akiba beneficiary, got %#v", b)
+	}
+	if _, fields, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 1001, Currency: "KES"}); !errors.Is(err, domain.ErrCoolingOffLimit) || fields["amount"] == "" {
+		t.Fatalf("expected cooling-off limit, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 600, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer within limit: %v", err)
+	}
+	if _, fields, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 401, Currency: "KES"}); !errors.Is(err, domain.ErrCoolingOffLimit) || !strings.HasPrefix(fields["amount"], "must be at most 400 ") {
+		t.Fatalf("expected a split payment to count against the limit, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 400, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer of the rest of the limit: %v", err)
+	}
+	if _, _, err := transfers.Withdraw(ctx, WithdrawalInput{UserID: alice, BeneficiaryID: b.ID, Amount: 100, Currency: "KES"}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("expected akiba beneficiary refused for withdrawal, got %v", err)
+	}
+	beneficiaries.now = func() time.Time { return b.CoolingOffUntil.Add(time.Second) }
+	res, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 5000, Currency: "KES"})
+	if err != nil {
+		t.Fatalf("transfer after cooling-off: %v", err)
+	}
+	if res.Recipient.ID != bob || res.Entry.Metadata["beneficiaryId"] != b.ID {
+		t.Fatalf("unexpected transfer result: %#v", res)
+	}
+}
+
+func TestHeldWithdrawalsCountTowardsCoolingOffLimit(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	beneficiaries := NewBeneficiaryService(memory.NewBeneficiaryRepository(st.Store), st.users, st.ledger, testCoolingOff)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	holds := NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil)
+	schedule := &fees.Schedule{Rules: []fees.Rule{{Type: fees.TypeWithdrawal, Currency: "KES", Flat: 50}}}
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(schedule), holds, beneficiaries, nil, nil)
+	fund(t, st.ledger, alice, "KES", 10000)
+	b, _, err := beneficiaries.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "mpesa", Nickname: "Mum", Phone: "+254711000111"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, _, err := transfers.Withdraw(ctx, WithdrawalInput{UserID: alice, BeneficiaryID: b.ID, Amount: 700, Currency: "KES"}); err != nil {
+		t.Fatalf("withdraw within limit: %v", err)
+	}
+	if _, _, err := transfers.Withdraw(ctx, WithdrawalInput{UserID: alice, BeneficiaryID: b.ID, Amount: 301, Currency: "KES"}); !errors.Is(err, domain.ErrCoolingOffLimit) {
+		t.Fatalf("expected the held withdrawal, not its fee, to count against the limit, got %v", err)
+	}
+	if _, _, err := transfers.Withdraw(ctx, WithdrawalInput{UserID: alice, BeneficiaryID: b.ID, Amount: 300, Currency: "KES"}); err != nil {
+		t.Fatalf("withdraw of the rest of the limit: %v", err)
+	}
+}
+
+func TestCoolingOffFollowsTheRecipient(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	beneficiaries := NewBeneficiaryService(memory.NewBeneficiaryRepository(st.Store), st.users, st.ledger, testCoolingOff)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(&fees.Schedule{}), nil, beneficiaries, nil, nil)
+	addUser(t, st.users, domain.User{UsernameLower: "carol"})
+	fund(t, st.ledger, alice, "KES", 10000)
+	b, _, err := beneficiaries.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "akiba_user", Nickname: "Bob", Recipient: "bob"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 600, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer within limit: %v", err)
+	}
+	if err := beneficiaries.Delete(ctx, alice, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	again, _, err := beneficiaries.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "akiba_user", Nickname: "Bobby", Recipient: "bob"})
+	if err != nil {
+		t.Fatalf("re-create: %v", err)
+	}
+	if _, fields, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: again.ID, Amount: 401, Currency: "KES"}); !errors.Is(err, domain.ErrCoolingOffLimit) || !strings.HasPrefix(fields["amount"], "must be at most 400 ") {
+		t.Fatalf("expected saving bob again to keep the cap, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "bob", Amount: 401, Currency: "KES"}); !errors.Is(err, domain.ErrCoolingOffLimit) {
+		t.Fatalf("expected paying bob by login to count against the cap, got %v", err)
+	}
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "carol", Amount: 1001, Currency: "KES"}); !errors.Is(err, domain.ErrCoolingOffLimit) {
+		t.Fatalf("expected a new recipient paid by login to be capped, got %v", err)
+	}
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "carol", Amount: 1000, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer to carol within limit: %v", err)
+	}
+	beneficiaries.now = func() time.Time { return time.Now().UTC().Add(25 * time.Hour) }
+	if _, _, err := transfers.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "carol", Amount: 5000, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer to carol after cooling-off: %v", err)
+	}
+}
+
+func TestConcurrentPaymentsCannotPassTheCoolingOffCap(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	beneficiaries := NewBeneficiaryService(memory.NewBeneficiaryRepository(st.Store), st.users, st.ledger, testCoolingOff)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	holds := NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil)
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(&fees.Schedule{}), holds, beneficiaries, nil, nil)
+	fund(t, st.ledger, alice, "KES", 10000)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	paid := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := transfers.Withdraw(ctx, WithdrawalInput{UserID: alice, Phone: "+254711000111", Amount: 300, Currency: "KES"})
+			if err == nil {
+				mu.Lock()
+				paid++
+				mu.Unlock()
+			} else if !errors.Is(err, domain.ErrCoolingOffLimit) {
+				t.Errorf("withdraw: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if paid != 3 {
+		t.Fatalf("expected 3 withdrawals of 300 under a cap of 1000, got %d", paid)
+	}
+}
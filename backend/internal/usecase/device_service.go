@@ -0,0 +1,230 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/notify"
+	"akiba/backend/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const maxPushTokenLen = 4096
+
+// DeviceConfig sets how long a login OTP is valid and how many wrong codes
+// void it.
+type DeviceConfig struct {
+	OTPTTL         time.Duration
+	OTPMaxAttempts int
+}
+
+// DeviceInput describes the installation a client signs in from. PublicKey
+// is the base64 DER (PKIX) P-256 key the device signs assertions with.
+type DeviceInput struct {
+	DeviceID  string
+	Platform  string
+	PublicKey string
+	PushToken string
+}
+
+// DeviceService keeps the registry of devices users sign in from. Logins
+// from devices that are not trusted yet are confirmed with a code sent to
+// the user's phone, and the user's other devices are told about them.
+type DeviceService struct {
+	users   repository.UserRepository
+	devices repository.DeviceRepository
+	sender  notify.Sender
+	cfg     DeviceConfig
+	now     func() time.Time
+}
+
+func NewDeviceService(users repository.UserRepository, devices repository.DeviceRepository, sender notify.Sender, cfg DeviceConfig) *DeviceService {
+	return &DeviceService{users: users, devices: devices, sender: sender, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+func (s *DeviceService) List(ctx context.Context, userID string) ([]*domain.Device, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	return s.devices.ListByUser(ctx, userID)
+}
+
+// Revoke forgets a device, so the next login from it needs an OTP again.
+// Tokens already bound to it stay valid until they expire.
+func (s *DeviceService) Revoke(ctx context.Context, userID, id string) error {
+	if strings.TrimSpace(userID) == "" {
+		return domain.ErrUnauthorized
+	}
+	return s.devices.Delete(ctx, userID, id)
+}
+
+// UseAssertion records an accepted device assertion, returning
+// domain.ErrAssertionReplayed if the same signed request was seen before.
+func (s *DeviceService) UseAssertion(ctx context.Context, a *auth.VerifiedAssertion) error {
+	return s.devices.UseAssertion(ctx, a.Digest, s.now(), a.ExpiresAt)
+}
+
+// parse validates in, reporting problems under "device.*" fields. A nil in
+// yields a nil device.
+func (s *DeviceService) parse(in *DeviceInput, fields domain.FieldErrors) *domain.Device {
+	if in == nil {
+		return nil
+	}
+	d := &domain.Device{DeviceID: strings.TrimSpace(in.DeviceID), Platform: domain.DevicePlatform(strings.ToLower(strings.TrimSpace(in.Platform))), PublicKey: strings.TrimSpace(in.PublicKey), PushToken: strings.TrimSpace(in.PushToken)}
+	if !domain.ValidateDeviceID(d.DeviceID) {
+		fields["device.id"] = "must be 8-128 letters, digits or . _ : -"
+	}
+	if !d.Platform.Valid() {
+		fields["device.platform"] = "must be ios, android or web"
+	}
+	if _, err := auth.ParseDeviceKey(d.PublicKey); err != nil {
+		fields["device.publicKey"] = "must be a base64 DER P-256 public key"
+	}
+	if len(d.PushToken) > maxPushTokenLen {
+		fields["device.pushToken"] = "is too long"
+	}
+	return d
+}
+
+// checkLogin decides whether a login from device, whose first factor was
+// method, may complete. A trusted device with the same key is returned
+// after recording the sign-in; otherwise an OTP is sent and the pending
+// challenge returned. A nil device always needs an OTP.
+func (s *DeviceService) checkLogin(ctx context.Context, user *domain.User, device *domain.Device, method string) (*domain.Device, *domain.OTPChallenge, error) {
+	now := s.now()
+	if device != nil {
+		known, err := s.devices.Get(ctx, user.ID, device.DeviceID)
+		if err != nil && !errors.Is(err, domain.ErrDeviceNotFound) {
+			return nil, nil, err
+		}
+		if known != nil && known.Trusted && known.PublicKey == device.PublicKey {
+			if err := s.devices.Touch(ctx, known.ID, device.PushToken, now); err != nil {
+				return nil, nil, err
+			}
+			known.LastSeenAt = now
+			if device.PushToken != "" {
+				known.PushToken = device.PushToken
+			}
+			return known, nil, nil
+		}
+		device.UserID = user.ID
+	}
+	code, err := newOTP()
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, err
+	}
+	challenge := &domain.OTPChallenge{UserID: user.ID, CodeHash: string(hash), Device: device, Method: method, ExpiresAt: now.Add(s.cfg.OTPTTL), CreatedAt: now}
+	if err := s.devices.CreateOTP(ctx, challenge); err != nil {
+		return nil, nil, err
+	}
+	if err := s.sender.SendOTP(ctx, user.PhoneE164, code); err != nil {
+		return nil, nil, err
+	}
+	s.alertOtherDevices(ctx, user.ID, device)
+	return nil, challenge, nil
+}
+
+// confirm checks an OTP and, on success, trusts the device the login named
+// and returns the challenge it answered. Each challenge accepts one correct
+// code and is voided after OTPMaxAttempts wrong ones.
+func (s *DeviceService) confirm(ctx context.Context, challengeID, code string) (*domain.User, *domain.OTPChallenge, error) {
+	challenge, err := s.devices.GetOTP(ctx, challengeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := s.now()
+	if !now.Before(challenge.ExpiresAt) {
+		_ = s.devices.DeleteOTP(ctx, challenge.ID)
+		return nil, nil, domain.ErrChallengeNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(challenge.CodeHash), []byte(code)) != nil {
+		attempts, err := s.devices.RecordOTPFailure(ctx, challenge.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if attempts >= s.cfg.OTPMaxAttempts {
+			if err := s.devices.DeleteOTP(ctx, challenge.ID); err != nil && !errors.Is(err, domain.ErrChallengeNotFound) {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, domain.ErrInvalidOTP
+	}
+	// Deleting first makes the code single use under concurrent attempts.
+	if err := s.devices.DeleteOTP(ctx, challenge.ID); err != nil {
+		return nil, nil, err
+	}
+	user, err := s.users.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Status.CanLogIn() {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	// The code went to the user's phone, so a correct one proves they
+	// receive messages there.
+	if !user.PhoneVerified() {
+		if err := s.users.MarkPhoneVerified(ctx, user.ID, now); err != nil {
+			return nil, nil, err
+		}
+		user.PhoneVerifiedAt = now
+	}
+	if challenge.Device != nil {
+		if err := s.trust(ctx, user.ID, challenge.Device); err != nil {
+			return nil, nil, err
+		}
+	}
+	return user, challenge, nil
+}
+
+// trust records device as trusted for userID.
+func (s *DeviceService) trust(ctx context.Context, userID string, device *domain.Device) error {
+	now := s.now()
+	device.UserID, device.Trusted, device.FirstSeenAt, device.LastSeenAt = userID, true, now, now
+	return s.devices.Upsert(ctx, device)
+}
+
+// alertOtherDevices pushes a sign-in warning to the user's trusted devices.
+// Delivery is best effort and never blocks the login.
+func (s *DeviceService) alertOtherDevices(ctx context.Context, userID string, from *domain.Device) {
+	devices, err := s.devices.ListByUser(ctx, userID)
+	if err != nil {
+		return
+	}
+	source := "an unrecognised device"
+	if from != nil {
+		source = fmt.Sprintf("a new %s device", from.Platform)
+	}
+	for _, d := range devices {
+		if !d.Trusted || d.PushToken == "" || (from != nil && d.DeviceID == from.DeviceID) {
+			continue
+		}
+		_ = s.sender.Push(ctx, d.PushToken, "New sign-in attempt", "Someone signed in to your Akiba account from "+source+". If this wasn't you, change your password.")
+	}
+}
+
+func newOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func deviceClaim(d *domain.Device) *auth.DeviceClaim {
+	if d == nil {
+		return nil
+	}
+	return &auth.DeviceClaim{ID: d.DeviceID, Key: d.PublicKey}
+}
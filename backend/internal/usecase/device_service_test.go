@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memSender records what would have been delivered.
+type memSender struct {
+	codes  []string
+	pushed []string
+}
+
+func (m *memSender) SendOTP(ctx context.Context, phone, code string) error {
+	m.codes = append(m.codes, code)
+	return nil
+}
+func (m *memSender) Push(ctx context.Context, pushToken, title, body string) error {
+	m.pushed = append(m.pushed, pushToken)
+	return nil
+}
+
+func newDeviceInput(t *testing.T, id, pushToken string) *DeviceInput {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DeviceInput{DeviceID: id, Platform: "android", PublicKey: base64.StdEncoding.EncodeToString(der), PushToken: pushToken}
+}
+
+// newDeviceFixture returns an auth service checking devices on a memory
+// store, the device store, the sender and the ID of user_1.
+func newDeviceFixture(t *testing.T) (*AuthService, *memory.DeviceRepository, *memSender, string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("Password1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := memory.NewStore()
+	users, store := memory.NewUserRepository(s), memory.NewDeviceRepository(s)
+	u1 := addUser(t, users, domain.User{UsernameLower: "user_1", PhoneE164: "+254700000001", PasswordHash: string(hash)})
+	sender := &memSender{}
+	devices := NewDeviceService(users, store, sender, DeviceConfig{OTPTTL: 5 * time.Minute, OTPMaxAttempts: 3})
+	return NewAuthService(users, auth.NewJWTManager("secret", "test"), time.Hour, testStepUp, nil, nil, devices, nil), store, sender, u1
+}
+
+func TestNewDeviceLoginNeedsOTP(t *testing.T) {
+	ctx := context.Background()
+	svc, store, sender, u1 := newDeviceFixture(t)
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	phone := newDeviceInput(t, "phone-0001", "push-phone")
+	if err := svc.devices.trust(ctx, u1, svc.devices.parse(phone, domain.FieldErrors{})); err != nil {
+		t.Fatal(err)
+	}
+
+	tablet := newDeviceInput(t, "tablet-0001", "push-tablet")
+	res, _, err := svc.Login(ctx, LoginInput{Login: "user_1", Password: "Password1", Device: tablet})
+	if err != nil || res.OTPChallengeID == "" || res.AccessToken != "" || res.User != nil {
+		t.Fatalf("expected an OTP challenge only, got %#v %v", res, err)
+	}
+	if len(sender.codes) != 1 || !slices.Equal(sender.pushed, []string{"push-phone"}) {
+		t.Fatalf("expected one code and a push to the phone, got %v %v", sender.codes, sender.pushed)
+	}
+	if _, _, err := svc.VerifyLoginOTP(ctx, VerifyLoginOTPInput{ChallengeID: res.OTPChallengeID, Code: "000000x"}); !errors.Is(err, domain.ErrInvalidOTP) {
+		t.Fatalf("expected wrong code rejected, got %v", err)
+	}
+	if u, _ := svc.users.GetByID(ctx, u1); u.PhoneVerified() {
+		t.Fatal("expected the phone unverified before a correct code")
+	}
+	done, _, err := svc.VerifyLoginOTP(ctx, VerifyLoginOTPInput{ChallengeID: res.OTPChallengeID, Code: sender.codes[0]})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if u, _ := svc.users.GetByID(ctx, u1); !u.PhoneVerified() {
+		t.Fatal("expected a correct code to verify the phone")
+	}
+	claims, err := jwtMgr.Verify(done.AccessToken)
+	if err != nil || claims.Device == nil || claims.Device.ID != "tablet-0001" || !slices.Equal(claims.AMR, []string{auth.MethodPassword, auth.MethodOTP}) {
+		t.Fatalf("expected token bound to the tablet, got %#v %v", claims, err)
+	}
+	if _, _, err := svc.VerifyLoginOTP(ctx, VerifyLoginOTPInput{ChallengeID: res.OTPChallengeID, Code: sender.codes[0]}); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("expected code to be single use, got %v", err)
+	}
+
+	// The tablet is trusted now; a different key under the same ID is not.
+	again, _, err := svc.Login(ctx, LoginInput{Login: "user_1", Password: "Password1", Device: tablet})
+	if err != nil || again.AccessToken == "" || len(sender.codes) != 1 {
+		t.Fatalf("expected direct login from trusted device, got %#v %v", again, err)
+	}
+	if d, _ := store.Get(ctx, u1, "tablet-0001"); d == nil || !d.Trusted || d.LastSeenAt.Before(d.FirstSeenAt) {
+		t.Fatalf("expected trusted tablet with sightings recorded, got %#v", d)
+	}
+	impostor := newDeviceInput(t, "tablet-0001", "")
+	if res, _, err := svc.Login(ctx, LoginInput{Login: "user_1", Password: "Password1", Device: impostor}); err != nil || res.OTPChallengeID == "" {
+		t.Fatalf("expected OTP for a changed device key, got %#v %v", res, err)
+	}
+}
+
+func TestLoginOTPVoidedAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	svc, _, sender, _ := newDeviceFixture(t)
+	res, _, err := svc.Login(ctx, LoginInput{Login: "user_1", Password: "Password1"})
+	if err != nil || res.OTPChallengeID == "" {
+		t.Fatalf("expected deviceless login to need an OTP, got %#v %v", res, err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := svc.VerifyLoginOTP(ctx, VerifyLoginOTPInput{ChallengeID: res.OTPChallengeID, Code: "wrong"}); !errors.Is(err, domain.ErrInvalidOTP) {
+			t.Fatalf("attempt %d: expected invalid OTP, got %v", i+1, err)
+		}
+	}
+	if _, _, err := svc.VerifyLoginOTP(ctx, VerifyLoginOTPInput{ChallengeID: res.OTPChallengeID, Code: sender.codes[0]}); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("expected challenge voided, got %v", err)
+	}
+	bad := &DeviceInput{DeviceID: "x", Platform: "symbian", PublicKey: "junk"}
+	if _, fields, err := svc.Login(ctx, LoginInput{Login: "user_1", Password: "Password1", Device: bad}); !errors.Is(err, domain.ErrInvalidInput) || len(fields) != 3 {
+		t.Fatalf("expected device validation errors, got %v %v", fields, err)
+	}
+}
@@ -0,0 +1,378 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const maxEvidenceBytes = 512 * 1024
+
+var evidenceContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+type OpenDisputeInput struct {
+	PayerID string
+	EntryID string
+	Amount  int64
+	Reason  string
+}
+type AddEvidenceInput struct {
+	ActorID     string
+	DisputeID   string
+	Note        string
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+type ResolveDisputeInput struct {
+	ActorID   string
+	DisputeID string
+	// Outcome is "customer" (refund the held funds to the payer) or
+	// "merchant" (release them back to the payee).
+	Outcome string
+	Note    string
+}
+
+const (
+	DisputeOutcomeCustomer = "customer"
+	DisputeOutcomeMerchant = "merchant"
+)
+
+// DisputeService runs the chargeback workflow. Opening a dispute moves the
+// disputed amount (or whatever the payee still holds) into suspense so it
+// cannot be spent while support reviews the case.
+type DisputeService struct {
+	disputes  repository.DisputeRepository
+	ledger    repository.LedgerRepository
+	reversals *ReversalService
+}
+
+func NewDisputeService(disputes repository.DisputeRepository, ledger repository.LedgerRepository, reversals *ReversalService) *DisputeService {
+	return &DisputeService{disputes: disputes, ledger: ledger, reversals: reversals}
+}
+
+func (s *DisputeService) Open(ctx context.Context, in OpenDisputeInput) (*domain.Dispute, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.PayerID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	fields := domain.FieldErrors{}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		fields["reason"] = "must be 1-500 characters"
+	}
+	if in.Amount < 0 {
+		fields["amount"] = "must be positive"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	orig, parties, err := s.reversals.payment(ctx, in.EntryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parties.payer.OwnerID != in.PayerID {
+		return nil, nil, domain.ErrEntryNotFound
+	}
+	state, err := s.reversals.refundState(ctx, orig.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	remaining := parties.amount - state.refunded
+	if state.reversed || remaining <= 0 {
+		return nil, nil, domain.ErrNotReversible
+	}
+	amount := in.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount > remaining {
+		return nil, domain.FieldErrors{"amount": fmt.Sprintf("must not exceed the refundable %d", remaining)}, domain.ErrInvalidInput
+	}
+
+	now := time.Now().UTC()
+	dispute := &domain.Dispute{
+		EntryID:        orig.ID,
+		PayerID:        in.PayerID,
+		PayeeOwnerID:   parties.payeeOwnerID,
+		PayerAccountID: parties.payer.ID,
+		PayeeAccountID: parties.payee.ID,
+		Currency:       parties.currency,
+		Amount:         amount,
+		Reason:         reason,
+		Status:         domain.DisputeStatusOpen,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.disputes.Create(ctx, dispute); err != nil {
+		return nil, nil, err
+	}
+
+	// The payee may already have spent the money; hold what is there.
+	hold := amount
+	if available := parties.payee.Available(); available < hold {
+		hold = available
+	}
+	if hold > 0 {
+		suspense, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerSuspense, domain.AccountTypeSystem, parties.currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry := &domain.JournalEntry{
+			Kind:      domain.EntryKindDisputeHold,
+			Reference: "dispute_hold:" + dispute.ID,
+			Postings: []domain.Posting{
+				{AccountID: parties.payee.ID, Amount: -hold, Currency: parties.currency},
+				{AccountID: suspense.ID, Amount: hold, Currency: parties.currency},
+			},
+			Metadata:  map[string]string{"disputeId": dispute.ID, "entryId": orig.ID},
+			CreatedAt: now,
+		}
+		if err := s.ledger.Post(ctx, entry); err != nil {
+			if !errors.Is(err, domain.ErrInsufficientFunds) {
+				return nil, nil, err
+			}
+			// Balance moved between the read and the post; leave the
+			// dispute open with nothing held rather than fail it.
+		} else {
+			if err := s.disputes.SetHold(ctx, dispute.ID, entry.ID, hold, now); err != nil {
+				return nil, nil, err
+			}
+			dispute.HoldEntryID, dispute.HeldAmount = entry.ID, hold
+		}
+	}
+	return dispute, nil, nil
+}
+
+func (s *DisputeService) AddEvidence(ctx context.Context, in AddEvidenceInput) (*domain.Evidence, domain.FieldErrors, error) {
+	dispute, err := s.visible(ctx, in.ActorID, in.DisputeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dispute.Status.Resolved() {
+		return nil, nil, domain.ErrInvalidTransition
+	}
+	fields := domain.FieldErrors{}
+	note := strings.TrimSpace(in.Note)
+	contentType := strings.ToLower(strings.TrimSpace(in.ContentType))
+	if note == "" && len(in.Data) == 0 {
+		fields["note"] = "note or attachment is required"
+	}
+	if len(note) > 2000 {
+		fields["note"] = "must be at most 2000 characters"
+	}
+	if len(in.Data) > 0 && !evidenceContentTypes[contentType] {
+		fields["contentType"] = "must be image/jpeg, image/png, application/pdf or text/plain"
+	}
+	if len(in.Data) > maxEvidenceBytes {
+		fields["data"] = "must be at most 512KB"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	id, err := newEvidenceID()
+	if err != nil {
+		return nil, nil, err
+	}
+	evidence := domain.Evidence{ID: id, SubmittedBy: in.ActorID, Note: note, CreatedAt: time.Now().UTC()}
+	if len(in.Data) > 0 {
+		sum := sha256.Sum256(in.Data)
+		evidence.FileName = strings.TrimSpace(in.FileName)
+		evidence.ContentType = contentType
+		evidence.SHA256 = hex.EncodeToString(sum[:])
+		evidence.Size = len(in.Data)
+		evidence.Data = in.Data
+	}
+	if err := s.disputes.AddEvidence(ctx, dispute.ID, evidence); err != nil {
+		return nil, nil, err
+	}
+	return &evidence, nil, nil
+}
+
+func (s *DisputeService) Get(ctx context.Context, actorID, disputeID string) (*domain.Dispute, error) {
+	return s.visible(ctx, actorID, disputeID)
+}
+
+func (s *DisputeService) GetEvidence(ctx context.Context, actorID, disputeID, evidenceID string) (*domain.Evidence, error) {
+	dispute, err := s.visible(ctx, actorID, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range dispute.Evidence {
+		if e.ID == evidenceID {
+			return &e, nil
+		}
+	}
+	return nil, domain.ErrEvidenceNotFound
+}
+
+// List returns the caller's own disputes, or for operators the queue
+// filtered by status.
+func (s *DisputeService) List(ctx context.Context, actorID string, status domain.DisputeStatus) ([]*domain.Dispute, error) {
+	if err := requirePermission(ctx, s.reversals.users, actorID, domain.PermDisputesManage); err != nil {
+		if !errors.Is(err, domain.ErrForbidden) {
+			return nil, err
+		}
+		return s.disputes.ListByParty(ctx, actorID)
+	}
+	return s.disputes.ListByStatus(ctx, status)
+}
+
+func (s *DisputeService) StartReview(ctx context.Context, actorID, disputeID string) (*domain.Dispute, error) {
+	if err := requirePermission(ctx, s.reversals.users, actorID, domain.PermDisputesManage); err != nil {
+		return nil, err
+	}
+	dispute, err := s.disputes.GetByID(ctx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	from := dispute.Status
+	if !from.CanTransition(domain.DisputeStatusUnderReview) {
+		return nil, domain.ErrInvalidTransition
+	}
+	dispute.Status, dispute.UpdatedAt = domain.DisputeStatusUnderReview, time.Now().UTC()
+	if err := s.disputes.Transition(ctx, dispute, from); err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// Resolve settles a dispute. Only operators with PermDisputesManage may
+// resolve. The payout out of suspense is posted first, under a reference
+// unique to the dispute, and the status changes after: two concurrent
+// resolutions cannot both pay out, and one that fails between the two
+// steps is finished by resolving again with the same outcome.
+func (s *DisputeService) Resolve(ctx context.Context, in ResolveDisputeInput) (*domain.Dispute, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.reversals.users, in.ActorID, domain.PermDisputesManage); err != nil {
+		return nil, nil, err
+	}
+	var next domain.DisputeStatus
+	switch in.Outcome {
+	case DisputeOutcomeCustomer:
+		next = domain.DisputeStatusResolvedCustomer
+	case DisputeOutcomeMerchant:
+		next = domain.DisputeStatusResolvedMerchant
+	default:
+		return nil, domain.FieldErrors{"outcome": "must be customer or merchant"}, domain.ErrInvalidInput
+	}
+	note := strings.TrimSpace(in.Note)
+	if len(note) > maxReasonLength {
+		return nil, domain.FieldErrors{"note": "must be at most 500 characters"}, domain.ErrInvalidInput
+	}
+	dispute, err := s.disputes.GetByID(ctx, in.DisputeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	from := dispute.Status
+	if !from.CanTransition(next) {
+		return nil, nil, domain.ErrInvalidTransition
+	}
+	now := time.Now().UTC()
+	if dispute.HeldAmount > 0 {
+		entry, err := s.settle(ctx, dispute, next, map[string]string{"actorId": in.ActorID, "disputeId": dispute.ID, "reason": note})
+		if err != nil {
+			return nil, nil, err
+		}
+		dispute.ResolutionEntryID = entry.ID
+	}
+	dispute.Status, dispute.ResolutionNote, dispute.ResolvedBy, dispute.ResolvedAt, dispute.UpdatedAt = next, note, in.ActorID, now, now
+	if err := s.disputes.Transition(ctx, dispute, from); err != nil {
+		return nil, nil, err
+	}
+	return dispute, nil, nil
+}
+
+// settle moves a dispute's held funds out of suspense: to the payer for
+// DisputeStatusResolvedCustomer, back to the payee otherwise. Both outcomes
+// share one reference, so only one can ever post; settling again with the
+// outcome already posted returns that entry.
+func (s *DisputeService) settle(ctx context.Context, dispute *domain.Dispute, next domain.DisputeStatus, meta map[string]string) (*domain.JournalEntry, error) {
+	reference := "dispute_resolution:" + dispute.ID
+	kind := domain.EntryKindDisputeRelease
+	if next == domain.DisputeStatusResolvedCustomer {
+		kind = domain.EntryKindRefund
+	}
+	suspense, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerSuspense, domain.AccountTypeSystem, dispute.Currency)
+	if err != nil {
+		return nil, err
+	}
+	var entry *domain.JournalEntry
+	if kind == domain.EntryKindRefund {
+		orig, parties, lookupErr := s.reversals.payment(ctx, dispute.EntryID)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		// postRefund reports a reused reference as ErrNotReversible.
+		entry, err = s.reversals.postRefund(ctx, orig, suspense.ID, parties, dispute.HeldAmount, reference, meta)
+	} else {
+		entry = &domain.JournalEntry{
+			Kind:      kind,
+			Reference: reference,
+			Postings: []domain.Posting{
+				{AccountID: suspense.ID, Amount: -dispute.HeldAmount, Currency: dispute.Currency},
+				{AccountID: dispute.PayeeAccountID, Amount: dispute.HeldAmount, Currency: dispute.Currency},
+			},
+			Metadata:  meta,
+			CreatedAt: time.Now().UTC(),
+		}
+		err = s.ledger.Post(ctx, entry)
+	}
+	if err == nil {
+		return entry, nil
+	}
+	if !errors.Is(err, domain.ErrDuplicateEntry) && !errors.Is(err, domain.ErrNotReversible) {
+		return nil, err
+	}
+	// Posted before: by a resolution that failed to record it, or by a
+	// concurrent one, which wins if it chose the other outcome.
+	posted, err := s.ledger.GetEntryByReference(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	if posted.Kind != kind {
+		return nil, domain.ErrInvalidTransition
+	}
+	return posted, nil
+}
+
+// visible loads a dispute for one of its parties or an operator; anyone
+// else gets not found.
+func (s *DisputeService) visible(ctx context.Context, actorID, disputeID string) (*domain.Dispute, error) {
+	if strings.TrimSpace(actorID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	dispute, err := s.disputes.GetByID(ctx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.PayerID == actorID || dispute.PayeeOwnerID == actorID {
+		return dispute, nil
+	}
+	if err := requirePermission(ctx, s.reversals.users, actorID, domain.PermDisputesManage); err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			return nil, domain.ErrDisputeNotFound
+		}
+		return nil, err
+	}
+	return dispute, nil
+}
+
+func newEvidenceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
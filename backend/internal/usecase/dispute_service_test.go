@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/repository"
+)
+
+// addPayment funds payerID with 10000 KES and has them send 5000, plus a
+// 100 fee, to recipient.
+func addPayment(t *testing.T, st testStore, payerID, recipient string) *domain.JournalEntry {
+	t.Helper()
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(&fees.Schedule{Rules: []fees.Rule{{Type: fees.TypeTransfer, Currency: "KES", Flat: 100}}}), nil, nil, nil, nil)
+	fund(t, st.ledger, payerID, "KES", 10000)
+	res, _, err := transfers.Transfer(context.Background(), TransferInput{SenderID: payerID, Recipient: recipient, Amount: 5000, Currency: "KES"})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	return res.Entry
+}
+
+// kesBalance returns the balance of ownerID's KES account of accountType.
+func kesBalance(ledger repository.LedgerRepository, ownerID string, accountType domain.AccountType) int64 {
+	a, _ := ledger.GetOrCreateAccount(context.Background(), ownerID, accountType, "KES")
+	return a.Balance
+}
+
+func TestReverseIsOperatorOnlyAndNegatesFee(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	operator := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	payment := addPayment(t, st, alice, "bob")
+	reversals := NewReversalService(st.users, st.merchants, st.ledger, memory.NewDisputeRepository(st.Store))
+	if _, _, err := reversals.Reverse(ctx, ReverseInput{ActorID: bob, EntryID: payment.ID, Reason: "mistake"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected forbidden for customer, got %v", err)
+	}
+	entry, _, err := reversals.Reverse(ctx, ReverseInput{ActorID: operator, EntryID: payment.ID, Reason: "mistake"})
+	if err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if entry.ReversalOf != payment.ID || len(payment.Postings) != len(entry.Postings) {
+		t.Fatalf("unexpected reversal: %#v", entry)
+	}
+	if kesBalance(st.ledger, alice, domain.AccountTypeWallet) != 10000 || kesBalance(st.ledger, bob, domain.AccountTypeWallet) != 0 || kesBalance(st.ledger, domain.SystemOwnerRevenue, domain.AccountTypeSystem) != 0 {
+		t.Fatalf("reversal did not restore balances")
+	}
+	if _, _, err := reversals.Reverse(ctx, ReverseInput{ActorID: operator, EntryID: payment.ID, Reason: "again"}); !errors.Is(err, domain.ErrNotReversible) {
+		t.Fatalf("expected second reversal to be refused, got %v", err)
+	}
+}
+
+func TestPartialRefundsCappedAtPrincipal(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	payment := addPayment(t, st, alice, "bob")
+	reversals := NewReversalService(st.users, st.merchants, st.ledger, memory.NewDisputeRepository(st.Store))
+	if _, _, err := reversals.Refund(ctx, RefundInput{ActorID: alice, EntryID: payment.ID, Amount: 100}); !errors.Is(err, domain.ErrEntryNotFound) {
+		t.Fatalf("expected payer to be refused, got %v", err)
+	}
+	if _, _, err := reversals.Refund(ctx, RefundInput{ActorID: bob, EntryID: payment.ID, Amount: 3000}); err != nil {
+		t.Fatalf("first refund: %v", err)
+	}
+	if _, fields, err := reversals.Refund(ctx, RefundInput{ActorID: bob, EntryID: payment.ID, Amount: 2001}); !errors.Is(err, domain.ErrInvalidInput) || fields["amount"] == "" {
+		t.Fatalf("expected refund over remaining to fail, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := reversals.Refund(ctx, RefundInput{ActorID: bob, EntryID: payment.ID, Amount: 2000}); err != nil {
+		t.Fatalf("second refund: %v", err)
+	}
+	if kesBalance(st.ledger, alice, domain.AccountTypeWallet) != 9900 || kesBalance(st.ledger, bob, domain.AccountTypeWallet) != 0 {
+		t.Fatalf("unexpected balances after full refund")
+	}
+}
+
+func TestDisputeHoldsFundsUntilResolvedForCustomer(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	operator := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	payment := addPayment(t, st, alice, "bob")
+	disputeRepo := memory.NewDisputeRepository(st.Store)
+	reversals := NewReversalService(st.users, st.merchants, st.ledger, disputeRepo)
+	disputes := NewDisputeService(disputeRepo, st.ledger, reversals)
+	dispute, _, err := disputes.Open(ctx, OpenDisputeInput{PayerID: alice, EntryID: payment.ID, Reason: "goods never arrived"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if dispute.Amount != 5000 || dispute.HeldAmount != 5000 || kesBalance(st.ledger, domain.SystemOwnerSuspense, domain.AccountTypeSystem) != 5000 {
+		t.Fatalf("expected full hold, got %#v", dispute)
+	}
+	if _, _, err := disputes.Open(ctx, OpenDisputeInput{PayerID: alice, EntryID: payment.ID, Reason: "again"}); !errors.Is(err, domain.ErrDisputeExists) {
+		t.Fatalf("expected duplicate dispute, got %v", err)
+	}
+	if _, _, err := reversals.Refund(ctx, RefundInput{ActorID: bob, EntryID: payment.ID, Amount: 100}); !errors.Is(err, domain.ErrDisputeExists) {
+		t.Fatalf("expected refund blocked during dispute, got %v", err)
+	}
+	if _, _, err := disputes.AddEvidence(ctx, AddEvidenceInput{ActorID: bob, DisputeID: dispute.ID, ContentType: "application/zip", Data: []byte("PK")}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("expected content type validation, got %v", err)
+	}
+	evidence, _, err := disputes.AddEvidence(ctx, AddEvidenceInput{ActorID: bob, DisputeID: dispute.ID, Note: "delivered", FileName: "receipt.txt", ContentType: "text/plain", Data: []byte("signed for")})
+	if err != nil || evidence.SHA256 == "" {
+		t.Fatalf("add evidence: err=%v evidence=%#v", err, evidence)
+	}
+	if _, _, err := disputes.Resolve(ctx, ResolveDisputeInput{ActorID: alice, DisputeID: dispute.ID, Outcome: DisputeOutcomeCustomer}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customers to be refused, got %v", err)
+	}
+	resolved, _, err := disputes.Resolve(ctx, ResolveDisputeInput{ActorID: operator, DisputeID: dispute.ID, Outcome: DisputeOutcomeCustomer, Note: "no proof of delivery"})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved.Status != domain.DisputeStatusResolvedCustomer || resolved.ResolutionEntryID == "" {
+		t.Fatalf("unexpected resolution: %#v", resolved)
+	}
+	if kesBalance(st.ledger, alice, domain.AccountTypeWallet) != 9900 || kesBalance(st.ledger, domain.SystemOwnerSuspense, domain.AccountTypeSystem) != 0 {
+		t.Fatalf("expected held funds refunded to payer")
+	}
+	if _, _, err := disputes.Resolve(ctx, ResolveDisputeInput{ActorID: operator, DisputeID: dispute.ID, Outcome: DisputeOutcomeMerchant}); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("expected resolved dispute to be final, got %v", err)
+	}
+}
+
+func TestReverseRefusesInternalEntries(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	operator := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	payment := addPayment(t, st, alice, "bob")
+	disputeRepo := memory.NewDisputeRepository(st.Store)
+	reversals := NewReversalService(st.users, st.merchants, st.ledger, disputeRepo)
+	disputes := NewDisputeService(disputeRepo, st.ledger, reversals)
+	dispute, _, err := disputes.Open(ctx, OpenDisputeInput{PayerID: alice, EntryID: payment.ID, Reason: "goods never arrived"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, _, err := reversals.Reverse(ctx, ReverseInput{ActorID: operator, EntryID: dispute.HoldEntryID, Reason: "mistake"}); !errors.Is(err, domain.ErrNotReversible) {
+		t.Fatalf("expected the dispute hold refused, got %v", err)
+	}
+	if kesBalance(st.ledger, domain.SystemOwnerSuspense, domain.AccountTypeSystem) != 5000 {
+		t.Fatalf("expected the held funds left in suspense")
+	}
+}
+
+// failingTransitions fails the next Transition, as if the database went
+// away after the payout was posted.
+type failingTransitions struct {
+	repository.DisputeRepository
+	fail bool
+}
+
+func (d *failingTransitions) Transition(ctx context.Context, dispute *domain.Dispute, from domain.DisputeStatus) error {
+	if d.fail {
+		d.fail = false
+		return errors.New("connection reset")
+	}
+	return d.DisputeRepository.Transition(ctx, dispute, from)
+}
+
+func TestResolveRetriesAfterAFailedTransition(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	operator := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	payment := addPayment(t, st, alice, "bob")
+	disputeRepo := memory.NewDisputeRepository(st.Store)
+	reversals := NewReversalService(st.users, st.merchants, st.ledger, disputeRepo)
+	disputes := NewDisputeService(disputeRepo, st.ledger, reversals)
+	dispute, _, err := disputes.Open(ctx, OpenDisputeInput{PayerID: alice, EntryID: payment.ID, Reason: "goods never arrived"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	failing := NewDisputeService(&failingTransitions{DisputeRepository: disputeRepo, fail: true}, st.ledger, reversals)
+	resolve := func(outcome string) (*domain.Dispute, error) {
+		resolved, _, err := failing.Resolve(ctx, ResolveDisputeInput{ActorID: operator, DisputeID: dispute.ID, Outcome: outcome})
+		return resolved, err
+	}
+	if _, err := resolve(DisputeOutcomeCustomer); err == nil {
+		t.Fatal("expected the failed transition reported")
+	}
+	if _, err := resolve(DisputeOutcomeMerchant); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("expected the other outcome refused once paid out, got %v", err)
+	}
+	resolved, err := resolve(DisputeOutcomeCustomer)
+	if err != nil || resolved.Status != domain.DisputeStatusResolvedCustomer || resolved.ResolutionEntryID == "" {
+		t.Fatalf("expected the retry to finish the resolution, got %#v %v", resolved, err)
+	}
+	if kesBalance(st.ledger, alice, domain.AccountTypeWallet) != 9900 || kesBalance(st.ledger, bob, domain.AccountTypeWallet) != 0 || kesBalance(st.ledger, domain.SystemOwnerSuspense, domain.AccountTypeSystem) != 0 {
+		t.Fatalf("expected exactly one payout out of suspense")
+	}
+}
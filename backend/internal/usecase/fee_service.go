@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+)
+
+type PreviewFeeInput struct {
+	Type      string
+	Currency  string
+	Amount    int64
+	PromoCode string
+}
+
+// FeePreview is the charge shown to the user before they confirm. Total is
+// what leaves their wallet: the amount plus the fee, except for conversions
+// where the fee is deducted from the amount sold.
+type FeePreview struct {
+	Type     fees.TransactionType
+	Currency string
+	Amount   int64
+	Gross    int64
+	Waived   int64
+	Fee      int64
+	Total    int64
+	Promo    string
+}
+
+type FeeService struct {
+	schedule *fees.Schedule
+	now      func() time.Time
+}
+
+func NewFeeService(schedule *fees.Schedule) *FeeService {
+	return &FeeService{schedule: schedule, now: func() time.Time { return time.Now().UTC() }}
+}
+
+func (s *FeeService) Preview(ctx context.Context, in PreviewFeeInput) (*FeePreview, domain.FieldErrors, error) {
+	fields := domain.FieldErrors{}
+	txType := fees.TransactionType(strings.ToLower(strings.TrimSpace(in.Type)))
+	switch txType {
+	case fees.TypeTransfer, fees.TypeWithdrawal, fees.TypeConversion:
+	default:
+		fields["type"] = "must be transfer, withdrawal or conversion"
+	}
+	currency := domain.NormalizeCurrency(in.Currency)
+	if _, ok := domain.LookupCurrency(currency); !ok {
+		fields["currency"] = "unsupported currency"
+	}
+	if problem := domain.AmountProblem(in.Amount); problem != "" {
+		fields["amount"] = problem
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	q := s.quote(txType, currency, in.Amount, in.PromoCode)
+	total := in.Amount
+	if txType != fees.TypeConversion {
+		withFees, fields, err := withFee(in.Amount, q)
+		if err != nil {
+			return nil, fields, err
+		}
+		total = withFees
+	}
+	return &FeePreview{Type: txType, Currency: currency, Amount: in.Amount, Gross: q.Gross, Waived: q.Waived, Fee: q.Fee, Total: total, Promo: q.Promo}, nil, nil
+}
+
+// quote is the single place transaction flows price a fee, so the preview
+// and the posted charge can never disagree.
+func (s *FeeService) quote(txType fees.TransactionType, currency string, amount int64, promoCode string) fees.Quote {
+	return s.schedule.Evaluate(txType, currency, amount, promoCode, s.now())
+}
@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/fx"
+	"akiba/backend/internal/repository"
+)
+
+const bpsScale = 10000
+
+// FXConfig prices quotes. SpreadBps is taken off the mid rate; the fee
+// comes from the fee schedule's conversion rule for the sell currency.
+type FXConfig struct {
+	QuoteTTL  time.Duration
+	SpreadBps int
+}
+
+type QuoteInput struct {
+	UserID       string
+	SellCurrency string
+	BuyCurrency  string
+	SellAmount   int64
+	PromoCode    string
+}
+type ConvertInput struct {
+	UserID  string
+	QuoteID string
+}
+type ConversionResult struct {
+	Quote *domain.FXQuote
+	Entry *domain.JournalEntry
+}
+
+type FXService struct {
+	users  repository.UserRepository
+	quotes repository.FXQuoteRepository
+	ledger repository.LedgerRepository
+	rates  fx.RateProvider
+	fees   *FeeService
+	cfg    FXConfig
+	now    func() time.Time
+}
+
+func NewFXService(users repository.UserRepository, quotes repository.FXQuoteRepository, ledger repository.LedgerRepository, rates fx.RateProvider, feeService *FeeService, cfg FXConfig) *FXService {
+	return &FXService{users: users, quotes: quotes, ledger: ledger, rates: rates, fees: feeService, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Quote prices selling SellAmount minor units and locks the result for
+// cfg.QuoteTTL. The fee is deducted from SellAmount before conversion and the
+// bought amount is rounded down.
+func (s *FXService) Quote(ctx context.Context, in QuoteInput) (*domain.FXQuote, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.UserID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	fields := domain.FieldErrors{}
+	sellCode := domain.NormalizeCurrency(in.SellCurrency)
+	buyCode := domain.NormalizeCurrency(in.BuyCurrency)
+	sell, okSell := domain.LookupCurrency(sellCode)
+	buy, okBuy := domain.LookupCurrency(buyCode)
+	if !okSell {
+		fields["sellCurrency"] = "unsupported currency"
+	}
+	if !okBuy {
+		fields["buyCurrency"] = "unsupported currency"
+	}
+	if okSell && okBuy && sellCode == buyCode {
+		fields["buyCurrency"] = "must differ from sellCurrency"
+	}
+	if problem := domain.AmountProblem(in.SellAmount); problem != "" {
+		fields["sellAmount"] = problem
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	mid, err := s.rates.MidRate(ctx, sellCode, buyCode)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnsupportedPair) {
+			return nil, domain.FieldErrors{"buyCurrency": "no rate available for this pair"}, domain.ErrInvalidInput
+		}
+		return nil, nil, err
+	}
+	fee := s.fees.quote(fees.TypeConversion, sellCode, in.SellAmount, in.PromoCode).Fee
+	net := in.SellAmount - fee
+	rate := new(big.Rat).Mul(mid, big.NewRat(int64(bpsScale-s.cfg.SpreadBps), bpsScale))
+	// net is in sell minor units; rescale to buy minor units before flooring.
+	bought := new(big.Rat).Mul(new(big.Rat).SetInt64(net), rate)
+	bought.Mul(bought, big.NewRat(pow10(buy.MinorUnits), pow10(sell.MinorUnits)))
+	buyAmount := new(big.Int).Quo(bought.Num(), bought.Denom())
+	if net <= 0 || buyAmount.Sign() <= 0 || !buyAmount.IsInt64() {
+		return nil, domain.FieldErrors{"sellAmount": "is too small to convert"}, domain.ErrInvalidInput
+	}
+	now := s.now()
+	quote := &domain.FXQuote{
+		UserID:       in.UserID,
+		SellCurrency: sellCode,
+		BuyCurrency:  buyCode,
+		SellAmount:   in.SellAmount,
+		BuyAmount:    buyAmount.Int64(),
+		Fee:          fee,
+		MidRate:      mid.FloatString(8),
+		Rate:         rate.FloatString(8),
+		SpreadBps:    s.cfg.SpreadBps,
+		Status:       domain.FXQuoteStatusOpen,
+		ExpiresAt:    now.Add(s.cfg.QuoteTTL),
+		CreatedAt:    now,
+	}
+	if err := s.quotes.Create(ctx, quote); err != nil {
+		return nil, nil, err
+	}
+	return quote, nil, nil
+}
+
+// Convert executes an open quote as one journal entry: the user's sell
+// wallet pays the FX position (and revenue for the fee) in the sell
+// currency, and the FX position pays the user's buy wallet in the buy
+// currency. The quote ID is the entry reference, so a quote executes once.
+func (s *FXService) Convert(ctx context.Context, in ConvertInput) (*ConversionResult, error) {
+	if strings.TrimSpace(in.UserID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	if err := requireCanSend(ctx, s.users, in.UserID); err != nil {
+		return nil, err
+	}
+	quote, err := s.quotes.GetByID(ctx, in.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote.UserID != in.UserID {
+		return nil, domain.ErrQuoteNotFound
+	}
+	if quote.Status != domain.FXQuoteStatusOpen {
+		return nil, domain.ErrQuoteExecuted
+	}
+	now := s.now()
+	if quote.Expired(now) {
+		return nil, domain.ErrQuoteExpired
+	}
+
+	sellWallet, err := s.ledger.GetOrCreateAccount(ctx, quote.UserID, domain.AccountTypeWallet, quote.SellCurrency)
+	if err != nil {
+		return nil, err
+	}
+	buyWallet, err := s.ledger.GetOrCreateAccount(ctx, quote.UserID, domain.AccountTypeWallet, quote.BuyCurrency)
+	if err != nil {
+		return nil, err
+	}
+	sellPosition, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerFXPosition, domain.AccountTypeSystem, quote.SellCurrency)
+	if err != nil {
+		return nil, err
+	}
+	buyPosition, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerFXPosition, domain.AccountTypeSystem, quote.BuyCurrency)
+	if err != nil {
+		return nil, err
+	}
+	postings := []domain.Posting{
+		{AccountID: sellWallet.ID, Amount: -quote.SellAmount, Currency: quote.SellCurrency},
+		{AccountID: sellPosition.ID, Amount: quote.SellAmount - quote.Fee, Currency: quote.SellCurrency},
+		{AccountID: buyPosition.ID, Amount: -quote.BuyAmount, Currency: quote.BuyCurrency},
+		{AccountID: buyWallet.ID, Amount: quote.BuyAmount, Currency: quote.BuyCurrency},
+	}
+	if quote.Fee > 0 {
+		revenue, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerRevenue, domain.AccountTypeSystem, quote.SellCurrency)
+		if err != nil {
+			return nil, err
+		}
+		postings = append(postings, domain.Posting{AccountID: revenue.ID, Amount: quote.Fee, Currency: quote.SellCurrency})
+	}
+	entry := &domain.JournalEntry{
+		Kind:      domain.EntryKindFXConversion,
+		Reference: "fx:" + quote.ID,
+		Postings:  postings,
+		Metadata:  map[string]string{"quoteId": quote.ID, "userId": quote.UserID, "rate": quote.Rate},
+		CreatedAt: now,
+	}
+	if err := s.ledger.Post(ctx, entry); err != nil {
+		if errors.Is(err, domain.ErrDuplicateEntry) {
+			return nil, domain.ErrQuoteExecuted
+		}
+		return nil, err
+	}
+	if err := s.quotes.MarkExecuted(ctx, quote.ID, entry.ID, now); err != nil && !errors.Is(err, domain.ErrQuoteExecuted) {
+		return nil, err
+	}
+	quote.Status, quote.EntryID, quote.ExecutedAt = domain.FXQuoteStatusExecuted, entry.ID, now
+	return &ConversionResult{Quote: quote, Entry: entry}, nil
+}
+
+func pow10(n int) int64 {
+	out := int64(1)
+	for i := 0; i < n; i++ {
+		out *= 10
+	}
+	return out
+}
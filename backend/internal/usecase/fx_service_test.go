@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/fx"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+// testConversionFees charges 25bps on KES conversions.
+var testConversionFees = &fees.Schedule{Rules: []fees.Rule{{Type: fees.TypeConversion, Currency: "KES", PercentBps: 25}}}
+
+func TestQuoteAppliesFeeAndSpread(t *testing.T) {
+	st := newTestStore()
+	rates, err := fx.NewStaticProvider(map[string]string{"KES": "130", "UGX": "3900"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewFXService(st.users, memory.NewFXQuoteRepository(st.Store), st.ledger, rates, NewFeeService(testConversionFees), FXConfig{QuoteTTL: 30 * time.Second, SpreadBps: 150})
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	q, fields, err := svc.Quote(context.Background(), QuoteInput{UserID: alice, SellCurrency: "kes", BuyCurrency: "usd", SellAmount: 13000})
+	if err != nil {
+		t.Fatalf("quote: err=%v fields=%#v", err, fields)
+	}
+	// fee = ceil(13000 * 25bps) = 33; (13000-33) * (1/130 * 0.985) = 98.25 cents, floored.
+	if q.Fee != 33 || q.BuyAmount != 98 || q.SellCurrency != "KES" || q.BuyCurrency != "USD" {
+		t.Fatalf("unexpected quote: %#v", q)
+	}
+}
+
+func TestQuoteRejectsSameCurrency(t *testing.T) {
+	st := newTestStore()
+	rates, err := fx.NewStaticProvider(map[string]string{"KES": "130", "UGX": "3900"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewFXService(st.users, memory.NewFXQuoteRepository(st.Store), st.ledger, rates, NewFeeService(testConversionFees), FXConfig{QuoteTTL: 30 * time.Second, SpreadBps: 150})
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	_, fields, err := svc.Quote(context.Background(), QuoteInput{UserID: alice, SellCurrency: "KES", BuyCurrency: "KES", SellAmount: 100})
+	if !errors.Is(err, domain.ErrInvalidInput) || fields["buyCurrency"] == "" {
+		t.Fatalf("expected buyCurrency validation, got err=%v fields=%#v", err, fields)
+	}
+}
+
+func TestConvertPostsBalancedEntryOnce(t *testing.T) {
+	st := newTestStore()
+	rates, err := fx.NewStaticProvider(map[string]string{"KES": "130", "UGX": "3900"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewFXService(st.users, memory.NewFXQuoteRepository(st.Store), st.ledger, rates, NewFeeService(testConversionFees), FXConfig{QuoteTTL: 30 * time.Second, SpreadBps: 150})
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	fund(t, st.ledger, alice, "KES", 20000)
+	q, _, err := svc.Quote(context.Background(), QuoteInput{UserID: alice, SellCurrency: "KES", BuyCurrency: "UGX", SellAmount: 10000})
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	if _, err := svc.Convert(context.Background(), ConvertInput{UserID: bob, QuoteID: q.ID}); !errors.Is(err, domain.ErrQuoteNotFound) {
+		t.Fatalf("expected other users to be refused, got %v", err)
+	}
+	res, err := svc.Convert(context.Background(), ConvertInput{UserID: alice, QuoteID: q.ID})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(res.Entry.Postings) != 5 {
+		t.Fatalf("expected four conversion legs plus fee leg, got %d", len(res.Entry.Postings))
+	}
+	kes, _ := st.ledger.GetOrCreateAccount(context.Background(), alice, domain.AccountTypeWallet, "KES")
+	ugx, _ := st.ledger.GetOrCreateAccount(context.Background(), alice, domain.AccountTypeWallet, "UGX")
+	if kes.Balance != 10000 || ugx.Balance != q.BuyAmount {
+		t.Fatalf("unexpected balances: kes=%d ugx=%d", kes.Balance, ugx.Balance)
+	}
+	if _, err := svc.Convert(context.Background(), ConvertInput{UserID: alice, QuoteID: q.ID}); !errors.Is(err, domain.ErrQuoteExecuted) {
+		t.Fatalf("expected quote executed, got %v", err)
+	}
+}
+
+func TestConvertRejectsExpiredQuote(t *testing.T) {
+	st := newTestStore()
+	rates, err := fx.NewStaticProvider(map[string]string{"KES": "130", "UGX": "3900"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewFXService(st.users, memory.NewFXQuoteRepository(st.Store), st.ledger, rates, NewFeeService(testConversionFees), FXConfig{QuoteTTL: 30 * time.Second, SpreadBps: 150})
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	fund(t, st.ledger, alice, "KES", 20000)
+	q, _, err := svc.Quote(context.Background(), QuoteInput{UserID: alice, SellCurrency: "KES", BuyCurrency: "USD", SellAmount: 10000})
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	svc.now = func() time.Time { return q.ExpiresAt }
+	if _, err := svc.Convert(context.Background(), ConvertInput{UserID: alice, QuoteID: q.ID}); !errors.Is(err, domain.ErrQuoteExpired) {
+		t.Fatalf("expected quote expired, got %v", err)
+	}
+}
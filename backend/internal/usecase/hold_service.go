@@ -0,0 +1,304 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const holdExpiryBatch = 100
+
+type AuthorizePaymentInput struct {
+	PayerID    string
+	MerchantID string
+	Amount     int64
+	PIN        string
+}
+type CaptureHoldInput struct {
+	ActorID string
+	HoldID  string
+	// Amount is the amount to capture; zero captures the full hold.
+	Amount int64
+}
+
+// HoldService runs two-phase payments: a hold reserves funds now and a
+// capture posts them later. Merchant authorizations are captured or voided
+// by the merchant owner; withdrawal holds are submitted to the payout rail
+// when placed, so they never expire and only support or admin may capture or
+// void them once the rail reports the outcome. Merchant authorizations need
+// the payer's PIN once one is set; pins may be nil.
+type HoldService struct {
+	users     repository.UserRepository
+	merchants repository.MerchantRepository
+	ledger    repository.LedgerRepository
+	pins      *PINService
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+func NewHoldService(users repository.UserRepository, merchants repository.MerchantRepository, ledger repository.LedgerRepository, ttl time.Duration, pins *PINService) *HoldService {
+	return &HoldService{users: users, merchants: merchants, ledger: ledger, pins: pins, ttl: ttl, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// AuthorizeMerchantPayment reserves Amount of the payer's wallet for a later
+// capture by the merchant, such as a fuel pump or hotel pre-authorization.
+func (s *HoldService) AuthorizeMerchantPayment(ctx context.Context, in AuthorizePaymentInput) (*domain.Hold, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.PayerID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	if problem := domain.AmountProblem(in.Amount); problem != "" {
+		return nil, domain.FieldErrors{"amount": problem}, domain.ErrInvalidInput
+	}
+	if err := requireCanSend(ctx, s.users, in.PayerID); err != nil {
+		return nil, nil, err
+	}
+	merchant, err := s.merchants.GetByID(ctx, in.MerchantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	accepting, err := merchantCanReceive(ctx, s.users, merchant)
+	if err != nil {
+		return nil, nil, err
+	}
+	if merchant.Status != domain.MerchantStatusActive || !accepting {
+		return nil, nil, domain.ErrMerchantNotFound
+	}
+	if merchant.OwnerID == in.PayerID {
+		return nil, domain.FieldErrors{"merchantId": "cannot pay your own merchant"}, domain.ErrInvalidInput
+	}
+	if fields, err := s.pins.verifyPayment(ctx, in.PayerID, in.PIN); err != nil {
+		return nil, fields, err
+	}
+	payer, err := s.ledger.GetOrCreateAccount(ctx, in.PayerID, domain.AccountTypeWallet, merchant.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	payee, err := s.ledger.GetOrCreateAccount(ctx, merchant.ID, domain.AccountTypeMerchant, merchant.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	hold := &domain.Hold{
+		AccountID:      payer.ID,
+		OwnerID:        in.PayerID,
+		Currency:       merchant.Currency,
+		Amount:         in.Amount,
+		Kind:           domain.HoldKindMerchantPayment,
+		PayeeAccountID: payee.ID,
+		Metadata:       map[string]string{"merchantId": merchant.ID, "payerId": in.PayerID},
+	}
+	if err := s.place(ctx, hold, nil); err != nil {
+		return nil, nil, err
+	}
+	return hold, nil, nil
+}
+
+// place stamps hold as active and reserves it, within limit when one is
+// set. Merchant holds get the configured expiry; withdrawal holds have none,
+// because releasing one after the payout went out would hand the customer
+// the money twice.
+func (s *HoldService) place(ctx context.Context, hold *domain.Hold, limit *domain.PayeeCap) error {
+	if hold.Amount <= 0 {
+		return domain.ErrInvalidInput
+	}
+	now := s.now()
+	hold.Status, hold.CreatedAt, hold.UpdatedAt = domain.HoldStatusActive, now, now
+	if hold.Kind != domain.HoldKindWithdrawal {
+		hold.ExpiresAt = now.Add(s.ttl)
+	}
+	if limit != nil {
+		return s.ledger.PlaceHoldWithinCap(ctx, hold, *limit)
+	}
+	return s.ledger.PlaceHold(ctx, hold)
+}
+
+func (s *HoldService) Get(ctx context.Context, actorID, holdID string) (*domain.Hold, error) {
+	hold, err := s.ledger.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.OwnerID == actorID {
+		return hold, nil
+	}
+	if ok, err := s.isMerchantOwner(ctx, actorID, hold); err != nil || ok {
+		return hold, err
+	}
+	if err := requirePermission(ctx, s.users, actorID, domain.PermLedgerRead); err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			return nil, domain.ErrHoldNotFound
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// Capture posts up to the held amount and releases the rest of the hold.
+// The capture is keyed on the hold so it can only ever post once.
+func (s *HoldService) Capture(ctx context.Context, in CaptureHoldInput) (*domain.Hold, *domain.JournalEntry, domain.FieldErrors, error) {
+	hold, err := s.ledger.GetHold(ctx, in.HoldID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := s.authorizeSettlement(ctx, in.ActorID, hold, true); err != nil {
+		return nil, nil, nil, err
+	}
+	if hold.Status != domain.HoldStatusActive || hold.Expired(s.now()) {
+		return nil, nil, nil, domain.ErrHoldNotActive
+	}
+	now := s.now()
+	entry := &domain.JournalEntry{Reference: "hold:" + hold.ID, CreatedAt: now}
+	captured := in.Amount
+	switch hold.Kind {
+	case domain.HoldKindMerchantPayment:
+		if captured == 0 {
+			captured = hold.Amount
+		}
+		if captured < 0 || captured > hold.Amount {
+			return nil, nil, domain.FieldErrors{"amount": "must be between 1 and the held amount"}, domain.ErrInvalidInput
+		}
+		// A merchant whose owner is being closed takes no more funds; the
+		// hold lapses back to the payer.
+		merchant, err := s.merchants.GetByID(ctx, hold.Metadata["merchantId"])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok, err := merchantCanReceive(ctx, s.users, merchant); err != nil || !ok {
+			if err == nil {
+				err = domain.ErrAccountRestricted
+			}
+			return nil, nil, nil, err
+		}
+		entry.Kind = domain.EntryKindMerchantPayment
+		entry.Postings = []domain.Posting{
+			{AccountID: hold.AccountID, Amount: -captured, Currency: hold.Currency},
+			{AccountID: hold.PayeeAccountID, Amount: captured, Currency: hold.Currency},
+		}
+		entry.Metadata = map[string]string{"merchantId": hold.Metadata["merchantId"], "payerId": hold.OwnerID, "holdId": hold.ID}
+	case domain.HoldKindWithdrawal:
+		// The fee was priced into the hold when it was placed.
+		if captured != 0 && captured != hold.Amount {
+			return nil, nil, domain.FieldErrors{"amount": "withdrawals are captured in full"}, domain.ErrInvalidInput
+		}
+		captured = hold.Amount
+		fee, _ := strconv.ParseInt(hold.Metadata["fee"], 10, 64)
+		clearing, err := s.ledger.GetOrCreateAccount(ctx, domain.SystemOwnerPayoutClearing, domain.AccountTypeSystem, hold.Currency)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		postings := []domain.Posting{
+			{AccountID: hold.AccountID, Amount: -hold.Amount, Currency: hold.Currency},
+			{AccountID: clearing.ID, Amount: hold.Amount - fee, Currency: hold.Currency},
+		}
+		if entry.Postings, err = withFeeLeg(ctx, s.ledger, postings, hold.Currency, fee); err != nil {
+			return nil, nil, nil, err
+		}
+		entry.Kind = domain.EntryKindWithdrawal
+		entry.Metadata = map[string]string{"holdId": hold.ID}
+		for k, v := range hold.Metadata {
+			entry.Metadata[k] = v
+		}
+	default:
+		return nil, nil, nil, domain.ErrHoldNotActive
+	}
+	if err := s.ledger.CaptureHold(ctx, hold.ID, captured, entry); err != nil {
+		return nil, nil, nil, err
+	}
+	hold.Status, hold.CapturedAmount, hold.EntryID, hold.UpdatedAt = domain.HoldStatusCaptured, captured, entry.ID, now
+	return hold, entry, nil, nil
+}
+
+// Void releases a hold without posting anything.
+func (s *HoldService) Void(ctx context.Context, actorID, holdID string) (*domain.Hold, error) {
+	hold, err := s.ledger.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeSettlement(ctx, actorID, hold, false); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := s.ledger.ReleaseHold(ctx, hold.ID, domain.HoldStatusVoided, now); err != nil {
+		return nil, err
+	}
+	hold.Status, hold.UpdatedAt = domain.HoldStatusVoided, now
+	return hold, nil
+}
+
+// ExpireDue releases every active hold past its expiry and returns how many
+// it released. It is safe to run from several instances at once.
+func (s *HoldService) ExpireDue(ctx context.Context) (int, error) {
+	released := 0
+	for {
+		now := s.now()
+		holds, err := s.ledger.ListExpiredHolds(ctx, now, holdExpiryBatch)
+		if err != nil {
+			return released, err
+		}
+		for _, h := range holds {
+			err := s.ledger.ReleaseHold(ctx, h.ID, domain.HoldStatusExpired, now)
+			if errors.Is(err, domain.ErrHoldNotActive) {
+				continue
+			}
+			if err != nil {
+				return released, err
+			}
+			released++
+		}
+		if len(holds) < holdExpiryBatch {
+			return released, nil
+		}
+	}
+}
+
+// authorizeSettlement decides who may capture or void. Merchant holds are
+// the merchant owner's to settle (operators may also void them); withdrawal
+// holds are settled only by operators, since the customer cannot know
+// whether the rail has already paid out.
+func (s *HoldService) authorizeSettlement(ctx context.Context, actorID string, hold *domain.Hold, capture bool) error {
+	if strings.TrimSpace(actorID) == "" {
+		return domain.ErrUnauthorized
+	}
+	switch hold.Kind {
+	case domain.HoldKindMerchantPayment:
+		if ok, err := s.isMerchantOwner(ctx, actorID, hold); err != nil || ok {
+			return err
+		}
+		if capture {
+			return denyHold(actorID, hold)
+		}
+	}
+	if err := requirePermission(ctx, s.users, actorID, domain.PermPaymentsOperate); err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			return denyHold(actorID, hold)
+		}
+		return err
+	}
+	return nil
+}
+
+// denyHold tells the hold's owner they may not act on it and hides the hold
+// from everyone else.
+func denyHold(actorID string, hold *domain.Hold) error {
+	if hold.OwnerID == actorID {
+		return domain.ErrForbidden
+	}
+	return domain.ErrHoldNotFound
+}
+
+func (s *HoldService) isMerchantOwner(ctx context.Context, actorID string, hold *domain.Hold) (bool, error) {
+	if hold.Kind != domain.HoldKindMerchantPayment {
+		return false, nil
+	}
+	merchant, err := s.merchants.GetByID(ctx, hold.Metadata["merchantId"])
+	if errors.Is(err, domain.ErrMerchantNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return merchant.OwnerID == actorID, nil
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+)
+
+func TestMerchantAuthorizationPartialCapture(t *testing.T) {
+	st := newTestStore()
+	svc := NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil)
+	payer, owner := addUser(t, st.users, domain.User{UsernameLower: "payer"}), addUser(t, st.users, domain.User{UsernameLower: "owner"})
+	merchant := addMerchant(t, st.merchants, owner)
+	fund(t, st.ledger, payer, "KES", 10000)
+	hold, _, err := svc.AuthorizeMerchantPayment(context.Background(), AuthorizePaymentInput{PayerID: payer, MerchantID: merchant.ID, Amount: 8000})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if _, _, err := svc.AuthorizeMerchantPayment(context.Background(), AuthorizePaymentInput{PayerID: payer, MerchantID: merchant.ID, Amount: 2001}); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("expected second hold to exceed available, got %v", err)
+	}
+	if _, _, _, err := svc.Capture(context.Background(), CaptureHoldInput{ActorID: payer, HoldID: hold.ID}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected payer capture to be refused, got %v", err)
+	}
+	captured, entry, _, err := svc.Capture(context.Background(), CaptureHoldInput{ActorID: owner, HoldID: hold.ID, Amount: 6500})
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if captured.Status != domain.HoldStatusCaptured || entry.Kind != domain.EntryKindMerchantPayment {
+		t.Fatalf("unexpected capture: hold=%#v entry=%#v", captured, entry)
+	}
+	wallet, _ := st.ledger.GetOrCreateAccount(context.Background(), payer, domain.AccountTypeWallet, "KES")
+	payee, _ := st.ledger.GetOrCreateAccount(context.Background(), merchant.ID, domain.AccountTypeMerchant, "KES")
+	if wallet.Balance != 3500 || wallet.Available() != 3500 || payee.Balance != 6500 {
+		t.Fatalf("unexpected balances: payer=%d available=%d merchant=%d", wallet.Balance, wallet.Available(), payee.Balance)
+	}
+	if _, err := svc.Void(context.Background(), owner, hold.ID); !errors.Is(err, domain.ErrHoldNotActive) {
+		t.Fatalf("expected captured hold to be final, got %v", err)
+	}
+}
+
+func TestExpiredHoldsAreReleased(t *testing.T) {
+	st := newTestStore()
+	svc := NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil)
+	payer, owner := addUser(t, st.users, domain.User{UsernameLower: "payer"}), addUser(t, st.users, domain.User{UsernameLower: "owner"})
+	merchant := addMerchant(t, st.merchants, owner)
+	fund(t, st.ledger, payer, "KES", 5000)
+	hold, _, err := svc.AuthorizeMerchantPayment(context.Background(), AuthorizePaymentInput{PayerID: payer, MerchantID: merchant.ID, Amount: 5000})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	svc.now = func() time.Time { return hold.ExpiresAt }
+	if _, _, _, err := svc.Capture(context.Background(), CaptureHoldInput{ActorID: owner, HoldID: hold.ID}); !errors.Is(err, domain.ErrHoldNotActive) {
+		t.Fatalf("expected expired hold to refuse capture, got %v", err)
+	}
+	n, err := svc.ExpireDue(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("expire: n=%d err=%v", n, err)
+	}
+	wallet, _ := st.ledger.GetOrCreateAccount(context.Background(), payer, domain.AccountTypeWallet, "KES")
+	if wallet.Available() != 5000 {
+		t.Fatalf("expected funds available again, got %d", wallet.Available())
+	}
+}
+
+func TestPlaceRefusesNonPositiveHolds(t *testing.T) {
+	st := newTestStore()
+	svc := NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil)
+	payer := addUser(t, st.users, domain.User{UsernameLower: "payer"})
+	fund(t, st.ledger, payer, "KES", 1000)
+	wallet, _ := st.ledger.GetOrCreateAccount(context.Background(), payer, domain.AccountTypeWallet, "KES")
+	for _, amount := range []int64{0, -1000} {
+		if err := svc.place(context.Background(), &domain.Hold{AccountID: wallet.ID, OwnerID: payer, Currency: "KES", Amount: amount}, nil); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Fatalf("hold of %d: expected ErrInvalidInput, got %v", amount, err)
+		}
+	}
+	wallet, _ = st.ledger.GetAccount(context.Background(), wallet.ID)
+	if wallet.Held != 0 || wallet.Available() != 1000 {
+		t.Fatalf("expected nothing held, got held=%d available=%d", wallet.Held, wallet.Available())
+	}
+}
@@ -0,0 +1,348 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/emvqr"
+	"akiba/backend/internal/repository"
+)
+
+// QRGloballyUniqueID identifies Akiba in the merchant account template of
+// the QR codes it issues. Codes carrying any other GUID are rejected.
+const QRGloballyUniqueID = "ke.akiba.pay"
+
+const maxQRReferenceLength = 25
+
+type CreateMerchantInput struct {
+	OwnerID      string
+	Name         string
+	City         string
+	CategoryCode string
+	CountryCode  string
+	Currency     string
+}
+type GenerateQRInput struct {
+	OwnerID    string
+	MerchantID string
+	Amount     int64
+	Reference  string
+}
+type QRCode struct {
+	Payload   string
+	Dynamic   bool
+	Merchant  *domain.Merchant
+	Amount    int64
+	Reference string
+}
+type PayQRInput struct {
+	PayerID string
+	Payload string
+	Amount  int64
+	PIN     string
+}
+type QRPaymentResult struct {
+	Entry    *domain.JournalEntry
+	Merchant *domain.Merchant
+	Amount   int64
+	Currency string
+}
+type SettlementReportInput struct {
+	OwnerID    string
+	MerchantID string
+	From       time.Time
+	To         time.Time
+}
+type SettlementDay struct {
+	Date     string
+	Count    int
+	Amount   int64
+	Refunded int64
+}
+type SettlementPayment struct {
+	EntryID   string
+	Amount    int64
+	Reference string
+	// ReversalOf is the payment a refund or reversal returns.
+	ReversalOf string
+	CreatedAt  time.Time
+}
+
+// SettlementReport nets the refunds and reversals posted in the window
+// against its payments, whenever the payments they return were made.
+type SettlementReport struct {
+	Merchant       *domain.Merchant
+	From           time.Time
+	To             time.Time
+	PaymentCount   int
+	GrossAmount    int64
+	RefundCount    int
+	RefundedAmount int64
+	NetAmount      int64
+	Balance        int64
+	Days           []SettlementDay
+	Payments       []SettlementPayment
+	Refunds        []SettlementPayment
+}
+
+// MerchantService runs merchant accounts and their QR payments. Paying a QR
+// code needs the payer's PIN once one is set; pins may be nil.
+type MerchantService struct {
+	users     repository.UserRepository
+	merchants repository.MerchantRepository
+	ledger    repository.LedgerRepository
+	pins      *PINService
+}
+
+func NewMerchantService(users repository.UserRepository, merchants repository.MerchantRepository, ledger repository.LedgerRepository, pins *PINService) *MerchantService {
+	return &MerchantService{users: users, merchants: merchants, ledger: ledger, pins: pins}
+}
+
+func (s *MerchantService) Create(ctx context.Context, in CreateMerchantInput) (*domain.Merchant, domain.FieldErrors, error) {
+	fields := domain.FieldErrors{}
+	name := domain.NormalizeMerchantName(in.Name)
+	city := domain.NormalizeMerchantName(in.City)
+	mcc := strings.TrimSpace(in.CategoryCode)
+	country := domain.NormalizeCountryCode(in.CountryCode)
+	currency := domain.NormalizeCurrency(in.Currency)
+	if name == "" || utf8.RuneCountInString(name) > 25 {
+		fields["name"] = "must be 1-25 characters"
+	}
+	if city == "" || utf8.RuneCountInString(city) > 15 {
+		fields["city"] = "must be 1-15 characters"
+	}
+	if !domain.ValidateMerchantCategory(mcc) {
+		fields["categoryCode"] = "must be a 4-digit ISO 18245 merchant category code"
+	}
+	if !domain.ValidateCountryCode(country) {
+		fields["countryCode"] = "must be an ISO 3166-1 alpha-2 code"
+	}
+	if _, ok := domain.LookupCurrency(currency); !ok {
+		fields["currency"] = "unsupported currency"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if strings.TrimSpace(in.OwnerID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	now := time.Now().UTC()
+	merchant := &domain.Merchant{OwnerID: in.OwnerID, Name: name, City: city, CategoryCode: mcc, CountryCode: country, Currency: currency, Status: domain.MerchantStatusActive, CreatedAt: now, UpdatedAt: now}
+	if err := s.merchants.Create(ctx, merchant); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.ledger.GetOrCreateAccount(ctx, merchant.ID, domain.AccountTypeMerchant, merchant.Currency); err != nil {
+		return nil, nil, err
+	}
+	return merchant, nil, nil
+}
+
+func (s *MerchantService) ListMine(ctx context.Context, ownerID string) ([]*domain.Merchant, error) {
+	if strings.TrimSpace(ownerID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	return s.merchants.ListByOwner(ctx, ownerID)
+}
+
+// GenerateQR issues a static code when Amount is zero and a single-use
+// dynamic code otherwise. A dynamic code's reference is its single-use key,
+// so it is always random: one the merchant chose could be guessed and paid
+// with a forged payload before the real code is scanned.
+func (s *MerchantService) GenerateQR(ctx context.Context, in GenerateQRInput) (*QRCode, domain.FieldErrors, error) {
+	merchant, err := s.ownedMerchant(ctx, in.OwnerID, in.MerchantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	reference := strings.TrimSpace(in.Reference)
+	if in.Amount != 0 {
+		if problem := domain.AmountProblem(in.Amount); problem != "" {
+			fields["amount"] = problem
+		}
+	}
+	if len(reference) > maxQRReferenceLength {
+		fields["reference"] = "must be at most 25 characters"
+	}
+	if in.Amount > 0 && reference != "" {
+		fields["reference"] = "is generated for dynamic codes"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	currency, _ := domain.LookupCurrency(merchant.Currency)
+	p := emvqr.Payload{GUID: QRGloballyUniqueID, MerchantID: merchant.ID, Category: merchant.CategoryCode, Currency: currency.Numeric, CountryCode: merchant.CountryCode, MerchantName: merchant.Name, MerchantCity: merchant.City, Reference: reference}
+	if in.Amount > 0 {
+		if p.Reference, err = newQRReference(); err != nil {
+			return nil, nil, err
+		}
+		p.Dynamic = true
+		p.Amount = currency.FormatAmount(in.Amount)
+	}
+	payload, err := emvqr.Encode(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &QRCode{Payload: payload, Dynamic: p.Dynamic, Merchant: merchant, Amount: in.Amount, Reference: p.Reference}, nil, nil
+}
+
+// PayQR decodes a scanned payload and moves funds from the payer's wallet to
+// the merchant account. Dynamic codes carry their own amount and can be paid
+// once; static codes take the amount from the payer.
+func (s *MerchantService) PayQR(ctx context.Context, in PayQRInput) (*QRPaymentResult, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.PayerID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	if err := requireCanSend(ctx, s.users, in.PayerID); err != nil {
+		return nil, nil, err
+	}
+	p, err := emvqr.Decode(in.Payload)
+	if err != nil || p.GUID != QRGloballyUniqueID {
+		return nil, domain.FieldErrors{"payload": "not a valid Akiba merchant QR code"}, domain.ErrInvalidQRPayload
+	}
+	merchant, err := s.merchants.GetByID(ctx, p.MerchantID)
+	if err != nil {
+		if errors.Is(err, domain.ErrMerchantNotFound) {
+			return nil, domain.FieldErrors{"payload": "merchant not found"}, domain.ErrInvalidQRPayload
+		}
+		return nil, nil, err
+	}
+	accepting, err := merchantCanReceive(ctx, s.users, merchant)
+	if err != nil {
+		return nil, nil, err
+	}
+	if merchant.Status != domain.MerchantStatusActive || !accepting {
+		return nil, domain.FieldErrors{"payload": "merchant is not accepting payments"}, domain.ErrInvalidQRPayload
+	}
+	currency, _ := domain.LookupCurrency(merchant.Currency)
+	if p.Currency != currency.Numeric {
+		return nil, domain.FieldErrors{"payload": "currency does not match merchant"}, domain.ErrInvalidQRPayload
+	}
+	amount := in.Amount
+	if p.Dynamic {
+		if p.Reference == "" {
+			return nil, domain.FieldErrors{"payload": "dynamic code without a reference"}, domain.ErrInvalidQRPayload
+		}
+		qrAmount, err := currency.ParseAmount(p.Amount)
+		if err != nil || domain.AmountProblem(qrAmount) != "" {
+			return nil, domain.FieldErrors{"payload": "invalid amount"}, domain.ErrInvalidQRPayload
+		}
+		if amount != 0 && amount != qrAmount {
+			return nil, domain.FieldErrors{"amount": "does not match the amount in the QR code"}, domain.ErrInvalidInput
+		}
+		amount = qrAmount
+	} else if problem := domain.AmountProblem(amount); problem != "" {
+		return nil, domain.FieldErrors{"amount": problem}, domain.ErrInvalidInput
+	}
+	if fields, err := s.pins.verifyPayment(ctx, in.PayerID, in.PIN); err != nil {
+		return nil, fields, err
+	}
+
+	payer, err := s.ledger.GetOrCreateAccount(ctx, in.PayerID, domain.AccountTypeWallet, merchant.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	payee, err := s.ledger.GetOrCreateAccount(ctx, merchant.ID, domain.AccountTypeMerchant, merchant.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := &domain.JournalEntry{
+		Kind: domain.EntryKindQRPayment,
+		Postings: []domain.Posting{
+			{AccountID: payer.ID, Amount: -amount, Currency: merchant.Currency},
+			{AccountID: payee.ID, Amount: amount, Currency: merchant.Currency},
+		},
+		Metadata:  map[string]string{"merchantId": merchant.ID, "payerId": in.PayerID, "reference": p.Reference},
+		CreatedAt: time.Now().UTC(),
+	}
+	if p.Dynamic {
+		entry.Reference = "qr:" + merchant.ID + ":" + p.Reference
+	}
+	if err := s.ledger.Post(ctx, entry); err != nil {
+		return nil, nil, err
+	}
+	return &QRPaymentResult{Entry: entry, Merchant: merchant, Amount: amount, Currency: merchant.Currency}, nil, nil
+}
+
+// SettlementReport summarises QR payments and captured authorizations
+// received in [From, To), less the refunds and reversals paid out of the
+// merchant account in the same window, with daily totals (UTC days) and
+// the merchant account's current balance.
+func (s *MerchantService) SettlementReport(ctx context.Context, in SettlementReportInput) (*SettlementReport, domain.FieldErrors, error) {
+	merchant, err := s.ownedMerchant(ctx, in.OwnerID, in.MerchantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if in.From.IsZero() || in.To.IsZero() || !in.From.Before(in.To) {
+		return nil, domain.FieldErrors{"from": "must be before to"}, domain.ErrInvalidInput
+	}
+	account, err := s.ledger.GetOrCreateAccount(ctx, merchant.ID, domain.AccountTypeMerchant, merchant.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := s.ledger.ListEntriesByAccount(ctx, account.ID, in.From.UTC(), in.To.UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &SettlementReport{Merchant: merchant, From: in.From.UTC(), To: in.To.UTC(), Balance: account.Balance, Days: []SettlementDay{}, Payments: []SettlementPayment{}, Refunds: []SettlementPayment{}}
+	for _, e := range entries {
+		line := SettlementPayment{EntryID: e.ID, Amount: e.AmountFor(account.ID), Reference: e.Metadata["reference"], ReversalOf: e.ReversalOf, CreatedAt: e.CreatedAt.UTC()}
+		switch e.Kind {
+		case domain.EntryKindQRPayment, domain.EntryKindMerchantPayment:
+			day := report.day(line.CreatedAt)
+			day.Count++
+			day.Amount += line.Amount
+			report.PaymentCount++
+			report.GrossAmount += line.Amount
+			report.Payments = append(report.Payments, line)
+		case domain.EntryKindRefund, domain.EntryKindReversal:
+			line.Amount = -line.Amount
+			report.day(line.CreatedAt).Refunded += line.Amount
+			report.RefundCount++
+			report.RefundedAmount += line.Amount
+			report.Refunds = append(report.Refunds, line)
+		}
+	}
+	report.NetAmount = report.GrossAmount - report.RefundedAmount
+	return report, nil, nil
+}
+
+// day returns the totals for the UTC day of at, starting a new day when at
+// is past the last one; entries come in time order.
+func (r *SettlementReport) day(at time.Time) *SettlementDay {
+	date := at.UTC().Format("2006-01-02")
+	if n := len(r.Days); n > 0 && r.Days[n-1].Date == date {
+		return &r.Days[n-1]
+	}
+	r.Days = append(r.Days, SettlementDay{Date: date})
+	return &r.Days[len(r.Days)-1]
+}
+
+// ownedMerchant hides merchants the caller does not own behind
+// ErrMerchantNotFound so IDs cannot be probed.
+func (s *MerchantService) ownedMerchant(ctx context.Context, ownerID, merchantID string) (*domain.Merchant, error) {
+	if strings.TrimSpace(ownerID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	merchant, err := s.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.OwnerID != ownerID {
+		return nil, domain.ErrMerchantNotFound
+	}
+	return merchant, nil
+}
+
+func newQRReference() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
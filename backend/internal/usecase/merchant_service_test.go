@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/emvqr"
+	"akiba/backend/internal/repository"
+)
+
+// addMerchant stores an active KES merchant owned by ownerID.
+func addMerchant(t *testing.T, merchants repository.MerchantRepository, ownerID string) *domain.Merchant {
+	t.Helper()
+	merchant := &domain.Merchant{OwnerID: ownerID, Name: "Duka", Currency: "KES", Status: domain.MerchantStatusActive, CreatedAt: time.Now().UTC()}
+	if err := merchants.Create(context.Background(), merchant); err != nil {
+		t.Fatalf("create merchant: %v", err)
+	}
+	return merchant
+}
+
+// fund credits ownerID's wallet from a house account so tests can spend.
+func fund(t *testing.T, ledger repository.LedgerRepository, ownerID, currency string, amount int64) {
+	t.Helper()
+	ctx := context.Background()
+	src, _ := ledger.GetOrCreateAccount(ctx, domain.SystemOwnerPrefix+"funding", domain.AccountTypeSystem, currency)
+	dst, _ := ledger.GetOrCreateAccount(ctx, ownerID, domain.AccountTypeWallet, currency)
+	entry := &domain.JournalEntry{Kind: "test_funding", Postings: []domain.Posting{{AccountID: src.ID, Amount: -amount, Currency: currency}, {AccountID: dst.ID, Amount: amount, Currency: currency}}, CreatedAt: time.Now().UTC()}
+	if err := ledger.Post(ctx, entry); err != nil {
+		t.Fatalf("fund: %v", err)
+	}
+}
+
+func newTestMerchant(t *testing.T, svc *MerchantService) *domain.Merchant {
+	t.Helper()
+	owner := addUser(t, svc.users, domain.User{UsernameLower: "owner"})
+	merchant, fields, err := svc.Create(context.Background(), CreateMerchantInput{OwnerID: owner, Name: " Mama  Mboga ", City: "Nairobi", CategoryCode: "5411", CountryCode: "ke", Currency: "kes"})
+	if err != nil {
+		t.Fatalf("create merchant: err=%v fields=%#v", err, fields)
+	}
+	return merchant
+}
+
+func TestCreateMerchantValidation(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	_, fields, err := svc.Create(context.Background(), CreateMerchantInput{OwnerID: "owner", Name: "", City: "Nairobi", CategoryCode: "54", CountryCode: "KEN", Currency: "EUR"})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("expected invalid input, got %v", err)
+	}
+	for _, k := range []string{"name", "categoryCode", "countryCode", "currency"} {
+		if fields[k] == "" {
+			t.Fatalf("expected %s field error, got %#v", k, fields)
+		}
+	}
+}
+
+func TestDynamicQRPaysOnceAndAppearsInSettlement(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	payer := addUser(t, st.users, domain.User{UsernameLower: "payer"})
+	merchant := newTestMerchant(t, svc)
+	if merchant.Name != "Mama Mboga" || merchant.Currency != "KES" || merchant.CountryCode != "KE" {
+		t.Fatalf("normalization failed: %#v", merchant)
+	}
+	qr, _, err := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID, Amount: 15050})
+	if err != nil || !qr.Dynamic || qr.Reference == "" {
+		t.Fatalf("generate qr: err=%v qr=%#v", err, qr)
+	}
+	fund(t, st.ledger, payer, "KES", 40000)
+
+	res, _, err := svc.PayQR(context.Background(), PayQRInput{PayerID: payer, Payload: qr.Payload})
+	if err != nil {
+		t.Fatalf("pay qr: %v", err)
+	}
+	if res.Amount != 15050 {
+		t.Fatalf("expected amount from payload, got %d", res.Amount)
+	}
+	if _, _, err := svc.PayQR(context.Background(), PayQRInput{PayerID: payer, Payload: qr.Payload}); !errors.Is(err, domain.ErrDuplicateEntry) {
+		t.Fatalf("expected duplicate on second scan, got %v", err)
+	}
+
+	// A partial refund out of the merchant account nets off the day.
+	store, _ := st.ledger.GetOrCreateAccount(context.Background(), merchant.ID, domain.AccountTypeMerchant, "KES")
+	wallet, _ := st.ledger.GetOrCreateAccount(context.Background(), payer, domain.AccountTypeWallet, "KES")
+	refund := &domain.JournalEntry{Kind: domain.EntryKindRefund, ReversalOf: res.Entry.ID, Postings: []domain.Posting{{AccountID: store.ID, Amount: -5050, Currency: "KES"}, {AccountID: wallet.ID, Amount: 5050, Currency: "KES"}}, CreatedAt: time.Now().UTC()}
+	if err := st.ledger.Post(context.Background(), refund); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	report, _, err := svc.SettlementReport(context.Background(), SettlementReportInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID, From: now.Add(-time.Hour), To: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("settlement report: %v", err)
+	}
+	if report.PaymentCount != 1 || report.GrossAmount != 15050 || report.Balance != 10000 || len(report.Days) != 1 {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+	if report.RefundCount != 1 || report.RefundedAmount != 5050 || report.NetAmount != 10000 || report.Days[0].Refunded != 5050 || report.Refunds[0].ReversalOf != res.Entry.ID {
+		t.Fatalf("expected the refund netted off, got %#v", report)
+	}
+}
+
+func TestDynamicQRReferenceIsGenerated(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	payer := addUser(t, st.users, domain.User{UsernameLower: "payer"})
+	merchant := newTestMerchant(t, svc)
+	if _, fields, err := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID, Amount: 100, Reference: "INV-42"}); !errors.Is(err, domain.ErrInvalidInput) || fields["reference"] == "" {
+		t.Fatalf("expected a chosen dynamic reference refused, got %v %v", fields, err)
+	}
+	first, _, _ := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID, Amount: 100})
+	second, _, _ := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID, Amount: 100})
+	if first.Reference == "" || first.Reference == second.Reference {
+		t.Fatalf("expected fresh references, got %q and %q", first.Reference, second.Reference)
+	}
+
+	// A forged dynamic code without a reference cannot claim the empty key.
+	currency, _ := domain.LookupCurrency("KES")
+	forged, _ := emvqr.Encode(emvqr.Payload{Dynamic: true, GUID: QRGloballyUniqueID, MerchantID: merchant.ID, Category: merchant.CategoryCode, Currency: currency.Numeric, Amount: "1.00", CountryCode: "KE", MerchantName: merchant.Name, MerchantCity: merchant.City})
+	if _, _, err := svc.PayQR(context.Background(), PayQRInput{PayerID: payer, Payload: forged}); !errors.Is(err, domain.ErrInvalidQRPayload) {
+		t.Fatalf("expected a dynamic code without reference refused, got %v", err)
+	}
+}
+
+func TestMerchantNameLengthCountsCharacters(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	in := CreateMerchantInput{OwnerID: "owner", Name: "Café Ñandú Mama Mboga Ltd", City: "Nairobi", CategoryCode: "5411", CountryCode: "KE", Currency: "KES"}
+	if _, fields, err := svc.Create(context.Background(), in); err != nil {
+		t.Fatalf("expected a 25-character name accepted, got %v %v", fields, err)
+	}
+}
+
+func TestStaticQRRequiresAmountAndFunds(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	payer := addUser(t, st.users, domain.User{UsernameLower: "payer"})
+	merchant := newTestMerchant(t, svc)
+	qr, _, err := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID})
+	if err != nil || qr.Dynamic {
+		t.Fatalf("generate static qr: err=%v qr=%#v", err, qr)
+	}
+	if _, fields, err := svc.PayQR(context.Background(), PayQRInput{PayerID: payer, Payload: qr.Payload}); !errors.Is(err, domain.ErrInvalidInput) || fields["amount"] == "" {
+		t.Fatalf("expected amount validation, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := svc.PayQR(context.Background(), PayQRInput{PayerID: payer, Payload: qr.Payload, Amount: 500}); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+}
+
+func TestQRGenerationHiddenFromNonOwner(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	merchant := newTestMerchant(t, svc)
+	if _, _, err := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: "someone-else", MerchantID: merchant.ID, Amount: 100}); !errors.Is(err, domain.ErrMerchantNotFound) {
+		t.Fatalf("expected merchant not found, got %v", err)
+	}
+}
+
+func TestQRPaymentRefusedWhileOwnerIsClosing(t *testing.T) {
+	st := newTestStore()
+	svc := NewMerchantService(st.users, st.merchants, st.ledger, nil)
+	payer := addUser(t, st.users, domain.User{UsernameLower: "payer"})
+	merchant := newTestMerchant(t, svc)
+	qr, _, err := svc.GenerateQR(context.Background(), GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID, Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setStatus(t, svc.users, merchant.OwnerID, domain.UserStatusClosing)
+	if _, fields, err := svc.PayQR(context.Background(), PayQRInput{PayerID: payer, Payload: qr.Payload}); !errors.Is(err, domain.ErrInvalidQRPayload) || fields["payload"] == "" {
+		t.Fatalf("expected the payment refused, got err=%v fields=%#v", err, fields)
+	}
+}
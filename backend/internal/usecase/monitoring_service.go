@@ -0,0 +1,429 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/aml"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const (
+	monitorBatch = 200
+	// monitorLag keeps the scan behind the newest entries so one posted
+	// with a slightly earlier timestamp by a slower writer is not skipped.
+	monitorLag       = 5 * time.Second
+	replayMaxEntries = 20000
+)
+
+type ReplayInput struct {
+	ActorID string
+	// Rules are the candidate rules to backtest; empty replays the active
+	// rule set.
+	Rules []aml.Rule
+	From  time.Time
+	To    time.Time
+}
+
+// ReplayHit is what one rule would have raised for one subject. Triggers
+// counts the entries that fired it; the live monitor folds those into a
+// single alert.
+type ReplayHit struct {
+	RuleID         string
+	SubjectKey     string
+	OwnerID        string
+	AccountID      string
+	TriggerEntryID string
+	Triggers       int
+	Value          int64
+	EntryIDs       []string
+	At             time.Time
+}
+
+type ReplayResult struct {
+	Scanned   int
+	Truncated bool
+	Hits      []ReplayHit
+}
+
+type CloseAlertInput struct {
+	ActorID string
+	AlertID string
+	// Status is dismissed (false positive) or escalated (reported).
+	Status string
+	Note   string
+}
+
+// MonitoringService runs rule-based AML transaction monitoring. Scan walks
+// the journal behind a persisted cursor and raises alerts into the case
+// queue; Replay evaluates candidate rules over historical postings without
+// raising anything.
+type MonitoringService struct {
+	users  repository.UserRepository
+	ledger repository.LedgerRepository
+	alerts repository.AMLRepository
+	rules  *aml.RuleSet
+	now    func() time.Time
+}
+
+func NewMonitoringService(users repository.UserRepository, ledger repository.LedgerRepository, alerts repository.AMLRepository, rules *aml.RuleSet) *MonitoringService {
+	return &MonitoringService{users: users, ledger: ledger, alerts: alerts, rules: rules, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Scan evaluates every entry posted since the previous scan and returns how
+// many new alerts it opened. Re-evaluating an entry after a crash only adds
+// evidence to the alert it already raised.
+func (s *MonitoringService) Scan(ctx context.Context) (int, error) {
+	after, afterID, err := s.alerts.GetCursor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rules := s.rules.Active()
+	until := s.now().Add(-monitorLag)
+	opened := 0
+	for {
+		entries, err := s.ledger.ListEntriesAfter(ctx, after, afterID, until, monitorBatch)
+		if err != nil {
+			return opened, err
+		}
+		eval := newRuleEvaluator(s.ledger, rules)
+		for _, entry := range entries {
+			hits, err := eval.evaluate(ctx, entry)
+			if err != nil {
+				return opened, err
+			}
+			for _, h := range hits {
+				created, err := s.raise(ctx, h)
+				if err != nil {
+					return opened, err
+				}
+				if created {
+					opened++
+				}
+			}
+			after, afterID = entry.CreatedAt, entry.ID
+		}
+		if len(entries) > 0 {
+			if err := s.alerts.SaveCursor(ctx, after, afterID); err != nil {
+				return opened, err
+			}
+		}
+		if len(entries) < monitorBatch {
+			return opened, nil
+		}
+	}
+}
+
+// raise opens an alert for h, or links its evidence to the alert already
+// open for the same rule and subject.
+func (s *MonitoringService) raise(ctx context.Context, h ruleHit) (bool, error) {
+	now := s.now()
+	for attempt := 0; attempt < 2; attempt++ {
+		existing, err := s.alerts.GetOpenAlert(ctx, h.rule.ID, h.subjectKey)
+		if err == nil {
+			return false, s.alerts.AddAlertEntries(ctx, existing.ID, h.EntryIDs, now)
+		}
+		if !errors.Is(err, domain.ErrAlertNotFound) {
+			return false, err
+		}
+		alert := &domain.AMLAlert{
+			RuleID:         h.rule.ID,
+			RuleName:       h.rule.Name,
+			Severity:       h.rule.Severity,
+			SubjectKey:     h.subjectKey,
+			OwnerID:        h.event.OwnerID,
+			AccountID:      h.accountID,
+			Currency:       h.rule.Currency,
+			Value:          h.Value,
+			Threshold:      h.rule.Threshold,
+			TriggerEntryID: h.event.EntryID,
+			EntryIDs:       h.EntryIDs,
+			Status:         domain.AlertStatusOpen,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		err = s.alerts.CreateAlert(ctx, alert)
+		if !errors.Is(err, domain.ErrAlertExists) {
+			return err == nil, err
+		}
+		// Another scanner opened it first; add to theirs.
+	}
+	return false, domain.ErrAlertExists
+}
+
+// Replay backtests rules against the entries posted in [From, To). Each
+// entry is judged on the history that existed when it was posted.
+func (s *MonitoringService) Replay(ctx context.Context, in ReplayInput) (*ReplayResult, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermAMLManage); err != nil {
+		return nil, nil, err
+	}
+	fields := domain.FieldErrors{}
+	if in.From.IsZero() || in.To.IsZero() || !in.From.Before(in.To) {
+		fields["to"] = "must be after from"
+	}
+	rules := s.rules.Active()
+	if len(in.Rules) > 0 {
+		set := aml.RuleSet{Rules: in.Rules}
+		if err := set.Validate(); err != nil {
+			fields["rules"] = strings.TrimPrefix(err.Error(), aml.ErrInvalidRules.Error()+": ")
+		}
+		rules = set.Active()
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+
+	result := &ReplayResult{Hits: []ReplayHit{}}
+	byKey := map[string]int{}
+	eval := newRuleEvaluator(s.ledger, rules)
+	after, afterID := in.From.Add(-time.Nanosecond), ""
+	for {
+		entries, err := s.ledger.ListEntriesAfter(ctx, after, afterID, in.To, monitorBatch)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if result.Scanned == replayMaxEntries {
+				result.Truncated = true
+				return result, nil, nil
+			}
+			result.Scanned++
+			after, afterID = entry.CreatedAt, entry.ID
+			hits, err := eval.evaluate(ctx, entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, h := range hits {
+				key := h.rule.ID + "|" + h.subjectKey
+				i, ok := byKey[key]
+				if !ok {
+					byKey[key] = len(result.Hits)
+					result.Hits = append(result.Hits, ReplayHit{RuleID: h.rule.ID, SubjectKey: h.subjectKey, OwnerID: h.event.OwnerID, AccountID: h.accountID, TriggerEntryID: h.event.EntryID, Triggers: 1, Value: h.Value, EntryIDs: h.EntryIDs, At: h.event.At})
+					continue
+				}
+				r := &result.Hits[i]
+				r.Triggers++
+				r.EntryIDs = mergeIDs(r.EntryIDs, h.EntryIDs)
+			}
+		}
+		if len(entries) < monitorBatch {
+			return result, nil, nil
+		}
+	}
+}
+
+func (s *MonitoringService) Rules(ctx context.Context, actorID string) ([]aml.Rule, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAMLManage); err != nil {
+		return nil, err
+	}
+	return s.rules.Rules, nil
+}
+
+func (s *MonitoringService) ListAlerts(ctx context.Context, actorID string, status domain.AlertStatus) ([]*domain.AMLAlert, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAMLManage); err != nil {
+		return nil, err
+	}
+	return s.alerts.ListAlerts(ctx, status)
+}
+
+func (s *MonitoringService) GetAlert(ctx context.Context, actorID, alertID string) (*domain.AMLAlert, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermAMLManage); err != nil {
+		return nil, err
+	}
+	return s.alerts.GetAlert(ctx, alertID)
+}
+
+// Assign takes an open alert into investigation by the caller.
+func (s *MonitoringService) Assign(ctx context.Context, actorID, alertID string) (*domain.AMLAlert, error) {
+	alert, err := s.GetAlert(ctx, actorID, alertID)
+	if err != nil {
+		return nil, err
+	}
+	from := alert.Status
+	if !from.CanTransition(domain.AlertStatusInvestigating) {
+		return nil, domain.ErrInvalidTransition
+	}
+	alert.Status, alert.AssignedTo, alert.UpdatedAt = domain.AlertStatusInvestigating, actorID, s.now()
+	if err := s.alerts.TransitionAlert(ctx, alert, from); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (s *MonitoringService) Close(ctx context.Context, in CloseAlertInput) (*domain.AMLAlert, domain.FieldErrors, error) {
+	alert, err := s.GetAlert(ctx, in.ActorID, in.AlertID)
+	if err != nil {
+		return nil, nil, err
+	}
+	next := domain.AlertStatus(in.Status)
+	if !next.Closed() {
+		return nil, domain.FieldErrors{"status": "must be dismissed or escalated"}, domain.ErrInvalidInput
+	}
+	note := strings.TrimSpace(in.Note)
+	if note == "" || len(note) > maxReasonLength {
+		return nil, domain.FieldErrors{"note": "must be 1-500 characters"}, domain.ErrInvalidInput
+	}
+	from := alert.Status
+	if !from.CanTransition(next) {
+		return nil, nil, domain.ErrInvalidTransition
+	}
+	now := s.now()
+	alert.Status, alert.ResolutionNote, alert.ResolvedBy, alert.ResolvedAt, alert.UpdatedAt = next, note, in.ActorID, now, now
+	if err := s.alerts.TransitionAlert(ctx, alert, from); err != nil {
+		return nil, nil, err
+	}
+	return alert, nil, nil
+}
+
+type ruleHit struct {
+	aml.Hit
+	rule       aml.Rule
+	event      aml.Event
+	subjectKey string
+	accountID  string
+}
+
+// ruleEvaluator turns journal entries into events and evaluates rules on
+// them, reading each group's history back from the ledger. Accounts are
+// cached for the evaluator's lifetime.
+type ruleEvaluator struct {
+	ledger   repository.LedgerRepository
+	rules    []aml.Rule
+	lookback time.Duration
+	accounts map[string]*domain.Account
+}
+
+func newRuleEvaluator(ledger repository.LedgerRepository, rules []aml.Rule) *ruleEvaluator {
+	return &ruleEvaluator{ledger: ledger, rules: rules, lookback: aml.Lookback(rules), accounts: map[string]*domain.Account{}}
+}
+
+func (e *ruleEvaluator) account(ctx context.Context, id string) (*domain.Account, error) {
+	if a, ok := e.accounts[id]; ok {
+		return a, nil
+	}
+	a, err := e.ledger.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	e.accounts[id] = a
+	return a, nil
+}
+
+func (e *ruleEvaluator) events(ctx context.Context, entry *domain.JournalEntry) ([]aml.Event, error) {
+	accounts := map[string]*domain.Account{}
+	for _, p := range entry.Postings {
+		a, err := e.account(ctx, p.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		accounts[a.ID] = a
+	}
+	return aml.EventsFromEntry(entry, accounts), nil
+}
+
+func (e *ruleEvaluator) evaluate(ctx context.Context, entry *domain.JournalEntry) ([]ruleHit, error) {
+	if len(e.rules) == 0 {
+		return nil, nil
+	}
+	events, err := e.events(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	var hits []ruleHit
+	for _, ev := range events {
+		type group struct {
+			history []aml.Event
+			since   time.Time
+		}
+		groups := map[aml.GroupBy]*group{}
+		for _, rule := range e.rules {
+			if rule.Currency != ev.Currency {
+				continue
+			}
+			g, ok := groups[rule.GroupBy]
+			if !ok {
+				history, since, err := e.history(ctx, ev, rule.GroupBy)
+				if err != nil {
+					return nil, err
+				}
+				g = &group{history: history, since: since}
+				groups[rule.GroupBy] = g
+			}
+			hit, ok := rule.Evaluate(ev, g.history, g.since)
+			if !ok {
+				continue
+			}
+			h := ruleHit{Hit: hit, rule: rule, event: ev, subjectKey: "owner:" + ev.OwnerID}
+			if rule.GroupBy == aml.GroupByAccount {
+				h.subjectKey, h.accountID = "account:"+ev.AccountID, ev.AccountID
+			}
+			hits = append(hits, h)
+		}
+	}
+	return hits, nil
+}
+
+// history returns the group's events from the lookback window up to the
+// trigger, and when the group's oldest account was opened.
+func (e *ruleEvaluator) history(ctx context.Context, trigger aml.Event, groupBy aml.GroupBy) ([]aml.Event, time.Time, error) {
+	var accounts []*domain.Account
+	if groupBy == aml.GroupByOwner {
+		owned, err := e.ledger.ListAccountsByOwner(ctx, trigger.OwnerID)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		for _, a := range owned {
+			if a.Currency == trigger.Currency && !a.AllowsNegative() {
+				accounts = append(accounts, a)
+			}
+		}
+	} else {
+		a, err := e.account(ctx, trigger.AccountID)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		accounts = []*domain.Account{a}
+	}
+
+	var out []aml.Event
+	since := trigger.At
+	// Stored timestamps have millisecond precision, so reach just past the
+	// trigger to be sure it is included.
+	from, to := trigger.At.Add(-e.lookback), trigger.At.Add(time.Millisecond)
+	for _, a := range accounts {
+		if a.CreatedAt.Before(since) {
+			since = a.CreatedAt
+		}
+		entries, err := e.ledger.ListEntriesByAccount(ctx, a.ID, from, to)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		for _, entry := range entries {
+			events, err := e.events(ctx, entry)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			for _, ev := range events {
+				if ev.AccountID == a.ID {
+					out = append(out, ev)
+				}
+			}
+		}
+	}
+	return out, since, nil
+}
+
+func mergeIDs(a, b []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(a)+len(b))
+	for _, id := range append(append([]string(nil), a...), b...) {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
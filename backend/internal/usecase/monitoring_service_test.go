@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/aml"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+// fanInRules watches for three distinct senders paying one account within
+// an hour.
+func fanInRules(t *testing.T) *aml.RuleSet {
+	t.Helper()
+	rules := &aml.RuleSet{Rules: []aml.Rule{{ID: "fan_in", Name: "Fan-in", Severity: "medium", Currency: "KES", Kinds: []string{"transfer"}, Direction: aml.DirectionIn, Window: aml.Duration(time.Hour), Aggregate: aml.AggregateDistinctCounterparties, Threshold: 3}}}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("rules: %v", err)
+	}
+	return rules
+}
+
+// addCustomers stores n customers, user1 to userN.
+func addCustomers(t *testing.T, st testStore, n int) []string {
+	t.Helper()
+	var ids []string
+	for i := 1; i <= n; i++ {
+		ids = append(ids, addUser(t, st.users, domain.User{UsernameLower: fmt.Sprintf("user%d", i)}))
+	}
+	return ids
+}
+
+// sendKES funds senderID with 1000 KES and has them send it to recipient.
+func sendKES(t *testing.T, st testStore, transfers *TransferService, senderID, recipient string) {
+	t.Helper()
+	fund(t, st.ledger, senderID, "KES", 1000)
+	if _, _, err := transfers.Transfer(context.Background(), TransferInput{SenderID: senderID, Recipient: recipient, Amount: 1000, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer from %s: %v", senderID, err)
+	}
+}
+
+func TestScanRaisesOneAlertAndLinksFurtherEvidence(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	svc := NewMonitoringService(st.users, st.ledger, memory.NewAMLRepository(st.Store), fanInRules(t))
+	svc.now = func() time.Time { return time.Now().UTC().Add(time.Minute) }
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(&fees.Schedule{}), nil, nil, nil, nil)
+	operator := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	customers := addCustomers(t, st, 5)
+	sendKES(t, st, transfers, customers[0], "user5")
+	sendKES(t, st, transfers, customers[1], "user5")
+	if n, err := svc.Scan(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no alert for two senders, got n=%d err=%v", n, err)
+	}
+	sendKES(t, st, transfers, customers[2], "user5")
+	if n, err := svc.Scan(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one alert, got n=%d err=%v", n, err)
+	}
+	sendKES(t, st, transfers, customers[3], "user5")
+	if n, err := svc.Scan(ctx); err != nil || n != 0 {
+		t.Fatalf("expected evidence added to the open alert, got n=%d err=%v", n, err)
+	}
+	alerts, _ := svc.ListAlerts(ctx, operator, domain.AlertStatusOpen)
+	if len(alerts) != 1 || len(alerts[0].EntryIDs) != 4 || alerts[0].OwnerID != customers[4] {
+		t.Fatalf("unexpected alerts: %#v", alerts)
+	}
+	if _, err := svc.ListAlerts(ctx, customers[4], ""); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customers to be refused, got %v", err)
+	}
+	if _, err := svc.Assign(ctx, operator, alerts[0].ID); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	closed, _, err := svc.Close(ctx, CloseAlertInput{ActorID: operator, AlertID: alerts[0].ID, Status: "escalated", Note: "filed STR"})
+	if err != nil || closed.Status != domain.AlertStatusEscalated {
+		t.Fatalf("close: err=%v alert=%#v", err, closed)
+	}
+}
+
+func TestReplayBacktestsWithoutRaising(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alerts := memory.NewAMLRepository(st.Store)
+	svc := NewMonitoringService(st.users, st.ledger, alerts, fanInRules(t))
+	svc.now = func() time.Time { return time.Now().UTC().Add(time.Minute) }
+	transfers := NewTransferService(st.users, st.ledger, NewFeeService(&fees.Schedule{}), nil, nil, nil, nil)
+	operator := addUser(t, st.users, domain.User{UsernameLower: "support", Role: domain.UserRoleSupport})
+	customers := addCustomers(t, st, 5)
+	for _, sender := range customers[:3] {
+		sendKES(t, st, transfers, sender, "user5")
+	}
+	candidate := []aml.Rule{{ID: "fan_in_2", Currency: "KES", Direction: aml.DirectionIn, Kinds: []string{"transfer"}, Window: aml.Duration(time.Hour), Aggregate: aml.AggregateDistinctCounterparties, Threshold: 2}}
+	now := time.Now().UTC()
+	res, _, err := svc.Replay(ctx, ReplayInput{ActorID: operator, Rules: candidate, From: now.Add(-time.Hour), To: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if res.Scanned != 6 || len(res.Hits) != 1 || res.Hits[0].Triggers != 2 || len(res.Hits[0].EntryIDs) != 3 {
+		t.Fatalf("unexpected replay: %#v", res)
+	}
+	if alerts, _ := alerts.ListAlerts(ctx, ""); len(alerts) != 0 {
+		t.Fatalf("replay must not raise alerts")
+	}
+	if _, fields, err := svc.Replay(ctx, ReplayInput{ActorID: operator, Rules: []aml.Rule{{ID: "bad"}}, From: now.Add(-time.Hour), To: now}); !errors.Is(err, domain.ErrInvalidInput) || fields["rules"] == "" {
+		t.Fatalf("expected invalid rules to be rejected, got %v", err)
+	}
+}
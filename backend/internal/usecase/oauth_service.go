@@ -0,0 +1,713 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oauthCodeTTL            = time.Minute
+	defaultOAuthAccessTTL   = 10 * time.Minute
+	defaultOAuthRefreshTTL  = 30 * 24 * time.Hour
+	pkceMethodS256          = "S256"
+	oauthResponseTypeCode   = "code"
+	oauthTokenTypeAccess    = "access_token"
+	oauthTokenTypeRefresh   = "refresh_token"
+	oauthRefreshTokenPrefix = "akr_"
+	oauthTokenBytes         = 32
+	maxOIDCNonceLen         = 255
+)
+
+// A PKCE verifier is 43-128 unreserved characters (RFC 7636 section 4.1);
+// an S256 challenge is always 43 base64url characters.
+var (
+	pkceVerifierPattern  = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+	pkceChallengePattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{43}$`)
+)
+
+type OAuthConfig struct {
+	// AccessTokenTTL is how long an access token issued to an app for a
+	// user lives.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long a grant with offline_access lasts,
+	// however often its refresh token is rotated.
+	RefreshTokenTTL time.Duration
+	// Issuer is the OpenID Connect issuer URL. OpenID Connect is off when
+	// it is empty or the token manager has no ID token key.
+	Issuer string
+	// AuthorizationEndpoint is the consent screen apps send users to,
+	// advertised in the discovery document.
+	AuthorizationEndpoint string
+}
+
+// AuthorizationRequest is an RFC 6749 section 4.1.1 request made by UserID
+// through the consent screen. PKCE with S256 is required of every client.
+// Nonce is the OpenID Connect nonce and AuthTime when the user last
+// authenticated, zero if their token does not say.
+type AuthorizationRequest struct {
+	UserID              string
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	AuthTime            time.Time
+}
+
+// AuthorizationPreview is what the consent screen shows. It is returned
+// alongside errors once the redirect URI has been checked, so the caller
+// knows it may send the error back to the app. Consented is set when the
+// user already approved every requested scope.
+type AuthorizationPreview struct {
+	Client      *domain.APIClient
+	RedirectURI string
+	State       string
+	Scopes      []domain.Scope
+	Consented   bool
+}
+
+// AuthorizationResult carries the code for an approved request. Code is
+// empty when the user denied it.
+type AuthorizationResult struct {
+	AuthorizationPreview
+	Consent *domain.OAuthConsent
+	Code    string
+}
+
+// ClientAuth is how a client authenticated at the token, revocation or
+// introspection endpoint; see APIClientService.Authenticate.
+type ClientAuth struct {
+	ClientID string
+	Secret   string
+	IP       string
+}
+
+type CodeExchangeInput struct {
+	ClientAuth
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// RefreshInput may narrow Scope for the new access token; the refresh
+// token keeps the grant's scopes.
+type RefreshInput struct {
+	ClientAuth
+	RefreshToken string
+	Scope        string
+}
+
+// OAuthTokenResult is the outcome of a code exchange or refresh. Client is
+// set once the client authenticated and Grant once the grant was found,
+// including alongside errors, so refusals can be audited. RefreshToken is
+// empty unless the grant has offline_access, and IDToken unless the code
+// was issued for openid.
+type OAuthTokenResult struct {
+	Client       *domain.APIClient
+	Grant        *domain.OAuthGrant
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresIn    time.Duration
+	Scopes       []domain.Scope
+}
+
+// TokenIntrospection is an RFC 7662 response. Only Active is set for a
+// token that is not active or not the caller's.
+type TokenIntrospection struct {
+	Active    bool
+	TokenType string
+	ClientID  string
+	Subject   string
+	Scopes    []domain.Scope
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// UserConsent is a consent with the app it was given to.
+type UserConsent struct {
+	Consent *domain.OAuthConsent
+	Client  *domain.APIClient
+}
+
+// UserInfo holds the standard claims (OpenID Connect Core section 5.1) the
+// user released to an app. Fields of scopes that were not granted are
+// empty. Email is never marked verified, as nothing proves ownership of
+// the address yet; the phone number is once the user confirmed an OTP sent
+// to it.
+type UserInfo struct {
+	Subject             string
+	PreferredUsername   string
+	Email               string
+	EmailVerified       bool
+	PhoneNumber         string
+	PhoneNumberVerified bool
+}
+
+// OIDCProvider is what the discovery document says about this provider.
+type OIDCProvider struct {
+	Issuer                string
+	AuthorizationEndpoint string
+	Scopes                []domain.Scope
+	Claims                []string
+}
+
+// OAuthService lets third-party apps act for users who approve them: the
+// authorization code grant with PKCE, refresh token rotation, revocation
+// and introspection, and OpenID Connect sign-in on top of them. Client
+// authentication is APIClientService's.
+type OAuthService struct {
+	oauth   repository.OAuthRepository
+	clients *APIClientService
+	users   repository.UserRepository
+	tokens  *auth.JWTManager
+	cfg     OAuthConfig
+	now     func() time.Time
+}
+
+func NewOAuthService(oauth repository.OAuthRepository, clients *APIClientService, users repository.UserRepository, tokens *auth.JWTManager, cfg OAuthConfig) *OAuthService {
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = defaultOAuthAccessTTL
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = defaultOAuthRefreshTTL
+	}
+	return &OAuthService{oauth: oauth, clients: clients, users: users, tokens: tokens, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// ValidateAuthorization checks a request before the user is asked to
+// consent. An unknown client is domain.ErrInvalidClient and an
+// unregistered redirect URI domain.ErrInvalidRedirectURI; neither may be
+// redirected back to the app. Later failures come with the preview.
+func (s *OAuthService) ValidateAuthorization(ctx context.Context, in AuthorizationRequest) (*AuthorizationPreview, error) {
+	client, err := s.clients.clients.GetByID(ctx, in.ClientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIClientNotFound) {
+			return nil, domain.ErrInvalidClient
+		}
+		return nil, err
+	}
+	if client.Status != domain.APIClientActive {
+		return nil, domain.ErrInvalidClient
+	}
+	// Redirect URIs are compared exactly, so a registered URI cannot be
+	// extended into an open redirect.
+	if in.RedirectURI == "" || !slices.Contains(client.RedirectURIs, in.RedirectURI) {
+		return nil, domain.ErrInvalidRedirectURI
+	}
+	p := &AuthorizationPreview{Client: client, RedirectURI: in.RedirectURI, State: in.State}
+	if in.ResponseType != oauthResponseTypeCode {
+		return p, domain.ErrUnsupportedResponse
+	}
+	if in.CodeChallengeMethod != pkceMethodS256 || !pkceChallengePattern.MatchString(in.CodeChallenge) {
+		return p, domain.ErrInvalidRequest
+	}
+	p.Scopes = domain.NormalizeScopes([]string{in.Scope})
+	if len(p.Scopes) == 0 {
+		return p, domain.ErrInvalidScope
+	}
+	for _, sc := range p.Scopes {
+		if !sc.Delegated() || !client.HasScope(sc) {
+			return p, domain.ErrInvalidScope
+		}
+	}
+	// email and phone only mean something to an OpenID Connect request.
+	if slices.Contains(p.Scopes, domain.ScopeOpenID) {
+		if !s.OIDCEnabled() {
+			return p, domain.ErrInvalidScope
+		}
+	} else if slices.Contains(p.Scopes, domain.ScopeEmail) || slices.Contains(p.Scopes, domain.ScopePhone) {
+		return p, domain.ErrInvalidScope
+	}
+	if len(in.Nonce) > maxOIDCNonceLen {
+		return p, domain.ErrInvalidRequest
+	}
+	user, err := s.users.GetByID(ctx, in.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return p, domain.ErrUnauthorized
+		}
+		return p, err
+	}
+	if !user.Status.CanLogIn() {
+		return p, domain.ErrForbidden
+	}
+	consent, err := s.oauth.GetConsent(ctx, in.UserID, client.ID)
+	if err != nil && !errors.Is(err, domain.ErrConsentNotFound) {
+		return p, err
+	}
+	p.Consented = consent != nil && consent.Covers(p.Scopes)
+	return p, nil
+}
+
+// Authorize records the user's answer. Approving widens the user's consent
+// to the requested scopes and issues a single-use code bound to the
+// redirect URI and PKCE challenge.
+func (s *OAuthService) Authorize(ctx context.Context, in AuthorizationRequest, approve bool) (*AuthorizationResult, error) {
+	p, err := s.ValidateAuthorization(ctx, in)
+	if err != nil {
+		if p == nil {
+			return nil, err
+		}
+		return &AuthorizationResult{AuthorizationPreview: *p}, err
+	}
+	res := &AuthorizationResult{AuthorizationPreview: *p}
+	if !approve {
+		return res, nil
+	}
+	now := s.now()
+	scopes := slices.Clone(p.Scopes)
+	consent, err := s.oauth.GetConsent(ctx, in.UserID, p.Client.ID)
+	switch {
+	case err == nil && consent.Active():
+		for _, sc := range consent.Scopes {
+			if !slices.Contains(scopes, sc) {
+				scopes = append(scopes, sc)
+			}
+		}
+	case err != nil && !errors.Is(err, domain.ErrConsentNotFound):
+		return nil, err
+	}
+	res.Consent = &domain.OAuthConsent{UserID: in.UserID, ClientID: p.Client.ID, Scopes: scopes, CreatedAt: now, UpdatedAt: now}
+	if err := s.oauth.UpsertConsent(ctx, res.Consent); err != nil {
+		return nil, err
+	}
+	code, err := randomOAuthToken("")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.oauth.CreateCode(ctx, &domain.OAuthCode{Hash: hashAPIKey(code), ClientID: p.Client.ID, UserID: in.UserID, RedirectURI: p.RedirectURI, Scopes: p.Scopes, CodeChallenge: in.CodeChallenge, Nonce: in.Nonce, AuthTime: in.AuthTime, ExpiresAt: now.Add(oauthCodeTTL), CreatedAt: now}); err != nil {
+		return nil, err
+	}
+	res.Code = code
+	return res, nil
+}
+
+// ExchangeCode runs the authorization code grant. A code presented a
+// second time is domain.ErrTokenReused and revokes whatever was issued
+// from it, since either the first or the second caller stole it (RFC 6749
+// section 4.1.2). A code issued for openid also yields an ID token.
+func (s *OAuthService) ExchangeCode(ctx context.Context, in CodeExchangeInput) (*OAuthTokenResult, error) {
+	client, _, err := s.clients.Authenticate(ctx, in.ClientID, in.Secret, in.IP)
+	if client == nil {
+		return nil, err
+	}
+	res := &OAuthTokenResult{Client: client}
+	if err != nil {
+		return res, err
+	}
+	now := s.now()
+	codeHash := hashAPIKey(in.Code)
+	code, err := s.oauth.ConsumeCode(ctx, codeHash, now)
+	if errors.Is(err, domain.ErrTokenReused) {
+		if code.ClientID != client.ID {
+			return res, domain.ErrInvalidGrant
+		}
+		if err := s.oauth.RevokeGrantsByCode(ctx, codeHash, now); err != nil {
+			return res, err
+		}
+		return res, domain.ErrTokenReused
+	}
+	if err != nil {
+		return res, err
+	}
+	if code.ClientID != client.ID || !now.Before(code.ExpiresAt) || code.RedirectURI != in.RedirectURI || !verifyPKCE(in.CodeVerifier, code.CodeChallenge) {
+		return res, domain.ErrInvalidGrant
+	}
+	user, err := s.checkUserConsent(ctx, code.UserID, client.ID, code.Scopes)
+	if err != nil {
+		return res, err
+	}
+	grant := &domain.OAuthGrant{ClientID: client.ID, UserID: code.UserID, CodeHash: codeHash, Scopes: code.Scopes, ExpiresAt: now.Add(s.cfg.AccessTokenTTL), CreatedAt: now, UpdatedAt: now}
+	if slices.Contains(code.Scopes, domain.ScopeOfflineAccess) {
+		if res.RefreshToken, err = randomOAuthToken(oauthRefreshTokenPrefix); err != nil {
+			return nil, err
+		}
+		grant.RefreshHash, grant.ExpiresAt = hashAPIKey(res.RefreshToken), now.Add(s.cfg.RefreshTokenTTL)
+	}
+	if err := s.oauth.CreateGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	res.Grant, res.Scopes = grant, grant.Scopes
+	if err := s.issueAccessToken(res); err != nil {
+		return nil, err
+	}
+	if slices.Contains(code.Scopes, domain.ScopeOpenID) {
+		if err := s.issueIDToken(res, user, code); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Refresh rotates the grant's refresh token. Presenting a token that was
+// already rotated away is domain.ErrTokenReused and revokes the grant.
+func (s *OAuthService) Refresh(ctx context.Context, in RefreshInput) (*OAuthTokenResult, error) {
+	client, _, err := s.clients.Authenticate(ctx, in.ClientID, in.Secret, in.IP)
+	if client == nil {
+		return nil, err
+	}
+	res := &OAuthTokenResult{Client: client}
+	if err != nil {
+		return res, err
+	}
+	now := s.now()
+	hash := hashAPIKey(in.RefreshToken)
+	grant, err := s.oauth.GetGrantByRefresh(ctx, hash)
+	if err != nil {
+		if errors.Is(err, domain.ErrGrantNotFound) {
+			return res, domain.ErrInvalidGrant
+		}
+		return res, err
+	}
+	if grant.ClientID != client.ID {
+		return res, domain.ErrInvalidGrant
+	}
+	res.Grant = grant
+	if !grant.Active(now) {
+		return res, domain.ErrInvalidGrant
+	}
+	if grant.RefreshHash != hash {
+		if err := s.oauth.RevokeGrant(ctx, grant.ID, now); err != nil {
+			return res, err
+		}
+		return res, domain.ErrTokenReused
+	}
+	res.Scopes = domain.NormalizeScopes([]string{in.Scope})
+	if len(res.Scopes) == 0 {
+		res.Scopes = grant.Scopes
+	}
+	for _, sc := range res.Scopes {
+		if !slices.Contains(grant.Scopes, sc) {
+			return res, domain.ErrInvalidScope
+		}
+	}
+	if _, err := s.checkUserConsent(ctx, grant.UserID, client.ID, res.Scopes); err != nil {
+		return res, err
+	}
+	if res.RefreshToken, err = randomOAuthToken(oauthRefreshTokenPrefix); err != nil {
+		return nil, err
+	}
+	if err := s.oauth.RotateRefresh(ctx, grant.ID, hash, hashAPIKey(res.RefreshToken), now); err != nil {
+		return res, err
+	}
+	if err := s.issueAccessToken(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Revoke ends the grant behind a refresh token or access token the client
+// holds (RFC 7009). Unknown tokens and other clients' tokens are ignored,
+// as the RFC asks, and the grant is nil. Client-credentials tokens cannot
+// be revoked; they live out their short TTL.
+func (s *OAuthService) Revoke(ctx context.Context, in ClientAuth, token string) (*domain.APIClient, *domain.OAuthGrant, error) {
+	client, _, err := s.clients.Authenticate(ctx, in.ClientID, in.Secret, in.IP)
+	if err != nil {
+		return client, nil, err
+	}
+	grant, err := s.grantForToken(ctx, client, token)
+	if err != nil || grant == nil || !grant.RevokedAt.IsZero() {
+		return client, nil, err
+	}
+	if err := s.oauth.RevokeGrant(ctx, grant.ID, s.now()); err != nil {
+		return client, nil, err
+	}
+	return client, grant, nil
+}
+
+// Introspect describes a token the client holds (RFC 7662). Tokens of
+// other clients are reported inactive.
+func (s *OAuthService) Introspect(ctx context.Context, in ClientAuth, token string) (*domain.APIClient, *TokenIntrospection, error) {
+	client, _, err := s.clients.Authenticate(ctx, in.ClientID, in.Secret, in.IP)
+	if err != nil {
+		return client, nil, err
+	}
+	now := s.now()
+	inactive := &TokenIntrospection{}
+	if claims, err := s.tokens.Verify(token); err == nil {
+		if claims.ClientID != client.ID {
+			return client, inactive, nil
+		}
+		if claims.Delegated() {
+			if err := s.CheckGrant(ctx, claims.Sub, claims.ClientID, claims.GrantID); err != nil {
+				if errors.Is(err, domain.ErrUnauthorized) {
+					return client, inactive, nil
+				}
+				return client, nil, err
+			}
+		}
+		out := &TokenIntrospection{Active: true, TokenType: oauthTokenTypeAccess, ClientID: client.ID, Subject: claims.Sub, Scopes: domain.NormalizeScopes([]string{claims.Scope})}
+		if claims.IssuedAt != nil {
+			out.IssuedAt = claims.IssuedAt.Time
+		}
+		if claims.ExpiresAt != nil {
+			out.ExpiresAt = claims.ExpiresAt.Time
+		}
+		return client, out, nil
+	}
+	grant, err := s.oauth.GetGrantByRefresh(ctx, hashAPIKey(token))
+	if errors.Is(err, domain.ErrGrantNotFound) {
+		return client, inactive, nil
+	}
+	if err != nil {
+		return client, nil, err
+	}
+	if grant.ClientID != client.ID || grant.RefreshHash != hashAPIKey(token) || !grant.Active(now) {
+		return client, inactive, nil
+	}
+	return client, &TokenIntrospection{Active: true, TokenType: oauthTokenTypeRefresh, ClientID: client.ID, Subject: grant.UserID, Scopes: grant.Scopes, IssuedAt: grant.UpdatedAt, ExpiresAt: grant.ExpiresAt}, nil
+}
+
+// CheckGrant re-checks an access token an app holds for a user, so that
+// revoking the grant or consent, disabling the app, or suspending the user
+// stops it before it expires. Any of those is domain.ErrUnauthorized.
+func (s *OAuthService) CheckGrant(ctx context.Context, userID, clientID, grantID string) error {
+	grant, err := s.oauth.GetGrant(ctx, grantID)
+	if err != nil {
+		if errors.Is(err, domain.ErrGrantNotFound) {
+			return domain.ErrUnauthorized
+		}
+		return err
+	}
+	if grant.UserID != userID || grant.ClientID != clientID || !grant.Active(s.now()) {
+		return domain.ErrUnauthorized
+	}
+	client, err := s.clients.clients.GetByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIClientNotFound) {
+			return domain.ErrUnauthorized
+		}
+		return err
+	}
+	if client.Status != domain.APIClientActive {
+		return domain.ErrUnauthorized
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrUnauthorized
+		}
+		return err
+	}
+	if !user.Status.CanLogIn() {
+		return domain.ErrUnauthorized
+	}
+	return nil
+}
+
+// OIDCEnabled reports whether apps may sign users in with OpenID Connect.
+func (s *OAuthService) OIDCEnabled() bool {
+	return s.cfg.Issuer != "" && s.tokens.CanIssueIDTokens()
+}
+
+// Provider describes the OpenID Connect provider, or returns nil when
+// OpenID Connect is off.
+func (s *OAuthService) Provider() *OIDCProvider {
+	if !s.OIDCEnabled() {
+		return nil
+	}
+	return &OIDCProvider{
+		Issuer:                s.cfg.Issuer,
+		AuthorizationEndpoint: s.cfg.AuthorizationEndpoint,
+		Scopes:                []domain.Scope{domain.ScopeOpenID, domain.ScopeProfile, domain.ScopeEmail, domain.ScopePhone, domain.ScopeOfflineAccess, domain.ScopeAccountsRead},
+		Claims:                []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "email", "email_verified", "phone_number", "phone_number_verified"},
+	}
+}
+
+// JWKS returns the keys apps verify ID tokens with.
+func (s *OAuthService) JWKS() []auth.JWK { return s.tokens.JWKS() }
+
+// UserInfo returns the claims the user released to an app holding a token
+// with scopes (OpenID Connect Core section 5.3). The token's grant has
+// already been checked; a token without openid is domain.ErrInvalidScope.
+func (s *OAuthService) UserInfo(ctx context.Context, userID string, scopes []domain.Scope) (*UserInfo, error) {
+	if !slices.Contains(scopes, domain.ScopeOpenID) {
+		return nil, domain.ErrInvalidScope
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrUnauthorized
+		}
+		return nil, err
+	}
+	if !user.Status.CanLogIn() {
+		return nil, domain.ErrUnauthorized
+	}
+	return userInfo(user, scopes), nil
+}
+
+// ListConsents returns the apps the user has approved.
+func (s *OAuthService) ListConsents(ctx context.Context, userID string) ([]UserConsent, error) {
+	consents, err := s.oauth.ListConsents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UserConsent, 0, len(consents))
+	for _, c := range consents {
+		client, err := s.clients.clients.GetByID(ctx, c.ClientID)
+		if err != nil && !errors.Is(err, domain.ErrAPIClientNotFound) {
+			return nil, err
+		}
+		out = append(out, UserConsent{Consent: c, Client: client})
+	}
+	return out, nil
+}
+
+// RevokeConsent withdraws the user's approval of an app and revokes every
+// grant the app holds for them, so its tokens stop working at once.
+func (s *OAuthService) RevokeConsent(ctx context.Context, userID, consentID string) (*domain.OAuthConsent, error) {
+	consent, err := s.oauth.GetConsentByID(ctx, consentID)
+	if err != nil {
+		return nil, err
+	}
+	if consent.UserID != userID {
+		return nil, domain.ErrConsentNotFound
+	}
+	now := s.now()
+	if consent.Active() {
+		if err := s.oauth.RevokeConsent(ctx, consent.ID, now); err != nil {
+			return nil, err
+		}
+		consent.RevokedAt, consent.UpdatedAt = now, now
+	}
+	if err := s.oauth.RevokeGrants(ctx, userID, consent.ClientID, now); err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// checkUserConsent refuses to issue tokens once the user has withdrawn
+// consent or can no longer log in, and returns the user otherwise.
+func (s *OAuthService) checkUserConsent(ctx context.Context, userID, clientID string, scopes []domain.Scope) (*domain.User, error) {
+	consent, err := s.oauth.GetConsent(ctx, userID, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrConsentNotFound) {
+			return nil, domain.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	if !consent.Covers(scopes) {
+		return nil, domain.ErrInvalidGrant
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	if !user.Status.CanLogIn() {
+		return nil, domain.ErrInvalidGrant
+	}
+	return user, nil
+}
+
+// grantForToken finds the client's grant behind an access or refresh
+// token; it returns nil for any other token.
+func (s *OAuthService) grantForToken(ctx context.Context, client *domain.APIClient, token string) (*domain.OAuthGrant, error) {
+	var grant *domain.OAuthGrant
+	var err error
+	if claims, verr := s.tokens.Verify(token); verr == nil {
+		if !claims.Delegated() || claims.ClientID != client.ID {
+			return nil, nil
+		}
+		grant, err = s.oauth.GetGrant(ctx, claims.GrantID)
+	} else {
+		grant, err = s.oauth.GetGrantByRefresh(ctx, hashAPIKey(token))
+	}
+	if errors.Is(err, domain.ErrGrantNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if grant.ClientID != client.ID {
+		return nil, nil
+	}
+	return grant, nil
+}
+
+// issueAccessToken signs res's access token for the grant's user, carrying
+// the grant ID so CheckGrant can find it and the client's networks so the
+// token is only usable where the client is.
+func (s *OAuthService) issueAccessToken(res *OAuthTokenResult) error {
+	jti, err := randomOAuthToken("")
+	if err != nil {
+		return err
+	}
+	opts := auth.TokenOptions{ID: jti, ClientID: res.Client.ID, Scopes: scopeStrings(res.Scopes), Networks: networkStrings(res.Client), GrantID: res.Grant.ID}
+	res.AccessToken, err = s.tokens.IssueToken(res.Grant.UserID, s.cfg.AccessTokenTTL, opts)
+	res.ExpiresIn = s.cfg.AccessTokenTTL
+	return err
+}
+
+// issueIDToken signs res's ID token (OpenID Connect Core section 2) for the
+// app that exchanged code, with the user claims of the granted scopes. It
+// lives as long as the access token it is issued with.
+func (s *OAuthService) issueIDToken(res *OAuthTokenResult, user *domain.User, code *domain.OAuthCode) error {
+	info := userInfo(user, res.Scopes)
+	claims := auth.IDTokenClaims{Nonce: code.Nonce, AuthorizedParty: res.Client.ID, AccessTokenHash: auth.AccessTokenHash(res.AccessToken), PreferredUsername: info.PreferredUsername, RegisteredClaims: jwt.RegisteredClaims{Issuer: s.cfg.Issuer, Subject: user.ID, Audience: jwt.ClaimStrings{res.Client.ID}}}
+	if !code.AuthTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(code.AuthTime)
+	}
+	if info.Email != "" {
+		claims.Email, claims.EmailVerified = info.Email, &info.EmailVerified
+	}
+	if info.PhoneNumber != "" {
+		claims.PhoneNumber, claims.PhoneNumberVerified = info.PhoneNumber, &info.PhoneNumberVerified
+	}
+	var err error
+	res.IDToken, err = s.tokens.IssueIDToken(claims, s.cfg.AccessTokenTTL)
+	return err
+}
+
+// userInfo maps user to the claims of the OpenID Connect scopes in scopes.
+func userInfo(user *domain.User, scopes []domain.Scope) *UserInfo {
+	info := &UserInfo{Subject: user.ID}
+	if slices.Contains(scopes, domain.ScopeProfile) {
+		info.PreferredUsername = user.UsernameLower
+	}
+	if slices.Contains(scopes, domain.ScopeEmail) {
+		info.Email = user.EmailLower
+	}
+	if slices.Contains(scopes, domain.ScopePhone) {
+		info.PhoneNumber, info.PhoneNumberVerified = user.PhoneE164, user.PhoneVerified()
+	}
+	return info
+}
+
+// verifyPKCE checks verifier against an S256 challenge (RFC 7636 section
+// 4.6).
+func verifyPKCE(verifier, challenge string) bool {
+	if !pkceVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+func randomOAuthToken(prefix string) (string, error) {
+	b := make([]byte, oauthTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,357 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+const testRedirectURI = "https://app.example.com/callback"
+
+// addOAuthApp registers Budget App, a confidential client holding only user
+// scopes, and returns it with its key.
+func addOAuthApp(t *testing.T, clients *APIClientService, adminID string) (*domain.APIClient, string) {
+	t.Helper()
+	app, key, _, err := clients.Create(context.Background(), CreateAPIClientInput{ActorID: adminID, Name: "Budget App", Scopes: []string{"profile", "accounts:read", "offline_access"}, RedirectURIs: []string{testRedirectURI}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, key
+}
+
+func pkcePair(seed string) (string, string) {
+	verifier := strings.Repeat(seed, 43)[:43]
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authorizeCode has userID consent to clientID asking for scope and
+// returns the code.
+func authorizeCode(t *testing.T, svc *OAuthService, userID, clientID, scope, challenge string) string {
+	t.Helper()
+	res, err := svc.Authorize(context.Background(), AuthorizationRequest{UserID: userID, ClientID: clientID, RedirectURI: testRedirectURI, ResponseType: "code", Scope: scope, State: "xyz", CodeChallenge: challenge, CodeChallengeMethod: "S256"}, true)
+	if err != nil || res.Code == "" {
+		t.Fatalf("expected a code, got %#v %v", res, err)
+	}
+	return res.Code
+}
+
+func TestAuthorizationCodeWithPKCE(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	jwtMgr := auth.NewJWTManager("secret", "akiba-api")
+	customer := addUser(t, st.users, domain.User{UsernameLower: "c_one", PhoneE164: "+254700000001"})
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	clients := NewAPIClientService(memory.NewAPIClientRepository(st.Store), st.users, jwtMgr, APIClientConfig{TokenTTL: time.Minute})
+	app, key := addOAuthApp(t, clients, admin)
+	repo := memory.NewOAuthRepository(st.Store)
+	svc := NewOAuthService(repo, clients, st.users, jwtMgr, OAuthConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	verifier, challenge := pkcePair("v")
+	req := AuthorizationRequest{UserID: customer, ClientID: app.ID, RedirectURI: testRedirectURI, ResponseType: "code", Scope: "profile accounts:read", State: "xyz", CodeChallenge: challenge, CodeChallengeMethod: "S256"}
+
+	// Only the client and redirect URI failures come without a preview,
+	// since the app cannot be told about them.
+	for name, mutate := range map[string]func(*AuthorizationRequest){
+		"unknown client": func(r *AuthorizationRequest) { r.ClientID = "cl9" },
+		"other redirect": func(r *AuthorizationRequest) { r.RedirectURI = testRedirectURI + "/../evil" },
+	} {
+		r := req
+		mutate(&r)
+		if p, err := svc.ValidateAuthorization(ctx, r); p != nil || (!errors.Is(err, domain.ErrInvalidClient) && !errors.Is(err, domain.ErrInvalidRedirectURI)) {
+			t.Fatalf("%s: expected refusal without redirect, got %v %v", name, p, err)
+		}
+	}
+	for name, tc := range map[string]struct {
+		mutate func(*AuthorizationRequest)
+		want   error
+	}{
+		"token response":  {func(r *AuthorizationRequest) { r.ResponseType = "token" }, domain.ErrUnsupportedResponse},
+		"plain pkce":      {func(r *AuthorizationRequest) { r.CodeChallengeMethod = "plain" }, domain.ErrInvalidRequest},
+		"no pkce":         {func(r *AuthorizationRequest) { r.CodeChallenge = "" }, domain.ErrInvalidRequest},
+		"client scope":    {func(r *AuthorizationRequest) { r.Scope = "webhooks:read" }, domain.ErrInvalidScope},
+		"ungranted scope": {func(r *AuthorizationRequest) { r.Scope = "profile payments:write" }, domain.ErrInvalidScope},
+	} {
+		r := req
+		tc.mutate(&r)
+		if p, err := svc.ValidateAuthorization(ctx, r); p == nil || !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v with preview, got %v %v", name, tc.want, p, err)
+		}
+	}
+
+	p, err := svc.ValidateAuthorization(ctx, req)
+	if err != nil || p.Consented || p.Client.Name != "Budget App" {
+		t.Fatalf("unexpected preview %#v %v", p, err)
+	}
+	denied, err := svc.Authorize(ctx, req, false)
+	if _, lookupErr := repo.GetConsent(ctx, customer, app.ID); err != nil || denied.Code != "" || !errors.Is(lookupErr, domain.ErrConsentNotFound) {
+		t.Fatalf("expected denial to record nothing, got %#v %v", denied, err)
+	}
+	code := authorizeCode(t, svc, customer, app.ID, req.Scope, challenge)
+	if p, _ := svc.ValidateAuthorization(ctx, req); !p.Consented {
+		t.Fatal("expected the consent remembered")
+	}
+
+	in := CodeExchangeInput{ClientAuth: ClientAuth{ClientID: app.ID, Secret: key}, Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier}
+	wrong := in
+	wrong.CodeVerifier, _ = pkcePair("w")
+	if _, err := svc.ExchangeCode(ctx, wrong); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("expected wrong verifier refused, got %v", err)
+	}
+	// The failed attempt used the code up; a fresh one is needed.
+	in.Code = authorizeCode(t, svc, customer, app.ID, req.Scope, challenge)
+	noSecret := in
+	noSecret.Secret = ""
+	if _, err := svc.ExchangeCode(ctx, noSecret); !errors.Is(err, domain.ErrInvalidClient) {
+		t.Fatalf("expected confidential client without its key refused, got %v", err)
+	}
+	res, err := svc.ExchangeCode(ctx, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := jwtMgr.Verify(res.AccessToken)
+	if err != nil || claims.Sub != customer || !claims.Delegated() || claims.GrantID != res.Grant.ID || claims.Scope != "profile accounts:read" || res.RefreshToken != "" {
+		t.Fatalf("unexpected token %#v %#v %v", res, claims, err)
+	}
+	if err := svc.CheckGrant(ctx, customer, app.ID, res.Grant.ID); err != nil {
+		t.Fatalf("expected the grant to stand, got %v", err)
+	}
+
+	// Replaying the code revokes what it was exchanged for.
+	if _, err := svc.ExchangeCode(ctx, in); !errors.Is(err, domain.ErrTokenReused) {
+		t.Fatalf("expected replay detected, got %v", err)
+	}
+	if err := svc.CheckGrant(ctx, customer, app.ID, res.Grant.ID); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatalf("expected replay to revoke the grant, got %v", err)
+	}
+	if info := introspect(t, svc, ClientAuth{ClientID: app.ID, Secret: key}, res.AccessToken); info.Active {
+		t.Fatal("expected revoked token inactive")
+	}
+}
+
+func introspect(t *testing.T, svc *OAuthService, client ClientAuth, token string) *TokenIntrospection {
+	t.Helper()
+	_, info, err := svc.Introspect(context.Background(), client, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestRefreshRotationRevocationAndConsent(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	jwtMgr := auth.NewJWTManager("secret", "akiba-api")
+	customer := addUser(t, st.users, domain.User{UsernameLower: "c_one", PhoneE164: "+254700000001"})
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	clients := NewAPIClientService(memory.NewAPIClientRepository(st.Store), st.users, jwtMgr, APIClientConfig{TokenTTL: time.Minute})
+	app, key := addOAuthApp(t, clients, admin)
+	svc := NewOAuthService(memory.NewOAuthRepository(st.Store), clients, st.users, jwtMgr, OAuthConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	verifier, challenge := pkcePair("r")
+	code := authorizeCode(t, svc, customer, app.ID, "profile offline_access", challenge)
+	client := ClientAuth{ClientID: app.ID, Secret: key}
+	first, err := svc.ExchangeCode(ctx, CodeExchangeInput{ClientAuth: client, Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier})
+	if err != nil || first.RefreshToken == "" {
+		t.Fatalf("expected a refresh token with offline_access, got %#v %v", first, err)
+	}
+	if info := introspect(t, svc, client, first.RefreshToken); !info.Active || info.TokenType != "refresh_token" || info.Subject != customer {
+		t.Fatalf("unexpected introspection %#v", info)
+	}
+
+	if _, err := svc.Refresh(ctx, RefreshInput{ClientAuth: client, RefreshToken: first.RefreshToken, Scope: "accounts:read"}); !errors.Is(err, domain.ErrInvalidScope) {
+		t.Fatalf("expected widening refused, got %v", err)
+	}
+	second, err := svc.Refresh(ctx, RefreshInput{ClientAuth: client, RefreshToken: first.RefreshToken, Scope: "profile"})
+	if err != nil || second.RefreshToken == first.RefreshToken || second.Grant.ID != first.Grant.ID {
+		t.Fatalf("expected rotation within the grant, got %#v %v", second, err)
+	}
+	if claims, _ := jwtMgr.Verify(second.AccessToken); claims.Scope != "profile" {
+		t.Fatalf("expected narrowed access token, got %q", claims.Scope)
+	}
+	if info := introspect(t, svc, client, first.RefreshToken); info.Active {
+		t.Fatal("expected rotated refresh token inactive")
+	}
+
+	// Reusing the rotated token means it leaked: the whole grant goes.
+	if _, err := svc.Refresh(ctx, RefreshInput{ClientAuth: client, RefreshToken: first.RefreshToken}); !errors.Is(err, domain.ErrTokenReused) {
+		t.Fatalf("expected reuse detected, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, RefreshInput{ClientAuth: client, RefreshToken: second.RefreshToken}); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("expected the live token revoked with its grant, got %v", err)
+	}
+
+	// RFC 7009 revocation of an access token ends its grant.
+	code = authorizeCode(t, svc, customer, app.ID, "profile offline_access", challenge)
+	third, err := svc.ExchangeCode(ctx, CodeExchangeInput{ClientAuth: client, Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, grant, err := svc.Revoke(ctx, client, "not-a-token"); err != nil || grant != nil {
+		t.Fatalf("expected unknown token ignored, got %v %v", grant, err)
+	}
+	if _, grant, err := svc.Revoke(ctx, client, third.AccessToken); err != nil || grant == nil || grant.ID != third.Grant.ID {
+		t.Fatalf("expected grant revoked, got %v %v", grant, err)
+	}
+	if _, err := svc.Refresh(ctx, RefreshInput{ClientAuth: client, RefreshToken: third.RefreshToken}); !errors.Is(err, domain.ErrInvalidGrant) {
+		t.Fatalf("expected revoked grant refused, got %v", err)
+	}
+
+	// Withdrawing consent from /me ends every grant the app holds.
+	code = authorizeCode(t, svc, customer, app.ID, "profile", challenge)
+	fourth, err := svc.ExchangeCode(ctx, CodeExchangeInput{ClientAuth: client, Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consents, err := svc.ListConsents(ctx, customer)
+	if err != nil || len(consents) != 1 || consents[0].Client.Name != "Budget App" || len(consents[0].Consent.Scopes) != 2 {
+		t.Fatalf("unexpected consents %#v %v", consents, err)
+	}
+	if _, err := svc.RevokeConsent(ctx, "c2", consents[0].Consent.ID); !errors.Is(err, domain.ErrConsentNotFound) {
+		t.Fatalf("expected another user's consent hidden, got %v", err)
+	}
+	if _, err := svc.RevokeConsent(ctx, customer, consents[0].Consent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CheckGrant(ctx, customer, app.ID, fourth.Grant.ID); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatalf("expected revoked consent to stop the token, got %v", err)
+	}
+	if consents, _ := svc.ListConsents(ctx, customer); len(consents) != 0 {
+		t.Fatalf("expected no consents left, got %d", len(consents))
+	}
+}
+
+func TestPublicClientsAndDelegatedScopes(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	jwtMgr := auth.NewJWTManager("secret", "akiba-api")
+	customer := addUser(t, st.users, domain.User{UsernameLower: "c_one", PhoneE164: "+254700000001"})
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	clients := NewAPIClientService(memory.NewAPIClientRepository(st.Store), st.users, jwtMgr, APIClientConfig{TokenTTL: time.Minute})
+	app, key := addOAuthApp(t, clients, admin)
+	svc := NewOAuthService(memory.NewOAuthRepository(st.Store), clients, st.users, jwtMgr, OAuthConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+
+	_, _, fields, err := clients.Create(ctx, CreateAPIClientInput{ActorID: admin, Name: "Mobile", Public: true, Scopes: []string{"profile", "webhooks:read"}, AllowedNetworks: []string{"10.0.0.0/8"}, RedirectURIs: []string{"http://evil.example/cb"}})
+	if !errors.Is(err, domain.ErrInvalidInput) || fields["scopes"] == "" || fields["allowedNetworks"] == "" || fields["redirectUris"] == "" {
+		t.Fatalf("expected public client limits enforced, got %v %v", fields, err)
+	}
+	mobile, mobileKey, _, err := clients.Create(ctx, CreateAPIClientInput{ActorID: admin, Name: "Mobile", Public: true, Scopes: []string{"profile"}, RedirectURIs: []string{"com.example.mobile:/oauth", "http://127.0.0.1:8400/cb"}})
+	if err != nil || mobileKey != "" || len(mobile.Keys) != 0 {
+		t.Fatalf("expected a public client without keys, got %#v %q %v", mobile, mobileKey, err)
+	}
+	if _, err := clients.IssueToken(ctx, ClientCredentialsInput{ClientID: mobile.ID}); !errors.Is(err, domain.ErrInvalidClient) {
+		t.Fatalf("expected public client refused client credentials, got %v", err)
+	}
+	// The confidential app holds only user scopes, so it has nothing to
+	// ask for by itself.
+	if _, err := clients.IssueToken(ctx, ClientCredentialsInput{ClientID: app.ID, Secret: key}); !errors.Is(err, domain.ErrInvalidScope) {
+		t.Fatalf("expected client credentials without client scopes refused, got %v", err)
+	}
+
+	verifier, challenge := pkcePair("m")
+	res, err := svc.Authorize(ctx, AuthorizationRequest{UserID: customer, ClientID: mobile.ID, RedirectURI: "com.example.mobile:/oauth", ResponseType: "code", Scope: "profile", CodeChallenge: challenge, CodeChallengeMethod: "S256"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := svc.ExchangeCode(ctx, CodeExchangeInput{ClientAuth: ClientAuth{ClientID: mobile.ID}, Code: res.Code, RedirectURI: "com.example.mobile:/oauth", CodeVerifier: verifier})
+	if err != nil || tok.AccessToken == "" {
+		t.Fatalf("expected a public client to exchange with PKCE alone, got %v", err)
+	}
+
+	// Suspending the user stops tokens already issued.
+	setStatus(t, st.users, customer, domain.UserStatusSuspended)
+	if err := svc.CheckGrant(ctx, customer, mobile.ID, tok.Grant.ID); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatalf("expected suspended user's grant refused, got %v", err)
+	}
+}
+
+func TestOpenIDConnectSignIn(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	jwtMgr := auth.NewJWTManager("secret", "akiba-api")
+	customer := addUser(t, st.users, domain.User{UsernameLower: "c_one", PhoneE164: "+254700000001"})
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	clients := NewAPIClientService(memory.NewAPIClientRepository(st.Store), st.users, jwtMgr, APIClientConfig{TokenTTL: time.Minute})
+	app, key := addOAuthApp(t, clients, admin)
+	repo := memory.NewOAuthRepository(st.Store)
+	svc := NewOAuthService(repo, clients, st.users, jwtMgr, OAuthConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	const issuer = "https://id.akiba.example"
+	sister, sisterKey, _, err := clients.Create(ctx, CreateAPIClientInput{ActorID: admin, Name: "Sister App", Scopes: []string{"openid", "profile", "email", "phone"}, RedirectURIs: []string{testRedirectURI}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, challenge := pkcePair("o")
+	req := AuthorizationRequest{UserID: customer, ClientID: sister.ID, RedirectURI: testRedirectURI, ResponseType: "code", Scope: "openid email phone", State: "xyz", CodeChallenge: challenge, CodeChallengeMethod: "S256", Nonce: "n-0S6", AuthTime: time.Now().Add(-time.Minute).Truncate(time.Second)}
+
+	// Without an issuer and signing key OpenID Connect is off.
+	if _, err := svc.ValidateAuthorization(ctx, req); !errors.Is(err, domain.ErrInvalidScope) {
+		t.Fatalf("expected openid refused while disabled, got %v", err)
+	}
+	signingKey, err := auth.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtMgr.SetIDTokenKey(signingKey)
+	svc = NewOAuthService(repo, clients, st.users, jwtMgr, OAuthConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour, Issuer: issuer})
+	for name, tc := range map[string]struct {
+		scope, nonce string
+		want         error
+	}{
+		"email without openid": {"profile email", "", domain.ErrInvalidScope},
+		"long nonce":           {"openid", strings.Repeat("n", 256), domain.ErrInvalidRequest},
+	} {
+		r := req
+		r.Scope, r.Nonce = tc.scope, tc.nonce
+		if _, err := svc.ValidateAuthorization(ctx, r); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+
+	res, err := svc.Authorize(ctx, req, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := svc.ExchangeCode(ctx, CodeExchangeInput{ClientAuth: ClientAuth{ClientID: sister.ID, Secret: sisterKey}, Code: res.Code, RedirectURI: testRedirectURI, CodeVerifier: verifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, err := jwtMgr.VerifyIDToken(tokens.IDToken, issuer, sister.ID)
+	if err != nil {
+		t.Fatalf("id token does not verify: %v", err)
+	}
+	if idToken.Subject != customer || idToken.Nonce != "n-0S6" || idToken.AuthTime == nil || !idToken.AuthTime.Equal(req.AuthTime) || idToken.AccessTokenHash != auth.AccessTokenHash(tokens.AccessToken) {
+		t.Fatalf("unexpected id token claims %#v", idToken)
+	}
+	// Email is never claimed verified; the phone is not until an OTP sent
+	// to it was confirmed. profile was not requested.
+	if idToken.Email != "c_one@example.com" || idToken.EmailVerified == nil || *idToken.EmailVerified || idToken.PhoneNumber != "+254700000001" || idToken.PhoneNumberVerified == nil || *idToken.PhoneNumberVerified || idToken.PreferredUsername != "" {
+		t.Fatalf("unexpected user claims %#v", idToken)
+	}
+
+	if err := st.users.MarkPhoneVerified(ctx, customer, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := svc.UserInfo(ctx, customer, tokens.Scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Subject != customer || info.Email != "c_one@example.com" || !info.PhoneNumberVerified || info.PreferredUsername != "" {
+		t.Fatalf("unexpected userinfo %#v", info)
+	}
+	if _, err := svc.UserInfo(ctx, customer, []domain.Scope{domain.ScopeProfile}); !errors.Is(err, domain.ErrInvalidScope) {
+		t.Fatalf("expected userinfo without openid refused, got %v", err)
+	}
+
+	// Codes issued without openid get no ID token.
+	plainVerifier, plainChallenge := pkcePair("p")
+	plain, err := svc.ExchangeCode(ctx, CodeExchangeInput{ClientAuth: ClientAuth{ClientID: app.ID, Secret: key}, Code: authorizeCode(t, svc, customer, app.ID, "profile", plainChallenge), RedirectURI: testRedirectURI, CodeVerifier: plainVerifier})
+	if err != nil || plain.IDToken != "" {
+		t.Fatalf("expected no id token without openid, got %q %v", plain.IDToken, err)
+	}
+}
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/events"
+	"akiba/backend/internal/repository"
+)
+
+const (
+	defaultOutboxBatch       = 100
+	defaultOutboxMaxAttempts = 12
+	outboxRetryBase          = time.Second
+	outboxRetryMax           = 10 * time.Minute
+	maxOutboxErrorLength     = 500
+)
+
+type OutboxConfig struct {
+	BatchSize int
+	// MaxAttempts is how many failed publishes move an event to the
+	// dead-letter store.
+	MaxAttempts int
+}
+
+// OutboxRelayResult counts what one RelayDue call did.
+type OutboxRelayResult struct {
+	Published    int
+	Failed       int
+	DeadLettered int
+}
+
+// OutboxRelay publishes outbox events in per-aggregate order: an event goes
+// out only once every earlier event of its aggregate has been published or
+// dead-lettered. Failures back off exponentially. Delivery is at least once.
+type OutboxRelay struct {
+	outbox    repository.OutboxRepository
+	publisher events.EventPublisher
+	cfg       OutboxConfig
+	now       func() time.Time
+}
+
+func NewOutboxRelay(outbox repository.OutboxRepository, publisher events.EventPublisher, cfg OutboxConfig) *OutboxRelay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultOutboxBatch
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	return &OutboxRelay{outbox: outbox, publisher: publisher, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// RelayDue publishes due events until a batch makes no progress.
+func (r *OutboxRelay) RelayDue(ctx context.Context) (OutboxRelayResult, error) {
+	var total OutboxRelayResult
+	for {
+		batch, err := r.outbox.ListDue(ctx, r.now(), r.cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		res, err := r.relayBatch(ctx, batch)
+		total.Published += res.Published
+		total.Failed += res.Failed
+		total.DeadLettered += res.DeadLettered
+		if err != nil || len(batch) < r.cfg.BatchSize || res.Published+res.DeadLettered == 0 {
+			return total, err
+		}
+	}
+}
+
+func (r *OutboxRelay) relayBatch(ctx context.Context, batch []*domain.OutboxEvent) (OutboxRelayResult, error) {
+	var res OutboxRelayResult
+	var keys []string
+	groups := map[string][]*domain.OutboxEvent{}
+	for _, e := range batch {
+		key := e.AggregateKey()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], e)
+	}
+	for _, key := range keys {
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool { return group[i].Seq < group[j].Seq })
+		for i, e := range group {
+			// Consecutive events need no check; a gap may hide an earlier
+			// event that is waiting to retry or missed this batch.
+			if i == 0 || e.Seq != group[i-1].Seq+1 {
+				blocked, err := r.outbox.PendingBefore(ctx, e)
+				if err != nil {
+					return res, err
+				}
+				if blocked {
+					break
+				}
+			}
+			ok, err := r.publish(ctx, e, &res)
+			if err != nil {
+				return res, err
+			}
+			if !ok {
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// publish sends one event and records the outcome. It reports whether later
+// events of the aggregate may go out.
+func (r *OutboxRelay) publish(ctx context.Context, e *domain.OutboxEvent, res *OutboxRelayResult) (bool, error) {
+	pubErr := r.publisher.Publish(ctx, e)
+	now := r.now()
+	if pubErr == nil {
+		res.Published++
+		return true, r.outbox.MarkPublished(ctx, e.ID, now)
+	}
+	e.Attempts++
+	e.LastError = pubErr.Error()
+	if len(e.LastError) > maxOutboxErrorLength {
+		e.LastError = e.LastError[:maxOutboxErrorLength]
+	}
+	if e.Attempts >= r.cfg.MaxAttempts {
+		e.DeadAt = now
+		res.DeadLettered++
+		return true, r.outbox.DeadLetter(ctx, e)
+	}
+	res.Failed++
+	return false, r.outbox.MarkFailed(ctx, e.ID, e.Attempts, e.LastError, now.Add(outboxBackoff(e.Attempts)))
+}
+
+// outboxBackoff doubles from outboxRetryBase after each failure, up to
+// outboxRetryMax.
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxRetryBase
+	for i := 1; i < attempts && d < outboxRetryMax; i++ {
+		d *= 2
+	}
+	if d > outboxRetryMax {
+		d = outboxRetryMax
+	}
+	return d
+}
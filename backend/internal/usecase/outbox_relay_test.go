@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/events"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+// recordingOutbox keeps a copy of what the relay dead-letters, which the
+// memory outbox does not expose.
+type recordingOutbox struct {
+	*memory.OutboxRepository
+	dead []*domain.OutboxEvent
+}
+
+func (o *recordingOutbox) DeadLetter(ctx context.Context, event *domain.OutboxEvent) error {
+	err := o.OutboxRepository.DeadLetter(ctx, event)
+	if err == nil {
+		o.dead = append(o.dead, event)
+	}
+	return err
+}
+
+// pending lists the outbox events still awaiting publication, in write order.
+func pending(t *testing.T, outbox *memory.OutboxRepository) []*domain.OutboxEvent {
+	t.Helper()
+	due, err := outbox.ListDue(context.Background(), time.Now().AddDate(1, 0, 0), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return due
+}
+
+// flakyPublisher fails every event of the listed aggregates.
+type flakyPublisher struct {
+	*events.MemoryPublisher
+	failing map[string]bool
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, e *domain.OutboxEvent) error {
+	if p.failing[e.AggregateKey()] {
+		return errors.New("broker unavailable")
+	}
+	return p.MemoryPublisher.Publish(ctx, e)
+}
+
+func publishedIDs(p *events.MemoryPublisher) []string {
+	var out []string
+	for _, e := range p.Envelopes() {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestOutboxRelayKeepsAggregateOrderAcrossRetries(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	outbox, users := memory.NewOutboxRepository(s), memory.NewUserRepository(s)
+	alice := addUser(t, users, domain.User{UsernameLower: "alice", Status: domain.UserStatusPendingVerification})
+	addUser(t, users, domain.User{UsernameLower: "bob"})
+	setStatus(t, users, alice, domain.UserStatusActive)
+	written := pending(t, outbox)
+	if len(written) != 3 {
+		t.Fatalf("expected three events, got %d", len(written))
+	}
+	a1, b1, a2 := written[0], written[1], written[2]
+	now := a2.NextAttemptAt
+	if a2.Seq != 2 || a2.Type != domain.EventUserStatusChanged || a2.AggregateKey() != a1.AggregateKey() {
+		t.Fatalf("unexpected event %#v", a2)
+	}
+
+	pub := &flakyPublisher{MemoryPublisher: events.NewMemoryPublisher(), failing: map[string]bool{a1.AggregateKey(): true}}
+	relay := NewOutboxRelay(outbox, pub, OutboxConfig{MaxAttempts: 3})
+	relay.now = func() time.Time { return now }
+
+	res, err := relay.RelayDue(ctx)
+	if err != nil || res.Published != 1 || res.Failed != 1 {
+		t.Fatalf("unexpected first pass %#v %v", res, err)
+	}
+	if got := publishedIDs(pub.MemoryPublisher); len(got) != 1 || got[0] != b1.ID {
+		t.Fatalf("expected only bob's event, got %v", got)
+	}
+	if e := pending(t, outbox)[0]; e.ID != a1.ID || e.Attempts != 1 || e.NextAttemptAt != now.Add(time.Second) {
+		t.Fatalf("expected retry scheduled, got %#v", e)
+	}
+
+	// Still backing off: the failed event is not due and its successor
+	// must wait behind it.
+	if res, _ := relay.RelayDue(ctx); res.Published+res.Failed != 0 {
+		t.Fatalf("expected nothing due, got %#v", res)
+	}
+
+	delete(pub.failing, a1.AggregateKey())
+	now = now.Add(time.Second)
+	if res, err := relay.RelayDue(ctx); err != nil || res.Published != 2 {
+		t.Fatalf("unexpected retry pass %#v %v", res, err)
+	}
+	got := publishedIDs(pub.MemoryPublisher)
+	if len(got) != 3 || got[1] != a1.ID || got[2] != a2.ID {
+		t.Fatalf("expected alice's events in order after bob's, got %v", got)
+	}
+}
+
+func TestOutboxRelayDeadLettersAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	s := memory.NewStore()
+	outbox, ledger := &recordingOutbox{OutboxRepository: memory.NewOutboxRepository(s)}, memory.NewLedgerRepository(s)
+	src, _ := ledger.GetOrCreateAccount(ctx, domain.SystemOwnerPrefix+"funding", domain.AccountTypeSystem, "KES")
+	dst, _ := ledger.GetOrCreateAccount(ctx, domain.SystemOwnerPrefix+"float", domain.AccountTypeSystem, "KES")
+	entry := &domain.JournalEntry{Kind: domain.EntryKindTransfer, Postings: []domain.Posting{{AccountID: src.ID, Amount: -100, Currency: "KES"}, {AccountID: dst.ID, Amount: 100, Currency: "KES"}}, CreatedAt: now}
+	if err := ledger.Post(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Post(ctx, &domain.JournalEntry{Kind: domain.EntryKindReversal, ReversalOf: entry.ID, Postings: []domain.Posting{{AccountID: dst.ID, Amount: -100, Currency: "KES"}, {AccountID: src.ID, Amount: 100, Currency: "KES"}}, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	written := pending(t, outbox.OutboxRepository)
+	posted, reversed := written[0], written[1]
+	if posted.Type != "transfer.completed" || reversed.AggregateID != entry.ID || reversed.Seq != 2 {
+		t.Fatalf("reversal not ordered with its entry: %#v", reversed)
+	}
+
+	pub := events.NewMemoryPublisher()
+	relay := NewOutboxRelay(outbox, publisherFunc(func(ctx context.Context, e *domain.OutboxEvent) error {
+		if e.ID == posted.ID {
+			return errors.New("consumer rejected payload")
+		}
+		return pub.Publish(ctx, e)
+	}), OutboxConfig{MaxAttempts: 3})
+	var waits []time.Duration
+	for i := 0; i < 3; i++ {
+		relay.now = func() time.Time { return now }
+		if _, err := relay.RelayDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if left := pending(t, outbox.OutboxRepository); len(left) > 0 && left[0].ID == posted.ID {
+			waits = append(waits, left[0].NextAttemptAt.Sub(now))
+			now = left[0].NextAttemptAt
+		}
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 2*time.Second {
+		t.Fatalf("expected exponential backoff, got %v", waits)
+	}
+	if len(outbox.dead) != 1 || outbox.dead[0].ID != posted.ID || outbox.dead[0].Attempts != 3 || outbox.dead[0].LastError != "consumer rejected payload" {
+		t.Fatalf("expected event dead-lettered, got %#v", outbox.dead)
+	}
+	if got := publishedIDs(pub); len(got) != 1 || got[0] != reversed.ID {
+		t.Fatalf("expected the dead letter to unblock its aggregate, got %v", got)
+	}
+}
+
+func TestOutboxBackoffIsCapped(t *testing.T) {
+	got := []time.Duration{outboxBackoff(1), outboxBackoff(4), outboxBackoff(30)}
+	want := []time.Duration{time.Second, 8 * time.Second, outboxRetryMax}
+	if got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("unexpected backoff %v", got)
+	}
+}
+
+type publisherFunc func(ctx context.Context, e *domain.OutboxEvent) error
+
+func (f publisherFunc) Publish(ctx context.Context, e *domain.OutboxEvent) error { return f(ctx, e) }
@@ -0,0 +1,317 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/webauthn"
+)
+
+const (
+	defaultPasskeyName = "Passkey"
+	maxPasskeyNameLen  = 64
+)
+
+// PasskeyConfig identifies the relying party and how long a ceremony
+// challenge stays valid.
+type PasskeyConfig struct {
+	WebAuthn     webauthn.Config
+	ChallengeTTL time.Duration
+}
+
+// RegistrationOptions feed navigator.credentials.create(). UserHandle is the
+// WebAuthn user.id; ExcludeCredentials lists the user's existing passkeys so
+// an authenticator is not registered twice.
+type RegistrationOptions struct {
+	ChallengeID        string
+	Challenge          []byte
+	RPID               string
+	RPName             string
+	UserHandle         []byte
+	UserName           string
+	Algorithms         []int64
+	ExcludeCredentials []string
+	UserVerification   string
+	Timeout            time.Duration
+}
+
+// LoginOptions feed navigator.credentials.get() for a usernameless login:
+// the authenticator offers its discoverable credentials for RPID.
+type LoginOptions struct {
+	ChallengeID      string
+	Challenge        []byte
+	RPID             string
+	UserVerification string
+	Timeout          time.Duration
+}
+
+// FinishRegistrationInput carries the create() response; binary fields are
+// base64url encoded as returned by PublicKeyCredential.toJSON().
+type FinishRegistrationInput struct {
+	UserID            string
+	ChallengeID       string
+	Name              string
+	ClientDataJSON    string
+	AttestationObject string
+}
+
+// PasskeyLoginInput carries the get() response, base64url encoded.
+// UserHandle is optional; when present it must match the credential owner.
+// Device names the installation signing in, as for LoginInput.
+type PasskeyLoginInput struct {
+	ChallengeID       string
+	CredentialID      string
+	ClientDataJSON    string
+	AuthenticatorData string
+	Signature         string
+	UserHandle        string
+	Device            *DeviceInput
+}
+
+// PasskeyService registers passkeys and verifies passkey logins. Tokens are
+// issued by AuthService.LoginWithPasskey.
+type PasskeyService struct {
+	users    repository.UserRepository
+	passkeys repository.PasskeyRepository
+	cfg      PasskeyConfig
+	now      func() time.Time
+}
+
+func NewPasskeyService(users repository.UserRepository, passkeys repository.PasskeyRepository, cfg PasskeyConfig) *PasskeyService {
+	return &PasskeyService{users: users, passkeys: passkeys, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// BeginRegistration issues a registration challenge for the signed-in user.
+// Callers must have checked for a fresh authentication.
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID string) (*RegistrationOptions, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.passkeys.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.challenge(ctx, user.ID, domain.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]string, 0, len(existing))
+	for _, p := range existing {
+		exclude = append(exclude, p.CredentialID)
+	}
+	return &RegistrationOptions{
+		ChallengeID:        challenge.ID,
+		Challenge:          challenge.Challenge,
+		RPID:               s.cfg.WebAuthn.RPID,
+		RPName:             s.cfg.WebAuthn.RPName,
+		UserHandle:         []byte(user.ID),
+		UserName:           user.UsernameLower,
+		Algorithms:         webauthn.SupportedAlgorithms,
+		ExcludeCredentials: exclude,
+		UserVerification:   s.userVerification(),
+		Timeout:            s.cfg.ChallengeTTL,
+	}, nil
+}
+
+// FinishRegistration verifies the attestation and stores the passkey.
+func (s *PasskeyService) FinishRegistration(ctx context.Context, in FinishRegistrationInput) (*domain.Passkey, domain.FieldErrors, error) {
+	fields := domain.FieldErrors{}
+	if strings.TrimSpace(in.ChallengeID) == "" {
+		fields["challengeId"] = "is required"
+	}
+	clientData := decodeBase64URL(fields, "clientDataJSON", in.ClientDataJSON)
+	attestation := decodeBase64URL(fields, "attestationObject", in.AttestationObject)
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	} else if utf8.RuneCountInString(name) > maxPasskeyNameLen {
+		fields["name"] = "must be at most 64 characters"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	user, err := s.user(ctx, in.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	challenge, err := s.consume(ctx, in.ChallengeID, domain.WebAuthnRegistration)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge.UserID != user.ID {
+		return nil, nil, domain.ErrChallengeNotFound
+	}
+	cred, err := s.cfg.WebAuthn.VerifyRegistration(challenge.Challenge, clientData, attestation)
+	if err != nil {
+		return nil, nil, domain.ErrPasskeyInvalid
+	}
+	now := s.now()
+	passkey := &domain.Passkey{
+		UserID:         user.ID,
+		CredentialID:   base64.RawURLEncoding.EncodeToString(cred.ID),
+		PublicKey:      cred.PublicKey,
+		Algorithm:      cred.Algorithm,
+		SignCount:      cred.SignCount,
+		AAGUID:         cred.AAGUID,
+		Attestation:    cred.AttestationType,
+		BackupEligible: cred.BackupEligible,
+		Name:           name,
+		CreatedAt:      now,
+	}
+	if err := s.passkeys.Create(ctx, passkey); err != nil {
+		return nil, nil, err
+	}
+	return passkey, nil, nil
+}
+
+// BeginLogin issues a login challenge not bound to any user.
+func (s *PasskeyService) BeginLogin(ctx context.Context) (*LoginOptions, error) {
+	challenge, err := s.challenge(ctx, "", domain.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginOptions{ChallengeID: challenge.ID, Challenge: challenge.Challenge, RPID: s.cfg.WebAuthn.RPID, UserVerification: s.userVerification(), Timeout: s.cfg.ChallengeTTL}, nil
+}
+
+func (s *PasskeyService) List(ctx context.Context, userID string) ([]*domain.Passkey, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.passkeys.ListByUser(ctx, user.ID)
+}
+
+func (s *PasskeyService) Delete(ctx context.Context, userID, passkeyID string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.passkeys.Delete(ctx, user.ID, passkeyID)
+}
+
+// verifyLogin checks an assertion and returns the credential owner. Unknown
+// credentials and bad signatures are indistinguishable to the caller; a
+// counter that did not advance fails with ErrPasskeyInvalid because the
+// credential may have been cloned.
+func (s *PasskeyService) verifyLogin(ctx context.Context, in PasskeyLoginInput) (*domain.User, domain.FieldErrors, error) {
+	fields := domain.FieldErrors{}
+	if strings.TrimSpace(in.ChallengeID) == "" {
+		fields["challengeId"] = "is required"
+	}
+	decodeBase64URL(fields, "credentialId", in.CredentialID)
+	clientData := decodeBase64URL(fields, "clientDataJSON", in.ClientDataJSON)
+	authData := decodeBase64URL(fields, "authenticatorData", in.AuthenticatorData)
+	signature := decodeBase64URL(fields, "signature", in.Signature)
+	var userHandle []byte
+	if in.UserHandle != "" {
+		userHandle = decodeBase64URL(fields, "userHandle", in.UserHandle)
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	challenge, err := s.consume(ctx, in.ChallengeID, domain.WebAuthnLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+	passkey, err := s.passkeys.GetByCredentialID(ctx, strings.TrimRight(in.CredentialID, "="))
+	if errors.Is(err, domain.ErrPasskeyNotFound) {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if userHandle != nil && string(userHandle) != passkey.UserID {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	count, err := s.cfg.WebAuthn.VerifyAssertion(challenge.Challenge, clientData, authData, signature, passkey.PublicKey, passkey.SignCount)
+	if errors.Is(err, webauthn.ErrSignCount) {
+		return nil, nil, domain.ErrPasskeyInvalid
+	}
+	if err != nil {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	user, err := s.users.GetByID(ctx, passkey.UserID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Status.CanLogIn() {
+		return nil, nil, domain.ErrInvalidCredentials
+	}
+	if err := s.passkeys.UpdateSignCount(ctx, passkey.ID, passkey.SignCount, count, s.now()); err != nil {
+		return nil, nil, err
+	}
+	return user, nil, nil
+}
+
+func (s *PasskeyService) challenge(ctx context.Context, userID string, purpose domain.WebAuthnPurpose) (*domain.WebAuthnChallenge, error) {
+	raw, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	challenge := &domain.WebAuthnChallenge{UserID: userID, Purpose: purpose, Challenge: raw, ExpiresAt: now.Add(s.cfg.ChallengeTTL), CreatedAt: now}
+	if err := s.passkeys.CreateChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consume takes the challenge out of storage, so a failed attempt needs a
+// new one.
+func (s *PasskeyService) consume(ctx context.Context, id string, purpose domain.WebAuthnPurpose) (*domain.WebAuthnChallenge, error) {
+	challenge, err := s.passkeys.ConsumeChallenge(ctx, id, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(challenge.ExpiresAt) {
+		return nil, domain.ErrChallengeNotFound
+	}
+	return challenge, nil
+}
+
+func (s *PasskeyService) userVerification() string {
+	if s.cfg.WebAuthn.RequireUserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+func (s *PasskeyService) user(ctx context.Context, userID string) (*domain.User, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Status.CanLogIn() {
+		return nil, domain.ErrForbidden
+	}
+	return user, nil
+}
+
+// decodeBase64URL decodes a required base64url field, padded or not,
+// recording a field error when it is missing or malformed.
+func decodeBase64URL(fields domain.FieldErrors, name, value string) []byte {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	if value == "" {
+		fields[name] = "is required"
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		fields[name] = "must be base64url encoded"
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/webauthn"
+	"akiba/backend/internal/webauthn/webauthntest"
+)
+
+var b64 = base64.RawURLEncoding.EncodeToString
+
+// newPasskeyFixture returns the passkey and auth services on a memory
+// store, the passkey store, an authenticator and the ID of its user.
+func newPasskeyFixture(t *testing.T) (*PasskeyService, *AuthService, *memory.PasskeyRepository, *webauthntest.Authenticator, string) {
+	t.Helper()
+	s := memory.NewStore()
+	users, store := memory.NewUserRepository(s), memory.NewPasskeyRepository(s)
+	u1 := addUser(t, users, domain.User{UsernameLower: "wanjiku"})
+	cfg := PasskeyConfig{WebAuthn: webauthn.Config{RPID: "localhost", RPName: "Akiba", Origins: []string{"http://localhost:3000"}, RequireUserVerification: true}, ChallengeTTL: 5 * time.Minute}
+	passkeys := NewPasskeyService(users, store, cfg)
+	authSvc := NewAuthService(users, auth.NewJWTManager("secret", "test"), time.Hour, testStepUp, nil, passkeys, nil, nil)
+	a, err := webauthntest.New("localhost", "http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passkeys, authSvc, store, a, u1
+}
+
+func registerPasskey(t *testing.T, svc *PasskeyService, a *webauthntest.Authenticator, u1 string) *domain.Passkey {
+	t.Helper()
+	ctx := context.Background()
+	opts, err := svc.BeginRegistration(ctx, u1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, attObj, err := a.Register(opts.Challenge, webauthntest.FormatPackedSelf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := svc.FinishRegistration(ctx, FinishRegistrationInput{UserID: u1, ChallengeID: opts.ChallengeID, Name: "Phone", ClientDataJSON: b64(clientData), AttestationObject: b64(attObj)})
+	if err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+	return p
+}
+
+func passkeyLogin(t *testing.T, svc *PasskeyService, a *webauthntest.Authenticator, u1 string) PasskeyLoginInput {
+	t.Helper()
+	opts, err := svc.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, authData, sig, err := a.Assert(opts.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return PasskeyLoginInput{ChallengeID: opts.ChallengeID, CredentialID: b64(a.CredentialID), ClientDataJSON: b64(clientData), AuthenticatorData: b64(authData), Signature: b64(sig), UserHandle: b64([]byte(u1))}
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, authSvc, store, a, u1 := newPasskeyFixture(t)
+	p := registerPasskey(t, svc, a, u1)
+	if p.UserID != u1 || p.Name != "Phone" || p.Attestation != webauthn.AttestationSelf || p.CredentialID != b64(a.CredentialID) {
+		t.Fatalf("unexpected passkey %#v", p)
+	}
+
+	opts, err := svc.BeginRegistration(ctx, u1)
+	if err != nil || !slices.Equal(opts.ExcludeCredentials, []string{p.CredentialID}) {
+		t.Fatalf("expected existing passkey excluded, got %v %v", opts, err)
+	}
+
+	res, _, err := authSvc.LoginWithPasskey(ctx, passkeyLogin(t, svc, a, u1))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	claims, err := auth.NewJWTManager("secret", "test").Verify(res.AccessToken)
+	if err != nil || res.User.ID != u1 || !slices.Equal(claims.AMR, []string{auth.MethodHardwareKey}) {
+		t.Fatalf("expected hwk token for u1, got %v %v", claims, err)
+	}
+	if stored, _ := store.GetByCredentialID(ctx, p.CredentialID); stored.SignCount != 1 || stored.LastUsedAt.IsZero() {
+		t.Fatalf("expected sign count and last use recorded, got %#v", stored)
+	}
+}
+
+func TestPasskeyLoginChecksTheDevice(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	users, deviceStore := memory.NewUserRepository(s), memory.NewDeviceRepository(s)
+	u1 := addUser(t, users, domain.User{UsernameLower: "wanjiku", PhoneE164: "+254700000001"})
+	passkeys := NewPasskeyService(users, memory.NewPasskeyRepository(s), PasskeyConfig{WebAuthn: webauthn.Config{RPID: "localhost", RPName: "Akiba", Origins: []string{"http://localhost:3000"}, RequireUserVerification: true}, ChallengeTTL: 5 * time.Minute})
+	sender := &memSender{}
+	devices := NewDeviceService(users, deviceStore, sender, DeviceConfig{OTPTTL: 5 * time.Minute, OTPMaxAttempts: 3})
+	jwtMgr := auth.NewJWTManager("secret", "test")
+	authSvc := NewAuthService(users, jwtMgr, time.Hour, testStepUp, nil, passkeys, devices, nil)
+	a, err := webauthntest.New("localhost", "http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registerPasskey(t, passkeys, a, u1)
+	phone := newDeviceInput(t, "phone-0001", "push-phone")
+	if err := devices.trust(ctx, u1, devices.parse(phone, domain.FieldErrors{})); err != nil {
+		t.Fatal(err)
+	}
+
+	in := passkeyLogin(t, passkeys, a, u1)
+	in.Device = newDeviceInput(t, "laptop-0001", "")
+	res, _, err := authSvc.LoginWithPasskey(ctx, in)
+	if err != nil || res.OTPChallengeID == "" || res.AccessToken != "" {
+		t.Fatalf("expected an OTP challenge for a new device, got %#v %v", res, err)
+	}
+	if len(sender.codes) != 1 || !slices.Equal(sender.pushed, []string{"push-phone"}) {
+		t.Fatalf("expected one code and a push to the phone, got %v %v", sender.codes, sender.pushed)
+	}
+	done, _, err := authSvc.VerifyLoginOTP(ctx, VerifyLoginOTPInput{ChallengeID: res.OTPChallengeID, Code: sender.codes[0]})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	claims, err := jwtMgr.Verify(done.AccessToken)
+	if err != nil || claims.Device == nil || claims.Device.ID != "laptop-0001" || !slices.Equal(claims.AMR, []string{auth.MethodHardwareKey, auth.MethodOTP}) {
+		t.Fatalf("expected a hwk+otp token bound to the laptop, got %#v %v", claims, err)
+	}
+
+	in = passkeyLogin(t, passkeys, a, u1)
+	in.Device = phone
+	res, _, err = authSvc.LoginWithPasskey(ctx, in)
+	if err != nil || res.AccessToken == "" {
+		t.Fatalf("expected direct login from a trusted device, got %#v %v", res, err)
+	}
+	if claims, err := jwtMgr.Verify(res.AccessToken); err != nil || claims.Device == nil || claims.Device.ID != "phone-0001" {
+		t.Fatalf("expected token bound to the phone, got %#v %v", claims, err)
+	}
+}
+
+func TestPasskeyLoginRejections(t *testing.T) {
+	ctx := context.Background()
+	svc, authSvc, _, a, u1 := newPasskeyFixture(t)
+	registerPasskey(t, svc, a, u1)
+
+	in := passkeyLogin(t, svc, a, u1)
+	if _, _, err := authSvc.LoginWithPasskey(ctx, in); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, _, err := authSvc.LoginWithPasskey(ctx, in); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("expected replayed challenge rejected, got %v", err)
+	}
+
+	// A clone that kept an older counter is refused even with a valid
+	// signature.
+	a.SignCount = 0
+	if _, _, err := authSvc.LoginWithPasskey(ctx, passkeyLogin(t, svc, a, u1)); !errors.Is(err, domain.ErrPasskeyInvalid) {
+		t.Fatalf("expected sign count rejection, got %v", err)
+	}
+	a.SignCount = 10
+
+	in = passkeyLogin(t, svc, a, u1)
+	in.UserHandle = b64([]byte("someone-else"))
+	if _, _, err := authSvc.LoginWithPasskey(ctx, in); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected user handle mismatch rejected, got %v", err)
+	}
+
+	stranger, err := webauthntest.New("localhost", "http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := authSvc.LoginWithPasskey(ctx, passkeyLogin(t, svc, stranger, u1)); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected unknown credential rejected, got %v", err)
+	}
+
+	in = passkeyLogin(t, svc, a, u1)
+	in.Signature = "***"
+	if _, fields, err := authSvc.LoginWithPasskey(ctx, in); !errors.Is(err, domain.ErrInvalidInput) || fields["signature"] == "" {
+		t.Fatalf("expected malformed signature rejected, got %v", err)
+	}
+}
+
+func TestPasskeyRegistrationChallengeIsBoundToUser(t *testing.T) {
+	ctx := context.Background()
+	svc, _, store, a, u1 := newPasskeyFixture(t)
+	other := &domain.WebAuthnChallenge{UserID: "someone-else", Purpose: domain.WebAuthnRegistration, Challenge: []byte("challenge"), ExpiresAt: time.Now().Add(time.Minute)}
+	if err := store.CreateChallenge(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	clientData, attObj, err := a.Register([]byte("challenge"), webauthntest.FormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.FinishRegistration(ctx, FinishRegistrationInput{UserID: u1, ChallengeID: other.ID, ClientDataJSON: b64(clientData), AttestationObject: b64(attObj)}); !errors.Is(err, domain.ErrChallengeNotFound) {
+		t.Fatalf("expected another user's challenge rejected, got %v", err)
+	}
+
+	opts, err := svc.BeginRegistration(ctx, u1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Origin = "https://evil.example"
+	clientData, attObj, err = a.Register(opts.Challenge, webauthntest.FormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.FinishRegistration(ctx, FinishRegistrationInput{UserID: u1, ChallengeID: opts.ChallengeID, ClientDataJSON: b64(clientData), AttestationObject: b64(attObj)}); !errors.Is(err, domain.ErrPasskeyInvalid) {
+		t.Fatalf("expected wrong origin rejected, got %v", err)
+	}
+	if stored, _ := store.ListByUser(ctx, u1); len(stored) != 0 {
+		t.Fatalf("no passkey should be stored, got %d", len(stored))
+	}
+}
@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"context"
+
+	"akiba/backend/internal/repository"
+)
+
+const defaultReencryptBatch = 100
+
+// PIIReencryption moves stored personal data onto the current encryption
+// key after a rotation, and seals any still kept in plaintext. Once it
+// reports nothing left to do, the old key can be removed from the key
+// file.
+type PIIReencryption struct {
+	users repository.PIIReencrypter
+	batch int
+}
+
+func NewPIIReencryption(users repository.PIIReencrypter, batch int) *PIIReencryption {
+	if batch <= 0 {
+		batch = defaultReencryptBatch
+	}
+	return &PIIReencryption{users: users, batch: batch}
+}
+
+// Run re-seals batches until one comes back short and returns how many
+// users it updated.
+func (j *PIIReencryption) Run(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := j.users.ReencryptPII(ctx, j.batch)
+		total += n
+		if err != nil || n < j.batch {
+			return total, err
+		}
+	}
+}
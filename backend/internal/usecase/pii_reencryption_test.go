@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeReencrypter struct {
+	pending int
+	calls   int
+	err     error
+}
+
+func (f *fakeReencrypter) ReencryptPII(ctx context.Context, limit int) (int, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := min(limit, f.pending)
+	f.pending -= n
+	return n, nil
+}
+
+func TestPIIReencryptionRunsUntilDone(t *testing.T) {
+	users := &fakeReencrypter{pending: 25}
+	n, err := NewPIIReencryption(users, 10).Run(context.Background())
+	if err != nil || n != 25 || users.pending != 0 || users.calls != 3 {
+		t.Fatalf("got %d %v after %d calls, %d pending", n, err, users.calls, users.pending)
+	}
+	users = &fakeReencrypter{pending: 20}
+	if n, _ := NewPIIReencryption(users, 10).Run(context.Background()); n != 20 || users.calls != 3 {
+		t.Fatalf("expected a final empty batch to end the run, got %d after %d calls", n, users.calls)
+	}
+	failing := &fakeReencrypter{pending: 5, err: errors.New("boom")}
+	if _, err := NewPIIReencryption(failing, 10).Run(context.Background()); err == nil || failing.calls != 1 {
+		t.Fatalf("expected the error to stop the run, got %v after %d calls", err, failing.calls)
+	}
+}
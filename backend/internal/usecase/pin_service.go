@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const pinRules = "must be 4-6 digits and not repeated or sequential"
+
+// PINConfig sets how many consecutive wrong PINs lock PIN entry, and for how
+// long. Failures are only cleared by a correct PIN or a reset, so the first
+// wrong PIN after a lockout expires locks again.
+type PINConfig struct {
+	MaxAttempts int
+	Lockout     time.Duration
+}
+
+type SetPINInput struct {
+	UserID string
+	PIN    string
+}
+type ChangePINInput struct {
+	UserID     string
+	CurrentPIN string
+	NewPIN     string
+}
+
+// PINService manages the transaction PIN that confirms payments. PINs are
+// stored as bcrypt hashes and never returned or logged.
+type PINService struct {
+	users repository.UserRepository
+	cfg   PINConfig
+	now   func() time.Time
+}
+
+func NewPINService(users repository.UserRepository, cfg PINConfig) *PINService {
+	return &PINService{users: users, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Set stores the user's first PIN. Callers must have checked for a fresh
+// password authentication.
+func (s *PINService) Set(ctx context.Context, in SetPINInput) (domain.FieldErrors, error) {
+	if !domain.ValidatePIN(in.PIN) {
+		return domain.FieldErrors{"pin": pinRules}, domain.ErrInvalidInput
+	}
+	user, err := s.user(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.HasPIN() {
+		return nil, domain.ErrPINAlreadySet
+	}
+	return nil, s.store(ctx, user.ID, in.PIN)
+}
+
+// Change replaces the PIN after verifying the current one, which counts
+// towards the lockout like any other attempt.
+func (s *PINService) Change(ctx context.Context, in ChangePINInput) (domain.FieldErrors, error) {
+	fields := domain.FieldErrors{}
+	if in.CurrentPIN == "" {
+		fields["currentPin"] = "is required"
+	}
+	if !domain.ValidatePIN(in.NewPIN) {
+		fields["newPin"] = pinRules
+	} else if in.NewPIN == in.CurrentPIN {
+		fields["newPin"] = "must differ from the current PIN"
+	}
+	if len(fields) > 0 {
+		return fields, domain.ErrInvalidInput
+	}
+	user, err := s.user(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasPIN() {
+		return nil, domain.ErrPINNotSet
+	}
+	if err := s.check(ctx, user, in.CurrentPIN); err != nil {
+		return nil, err
+	}
+	return nil, s.store(ctx, user.ID, in.NewPIN)
+}
+
+// Reset replaces a forgotten PIN and lifts any lockout. Callers must have
+// checked for a fresh password authentication.
+func (s *PINService) Reset(ctx context.Context, in SetPINInput) (domain.FieldErrors, error) {
+	if !domain.ValidatePIN(in.PIN) {
+		return domain.FieldErrors{"newPin": pinRules}, domain.ErrInvalidInput
+	}
+	user, err := s.user(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.store(ctx, user.ID, in.PIN)
+}
+
+// Verify checks pin against the user's PIN, counting failures and locking
+// PIN entry once MaxAttempts is reached.
+func (s *PINService) Verify(ctx context.Context, userID, pin string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.HasPIN() {
+		return domain.ErrPINNotSet
+	}
+	return s.check(ctx, user, pin)
+}
+
+// verifyPayment confirms a payment. Users who have not set a PIN yet are
+// not asked for one; once set, it is required.
+func (s *PINService) verifyPayment(ctx context.Context, userID, pin string) (domain.FieldErrors, error) {
+	if s == nil {
+		return nil, nil
+	}
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasPIN() {
+		return nil, nil
+	}
+	if strings.TrimSpace(pin) == "" {
+		return domain.FieldErrors{"pin": "is required"}, domain.ErrInvalidInput
+	}
+	return nil, s.check(ctx, user, pin)
+}
+
+// check claims an attempt before comparing, so concurrent guesses cannot
+// get past MaxAttempts between reading the count and recording a failure.
+func (s *PINService) check(ctx context.Context, user *domain.User, pin string) error {
+	now := s.now()
+	attempts, err := s.users.ClaimPINAttempt(ctx, user.ID, s.cfg.MaxAttempts, now)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PINHash), []byte(pin)) == nil {
+		return s.users.ResetPINFailures(ctx, user.ID)
+	}
+	if attempts >= s.cfg.MaxAttempts {
+		if err := s.users.LockPIN(ctx, user.ID, now.Add(s.cfg.Lockout)); err != nil {
+			return err
+		}
+		return domain.ErrPINLocked
+	}
+	return domain.ErrInvalidPIN
+}
+
+func (s *PINService) store(ctx context.Context, userID, pin string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.users.SetPIN(ctx, userID, string(hash), s.now())
+}
+
+func (s *PINService) user(ctx context.Context, userID string) (*domain.User, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	return s.users.GetByID(ctx, userID)
+}
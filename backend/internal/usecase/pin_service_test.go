@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+func TestPINLocksAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewUserRepository(memory.NewStore())
+	u1 := addUser(t, users, domain.User{UsernameLower: "user_1"})
+	svc := NewPINService(users, PINConfig{MaxAttempts: 3, Lockout: time.Hour})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	if fields, err := svc.Set(ctx, SetPINInput{UserID: u1, PIN: "1234"}); !errors.Is(err, domain.ErrInvalidInput) || fields["pin"] == "" {
+		t.Fatalf("expected sequential PIN rejected, got %v", err)
+	}
+	if _, err := svc.Set(ctx, SetPINInput{UserID: u1, PIN: "4827"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if getUser(t, users, u1).PINHash == "4827" {
+		t.Fatalf("PIN stored in clear")
+	}
+	if _, err := svc.Set(ctx, SetPINInput{UserID: u1, PIN: "5931"}); !errors.Is(err, domain.ErrPINAlreadySet) {
+		t.Fatalf("expected already set, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := svc.Verify(ctx, u1, "0000"); !errors.Is(err, domain.ErrInvalidPIN) {
+			t.Fatalf("attempt %d: expected invalid PIN, got %v", i+1, err)
+		}
+	}
+	if err := svc.Verify(ctx, u1, "0000"); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("expected lockout on third failure, got %v", err)
+	}
+	if err := svc.Verify(ctx, u1, "4827"); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("correct PIN must not pass while locked, got %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := svc.Verify(ctx, u1, "0000"); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("a failure after the lockout must lock again, got %v", err)
+	}
+	if _, err := svc.Reset(ctx, SetPINInput{UserID: u1, PIN: "5931"}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := svc.Verify(ctx, u1, "5931"); err != nil || getUser(t, users, u1).PINFailures != 0 {
+		t.Fatalf("expected reset to clear the lockout, got %v", err)
+	}
+}
+
+func TestConcurrentWrongPINsCannotPassMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewUserRepository(memory.NewStore())
+	u1 := addUser(t, users, domain.User{UsernameLower: "user_1"})
+	svc := NewPINService(users, PINConfig{MaxAttempts: 3, Lockout: time.Hour})
+	if _, err := svc.Set(ctx, SetPINInput{UserID: u1, PIN: "4827"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	errs := make(chan error, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- svc.Verify(ctx, u1, "0000")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	invalid := 0
+	for err := range errs {
+		switch {
+		case errors.Is(err, domain.ErrInvalidPIN):
+			invalid++
+		case !errors.Is(err, domain.ErrPINLocked):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if invalid != 2 {
+		t.Fatalf("expected only MaxAttempts guesses compared, got %d wrong PINs before the lock", invalid)
+	}
+	if err := svc.Verify(ctx, u1, "4827"); !errors.Is(err, domain.ErrPINLocked) {
+		t.Fatalf("correct PIN must not pass while locked, got %v", err)
+	}
+}
+
+func TestTransferRequiresPINOnceSet(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	u1 := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	pins := NewPINService(st.users, PINConfig{MaxAttempts: 5, Lockout: time.Hour})
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, pins)
+	fund(t, st.ledger, u1, "KES", 10000)
+	if _, _, err := svc.Transfer(ctx, TransferInput{SenderID: u1, Recipient: "bob", Amount: 1000, Currency: "KES"}); err != nil {
+		t.Fatalf("transfer without a PIN set: %v", err)
+	}
+	if _, err := pins.Set(ctx, SetPINInput{UserID: u1, PIN: "4827"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, fields, err := svc.Transfer(ctx, TransferInput{SenderID: u1, Recipient: "bob", Amount: 1000, Currency: "KES"}); !errors.Is(err, domain.ErrInvalidInput) || fields["pin"] == "" {
+		t.Fatalf("expected PIN required, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := svc.Withdraw(ctx, WithdrawalInput{UserID: u1, Amount: 1000, Currency: "KES", Phone: "+254700000001", PIN: "4828"}); !errors.Is(err, domain.ErrInvalidPIN) {
+		t.Fatalf("expected wrong PIN rejected, got %v", err)
+	}
+	if _, _, err := svc.Transfer(ctx, TransferInput{SenderID: u1, Recipient: "bob", Amount: 1000, Currency: "KES", PIN: "4827"}); err != nil {
+		t.Fatalf("transfer with PIN: %v", err)
+	}
+	sender, _ := st.ledger.GetOrCreateAccount(ctx, u1, domain.AccountTypeWallet, "KES")
+	if sender.Balance != 7800 {
+		t.Fatalf("expected two transfers with fees, balance=%d", sender.Balance)
+	}
+}
+
+func TestMerchantPaymentsRequirePINOnceSet(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	payer := addUser(t, st.users, domain.User{UsernameLower: "payer"})
+	pins := NewPINService(st.users, PINConfig{MaxAttempts: 5, Lockout: time.Hour})
+	merchants := NewMerchantService(st.users, st.merchants, st.ledger, pins)
+	merchant := newTestMerchant(t, merchants)
+	holds := NewHoldService(st.users, st.merchants, st.ledger, time.Hour, pins)
+	qr, _, err := merchants.GenerateQR(ctx, GenerateQRInput{OwnerID: merchant.OwnerID, MerchantID: merchant.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fund(t, st.ledger, payer, "KES", 10000)
+	if _, err := pins.Set(ctx, SetPINInput{UserID: payer, PIN: "4827"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	if _, fields, err := merchants.PayQR(ctx, PayQRInput{PayerID: payer, Payload: qr.Payload, Amount: 500}); !errors.Is(err, domain.ErrInvalidInput) || fields["pin"] == "" {
+		t.Fatalf("expected PIN required for a QR payment, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := merchants.PayQR(ctx, PayQRInput{PayerID: payer, Payload: qr.Payload, Amount: 500, PIN: "4828"}); !errors.Is(err, domain.ErrInvalidPIN) {
+		t.Fatalf("expected wrong PIN rejected for a QR payment, got %v", err)
+	}
+	if _, _, err := merchants.PayQR(ctx, PayQRInput{PayerID: payer, Payload: qr.Payload, Amount: 500, PIN: "4827"}); err != nil {
+		t.Fatalf("QR payment with PIN: %v", err)
+	}
+	if _, fields, err := holds.AuthorizeMerchantPayment(ctx, AuthorizePaymentInput{PayerID: payer, MerchantID: merchant.ID, Amount: 500}); !errors.Is(err, domain.ErrInvalidInput) || fields["pin"] == "" {
+		t.Fatalf("expected PIN required for an authorization, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := holds.AuthorizeMerchantPayment(ctx, AuthorizePaymentInput{PayerID: payer, MerchantID: merchant.ID, Amount: 500, PIN: "4828"}); !errors.Is(err, domain.ErrInvalidPIN) {
+		t.Fatalf("expected wrong PIN rejected for an authorization, got %v", err)
+	}
+	if _, _, err := holds.AuthorizeMerchantPayment(ctx, AuthorizePaymentInput{PayerID: payer, MerchantID: merchant.ID, Amount: 500, PIN: "4827"}); err != nil {
+		t.Fatalf("authorization with PIN: %v", err)
+	}
+	wallet, _ := st.ledger.GetOrCreateAccount(ctx, payer, domain.AccountTypeWallet, "KES")
+	if wallet.Balance != 9500 || wallet.Available() != 9000 {
+		t.Fatalf("expected one payment and one hold, got %+v", wallet)
+	}
+}
@@ -0,0 +1,264 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+const maxReasonLength = 500
+
+type ReverseInput struct {
+	ActorID string
+	EntryID string
+	Reason  string
+}
+type RefundInput struct {
+	ActorID string
+	EntryID string
+	Amount  int64
+	Reason  string
+}
+
+var disputableKinds = map[domain.EntryKind]bool{
+	domain.EntryKindQRPayment:       true,
+	domain.EntryKindMerchantPayment: true,
+	domain.EntryKindTransfer:        true,
+}
+
+// entryParties identifies who paid whom in a customer payment: the
+// non-house account debited and the non-house account credited. Fee legs
+// to house accounts are not part of the principal.
+type entryParties struct {
+	payer        *domain.Account
+	payee        *domain.Account
+	payeeOwnerID string
+	amount       int64
+	currency     string
+}
+
+// refundState summarises the reversal entries already posted against an
+// original entry.
+type refundState struct {
+	count    int
+	refunded int64
+	reversed bool
+}
+
+type ReversalService struct {
+	users     repository.UserRepository
+	merchants repository.MerchantRepository
+	ledger    repository.LedgerRepository
+	disputes  repository.DisputeRepository
+}
+
+func NewReversalService(users repository.UserRepository, merchants repository.MerchantRepository, ledger repository.LedgerRepository, disputes repository.DisputeRepository) *ReversalService {
+	return &ReversalService{users: users, merchants: merchants, ledger: ledger, disputes: disputes}
+}
+
+// Reverse posts the exact negation of a customer payment, fee legs
+// included. Only operators may reverse, and only payments with nothing
+// refunded yet. Internal entries (dispute holds and releases, conversions,
+// withdrawals, closure payouts) are settled by their own workflows:
+// reversing a dispute hold, say, would return money a later resolution
+// pays out of suspense again.
+func (s *ReversalService) Reverse(ctx context.Context, in ReverseInput) (*domain.JournalEntry, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermPaymentsOperate); err != nil {
+		return nil, nil, err
+	}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		return nil, domain.FieldErrors{"reason": "must be 1-500 characters"}, domain.ErrInvalidInput
+	}
+	orig, err := s.ledger.GetEntry(ctx, in.EntryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if orig.ReversalOf != "" || !disputableKinds[orig.Kind] {
+		return nil, nil, domain.ErrNotReversible
+	}
+	if err := s.ensureNotDisputed(ctx, orig.ID); err != nil {
+		return nil, nil, err
+	}
+	state, err := s.refundState(ctx, orig.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state.count > 0 {
+		return nil, nil, domain.ErrNotReversible
+	}
+	postings := make([]domain.Posting, 0, len(orig.Postings))
+	for _, p := range orig.Postings {
+		postings = append(postings, domain.Posting{AccountID: p.AccountID, Amount: -p.Amount, Currency: p.Currency})
+	}
+	entry := &domain.JournalEntry{
+		Kind:       domain.EntryKindReversal,
+		Reference:  reversalReference(orig.ID, state.count),
+		ReversalOf: orig.ID,
+		Postings:   postings,
+		Metadata:   map[string]string{"actorId": in.ActorID, "reason": reason},
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.ledger.Post(ctx, entry); err != nil {
+		return nil, nil, concurrentReversal(err)
+	}
+	return entry, nil, nil
+}
+
+// Refund returns part or all of a payment's principal from the payee to the
+// payer. The payee (merchant owner or transfer recipient) or an operator may
+// refund; fees are not returned.
+func (s *ReversalService) Refund(ctx context.Context, in RefundInput) (*domain.JournalEntry, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.ActorID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	fields := domain.FieldErrors{}
+	reason := strings.TrimSpace(in.Reason)
+	if problem := domain.AmountProblem(in.Amount); problem != "" {
+		fields["amount"] = problem
+	}
+	if len(reason) > maxReasonLength {
+		fields["reason"] = "must be at most 500 characters"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	orig, parties, err := s.payment(ctx, in.EntryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parties.payeeOwnerID != in.ActorID {
+		if err := requirePermission(ctx, s.users, in.ActorID, domain.PermPaymentsOperate); err != nil {
+			// Non-parties learn nothing about the entry.
+			return nil, nil, domain.ErrEntryNotFound
+		}
+	}
+	if err := s.ensureNotDisputed(ctx, orig.ID); err != nil {
+		return nil, nil, err
+	}
+	state, err := s.refundState(ctx, orig.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state.reversed {
+		return nil, nil, domain.ErrNotReversible
+	}
+	if remaining := parties.amount - state.refunded; in.Amount > remaining {
+		return nil, domain.FieldErrors{"amount": fmt.Sprintf("must not exceed the refundable %d", remaining)}, domain.ErrInvalidInput
+	}
+	entry, err := s.postRefund(ctx, orig, parties.payee.ID, parties, in.Amount, reversalReference(orig.ID, state.count), map[string]string{"actorId": in.ActorID, "reason": reason})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, nil, nil
+}
+
+// payment loads an entry and its parties, refusing entries that are not a
+// customer-to-customer or customer-to-merchant payment.
+func (s *ReversalService) payment(ctx context.Context, entryID string) (*domain.JournalEntry, *entryParties, error) {
+	orig, err := s.ledger.GetEntry(ctx, entryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if orig.ReversalOf != "" || !disputableKinds[orig.Kind] {
+		return nil, nil, domain.ErrNotReversible
+	}
+	parties := &entryParties{}
+	for _, p := range orig.Postings {
+		account, err := s.ledger.GetAccount(ctx, p.AccountID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if account.Type == domain.AccountTypeSystem {
+			continue
+		}
+		if p.Amount < 0 {
+			parties.payer = account
+		} else {
+			parties.payee, parties.amount, parties.currency = account, p.Amount, p.Currency
+		}
+	}
+	if parties.payer == nil || parties.payee == nil {
+		return nil, nil, domain.ErrNotReversible
+	}
+	parties.payeeOwnerID = parties.payee.OwnerID
+	if parties.payee.Type == domain.AccountTypeMerchant {
+		merchant, err := s.merchants.GetByID(ctx, parties.payee.OwnerID)
+		if err != nil {
+			return nil, nil, err
+		}
+		parties.payeeOwnerID = merchant.OwnerID
+	}
+	return orig, parties, nil
+}
+
+// postRefund credits amount back to the payer from fromAccountID (the payee,
+// or suspense when resolving a dispute) under reference.
+func (s *ReversalService) postRefund(ctx context.Context, orig *domain.JournalEntry, fromAccountID string, parties *entryParties, amount int64, reference string, meta map[string]string) (*domain.JournalEntry, error) {
+	entry := &domain.JournalEntry{
+		Kind:       domain.EntryKindRefund,
+		Reference:  reference,
+		ReversalOf: orig.ID,
+		Postings: []domain.Posting{
+			{AccountID: fromAccountID, Amount: -amount, Currency: parties.currency},
+			{AccountID: parties.payer.ID, Amount: amount, Currency: parties.currency},
+		},
+		Metadata:  meta,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.ledger.Post(ctx, entry); err != nil {
+		return nil, concurrentReversal(err)
+	}
+	return entry, nil
+}
+
+func (s *ReversalService) refundState(ctx context.Context, entryID string) (refundState, error) {
+	reversals, err := s.ledger.ListReversals(ctx, entryID)
+	if err != nil {
+		return refundState{}, err
+	}
+	state := refundState{count: len(reversals)}
+	for _, r := range reversals {
+		switch r.Kind {
+		case domain.EntryKindReversal:
+			state.reversed = true
+		case domain.EntryKindRefund:
+			for _, p := range r.Postings {
+				if p.Amount > 0 {
+					state.refunded += p.Amount
+				}
+			}
+		}
+	}
+	return state, nil
+}
+
+func (s *ReversalService) ensureNotDisputed(ctx context.Context, entryID string) error {
+	_, err := s.disputes.GetActiveByEntry(ctx, entryID)
+	if err == nil {
+		return domain.ErrDisputeExists
+	}
+	if errors.Is(err, domain.ErrDisputeNotFound) {
+		return nil
+	}
+	return err
+}
+
+// reversalReference numbers every reversal and refund of an entry. Two
+// concurrent attempts computed from the same state collide on the ledger's
+// unique reference, so refunds can never exceed the original.
+func reversalReference(entryID string, priorCount int) string {
+	return fmt.Sprintf("reversal:%s:%d", entryID, priorCount+1)
+}
+
+func concurrentReversal(err error) error {
+	if errors.Is(err, domain.ErrDuplicateEntry) {
+		return domain.ErrNotReversible
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/risk"
+)
+
+const maxRiskDecisions = 200
+
+// paymentKinds are the entries that count as the user paying someone for
+// history and velocity.
+var paymentKinds = map[domain.EntryKind]bool{
+	domain.EntryKindTransfer:        true,
+	domain.EntryKindWithdrawal:      true,
+	domain.EntryKindQRPayment:       true,
+	domain.EntryKindMerchantPayment: true,
+}
+
+// RiskInput describes a payment about to be authorized. SteppedUp is set
+// when the user has just re-authenticated.
+type RiskInput struct {
+	UserID      string
+	DeviceID    string
+	RecipientID string
+	Amount      int64
+	Currency    string
+	SteppedUp   bool
+}
+
+// RiskService scores payments before they are authorized and keeps every
+// decision, with its factors, for review by support and admin.
+type RiskService struct {
+	users     repository.UserRepository
+	decisions repository.RiskRepository
+	ledger    repository.LedgerRepository
+	cfg       *risk.Config
+	now       func() time.Time
+}
+
+func NewRiskService(users repository.UserRepository, decisions repository.RiskRepository, ledger repository.LedgerRepository, cfg *risk.Config) *RiskService {
+	return &RiskService{users: users, decisions: decisions, ledger: ledger, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// assessPayment scores a payment from the user's ledger history and stores
+// the decision before returning it.
+func (s *RiskService) assessPayment(ctx context.Context, action string, in RiskInput) (*domain.RiskDecision, error) {
+	now := s.now()
+	inputs := risk.Inputs{Amount: in.Amount, At: now}
+	if in.DeviceID != "" {
+		known, err := s.decisions.HasAllowedDevice(ctx, in.UserID, in.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		inputs.KnownDevice = known
+	}
+	paidRecipient, err := s.loadHistory(ctx, in, now, &inputs)
+	if err != nil {
+		return nil, err
+	}
+	// Whether the recipient is new comes from what the user has paid it,
+	// not from whether it is saved as a beneficiary.
+	inputs.NewBeneficiary = in.RecipientID != "" && !paidRecipient
+
+	assessment := s.cfg.Assess(inputs)
+	decision := &domain.RiskDecision{
+		UserID:      in.UserID,
+		Action:      action,
+		Amount:      in.Amount,
+		Currency:    in.Currency,
+		DeviceID:    in.DeviceID,
+		RecipientID: in.RecipientID,
+		Score:       assessment.Score,
+		Decision:    string(assessment.Decision),
+		SteppedUp:   in.SteppedUp && assessment.Decision == risk.DecisionStepUp,
+		CreatedAt:   now,
+	}
+	for _, f := range assessment.Factors {
+		decision.Factors = append(decision.Factors, domain.RiskFactor{Signal: string(f.Signal), Value: f.Value, Weight: f.Weight, Contribution: f.Contribution, Detail: f.Detail})
+	}
+	if err := s.decisions.Create(ctx, decision); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// loadHistory fills the user's earlier payment amounts and recent velocity
+// from the wallet in the payment currency, and reports whether the user has
+// paid in.RecipientID within the history window.
+func (s *RiskService) loadHistory(ctx context.Context, in RiskInput, now time.Time, inputs *risk.Inputs) (bool, error) {
+	accounts, err := s.ledger.ListAccountsByOwner(ctx, in.UserID)
+	if err != nil {
+		return false, err
+	}
+	var wallet *domain.Account
+	for _, a := range accounts {
+		if a.Type == domain.AccountTypeWallet && a.Currency == in.Currency {
+			wallet = a
+		}
+	}
+	if wallet == nil {
+		return false, nil
+	}
+	entries, err := s.ledger.ListEntriesByAccount(ctx, wallet.ID, now.Add(-s.cfg.HistoryWindow()), now)
+	if err != nil {
+		return false, err
+	}
+	paidRecipient := false
+	recent := now.Add(-s.cfg.VelocityWindow())
+	for _, e := range entries {
+		amount := -e.AmountFor(wallet.ID)
+		if !paymentKinds[e.Kind] || amount <= 0 {
+			continue
+		}
+		// Compare principals: the fee is charged on top.
+		fee, _ := strconv.ParseInt(e.Metadata["fee"], 10, 64)
+		inputs.History = append(inputs.History, amount-fee)
+		if e.CreatedAt.After(recent) {
+			inputs.RecentPayments++
+		}
+		if in.RecipientID != "" && e.Metadata["recipientId"] == in.RecipientID {
+			paidRecipient = true
+		}
+	}
+	return paidRecipient, nil
+}
+
+func (s *RiskService) List(ctx context.Context, actorID, userID, decision string) ([]*domain.RiskDecision, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermRiskRead); err != nil {
+		return nil, err
+	}
+	return s.decisions.List(ctx, strings.TrimSpace(userID), strings.TrimSpace(decision), maxRiskDecisions)
+}
+
+func (s *RiskService) Get(ctx context.Context, actorID, id string) (*domain.RiskDecision, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermRiskRead); err != nil {
+		return nil, err
+	}
+	return s.decisions.GetByID(ctx, id)
+}
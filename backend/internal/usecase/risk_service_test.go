@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/risk"
+)
+
+// newTestRiskService scores transfers with time of day switched off so the
+// outcome does not depend on when the test runs.
+func newTestRiskService(st testStore, decisions *memory.RiskRepository) *RiskService {
+	cfg := risk.DefaultConfig()
+	delete(cfg.Weights, risk.SignalTimeOfDay)
+	svc := NewRiskService(st.users, decisions, st.ledger, cfg)
+	svc.now = func() time.Time { return time.Now().UTC().Add(time.Minute) }
+	return svc
+}
+
+// latestDecision returns userID's most recent risk decision.
+func latestDecision(t *testing.T, decisions *memory.RiskRepository, userID string) *domain.RiskDecision {
+	t.Helper()
+	listed, err := decisions.List(context.Background(), userID, "", 1)
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("expected a stored decision, got %v %v", listed, err)
+	}
+	return listed[0]
+}
+
+func TestTransferFromNewDeviceToNewRecipientStepsUp(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice, bob := addUser(t, st.users, domain.User{UsernameLower: "alice"}), addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	ops := addUser(t, st.users, domain.User{UsernameLower: "ops", Role: domain.UserRoleSupport})
+	decisions := memory.NewRiskRepository(st.Store)
+	svc := newTestRiskService(st, decisions)
+	scored := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), nil, nil, svc, nil)
+	fund(t, st.ledger, alice, "KES", 100000)
+	_, _, err := scored.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "bob", Amount: 5000, Currency: "KES", DeviceID: "d1"})
+	if !errors.Is(err, domain.ErrStepUpRequired) {
+		t.Fatalf("expected step-up, got %v", err)
+	}
+	sender, _ := st.ledger.GetOrCreateAccount(ctx, alice, domain.AccountTypeWallet, "KES")
+	if sender.Balance != 100000 {
+		t.Fatalf("stepped-up transfer must not move funds, balance=%d", sender.Balance)
+	}
+	if stored, _ := decisions.List(ctx, "", "", 10); len(stored) != 1 {
+		t.Fatalf("expected one stored decision, got %d", len(stored))
+	}
+	d := latestDecision(t, decisions, alice)
+	if d.Decision != string(risk.DecisionStepUp) || d.Score != 58 || d.RecipientID != bob || d.DeviceID != "d1" || len(d.Factors) != 4 {
+		t.Fatalf("unexpected decision: %#v", d)
+	}
+	if _, err := svc.List(ctx, alice, "", ""); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customers barred from decisions, got %v", err)
+	}
+	listed, err := svc.List(ctx, ops, alice, "step_up")
+	if err != nil || len(listed) != 1 || listed[0].ID != d.ID {
+		t.Fatalf("unexpected listing: %v %#v", err, listed)
+	}
+	if _, _, err := scored.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "bob", Amount: 5000, Currency: "KES", DeviceID: "d1", SteppedUp: true}); err != nil {
+		t.Fatalf("stepped-up transfer: %v", err)
+	}
+	if d := latestDecision(t, decisions, alice); d.Decision != string(risk.DecisionStepUp) || !d.SteppedUp {
+		t.Fatalf("expected satisfied step-up, got %#v", d)
+	}
+	if known, _ := decisions.HasAllowedDevice(ctx, alice, "d1"); !known {
+		t.Fatalf("device should be known after a satisfied step-up")
+	}
+}
+
+func TestTransferFromKnownDeviceToPaidRecipientAllowed(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	decisions := memory.NewRiskRepository(st.Store)
+	plain := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), nil, nil, nil, nil)
+	scored := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), nil, nil, newTestRiskService(st, decisions), nil)
+	fund(t, st.ledger, alice, "KES", 100000)
+	for i := 0; i < 3; i++ {
+		if _, _, err := plain.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "bob", Amount: 5000, Currency: "KES"}); err != nil {
+			t.Fatalf("seed transfer: %v", err)
+		}
+	}
+	if err := decisions.Create(ctx, &domain.RiskDecision{UserID: alice, DeviceID: "d1", Decision: string(risk.DecisionAllow), CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := scored.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "bob", Amount: 5000, Currency: "KES", DeviceID: "d1"})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	d := latestDecision(t, decisions, alice)
+	// Only velocity counts: three payments in the last hour.
+	if d.Decision != string(risk.DecisionAllow) || d.Score != 6 || res.Entry.Metadata["riskDecisionId"] != d.ID {
+		t.Fatalf("unexpected decision %#v for entry %#v", d, res.Entry.Metadata)
+	}
+}
+
+func TestNewBeneficiarySignalComesFromPaymentHistory(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob"})
+	decisions := memory.NewRiskRepository(st.Store)
+	plain := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), nil, nil, nil, nil)
+	fund(t, st.ledger, alice, "KES", 100000)
+	if _, _, err := plain.Transfer(ctx, TransferInput{SenderID: alice, Recipient: "bob", Amount: 5000, Currency: "KES"}); err != nil {
+		t.Fatalf("seed transfer: %v", err)
+	}
+	beneficiaries := NewBeneficiaryService(memory.NewBeneficiaryRepository(st.Store), st.users, st.ledger, BeneficiaryConfig{CoolingOff: 24 * time.Hour, CoolingOffLimit: 1000000})
+	b, _, err := beneficiaries.Create(ctx, CreateBeneficiaryInput{OwnerID: alice, Type: "akiba_user", Nickname: "Bob", Recipient: "bob"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	scored := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), nil, beneficiaries, newTestRiskService(st, decisions), nil)
+	if _, _, err := scored.Transfer(ctx, TransferInput{SenderID: alice, BeneficiaryID: b.ID, Amount: 5000, Currency: "KES", DeviceID: "d1", SteppedUp: true}); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	// Bob was only just saved, but alice has paid him before.
+	d := latestDecision(t, decisions, alice)
+	i := slices.IndexFunc(d.Factors, func(f domain.RiskFactor) bool { return f.Signal == string(risk.SignalNewBeneficiary) })
+	if i < 0 || d.Factors[i].Value != 0 {
+		t.Fatalf("expected an established recipient, got %#v", d.Factors)
+	}
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// TOTPConfig names the issuer authenticator apps list the account under and
+// sets how many consecutive wrong codes lock TOTP entry, and for how long.
+type TOTPConfig struct {
+	Issuer      string
+	MaxAttempts int
+	Lockout     time.Duration
+}
+
+// TOTPEnrollment is a new secret and the otpauth URI an authenticator app
+// scans to add it.
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// TOTPService manages authenticator app codes (RFC 6238) as a second
+// factor for step-up. A secret only counts once a code from it has been
+// confirmed, and each code is accepted once.
+type TOTPService struct {
+	users repository.UserRepository
+	cfg   TOTPConfig
+	now   func() time.Time
+}
+
+func NewTOTPService(users repository.UserRepository, cfg TOTPConfig) *TOTPService {
+	return &TOTPService{users: users, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Enroll starts enrolling a new secret, replacing one not yet confirmed.
+// Callers must have checked for a fresh authentication.
+func (s *TOTPService) Enroll(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.HasTOTP() {
+		return nil, domain.ErrTOTPAlreadyEnrolled
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetTOTP(ctx, user.ID, secret, s.now()); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: auth.TOTPKeyURI(s.cfg.Issuer, user.EmailLower, secret)}, nil
+}
+
+// Confirm finishes enrolling once code matches the new secret.
+func (s *TOTPService) Confirm(ctx context.Context, userID, code string) (domain.FieldErrors, error) {
+	if strings.TrimSpace(code) == "" {
+		return domain.FieldErrors{"code": "is required"}, domain.ErrInvalidInput
+	}
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.HasTOTP() {
+		return nil, domain.ErrTOTPAlreadyEnrolled
+	}
+	if user.TOTPSecret == "" {
+		return nil, domain.ErrTOTPNotEnrolled
+	}
+	return nil, s.check(ctx, user, code)
+}
+
+// Disable removes the user's secret. Callers must have checked for a fresh
+// authentication.
+func (s *TOTPService) Disable(ctx context.Context, userID string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPSecret == "" {
+		return domain.ErrTOTPNotEnrolled
+	}
+	return s.users.SetTOTP(ctx, user.ID, "", s.now())
+}
+
+// Verify checks code against the user's confirmed secret, counting
+// failures and locking TOTP entry once MaxAttempts is reached.
+func (s *TOTPService) Verify(ctx context.Context, userID, code string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.HasTOTP() {
+		return domain.ErrTOTPNotEnrolled
+	}
+	return s.check(ctx, user, code)
+}
+
+// check accepts code if it matches a step later than the last one used. An
+// attempt is claimed before the code is checked, so concurrent guesses
+// cannot get past MaxAttempts; a replayed code counts like a wrong one.
+func (s *TOTPService) check(ctx context.Context, user *domain.User, code string) error {
+	now := s.now()
+	attempts, err := s.users.ClaimTOTPAttempt(ctx, user.ID, s.cfg.MaxAttempts, now)
+	if err != nil {
+		return err
+	}
+	if step, ok := auth.VerifyTOTP(user.TOTPSecret, code, now); ok {
+		if err := s.users.UseTOTPStep(ctx, user.ID, step, now); !errors.Is(err, domain.ErrInvalidOTP) {
+			return err
+		}
+	}
+	if attempts >= s.cfg.MaxAttempts {
+		if err := s.users.LockTOTP(ctx, user.ID, now.Add(s.cfg.Lockout)); err != nil {
+			return err
+		}
+		return domain.ErrTOTPLocked
+	}
+	return domain.ErrInvalidOTP
+}
+
+func (s *TOTPService) user(ctx context.Context, userID string) (*domain.User, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	return s.users.GetByID(ctx, userID)
+}
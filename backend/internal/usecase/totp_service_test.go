@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/auth"
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/infrastructure/memory"
+)
+
+func TestTOTPEnrollConfirmAndLockout(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewUserRepository(memory.NewStore())
+	u := &domain.User{EmailLower: "alice@example.com", UsernameLower: "alice", Status: domain.UserStatusActive}
+	if err := users.Create(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewTOTPService(users, TOTPConfig{Issuer: "Akiba", MaxAttempts: 3, Lockout: time.Hour})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	code := func() string {
+		stored, _ := users.GetByID(ctx, u.ID)
+		c, err := auth.TOTPCode(stored.TOTPSecret, auth.TOTPStep(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	if err := svc.Verify(ctx, u.ID, "123456"); !errors.Is(err, domain.ErrTOTPNotEnrolled) {
+		t.Fatalf("expected not enrolled, got %v", err)
+	}
+	enrollment, err := svc.Enroll(ctx, u.ID)
+	if err != nil || enrollment.Secret == "" || enrollment.URI == "" {
+		t.Fatalf("enroll: %+v %v", enrollment, err)
+	}
+	if err := svc.Verify(ctx, u.ID, code()); !errors.Is(err, domain.ErrTOTPNotEnrolled) {
+		t.Fatalf("an unconfirmed secret must not verify, got %v", err)
+	}
+	if _, err := svc.Confirm(ctx, u.ID, code()); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if _, err := svc.Enroll(ctx, u.ID); !errors.Is(err, domain.ErrTOTPAlreadyEnrolled) {
+		t.Fatalf("expected already enrolled, got %v", err)
+	}
+	if err := svc.Verify(ctx, u.ID, code()); !errors.Is(err, domain.ErrInvalidOTP) {
+		t.Fatalf("a code must not be accepted twice, got %v", err)
+	}
+	now = now.Add(auth.TOTPPeriod)
+	if err := svc.Verify(ctx, u.ID, code()); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if err := svc.Verify(ctx, u.ID, "000000"); !errors.Is(err, domain.ErrInvalidOTP) {
+		t.Fatalf("expected invalid code, got %v", err)
+	}
+	_ = svc.Verify(ctx, u.ID, "000000")
+	if err := svc.Verify(ctx, u.ID, "000000"); !errors.Is(err, domain.ErrTOTPLocked) {
+		t.Fatalf("expected lockout on third failure, got %v", err)
+	}
+	now = now.Add(auth.TOTPPeriod)
+	if err := svc.Verify(ctx, u.ID, code()); !errors.Is(err, domain.ErrTOTPLocked) {
+		t.Fatalf("correct code must not pass while locked, got %v", err)
+	}
+
+	if err := svc.Disable(ctx, u.ID); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if stored, _ := users.GetByID(ctx, u.ID); stored.TOTPSecret != "" || !stored.TOTPLockedUntil.IsZero() {
+		t.Fatalf("expected the secret and lockout removed: %+v", stored)
+	}
+}
@@ -0,0 +1,298 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+	"akiba/backend/internal/repository"
+	"akiba/backend/internal/risk"
+)
+
+const maxTransferNoteLength = 140
+
+type TransferInput struct {
+	SenderID  string
+	Recipient string
+	// BeneficiaryID pays a saved Akiba beneficiary instead of Recipient.
+	BeneficiaryID string
+	Amount        int64
+	Currency      string
+	Note          string
+	PromoCode     string
+	// DeviceID identifies the client device for risk scoring.
+	DeviceID string
+	// SteppedUp is set when the sender re-authenticated recently, which
+	// satisfies a step-up risk decision.
+	SteppedUp bool
+	// PIN confirms the payment once the sender has set a transaction PIN.
+	PIN string
+}
+type WithdrawalInput struct {
+	UserID   string
+	Amount   int64
+	Currency string
+	Phone    string
+	// BeneficiaryID pays out to a saved M-Pesa beneficiary instead of Phone.
+	BeneficiaryID string
+	PromoCode     string
+	PIN           string
+}
+type TransferResult struct {
+	Entry     *domain.JournalEntry
+	Recipient *domain.User
+	Amount    int64
+	Fee       int64
+	Currency  string
+}
+type WithdrawalResult struct {
+	Hold     *domain.Hold
+	Amount   int64
+	Fee      int64
+	Currency string
+	Phone    string
+}
+
+type TransferService struct {
+	users         repository.UserRepository
+	ledger        repository.LedgerRepository
+	fees          *FeeService
+	holds         *HoldService
+	beneficiaries *BeneficiaryService
+	risk          *RiskService
+	pins          *PINService
+}
+
+func NewTransferService(users repository.UserRepository, ledger repository.LedgerRepository, feeService *FeeService, holds *HoldService, beneficiaries *BeneficiaryService, riskService *RiskService, pins *PINService) *TransferService {
+	return &TransferService{users: users, ledger: ledger, fees: feeService, holds: holds, beneficiaries: beneficiaries, risk: riskService, pins: pins}
+}
+
+// Transfer moves Amount from the sender's wallet to the recipient's wallet
+// in the same currency. Recipient is an email, E.164 phone or username, or
+// BeneficiaryID names a saved payee; either way a recipient new to the
+// sender is subject to the cooling-off cap. The fee is charged on top and
+// posted to revenue.
+func (s *TransferService) Transfer(ctx context.Context, in TransferInput) (*TransferResult, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.SenderID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	fields := domain.FieldErrors{}
+	currency := domain.NormalizeCurrency(in.Currency)
+	note := strings.TrimSpace(in.Note)
+	if (strings.TrimSpace(in.Recipient) == "") == (in.BeneficiaryID == "") {
+		fields["recipient"] = "exactly one of recipient or beneficiaryId is required"
+	}
+	if _, ok := domain.LookupCurrency(currency); !ok {
+		fields["currency"] = "unsupported currency"
+	}
+	if problem := domain.AmountProblem(in.Amount); problem != "" {
+		fields["amount"] = problem
+	}
+	if len(note) > maxTransferNoteLength {
+		fields["note"] = "must be at most 140 characters"
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if err := requireCanSend(ctx, s.users, in.SenderID); err != nil {
+		return nil, nil, err
+	}
+	if fields, err := s.pins.verifyPayment(ctx, in.SenderID, in.PIN); err != nil {
+		return nil, fields, err
+	}
+	recipient, fields, err := s.resolveRecipient(ctx, in)
+	if err != nil {
+		return nil, fields, err
+	}
+	if !recipient.Status.CanReceive() {
+		return nil, nil, domain.ErrRecipientNotFound
+	}
+	if recipient.ID == in.SenderID {
+		return nil, domain.FieldErrors{"recipient": "cannot transfer to yourself"}, domain.ErrInvalidInput
+	}
+	riskDecisionID := ""
+	if s.risk != nil {
+		decision, err := s.risk.assessPayment(ctx, "transfer", RiskInput{UserID: in.SenderID, DeviceID: in.DeviceID, RecipientID: recipient.ID, Amount: in.Amount, Currency: currency, SteppedUp: in.SteppedUp})
+		if err != nil {
+			return nil, nil, err
+		}
+		switch risk.Decision(decision.Decision) {
+		case risk.DecisionBlock:
+			return nil, nil, domain.ErrRiskBlocked
+		case risk.DecisionStepUp:
+			if !decision.SteppedUp {
+				return nil, nil, domain.ErrStepUpRequired
+			}
+		}
+		riskDecisionID = decision.ID
+	}
+	fee := s.fees.quote(fees.TypeTransfer, currency, in.Amount, in.PromoCode)
+	total, fields, err := withFee(in.Amount, fee)
+	if err != nil {
+		return nil, fields, err
+	}
+	from, err := s.ledger.GetOrCreateAccount(ctx, in.SenderID, domain.AccountTypeWallet, currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := s.ledger.GetOrCreateAccount(ctx, recipient.ID, domain.AccountTypeWallet, currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	postings := []domain.Posting{
+		{AccountID: from.ID, Amount: -total, Currency: currency},
+		{AccountID: to.ID, Amount: in.Amount, Currency: currency},
+	}
+	postings, err = withFeeLeg(ctx, s.ledger, postings, currency, fee.Fee)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := &domain.JournalEntry{
+		Kind:      domain.EntryKindTransfer,
+		Postings:  postings,
+		Metadata:  feeMetadata(map[string]string{"senderId": in.SenderID, "recipientId": recipient.ID, "beneficiaryId": in.BeneficiaryID, "riskDecisionId": riskDecisionID, "note": note}, fee),
+		CreatedAt: time.Now().UTC(),
+	}
+	limit, err := s.beneficiaries.payeeCap(ctx, from, payeeUser, recipient.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if limit != nil {
+		err = s.ledger.PostWithinCap(ctx, entry, *limit)
+	} else {
+		err = s.ledger.Post(ctx, entry)
+	}
+	if err != nil {
+		return nil, s.refusedByCap(ctx, limit, err), err
+	}
+	return &TransferResult{Entry: entry, Recipient: recipient, Amount: in.Amount, Fee: fee.Fee, Currency: currency}, nil, nil
+}
+
+// resolveRecipient finds the payee by login or saved beneficiary.
+func (s *TransferService) resolveRecipient(ctx context.Context, in TransferInput) (*domain.User, domain.FieldErrors, error) {
+	lookup := func() (*domain.User, error) { return s.users.GetByLogin(ctx, normalizeLogin(in.Recipient)) }
+	if in.BeneficiaryID != "" {
+		b, fields, err := s.beneficiaries.authorizePayment(ctx, in.SenderID, in.BeneficiaryID, domain.BeneficiaryTypeAkiba)
+		if err != nil {
+			return nil, fields, err
+		}
+		lookup = func() (*domain.User, error) { return s.users.GetByID(ctx, b.RecipientID) }
+	}
+	recipient, err := lookup()
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, nil, domain.ErrRecipientNotFound
+	}
+	return recipient, nil, err
+}
+
+// Withdraw reserves the amount plus fee on the wallet while the
+// mobile-money rail delivers to Phone or a saved M-Pesa beneficiary, under
+// the cooling-off cap while the number is new to the user. Support captures
+// the hold into payout clearing once the rail confirms, or voids it if the
+// payout fails.
+func (s *TransferService) Withdraw(ctx context.Context, in WithdrawalInput) (*WithdrawalResult, domain.FieldErrors, error) {
+	if strings.TrimSpace(in.UserID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	fields := domain.FieldErrors{}
+	currency := domain.NormalizeCurrency(in.Currency)
+	phone := domain.NormalizePhone(in.Phone)
+	if in.BeneficiaryID != "" {
+		if phone != "" {
+			fields["phone"] = "must be empty when beneficiaryId is set"
+		}
+	} else if !domain.ValidatePhoneE164(phone) {
+		fields["phone"] = "must be valid E.164 format"
+	}
+	if _, ok := domain.LookupCurrency(currency); !ok {
+		fields["currency"] = "unsupported currency"
+	}
+	if problem := domain.AmountProblem(in.Amount); problem != "" {
+		fields["amount"] = problem
+	}
+	if len(fields) > 0 {
+		return nil, fields, domain.ErrInvalidInput
+	}
+	if err := requireCanSend(ctx, s.users, in.UserID); err != nil {
+		return nil, nil, err
+	}
+	if fields, err := s.pins.verifyPayment(ctx, in.UserID, in.PIN); err != nil {
+		return nil, fields, err
+	}
+	if in.BeneficiaryID != "" {
+		b, fields, err := s.beneficiaries.authorizePayment(ctx, in.UserID, in.BeneficiaryID, domain.BeneficiaryTypeMpesa)
+		if err != nil {
+			return nil, fields, err
+		}
+		phone = b.Phone
+	}
+	fee := s.fees.quote(fees.TypeWithdrawal, currency, in.Amount, in.PromoCode)
+	total, fields, err := withFee(in.Amount, fee)
+	if err != nil {
+		return nil, fields, err
+	}
+	wallet, err := s.ledger.GetOrCreateAccount(ctx, in.UserID, domain.AccountTypeWallet, currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	hold := &domain.Hold{
+		AccountID: wallet.ID,
+		OwnerID:   in.UserID,
+		Currency:  currency,
+		Amount:    total,
+		Kind:      domain.HoldKindWithdrawal,
+		Metadata:  feeMetadata(map[string]string{"userId": in.UserID, "phone": phone, "beneficiaryId": in.BeneficiaryID}, fee),
+	}
+	limit, err := s.beneficiaries.payeeCap(ctx, wallet, payeePhone, phone)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.holds.place(ctx, hold, limit); err != nil {
+		return nil, s.refusedByCap(ctx, limit, err), err
+	}
+	return &WithdrawalResult{Hold: hold, Amount: in.Amount, Fee: fee.Fee, Currency: currency, Phone: phone}, nil, nil
+}
+
+// refusedByCap explains err when the cooling-off cap refused the payment.
+func (s *TransferService) refusedByCap(ctx context.Context, limit *domain.PayeeCap, err error) domain.FieldErrors {
+	if limit == nil || !errors.Is(err, domain.ErrCoolingOffLimit) {
+		return nil
+	}
+	return s.beneficiaries.coolingOffFields(ctx, limit)
+}
+
+// withFee is amount plus its fee, refused when the total would pass
+// MaxAmount.
+func withFee(amount int64, q fees.Quote) (int64, domain.FieldErrors, error) {
+	if q.Fee > domain.MaxAmount-amount {
+		return 0, domain.FieldErrors{"amount": "is too large to cover its fee"}, domain.ErrInvalidInput
+	}
+	return amount + q.Fee, nil, nil
+}
+
+// withFeeLeg appends the credit of fee to the revenue account.
+func withFeeLeg(ctx context.Context, ledger repository.LedgerRepository, postings []domain.Posting, currency string, fee int64) ([]domain.Posting, error) {
+	if fee <= 0 {
+		return postings, nil
+	}
+	revenue, err := ledger.GetOrCreateAccount(ctx, domain.SystemOwnerRevenue, domain.AccountTypeSystem, currency)
+	if err != nil {
+		return nil, err
+	}
+	return append(postings, domain.Posting{AccountID: revenue.ID, Amount: fee, Currency: currency}), nil
+}
+
+// feeMetadata records how the fee was priced on the journal entry so a
+// charge can be explained later without re-evaluating the schedule.
+func feeMetadata(meta map[string]string, q fees.Quote) map[string]string {
+	meta["fee"] = strconv.FormatInt(q.Fee, 10)
+	if q.Waived > 0 {
+		meta["feeWaived"] = strconv.FormatInt(q.Waived, 10)
+		meta["promo"] = q.Promo
+	}
+	return meta
+}
@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/fees"
+)
+
+// testTransferFees charges a flat 100 per KES transfer and 1% (at least
+// 50) per withdrawal; promo FREE waives transfer fees.
+var testTransferFees = &fees.Schedule{
+	Rules:  []fees.Rule{{Type: fees.TypeTransfer, Currency: "KES", Flat: 100}, {Type: fees.TypeWithdrawal, Currency: "KES", PercentBps: 100, Min: 50}},
+	Promos: []fees.Promo{{Code: "FREE", Types: []fees.TransactionType{fees.TypeTransfer}, DiscountBps: 10000}},
+}
+
+func TestTransferChargesFeeToRevenue(t *testing.T) {
+	st := newTestStore()
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, nil)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice", PhoneE164: "+254700000001"})
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob", PhoneE164: "+254700000002"})
+	fund(t, st.ledger, alice, "KES", 10000)
+	res, fields, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: " BOB ", Amount: 5000, Currency: "kes"})
+	if err != nil {
+		t.Fatalf("transfer: err=%v fields=%#v", err, fields)
+	}
+	if res.Fee != 100 || res.Recipient.ID != bob {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+	sender, _ := st.ledger.GetOrCreateAccount(context.Background(), alice, domain.AccountTypeWallet, "KES")
+	revenue, _ := st.ledger.GetOrCreateAccount(context.Background(), domain.SystemOwnerRevenue, domain.AccountTypeSystem, "KES")
+	if sender.Balance != 4900 || revenue.Balance != 100 {
+		t.Fatalf("unexpected balances: sender=%d revenue=%d", sender.Balance, revenue.Balance)
+	}
+}
+
+func TestTransferPromoWaivesFee(t *testing.T) {
+	st := newTestStore()
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, nil)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice", PhoneE164: "+254700000001"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob", PhoneE164: "+254700000002"})
+	fund(t, st.ledger, alice, "KES", 5000)
+	res, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "bob@example.com", Amount: 5000, Currency: "KES", PromoCode: "free"})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if res.Fee != 0 || res.Entry.Metadata["feeWaived"] != "100" {
+		t.Fatalf("expected waived fee, got fee=%d meta=%#v", res.Fee, res.Entry.Metadata)
+	}
+}
+
+func TestFrozenUserReceivesButCannotSend(t *testing.T) {
+	st := newTestStore()
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, nil)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice", PhoneE164: "+254700000001"})
+	bob := addUser(t, st.users, domain.User{UsernameLower: "bob", PhoneE164: "+254700000002"})
+	fund(t, st.ledger, alice, "KES", 10000)
+	fund(t, st.ledger, bob, "KES", 10000)
+	setStatus(t, st.users, bob, domain.UserStatusFrozen)
+
+	if _, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "bob", Amount: 1000, Currency: "KES"}); err != nil {
+		t.Fatalf("expected frozen recipient paid, got %v", err)
+	}
+	if _, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: bob, Recipient: "alice", Amount: 1000, Currency: "KES"}); !errors.Is(err, domain.ErrAccountRestricted) {
+		t.Fatalf("expected frozen sender refused, got %v", err)
+	}
+	if _, _, err := svc.Withdraw(context.Background(), WithdrawalInput{UserID: bob, Phone: "+254700000002", Amount: 1000, Currency: "KES"}); !errors.Is(err, domain.ErrAccountRestricted) {
+		t.Fatalf("expected frozen withdrawal refused, got %v", err)
+	}
+	setStatus(t, st.users, bob, domain.UserStatusClosed)
+	if _, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "bob", Amount: 1000, Currency: "KES"}); !errors.Is(err, domain.ErrRecipientNotFound) {
+		t.Fatalf("expected closed recipient refused, got %v", err)
+	}
+}
+
+func TestTransferRejectsSelfAndInsufficientFunds(t *testing.T) {
+	st := newTestStore()
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, nil)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice", PhoneE164: "+254700000001"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob", PhoneE164: "+254700000002"})
+	if _, fields, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "alice", Amount: 100, Currency: "KES"}); !errors.Is(err, domain.ErrInvalidInput) || fields["recipient"] == "" {
+		t.Fatalf("expected self-transfer validation, got err=%v fields=%#v", err, fields)
+	}
+	if _, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "bob", Amount: 100, Currency: "KES"}); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+	if _, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "nobody", Amount: 100, Currency: "KES"}); !errors.Is(err, domain.ErrRecipientNotFound) {
+		t.Fatalf("expected recipient not found, got %v", err)
+	}
+}
+
+func TestWithdrawHoldsFundsUntilCaptured(t *testing.T) {
+	st := newTestStore()
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, nil)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice", PhoneE164: "+254700000001"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob", PhoneE164: "+254700000002"})
+	ops := addUser(t, st.users, domain.User{UsernameLower: "ops", PhoneE164: "+254700000009", Role: domain.UserRoleSupport})
+	fund(t, st.ledger, alice, "KES", 2000)
+	res, fields, err := svc.Withdraw(context.Background(), WithdrawalInput{UserID: alice, Amount: 1000, Currency: "KES", Phone: "+254711111111"})
+	if err != nil {
+		t.Fatalf("withdraw: err=%v fields=%#v", err, fields)
+	}
+	wallet, _ := st.ledger.GetOrCreateAccount(context.Background(), alice, domain.AccountTypeWallet, "KES")
+	if res.Fee != 50 || res.Hold.Amount != 1050 || wallet.Balance != 2000 || wallet.Available() != 950 {
+		t.Fatalf("unexpected withdrawal: fee=%d hold=%d balance=%d available=%d", res.Fee, res.Hold.Amount, wallet.Balance, wallet.Available())
+	}
+	if _, _, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "bob", Amount: 900, Currency: "KES"}); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("expected held funds to be unspendable, got %v", err)
+	}
+	if _, _, _, err := svc.holds.Capture(context.Background(), CaptureHoldInput{ActorID: alice, HoldID: res.Hold.ID}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customer capture to be refused, got %v", err)
+	}
+	// The payout may already be on the rail, so neither the customer nor the
+	// expiry sweep can hand the funds back.
+	if _, err := svc.holds.Void(context.Background(), alice, res.Hold.ID); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customer void of an in-flight withdrawal to be refused, got %v", err)
+	}
+	svc.holds.now = func() time.Time { return time.Now().UTC().Add(365 * 24 * time.Hour) }
+	if n, err := svc.holds.ExpireDue(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected withdrawal holds to never expire, released %d: %v", n, err)
+	}
+	if _, _, _, err := svc.holds.Capture(context.Background(), CaptureHoldInput{ActorID: ops, HoldID: res.Hold.ID}); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	clearing, _ := st.ledger.GetOrCreateAccount(context.Background(), domain.SystemOwnerPayoutClearing, domain.AccountTypeSystem, "KES")
+	wallet, _ = st.ledger.GetAccount(context.Background(), wallet.ID)
+	if clearing.Balance != 1000 || wallet.Balance != 950 || wallet.Held != 0 {
+		t.Fatalf("unexpected balances after capture: clearing=%d wallet=%d held=%d", clearing.Balance, wallet.Balance, wallet.Held)
+	}
+}
+
+func TestHugeAmountsCannotWrapTheLedger(t *testing.T) {
+	st := newTestStore()
+	svc := NewTransferService(st.users, st.ledger, NewFeeService(testTransferFees), NewHoldService(st.users, st.merchants, st.ledger, time.Hour, nil), nil, nil, nil)
+	alice := addUser(t, st.users, domain.User{UsernameLower: "alice", PhoneE164: "+254700000001"})
+	addUser(t, st.users, domain.User{UsernameLower: "bob", PhoneE164: "+254700000002"})
+	for _, amount := range []int64{math.MaxInt64 - 50, domain.MaxAmount} {
+		if _, fields, err := svc.Transfer(context.Background(), TransferInput{SenderID: alice, Recipient: "bob", Amount: amount, Currency: "KES"}); !errors.Is(err, domain.ErrInvalidInput) || fields["amount"] == "" {
+			t.Fatalf("transfer of %d: expected amount validation, got err=%v fields=%#v", amount, err, fields)
+		}
+		if _, fields, err := svc.Withdraw(context.Background(), WithdrawalInput{UserID: alice, Phone: "+254711111111", Amount: amount, Currency: "KES"}); !errors.Is(err, domain.ErrInvalidInput) || fields["amount"] == "" {
+			t.Fatalf("withdrawal of %d: expected amount validation, got err=%v fields=%#v", amount, err, fields)
+		}
+	}
+	sender, _ := st.ledger.GetOrCreateAccount(context.Background(), alice, domain.AccountTypeWallet, "KES")
+	if sender.Balance != 0 || sender.Held != 0 {
+		t.Fatalf("expected the sender untouched, got balance=%d held=%d", sender.Balance, sender.Held)
+	}
+
+	// The postings the unchecked sum produced: all credits, balanced only
+	// modulo 2^64.
+	wrapped := &domain.JournalEntry{Postings: []domain.Posting{
+		{AccountID: "a", Amount: math.MaxInt64 - 48, Currency: "KES"},
+		{AccountID: "b", Amount: math.MaxInt64 - 50, Currency: "KES"},
+		{AccountID: "c", Amount: 100, Currency: "KES"},
+	}}
+	if err := wrapped.Validate(); !errors.Is(err, domain.ErrUnbalancedEntry) {
+		t.Fatalf("expected an overflowing entry to be unbalanced, got %v", err)
+	}
+}
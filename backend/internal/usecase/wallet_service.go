@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/repository"
+)
+
+// AccountBalance is a wallet with the active holds that make its available
+// balance lower than its ledger balance.
+type AccountBalance struct {
+	Account *domain.Account
+	Holds   []*domain.Hold
+}
+
+type WalletService struct {
+	ledger repository.LedgerRepository
+}
+
+func NewWalletService(ledger repository.LedgerRepository) *WalletService {
+	return &WalletService{ledger: ledger}
+}
+
+// List returns the caller's wallet accounts, one per currency held, with
+// their active holds.
+func (s *WalletService) List(ctx context.Context, userID string) ([]AccountBalance, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	accounts, err := s.ledger.ListAccountsByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AccountBalance, 0, len(accounts))
+	for _, a := range accounts {
+		if a.Type != domain.AccountTypeWallet {
+			continue
+		}
+		holds, err := s.ledger.ListActiveHolds(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, AccountBalance{Account: a, Holds: holds})
+	}
+	return out, nil
+}
+
+// Open returns the caller's wallet in currency, creating it if needed.
+func (s *WalletService) Open(ctx context.Context, userID, currency string) (*domain.Account, domain.FieldErrors, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, nil, domain.ErrUnauthorized
+	}
+	currency = domain.NormalizeCurrency(currency)
+	if _, ok := domain.LookupCurrency(currency); !ok {
+		return nil, domain.FieldErrors{"currency": "unsupported currency"}, domain.ErrInvalidInput
+	}
+	account, err := s.ledger.GetOrCreateAccount(ctx, userID, domain.AccountTypeWallet, currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, nil, nil
+}
@@ -0,0 +1,358 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/events"
+	"akiba/backend/internal/repository"
+)
+
+const (
+	webhookDeliveryBatch        = 100
+	defaultWebhookMaxAttempts   = 10
+	webhookRetryBase            = 30 * time.Second
+	webhookRetryMax             = 6 * time.Hour
+	defaultWebhookSecretOverlap = 24 * time.Hour
+	maxWebhookSecretOverlap     = 7 * 24 * time.Hour
+	maxWebhookDeliveriesListed  = 100
+	maxWebhookAttemptError      = 300
+)
+
+type WebhookConfig struct {
+	// AllowHTTP accepts plain http endpoint URLs, for local development.
+	AllowHTTP bool
+	// MaxAttempts is how many failed calls mark a delivery failed.
+	MaxAttempts int
+}
+
+type CreateWebhookInput struct {
+	ActorID    string
+	ClientID   string
+	URL        string
+	EventTypes []string
+}
+
+type RotateWebhookSecretInput struct {
+	ActorID    string
+	EndpointID string
+	// Overlap is how long the previous secret keeps signing; zero means
+	// 24 hours.
+	Overlap time.Duration
+}
+
+// WebhookDeliveryResult counts what one DeliverDue call did.
+type WebhookDeliveryResult struct {
+	Succeeded int
+	Retrying  int
+	Failed    int
+}
+
+// WebhookService fans outbox events out to partner endpoints and delivers
+// them signed, retrying with exponential backoff. It is an
+// events.EventPublisher, so the outbox relay feeds it.
+type WebhookService struct {
+	webhooks repository.WebhookRepository
+	clients  repository.APIClientRepository
+	users    repository.UserRepository
+	sender   *events.WebhookSender
+	cfg      WebhookConfig
+	now      func() time.Time
+}
+
+func NewWebhookService(webhooks repository.WebhookRepository, clients repository.APIClientRepository, users repository.UserRepository, sender *events.WebhookSender, cfg WebhookConfig) *WebhookService {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	return &WebhookService{webhooks: webhooks, clients: clients, users: users, sender: sender, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// CreateEndpoint registers a URL for an active API client. The signing secret is
+// returned once and cannot be read back.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, in CreateWebhookInput) (*domain.WebhookEndpoint, string, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermWebhooksManage); err != nil {
+		return nil, "", nil, err
+	}
+	clientID, url := strings.TrimSpace(in.ClientID), strings.TrimSpace(in.URL)
+	types := domain.NormalizeEventTypes(in.EventTypes)
+	fields := domain.FieldErrors{}
+	switch client, err := s.clients.GetByID(ctx, clientID); {
+	case errors.Is(err, domain.ErrAPIClientNotFound):
+		fields["clientId"] = "unknown API client"
+	case err != nil:
+		return nil, "", nil, err
+	case client.Status != domain.APIClientActive:
+		fields["clientId"] = "API client is disabled"
+	}
+	if !domain.ValidateWebhookURL(url, s.cfg.AllowHTTP) {
+		fields["url"] = "must be an absolute https URL"
+	}
+	known := domain.KnownEventTypes()
+	if len(types) == 0 {
+		fields["eventTypes"] = "subscribe to at least one event type"
+	}
+	for _, t := range types {
+		if t != domain.WebhookEventAll && !slices.Contains(known, t) {
+			fields["eventTypes"] = "unknown event type " + string(t)
+			break
+		}
+	}
+	if len(fields) > 0 {
+		return nil, "", fields, domain.ErrInvalidInput
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	now := s.now()
+	endpoint := &domain.WebhookEndpoint{ClientID: clientID, URL: url, EventTypes: types, Secrets: []domain.WebhookSecret{{Secret: secret, CreatedAt: now}}, Status: domain.WebhookEndpointActive, CreatedBy: in.ActorID, CreatedAt: now, UpdatedAt: now}
+	if err := s.webhooks.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, "", nil, err
+	}
+	return endpoint, secret, nil, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, actorID, clientID string) ([]*domain.WebhookEndpoint, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	return s.webhooks.ListEndpoints(ctx, strings.TrimSpace(clientID))
+}
+
+// DisableEndpoint stops new deliveries to the endpoint; pending ones fail
+// when they come due.
+func (s *WebhookService) DisableEndpoint(ctx context.Context, actorID, endpointID string) (*domain.WebhookEndpoint, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	endpoint, err := s.webhooks.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := s.webhooks.SetEndpointStatus(ctx, endpoint.ID, domain.WebhookEndpointDisabled, now); err != nil {
+		return nil, err
+	}
+	endpoint.Status, endpoint.UpdatedAt = domain.WebhookEndpointDisabled, now
+	return endpoint, nil
+}
+
+// RotateSecret issues a new signing secret. Deliveries carry signatures
+// from both secrets until the overlap ends, so the partner can deploy the
+// new one without rejecting anything.
+func (s *WebhookService) RotateSecret(ctx context.Context, in RotateWebhookSecretInput) (*domain.WebhookEndpoint, string, domain.FieldErrors, error) {
+	if err := requirePermission(ctx, s.users, in.ActorID, domain.PermWebhooksManage); err != nil {
+		return nil, "", nil, err
+	}
+	overlap := in.Overlap
+	if overlap == 0 {
+		overlap = defaultWebhookSecretOverlap
+	}
+	if overlap < 0 || overlap > maxWebhookSecretOverlap {
+		return nil, "", domain.FieldErrors{"overlap": "must be positive and at most 168h"}, domain.ErrInvalidInput
+	}
+	endpoint, err := s.webhooks.GetEndpoint(ctx, in.EndpointID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	now := s.now()
+	var secrets []domain.WebhookSecret
+	for _, old := range endpoint.Secrets {
+		switch {
+		case old.ExpiresAt.IsZero():
+			old.ExpiresAt = now.Add(overlap)
+		case !now.Before(old.ExpiresAt):
+			continue
+		}
+		secrets = append(secrets, old)
+	}
+	secrets = append(secrets, domain.WebhookSecret{Secret: secret, CreatedAt: now})
+	if err := s.webhooks.SetSecrets(ctx, endpoint.ID, secrets, now); err != nil {
+		return nil, "", nil, err
+	}
+	endpoint.Secrets, endpoint.UpdatedAt = secrets, now
+	return endpoint, secret, nil, nil
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, actorID, endpointID string) ([]*domain.WebhookDelivery, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	if _, err := s.webhooks.GetEndpoint(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	return s.webhooks.ListDeliveries(ctx, endpointID, maxWebhookDeliveriesListed)
+}
+
+// Redeliver queues a delivery again with a fresh set of attempts, whatever
+// its status. Receivers dedupe on the event ID.
+func (s *WebhookService) Redeliver(ctx context.Context, actorID, deliveryID string) (*domain.WebhookDelivery, error) {
+	if err := requirePermission(ctx, s.users, actorID, domain.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	delivery, err := s.webhooks.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return s.requeue(ctx, delivery)
+}
+
+func (s *WebhookService) requeue(ctx context.Context, delivery *domain.WebhookDelivery) (*domain.WebhookDelivery, error) {
+	now := s.now()
+	if err := s.webhooks.Requeue(ctx, delivery.ID, now); err != nil {
+		return nil, err
+	}
+	delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.UpdatedAt = domain.WebhookDeliveryPending, 0, now, now
+	return delivery, nil
+}
+
+// ListClientEndpoints returns the calling API client's own endpoints.
+func (s *WebhookService) ListClientEndpoints(ctx context.Context, clientID string) ([]*domain.WebhookEndpoint, error) {
+	if err := requireClientScope(ctx, s.clients, clientID, domain.ScopeWebhooksRead); err != nil {
+		return nil, err
+	}
+	return s.webhooks.ListEndpoints(ctx, clientID)
+}
+
+// ListClientDeliveries is ListDeliveries for an endpoint the calling API
+// client owns; anyone else's is reported as not found.
+func (s *WebhookService) ListClientDeliveries(ctx context.Context, clientID, endpointID string) ([]*domain.WebhookDelivery, error) {
+	if err := requireClientScope(ctx, s.clients, clientID, domain.ScopeWebhooksRead); err != nil {
+		return nil, err
+	}
+	endpoint, err := s.webhooks.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.ClientID != clientID {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return s.webhooks.ListDeliveries(ctx, endpoint.ID, maxWebhookDeliveriesListed)
+}
+
+// RedeliverForClient is Redeliver for a delivery to the calling API
+// client's own endpoint.
+func (s *WebhookService) RedeliverForClient(ctx context.Context, clientID, deliveryID string) (*domain.WebhookDelivery, error) {
+	if err := requireClientScope(ctx, s.clients, clientID, domain.ScopeWebhooksWrite); err != nil {
+		return nil, err
+	}
+	delivery, err := s.webhooks.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.ClientID != clientID {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	return s.requeue(ctx, delivery)
+}
+
+// Publish queues a delivery of event to every active endpoint subscribed to
+// its type. A repeated event adds nothing, so the relay may retry freely.
+func (s *WebhookService) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	endpoints, err := s.webhooks.ListSubscribed(ctx, event.Type)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(events.NewEnvelope(event))
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, e := range endpoints {
+		d := &domain.WebhookDelivery{EndpointID: e.ID, ClientID: e.ClientID, EventID: event.ID, EventType: event.Type, Payload: payload, Status: domain.WebhookDeliveryPending, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+		if err := s.webhooks.CreateDelivery(ctx, d); err != nil && !errors.Is(err, domain.ErrDuplicateEntry) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverDue calls every endpoint with a due delivery once.
+func (s *WebhookService) DeliverDue(ctx context.Context) (WebhookDeliveryResult, error) {
+	var res WebhookDeliveryResult
+	deliveries, err := s.webhooks.ListDueDeliveries(ctx, s.now(), webhookDeliveryBatch)
+	if err != nil {
+		return res, err
+	}
+	endpoints := map[string]*domain.WebhookEndpoint{}
+	for _, d := range deliveries {
+		endpoint, ok := endpoints[d.EndpointID]
+		if !ok {
+			endpoint, err = s.webhooks.GetEndpoint(ctx, d.EndpointID)
+			if err != nil && !errors.Is(err, domain.ErrWebhookNotFound) {
+				return res, err
+			}
+			endpoints[d.EndpointID] = endpoint
+		}
+		status, err := s.deliver(ctx, endpoint, d)
+		if err != nil {
+			return res, err
+		}
+		switch status {
+		case domain.WebhookDeliverySucceeded:
+			res.Succeeded++
+		case domain.WebhookDeliveryFailed:
+			res.Failed++
+		default:
+			res.Retrying++
+		}
+	}
+	return res, nil
+}
+
+func (s *WebhookService) deliver(ctx context.Context, endpoint *domain.WebhookEndpoint, d *domain.WebhookDelivery) (domain.WebhookDeliveryStatus, error) {
+	now := s.now()
+	attempt := domain.WebhookAttempt{At: now}
+	attempts := d.Attempts + 1
+	if endpoint == nil || endpoint.Status != domain.WebhookEndpointActive {
+		attempt.Error = "endpoint disabled"
+		return domain.WebhookDeliveryFailed, s.webhooks.RecordAttempt(ctx, d.ID, attempt, domain.WebhookDeliveryFailed, attempts, now)
+	}
+	headers := map[string]string{"Akiba-Webhook-Id": d.ID, "Akiba-Event-Id": d.EventID, "Akiba-Event-Type": string(d.EventType)}
+	result, sendErr := s.sender.Send(ctx, endpoint.URL, endpoint.SigningSecrets(now), headers, d.Payload, now)
+	attempt.StatusCode, attempt.DurationMs = result.StatusCode, result.Duration.Milliseconds()
+	switch {
+	case sendErr != nil:
+		attempt.Error = sendErr.Error()
+	case result.StatusCode < 200 || result.StatusCode > 299:
+		attempt.Error = "non-2xx response"
+	default:
+		return domain.WebhookDeliverySucceeded, s.webhooks.RecordAttempt(ctx, d.ID, attempt, domain.WebhookDeliverySucceeded, attempts, now)
+	}
+	if len(attempt.Error) > maxWebhookAttemptError {
+		attempt.Error = attempt.Error[:maxWebhookAttemptError]
+	}
+	if attempts >= s.cfg.MaxAttempts {
+		return domain.WebhookDeliveryFailed, s.webhooks.RecordAttempt(ctx, d.ID, attempt, domain.WebhookDeliveryFailed, attempts, now)
+	}
+	return domain.WebhookDeliveryPending, s.webhooks.RecordAttempt(ctx, d.ID, attempt, domain.WebhookDeliveryPending, attempts, now.Add(webhookBackoff(attempts)))
+}
+
+// webhookBackoff doubles from webhookRetryBase after each failure, up to
+// webhookRetryMax.
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookRetryBase
+	for i := 1; i < attempts && d < webhookRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, webhookRetryMax)
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
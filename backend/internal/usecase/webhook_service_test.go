@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"akiba/backend/internal/domain"
+	"akiba/backend/internal/events"
+	"akiba/backend/internal/infrastructure/memory"
+	"akiba/backend/internal/repository"
+)
+
+// receiver is a partner endpoint that checks every request against the
+// secrets it knows, as a real partner would.
+type receiver struct {
+	mu       sync.Mutex
+	secrets  []string
+	now      func() time.Time
+	statuses []int
+	verified []string
+	rejected int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	ok := false
+	for _, s := range rc.secrets {
+		if events.VerifySignature(r.Header.Get(events.SignatureHeader), s, body, rc.now(), 5*time.Minute) == nil {
+			ok = true
+		}
+	}
+	if !ok {
+		rc.rejected++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	if status < 300 {
+		rc.verified = append(rc.verified, r.Header.Get("Akiba-Event-Id"))
+	}
+	w.WriteHeader(status)
+}
+
+// addAPIClient stores an active API client holding scopes and returns its
+// ID.
+func addAPIClient(t *testing.T, clients repository.APIClientRepository, name string, scopes []domain.Scope) string {
+	t.Helper()
+	client := &domain.APIClient{Name: name, Scopes: scopes, Status: domain.APIClientActive}
+	if err := clients.Create(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	return client.ID
+}
+
+// webhookDeliveries lists an endpoint's deliveries, oldest first.
+func webhookDeliveries(t *testing.T, repo *memory.WebhookRepository, endpointID string) []*domain.WebhookDelivery {
+	t.Helper()
+	list, err := repo.ListDeliveries(context.Background(), endpointID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Reverse(list)
+	return list
+}
+
+func signedUpEvent(id string) *domain.OutboxEvent {
+	e := domain.NewUserSignedUpEvent(&domain.User{ID: "u-" + id, UsernameLower: "user_" + id})
+	e.ID, e.Seq = id, 1
+	return e
+}
+
+func TestWebhookDeliveriesAreSignedAndRetried(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	clients, repo := memory.NewAPIClientRepository(st.Store), memory.NewWebhookRepository(st.Store)
+	svc := NewWebhookService(repo, clients, st.users, events.NewWebhookSender(time.Second), WebhookConfig{AllowHTTP: true, MaxAttempts: 3})
+	svc.now = func() time.Time { return now }
+	rc := &receiver{now: func() time.Time { return now }}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	customer := addUser(t, st.users, domain.User{UsernameLower: "customer"})
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	acme := addAPIClient(t, clients, "Acme", domain.KnownScopes())
+	globex := addAPIClient(t, clients, "Globex", []domain.Scope{domain.ScopeWebhooksRead})
+
+	if _, _, _, err := svc.CreateEndpoint(ctx, CreateWebhookInput{ActorID: customer, ClientID: acme, URL: srv.URL, EventTypes: []string{"transfer.completed"}}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected customer refused, got %v", err)
+	}
+	_, _, fields, err := svc.CreateEndpoint(ctx, CreateWebhookInput{ActorID: admin, ClientID: "initech", URL: srv.URL, EventTypes: []string{"transfer.complete"}})
+	if !errors.Is(err, domain.ErrInvalidInput) || fields["eventTypes"] == "" || fields["clientId"] == "" {
+		t.Fatalf("expected unknown client and event type refused, got %v %v", fields, err)
+	}
+	endpoint, secret, _, err := svc.CreateEndpoint(ctx, CreateWebhookInput{ActorID: admin, ClientID: acme, URL: srv.URL, EventTypes: []string{"user.signed_up"}})
+	if err != nil || len(secret) < 40 {
+		t.Fatalf("create endpoint: %v", err)
+	}
+	rc.secrets = []string{secret}
+
+	// Unsubscribed types fan out to nobody; a repeated event only once.
+	transfer := signedUpEvent("e0")
+	transfer.Type = "transfer.completed"
+	for _, e := range []*domain.OutboxEvent{transfer, signedUpEvent("e1"), signedUpEvent("e1")} {
+		if err := svc.Publish(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if list := webhookDeliveries(t, repo, endpoint.ID); len(list) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(list))
+	}
+
+	rc.statuses = []int{http.StatusInternalServerError}
+	if res, err := svc.DeliverDue(ctx); err != nil || res.Retrying != 1 {
+		t.Fatalf("expected retry, got %#v %v", res, err)
+	}
+	d := webhookDeliveries(t, repo, endpoint.ID)[0]
+	if d.Attempts != 1 || d.History[0].StatusCode != 500 || d.NextAttemptAt != now.Add(30*time.Second) {
+		t.Fatalf("expected failed attempt logged with backoff, got %#v", d)
+	}
+	now = now.Add(30 * time.Second)
+	if res, err := svc.DeliverDue(ctx); err != nil || res.Succeeded != 1 {
+		t.Fatalf("expected success, got %#v %v", res, err)
+	}
+	d, _ = repo.GetDelivery(ctx, d.ID)
+	if d.Status != domain.WebhookDeliverySucceeded || d.History[1].StatusCode != 200 || len(rc.verified) != 1 || rc.verified[0] != "e1" {
+		t.Fatalf("expected verified delivery, got %#v %v", d, rc.verified)
+	}
+
+	// A receiver that does not know the secret rejects the delivery; after
+	// MaxAttempts it fails for good until redelivered by hand.
+	rc.secrets = []string{"whsec_wrong"}
+	if err := svc.Publish(ctx, signedUpEvent("e2")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.DeliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Hour)
+	}
+	failed := webhookDeliveries(t, repo, endpoint.ID)[1]
+	if failed.Status != domain.WebhookDeliveryFailed || failed.Attempts != 3 || rc.rejected != 3 {
+		t.Fatalf("expected delivery exhausted, got %#v", failed)
+	}
+	rc.secrets = []string{secret}
+	if _, err := svc.Redeliver(ctx, admin, failed.ID); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := svc.DeliverDue(ctx)
+	if failed, _ = repo.GetDelivery(ctx, failed.ID); res.Succeeded != 1 || failed.Status != domain.WebhookDeliverySucceeded || len(failed.History) != 4 {
+		t.Fatalf("expected redelivery to succeed, got %#v", failed)
+	}
+	if list, err := svc.ListDeliveries(ctx, admin, endpoint.ID); err != nil || len(list) != 2 {
+		t.Fatalf("unexpected deliveries %v %v", list, err)
+	}
+
+	// The owning client sees and redelivers its own deliveries; another
+	// client neither, and read scope alone cannot redeliver.
+	if list, err := svc.ListClientDeliveries(ctx, acme, endpoint.ID); err != nil || len(list) != 2 {
+		t.Fatalf("unexpected client deliveries %v %v", list, err)
+	}
+	if _, err := svc.RedeliverForClient(ctx, acme, failed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ListClientDeliveries(ctx, globex, endpoint.ID); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Fatalf("expected another client's endpoint hidden, got %v", err)
+	}
+	if _, err := svc.RedeliverForClient(ctx, globex, failed.ID); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected redelivery without webhooks:write refused, got %v", err)
+	}
+}
+
+func TestWebhookSecretRotationOverlaps(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	clients := memory.NewAPIClientRepository(st.Store)
+	svc := NewWebhookService(memory.NewWebhookRepository(st.Store), clients, st.users, events.NewWebhookSender(time.Second), WebhookConfig{AllowHTTP: true, MaxAttempts: 3})
+	svc.now = func() time.Time { return now }
+	rc := &receiver{now: func() time.Time { return now }}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	admin := addUser(t, st.users, domain.User{UsernameLower: "admin", Role: domain.UserRoleAdmin})
+	acme := addAPIClient(t, clients, "Acme", domain.KnownScopes())
+	endpoint, oldSecret, _, err := svc.CreateEndpoint(ctx, CreateWebhookInput{ActorID: admin, ClientID: acme, URL: srv.URL, EventTypes: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newSecret, _, err := svc.RotateSecret(ctx, RotateWebhookSecretInput{ActorID: admin, EndpointID: endpoint.ID, Overlap: time.Hour})
+	if err != nil || newSecret == oldSecret {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	// During the overlap a partner still on the old secret and one already
+	// on the new secret both accept.
+	for i, secret := range []string{oldSecret, newSecret} {
+		rc.secrets = []string{secret}
+		if err := svc.Publish(ctx, signedUpEvent("r"+strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+		if res, _ := svc.DeliverDue(ctx); res.Succeeded != 1 {
+			t.Fatalf("expected delivery signed for secret %d, got %#v", i, res)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	rc.secrets = []string{oldSecret}
+	if err := svc.Publish(ctx, signedUpEvent("r2")); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := svc.DeliverDue(ctx); res.Retrying != 1 {
+		t.Fatalf("expected the old secret to stop working after the overlap, got %#v", res)
+	}
+}
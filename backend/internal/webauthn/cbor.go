@@ -0,0 +1,145 @@
+package webauthn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The CBOR subset WebAuthn needs: CTAP2 canonical encoding of integers, byte
+// and text strings, arrays, maps and the simple values false, true and null.
+// Integers decode to int64, maps to map[any]any keyed by int64 or string.
+
+const maxCBORDepth = 16
+
+var errCBOR = errors.New("invalid CBOR")
+
+// decodeCBOR decodes the first item in data and returns it with the number
+// of bytes it occupied.
+func decodeCBOR(data []byte) (any, int, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.item(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("%w: unsupported additional info %d", errCBOR, info)
+	}
+	if len(d.data)-d.pos < size {
+		return 0, 0, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+	for _, c := range d.data[d.pos : d.pos+size] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.pos += size
+	return major, arg, nil
+}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", errCBOR)
+	}
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("%w: string exceeds input", errCBOR)
+		}
+		b := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		if major == 3 {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+	case 4:
+		// Every element takes at least one byte.
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("%w: array exceeds input", errCBOR)
+		}
+		out := make([]any, 0, int(arg))
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos)/2 {
+			return nil, fmt.Errorf("%w: map exceeds input", errCBOR)
+		}
+		out := make(map[any]any, int(arg))
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%w: map key must be an integer or text", errCBOR)
+			}
+			if _, dup := out[k]; dup {
+				return nil, fmt.Errorf("%w: duplicate map key %v", errCBOR, k)
+			}
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: unsupported simple value %d", errCBOR, arg)
+	}
+	return nil, fmt.Errorf("%w: unsupported major type %d", errCBOR, major)
+}
@@ -0,0 +1,128 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for credentials.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms lists the algorithms offered in registration options,
+// most preferred first.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9052/9053).
+const (
+	coseKty  int64 = 1
+	coseAlg  int64 = 3
+	coseCrv  int64 = -1
+	coseX    int64 = -2
+	coseY    int64 = -3
+	coseN    int64 = -1
+	coseE    int64 = -2
+	ktyOKP   int64 = 1
+	ktyEC2   int64 = 2
+	ktyRSA   int64 = 3
+	crvP256  int64 = 1
+	crvEd255 int64 = 6
+)
+
+const minRSABits = 2048
+
+// PublicKey is a credential public key decoded from its COSE_Key form.
+type PublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key, accepting ES256 on P-256, EdDSA on
+// Ed25519 and RS256 with at least 2048-bit moduli.
+func ParsePublicKey(cose []byte) (*PublicKey, error) {
+	v, n, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[any]any)
+	if !ok || n != len(cose) {
+		return nil, fmt.Errorf("%w: malformed COSE key", ErrVerification)
+	}
+	kty, _ := m[coseKty].(int64)
+	alg, _ := m[coseAlg].(int64)
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[coseCrv].(int64)
+		x, _ := m[coseX].([]byte)
+		y, _ := m[coseY].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: bad P-256 key", ErrVerification)
+		}
+		// ecdh rejects points that are not on the curve.
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("%w: bad P-256 point", ErrVerification)
+		}
+		return &PublicKey{Algorithm: alg, key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}}, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[coseCrv].(int64)
+		x, _ := m[coseX].([]byte)
+		if crv != crvEd255 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad Ed25519 key", ErrVerification)
+		}
+		return &PublicKey{Algorithm: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		nb, _ := m[coseN].([]byte)
+		eb, _ := m[coseE].([]byte)
+		modulus := new(big.Int).SetBytes(nb)
+		exp := new(big.Int).SetBytes(eb)
+		if modulus.BitLen() < minRSABits || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: bad RSA key", ErrVerification)
+		}
+		return &PublicKey{Algorithm: alg, key: &rsa.PublicKey{N: modulus, E: int(exp.Int64())}}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported key type %d with algorithm %d", ErrVerification, kty, alg)
+}
+
+// Verify checks sig over data.
+func (k *PublicKey) Verify(data, sig []byte) error {
+	ok := false
+	switch pub := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = ecdsa.VerifyASN1(pub, digest[:], sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, data, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: bad signature", ErrVerification)
+	}
+	return nil
+}
+
+// x509Algorithm maps a COSE algorithm to the certificate signature
+// algorithm used to check packed attestation statements.
+func x509Algorithm(alg int64) (x509.SignatureAlgorithm, bool) {
+	switch alg {
+	case AlgES256:
+		return x509.ECDSAWithSHA256, true
+	case AlgEdDSA:
+		return x509.PureEd25519, true
+	case AlgRS256:
+		return x509.SHA256WithRSA, true
+	}
+	return x509.UnknownSignatureAlgorithm, false
+}
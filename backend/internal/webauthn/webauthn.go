@@ -0,0 +1,330 @@
+// Package webauthn verifies WebAuthn Level 2 registration (attestation
+// formats "none" and "packed") and authentication ceremonies for passkeys.
+// It is storage-agnostic: callers keep challenges and credentials.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrVerification wraps every ceremony that fails verification.
+	ErrVerification = errors.New("webauthn verification failed")
+	// ErrSignCount reports a signature counter that did not advance, which
+	// suggests a cloned authenticator.
+	ErrSignCount = errors.New("webauthn sign count did not increase")
+)
+
+// Authenticator data flags.
+const (
+	FlagUserPresent    byte = 0x01
+	FlagUserVerified   byte = 0x04
+	FlagBackupEligible byte = 0x08
+	FlagBackedUp       byte = 0x10
+	FlagAttestedData   byte = 0x40
+	FlagExtensionData  byte = 0x80
+)
+
+// Attestation types recorded with a credential.
+const (
+	AttestationNone  = "none"
+	AttestationSelf  = "self"
+	AttestationBasic = "basic"
+)
+
+const (
+	challengeBytes     = 32
+	maxCredentialIDLen = 1023
+)
+
+// Config identifies the relying party. Origins lists the exact origins
+// (scheme://host[:port]) clients may run the ceremonies from.
+type Config struct {
+	RPID                    string
+	RPName                  string
+	Origins                 []string
+	RequireUserVerification bool
+}
+
+// NewChallenge returns a fresh random challenge.
+func NewChallenge() ([]byte, error) {
+	b := make([]byte, challengeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Credential is a verified new credential to store for the user.
+type Credential struct {
+	ID              []byte
+	PublicKey       []byte
+	Algorithm       int64
+	SignCount       uint32
+	AAGUID          []byte
+	AttestationType string
+	BackupEligible  bool
+}
+
+type authenticatorData struct {
+	raw          []byte
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// VerifyRegistration checks a navigator.credentials.create() response
+// against the challenge issued for it.
+func (c Config) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := c.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+	v, n, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %v", ErrVerification, err)
+	}
+	obj, ok := v.(map[any]any)
+	if !ok || n != len(attestationObject) {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrVerification)
+	}
+	format, _ := obj["fmt"].(string)
+	stmt, _ := obj["attStmt"].(map[any]any)
+	rawAuthData, _ := obj["authData"].([]byte)
+	if stmt == nil {
+		return nil, fmt.Errorf("%w: missing attestation statement", ErrVerification)
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.flags&FlagAttestedData == 0 {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrVerification)
+	}
+	key, err := ParsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	cred := &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		Algorithm:      key.Algorithm,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		BackupEligible: authData.flags&FlagBackupEligible != 0,
+	}
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return nil, fmt.Errorf("%w: none attestation with a statement", ErrVerification)
+		}
+		cred.AttestationType = AttestationNone
+	case "packed":
+		if cred.AttestationType, err = verifyPacked(stmt, authData, clientDataJSON, key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported attestation format %q", ErrVerification, format)
+	}
+	return cred, nil
+}
+
+// VerifyAssertion checks a navigator.credentials.get() response signed by
+// the stored credential publicKey and returns the new signature counter.
+// Counters must increase unless the authenticator does not keep one (both
+// zero); anything else fails with ErrSignCount.
+func (c Config) VerifyAssertion(challenge, clientDataJSON, rawAuthData, signature, publicKey []byte, storedSignCount uint32) (uint32, error) {
+	if err := c.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := key.Verify(append(append([]byte(nil), rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return 0, err
+	}
+	if (authData.signCount != 0 || storedSignCount != 0) && authData.signCount <= storedSignCount {
+		return 0, ErrSignCount
+	}
+	return authData.signCount, nil
+}
+
+func (c Config) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var cd collectedClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: client data: %v", ErrVerification, err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: client data type %q", ErrVerification, cd.Type)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrVerification)
+	}
+	if !slices.Contains(c.Origins, cd.Origin) || cd.CrossOrigin {
+		return fmt.Errorf("%w: origin %q not allowed", ErrVerification, cd.Origin)
+	}
+	return nil
+}
+
+func (c Config) verifyAuthenticatorData(d *authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(d.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: RP ID mismatch", ErrVerification)
+	}
+	if d.flags&FlagUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", ErrVerification)
+	}
+	if c.RequireUserVerification && d.flags&FlagUserVerified == 0 {
+		return fmt.Errorf("%w: user not verified", ErrVerification)
+	}
+	if d.flags&FlagBackedUp != 0 && d.flags&FlagBackupEligible == 0 {
+		return fmt.Errorf("%w: backed up but not backup eligible", ErrVerification)
+	}
+	return nil
+}
+
+// parseAuthenticatorData splits authenticator data into its fields:
+// rpIdHash(32) flags(1) signCount(4) [aaguid(16) credIdLen(2) credId key]
+// [extensions].
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrVerification)
+	}
+	d := &authenticatorData{raw: b, rpIDHash: b[:32], flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	rest := b[37:]
+	if d.flags&FlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrVerification)
+		}
+		d.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > maxCredentialIDLen || len(rest) < idLen {
+			return nil, fmt.Errorf("%w: bad credential ID length", ErrVerification)
+		}
+		d.credentialID, rest = rest[:idLen], rest[idLen:]
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrVerification, err)
+		}
+		d.publicKey, rest = rest[:n], rest[n:]
+	}
+	if d.flags&FlagExtensionData != 0 {
+		v, n, err := decodeCBOR(rest)
+		if _, ok := v.(map[any]any); err != nil || !ok {
+			return nil, fmt.Errorf("%w: malformed extensions", ErrVerification)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrVerification)
+	}
+	return d, nil
+}
+
+// idFidoGenCeAAGUID is the certificate extension carrying the
+// authenticator's AAGUID.
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyPacked checks a packed attestation statement. With x5c the leaf
+// certificate must sign and meet the packed certificate requirements; the
+// chain is not evaluated against a trust store, so the result is recorded as
+// basic attestation. Without x5c the credential key must sign (self
+// attestation).
+func verifyPacked(stmt map[any]any, authData *authenticatorData, clientDataJSON []byte, key *PublicKey) (string, error) {
+	alg, _ := stmt["alg"].(int64)
+	sig, _ := stmt["sig"].([]byte)
+	if len(sig) == 0 {
+		return "", fmt.Errorf("%w: packed attestation without signature", ErrVerification)
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData.raw...), clientDataHash[:]...)
+	x5c, hasX5C := stmt["x5c"].([]any)
+	if !hasX5C {
+		if alg != key.Algorithm {
+			return "", fmt.Errorf("%w: self attestation algorithm mismatch", ErrVerification)
+		}
+		if err := key.Verify(signed, sig); err != nil {
+			return "", err
+		}
+		return AttestationSelf, nil
+	}
+	if len(x5c) == 0 {
+		return "", fmt.Errorf("%w: empty x5c", ErrVerification)
+	}
+	der, _ := x5c[0].([]byte)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", fmt.Errorf("%w: attestation certificate: %v", ErrVerification, err)
+	}
+	sigAlg, ok := x509Algorithm(alg)
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported attestation algorithm %d", ErrVerification, alg)
+	}
+	if err := cert.CheckSignature(sigAlg, signed, sig); err != nil {
+		return "", fmt.Errorf("%w: attestation signature: %v", ErrVerification, err)
+	}
+	if err := checkPackedCertificate(cert, authData.aaguid); err != nil {
+		return "", err
+	}
+	return AttestationBasic, nil
+}
+
+func checkPackedCertificate(cert *x509.Certificate, aaguid []byte) error {
+	subject := cert.Subject
+	if cert.Version != 3 || cert.IsCA || len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" || !slices.Contains(subject.OrganizationalUnit, "Authenticator Attestation") {
+		return fmt.Errorf("%w: attestation certificate does not meet packed requirements", ErrVerification)
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		var certAAGUID []byte
+		if ext.Critical || unmarshalOctets(ext, &certAAGUID) != nil || !bytes.Equal(certAAGUID, aaguid) {
+			return fmt.Errorf("%w: attestation certificate AAGUID mismatch", ErrVerification)
+		}
+	}
+	return nil
+}
+
+func unmarshalOctets(ext pkix.Extension, out *[]byte) error {
+	rest, err := asn1.Unmarshal(ext.Value, out)
+	if err == nil && len(rest) > 0 {
+		err = errors.New("trailing data")
+	}
+	return err
+}
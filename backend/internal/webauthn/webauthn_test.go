@@ -0,0 +1,148 @@
+package webauthn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"akiba/backend/internal/webauthn/webauthntest"
+)
+
+var testConfig = Config{RPID: "localhost", RPName: "Akiba", Origins: []string{"http://localhost:3000"}, RequireUserVerification: true}
+
+func newAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	t.Helper()
+	a, err := webauthntest.New("localhost", "http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func challenge(t *testing.T) []byte {
+	t.Helper()
+	c, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestVerifyRegistrationFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{webauthntest.FormatNone, AttestationNone},
+		{webauthntest.FormatPackedSelf, AttestationSelf},
+		{webauthntest.FormatPackedBasic, AttestationBasic},
+	} {
+		a := newAuthenticator(t)
+		ch := challenge(t)
+		clientData, attObj, err := a.Register(ch, tc.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred, err := testConfig.VerifyRegistration(ch, clientData, attObj)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if cred.AttestationType != tc.want || cred.Algorithm != AlgES256 || !bytes.Equal(cred.ID, a.CredentialID) || !bytes.Equal(cred.PublicKey, a.PublicKey()) {
+			t.Fatalf("%s: unexpected credential %#v", tc.format, cred)
+		}
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	ch := challenge(t)
+	for name, tamper := range map[string]func(a *webauthntest.Authenticator){
+		"origin":     func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" },
+		"rp id":      func(a *webauthntest.Authenticator) { a.RPID = "evil.example" },
+		"unverified": func(a *webauthntest.Authenticator) { a.UserVerified = false },
+	} {
+		a := newAuthenticator(t)
+		tamper(a)
+		clientData, attObj, err := a.Register(ch, webauthntest.FormatPackedSelf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testConfig.VerifyRegistration(ch, clientData, attObj); !errors.Is(err, ErrVerification) {
+			t.Fatalf("%s: expected verification failure, got %v", name, err)
+		}
+	}
+
+	a := newAuthenticator(t)
+	clientData, attObj, err := a.Register(ch, webauthntest.FormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testConfig.VerifyRegistration(challenge(t), clientData, attObj); !errors.Is(err, ErrVerification) {
+		t.Fatalf("expected challenge mismatch, got %v", err)
+	}
+	attObj[len(attObj)-1] ^= 0xff
+	if _, err := testConfig.VerifyRegistration(ch, clientData, attObj); !errors.Is(err, ErrVerification) {
+		t.Fatalf("expected tampered key to fail, got %v", err)
+	}
+}
+
+func TestVerifyAssertionAndSignCount(t *testing.T) {
+	a := newAuthenticator(t)
+	ch := challenge(t)
+	clientData, authData, sig, err := a.Assert(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := testConfig.VerifyAssertion(ch, clientData, authData, sig, a.PublicKey(), 0)
+	if err != nil || count != 1 {
+		t.Fatalf("expected count 1, got %d %v", count, err)
+	}
+
+	// A replayed or cloned authenticator reports a counter that has not
+	// moved past the stored one.
+	if _, err := testConfig.VerifyAssertion(ch, clientData, authData, sig, a.PublicKey(), 1); !errors.Is(err, ErrSignCount) {
+		t.Fatalf("expected sign count error, got %v", err)
+	}
+
+	other := newAuthenticator(t)
+	if _, err := testConfig.VerifyAssertion(ch, clientData, authData, sig, other.PublicKey(), 0); !errors.Is(err, ErrVerification) {
+		t.Fatalf("expected signature failure with another key, got %v", err)
+	}
+	if _, err := testConfig.VerifyAssertion(challenge(t), clientData, authData, sig, a.PublicKey(), 0); !errors.Is(err, ErrVerification) {
+		t.Fatalf("expected challenge mismatch, got %v", err)
+	}
+
+	// Registration responses cannot be replayed as assertions.
+	regData, _, err := a.Register(ch, webauthntest.FormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testConfig.VerifyAssertion(ch, regData, authData, sig, a.PublicKey(), 0); !errors.Is(err, ErrVerification) {
+		t.Fatalf("expected ceremony type mismatch, got %v", err)
+	}
+}
+
+func TestCounterlessAuthenticator(t *testing.T) {
+	a := newAuthenticator(t)
+	a.SignCount = ^uint32(0) // Assert wraps the counter to zero.
+	ch := challenge(t)
+	clientData, authData, sig, err := a.Assert(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := testConfig.VerifyAssertion(ch, clientData, authData, sig, a.PublicKey(), 0); err != nil || count != 0 {
+		t.Fatalf("expected counterless assertion to pass, got %d %v", count, err)
+	}
+}
+
+func TestDecodeCBORRejectsMalformed(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"truncated":     {0x62, 'a'},
+		"duplicate key": webauthntest.Map([2][]byte{webauthntest.Int(1), webauthntest.Int(1)}, [2][]byte{webauthntest.Int(1), webauthntest.Int(2)}),
+		"indefinite":    {0x9f, 0xff},
+		"huge array":    {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	} {
+		if _, _, err := decodeCBOR(data); !errors.Is(err, errCBOR) {
+			t.Fatalf("%s: expected CBOR error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package webauthntest provides a software authenticator that produces
+// WebAuthn registration and assertion responses for tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"time"
+)
+
+// Attestation formats the authenticator can produce.
+const (
+	FormatNone        = "none"
+	FormatPackedSelf  = "packed"
+	FormatPackedBasic = "packed-x5c"
+)
+
+// Authenticator is an ES256 platform authenticator holding one credential.
+// Tests tamper with the exported fields to exercise failure paths.
+type Authenticator struct {
+	RPID         string
+	Origin       string
+	AAGUID       []byte
+	CredentialID []byte
+	SignCount    uint32
+	UserVerified bool
+	key          *ecdsa.PrivateKey
+}
+
+func New(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	aaguid := []byte("akiba-test-auth!")
+	return &Authenticator{RPID: rpID, Origin: origin, AAGUID: aaguid, CredentialID: id, UserVerified: true, key: key}, nil
+}
+
+// Register answers a registration challenge with clientDataJSON and an
+// attestation object in the given format.
+func (a *Authenticator) Register(challenge []byte, format string) (clientDataJSON, attestationObject []byte, err error) {
+	clientDataJSON = a.clientData("webauthn.create", challenge)
+	authData := a.authData(true)
+	stmt := Map()
+	switch format {
+	case FormatPackedSelf, FormatPackedBasic:
+		sig, x5c, err := a.attest(authData, clientDataJSON, format == FormatPackedBasic)
+		if err != nil {
+			return nil, nil, err
+		}
+		pairs := [][2][]byte{{Text("alg"), Int(-7)}, {Text("sig"), Bytes(sig)}}
+		if x5c != nil {
+			pairs = append(pairs, [2][]byte{Text("x5c"), Array(Bytes(x5c))})
+		}
+		stmt = Map(pairs...)
+		format = "packed"
+	}
+	attestationObject = Map([2][]byte{Text("fmt"), Text(format)}, [2][]byte{Text("attStmt"), stmt}, [2][]byte{Text("authData"), Bytes(authData)})
+	return clientDataJSON, attestationObject, nil
+}
+
+// Assert answers an authentication challenge, advancing the sign count.
+func (a *Authenticator) Assert(challenge []byte) (clientDataJSON, authenticatorData, signature []byte, err error) {
+	a.SignCount++
+	clientDataJSON = a.clientData("webauthn.get", challenge)
+	authenticatorData = a.authData(false)
+	signature, err = a.sign(a.key, authenticatorData, clientDataJSON)
+	return clientDataJSON, authenticatorData, signature, err
+}
+
+// PublicKey returns the credential public key as a COSE_Key.
+func (a *Authenticator) PublicKey() []byte {
+	x := a.key.X.FillBytes(make([]byte, 32))
+	y := a.key.Y.FillBytes(make([]byte, 32))
+	return Map([2][]byte{Int(1), Int(2)}, [2][]byte{Int(3), Int(-7)}, [2][]byte{Int(-1), Int(1)}, [2][]byte{Int(-2), Bytes(x)}, [2][]byte{Int(-3), Bytes(y)})
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) []byte {
+	b, _ := json.Marshal(map[string]any{"type": ceremony, "challenge": base64.RawURLEncoding.EncodeToString(challenge), "origin": a.Origin, "crossOrigin": false})
+	return b
+}
+
+func (a *Authenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags := byte(0x01)
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	out := append([]byte(nil), rpIDHash[:]...)
+	if attested {
+		flags |= 0x40
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.SignCount)
+	if attested {
+		out = append(out, a.AAGUID...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.CredentialID)))
+		out = append(out, a.CredentialID...)
+		out = append(out, a.PublicKey()...)
+	}
+	return out
+}
+
+func (a *Authenticator) sign(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+// attest signs with the credential key (self attestation) or with a fresh
+// attestation key whose self-signed certificate meets the packed
+// requirements.
+func (a *Authenticator) attest(authData, clientDataJSON []byte, basic bool) (sig, x5c []byte, err error) {
+	signer := a.key
+	if basic {
+		if signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, nil, err
+		}
+		aaguidExt, err := asn1.Marshal(a.AAGUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:    big.NewInt(1),
+			Subject:         pkix.Name{Country: []string{"KE"}, Organization: []string{"Akiba Test"}, OrganizationalUnit: []string{"Authenticator Attestation"}, CommonName: "Akiba Test Authenticator"},
+			NotBefore:       time.Now().Add(-time.Hour),
+			NotAfter:        time.Now().Add(time.Hour),
+			ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}, Value: aaguidExt}},
+		}
+		if x5c, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &signer.PublicKey, signer); err != nil {
+			return nil, nil, err
+		}
+	}
+	sig, err = a.sign(signer, authData, clientDataJSON)
+	return sig, x5c, err
+}
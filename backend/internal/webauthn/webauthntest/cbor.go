@@ -0,0 +1,46 @@
+package webauthntest
+
+// Minimal canonical CBOR encoding for building fixtures. Each helper returns
+// one encoded item; Map keeps pairs in the order given.
+
+func head(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	case n <= 0xffffffff:
+		return []byte{major<<5 | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return []byte{major<<5 | 27, byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32), byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+func Int(v int64) []byte {
+	if v < 0 {
+		return head(1, uint64(-1-v))
+	}
+	return head(0, uint64(v))
+}
+
+func Bytes(b []byte) []byte { return append(head(2, uint64(len(b))), b...) }
+
+func Text(s string) []byte { return append(head(3, uint64(len(s))), s...) }
+
+func Array(items ...[]byte) []byte {
+	out := head(4, uint64(len(items)))
+	for _, it := range items {
+		out = append(out, it...)
+	}
+	return out
+}
+
+func Map(pairs ...[2][]byte) []byte {
+	out := head(5, uint64(len(pairs)))
+	for _, p := range pairs {
+		out = append(out, p[0]...)
+		out = append(out, p[1]...)
+	}
+	return out
+}